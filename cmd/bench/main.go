@@ -0,0 +1,58 @@
+// Command bench measures the processing pipeline on a synthetic chain: it generates the blocks, runs the
+// parser over them with an in-memory storage and reports the throughput, the memory and the notification
+// latency. Run it with the same flags before and after a change of the pipeline to measure a regression,
+// the JSON report of -json can be compared by a CI job. The parser logs are silenced unless -v is given.
+//
+// Usage:
+//
+//	go run ./cmd/bench -blocks 1000 -txs 200 -match-rate 0.01 -subscriptions 1000
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"eth-parser/internal/parser"
+)
+
+func main() {
+	var config parser.BenchmarkConfig
+	flag.IntVar(&config.Blocks, "blocks", 1000, "number of blocks of the synthetic chain")
+	flag.IntVar(&config.TransactionsPerBlock, "txs", 200, "transactions per block")
+	flag.Float64Var(&config.MatchRate, "match-rate", 0.01, "share of the transactions sent to a subscribed address, between 0 and 1")
+	flag.IntVar(&config.Subscriptions, "subscriptions", 1000, "number of subscribed addresses")
+	flag.IntVar(&config.MaxBlocksPerCycle, "blocks-per-cycle", 100, "blocks of a fetch cycle, 0 for a single cycle")
+	flag.Int64Var(&config.Seed, "seed", 1, "seed of the synthetic chain")
+	asJSON := flag.Bool("json", false, "write the report as JSON")
+	verbose := flag.Bool("v", false, "keep the parser logs")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	logs := log.Writer()
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+	report, err := parser.RunBenchmark(ctx, config)
+	log.SetOutput(logs)
+	if err != nil {
+		log.Fatalf("Benchmark failed: %v", err)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = report.Write(os.Stdout)
+	}
+	if err != nil {
+		log.Fatalf("Could not write the report: %v", err)
+	}
+}
@@ -2,31 +2,570 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
+	"eth-parser/internal/api"
 	"eth-parser/internal/parser"
 )
 
 func main() {
-	// Initialize the memory storage
-	storage := parser.NewMemoryStorage()
+	dev := flag.Bool("dev", false, "serve the Swagger UI at /docs")
+	networkName := flag.String("network", "mainnet", "network preset: mainnet, sepolia, holesky, polygon, gnosis, arbitrum, optimism or base")
+	flag.Parse()
+
+	// FEDERATION_MEMBERS runs an aggregator of remote deployments instead of a parser, e.g.
+	// mainnet=http://parser-mainnet:8080,polygon=http://parser-polygon:8080
+	if members := os.Getenv("FEDERATION_MEMBERS"); members != "" {
+		runFederation(members)
+		return
+	}
+
+	// The network preset provides the node URL, chain ID, block time and explorer links, ETH_RPC_URL overrides the node
+	network, err := parser.LookupNetwork(*networkName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if rpcURL := os.Getenv("ETH_RPC_URL"); rpcURL != "" {
+		network.RPCURL = rpcURL
+	}
+	// EXPLORER_URL replaces the explorer of the links, e.g. a self-hosted Blockscout, "none" removes them
+	switch explorerURL := os.Getenv("EXPLORER_URL"); explorerURL {
+	case "":
+	case "none":
+		network.ExplorerURL = ""
+	default:
+		network.ExplorerURL = explorerURL
+	}
+	// Each node endpoint has its own egress: proxy, DNS server and source address (ETH_RPC_* and ETH_WS_*)
+	client, err := parser.NewJsonRpcClientWithEgress(network.RPCURL, envEgress("ETH_RPC"))
+	if err != nil {
+		log.Fatalf("Invalid ETH_RPC egress: %v", err)
+	}
+	// and its own provider credentials (ETH_RPC_HEADERS, ETH_RPC_BEARER_TOKEN, ETH_RPC_BASIC_AUTH, ETH_RPC_QUERY_PARAMS)
+	if _, err := client.WithAuth(envAuth("ETH_RPC")); err != nil {
+		log.Fatalf("Invalid ETH_RPC auth: %v", err)
+	}
+	log.Printf("Using network %s (chain ID %d) at %s\n", network.Name, network.ChainID, network.RPCURL)
+
+	// Record the node traffic to RPC_RECORD_DIR for audit and replay, rotated every RPC_RECORD_MAX_MB and keeping
+	// the last RPC_RECORD_MAX_FILES files
+	var recorder *parser.FileRecorder
+	if recordDir := os.Getenv("RPC_RECORD_DIR"); recordDir != "" {
+		recorder, err = parser.NewFileRecorder(recordDir, int64(envInt("RPC_RECORD_MAX_MB", 100))<<20, envInt("RPC_RECORD_MAX_FILES", 10))
+		if err != nil {
+			log.Fatalf("Invalid RPC_RECORD_DIR: %v", err)
+		}
+		client.WithRecorder(recorder)
+	}
+	// Route methods to other providers with RPC_METHOD_ROUTES, e.g. eth_getLogs=https://a/KEY;eth_getBlockByNumber,
+	// eth_getBlockReceipts=https://b/KEY, the node serving the other methods and the failed requests
+	var rpcClient parser.JsonRpcClient = client
+	if routes := envSecret("RPC_METHOD_ROUTES"); routes != "" {
+		router, err := parser.NewMethodRouter(client, envMethodRoutes(routes, recorder))
+		if err != nil {
+			log.Fatalf("Invalid RPC_METHOD_ROUTES: %v", err)
+		}
+		rpcClient = router
+	}
+	// Replay a recording of RPC_REPLAY_DIR instead of querying the node
+	if replayDir := os.Getenv("RPC_REPLAY_DIR"); replayDir != "" {
+		files, err := parser.RecordingFiles(replayDir)
+		if err != nil {
+			log.Fatalf("Invalid RPC_REPLAY_DIR: %v", err)
+		}
+		exchanges, err := parser.LoadRPCRecording(files...)
+		if err != nil {
+			log.Fatalf("Could not load the recording: %v", err)
+		}
+		log.Printf("Replaying %d recorded requests of %s\n", len(exchanges), replayDir)
+		rpcClient = parser.NewReplayClient(exchanges)
+	}
+
+	// Open the storage backend of STORAGE_DSN, by default the memory storage, bounded when caps are configured
+	storageDSN := os.Getenv("STORAGE_DSN")
+	if storageDSN == "" {
+		storageDSN = fmt.Sprintf("memory://?max_tx_per_address=%d&max_tx=%d", envInt("MEMORY_MAX_TX_PER_ADDRESS", 0), envInt("MEMORY_MAX_TX", 0))
+	}
+	storage, err := parser.OpenStorage(context.Background(), storageDSN)
+	if err != nil {
+		log.Fatalf("Could not open the storage: %v", err)
+	}
+	// STORAGE_ENCODING=protobuf stores the payloads of a SQL storage in the compact protobuf encoding
+	if encoding := os.Getenv("STORAGE_ENCODING"); encoding != "" {
+		codec, err := parser.CodecByName(encoding)
+		if err != nil {
+			log.Fatalf("Invalid STORAGE_ENCODING: %v", err)
+		}
+		if sqlStorage, ok := storage.(*parser.SQLStorage); ok {
+			sqlStorage.WithCodec(codec)
+		}
+	}
+
+	// Journal the matched transactions to JOURNAL_FILE before storing them, the memory storage is rebuilt from it
+	var journal *parser.Journal
+	if journalPath := os.Getenv("JOURNAL_FILE"); journalPath != "" {
+		if _, memory := storage.(*parser.MemoryStorage); memory {
+			replay, err := parser.ReplayJournal(journalPath, storage)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Fatalf("Could not replay the journal: %v", err)
+			}
+			log.Printf("Replayed %d transactions of %d blocks from the journal\n", replay.Transactions, replay.Blocks)
+		}
+		if journal, err = parser.OpenJournal(journalPath); err != nil {
+			log.Fatalf("Could not open the journal: %v", err)
+		}
+	}
+
+	// Mirror the transactions, the outbox and the checkpoint to STORAGE_DUAL_WRITE_DSN during a migration to
+	// another backend, see cmd/migrate-storage. The reads and the other stores stay on STORAGE_DSN.
+	var parserStorage parser.Storage = storage
+	if dualDSN := os.Getenv("STORAGE_DUAL_WRITE_DSN"); dualDSN != "" {
+		secondary, err := parser.OpenStorage(context.Background(), dualDSN)
+		if err != nil {
+			log.Fatalf("Could not open the dual write storage: %v", err)
+		}
+		parserStorage = parser.NewDualWriteStorage(storage, secondary)
+		log.Println("Writing to both storages until the cutover")
+	}
 
 	// Create a context that will be canceled on shutdown
 	ctx := context.Background()
 
+	opts := []parser.Option{
+		parser.WithNetwork(network),
+		parser.WithEntityNotification(parser.NotifyEntityOnConsole),
+		parser.WithEventNotification(parser.NotifyEventOnConsole),
+		parser.WithDeploymentMonitoring(os.Getenv("AUTO_SUBSCRIBE_DEPLOYMENTS") == "true"),
+		parser.WithTokenMetadata(),
+		parser.WithCapabilityDetection(),
+	}
+	// Check the pending block every cycle for the replacements of the outgoing transactions, an extra node
+	// request per cycle, when PENDING_TRACKING=true
+	if os.Getenv("PENDING_TRACKING") == "true" {
+		opts = append(opts, parser.WithPendingTracking())
+	}
+	// Maintain the ERC-20 allowances of the subscribed addresses, an eth_getLogs request per block and the
+	// approval alerts, when ALLOWANCE_TRACKING=true
+	if os.Getenv("ALLOWANCE_TRACKING") == "true" {
+		opts = append(opts, parser.WithAllowanceTracking())
+	}
+	if journal != nil {
+		opts = append(opts, parser.WithJournal(journal))
+	}
+
+	// Blocks the node returns in an unexpected encoding are fetched again from FALLBACK_RPC_URL, if set
+	if fallbackURL := os.Getenv("FALLBACK_RPC_URL"); fallbackURL != "" {
+		fallbackClient, err := parser.NewJsonRpcClientWithEgress(fallbackURL, envEgress("FALLBACK_RPC"))
+		if err != nil {
+			log.Fatalf("Invalid FALLBACK_RPC egress: %v", err)
+		}
+		if _, err := fallbackClient.WithAuth(envAuth("FALLBACK_RPC")); err != nil {
+			log.Fatalf("Invalid FALLBACK_RPC auth: %v", err)
+		}
+		if recorder != nil {
+			fallbackClient.WithRecorder(recorder)
+		}
+		opts = append(opts, parser.WithFallbackClient(fallbackClient))
+	}
+
+	// Backfill the start blocks of the imported subscriptions from the Etherscan-compatible API of
+	// ETHERSCAN_API_URL, with at most ETHERSCAN_RPS requests per second, and fetch the blocks the node can't
+	// decode from it when FALLBACK_RPC_URL isn't set. ETHERSCAN_CHAIN_ID selects the chain of a multichain API.
+	if etherscanURL := os.Getenv("ETHERSCAN_API_URL"); etherscanURL != "" {
+		etherscan := parser.NewEtherscanClient(etherscanURL, envSecret("ETHERSCAN_API_KEY"), float64(envInt("ETHERSCAN_RPS", 5)))
+		if chainID := envInt("ETHERSCAN_CHAIN_ID", 0); chainID != 0 {
+			etherscan.WithChainID(int64(chainID))
+		}
+		opts = append(opts, parser.WithHistorySource(etherscan))
+		if os.Getenv("FALLBACK_RPC_URL") == "" {
+			opts = append(opts, parser.WithFallbackClient(etherscan))
+		}
+	}
+
+	// Switch to the first of SECONDARY_RPC_URLS ahead of the node when its head stalls for STALE_HEAD_AFTER,
+	// four block times of the network by default
+	if secondaryURLs := os.Getenv("SECONDARY_RPC_URLS"); secondaryURLs != "" && os.Getenv("RPC_REPLAY_DIR") == "" {
+		policy := parser.StaleHeadPolicy{StallAfter: envDuration("STALE_HEAD_AFTER", 4*network.BlockTime)}
+		for i, secondaryURL := range strings.Split(secondaryURLs, ",") {
+			secondary, err := parser.NewJsonRpcClientWithEgress(strings.TrimSpace(secondaryURL), envEgress("SECONDARY_RPC"))
+			if err != nil {
+				log.Fatalf("Invalid SECONDARY_RPC egress: %v", err)
+			}
+			if _, err := secondary.WithAuth(envAuth("SECONDARY_RPC")); err != nil {
+				log.Fatalf("Invalid SECONDARY_RPC auth: %v", err)
+			}
+			if recorder != nil {
+				secondary.WithRecorder(recorder)
+			}
+			// Named after the host only, a key in the URL path isn't logged
+			parsed, err := url.Parse(strings.TrimSpace(secondaryURL))
+			if err != nil {
+				log.Fatalf("Invalid SECONDARY_RPC_URLS entry %d", i+1)
+			}
+			policy.Providers = append(policy.Providers, parser.RPCProvider{Name: parsed.Host, Client: secondary})
+		}
+		opts = append(opts, parser.WithStaleHeadDetection(policy))
+	}
+
+	// Refuse to process blocks if the node is not on the expected chain, the one of the network preset by default
+	opts = append(opts, parser.WithChainID(int64(envInt("ETH_CHAIN_ID", int(network.ChainID)))))
+
+	// Label the well-known counterparties, with the bundled mainnet labels and the ones of LABELS_FILE
+	labels := parser.NewLabelDB()
+	if network.Name == "mainnet" {
+		labels = parser.NewBundledLabelDB()
+	}
+	if labelsFile := os.Getenv("LABELS_FILE"); labelsFile != "" {
+		if err := labels.LoadFile(labelsFile); err != nil {
+			log.Fatalf("Loading labels: %v", err)
+		}
+	}
+	opts = append(opts, parser.WithLabels(labels))
+	// Show the primary ENS names of the counterparties, cached for ENS_NAMES_TTL, e.g. 24h
+	if ttl := envDuration("ENS_NAMES_TTL", 0); ttl > 0 {
+		opts = append(opts, parser.WithENSNames(ttl))
+	}
+
+	// Tag the matched transactions (transfer, swap, mint, bridge deposit...), using the labels for the counterparties
+	opts = append(opts, parser.WithClassifier(parser.NewHeuristicClassifier(labels)))
+
+	// Screen the counterparties against the SCREENING_DENYLIST_FILE, e.g. the OFAC sanctioned addresses, and
+	// with the screening API at SCREENING_API_URL
+	if denylistFile := os.Getenv("SCREENING_DENYLIST_FILE"); denylistFile != "" {
+		denylist, err := parser.NewDenylistScreener(denylistFile)
+		if err != nil {
+			log.Fatalf("Loading the screening denylist: %v", err)
+		}
+		opts = append(opts, parser.WithScreener("denylist", denylist))
+	}
+	if screeningURL := os.Getenv("SCREENING_API_URL"); screeningURL != "" {
+		opts = append(opts, parser.WithScreener("api", parser.NewAPIScreener(screeningURL, envSecret("SCREENING_API_KEY"))))
+	}
+
+	// Register the contract ABIs of ABI_DIR for POST /contracts/call, named after their file, besides the ERC-20 one
+	if abiDir := os.Getenv("ABI_DIR"); abiDir != "" {
+		files, err := filepath.Glob(filepath.Join(abiDir, "*.json"))
+		if err != nil {
+			log.Fatalf("Listing the ABIs: %v", err)
+		}
+		for _, file := range files {
+			abi, err := parser.LoadABIFile(file)
+			if err != nil {
+				log.Fatalf("Loading the ABI %s: %v", file, err)
+			}
+			opts = append(opts, parser.WithContractABI(strings.TrimSuffix(filepath.Base(file), ".json"), abi))
+		}
+	}
+
+	// Annotate the transactions with the ETH/USD price at block time when a price provider is configured, and
+	// the token transfers with the price of the token symbols mapped in PRICE_TOKEN_IDS (CoinGecko coin IDs) or
+	// PRICE_TOKEN_FEEDS (Chainlink USD feed addresses)
+	switch os.Getenv("PRICE_PROVIDER") {
+	case "":
+		// No price enrichment
+	case "coingecko":
+		opts = append(opts, parser.WithPriceProvider(parser.NewCoinGeckoPriceProvider(os.Getenv("COINGECKO_API_KEY"), envAssetMap("PRICE_TOKEN_IDS"))))
+	case "chainlink":
+		opts = append(opts, parser.WithPriceProvider(parser.NewChainlinkPriceProvider(rpcClient, envAssetMap("PRICE_TOKEN_FEEDS"))))
+	default:
+		log.Fatalf("Invalid PRICE_PROVIDER %q, expected coingecko or chainlink", os.Getenv("PRICE_PROVIDER"))
+	}
+
+	// Track new heads over WebSocket when a WS endpoint is configured, polling stays as cross-check and fallback.
+	// WS_MAX_MESSAGE_SIZE caps the messages of the node, 16MB by default.
+	if wsURL := os.Getenv("ETH_WS_URL"); wsURL != "" {
+		heads, err := parser.NewWSHeadSubscriberWithEgress(wsURL, envEgress("ETH_WS"))
+		if err != nil {
+			log.Fatalf("Invalid ETH_WS egress: %v", err)
+		}
+		heads.WithMaxMessageSize(int64(envInt("WS_MAX_MESSAGE_SIZE", parser.DefaultWSMaxMessageSize)))
+		opts = append(opts, parser.WithHeadSubscriber(heads, 30*time.Second))
+	}
+
+	// Resume from the last processed block, the transactions caught up after a long downtime are notified
+	// according to RECOVERY_NOTIFICATIONS (deliver, suppress or historical)
+	if checkpoints, ok := parserStorage.(parser.CheckpointStore); ok {
+		opts = append(opts, parser.WithCheckpoint(checkpoints))
+	}
+	switch mode := os.Getenv("RECOVERY_NOTIFICATIONS"); mode {
+	case "", parser.RecoveryNotifyDeliver:
+		// Notified as the live transactions
+	case parser.RecoveryNotifySuppress, parser.RecoveryNotifyHistorical:
+		opts = append(opts, parser.WithRecoveryNotifications(mode))
+	default:
+		log.Fatalf("Invalid RECOVERY_NOTIFICATIONS %q, expected deliver, suppress or historical", mode)
+	}
+
+	// Handle the self-transfers and the zero-value transactions according to SELF_TRANSFERS and
+	// ZERO_VALUE_TRANSACTIONS (deliver, suppress or skip)
+	for variable, option := range map[string]func(string) parser.Option{
+		"SELF_TRANSFERS":          parser.WithSelfTransfers,
+		"ZERO_VALUE_TRANSACTIONS": parser.WithZeroValueTransactions,
+	} {
+		switch mode := os.Getenv(variable); mode {
+		case "", parser.TransactionsDeliver:
+			// Stored and notified as the other transactions
+		case parser.TransactionsSuppress, parser.TransactionsSkip:
+			opts = append(opts, option(mode))
+		default:
+			log.Fatalf("Invalid %s %q, expected deliver, suppress or skip", variable, mode)
+		}
+	}
+
+	// Retry the blocks failing processing and keep them as dead letters for a replay after BLOCK_ATTEMPTS attempts
+	if deadLetters, ok := storage.(parser.BlockDeadLetterStore); ok {
+		opts = append(opts, parser.WithBlockDeadLetters(deadLetters, envInt("BLOCK_ATTEMPTS", 3)))
+	}
+
+	// Retain the notifications for the pull consumers of GET /events, up to CONSUMER_RETENTION unacknowledged each
+	if consumers, ok := storage.(parser.ConsumerStore); ok {
+		opts = append(opts, parser.WithConsumers(consumers, envInt("CONSUMER_RETENTION", 10000)))
+	}
+
+	// Index the senders and recipients of every range of ACTIVITY_INDEX_BLOCKS blocks, keeping the last
+	// ACTIVITY_INDEX_RETAIN ranges (all of them when zero), so that the backfills skip the ranges without activity
+	// of their addresses; zero blocks, the default, disables the index
+	if blocks := envInt("ACTIVITY_INDEX_BLOCKS", 0); blocks > 0 {
+		if index, ok := storage.(parser.ActivityIndexStore); ok {
+			opts = append(opts, parser.WithActivityIndex(index, blocks, envInt("ACTIVITY_INDEX_RETAIN", 0)))
+		}
+	}
+
+	// Forward the RPC_PROXY_METHODS, comma separated, of POST /rpc to the node, "none" disables the proxy
+	switch methods := os.Getenv("RPC_PROXY_METHODS"); methods {
+	case "":
+	case "none":
+		opts = append(opts, parser.WithRPCProxy())
+	default:
+		opts = append(opts, parser.WithRPCProxy(strings.Split(methods, ",")...))
+	}
+
+	// Throttle the addresses notified more than NOTIFICATION_RATE_LIMIT times per NOTIFICATION_RATE_WINDOW,
+	// their transactions are delivered as one summary per window. It's opt-in, unset or zero disables it.
+	if limit := envInt("NOTIFICATION_RATE_LIMIT", 0); limit > 0 {
+		opts = append(opts, parser.WithNotificationThrottle(limit, envDuration("NOTIFICATION_RATE_WINDOW", time.Minute)))
+	}
+
+	// Match the transactions with ADDRESS_MATCHER, e.g. "bloom?fp=0.001" for very large watchlists
+	if spec := os.Getenv("ADDRESS_MATCHER"); spec != "" {
+		matcher, err := parser.ParseMatcher(spec)
+		if err != nil {
+			log.Fatalf("Invalid ADDRESS_MATCHER: %v", err)
+		}
+		opts = append(opts, parser.WithMatcher(matcher))
+	}
+
+	// Download up to PREFETCH_BLOCKS blocks ahead of the processing when catching up, zero disables it
+	opts = append(opts, parser.WithPrefetch(envInt("PREFETCH_BLOCKS", 8)))
+	// Download FETCH_WORKERS blocks at once, cap the node requests to RPC_RATE_LIMIT per second and read
+	// NOTIFICATION_BATCH_SIZE outbox events per dispatch round; PATCH /admin/config changes them while running
+	opts = append(opts, parser.WithFetchWorkers(envInt("FETCH_WORKERS", 1)),
+		parser.WithRPCRateLimit(float64(envInt("RPC_RATE_LIMIT", 0))),
+		parser.WithNotificationBatchSize(envInt("NOTIFICATION_BATCH_SIZE", 100)))
+
+	// Count the node requests per method and day, see GET /admin/usage, and project them against the plan of
+	// the provider, see envUsagePlan
+	if usage, ok := storage.(parser.UsageStore); ok {
+		opts = append(opts, parser.WithUsageStore(usage))
+	}
+	if plan, ok := envUsagePlan(); ok {
+		opts = append(opts, parser.WithUsagePlan(plan))
+	}
+
+	// Record who changed the subscriptions through the API, see GET /audit
+	if audit, ok := storage.(parser.AuditStore); ok {
+		opts = append(opts, parser.WithAuditLog(audit))
+	}
+
+	// Restart the background loops stuck for WATCHDOG_DEADLINE, zero disables the watchdog
+	if deadline := envDuration("WATCHDOG_DEADLINE", 5*time.Minute); deadline > 0 {
+		opts = append(opts, parser.WithWatchdog(deadline))
+	}
+
+	// Bound a fetch cycle to CYCLE_DEADLINE and checkpoint every CHECKPOINT_INTERVAL blocks within it
+	opts = append(opts, parser.WithCycleDeadline(envDuration("CYCLE_DEADLINE", time.Minute)),
+		parser.WithCheckpointInterval(envInt("CHECKPOINT_INTERVAL", 1)))
+	// Drain the pending notifications on shutdown for SHUTDOWN_DRAIN_TIMEOUT at most
+	opts = append(opts, parser.WithShutdownDrain(envDuration("SHUTDOWN_DRAIN_TIMEOUT", 10*time.Second)))
+
+	// Degrade the health after HEALTH_RPC_FAILURES consecutive failed node requests (down), a storage write error
+	// in the last HEALTH_STORAGE_ERROR_WINDOW or HEALTH_OUTBOX_BACKLOG pending notifications, see GET /healthz
+	opts = append(opts, parser.WithHealthThresholds(parser.HealthThresholds{
+		RPCFailures:        envInt("HEALTH_RPC_FAILURES", 5),
+		StorageErrorWindow: envDuration("HEALTH_STORAGE_ERROR_WINDOW", 5*time.Minute),
+		OutboxBacklog:      envInt("HEALTH_OUTBOX_BACKLOG", 1000),
+	}))
+
+	// Recompute the block hashes from the headers when the node isn't trusted
+	if os.Getenv("VERIFY_HEADERS") == "true" {
+		opts = append(opts, parser.WithHeaderVerification())
+	}
+
+	// Generate the daily or weekly activity reports, notified as events and kept for GET /reports
+	switch schedule := os.Getenv("REPORT_SCHEDULE"); schedule {
+	case "":
+	case parser.ReportDaily, parser.ReportWeekly:
+		opts = append(opts, parser.WithReports(schedule, envInt("REPORT_RETENTION", 0)))
+	default:
+		log.Fatalf("Invalid REPORT_SCHEDULE %q, expected daily or weekly", schedule)
+	}
+
+	// The admin endpoints are enabled by an admin key, multi-tenancy serves each tenant its own namespace. The
+	// admin key alone still enables multi-tenancy as it did before MULTI_TENANCY, MULTI_TENANCY=false keeps the
+	// admin endpoints on a single namespace.
+	var ethParser *parser.EthParser
+	var tenants *parser.TenantManager
+	adminKey := os.Getenv("ADMIN_API_KEY")
+	multiTenancy := adminKey != ""
+	switch os.Getenv("MULTI_TENANCY") {
+	case "":
+	case "true":
+		multiTenancy = true
+	case "false":
+		multiTenancy = false
+	default:
+		log.Fatalf("Invalid MULTI_TENANCY %q, expected true or false", os.Getenv("MULTI_TENANCY"))
+	}
+	if multiTenancy && adminKey == "" {
+		log.Fatal("MULTI_TENANCY requires ADMIN_API_KEY to manage the tenants")
+	}
+
+	// Send email notifications, as configured per subscription, when an SMTP server is configured
+	notify := parser.NotifyOnConsoleFor(network)
+	if smtpAddr := os.Getenv("SMTP_ADDR"); smtpAddr != "" {
+		emailNotifier := parser.NewEmailNotifier(parser.SMTPConfig{
+			Addr:     smtpAddr,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		}, func(address string) []parser.Subscription {
+			if tenants != nil {
+				return tenants.SubscriptionsFor(address)
+			}
+			if subscription, ok := ethParser.GetSubscription(address); ok {
+				return []parser.Subscription{subscription}
+			}
+			return nil
+		}).WithNetwork(network)
+		notify = parser.MultiNotify(notify, emailNotifier.Notify)
+		go emailNotifier.Run(ctx)
+		// Check the SMTP server before delivering the first notifications, for WARM_UP_TIMEOUT at most
+		opts = append(opts, parser.WithWarmUp("smtp", emailNotifier, envDuration("WARM_UP_TIMEOUT", time.Minute)))
+		// Send the buffered digests on shutdown
+		opts = append(opts, parser.WithFlusher("smtp", emailNotifier))
+	}
+
+	// Save the subscriptions to the storage and restore them at startup, the TenantStore saves the ones of the tenants
+	if subscriptionStore, ok := storage.(parser.SubscriptionStore); ok && !multiTenancy {
+		opts = append(opts, parser.WithSubscriptionStore(subscriptionStore))
+	}
+
+	// Write the export jobs of POST /admin/exports to EXPORT_DESTINATION, their download URLs being valid for
+	// EXPORT_URL_EXPIRY
+	if destination := os.Getenv("EXPORT_DESTINATION"); destination != "" {
+		opts = append(opts, parser.WithExportDestination(envExportDestination(destination), envDuration("EXPORT_URL_EXPIRY", time.Hour)))
+	}
+
+	// Fetch every FETCH_INTERVAL, e.g. 500ms, once per block of the network by default
+	fetchInterval := envDuration("FETCH_INTERVAL", network.BlockTime)
+	if fetchInterval <= 0 {
+		log.Fatalf("Invalid FETCH_INTERVAL %s, expected a positive duration", fetchInterval)
+	}
+	opts = append(opts, parser.WithFetchInterval(fetchInterval))
+
+	// Transaction quantities are decimal strings in the responses and the notifications, NUMBER_ENCODING=hex keeps the node encoding
+	numberEncoding := parser.NumberEncodingDecimal
+	switch os.Getenv("NUMBER_ENCODING") {
+	case "", parser.NumberEncodingDecimal:
+		// The default
+	case parser.NumberEncodingHex:
+		numberEncoding = parser.NumberEncodingHex
+	default:
+		log.Fatalf("Invalid NUMBER_ENCODING %q, expected decimal or hex", os.Getenv("NUMBER_ENCODING"))
+	}
+	opts = append(opts, parser.WithNotificationNumberEncoding(numberEncoding))
+
 	// Initialize the Ethereum parser with the memory storage and JsonRpc Client
-	ethParser := parser.NewEthParser(ctx, storage, 10, parser.NewJsonRpcClient(), parser.NotifyOnConsole)
+	ethParser = parser.New(parserStorage, 0, rpcClient, notify, opts...)
+	if err := ethParser.Start(ctx); err != nil {
+		log.Fatalf("Starting the parser: %v", err)
+	}
+
+	if multiTenancy {
+		tenants = parser.NewTenantManager(ethParser)
+		if tenantStore, ok := storage.(parser.TenantStore); ok {
+			tenants.WithStore(tenantStore)
+		}
+	}
+
+	// ADMIN_ADDR serves the admin endpoints, the metrics and the pprof profiles on their own listener, so that
+	// they can be firewalled; every request of that listener requires the admin key
+	adminAddr := os.Getenv("ADMIN_ADDR")
+	if adminAddr != "" && adminKey == "" {
+		log.Fatal("ADMIN_ADDR requires ADMIN_API_KEY to protect the admin listener")
+	}
 
 	//Setup Routes
-	SetupRoutes(ethParser)
+	apiOpts := []api.Option{api.WithAdminKey(adminKey), api.WithNumberEncoding(numberEncoding)}
+	if tenants != nil {
+		apiOpts = append(apiOpts, api.WithTenants(tenants))
+	}
+	// SHARE_LINK_SECRET signs the read-only share links, built on SHARE_PUBLIC_URL; changing it revokes them
+	if secret := envSecret("SHARE_LINK_SECRET"); secret != "" {
+		apiOpts = append(apiOpts, api.WithShareLinks(parser.ShareLinkSigner{Secret: []byte(secret)}, os.Getenv("SHARE_PUBLIC_URL")))
+	}
+	apiHandler := api.NewAPIHandler(ethParser, apiOpts...)
+	if adminAddr != "" {
+		apiHandler = api.PublicOnly(apiHandler)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", apiHandler)
+	if *dev {
+		api.SetupDocs(mux)
+	}
 
-	// Start the HTTP server in a goroutine
-	server := &http.Server{Addr: ":8080"}
+	// Start the HTTP servers in goroutines
+	// Write requests retried with the same Idempotency-Key replay the stored response within the window
+	idempotencyWindow := envDuration("IDEMPOTENCY_WINDOW", 24*time.Hour)
+	idempotent := func(next http.Handler) http.Handler { return next }
+	if store, ok := storage.(parser.IdempotencyStore); ok {
+		idempotent = func(next http.Handler) http.Handler {
+			return api.NewIdempotencyMiddleware(store, idempotencyWindow, next)
+		}
+	} else {
+		log.Println("The storage backend doesn't support the Idempotency-Key header, it's ignored")
+	}
+	// Restrict the admin and write endpoints to the networks of ACCESS_ALLOWED_IPS and/or to the bearer JWTs
+	// signed by a key of JWT_JWKS_URL, client addresses being read from X-Forwarded-For behind TRUSTED_PROXIES
+	guarded := func(next http.Handler) http.Handler { return next }
+	if policy := envAccessPolicy(); len(policy.AllowedNetworks) > 0 || policy.JWT != nil {
+		guarded = func(next http.Handler) http.Handler { return api.NewAccessMiddleware(policy, next) }
+	}
+	// Requests not matching the schemas of openapi.json are rejected with their violations, unless
+	// REQUEST_VALIDATION is off
+	validated := api.NewValidationMiddleware
+	if os.Getenv("REQUEST_VALIDATION") == "off" {
+		validated = func(next http.Handler) http.Handler { return next }
+	}
+	server := &http.Server{
+		Addr:    ":8080",
+		Handler: guarded(validated(idempotent(mux))),
+	}
 	go func() {
 		log.Println("Starting the HTTP server")
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -35,6 +574,20 @@ func main() {
 
 		log.Println("HTTP server stopped")
 	}()
+	var adminServer *http.Server
+	if adminAddr != "" {
+		adminServer = &http.Server{
+			Addr:    adminAddr,
+			Handler: guarded(validated(idempotent(api.NewAdminHandler(ethParser, apiOpts...)))),
+		}
+		go func() {
+			log.Printf("Starting the admin HTTP server on %s\n", adminAddr)
+			if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Could not listen on %s: %v\n", adminAddr, err)
+			}
+			log.Println("Admin HTTP server stopped")
+		}()
+	}
 
 	// Set up a channel to listen for interrupt or terminate signals from the OS
 	stop := make(chan os.Signal, 1)
@@ -49,52 +602,288 @@ func main() {
 	if err := server.Close(); err != nil {
 		log.Fatalf("Server Close: %v", err)
 	}
+	if adminServer != nil {
+		if err := adminServer.Close(); err != nil {
+			log.Fatalf("Admin Server Close: %v", err)
+		}
+	}
 
-	// Wait for parser goroutines to terminate.
-	ethParser.WaitForShutdown()
+	// Wait for parser goroutines to terminate, SHUTDOWN_TIMEOUT at most
+	stopCtx, cancel := context.WithTimeout(context.Background(), envDuration("SHUTDOWN_TIMEOUT", 30*time.Second))
+	defer cancel()
+	if err := ethParser.Stop(stopCtx); err != nil {
+		log.Fatalf("Parser did not stop in time: %v", err)
+	}
+	// Deliver the notifications left behind, SHUTDOWN_DRAIN_TIMEOUT at most, the failures are logged
+	ethParser.Drain()
+	if recorder != nil {
+		recorder.Close()
+	}
+	if journal != nil {
+		journal.Close()
+	}
+	if closer, ok := parserStorage.(io.Closer); ok {
+		closer.Close()
+	}
 	log.Println("Application gracefully stopped")
 }
 
-func SetupRoutes(ethParser parser.Parser) {
-	// Endpoint to get the current block number
-	http.HandleFunc("/current_block", func(w http.ResponseWriter, r *http.Request) {
-		block := ethParser.GetCurrentBlock()
-		json.NewEncoder(w).Encode(map[string]int{"current_block": block})
-	})
-
-	// Endpoint to subscribe to an Ethereum address
-	http.HandleFunc("/subscribe", func(w http.ResponseWriter, r *http.Request) {
-		var request map[string]string
-		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
-			return
-		}
-		address, ok := request["address"]
-		if !ok {
-			http.Error(w, "Address field is required", http.StatusBadRequest)
-			return
-		}
-		success := ethParser.Subscribe(address)
-		json.NewEncoder(w).Encode(map[string]bool{"success": success})
-	})
-
-	// Endpoint to get transactions for a subscribed address
-	http.HandleFunc("/transactions", func(w http.ResponseWriter, r *http.Request) {
-		var request map[string]string
-		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
-			return
-		}
-		address, ok := request["address"]
-		if !ok {
-			http.Error(w, "Address field is required", http.StatusBadRequest)
-			return
-		}
-		transactions := ethParser.GetTransactions(address)
-		if len(transactions) == 0 {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
-		json.NewEncoder(w).Encode(transactions)
-	})
+// runFederation serves the API of the federation members, restricted like the API of a parser by
+// ACCESS_ALLOWED_IPS and JWT_JWKS_URL, until the process is interrupted
+func runFederation(spec string) {
+	var members []api.FederationMember
+	for _, entry := range strings.Split(spec, ",") {
+		name, memberURL, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if _, err := url.ParseRequestURI(memberURL); !found || name == "" || err != nil {
+			log.Fatalf("Invalid FEDERATION_MEMBERS entry %q, expected name=url", entry)
+		}
+		members = append(members, api.FederationMember{Name: name, URL: memberURL})
+	}
+	// The timeout leaves room for the long polls of the members, 60 seconds at most
+	handler := api.NewFederationHandler(members, &http.Client{Timeout: envDuration("FEDERATION_TIMEOUT", 90*time.Second)})
+	if policy := envAccessPolicy(); len(policy.AllowedNetworks) > 0 || policy.JWT != nil {
+		handler = api.NewAccessMiddleware(policy, handler)
+	}
+	server := &http.Server{Addr: ":8080", Handler: handler}
+	go func() {
+		log.Printf("Starting the federation of %d members\n", len(members))
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Could not listen on :8080: %v\n", err)
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+	log.Println("Received shutdown signal")
+	if err := server.Close(); err != nil {
+		log.Fatalf("Server Close: %v", err)
+	}
+}
+
+// envInt reads an integer environment variable, returning def when it's not set
+func envInt(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("Invalid %s %q: %v", name, value, err)
+	}
+	return parsed
+}
+
+// envFloat reads a decimal environment variable, returning def when it's not set
+func envFloat(name string, def float64) float64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Fatalf("Invalid %s %q: %v", name, value, err)
+	}
+	return parsed
+}
+
+// envUsagePlan reads the plan of the node provider: RPC_PLAN_QUOTA units a month for RPC_PLAN_PRICE USD,
+// RPC_PLAN_PRICE_PER_MILLION units beyond the quota, and the units of a request per method in RPC_METHOD_UNITS
+// (method=units, comma separated), RPC_DEFAULT_UNITS for the other methods. ok is false when none is set.
+func envUsagePlan() (plan parser.UsagePlan, ok bool) {
+	for _, name := range []string{"RPC_PLAN_QUOTA", "RPC_PLAN_PRICE", "RPC_PLAN_PRICE_PER_MILLION", "RPC_METHOD_UNITS", "RPC_DEFAULT_UNITS"} {
+		ok = ok || os.Getenv(name) != ""
+	}
+	plan.MonthlyQuota = int64(envInt("RPC_PLAN_QUOTA", 0))
+	plan.MonthlyPrice = envFloat("RPC_PLAN_PRICE", 0)
+	plan.PricePerMillion = envFloat("RPC_PLAN_PRICE_PER_MILLION", 0)
+	plan.DefaultUnits = int64(envInt("RPC_DEFAULT_UNITS", 0))
+	if units := os.Getenv("RPC_METHOD_UNITS"); units != "" {
+		plan.MethodUnits = make(map[string]int64)
+		for _, entry := range strings.Split(units, ",") {
+			method, value, _ := strings.Cut(strings.TrimSpace(entry), "=")
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if method == "" || err != nil || parsed < 0 {
+				log.Fatalf("Invalid RPC_METHOD_UNITS entry %q, expected method=units", entry)
+			}
+			plan.MethodUnits[method] = parsed
+		}
+	}
+	return plan, ok
+}
+
+// envAssetMap reads a map of asset symbols to values from a variable of symbol=value entries, comma separated
+func envAssetMap(name string) map[string]string {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	assets := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		symbol, mapped, _ := strings.Cut(strings.TrimSpace(entry), "=")
+		if symbol == "" || mapped == "" {
+			log.Fatalf("Invalid %s entry %q, expected symbol=value", name, entry)
+		}
+		assets[symbol] = mapped
+	}
+	return assets
+}
+
+// envEgress reads the egress of a node endpoint from the <prefix>_PROXY, <prefix>_DNS and <prefix>_SOURCE_ADDR variables
+func envEgress(prefix string) parser.EgressConfig {
+	return parser.EgressConfig{
+		ProxyURL:   os.Getenv(prefix + "_PROXY"),
+		DNSServer:  os.Getenv(prefix + "_DNS"),
+		SourceAddr: os.Getenv(prefix + "_SOURCE_ADDR"),
+	}
+}
+
+// envAuth reads the provider credentials of a node endpoint from the <prefix>_HEADERS (name=value, comma
+// separated), <prefix>_BEARER_TOKEN, <prefix>_BASIC_AUTH (user:password) and <prefix>_QUERY_PARAMS (a URL
+// query) secrets, see envSecret
+func envAuth(prefix string) parser.EndpointAuth {
+	var auth parser.EndpointAuth
+	var err error
+	if headers := envSecret(prefix + "_HEADERS"); headers != "" {
+		if auth.Headers, err = parser.ParseHeaderList(headers); err != nil {
+			log.Fatalf("Invalid %s_HEADERS: %v", prefix, err)
+		}
+	}
+	auth.BearerToken = envSecret(prefix + "_BEARER_TOKEN")
+	if basic := envSecret(prefix + "_BASIC_AUTH"); basic != "" {
+		var found bool
+		if auth.Username, auth.Password, found = strings.Cut(basic, ":"); !found {
+			log.Fatalf("Invalid %s_BASIC_AUTH, expected user:password", prefix)
+		}
+	}
+	if params := envSecret(prefix + "_QUERY_PARAMS"); params != "" {
+		query, err := url.ParseQuery(params)
+		if err != nil {
+			log.Fatalf("Invalid %s_QUERY_PARAMS: the query can't be parsed", prefix)
+		}
+		auth.QueryParams = make(map[string]string, len(query))
+		for name := range query {
+			auth.QueryParams[name] = query.Get(name)
+		}
+	}
+	return auth
+}
+
+// envSecret reads a secret from the name environment variable, else from the file at <name>_FILE, e.g. a
+// mounted Docker or Kubernetes secret, without its trailing newline
+func envSecret(name string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Could not read %s_FILE: %v", name, err)
+	}
+	return strings.TrimRight(string(data), "\r\n")
+}
+
+// envDuration reads a duration environment variable (e.g. 30m), returning def when it's not set
+func envDuration(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid %s %q: %v", name, value, err)
+	}
+	return parsed
+}
+
+// envMethodRoutes parses the method routes of RPC_METHOD_ROUTES, methods=url entries separated by semicolons.
+// The endpoints share the egress of the node and are named after their host, a key in the URL path isn't logged.
+func envMethodRoutes(routes string, recorder *parser.FileRecorder) []parser.MethodRoute {
+	var methodRoutes []parser.MethodRoute
+	for i, entry := range strings.Split(routes, ";") {
+		methods, endpointURL, found := strings.Cut(strings.TrimSpace(entry), "=")
+		parsed, err := url.Parse(endpointURL)
+		if !found || methods == "" || err != nil || parsed.Host == "" {
+			log.Fatalf("Invalid RPC_METHOD_ROUTES entry %d, expected methods=url", i+1)
+		}
+		endpoint, err := parser.NewJsonRpcClientWithEgress(endpointURL, envEgress("ETH_RPC"))
+		if err != nil {
+			log.Fatalf("Invalid ETH_RPC egress: %v", err)
+		}
+		if recorder != nil {
+			endpoint.WithRecorder(recorder)
+		}
+		route := parser.MethodRoute{Name: parsed.Host, Client: endpoint}
+		for _, method := range strings.Split(methods, ",") {
+			route.Methods = append(route.Methods, strings.TrimSpace(method))
+		}
+		methodRoutes = append(methodRoutes, route)
+	}
+	return methodRoutes
+}
+
+// envExportDestination returns the export destination of a local directory, an s3://bucket/prefix or a
+// gs://bucket/prefix URL. The buckets are written with the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY secrets
+// (the HMAC keys for GCS) in AWS_REGION, S3_ENDPOINT addressing a compatible storage such as MinIO.
+// The local files are downloaded from the API at EXPORT_PUBLIC_URL with URLs signed with the
+// EXPORT_URL_SECRET secret, a random one invalidating them on restart when it's not set.
+func envExportDestination(destination string) parser.ExportDestination {
+	location, err := url.Parse(destination)
+	if err != nil {
+		log.Fatalf("Invalid EXPORT_DESTINATION: %v", err)
+	}
+	switch location.Scheme {
+	case "s3", "gs":
+		s3 := &parser.S3Destination{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("AWS_REGION"),
+			Bucket:    location.Host,
+			Prefix:    strings.Trim(location.Path, "/"),
+			AccessKey: envSecret("AWS_ACCESS_KEY_ID"),
+			SecretKey: envSecret("AWS_SECRET_ACCESS_KEY"),
+		}
+		if location.Scheme == "gs" {
+			s3.Endpoint, s3.Region = "https://storage.googleapis.com", "auto"
+		}
+		if s3.Region == "" {
+			log.Fatalf("AWS_REGION is required with EXPORT_DESTINATION %s", destination)
+		}
+		return s3
+	case "", "file":
+		secret := []byte(envSecret("EXPORT_URL_SECRET"))
+		if len(secret) == 0 {
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				log.Fatalf("Generating the export URL secret: %v", err)
+			}
+		}
+		return &parser.LocalDestination{Dir: location.Path, BaseURL: os.Getenv("EXPORT_PUBLIC_URL"), Secret: secret}
+	}
+	log.Fatalf("Invalid EXPORT_DESTINATION %q, expected a directory, s3:// or gs://", destination)
+	return nil
+}
+
+// envAccessPolicy reads the access policy of the admin and write endpoints from ACCESS_ALLOWED_IPS and
+// TRUSTED_PROXIES (CIDR networks or addresses, comma separated) and JWT_JWKS_URL, JWT_ISSUER, JWT_AUDIENCE and
+// JWT_LEEWAY
+func envAccessPolicy() api.AccessPolicy {
+	var policy api.AccessPolicy
+	var err error
+	for name, networks := range map[string]*[]netip.Prefix{"ACCESS_ALLOWED_IPS": &policy.AllowedNetworks, "TRUSTED_PROXIES": &policy.TrustedProxies} {
+		if *networks, err = api.ParseNetworks(os.Getenv(name)); err != nil {
+			log.Fatalf("Invalid %s: %v", name, err)
+		}
+	}
+	if jwksURL := os.Getenv("JWT_JWKS_URL"); jwksURL != "" {
+		policy.JWT = api.NewJWTValidator(api.JWTConfig{
+			JWKSURL:  jwksURL,
+			Issuer:   os.Getenv("JWT_ISSUER"),
+			Audience: os.Getenv("JWT_AUDIENCE"),
+			Leeway:   envDuration("JWT_LEEWAY", 30*time.Second),
+		}, &http.Client{Timeout: 10 * time.Second})
+	}
+	return policy
 }
@@ -1,3 +1,3 @@
 module eth-parser
 
-go 1.21.3
+go 1.22
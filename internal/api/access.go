@@ -0,0 +1,154 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// sensitiveRoutes are the admin, operator and write endpoints guarded by an AccessPolicy, with the audit log, the
+// export of the subscriptions and the contract calls through the node. The reads of the transactions stay public.
+var sensitiveRoutes = []string{
+	"/admin/",
+	"/debug/",
+	"GET /metrics",
+	"GET /audit",
+	"GET /subscriptions/export",
+	"POST /contracts/call",
+	"POST /subscribe",
+	"POST /subscriptions/import",
+	"PUT /subscriptions/{address}",
+	"DELETE /subscriptions/{address}",
+	"POST /subscriptions/{address}/test-notification",
+	"POST /subscriptions/{address}/mute",
+	"POST /subscriptions/{address}/share",
+	"DELETE /subscriptions/{address}/mute",
+	"POST /watch_tx",
+	"POST /consumers",
+	"DELETE /consumers/{name}",
+	"POST /events/ack",
+	"POST /entities/add",
+	"POST /entities/remove",
+}
+
+// AccessPolicy restricts the admin and write endpoints to allowed client networks and, optionally, to the
+// requests with a valid JWT, so that the service can be exposed without a gateway doing the authentication
+type AccessPolicy struct {
+	// AllowedNetworks are the client networks allowed on the sensitive endpoints, any when empty
+	AllowedNetworks []netip.Prefix
+	// TrustedProxies are the networks of the reverse proxies whose X-Forwarded-For header tells the client address
+	TrustedProxies []netip.Prefix
+	// JWT requires a bearer token signed by a key of its JWKS on the sensitive endpoints, if set
+	JWT *JWTValidator
+}
+
+// accessMiddleware enforces an AccessPolicy on the sensitive routes
+type accessMiddleware struct {
+	policy    AccessPolicy
+	sensitive *http.ServeMux
+	next      http.Handler
+}
+
+// NewAccessMiddleware wraps next with the policy: the sensitive requests from a client outside the allowed
+// networks are replied 403, those without a valid token 401. With a JWT validator the admin key is sent in
+// the X-Admin-Key header, the Authorization header carrying the token.
+func NewAccessMiddleware(policy AccessPolicy, next http.Handler) http.Handler {
+	sensitive := http.NewServeMux()
+	for _, pattern := range sensitiveRoutes {
+		sensitive.HandleFunc(pattern, func(http.ResponseWriter, *http.Request) {})
+	}
+	return &accessMiddleware{policy: policy, sensitive: sensitive, next: next}
+}
+
+// ServeHTTP checks the sensitive requests before passing them on
+func (m *accessMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := m.sensitive.Handler(r); pattern == "" {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+	if len(m.policy.AllowedNetworks) > 0 {
+		client, ok := m.clientAddr(r)
+		if !ok || !containsAddr(m.policy.AllowedNetworks, client) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+	if m.policy.JWT != nil {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found {
+			w.Header().Set("WWW-Authenticate", `Bearer`)
+			http.Error(w, "Missing bearer token", http.StatusUnauthorized)
+			return
+		}
+		if _, err := m.policy.JWT.Validate(token); err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, "Invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+	}
+	m.next.ServeHTTP(w, r)
+}
+
+// clientAddr returns the address of the client: the peer of the connection or, when it's a trusted proxy, the
+// last address of X-Forwarded-For that isn't a trusted proxy
+func (m *accessMiddleware) clientAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	addr = addr.Unmap()
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0 && containsAddr(m.policy.TrustedProxies, addr); i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if hop == "" {
+			continue
+		}
+		if addr, err = netip.ParseAddr(hop); err != nil {
+			return netip.Addr{}, false
+		}
+		addr = addr.Unmap()
+	}
+	return addr, true
+}
+
+// containsAddr reports whether one of the networks contains the address
+func containsAddr(networks []netip.Prefix, addr netip.Addr) bool {
+	for _, network := range networks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseNetworks parses a comma separated list of CIDR networks or single addresses, e.g.
+// "10.0.0.0/8,192.168.1.10"
+func ParseNetworks(list string) ([]netip.Prefix, error) {
+	var networks []netip.Prefix
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q", entry)
+			}
+			networks = append(networks, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		network, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", entry)
+		}
+		networks = append(networks, network.Masked())
+	}
+	return networks, nil
+}
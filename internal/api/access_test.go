@@ -0,0 +1,152 @@
+package api_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"eth-parser/internal/api"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// signToken returns a RS256 token of the claims signed by key
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestAccessMiddlewareJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	defer jwks.Close()
+
+	validator := api.NewJWTValidator(api.JWTConfig{JWKSURL: jwks.URL, Issuer: "https://issuer", Audience: "eth-parser"}, jwks.Client())
+	handler := api.NewAccessMiddleware(api.AccessPolicy{JWT: validator}, api.NewAPIHandler(newParser()))
+	valid := map[string]interface{}{"iss": "https://issuer", "aud": []string{"eth-parser"}, "sub": "ops", "exp": time.Now().Add(time.Hour).Unix()}
+	expired := map[string]interface{}{"iss": "https://issuer", "aud": "eth-parser", "exp": time.Now().Add(-time.Hour).Unix()}
+	otherAudience := map[string]interface{}{"iss": "https://issuer", "aud": "other", "exp": time.Now().Add(time.Hour).Unix()}
+
+	for name, test := range map[string]struct {
+		token string
+		code  int
+	}{
+		"missing":        {"", http.StatusUnauthorized},
+		"valid":          {signToken(t, key, "k1", valid), http.StatusOK},
+		"expired":        {signToken(t, key, "k1", expired), http.StatusUnauthorized},
+		"other audience": {signToken(t, key, "k1", otherAudience), http.StatusUnauthorized},
+		"unknown key":    {signToken(t, key, "k2", valid), http.StatusUnauthorized},
+	} {
+		header := map[string]string{}
+		if test.token != "" {
+			header["Authorization"] = "Bearer " + test.token
+		}
+		if rec := serve(handler, http.MethodPost, "/subscribe", `{"address":"0x1"}`, header); rec.Code != test.code {
+			t.Errorf("%s token: expected %d, got %d: %s", name, test.code, rec.Code, rec.Body)
+		}
+	}
+	// The reads don't require a token, unlike the audit log, the export and the contract calls
+	if rec := serve(handler, http.MethodGet, "/current_block", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected a public read, got %d", rec.Code)
+	}
+	for _, route := range []struct{ method, path string }{
+		{http.MethodGet, "/audit"}, {http.MethodGet, "/subscriptions/export"}, {http.MethodPost, "/contracts/call"},
+	} {
+		if rec := serve(handler, route.method, route.path, "", nil); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s: expected 401 without a token, got %d", route.method, route.path, rec.Code)
+		}
+	}
+}
+
+func TestJWTValidatorSharedFetch(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches atomic.Int32
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	defer jwks.Close()
+
+	// The tokens validated while the JWKS is fetched wait for the same fetch
+	validator := api.NewJWTValidator(api.JWTConfig{JWKSURL: jwks.URL}, nil)
+	token := signToken(t, key, "k1", map[string]interface{}{"exp": time.Now().Add(time.Hour).Unix()})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := validator.Validate(token); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("Expected a single fetch of the JWKS, got %d", got)
+	}
+}
+
+func TestAccessMiddlewareAllowedNetworks(t *testing.T) {
+	allowed, err := api.ParseNetworks("10.0.0.0/8, 192.168.1.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxies, _ := api.ParseNetworks("172.16.0.1")
+	handler := api.NewAccessMiddleware(api.AccessPolicy{AllowedNetworks: allowed, TrustedProxies: proxies}, api.NewAPIHandler(newParser()))
+
+	for _, test := range []struct {
+		remoteAddr, forwardedFor string
+		code                     int
+	}{
+		{"10.1.2.3:4000", "", http.StatusOK},
+		{"192.168.1.11:4000", "", http.StatusForbidden},
+		// The address forwarded by a trusted proxy is the client, not the one of an untrusted peer
+		{"172.16.0.1:4000", "203.0.113.5, 192.168.1.10", http.StatusOK},
+		{"203.0.113.5:4000", "10.1.2.3", http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodDelete, "/subscriptions/0x1", nil)
+		req.RemoteAddr = test.remoteAddr
+		if test.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", test.forwardedFor)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code == http.StatusForbidden != (test.code == http.StatusForbidden) {
+			t.Errorf("%s forwarding %q: expected %d, got %d", test.remoteAddr, test.forwardedFor, test.code, rec.Code)
+		}
+	}
+	if rec := serve(handler, http.MethodGet, "/subscriptions/0x1", "", nil); rec.Code == http.StatusForbidden {
+		t.Errorf("Expected the other methods of a route to stay public")
+	}
+}
@@ -0,0 +1,261 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"strings"
+
+	"eth-parser/internal/parser"
+)
+
+// checkAdmin replies 404 when no admin key is configured and 401 when the admin key of the request is invalid
+func (s *apiServer) checkAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.adminKey == "" {
+		http.NotFound(w, r)
+		return false
+	}
+	if !validAdminKey(r, s.adminKey) {
+		http.Error(w, "Missing or invalid admin key", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// validAdminKey reports whether the request carries the admin key, in the X-Admin-Key header or as a bearer
+// token of the Authorization header, e.g. for a Prometheus scrape
+func validAdminKey(r *http.Request, adminKey string) bool {
+	key := r.Header.Get("X-Admin-Key")
+	if key == "" {
+		key = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) == 1
+}
+
+// NewAdminHandler returns the handler of an admin listener: the admin endpoints, the Prometheus metrics, the
+// readiness probe and the pprof profiles, all requiring the admin key of WithAdminKey so that the listener can
+// be exposed to the operators only. The public handler is then wrapped with PublicOnly.
+func NewAdminHandler(ethParser *parser.EthParser, opts ...Option) http.Handler {
+	s := newAPIServer(ethParser, opts...)
+	api := http.NewServeMux()
+	RegisterHandlers(api, s)
+	mux := http.NewServeMux()
+	mux.Handle("/admin/", api)
+	mux.Handle("GET /metrics", metricsHandler(ethParser, s.storage))
+	mux.Handle("GET /readyz", readinessHandler(ethParser))
+	mux.Handle("GET /healthz", healthHandler(ethParser))
+	mux.HandleFunc("GET /debug/pprof/", pprof.Index)
+	mux.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+	return requireAdminKey(s.adminKey, mux)
+}
+
+// requireAdminKey replies 401 to the requests without the admin key, to all of them when it's empty
+func requireAdminKey(adminKey string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if adminKey == "" || !validAdminKey(r, adminKey) {
+			http.Error(w, "Missing or invalid admin key", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// PublicOnly replies 404 to the admin paths, the metrics and the health report of the handler of NewAPIHandler,
+// when they're served by the handler of NewAdminHandler
+func PublicOnly(api http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/admin/") || r.URL.Path == "/metrics" || r.URL.Path == "/healthz" {
+			http.NotFound(w, r)
+			return
+		}
+		api.ServeHTTP(w, r)
+	})
+}
+
+// GetStorageStats returns the size of the stored data
+func (s *apiServer) GetStorageStats(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAdmin(w, r) {
+		return
+	}
+	stats, err := s.storage.Stats()
+	if err != nil {
+		http.Error(w, "Could not read the storage stats", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(stats)
+}
+
+// GetUsage returns the node requests of the month and their projection against the plan of the provider
+func (s *apiServer) GetUsage(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAdmin(w, r) {
+		return
+	}
+	json.NewEncoder(w).Encode(s.ethParser.UsageReport())
+}
+
+// GetRuntimeConfig returns the knobs tunable while running
+func (s *apiServer) GetRuntimeConfig(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAdmin(w, r) {
+		return
+	}
+	json.NewEncoder(w).Encode(s.ethParser.Config())
+}
+
+// UpdateRuntimeConfig changes the given knobs without a restart
+func (s *apiServer) UpdateRuntimeConfig(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAdmin(w, r) {
+		return
+	}
+	var update parser.RuntimeConfigUpdate
+	if !decodeRequest(w, r, &update) {
+		return
+	}
+	config, err := s.ethParser.UpdateConfig(update)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(config)
+}
+
+// GetDecodeFailures returns the last blocks that could only be partially decoded
+func (s *apiServer) GetDecodeFailures(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAdmin(w, r) {
+		return
+	}
+	json.NewEncoder(w).Encode(s.ethParser.DecodeFailures())
+}
+
+// Rescan runs the matching again over already processed blocks
+func (s *apiServer) Rescan(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAdmin(w, r) {
+		return
+	}
+	var request parser.RescanRequest
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+	result, err := s.ethParser.Rescan(request)
+	if errors.Is(err, parser.ErrInvalidRescan) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error rescanning blocks %d to %d: %v\n", request.FromBlock, request.ToBlock, err)
+		http.Error(w, "Rescan failed", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// LookupActivity returns the blocks where an address may have been active, from the activity index
+func (s *apiServer) LookupActivity(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAdmin(w, r) {
+		return
+	}
+	query := r.URL.Query()
+	address := query.Get("address")
+	if address == "" {
+		http.Error(w, "Missing address", http.StatusBadRequest)
+		return
+	}
+	var blocks [2]int
+	for i, name := range []string{"fromBlock", "toBlock"} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return
+			}
+			blocks[i] = parsed
+		}
+	}
+	lookup, err := s.ethParser.LookupActivity(address, blocks[0], blocks[1])
+	switch {
+	case errors.Is(err, parser.ErrActivityIndexDisabled):
+		http.NotFound(w, r)
+		return
+	case errors.Is(err, parser.ErrInvalidRescan):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Printf("Error looking up the activity of address %s: %v\n", address, err)
+		http.Error(w, "Could not read the activity index", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(lookup)
+}
+
+// ListBlockDeadLetters returns the blocks given up after repeatedly failing processing
+func (s *apiServer) ListBlockDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAdmin(w, r) {
+		return
+	}
+	letters, err := s.ethParser.BlockDeadLetters()
+	if err != nil {
+		log.Println("Error reading the block dead letters:", err)
+		http.Error(w, "Could not read the dead letters", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(letters)
+}
+
+// GetBlockDeadLetter returns a dead-lettered block with its raw payload
+func (s *apiServer) GetBlockDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAdmin(w, r) {
+		return
+	}
+	block, ok := decodeBlockPath(w, r)
+	if !ok {
+		return
+	}
+	letter, found, err := s.ethParser.GetBlockDeadLetter(block)
+	if err != nil {
+		log.Printf("Error reading the dead letter of block %d: %v\n", block, err)
+		http.Error(w, "Could not read the dead letter", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Block not dead-lettered", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(letter)
+}
+
+// ReplayBlockDeadLetter processes a dead-lettered block again
+func (s *apiServer) ReplayBlockDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAdmin(w, r) {
+		return
+	}
+	block, ok := decodeBlockPath(w, r)
+	if !ok {
+		return
+	}
+	err := s.ethParser.ReplayBlockDeadLetter(block)
+	if errors.Is(err, parser.ErrUnknownDeadLetter) {
+		http.Error(w, "Block not dead-lettered", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	json.NewEncoder(w).Encode(SuccessResponse{Success: true})
+}
+
+// decodeBlockPath reads the block number of the path, replying 400 when it's invalid
+func decodeBlockPath(w http.ResponseWriter, r *http.Request) (int, bool) {
+	block, err := strconv.Atoi(r.PathValue("block"))
+	if err != nil || block < 0 {
+		http.Error(w, "Invalid block number", http.StatusBadRequest)
+		return 0, false
+	}
+	return block, true
+}
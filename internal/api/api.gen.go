@@ -0,0 +1,328 @@
+// Code generated by openapi-gen from openapi.json; DO NOT EDIT.
+
+package api
+
+import (
+	"net/http"
+
+	"eth-parser/internal/parser"
+)
+
+// AddressRequest is the request body of the per address endpoints.
+type AddressRequest struct {
+	Address string `json:"address"`
+}
+
+type ConsumerAckRequest struct {
+	Consumer string   `json:"consumer"`
+	Ids      []string `json:"ids"`
+}
+
+// CreateTenantRequest is the request body of the tenant creation endpoint.
+type CreateTenantRequest struct {
+	// Id is the ID of the tenant, which namespaces its entities and consumers.
+	Id string `json:"id"`
+	// MaxSubscriptions is the subscription quota, zero means unlimited.
+	MaxSubscriptions int    `json:"maxSubscriptions,omitempty"`
+	Name             string `json:"name,omitempty"`
+}
+
+// CreateTenantResponse is the response of the tenant creation endpoint.
+type CreateTenantResponse struct {
+	ApiKey string        `json:"apiKey"`
+	Tenant parser.Tenant `json:"tenant"`
+}
+
+// CurrentBlockResponse is the response of the current block endpoint.
+type CurrentBlockResponse struct {
+	CurrentBlock int `json:"current_block"`
+}
+
+// EntityMemberRequest is the request body to link or unlink an address and an entity.
+type EntityMemberRequest struct {
+	Address string `json:"address"`
+	Entity  string `json:"entity"`
+}
+
+// EntityRequest is the request body of the per entity endpoints.
+type EntityRequest struct {
+	Entity string `json:"entity"`
+}
+
+// EntityTransactionsResponse is the response of the entity transactions endpoint.
+type EntityTransactionsResponse struct {
+	Addresses    []string                   `json:"addresses"`
+	Transactions []parser.EntityTransaction `json:"transactions"`
+}
+
+// ImportSubscriptionsResponse is the response of the subscription import endpoint.
+type ImportSubscriptionsResponse struct {
+	Errors []SubscriptionImportError `json:"errors"`
+	// Imported is the number of newly subscribed addresses.
+	Imported int `json:"imported"`
+	// Skipped is the number of addresses already subscribed, left unchanged.
+	Skipped int `json:"skipped"`
+}
+
+type ShareLink struct {
+	Address   string `json:"address"`
+	ExpiresAt string `json:"expiresAt"`
+	Token     string `json:"token"`
+	// Url link to GET /shared/{token}/transactions, relative when no public URL is configured.
+	Url string `json:"url"`
+}
+
+type ShareLinkRequest struct {
+	// ExpiresIn validity of the link, a duration such as 72h, 24h by default and at most 720h.
+	ExpiresIn string `json:"expiresIn,omitempty"`
+}
+
+// StatusResponse is the response of the status endpoint.
+type StatusResponse struct {
+	Capabilities    parser.Capabilities  `json:"capabilities"`
+	ChainIdMismatch bool                 `json:"chainIdMismatch"`
+	CurrentBlock    int                  `json:"currentBlock"`
+	Throttle        parser.ThrottleState `json:"throttle"`
+}
+
+// SubscriptionImportError is a row of an imported file that couldn't be subscribed or backfilled.
+type SubscriptionImportError struct {
+	Address string `json:"address"`
+	Error   string `json:"error"`
+	// Row is the 1-based position of the subscription in the file, header excluded.
+	Row int `json:"row"`
+}
+
+// SuccessResponse reports the outcome of a write operation.
+type SuccessResponse struct {
+	Success bool `json:"success"`
+}
+
+// TransactionChangesResponse is the response of the differential sync endpoint.
+type TransactionChangesResponse struct {
+	// Cursor is the last processed block, the since_block of the next call.
+	Cursor       int                  `json:"cursor"`
+	Transactions []parser.Transaction `json:"transactions"`
+}
+
+// TransactionsBatchRequest is the request body of the batch transactions endpoint.
+type TransactionsBatchRequest struct {
+	// Addresses are the addresses, at most 100.
+	Addresses []string `json:"addresses"`
+	// Category returns only the transactions of the category.
+	Category string `json:"category,omitempty"`
+	// Direction returns only the transactions with the direction relative to their address.
+	Direction string `json:"direction,omitempty"`
+	// FromBlock is the first block included.
+	FromBlock int `json:"fromBlock,omitempty"`
+	// FromTime is the first block time included, the transactions stored without blockTime are left out.
+	FromTime string `json:"fromTime,omitempty"`
+	// Limit returns the first transactions of each address in the order, all of them when omitted.
+	Limit int `json:"limit,omitempty"`
+	// MinValue returns only the transactions of at least this value in wei, decimal or 0x prefixed hex.
+	MinValue string `json:"minValue,omitempty"`
+	// Order lists the transactions from the oldest block, the default, or from the most recent one.
+	Order string `json:"order,omitempty"`
+	// ToBlock is the last block included.
+	ToBlock int `json:"toBlock,omitempty"`
+	// ToTime is the last block time included.
+	ToTime string `json:"toTime,omitempty"`
+}
+
+// ValidationError is the reply to a request that doesn't match the schema of its operation.
+type ValidationError struct {
+	Error      string      `json:"error"`
+	Violations []Violation `json:"violations"`
+}
+
+// Violation is a value of a request that doesn't match its schema.
+type Violation struct {
+	// Field is the parameter name, or the path of the body field such as addresses[0], empty for the whole body.
+	Field   string `json:"field"`
+	In      string `json:"in"`
+	Message string `json:"message"`
+}
+
+// WaitTransactionsResponse is the response of the long-poll transactions endpoint.
+type WaitTransactionsResponse struct {
+	// Cursor is the last processed block, to pass to the next call.
+	Cursor       int                  `json:"cursor"`
+	Flow         parser.FlowSummary   `json:"flow"`
+	Transactions []parser.Transaction `json:"transactions"`
+}
+
+// ServerInterface is implemented by the API handlers, one method per operation
+type ServerInterface interface {
+	// GetAllowances returns the current ERC-20 allowances granted by a subscribed address.
+	GetAllowances(w http.ResponseWriter, r *http.Request)
+	// GetTransactionChanges returns the transactions of a subscribed address discovered after a block, to sync a client incrementally.
+	GetTransactionChanges(w http.ResponseWriter, r *http.Request)
+	// GetCounterparties returns the top counterparties of a subscribed address by transaction count or total value.
+	GetCounterparties(w http.ResponseWriter, r *http.Request)
+	// GetTimeSeries returns the activity of an address in time buckets computed from its stored transactions, the empty buckets included, for the charts of a dashboard.
+	GetTimeSeries(w http.ResponseWriter, r *http.Request)
+	// WaitForTransactions long-polls the transactions of a subscribed address in the blocks processed after the cursor.
+	WaitForTransactions(w http.ResponseWriter, r *http.Request)
+	// LookupActivity returns the blocks where an address, subscribed or not, may have been active according to the activity index, e.g. before a backfill, requires the X-Admin-Key header.
+	LookupActivity(w http.ResponseWriter, r *http.Request)
+	// GetRuntimeConfig returns the knobs of the parser tunable while it runs, requires the X-Admin-Key header.
+	GetRuntimeConfig(w http.ResponseWriter, r *http.Request)
+	// UpdateRuntimeConfig changes the given knobs of the parser without a restart, e.g. to adapt to the provider during an incident, and returns the new config, requires the X-Admin-Key header.
+	UpdateRuntimeConfig(w http.ResponseWriter, r *http.Request)
+	// ListBlockDeadLetters lists the blocks given up after repeatedly failing processing, without their payload, requires the X-Admin-Key header.
+	ListBlockDeadLetters(w http.ResponseWriter, r *http.Request)
+	// GetBlockDeadLetter returns a dead-lettered block with the raw block returned by the node, requires the X-Admin-Key header.
+	GetBlockDeadLetter(w http.ResponseWriter, r *http.Request)
+	// ReplayBlockDeadLetter processes a dead-lettered block again and removes it from the dead letters on success, requires the X-Admin-Key header.
+	ReplayBlockDeadLetter(w http.ResponseWriter, r *http.Request)
+	// GetDecodeFailures returns the last blocks that could only be partially decoded, requires the X-Admin-Key header.
+	GetDecodeFailures(w http.ResponseWriter, r *http.Request)
+	// ListExports lists the export jobs, the most recent first, requires the X-Admin-Key header.
+	ListExports(w http.ResponseWriter, r *http.Request)
+	// StartExport starts an asynchronous export of the full history of an address or an entity to the export destination, as CSV or NDJSON, requires the X-Admin-Key header.
+	StartExport(w http.ResponseWriter, r *http.Request)
+	// GetExport returns an export job, with a signed download URL once it's done, requires the X-Admin-Key header.
+	GetExport(w http.ResponseWriter, r *http.Request)
+	// Rescan runs the matching again over already processed blocks, requires the X-Admin-Key header.
+	Rescan(w http.ResponseWriter, r *http.Request)
+	// GetStorageStats returns the size of the stored data, requires the X-Admin-Key header.
+	GetStorageStats(w http.ResponseWriter, r *http.Request)
+	// ListTenants lists the tenants with their subscription usage, requires the X-Admin-Key header.
+	ListTenants(w http.ResponseWriter, r *http.Request)
+	// CreateTenant creates a tenant and returns its API key, requires the X-Admin-Key header.
+	CreateTenant(w http.ResponseWriter, r *http.Request)
+	// DeleteTenant deletes a tenant and revokes its API key, requires the X-Admin-Key header.
+	DeleteTenant(w http.ResponseWriter, r *http.Request)
+	// GetUsage returns the node requests of the month per method and day and their projection to the end of the month against the plan of the provider, requires the X-Admin-Key header.
+	GetUsage(w http.ResponseWriter, r *http.Request)
+	// ListAuditLog lists the subscription changes, who made them and when, the most recent first.
+	ListAuditLog(w http.ResponseWriter, r *http.Request)
+	// ListConsumers lists the registered pull consumers, the ones of the tenant with multi-tenancy.
+	ListConsumers(w http.ResponseWriter, r *http.Request)
+	// RegisterConsumer registers a pull consumer, or updates the addresses of a registered one. The events delivered from then on are retained for it until it acknowledges them.
+	RegisterConsumer(w http.ResponseWriter, r *http.Request)
+	// UnregisterConsumer unregisters a pull consumer and drops its unacknowledged events.
+	UnregisterConsumer(w http.ResponseWriter, r *http.Request)
+	// CallContract reads a contract with eth_call, encoding the arguments and decoding the outputs with a registered ABI.
+	CallContract(w http.ResponseWriter, r *http.Request)
+	// GetCurrentBlock returns the last parsed block number.
+	GetCurrentBlock(w http.ResponseWriter, r *http.Request)
+	// AddToEntity links an address to an entity and subscribes it.
+	AddToEntity(w http.ResponseWriter, r *http.Request)
+	// RemoveFromEntity unlinks an address from an entity.
+	RemoveFromEntity(w http.ResponseWriter, r *http.Request)
+	// GetEntityTransactions returns the member addresses and the transactions of an entity.
+	GetEntityTransactions(w http.ResponseWriter, r *http.Request)
+	// PullConsumerEvents returns the oldest unacknowledged events of a pull consumer. The same events are returned until they're acknowledged with POST /events/ack.
+	PullConsumerEvents(w http.ResponseWriter, r *http.Request)
+	// AckConsumerEvents acknowledges processed events of a pull consumer, which are then no longer returned. Unknown event IDs are ignored.
+	AckConsumerEvents(w http.ResponseWriter, r *http.Request)
+	// DownloadExport downloads the file of an export job written to a local directory, with the signed URL of the job.
+	DownloadExport(w http.ResponseWriter, r *http.Request)
+	// ListReports lists the generated daily or weekly activity reports, the most recent first.
+	ListReports(w http.ResponseWriter, r *http.Request)
+	// GetReport downloads a generated report.
+	GetReport(w http.ResponseWriter, r *http.Request)
+	// ProxyRPC forwards a JSON-RPC request of a whitelisted method to the node of the parser, falling back to the fallback node. Requires the X-API-Key of a tenant or the admin key, and isn't served when neither is configured.
+	ProxyRPC(w http.ResponseWriter, r *http.Request)
+	// GetSharedTransactions returns the transactions of the address of a share link, the token being the only credential, with the filters and the pagination of /transactions.
+	GetSharedTransactions(w http.ResponseWriter, r *http.Request)
+	// WaitForSharedTransactions long-polls the new transactions of the address of a share link, like /addresses/{address}/transactions/wait.
+	WaitForSharedTransactions(w http.ResponseWriter, r *http.Request)
+	// GetStatus returns the status of the deployment and the capabilities detected on the node.
+	GetStatus(w http.ResponseWriter, r *http.Request)
+	// Subscribe subscribes to an address, optionally with email notifications.
+	Subscribe(w http.ResponseWriter, r *http.Request)
+	// ExportSubscriptions downloads the subscriptions as a JSON or CSV file that the import endpoint accepts.
+	ExportSubscriptions(w http.ResponseWriter, r *http.Request)
+	// ImportSubscriptions subscribes to the addresses of a JSON or CSV file, backfilling the processed blocks from their start block.
+	ImportSubscriptions(w http.ResponseWriter, r *http.Request)
+	// Unsubscribe unsubscribes from an address. It's a soft delete: the stored transactions of the address stay queryable.
+	Unsubscribe(w http.ResponseWriter, r *http.Request)
+	// UpdateSubscription replaces the notification settings of a subscribed address.
+	UpdateSubscription(w http.ResponseWriter, r *http.Request)
+	// UnmuteSubscription resumes the notifications of a muted address. The transactions of the muted period aren't notified.
+	UnmuteSubscription(w http.ResponseWriter, r *http.Request)
+	// MuteSubscription pauses the notifications of a subscribed address, e.g. during planned large transfers. The address is still indexed: its transactions are stored, but not notified.
+	MuteSubscription(w http.ResponseWriter, r *http.Request)
+	// CreateShareLink signs a time-limited read-only link to the transaction history and the new transactions of a subscribed address, to share a monitoring view without an API key.
+	CreateShareLink(w http.ResponseWriter, r *http.Request)
+	// SendTestNotification sends a synthetic incoming transaction, marked test, through the notifications of a subscribed address to check their configuration.
+	SendTestNotification(w http.ResponseWriter, r *http.Request)
+	// GetTokenMetadata returns the name, symbol and decimals of a token contract, read with eth_call and cached.
+	GetTokenMetadata(w http.ResponseWriter, r *http.Request)
+	// GetTransactions returns the transactions of a subscribed address.
+	GetTransactions(w http.ResponseWriter, r *http.Request)
+	// GetTransactionsBatch returns the transactions of several subscribed addresses in one request, e.g. for a monitoring dashboard.
+	GetTransactionsBatch(w http.ResponseWriter, r *http.Request)
+	// GetNonceHistory returns the outgoing transactions of an address ordered by nonce, with gap detection.
+	GetNonceHistory(w http.ResponseWriter, r *http.Request)
+	// GetPendingTransactions returns the tracked pending outgoing transactions of an address.
+	GetPendingTransactions(w http.ResponseWriter, r *http.Request)
+	// GetTransactionByHash returns a transaction by hash, from the storage when the parser saw it, from the node otherwise.
+	GetTransactionByHash(w http.ResponseWriter, r *http.Request)
+	// WatchTransaction polls a transaction broadcast by another service until it's mined for its confirmation target or dropped, sending a transaction_status event on each status change.
+	WatchTransaction(w http.ResponseWriter, r *http.Request)
+	// GetWatchedTransaction returns the status of a watched transaction, kept for 24 hours after it's confirmed or dropped.
+	GetWatchedTransaction(w http.ResponseWriter, r *http.Request)
+}
+
+// RegisterHandlers registers the operations of the API on mux
+func RegisterHandlers(mux *http.ServeMux, si ServerInterface) {
+	mux.HandleFunc("GET /addresses/{address}/allowances", si.GetAllowances)
+	mux.HandleFunc("GET /addresses/{address}/changes", si.GetTransactionChanges)
+	mux.HandleFunc("GET /addresses/{address}/counterparties", si.GetCounterparties)
+	mux.HandleFunc("GET /addresses/{address}/timeseries", si.GetTimeSeries)
+	mux.HandleFunc("GET /addresses/{address}/transactions/wait", si.WaitForTransactions)
+	mux.HandleFunc("GET /admin/activity-index", si.LookupActivity)
+	mux.HandleFunc("GET /admin/config", si.GetRuntimeConfig)
+	mux.HandleFunc("PATCH /admin/config", si.UpdateRuntimeConfig)
+	mux.HandleFunc("GET /admin/dead-letters/blocks", si.ListBlockDeadLetters)
+	mux.HandleFunc("GET /admin/dead-letters/blocks/{block}", si.GetBlockDeadLetter)
+	mux.HandleFunc("POST /admin/dead-letters/blocks/{block}/replay", si.ReplayBlockDeadLetter)
+	mux.HandleFunc("GET /admin/decode-failures", si.GetDecodeFailures)
+	mux.HandleFunc("GET /admin/exports", si.ListExports)
+	mux.HandleFunc("POST /admin/exports", si.StartExport)
+	mux.HandleFunc("GET /admin/exports/{id}", si.GetExport)
+	mux.HandleFunc("POST /admin/rescan", si.Rescan)
+	mux.HandleFunc("GET /admin/storage", si.GetStorageStats)
+	mux.HandleFunc("GET /admin/tenants", si.ListTenants)
+	mux.HandleFunc("POST /admin/tenants", si.CreateTenant)
+	mux.HandleFunc("DELETE /admin/tenants/{id}", si.DeleteTenant)
+	mux.HandleFunc("GET /admin/usage", si.GetUsage)
+	mux.HandleFunc("GET /audit", si.ListAuditLog)
+	mux.HandleFunc("GET /consumers", si.ListConsumers)
+	mux.HandleFunc("POST /consumers", si.RegisterConsumer)
+	mux.HandleFunc("DELETE /consumers/{name}", si.UnregisterConsumer)
+	mux.HandleFunc("POST /contracts/call", si.CallContract)
+	mux.HandleFunc("GET /current_block", si.GetCurrentBlock)
+	mux.HandleFunc("POST /entities/add", si.AddToEntity)
+	mux.HandleFunc("POST /entities/remove", si.RemoveFromEntity)
+	mux.HandleFunc("POST /entities/transactions", si.GetEntityTransactions)
+	mux.HandleFunc("GET /events", si.PullConsumerEvents)
+	mux.HandleFunc("POST /events/ack", si.AckConsumerEvents)
+	mux.HandleFunc("GET /exports/download/{key}", si.DownloadExport)
+	mux.HandleFunc("GET /reports", si.ListReports)
+	mux.HandleFunc("GET /reports/{id}", si.GetReport)
+	mux.HandleFunc("POST /rpc", si.ProxyRPC)
+	mux.HandleFunc("GET /shared/{token}/transactions", si.GetSharedTransactions)
+	mux.HandleFunc("GET /shared/{token}/transactions/wait", si.WaitForSharedTransactions)
+	mux.HandleFunc("GET /status", si.GetStatus)
+	mux.HandleFunc("POST /subscribe", si.Subscribe)
+	mux.HandleFunc("GET /subscriptions/export", si.ExportSubscriptions)
+	mux.HandleFunc("POST /subscriptions/import", si.ImportSubscriptions)
+	mux.HandleFunc("DELETE /subscriptions/{address}", si.Unsubscribe)
+	mux.HandleFunc("PUT /subscriptions/{address}", si.UpdateSubscription)
+	mux.HandleFunc("DELETE /subscriptions/{address}/mute", si.UnmuteSubscription)
+	mux.HandleFunc("POST /subscriptions/{address}/mute", si.MuteSubscription)
+	mux.HandleFunc("POST /subscriptions/{address}/share", si.CreateShareLink)
+	mux.HandleFunc("POST /subscriptions/{address}/test-notification", si.SendTestNotification)
+	mux.HandleFunc("GET /tokens/{address}", si.GetTokenMetadata)
+	mux.HandleFunc("POST /transactions", si.GetTransactions)
+	mux.HandleFunc("POST /transactions/batch", si.GetTransactionsBatch)
+	mux.HandleFunc("POST /transactions/nonces", si.GetNonceHistory)
+	mux.HandleFunc("POST /transactions/pending", si.GetPendingTransactions)
+	mux.HandleFunc("GET /transactions/{hash}", si.GetTransactionByHash)
+	mux.HandleFunc("POST /watch_tx", si.WatchTransaction)
+	mux.HandleFunc("GET /watch_tx/{hash}", si.GetWatchedTransaction)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"eth-parser/internal/parser"
+)
+
+// defaultAuditLimit is the number of audit entries returned without limit parameter
+const defaultAuditLimit = 100
+
+// recordAudit appends a subscription change made by the request to the audit log, the failures are only
+// logged as the change is already applied
+func (s *apiServer) recordAudit(p parser.Parser, r *http.Request, entry parser.AuditEntry) {
+	if s.adminKey != "" && validAdminKey(r, s.adminKey) {
+		entry.Actor = "admin"
+	}
+	entry.RemoteAddr = r.RemoteAddr
+	if err := p.RecordAudit(entry); err != nil {
+		log.Printf("Error recording the %s of %s in the audit log: %v\n", entry.Action, entry.Address, err)
+	}
+}
+
+// subscriptionState returns the current subscription of an address for the audit log, nil when it's not subscribed
+func subscriptionState(p parser.Parser, address string) *parser.Subscription {
+	subscription, ok := p.GetSubscription(address)
+	if !ok {
+		return nil
+	}
+	return &subscription
+}
+
+// ListAuditLog returns the subscription changes, the most recent first
+func (s *apiServer) ListAuditLog(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	filter := parser.AuditFilter{Address: query.Get("address"), Limit: defaultAuditLimit}
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = parsed
+	}
+	entries, err := p.AuditLog(filter)
+	if err != nil {
+		http.Error(w, "Error reading the audit log: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(entries)
+}
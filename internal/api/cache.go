@@ -0,0 +1,46 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"eth-parser/internal/parser"
+)
+
+// cacheMaxAge is the Cache-Control max-age, in seconds, of the read endpoints.
+// It's kept below the fetch period so clients never miss a whole update.
+const cacheMaxAge = 5
+
+// currentBlockETag returns the ETag of the /current_block response
+func currentBlockETag(block int) string {
+	return fmt.Sprintf(`W/"block-%d"`, block)
+}
+
+// transactionsETag returns the ETag of a transaction list, derived from the last matched block and the
+// number of transactions, which both change whenever new transactions are stored for the address
+func transactionsETag(address string, transactions []parser.Transaction) string {
+	lastBlock := 0
+	for _, tx := range transactions {
+		if tx.BlockNumberDecimal > lastBlock {
+			lastBlock = tx.BlockNumberDecimal
+		}
+	}
+	return fmt.Sprintf(`W/"%s-%d-%d"`, strings.ToLower(address), lastBlock, len(transactions))
+}
+
+// checkNotModified sets the caching headers and replies 304 Not Modified if the request If-None-Match
+// header matches etag. It returns true when the response has been written.
+func checkNotModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", cacheMaxAge))
+
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
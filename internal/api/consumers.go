@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"eth-parser/internal/parser"
+)
+
+// maxConsumerEvents bounds the events returned by a pull
+const maxConsumerEvents = 1000
+
+// consumerError replies to an error of the consumer methods and reports whether there was one
+func consumerError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, parser.ErrConsumersDisabled):
+		http.Error(w, "Consumers are disabled", http.StatusNotFound)
+	case errors.Is(err, parser.ErrUnknownConsumer):
+		http.Error(w, "Unknown consumer", http.StatusNotFound)
+	case errors.Is(err, parser.ErrInvalidConsumer):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Println("Error of the consumers:", err)
+		http.Error(w, "Could not update the consumer", http.StatusInternalServerError)
+	}
+	return true
+}
+
+// ListConsumers returns the registered pull consumers, the ones of the tenant with multi-tenancy
+func (s *apiServer) ListConsumers(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	consumers, err := p.Consumers()
+	if err != nil {
+		log.Println("Error reading the consumers:", err)
+		http.Error(w, "Could not read the consumers", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(consumers)
+}
+
+// RegisterConsumer registers a pull consumer or updates its addresses
+func (s *apiServer) RegisterConsumer(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	var request parser.Consumer
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+	consumer, err := p.RegisterConsumer(request)
+	if consumerError(w, err) {
+		return
+	}
+	json.NewEncoder(w).Encode(consumer)
+}
+
+// UnregisterConsumer removes a pull consumer and its unacknowledged events
+func (s *apiServer) UnregisterConsumer(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	if consumerError(w, p.UnregisterConsumer(r.PathValue("name"))) {
+		return
+	}
+	json.NewEncoder(w).Encode(SuccessResponse{Success: true})
+}
+
+// PullConsumerEvents returns the oldest unacknowledged events of a consumer
+func (s *apiServer) PullConsumerEvents(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	limit := 100
+	if value := query.Get("max"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxConsumerEvents {
+			http.Error(w, "Invalid max", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+	events, err := p.PullConsumerEvents(query.Get("consumer"), limit)
+	if consumerError(w, err) {
+		return
+	}
+	if accepts(r, parser.CodecProtobuf, parser.ProtobufContentType) {
+		data, _ := parser.ProtobufCodec{}.Marshal(events)
+		w.Header().Set("Content-Type", parser.ProtobufContentType)
+		w.Write(data)
+		return
+	}
+	for i := range events {
+		events[i].Transactions = parser.TransactionsWithNumberEncoding(events[i].Transactions, s.numberEncoding)
+	}
+	json.NewEncoder(w).Encode(events)
+}
+
+// AckConsumerEvents acknowledges processed events of a consumer
+func (s *apiServer) AckConsumerEvents(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	var request ConsumerAckRequest
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+	if consumerError(w, p.AckConsumerEvents(request.Consumer, request.Ids)) {
+		return
+	}
+	json.NewEncoder(w).Encode(SuccessResponse{Success: true})
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"eth-parser/internal/parser"
+)
+
+// StartExport starts an asynchronous export job
+func (s *apiServer) StartExport(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAdmin(w, r) {
+		return
+	}
+	var request parser.ExportRequest
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+	job, err := s.ethParser.StartExport(request)
+	switch {
+	case errors.Is(err, parser.ErrExportsDisabled):
+		http.Error(w, "Exports aren't configured", http.StatusNotFound)
+		return
+	case errors.Is(err, parser.ErrInvalidExportFormat):
+		http.Error(w, "Format must be csv or ndjson", http.StatusBadRequest)
+		return
+	case errors.Is(err, parser.ErrInvalidExportSource):
+		http.Error(w, "Exactly one of address and entity is required", http.StatusBadRequest)
+		return
+	case errors.Is(err, parser.ErrTooManyExports):
+		http.Error(w, "Too many running exports", http.StatusTooManyRequests)
+		return
+	case err != nil:
+		log.Println("Error starting an export:", err)
+		http.Error(w, "Could not start the export", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// ListExports returns the export jobs, the most recent first
+func (s *apiServer) ListExports(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAdmin(w, r) {
+		return
+	}
+	json.NewEncoder(w).Encode(s.ethParser.Exports())
+}
+
+// GetExport returns an export job with its download URL once it's done
+func (s *apiServer) GetExport(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAdmin(w, r) {
+		return
+	}
+	job, ok := s.ethParser.GetExport(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "Unknown export job", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(job)
+}
+
+// DownloadExport serves the file of an export written to a local directory, the signature of the URL
+// authorizes the download
+func (s *apiServer) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	destination, ok := s.ethParser.ExportDestination().(*parser.LocalDestination)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	key := r.PathValue("key")
+	file, err := destination.Open(key, r.URL.Query().Get("expires"), r.URL.Query().Get("signature"))
+	if errors.Is(err, parser.ErrInvalidDownloadSignature) {
+		http.Error(w, "Invalid or expired signature", http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Error opening the export %s: %v\n", key, err)
+		http.Error(w, "Could not read the export", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	contentType := "text/csv"
+	if strings.HasSuffix(key, "."+parser.ExportFormatNDJSON) {
+		contentType = "application/x-ndjson"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+key+`"`)
+	http.ServeContent(w, r, key, time.Time{}, file)
+}
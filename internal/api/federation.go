@@ -0,0 +1,382 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxFederatedBody bounds the request bodies and the member responses buffered to be merged
+const maxFederatedBody = 32 << 20
+
+// federationHeaders are the request headers forwarded to the members, so that they authenticate the client
+var federationHeaders = []string{"Authorization", "X-API-Key", "X-Admin-Key", "Idempotency-Key", "Content-Type"}
+
+// FederationMember is a remote eth-parser deployment aggregated by NewFederationHandler, e.g. the one of a
+// chain or of a region
+type FederationMember struct {
+	Name string `json:"name"`
+	// URL is the base URL of the API of the member
+	URL string `json:"url"`
+}
+
+// MemberStatus is the state of a member served by GET /federation/members
+type MemberStatus struct {
+	FederationMember
+	Reachable    bool   `json:"reachable"`
+	CurrentBlock int    `json:"currentBlock,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// federation serves a single API over the members
+type federation struct {
+	members []FederationMember
+	client  *http.Client
+}
+
+// memberResponse is the buffered response of a member, err being set when it couldn't be reached
+type memberResponse struct {
+	member FederationMember
+	status int
+	header http.Header
+	body   []byte
+	err    error
+}
+
+// failed reports whether the member couldn't answer, unreachable or with a server error
+func (m memberResponse) failed() bool {
+	return m.err != nil || m.status >= http.StatusInternalServerError
+}
+
+// NewFederationHandler returns a handler aggregating the API of several eth-parser deployments. The
+// subscriptions are sent to every member, or to the one of the member query parameter, and the transaction
+// queries are merged, each transaction carrying the name of its member. The other endpoints are proxied to
+// the member of the member query parameter. The members that don't answer are listed in the
+// X-Federation-Unavailable header of a partial response, which fails with 502 when none answered.
+func NewFederationHandler(members []FederationMember, client *http.Client) http.Handler {
+	f := &federation{members: members, client: client}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /federation/members", f.memberStatuses)
+	for _, pattern := range []string{"POST /subscribe", "PUT /subscriptions/{address}", "DELETE /subscriptions/{address}",
+		"POST /subscriptions/{address}/mute", "DELETE /subscriptions/{address}/mute"} {
+		mux.HandleFunc(pattern, f.fanOut)
+	}
+	mux.HandleFunc("POST /transactions", f.mergeTransactions)
+	mux.HandleFunc("GET /transactions/{hash}", f.findTransaction)
+	mux.HandleFunc("/", f.proxy)
+	return mux
+}
+
+// selectedMembers returns the member of the member query parameter, all the members without it. It replies
+// 400 for an unknown member.
+func (f *federation) selectedMembers(w http.ResponseWriter, r *http.Request) ([]FederationMember, bool) {
+	name := r.URL.Query().Get("member")
+	if name == "" {
+		return f.members, true
+	}
+	for _, member := range f.members {
+		if member.Name == name {
+			return []FederationMember{member}, true
+		}
+	}
+	http.Error(w, "Unknown member "+name, http.StatusBadRequest)
+	return nil, false
+}
+
+// memberRequest creates the request of a member for a client request, without the member query parameter
+func memberRequest(member FederationMember, r *http.Request, body io.Reader) (*http.Request, error) {
+	query := r.URL.Query()
+	query.Del("member")
+	target, err := url.Parse(strings.TrimSuffix(member.URL, "/") + r.URL.Path)
+	if err != nil {
+		return nil, err
+	}
+	target.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range federationHeaders {
+		if value := r.Header.Get(name); value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+	return req, nil
+}
+
+// sendAll sends a client request to the members concurrently and returns their responses in member order
+func (f *federation) sendAll(r *http.Request, members []FederationMember) ([]memberResponse, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxFederatedBody))
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]memberResponse, len(members))
+	var wg sync.WaitGroup
+	for i, member := range members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses[i] = f.send(member, r, body)
+		}()
+	}
+	wg.Wait()
+	return responses, nil
+}
+
+// send sends a client request with body to a member and buffers its response
+func (f *federation) send(member FederationMember, r *http.Request, body []byte) memberResponse {
+	response := memberResponse{member: member}
+	req, err := memberRequest(member, r, bytes.NewReader(body))
+	if err != nil {
+		response.err = err
+		return response
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		response.err = err
+		return response
+	}
+	defer resp.Body.Close()
+	response.status, response.header = resp.StatusCode, resp.Header
+	response.body, response.err = io.ReadAll(io.LimitReader(resp.Body, maxFederatedBody))
+	return response
+}
+
+// setUnavailable lists the members that couldn't answer, it reports whether all of them failed
+func setUnavailable(w http.ResponseWriter, responses []memberResponse) bool {
+	var unavailable []string
+	for _, response := range responses {
+		if response.failed() {
+			unavailable = append(unavailable, response.member.Name)
+		}
+	}
+	if len(unavailable) > 0 {
+		w.Header().Set("X-Federation-Unavailable", strings.Join(unavailable, ","))
+	}
+	if len(unavailable) == len(responses) {
+		http.Error(w, "No member answered", http.StatusBadGateway)
+		return true
+	}
+	return false
+}
+
+// relay writes the buffered response of a member
+func relay(w http.ResponseWriter, response memberResponse) {
+	if contentType := response.header.Get("Content-Type"); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.WriteHeader(response.status)
+	w.Write(response.body)
+}
+
+// firstAnswer returns the first successful response, or the first client error when none succeeded
+func firstAnswer(responses []memberResponse) memberResponse {
+	var answer *memberResponse
+	for i := range responses {
+		switch response := &responses[i]; {
+		case response.failed():
+		case response.status < http.StatusBadRequest:
+			return *response
+		case answer == nil:
+			answer = response
+		}
+	}
+	return *answer
+}
+
+// fanOut sends a subscription request to the selected members. The response is the one of the first member
+// that applied it; for /subscribe, success is set when it's a new subscription of any member.
+func (f *federation) fanOut(w http.ResponseWriter, r *http.Request) {
+	members, ok := f.selectedMembers(w, r)
+	if !ok {
+		return
+	}
+	responses, err := f.sendAll(r, members)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if setUnavailable(w, responses) {
+		return
+	}
+	answer := firstAnswer(responses)
+	if r.URL.Path != "/subscribe" || answer.status != http.StatusOK {
+		relay(w, answer)
+		return
+	}
+	var merged SuccessResponse
+	for _, response := range responses {
+		var result SuccessResponse
+		if !response.failed() && response.status == http.StatusOK && json.Unmarshal(response.body, &result) == nil {
+			merged.Success = merged.Success || result.Success
+		}
+	}
+	json.NewEncoder(w).Encode(merged)
+}
+
+// memberTransactions decodes the transactions of a member response and tags them with the member name
+func memberTransactions(response memberResponse) ([]map[string]json.RawMessage, error) {
+	var transactions []map[string]json.RawMessage
+	if response.status == http.StatusNoContent {
+		return nil, nil
+	}
+	if err := json.Unmarshal(response.body, &transactions); err != nil {
+		return nil, fmt.Errorf("member %s: %w", response.member.Name, err)
+	}
+	name, _ := json.Marshal(response.member.Name)
+	for _, tx := range transactions {
+		tx["member"] = name
+	}
+	return transactions, nil
+}
+
+// mergeTransactions queries the transactions of an address on the selected members and merges them by block
+// time, the block numbers of different chains not being comparable. The pages of the members can't be merged,
+// so the cursor and limit parameters are rejected.
+func (f *federation) mergeTransactions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Has("cursor") || query.Has("limit") {
+		http.Error(w, "The federated transactions aren't paginated", http.StatusBadRequest)
+		return
+	}
+	members, ok := f.selectedMembers(w, r)
+	if !ok {
+		return
+	}
+	query.Del("format")
+	r = r.Clone(r.Context())
+	r.URL.RawQuery = query.Encode()
+	responses, err := f.sendAll(r, members)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if setUnavailable(w, responses) {
+		return
+	}
+	if answer := firstAnswer(responses); answer.status >= http.StatusBadRequest {
+		relay(w, answer)
+		return
+	}
+
+	var merged []map[string]json.RawMessage
+	for _, response := range responses {
+		if response.failed() || response.status >= http.StatusBadRequest {
+			continue
+		}
+		transactions, err := memberTransactions(response)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		merged = append(merged, transactions...)
+		if response.header.Get("X-Results-Truncated") == "true" {
+			w.Header().Set("X-Results-Truncated", "true")
+		}
+	}
+	if len(merged) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	// The RFC 3339 times of the API sort as strings, the transactions without block time come first
+	descending := query.Get("order") == "desc"
+	sort.SliceStable(merged, func(i, j int) bool {
+		if descending {
+			return string(merged[i]["blockTime"]) > string(merged[j]["blockTime"])
+		}
+		return string(merged[i]["blockTime"]) < string(merged[j]["blockTime"])
+	})
+	json.NewEncoder(w).Encode(merged)
+}
+
+// findTransaction looks a transaction up on the selected members, the first member finding it answering
+func (f *federation) findTransaction(w http.ResponseWriter, r *http.Request) {
+	members, ok := f.selectedMembers(w, r)
+	if !ok {
+		return
+	}
+	responses, err := f.sendAll(r, members)
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if setUnavailable(w, responses) {
+		return
+	}
+	for _, response := range responses {
+		if response.failed() || response.status != http.StatusOK {
+			continue
+		}
+		var lookup map[string]json.RawMessage
+		if err := json.Unmarshal(response.body, &lookup); err != nil {
+			http.Error(w, fmt.Sprintf("member %s: %v", response.member.Name, err), http.StatusBadGateway)
+			return
+		}
+		lookup["member"], _ = json.Marshal(response.member.Name)
+		json.NewEncoder(w).Encode(lookup)
+		return
+	}
+	relay(w, firstAnswer(responses))
+}
+
+// memberStatuses returns the current block of every member, or why it couldn't be reached
+func (f *federation) memberStatuses(w http.ResponseWriter, r *http.Request) {
+	r = r.Clone(r.Context())
+	r.URL.Path, r.URL.RawQuery = "/current_block", ""
+	responses, _ := f.sendAll(r, f.members)
+	statuses := make([]MemberStatus, len(responses))
+	for i, response := range responses {
+		statuses[i].FederationMember = response.member
+		var block CurrentBlockResponse
+		switch {
+		case response.err != nil:
+			statuses[i].Error = response.err.Error()
+		case response.status != http.StatusOK:
+			statuses[i].Error = fmt.Sprintf("status %d", response.status)
+		case json.Unmarshal(response.body, &block) != nil:
+			statuses[i].Error = "invalid current block"
+		default:
+			statuses[i].Reachable, statuses[i].CurrentBlock = true, block.CurrentBlock
+		}
+	}
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// proxy streams a request of the other endpoints to the member of the member query parameter, which may be
+// omitted when there's a single member
+func (f *federation) proxy(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("member") == "" && len(f.members) != 1 {
+		http.Error(w, "The member query parameter is required for "+r.URL.Path, http.StatusBadRequest)
+		return
+	}
+	members, ok := f.selectedMembers(w, r)
+	if !ok {
+		return
+	}
+	req, err := memberRequest(members[0], r, r.Body)
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if accept := r.Header.Get("Accept"); accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		w.Header().Set("X-Federation-Unavailable", members[0].Name)
+		http.Error(w, "No member answered", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
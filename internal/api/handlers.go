@@ -0,0 +1,650 @@
+package api
+
+import (
+	"context"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"eth-parser/internal/parser"
+)
+
+//go:generate go run ../../cmd/openapi-gen -spec openapi.json -out api.gen.go -package api
+
+// openAPISpec is the OpenAPI 3 document of the API, the handler types and routes are generated from it
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// swaggerUIPage renders the Swagger UI for the served OpenAPI document
+const swaggerUIPage = `<!DOCTYPE html>
+<html>
+<head>
+  <title>EthParser API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>`
+
+// Option configures the handlers created by NewAPIHandler and NewAdminHandler
+type Option func(*apiServer)
+
+// WithTenants enables multi-tenancy, each API key being served the namespace of its tenant
+func WithTenants(tenants *parser.TenantManager) Option {
+	return func(s *apiServer) {
+		s.tenants = tenants
+	}
+}
+
+// WithAdminKey enables the admin endpoints, protected by the key
+func WithAdminKey(adminKey string) Option {
+	return func(s *apiServer) {
+		s.adminKey = adminKey
+	}
+}
+
+// WithNumberEncoding sets the encoding of the transaction quantities in the responses,
+// parser.NumberEncodingDecimal (the default) or parser.NumberEncodingHex
+func WithNumberEncoding(encoding string) Option {
+	return func(s *apiServer) {
+		s.numberEncoding = encoding
+	}
+}
+
+// newAPIServer creates the apiServer of the handlers
+func newAPIServer(ethParser *parser.EthParser, opts ...Option) *apiServer {
+	s := &apiServer{
+		parser:         ethParser,
+		ethParser:      ethParser,
+		storage:        ethParser.Storage(),
+		numberEncoding: parser.NumberEncodingDecimal,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// NewAPIHandler returns a self-contained handler serving the API operations, the Prometheus metrics, the
+// readiness probe and the OpenAPI document of the parser, so that embedders can mount it under their own
+// router and middleware, or run several instances in one process. To serve the admin endpoints and the
+// metrics on a separate listener, see NewAdminHandler and PublicOnly.
+func NewAPIHandler(ethParser *parser.EthParser, opts ...Option) http.Handler {
+	s := newAPIServer(ethParser, opts...)
+	mux := http.NewServeMux()
+	RegisterHandlers(mux, s)
+	mux.Handle("GET /readyz", readinessHandler(ethParser))
+	mux.Handle("GET /healthz", healthHandler(ethParser))
+	mux.Handle("GET /metrics", metricsHandler(ethParser, s.storage))
+	mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPISpec)
+	})
+	return mux
+}
+
+// SetupDocs registers the Swagger UI, meant for development only since it loads assets from a CDN
+func SetupDocs(mux *http.ServeMux) {
+	mux.HandleFunc("GET /docs", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(swaggerUIPage))
+	})
+}
+
+// apiServer implements the generated ServerInterface on top of a Parser
+type apiServer struct {
+	parser parser.Parser
+	// ethParser is the parser shared by the tenants, for the deployment wide status
+	ethParser *parser.EthParser
+	storage   parser.Storage
+	// tenants isolates the API per tenant, nil when multi-tenancy is disabled
+	tenants *parser.TenantManager
+	// adminKey protects the admin endpoints, which are disabled when it's empty
+	adminKey string
+	// numberEncoding is the encoding of the transaction quantities in the responses
+	numberEncoding string
+	// shareSigner signs the share links, which are disabled when it's nil
+	shareSigner  *parser.ShareLinkSigner
+	shareBaseURL string
+}
+
+// requestUnits parses the ?units= of the computed value fields of the transactions, replying 400 when it's
+// invalid
+func requestUnits(w http.ResponseWriter, r *http.Request) (parser.Units, bool) {
+	units, err := parser.ParseUnits(r.URL.Query().Get("units"))
+	if err != nil {
+		http.Error(w, "Invalid units", http.StatusBadRequest)
+		return parser.Units{}, false
+	}
+	return units, true
+}
+
+// decodeRequest decodes the JSON body of a request, replying 400 when it's invalid
+func decodeRequest(w http.ResponseWriter, r *http.Request, request interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// decodeAddressRequest decodes an AddressRequest, replying 400 when it's invalid or has no address
+func decodeAddressRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var request AddressRequest
+	if !decodeRequest(w, r, &request) {
+		return "", false
+	}
+	if request.Address == "" {
+		http.Error(w, "Address field is required", http.StatusBadRequest)
+		return "", false
+	}
+	return request.Address, true
+}
+
+// GetCurrentBlock returns the current block number
+func (s *apiServer) GetCurrentBlock(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	block := p.GetCurrentBlock()
+	if checkNotModified(w, r, currentBlockETag(block)) {
+		return
+	}
+	json.NewEncoder(w).Encode(CurrentBlockResponse{CurrentBlock: block})
+}
+
+// GetStatus returns the status of the deployment and the detected node capabilities
+func (s *apiServer) GetStatus(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(StatusResponse{
+		CurrentBlock:    s.ethParser.GetCurrentBlock(),
+		ChainIdMismatch: s.ethParser.ChainIDMismatch(),
+		Capabilities:    s.ethParser.Capabilities(),
+		Throttle:        s.ethParser.ThrottleState(),
+	})
+}
+
+// Subscribe subscribes to an Ethereum address
+func (s *apiServer) Subscribe(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	var request parser.Subscription
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+	if message := subscriptionError(request); message != "" {
+		http.Error(w, message, http.StatusBadRequest)
+		return
+	}
+	var success bool
+	if tenant, isTenant := p.(*parser.TenantParser); isTenant {
+		var err error
+		success, err = tenant.TrySubscribe(request)
+		if err == parser.ErrSubscriptionQuotaExceeded {
+			http.Error(w, "Subscription quota exceeded", http.StatusForbidden)
+			return
+		}
+	} else {
+		request.Tenant = ""
+		success = p.SubscribeWith(request)
+	}
+	if success {
+		s.recordAudit(p, r, parser.AuditEntry{Action: parser.AuditSubscribe, Address: request.Address, After: subscriptionState(p, request.Address)})
+	}
+	json.NewEncoder(w).Encode(SuccessResponse{Success: success})
+}
+
+// GetTransactions returns the transactions of a subscribed address
+func (s *apiServer) GetTransactions(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	filters, ok := urlTxFilters(w, r)
+	if !ok {
+		return
+	}
+	units, ok := requestUnits(w, r)
+	if !ok {
+		return
+	}
+	address, ok := decodeAddressRequest(w, r)
+	if !ok {
+		return
+	}
+	s.writeTransactions(w, r, p, address, filters, units)
+}
+
+// writeTransactions writes the page of the transactions of address matching the filters of the request
+func (s *apiServer) writeTransactions(w http.ResponseWriter, r *http.Request, p parser.Parser, address string, filters txFilters, units parser.Units) {
+	page, ok := parsePage(w, r, address)
+	if !ok {
+		return
+	}
+	query, ok := txQuery(w, []string{address}, filters, page)
+	if !ok {
+		return
+	}
+	result := p.QueryTransactions(query)
+	transactions := result.Transactions[address]
+	if page != nil {
+		setNextCursor(w, result.Next[address])
+	}
+	if len(transactions) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if p.TransactionsTruncated(address) {
+		// The bounded storage evicted part of the history
+		w.Header().Set("X-Results-Truncated", "true")
+	}
+	if checkNotModified(w, r, transactionsETag(address, transactions)) {
+		return
+	}
+	flow := parser.ComputeFlow(transactions, address, s.numberEncoding)
+	w.Header().Set("X-Flow-In", flow.TotalIn)
+	w.Header().Set("X-Flow-Out", flow.TotalOut)
+	w.Header().Set("X-Flow-Net", flow.Net)
+	s.streamTransactions(w, r, address, transactions, units)
+}
+
+// maxBatchAddresses caps the addresses of a batch transactions request
+const maxBatchAddresses = 100
+
+// GetTransactionsBatch returns the transactions of several addresses with one storage read
+func (s *apiServer) GetTransactionsBatch(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	units, ok := requestUnits(w, r)
+	if !ok {
+		return
+	}
+	var request TransactionsBatchRequest
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+	switch {
+	case len(request.Addresses) == 0:
+		http.Error(w, "Addresses field is required", http.StatusBadRequest)
+		return
+	case len(request.Addresses) > maxBatchAddresses:
+		http.Error(w, "At most "+strconv.Itoa(maxBatchAddresses)+" addresses per request", http.StatusBadRequest)
+		return
+	case request.Limit < 0 || request.Limit > maxPageLimit:
+		http.Error(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+	query, ok := txQuery(w, request.Addresses, txFilters{
+		fromBlock: request.FromBlock,
+		toBlock:   request.ToBlock,
+		fromTime:  request.FromTime,
+		toTime:    request.ToTime,
+		direction: request.Direction,
+		minValue:  request.MinValue,
+		category:  request.Category,
+		order:     request.Order,
+	}, &pageRequest{limit: request.Limit})
+	if !ok {
+		return
+	}
+	batch := p.QueryTransactions(query).Transactions
+	for address, transactions := range batch {
+		transactions = parser.TransactionsWithUnits(parser.TransactionsWithDirection(transactions, address), units)
+		batch[address] = parser.TransactionsWithNumberEncoding(transactions, s.numberEncoding)
+	}
+	json.NewEncoder(w).Encode(batch)
+}
+
+// GetTransactionByHash returns a transaction by hash, from the storage or the node
+func (s *apiServer) GetTransactionByHash(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	hash := strings.ToLower(r.PathValue("hash"))
+	if !isTransactionHash(hash) {
+		http.Error(w, "Invalid transaction hash", http.StatusBadRequest)
+		return
+	}
+	units, ok := requestUnits(w, r)
+	if !ok {
+		return
+	}
+	lookup, found, err := p.LookupTransaction(hash)
+	if err != nil {
+		log.Printf("Error looking up transaction %s: %v\n", hash, err)
+		http.Error(w, "Transaction lookup failed", http.StatusBadGateway)
+		return
+	}
+	if !found {
+		http.Error(w, "Transaction not found", http.StatusNotFound)
+		return
+	}
+	lookup.Transaction = lookup.Transaction.WithUnits(units).WithNumberEncoding(s.numberEncoding)
+	json.NewEncoder(w).Encode(lookup)
+}
+
+// GetTokenMetadata returns the metadata of a token contract
+func (s *apiServer) GetTokenMetadata(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	address := r.PathValue("address")
+	if _, err := hex.DecodeString(strings.TrimPrefix(address, "0x")); err != nil || len(address) != 42 || address[:2] != "0x" {
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return
+	}
+	metadata, err := p.TokenMetadata(address)
+	if err != nil {
+		// ErrUnknownToken, the node errors are cached as unresolved tokens too
+		http.Error(w, "Unknown token", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(metadata)
+}
+
+// CallContract reads a contract with eth_call through a registered ABI
+func (s *apiServer) CallContract(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	var request parser.ContractCall
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+	result, err := p.CallContract(request)
+	if errors.Is(err, parser.ErrInvalidContractCall) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error calling %s on %s: %v\n", request.Method, request.Contract, err)
+		http.Error(w, "Contract call failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// Long-poll timeouts of WaitForTransactions, in seconds
+const (
+	defaultWaitTimeout = 30
+	maxWaitTimeout     = 60
+)
+
+// WaitForTransactions long-polls the new transactions of a subscribed address after the cursor
+func (s *apiServer) WaitForTransactions(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	address := r.PathValue("address")
+	if _, subscribed := p.GetSubscription(address); !subscribed {
+		http.Error(w, "Address not subscribed", http.StatusNotFound)
+		return
+	}
+	s.waitTransactions(w, r, p, address)
+}
+
+// waitTransactions long-polls the transactions of a subscribed address after the cursor of the request
+func (s *apiServer) waitTransactions(w http.ResponseWriter, r *http.Request, p parser.Parser, address string) {
+	query := r.URL.Query()
+	cursor := -1
+	if value := query.Get("cursor"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		cursor = parsed
+	}
+	timeout := defaultWaitTimeout
+	if value := query.Get("timeout"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 || parsed > maxWaitTimeout {
+			http.Error(w, "Invalid timeout", http.StatusBadRequest)
+			return
+		}
+		timeout = parsed
+	}
+	units, ok := requestUnits(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	transactions, next, _ := p.WaitForTransactions(ctx, address, cursor)
+	if r.Context().Err() != nil {
+		// The client went away
+		return
+	}
+	if transactions == nil {
+		transactions = []parser.Transaction{}
+	}
+	directed := parser.TransactionsWithUnits(parser.TransactionsWithDirection(transactions, address), units)
+	json.NewEncoder(w).Encode(WaitTransactionsResponse{
+		Transactions: parser.TransactionsWithNumberEncoding(directed, s.numberEncoding),
+		Cursor:       next,
+		Flow:         parser.ComputeFlow(transactions, address, s.numberEncoding),
+	})
+}
+
+// GetTransactionChanges returns the transactions of a subscribed address discovered after since_block
+func (s *apiServer) GetTransactionChanges(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	address := r.PathValue("address")
+	if _, subscribed := p.GetSubscription(address); !subscribed {
+		http.Error(w, "Address not subscribed", http.StatusNotFound)
+		return
+	}
+	sinceBlock := 0
+	if value := r.URL.Query().Get("since_block"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			http.Error(w, "Invalid since_block", http.StatusBadRequest)
+			return
+		}
+		sinceBlock = parsed
+	}
+	units, ok := requestUnits(w, r)
+	if !ok {
+		return
+	}
+	transactions, cursor := p.TransactionChanges(address, sinceBlock)
+	if transactions == nil {
+		transactions = []parser.Transaction{}
+	}
+	directed := parser.TransactionsWithUnits(parser.TransactionsWithDirection(transactions, address), units)
+	json.NewEncoder(w).Encode(TransactionChangesResponse{
+		Transactions: parser.TransactionsWithNumberEncoding(directed, s.numberEncoding),
+		Cursor:       cursor,
+	})
+}
+
+// isTransactionHash reports whether s is a 0x prefixed 32 bytes hex hash
+func isTransactionHash(s string) bool {
+	if len(s) != 66 || s[:2] != "0x" {
+		return false
+	}
+	_, err := hex.DecodeString(s[2:])
+	return err == nil
+}
+
+// GetNonceHistory returns the outgoing transactions of an address ordered by nonce, with gap detection
+func (s *apiServer) GetNonceHistory(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	address, ok := decodeAddressRequest(w, r)
+	if !ok {
+		return
+	}
+	history := p.GetNonceHistory(address)
+	history.Transactions = parser.TransactionsWithNumberEncoding(history.Transactions, s.numberEncoding)
+	json.NewEncoder(w).Encode(history)
+}
+
+// GetPendingTransactions returns the tracked pending outgoing transactions of an address
+func (s *apiServer) GetPendingTransactions(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	address, ok := decodeAddressRequest(w, r)
+	if !ok {
+		return
+	}
+	pending := p.GetPendingTransactions(address)
+	for i := range pending {
+		pending[i].Transaction = pending[i].Transaction.WithNumberEncoding(s.numberEncoding)
+	}
+	json.NewEncoder(w).Encode(pending)
+}
+
+// GetAllowances returns the current ERC-20 allowances granted by a subscribed address
+func (s *apiServer) GetAllowances(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	json.NewEncoder(w).Encode(p.GetAllowances(r.PathValue("address")))
+}
+
+// Number of counterparties returned by GetCounterparties
+const (
+	defaultCounterparties = 10
+	maxCounterparties     = 100
+)
+
+// GetCounterparties returns the top counterparties of a subscribed address
+func (s *apiServer) GetCounterparties(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	orderBy := parser.CounterpartyByCount
+	if value := query.Get("orderBy"); value != "" {
+		if value != parser.CounterpartyByCount && value != parser.CounterpartyByValue {
+			http.Error(w, "Invalid orderBy", http.StatusBadRequest)
+			return
+		}
+		orderBy = value
+	}
+	limit := defaultCounterparties
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > maxCounterparties {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+	json.NewEncoder(w).Encode(p.GetCounterparties(r.PathValue("address"), orderBy, limit))
+}
+
+// AddToEntity links an address to an entity
+func (s *apiServer) AddToEntity(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	var request EntityMemberRequest
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+	if request.Entity == "" || request.Address == "" {
+		http.Error(w, "Entity and address fields are required", http.StatusBadRequest)
+		return
+	}
+	before := subscriptionState(p, request.Address)
+	success := p.AddToEntity(request.Entity, request.Address)
+	if success {
+		s.recordAudit(p, r, parser.AuditEntry{Action: parser.AuditAddToEntity, Address: request.Address, Entity: request.Entity,
+			Before: before, After: subscriptionState(p, request.Address)})
+	}
+	json.NewEncoder(w).Encode(SuccessResponse{Success: success})
+}
+
+// RemoveFromEntity unlinks an address from an entity
+func (s *apiServer) RemoveFromEntity(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	var request EntityMemberRequest
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+	if request.Entity == "" || request.Address == "" {
+		http.Error(w, "Entity and address fields are required", http.StatusBadRequest)
+		return
+	}
+	success := p.RemoveFromEntity(request.Entity, request.Address)
+	if success {
+		s.recordAudit(p, r, parser.AuditEntry{Action: parser.AuditRemoveFromEntity, Address: request.Address, Entity: request.Entity})
+	}
+	json.NewEncoder(w).Encode(SuccessResponse{Success: success})
+}
+
+// GetEntityTransactions returns the member addresses and the transactions of an entity
+func (s *apiServer) GetEntityTransactions(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	units, ok := requestUnits(w, r)
+	if !ok {
+		return
+	}
+	var request EntityRequest
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+	if request.Entity == "" {
+		http.Error(w, "Entity field is required", http.StatusBadRequest)
+		return
+	}
+	page, ok := parsePage(w, r, "entity:"+request.Entity)
+	if !ok {
+		return
+	}
+	transactions := p.GetEntityTransactions(request.Entity)
+	if page != nil {
+		var next parser.TransactionCursor
+		transactions, next = parser.PageEntityTransactions(transactions, request.Entity, page.after, page.limit)
+		setNextCursor(w, next)
+	}
+	if len(transactions) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	for i := range transactions {
+		transactions[i].Transaction = transactions[i].Transaction.WithUnits(units).WithNumberEncoding(s.numberEncoding)
+	}
+	json.NewEncoder(w).Encode(EntityTransactionsResponse{
+		Addresses:    p.GetEntityAddresses(request.Entity),
+		Transactions: transactions,
+	})
+}
@@ -0,0 +1,454 @@
+package api_test
+
+import (
+	"encoding/json"
+	"eth-parser/internal/api"
+	"eth-parser/internal/parser"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nodeClient answers every request with block 0
+type nodeClient struct{}
+
+func (nodeClient) SendRequest(req parser.JSONRPCRequest) (parser.JSONRPCResponse, error) {
+	return parser.JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: "0x0"}, nil
+}
+
+func newParser() *parser.EthParser {
+	return parser.New(parser.NewMemoryStorage(), 1, nodeClient{}, func(string, []parser.Transaction) {})
+}
+
+func serve(handler http.Handler, method string, path string, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPIHandlersMountedUnderARouter(t *testing.T) {
+	first, second := newParser(), newParser()
+	router := http.NewServeMux()
+	router.Handle("/first/", http.StripPrefix("/first", api.NewAPIHandler(first)))
+	router.Handle("/second/", http.StripPrefix("/second", api.NewAPIHandler(second)))
+
+	if rec := serve(router, http.MethodPost, "/first/subscribe", `{"address":"0x1"}`, nil); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if _, ok := first.GetSubscription("0x1"); !ok {
+		t.Error("Expected the first parser to be subscribed")
+	}
+	if _, ok := second.GetSubscription("0x1"); ok {
+		t.Error("Expected the second parser not to be subscribed")
+	}
+	if rec := serve(router, http.MethodGet, "/second/openapi.json", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected the OpenAPI document, got %d", rec.Code)
+	}
+}
+
+func TestAdminHandlerSeparateFromPublic(t *testing.T) {
+	ethParser := newParser()
+	public := api.PublicOnly(api.NewAPIHandler(ethParser, api.WithAdminKey("secret")))
+	admin := api.NewAdminHandler(ethParser, api.WithAdminKey("secret"))
+
+	for _, path := range []string{"/metrics", "/healthz", "/admin/storage"} {
+		if rec := serve(public, http.MethodGet, path, "", map[string]string{"X-Admin-Key": "secret"}); rec.Code != http.StatusNotFound {
+			t.Errorf("Expected %s to be hidden from the public handler, got %d", path, rec.Code)
+		}
+	}
+	if rec := serve(admin, http.MethodGet, "/metrics", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without the admin key, got %d", rec.Code)
+	}
+	if rec := serve(admin, http.MethodGet, "/metrics", "", map[string]string{"Authorization": "Bearer secret"}); rec.Code != http.StatusOK {
+		t.Errorf("Expected the metrics with the admin key, got %d", rec.Code)
+	}
+	if rec := serve(admin, http.MethodGet, "/admin/storage", "", map[string]string{"X-Admin-Key": "secret"}); rec.Code != http.StatusOK {
+		t.Errorf("Expected the storage stats with the admin key, got %d", rec.Code)
+	}
+}
+
+func TestHealthAndReadiness(t *testing.T) {
+	ethParser := newParser()
+	ethParser.Health().Register("broker", func() (string, string) { return parser.HealthDegraded, "reconnecting" })
+	handler := api.NewAPIHandler(ethParser)
+
+	if rec := serve(handler, http.MethodGet, "/readyz", "", nil); rec.Code != http.StatusOK || rec.Body.String() != "ready, degraded: broker: reconnecting\n" {
+		t.Errorf("Expected a degraded readiness, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	ethParser.Health().Register("broker", func() (string, string) { return parser.HealthDown, "disconnected" })
+	rec := serve(handler, http.MethodGet, "/healthz", "", nil)
+	var report parser.HealthReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusServiceUnavailable || report.Status != parser.HealthDown || len(report.Components) != 6 {
+		t.Errorf("Expected the health report of the components, got %d %+v", rec.Code, report)
+	}
+	if rec := serve(handler, http.MethodGet, "/readyz", "", nil); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected the parser to be unready, got %d", rec.Code)
+	}
+}
+
+func TestGetTransactionsStreaming(t *testing.T) {
+	storage := parser.NewMemoryStorage()
+	storage.SaveTransactions("0x1", []parser.Transaction{
+		{Hash: "0xa1", From: "0x1", To: "0x2", Value: "0x1", BlockNumberDecimal: 1},
+		{Hash: "0xa2", From: "0x2", To: "0x1", Value: "0x2", BlockNumberDecimal: 2},
+	})
+	ethParser := parser.New(storage, 1, nodeClient{}, func(string, []parser.Transaction) {})
+	ethParser.Subscribe("0x1")
+	handler := api.NewAPIHandler(ethParser)
+
+	rec := serve(handler, http.MethodPost, "/transactions", `{"address":"0x1"}`, nil)
+	var transactions []parser.Transaction
+	if err := json.Unmarshal(rec.Body.Bytes(), &transactions); err != nil || len(transactions) != 2 || transactions[1].Direction != "in" {
+		t.Fatalf("Expected the JSON array of the transactions, got %s: %v", rec.Body, err)
+	}
+	if contentType := rec.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Unexpected content type %q", contentType)
+	}
+
+	rec = serve(handler, http.MethodPost, "/transactions", `{"address":"0x1"}`, map[string]string{"Accept": "application/x-ndjson"})
+	lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+	if rec.Header().Get("Content-Type") != "application/x-ndjson" || len(lines) != 2 {
+		t.Fatalf("Expected one transaction per line, got %q", rec.Body)
+	}
+	var tx parser.Transaction
+	if err := json.Unmarshal([]byte(lines[0]), &tx); err != nil || tx.Hash != "0xa1" || tx.Direction != "out" {
+		t.Errorf("Unexpected first line %q: %v", lines[0], err)
+	}
+	if rec := serve(handler, http.MethodPost, "/transactions?format=ndjson", `{"address":"0x1"}`, nil); rec.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("Expected format=ndjson to select NDJSON, got %q", rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestMuteSubscription(t *testing.T) {
+	ethParser := newParser()
+	ethParser.Subscribe("0x1")
+	handler := api.NewAPIHandler(ethParser)
+
+	if rec := serve(handler, http.MethodPost, "/subscriptions/0x1/mute?duration=soon", "", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid duration, got %d", rec.Code)
+	}
+	if rec := serve(handler, http.MethodPost, "/subscriptions/0x2/mute", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unsubscribed address, got %d", rec.Code)
+	}
+	rec := serve(handler, http.MethodPost, "/subscriptions/0x1/mute?duration=2h", "", nil)
+	var subscription parser.Subscription
+	if err := json.Unmarshal(rec.Body.Bytes(), &subscription); err != nil || !subscription.Muted || subscription.MutedUntil == nil {
+		t.Fatalf("Expected the muted subscription, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(handler, http.MethodDelete, "/subscriptions/0x1/mute", "", nil); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	if subscription, _ := ethParser.GetSubscription("0x1"); subscription.Muted {
+		t.Errorf("Expected the subscription to be unmuted, got %+v", subscription)
+	}
+}
+
+func TestSubscribeMetadata(t *testing.T) {
+	ethParser := newParser()
+	handler := api.NewAPIHandler(ethParser)
+
+	if rec := serve(handler, http.MethodPost, "/subscribe", `{"address":"0x1","metadata":"`+strings.Repeat("x", parser.MaxSubscriptionMetadataSize)+`"}`, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for oversized metadata, got %d", rec.Code)
+	}
+	rec := serve(handler, http.MethodPost, "/subscribe", `{"address":"0x1","metadata":{"userId":42}}`, nil)
+	if subscription, _ := ethParser.GetSubscription("0x1"); string(subscription.Metadata) != `{"userId":42}` {
+		t.Fatalf("Expected the metadata to be stored verbatim, got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestSubscribeEmailRecipients(t *testing.T) {
+	ethParser := newParser()
+	handler := api.NewAPIHandler(ethParser)
+
+	for _, recipients := range []string{`[]`, `["not an address"]`, `["a@example.com\r\nBcc: victim@example.com"]`, `["a@example.com","b@example.com","c@example.com","d@example.com","e@example.com","f@example.com","g@example.com","h@example.com","i@example.com","j@example.com","k@example.com"]`} {
+		if rec := serve(handler, http.MethodPost, "/subscribe", `{"address":"0x1","email":{"recipients":`+recipients+`}}`, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for the recipients %s, got %d", recipients, rec.Code)
+		}
+	}
+	if _, subscribed := ethParser.GetSubscription("0x1"); subscribed {
+		t.Fatal("Expected the invalid subscriptions to be rejected")
+	}
+	if rec := serve(handler, http.MethodPost, "/subscribe", `{"address":"0x1","email":{"recipients":["Alice <alice@example.com>"]}}`, nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 for a named recipient, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestRuntimeConfig(t *testing.T) {
+	ethParser := newParser()
+	handler := api.NewAPIHandler(ethParser, api.WithAdminKey("secret"))
+	admin := map[string]string{"X-Admin-Key": "secret"}
+
+	if rec := serve(handler, http.MethodPatch, "/admin/config", `{"rpcRateLimit": 10}`, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without the admin key, got %d", rec.Code)
+	}
+	if rec := serve(handler, http.MethodPatch, "/admin/config", `{"fetchWorkers": 0}`, admin); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid value, got %d", rec.Code)
+	}
+
+	rec := serve(handler, http.MethodPatch, "/admin/config", `{"rpcRateLimit": 10, "maxBlocksPerCycle": 20}`, admin)
+	var config parser.RuntimeConfig
+	if err := json.Unmarshal(rec.Body.Bytes(), &config); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("Expected the updated config, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if config.RPCRateLimit != 10 || config.MaxBlocksPerCycle != 20 || config.FetchWorkers != 1 {
+		t.Errorf("Expected the given knobs to change, got %+v", config)
+	}
+	if got := ethParser.Config(); got != config {
+		t.Errorf("Expected GET to match the update, got %+v", got)
+	}
+	if rec := serve(handler, http.MethodGet, "/admin/config", "", admin); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"maxBlocksPerCycle":20`) {
+		t.Errorf("Expected the config, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestUsage(t *testing.T) {
+	ethParser := newParser()
+	ethParser.ProcessNextCycle()
+	handler := api.NewAPIHandler(ethParser, api.WithAdminKey("secret"))
+	if rec := serve(handler, http.MethodGet, "/admin/usage", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without the admin key, got %d", rec.Code)
+	}
+	rec := serve(handler, http.MethodGet, "/admin/usage", "", map[string]string{"X-Admin-Key": "secret"})
+	var report parser.UsageReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); rec.Code != http.StatusOK || err != nil || report.Calls == 0 {
+		t.Fatalf("Expected the requests of the cycle, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if report.Plan != nil || report.ExceedsPlan || report.ProjectedUnits < report.Units {
+		t.Errorf("Expected a projection without plan, got %+v", report)
+	}
+}
+
+func TestConsumers(t *testing.T) {
+	if rec := serve(api.NewAPIHandler(newParser()), http.MethodPost, "/consumers", `{"name": "billing"}`, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 when the consumers are disabled, got %d", rec.Code)
+	}
+
+	storage := parser.NewMemoryStorage()
+	ethParser := parser.New(storage, 1, nodeClient{}, func(string, []parser.Transaction) {}, parser.WithConsumers(storage, 0))
+	handler := api.NewAPIHandler(ethParser)
+	if rec := serve(handler, http.MethodPost, "/consumers", `{"name": "bad name"}`, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid name, got %d", rec.Code)
+	}
+	if rec := serve(handler, http.MethodPost, "/consumers", `{"name": "billing"}`, nil); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"name":"billing"`) {
+		t.Fatalf("Expected the registered consumer, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(handler, http.MethodGet, "/events?consumer=billing&max=0", "", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid max, got %d", rec.Code)
+	}
+	if rec := serve(handler, http.MethodGet, "/events?consumer=unknown", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown consumer, got %d", rec.Code)
+	}
+
+	storage.AddConsumerEvent("billing", parser.OutboxEvent{ID: "1:0x1", Address: "0x1", BlockNumber: 1,
+		Transactions: []parser.Transaction{{Hash: "0xa1", To: "0x1", Value: "0x64"}}})
+	rec := serve(handler, http.MethodGet, "/events?consumer=billing&max=10", "", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"id":"1:0x1"`) {
+		t.Fatalf("Expected the retained event, got %d: %s", rec.Code, rec.Body.String())
+	}
+	// The quantities are encoded like the other responses
+	if !strings.Contains(rec.Body.String(), `"value":"100"`) {
+		t.Errorf("Expected the decimal value of the transaction, got %s", rec.Body.String())
+	}
+	rec = serve(handler, http.MethodGet, "/events?consumer=billing", "", map[string]string{"Accept": "application/x-protobuf"})
+	var events []parser.OutboxEvent
+	if err := (parser.ProtobufCodec{}).Unmarshal(rec.Body.Bytes(), &events); err != nil || len(events) != 1 || events[0].ID != "1:0x1" {
+		t.Fatalf("Expected the protobuf encoded event, got %+v, %v", events, err)
+	}
+	if rec := serve(handler, http.MethodPost, "/events/ack", `{"consumer": "billing", "ids": ["1:0x1"]}`, nil); rec.Code != http.StatusOK {
+		t.Fatalf("Expected the acknowledgment, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(handler, http.MethodGet, "/events?consumer=billing", "", nil); strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("Expected no event after the acknowledgment, got %s", rec.Body.String())
+	}
+	if rec := serve(handler, http.MethodDelete, "/consumers/billing", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected the consumer to be unregistered, got %d", rec.Code)
+	}
+
+	// With multi-tenancy, the consumers are scoped to the tenant of the API key
+	tenants := parser.NewTenantManager(ethParser)
+	keyA, _ := tenants.CreateTenant("a", "Team A", 0)
+	keyB, _ := tenants.CreateTenant("b", "Team B", 0)
+	handler = api.NewAPIHandler(ethParser, api.WithTenants(tenants))
+	if rec := serve(handler, http.MethodPost, "/consumers", `{"name": "billing"}`, map[string]string{"X-API-Key": keyA}); rec.Code != http.StatusOK {
+		t.Fatalf("Expected the registered consumer, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(handler, http.MethodGet, "/consumers", "", map[string]string{"X-API-Key": keyB}); strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("Expected no consumer for another tenant, got %s", rec.Body.String())
+	}
+	if rec := serve(handler, http.MethodGet, "/events?consumer=billing", "", map[string]string{"X-API-Key": keyB}); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for the consumer of another tenant, got %d", rec.Code)
+	}
+	if rec := serve(handler, http.MethodDelete, "/consumers/billing", "", map[string]string{"X-API-Key": keyB}); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for the consumer of another tenant, got %d", rec.Code)
+	}
+}
+
+func TestTimeSeries(t *testing.T) {
+	storage := parser.NewMemoryStorage()
+	blockTime := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	storage.SaveTransactions("0xabc", []parser.Transaction{{Hash: "0x1", From: "0xdef", To: "0xabc", Value: "0x64", BlockTime: &blockTime}})
+	handler := api.NewAPIHandler(parser.New(storage, 1, nodeClient{}, func(string, []parser.Transaction) {}))
+
+	rec := serve(handler, http.MethodGet, "/addresses/0xABC/timeseries?metric=volume&interval=1d", "", nil)
+	var series parser.TimeSeries
+	if err := json.Unmarshal(rec.Body.Bytes(), &series); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("Expected the time series, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(series.Buckets) != 1 || series.Buckets[0].Count != 1 || series.Buckets[0].Volume != "100" || !series.Buckets[0].Start.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected a daily bucket, got %+v", series)
+	}
+	for _, query := range []string{"metric=gas", "interval=0d", "interval=1s", "from=yesterday"} {
+		if rec := serve(handler, http.MethodGet, "/addresses/0xabc/timeseries?"+query, "", nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestShareLinks(t *testing.T) {
+	storage := parser.NewMemoryStorage()
+	storage.SaveTransactions("0xabc", []parser.Transaction{{Hash: "0x1", From: "0xdef", To: "0xabc", Value: "0x64", BlockNumber: "0x1", BlockNumberDecimal: 1}})
+	ethParser := parser.New(storage, 1, nodeClient{}, func(string, []parser.Transaction) {})
+	ethParser.Subscribe("0xabc")
+	if rec := serve(api.NewAPIHandler(ethParser), http.MethodPost, "/subscriptions/0xabc/share", `{}`, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 when the share links are disabled, got %d", rec.Code)
+	}
+
+	handler := api.NewAPIHandler(ethParser, api.WithShareLinks(parser.ShareLinkSigner{Secret: []byte("secret")}, "https://parser.example.com/"))
+	if rec := serve(handler, http.MethodPost, "/subscriptions/0xabc/share", `{"expiresIn": "1000h"}`, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 beyond the longest expiry, got %d", rec.Code)
+	}
+	if rec := serve(handler, http.MethodPost, "/subscriptions/0x123/share", `{}`, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an address not subscribed, got %d", rec.Code)
+	}
+	rec := serve(handler, http.MethodPost, "/subscriptions/0xabc/share", `{"expiresIn": "1h"}`, nil)
+	var link api.ShareLink
+	if err := json.Unmarshal(rec.Body.Bytes(), &link); rec.Code != http.StatusCreated || err != nil {
+		t.Fatalf("Expected the share link, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if link.Url != "https://parser.example.com/shared/"+link.Token+"/transactions" {
+		t.Errorf("Expected an absolute link, got %s", link.Url)
+	}
+
+	rec = serve(handler, http.MethodGet, "/shared/"+link.Token+"/transactions", "", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"hash":"0x1"`) {
+		t.Fatalf("Expected the shared transactions, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(handler, http.MethodGet, "/shared/"+link.Token+"/transactions/wait?cursor=0&timeout=0", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected the shared long poll, got %d", rec.Code)
+	}
+	forged := strings.Replace(link.Token, ".", ".0", 1)
+	if rec := serve(handler, http.MethodGet, "/shared/"+forged+"/transactions", "", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a forged link, got %d", rec.Code)
+	}
+	expired := parser.ShareLinkSigner{Secret: []byte("secret")}.Sign(parser.ShareGrant{Address: "0xabc", ExpiresAt: time.Now().Add(-time.Minute)})
+	if rec := serve(handler, http.MethodGet, "/shared/"+expired+"/transactions", "", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for an expired link, got %d", rec.Code)
+	}
+
+	ethParser.Unsubscribe("0xabc")
+	if rec := serve(handler, http.MethodGet, "/shared/"+link.Token+"/transactions", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 once unsubscribed, got %d", rec.Code)
+	}
+}
+
+func TestFederation(t *testing.T) {
+	newMember := func(value string, blockTime time.Time) (*parser.EthParser, *httptest.Server) {
+		storage := parser.NewMemoryStorage()
+		storage.SaveTransactions("0xabc", []parser.Transaction{{Hash: "0x" + value, From: "0xdef", To: "0xabc", Value: "0x" + value, BlockNumber: "0x1", BlockNumberDecimal: 1, BlockTime: &blockTime}})
+		ethParser := parser.New(storage, 1, nodeClient{}, func(string, []parser.Transaction) {})
+		return ethParser, httptest.NewServer(api.NewAPIHandler(ethParser))
+	}
+	mainnet, mainnetServer := newMember("2", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	defer mainnetServer.Close()
+	polygon, polygonServer := newMember("1", time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+	handler := api.NewFederationHandler([]api.FederationMember{{Name: "mainnet", URL: mainnetServer.URL}, {Name: "polygon", URL: polygonServer.URL}}, http.DefaultClient)
+
+	rec := serve(handler, http.MethodPost, "/subscribe", `{"address": "0xabc"}`, nil)
+	_, onMainnet := mainnet.GetSubscription("0xabc")
+	_, onPolygon := polygon.GetSubscription("0xabc")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"success":true`) || !onMainnet || !onPolygon {
+		t.Fatalf("Expected the subscription on every member, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = serve(handler, http.MethodPost, "/transactions", `{"address": "0xabc"}`, nil)
+	var transactions []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &transactions); err != nil || len(transactions) != 2 {
+		t.Fatalf("Expected the transactions of both members, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if transactions[0]["member"] != "polygon" || transactions[1]["member"] != "mainnet" {
+		t.Errorf("Expected the transactions by block time with their member, got %+v", transactions)
+	}
+	if rec := serve(handler, http.MethodPost, "/transactions?limit=10", `{"address": "0xabc"}`, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a paginated query, got %d", rec.Code)
+	}
+	if rec := serve(handler, http.MethodPost, "/transactions?member=base", `{"address": "0xabc"}`, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown member, got %d", rec.Code)
+	}
+
+	if rec := serve(handler, http.MethodGet, "/status", "", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected the member to be required by a proxied endpoint, got %d", rec.Code)
+	}
+	if rec := serve(handler, http.MethodGet, "/status?member=polygon", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected the status of the member, got %d", rec.Code)
+	}
+
+	// A member down leaves a partial response
+	polygonServer.Close()
+	rec = serve(handler, http.MethodPost, "/transactions", `{"address": "0xabc"}`, nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Federation-Unavailable") != "polygon" || strings.Count(rec.Body.String(), `"hash"`) != 1 {
+		t.Errorf("Expected the transactions of mainnet only, got %d %v: %s", rec.Code, rec.Header(), rec.Body.String())
+	}
+	rec = serve(handler, http.MethodGet, "/federation/members", "", nil)
+	var statuses []api.MemberStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &statuses); err != nil || len(statuses) != 2 || !statuses[0].Reachable || statuses[1].Reachable {
+		t.Errorf("Expected mainnet to be reachable only, got %+v", statuses)
+	}
+}
+
+func TestWatchTransactionTenants(t *testing.T) {
+	ethParser := newParser()
+	tenants := parser.NewTenantManager(ethParser)
+	keyA, _ := tenants.CreateTenant("a", "Team A", 0)
+	keyB, _ := tenants.CreateTenant("b", "Team B", 0)
+	handler := api.NewAPIHandler(ethParser, api.WithTenants(tenants))
+	hash := "0x" + strings.Repeat("ab", 32)
+
+	// A watch is only readable by the tenant that made it
+	if rec := serve(handler, http.MethodPost, "/watch_tx", `{"hash": "`+hash+`"}`, map[string]string{"X-API-Key": keyA}); rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected the watch, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(handler, http.MethodGet, "/watch_tx/"+hash, "", map[string]string{"X-API-Key": keyA}); rec.Code != http.StatusOK {
+		t.Errorf("Expected the watch of tenant a, got %d", rec.Code)
+	}
+	if rec := serve(handler, http.MethodGet, "/watch_tx/"+hash, "", map[string]string{"X-API-Key": keyB}); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for the watch of another tenant, got %d", rec.Code)
+	}
+	if _, found := ethParser.WatchedTransaction(hash); found {
+		t.Error("The watch of a tenant must not be one of the shared parser")
+	}
+
+	// Each tenant has its own quota of watches
+	for i := 1; i < 1000; i++ {
+		body := fmt.Sprintf(`{"hash": "0x%064x"}`, i)
+		if rec := serve(handler, http.MethodPost, "/watch_tx", body, map[string]string{"X-API-Key": keyA}); rec.Code != http.StatusAccepted {
+			t.Fatalf("Expected the watch %d, got %d", i, rec.Code)
+		}
+	}
+	if rec := serve(handler, http.MethodPost, "/watch_tx", `{"hash": "0x`+strings.Repeat("cd", 32)+`"}`, map[string]string{"X-API-Key": keyA}); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected 429 beyond the quota of tenant a, got %d", rec.Code)
+	}
+	if rec := serve(handler, http.MethodPost, "/watch_tx", `{"hash": "`+hash+`"}`, map[string]string{"X-API-Key": keyB}); rec.Code != http.StatusAccepted {
+		t.Errorf("Expected tenant b to watch within its own quota, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"eth-parser/internal/parser"
+)
+
+// idempotencyPurgePeriod is the minimum interval between two purges of the expired idempotency records
+const idempotencyPurgePeriod = time.Minute
+
+// idempotencyMiddleware replays the stored response of a write request retried with the same Idempotency-Key
+// header within the window, instead of running it again. Keys are scoped per API key, so tenants can't clash.
+type idempotencyMiddleware struct {
+	store     parser.IdempotencyStore
+	window    time.Duration
+	next      http.Handler
+	inFlight  map[string]bool
+	lastPurge time.Time
+	mu        sync.Mutex
+}
+
+// NewIdempotencyMiddleware wraps next with the Idempotency-Key support, records are kept for window
+func NewIdempotencyMiddleware(store parser.IdempotencyStore, window time.Duration, next http.Handler) http.Handler {
+	return &idempotencyMiddleware{store: store, window: window, next: next, inFlight: make(map[string]bool)}
+}
+
+// responseRecorder captures the response of a request while writing it
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	r.statusCode = statusCode
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// ServeHTTP runs the request once per idempotency key
+func (m *idempotencyMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if idempotencyKey == "" || (r.Method != http.MethodPost && r.Method != http.MethodDelete) {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	scope := sha256.Sum256([]byte(r.Header.Get("X-API-Key")))
+	key := hex.EncodeToString(scope[:8]) + ":" + idempotencyKey
+	fingerprint := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"\n"), body...))
+	requestHash := hex.EncodeToString(fingerprint[:])
+	now := time.Now()
+
+	m.mu.Lock()
+	if m.inFlight[key] {
+		m.mu.Unlock()
+		http.Error(w, "A request with this Idempotency-Key is in progress", http.StatusConflict)
+		return
+	}
+	m.inFlight[key] = true
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.inFlight, key)
+		m.mu.Unlock()
+	}()
+
+	record, found, err := m.store.GetIdempotencyRecord(key)
+	if err != nil {
+		log.Println("Error reading idempotency record:", err)
+		http.Error(w, "Could not check the Idempotency-Key", http.StatusInternalServerError)
+		return
+	}
+	if found && now.Sub(record.CreatedAt) < m.window {
+		if record.RequestHash != requestHash {
+			http.Error(w, "Idempotency-Key already used for a different request", http.StatusUnprocessableEntity)
+			return
+		}
+		if record.ContentType != "" {
+			w.Header().Set("Content-Type", record.ContentType)
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(record.StatusCode)
+		w.Write(record.Body)
+		return
+	}
+
+	recorder := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+	m.next.ServeHTTP(recorder, r)
+
+	// Server errors are not stored, so that a retry runs the request again
+	if recorder.statusCode >= http.StatusInternalServerError {
+		return
+	}
+	err = m.store.SaveIdempotencyRecord(parser.IdempotencyRecord{
+		Key:         key,
+		RequestHash: requestHash,
+		StatusCode:  recorder.statusCode,
+		ContentType: recorder.Header().Get("Content-Type"),
+		Body:        recorder.body.Bytes(),
+		CreatedAt:   now,
+	})
+	if err != nil {
+		log.Println("Error saving idempotency record:", err)
+	}
+	m.purgeExpired(now)
+}
+
+// purgeExpired deletes the records older than the window, at most once per idempotencyPurgePeriod
+func (m *idempotencyMiddleware) purgeExpired(now time.Time) {
+	m.mu.Lock()
+	if now.Sub(m.lastPurge) < idempotencyPurgePeriod {
+		m.mu.Unlock()
+		return
+	}
+	m.lastPurge = now
+	m.mu.Unlock()
+
+	if err := m.store.DeleteIdempotencyRecordsBefore(now.Add(-m.window)); err != nil {
+		log.Println("Error purging idempotency records:", err)
+	}
+}
@@ -0,0 +1,339 @@
+package api
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidToken is returned by JWTValidator.Validate, wrapped with the reason
+var ErrInvalidToken = errors.New("invalid token")
+
+const (
+	// defaultJWKSRefresh is the interval between two fetches of the JWKS, to pick up the rotated keys
+	defaultJWKSRefresh = time.Hour
+	// jwksMinRefetch is the minimum interval between the fetches of the JWKS for an unknown key ID
+	jwksMinRefetch = time.Minute
+	// jwksFetchTimeout bounds a fetch of the JWKS with the default client
+	jwksFetchTimeout = 10 * time.Second
+)
+
+// JWTConfig configures a JWTValidator
+type JWTConfig struct {
+	// JWKSURL is the URL of the JSON Web Key Set of the issuer, e.g. https://issuer/.well-known/jwks.json
+	JWKSURL string
+	// Issuer is the required iss claim, if set
+	Issuer string
+	// Audience is a required value of the aud claim, if set
+	Audience string
+	// Leeway is the clock skew allowed on the exp and nbf claims
+	Leeway time.Duration
+	// RefreshInterval is the interval between two fetches of the JWKS, one hour by default
+	RefreshInterval time.Duration
+}
+
+// JWTClaims are the registered claims of a validated token
+type JWTClaims struct {
+	Subject   string
+	Issuer    string
+	Audience  []string
+	ExpiresAt time.Time
+}
+
+// JWTValidator validates the RS256/384/512 and ES256/384/512 JSON Web Tokens signed by a key of a JWKS, fetched
+// on the first token and again every RefreshInterval or for an unknown key ID
+type JWTValidator struct {
+	config JWTConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	fetch     *jwksFetch // the fetch in progress, nil when none
+}
+
+// jwksFetch is a fetch of the JWKS shared by the tokens validated while it runs
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// NewJWTValidator creates a JWTValidator fetching the JWKS with client, a client with a 10 seconds timeout
+// when nil
+func NewJWTValidator(config JWTConfig, client *http.Client) *JWTValidator {
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = defaultJWKSRefresh
+	}
+	if client == nil {
+		client = &http.Client{Timeout: jwksFetchTimeout}
+	}
+	return &JWTValidator{config: config, client: client}
+}
+
+// jwtHeader is the JOSE header of a token
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// jwtPayload is the payload of a token, aud being a string or an array
+type jwtPayload struct {
+	Sub string          `json:"sub"`
+	Iss string          `json:"iss"`
+	Aud json.RawMessage `json:"aud"`
+	Exp *float64        `json:"exp"`
+	Nbf *float64        `json:"nbf"`
+}
+
+// Validate checks the signature, the expiry, the issuer and the audience of a compact JWT
+func (v *JWTValidator) Validate(token string) (JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return JWTClaims{}, fmt.Errorf("%w: malformed", ErrInvalidToken)
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return JWTClaims{}, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return JWTClaims{}, fmt.Errorf("%w: signature encoding", ErrInvalidToken)
+	}
+	key, err := v.key(header.Kid)
+	if err != nil {
+		return JWTClaims{}, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return JWTClaims{}, err
+	}
+
+	var payload jwtPayload
+	if err := decodeSegment(parts[1], &payload); err != nil {
+		return JWTClaims{}, fmt.Errorf("%w: payload: %v", ErrInvalidToken, err)
+	}
+	claims := JWTClaims{Subject: payload.Sub, Issuer: payload.Iss}
+	if len(payload.Aud) > 0 {
+		var audience string
+		if json.Unmarshal(payload.Aud, &audience) == nil {
+			claims.Audience = []string{audience}
+		} else if err := json.Unmarshal(payload.Aud, &claims.Audience); err != nil {
+			return JWTClaims{}, fmt.Errorf("%w: aud claim", ErrInvalidToken)
+		}
+	}
+	now := time.Now()
+	if payload.Exp == nil {
+		return JWTClaims{}, fmt.Errorf("%w: no exp claim", ErrInvalidToken)
+	}
+	claims.ExpiresAt = time.Unix(int64(*payload.Exp), 0)
+	if now.After(claims.ExpiresAt.Add(v.config.Leeway)) {
+		return JWTClaims{}, fmt.Errorf("%w: expired", ErrInvalidToken)
+	}
+	if payload.Nbf != nil && now.Add(v.config.Leeway).Before(time.Unix(int64(*payload.Nbf), 0)) {
+		return JWTClaims{}, fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+	}
+	if v.config.Issuer != "" && claims.Issuer != v.config.Issuer {
+		return JWTClaims{}, fmt.Errorf("%w: issuer", ErrInvalidToken)
+	}
+	if v.config.Audience != "" && !containsString(claims.Audience, v.config.Audience) {
+		return JWTClaims{}, fmt.Errorf("%w: audience", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a token
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// verifySignature checks the signature of the signing input with the key of the algorithm. The symmetric and
+// "none" algorithms are rejected: the keys of a JWKS are public.
+func verifySignature(alg string, key crypto.PublicKey, input string, signature []byte) error {
+	var hasher hash.Hash
+	var hashID crypto.Hash
+	switch alg[min(2, len(alg)):] {
+	case "256":
+		hasher, hashID = sha256.New(), crypto.SHA256
+	case "384":
+		hasher, hashID = sha512.New384(), crypto.SHA384
+	case "512":
+		hasher, hashID = sha512.New(), crypto.SHA512
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, alg)
+	}
+	hasher.Write([]byte(input))
+	digest := hasher.Sum(nil)
+
+	switch {
+	case strings.HasPrefix(alg, "RS"):
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPKCS1v15(rsaKey, hashID, digest, signature) != nil {
+			return fmt.Errorf("%w: signature", ErrInvalidToken)
+		}
+	case strings.HasPrefix(alg, "ES"):
+		// ES256 is signed with a P-256 key, ES384 with P-384 and ES512 with P-521
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || ecKey.Curve.Params().BitSize != map[crypto.Hash]int{crypto.SHA256: 256, crypto.SHA384: 384, crypto.SHA512: 521}[hashID] ||
+			len(signature) != 2*((ecKey.Curve.Params().BitSize+7)/8) {
+			return fmt.Errorf("%w: signature", ErrInvalidToken)
+		}
+		half := len(signature) / 2
+		r, s := new(big.Int).SetBytes(signature[:half]), new(big.Int).SetBytes(signature[half:])
+		if !ecdsa.Verify(ecKey, digest, r, s) {
+			return fmt.Errorf("%w: signature", ErrInvalidToken)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, alg)
+	}
+	return nil
+}
+
+// key returns the key of the key ID, fetching the JWKS when it's stale or misses the key. The JWKS is fetched
+// without holding the lock, once for all the tokens waiting for it, so that a slow issuer doesn't block the
+// validation of the tokens signed by the known keys.
+func (v *JWTValidator) key(kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := time.Now()
+	key, found := v.keys[kid]
+	stale := v.keys == nil || now.Sub(v.fetchedAt) >= v.config.RefreshInterval
+	if stale || (!found && now.Sub(v.fetchedAt) >= jwksMinRefetch) {
+		fetch := v.fetch
+		if fetch == nil {
+			fetch = &jwksFetch{done: make(chan struct{})}
+			v.fetch = fetch
+			v.mu.Unlock()
+			keys, err := v.fetchKeys()
+			v.mu.Lock()
+			// Keep validating with the last keys while the issuer is unreachable
+			if err == nil {
+				v.keys = keys
+			}
+			fetch.err = err
+			v.fetchedAt = now
+			v.fetch = nil
+			close(fetch.done)
+		} else {
+			v.mu.Unlock()
+			<-fetch.done
+			v.mu.Lock()
+		}
+		if fetch.err != nil && v.keys == nil {
+			return nil, fmt.Errorf("fetching the JWKS: %w", fetch.err)
+		}
+		key, found = v.keys[kid]
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: unknown key ID %q", ErrInvalidToken, kid)
+	}
+	return key, nil
+}
+
+// jwk is a JSON Web Key of an RSA or EC public key
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetchKeys fetches the signature keys of the JWKS by key ID, skipping the keys of other types or uses
+func (v *JWTValidator) fetchKeys() (map[string]crypto.PublicKey, error) {
+	resp, err := v.client.Get(v.config.JWKSURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		publicKey, err := key.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", key.Kid, err)
+		}
+		if publicKey != nil {
+			keys[key.Kid] = publicKey
+		}
+	}
+	return keys, nil
+}
+
+// publicKey decodes the key, nil for an unsupported key type
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	decode := func(value string) (*big.Int, error) {
+		data, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil || len(data) == 0 {
+			return nil, errors.New("invalid parameter encoding")
+		}
+		return new(big.Int).SetBytes(data), nil
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"eth-parser/internal/parser"
+)
+
+// writeGauge writes a gauge in the Prometheus text exposition format
+func writeGauge(w io.Writer, name string, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", name, help, name, name, value)
+}
+
+// metricsHandler serves the Prometheus metrics of the storage and of the fetch loop lag
+func metricsHandler(ethParser *parser.EthParser, storage parser.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats, err := storage.Stats()
+		if err != nil {
+			log.Println("Error reading storage stats:", err)
+			http.Error(w, "Could not read the storage stats", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeGauge(w, "ethparser_storage_addresses", "Number of addresses with stored transactions.", float64(stats.Addresses))
+		writeGauge(w, "ethparser_storage_transactions", "Number of stored transactions.", float64(stats.Transactions))
+		writeGauge(w, "ethparser_storage_bytes", "Approximate size of the stored transactions in bytes.", float64(stats.ApproximateBytes))
+		writeGauge(w, "ethparser_storage_oldest_block", "Oldest block with stored transactions.", float64(stats.OldestBlock))
+		writeGauge(w, "ethparser_storage_newest_block", "Newest block with stored transactions.", float64(stats.NewestBlock))
+
+		lag := ethParser.BackpressureStats()
+		writeGauge(w, "ethparser_block_lag", "Known blocks not processed yet.", float64(lag.Lag))
+		writeGauge(w, "ethparser_block_lag_max", "Highest lag observed.", float64(lag.MaxLag))
+		writeGauge(w, "ethparser_catch_up_cycles", "Fetch cycles that left blocks behind for the next one.", float64(lag.CatchUpCycles))
+		writeGauge(w, "ethparser_throttled_head_polls", "Head polls skipped because the lag exceeded the limit.", float64(lag.ThrottledHeadPolls))
+		writeGauge(w, "ethparser_prefetched_blocks", "Blocks processed from a download made ahead of the processing.", float64(lag.PrefetchedBlocks))
+		writeGauge(w, "ethparser_header_mismatches", "Fetched blocks whose recomputed header hash differed from the reported one.", float64(ethParser.HeaderMismatches()))
+
+		matching := ethParser.MatchingStats()
+		writeGauge(w, "ethparser_blocks_scanned", "Blocks examined by the filter stage.", float64(matching.BlocksScanned))
+		writeGauge(w, "ethparser_blocks_without_match", "Scanned blocks without any matched transaction.", float64(matching.EmptyBlocks))
+		writeGauge(w, "ethparser_transactions_examined", "Transactions of the scanned blocks.", float64(matching.TransactionsExamined))
+		writeGauge(w, "ethparser_transactions_matched", "Scanned transactions matched for at least one subscribed address.", float64(matching.TransactionsMatched))
+		writeGauge(w, "ethparser_matcher_false_positives", "Addresses proposed by the matcher that weren't subscribed.", float64(matching.FalsePositives))
+		writeGauge(w, "ethparser_downloaded_bytes", "Size of the scanned blocks as returned by the node.", float64(matching.BytesDownloaded))
+		writeGauge(w, "ethparser_downloaded_bytes_per_match", "Downloaded bytes per matched transaction.", matching.BytesPerMatch())
+
+		throttle := ethParser.ThrottleState()
+		throttled := 0.0
+		if throttle.Throttled {
+			throttled = 1
+		}
+		writeGauge(w, "ethparser_rpc_throttled", "1 while the node requests are paused after a rate limit, 0 otherwise.", throttled)
+		writeGauge(w, "ethparser_rpc_rate_limited", "Node requests rejected by a rate limit.", float64(throttle.RateLimited))
+		writeGauge(w, "ethparser_rpc_deferred_blocks", "Blocks rescheduled to a later cycle because of a rate limit.", float64(throttle.DeferredBlocks))
+
+		writeGauge(w, "ethparser_provider_switches", "Switches of the node provider after a stalled head.", float64(ethParser.ProviderStats().Switches))
+
+		proxy := ethParser.ProxyStats()
+		writeGauge(w, "ethparser_rpc_proxy_requests", "JSON-RPC requests received by POST /rpc.", float64(proxy.Requests))
+		writeGauge(w, "ethparser_rpc_proxy_rejected", "Proxy requests for methods outside the whitelist.", float64(proxy.Rejected))
+		writeGauge(w, "ethparser_rpc_proxy_fallbacks", "Proxy requests answered by the fallback node.", float64(proxy.Fallbacks))
+		writeGauge(w, "ethparser_rpc_proxy_errors", "Proxy requests neither node answered.", float64(proxy.Errors))
+
+		recovery := ethParser.Recovery()
+		active := 0.0
+		if recovery.Active {
+			active = 1
+		}
+		writeGauge(w, "ethparser_recovery_active", "Whether the startup recovery is catching up from the checkpoint.", active)
+		writeGauge(w, "ethparser_recovery_processed_blocks", "Blocks caught up by the startup recovery.", float64(recovery.ProcessedBlocks))
+		writeGauge(w, "ethparser_recovery_total_blocks", "Blocks between the checkpoint and the head seen at startup.", float64(recovery.TotalBlocks))
+	}
+}
+
+// readinessHandler replies 503 with the reasons of the components down, e.g. the startup recovery catching up,
+// see EthParser.Health. The reasons of the degraded components follow ready.
+func readinessHandler(ethParser *parser.EthParser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := ethParser.Health().Check()
+		switch {
+		case !report.Ready():
+			http.Error(w, report.Reasons(), http.StatusServiceUnavailable)
+		case report.Status == parser.HealthDegraded:
+			fmt.Fprintf(w, "ready, degraded: %s\n", report.Reasons())
+		default:
+			w.Write([]byte("ready\n"))
+		}
+	}
+}
+
+// healthHandler replies the health of every component as JSON, with a 503 when one is down
+func healthHandler(ethParser *parser.EthParser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := ethParser.Health().Check()
+		w.Header().Set("Content-Type", "application/json")
+		if !report.Ready() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	}
+}
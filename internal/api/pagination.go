@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"eth-parser/internal/parser"
+)
+
+// Bounds of the limit query parameter of the paginated lists
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// pageRequest is the pagination requested with the cursor and limit query parameters
+type pageRequest struct {
+	after *parser.TransactionCursor
+	limit int
+}
+
+// parsePage reads the cursor and limit query parameters of the list of an address, see
+// parser.TransactionCursor.Address, replying 400 when they're invalid. It's nil when the request doesn't
+// paginate, in which case the whole list is returned.
+func parsePage(w http.ResponseWriter, r *http.Request, address string) (*pageRequest, bool) {
+	query := r.URL.Query()
+	token, value := query.Get("cursor"), query.Get("limit")
+	if token == "" && value == "" {
+		return nil, true
+	}
+	page := &pageRequest{limit: defaultPageLimit}
+	if token != "" {
+		after, err := parser.DecodeTransactionCursor(token, address)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return nil, false
+		}
+		page.after = &after
+	}
+	if value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > maxPageLimit {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return nil, false
+		}
+		page.limit = parsed
+	}
+	return page, true
+}
+
+// setNextCursor sets the X-Next-Cursor header, the token resuming the list after the returned page
+func setNextCursor(w http.ResponseWriter, next parser.TransactionCursor) {
+	w.Header().Set("X-Next-Cursor", next.Encode())
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"eth-parser/internal/parser"
+)
+
+// txFilters are the filters of the transaction lists, read from the query parameters or from a request body
+type txFilters struct {
+	fromBlock, toBlock int
+	fromTime, toTime   string // RFC 3339
+	direction          string
+	minValue           string // wei, decimal or 0x prefixed hex
+	category           string
+	order              string
+}
+
+// urlTxFilters reads the filters from the query parameters, replying 400 when a block number isn't an integer
+func urlTxFilters(w http.ResponseWriter, r *http.Request) (txFilters, bool) {
+	values := r.URL.Query()
+	filters := txFilters{
+		fromTime:  values.Get("fromTime"),
+		toTime:    values.Get("toTime"),
+		direction: values.Get("direction"),
+		minValue:  values.Get("minValue"),
+		category:  values.Get("category"),
+		order:     values.Get("order"),
+	}
+	for name, block := range map[string]*int{"fromBlock": &filters.fromBlock, "toBlock": &filters.toBlock} {
+		if value := values.Get(name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, "Invalid block range", http.StatusBadRequest)
+				return txFilters{}, false
+			}
+			*block = parsed
+		}
+	}
+	return filters, true
+}
+
+// txQuery returns the query of the addresses with the filters and the page, nil for the whole lists, replying
+// 400 when it's invalid
+func txQuery(w http.ResponseWriter, addresses []string, filters txFilters, page *pageRequest) (parser.TxQuery, bool) {
+	query := parser.TxQuery{
+		Addresses: addresses,
+		FromBlock: filters.fromBlock,
+		ToBlock:   filters.toBlock,
+		Direction: filters.direction,
+		Category:  filters.category,
+		Order:     filters.order,
+	}
+	if page != nil {
+		query.After, query.Limit = page.after, page.limit
+	}
+	var err error
+	for _, bound := range []struct {
+		value  string
+		parsed *time.Time
+	}{{filters.fromTime, &query.FromTime}, {filters.toTime, &query.ToTime}} {
+		if bound.value == "" {
+			continue
+		}
+		if *bound.parsed, err = time.Parse(time.RFC3339, bound.value); err != nil {
+			http.Error(w, "Invalid time range", http.StatusBadRequest)
+			return parser.TxQuery{}, false
+		}
+	}
+	if filters.minValue != "" {
+		minValue, ok := new(big.Int).SetString(filters.minValue, 0)
+		if !ok {
+			http.Error(w, "Invalid min value", http.StatusBadRequest)
+			return parser.TxQuery{}, false
+		}
+		query.MinValue = minValue
+	}
+	if err := query.Validate(); err != nil {
+		// "invalid query: category" is replied as "Invalid category"
+		http.Error(w, "Invalid "+strings.TrimPrefix(err.Error(), parser.ErrInvalidQuery.Error()+": "), http.StatusBadRequest)
+		return parser.TxQuery{}, false
+	}
+	return query, true
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"eth-parser/internal/parser"
+)
+
+// ListReports returns the generated reports, of an address or entity when given
+func (s *apiServer) ListReports(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	address := r.URL.Query().Get("address")
+	entity := r.URL.Query().Get("entity")
+	reports := []parser.Report{}
+	for _, report := range p.Reports() {
+		if address != "" && !strings.EqualFold(report.Address, address) {
+			continue
+		}
+		if entity != "" && report.Entity != entity {
+			continue
+		}
+		reports = append(reports, report)
+	}
+	json.NewEncoder(w).Encode(reports)
+}
+
+// GetReport returns a generated report as an attachment
+func (s *apiServer) GetReport(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
+		return
+	}
+	report, found := p.GetReport(id)
+	if !found {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="report-%d.json"`, report.ID))
+	json.NewEncoder(w).Encode(report)
+}
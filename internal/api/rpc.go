@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"eth-parser/internal/parser"
+)
+
+// JSON-RPC 2.0 error codes of the proxy endpoint
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInternalError  = -32603
+)
+
+// RPCRequest is a JSON-RPC 2.0 request of the proxy endpoint, its ID is echoed unchanged
+type RPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  []interface{}   `json:"params"`
+}
+
+// RPCResponse is a JSON-RPC 2.0 response of the proxy endpoint, with either a result or an error
+type RPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   interface{}     `json:"error,omitempty"`
+}
+
+// RPCError is a JSON-RPC 2.0 error object
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// checkRPCAccess authenticates a proxy request with the API key of a tenant or with the admin key. The
+// endpoint isn't served when neither multi-tenancy nor the admin key is configured.
+func (s *apiServer) checkRPCAccess(w http.ResponseWriter, r *http.Request) bool {
+	if s.tenants == nil && s.adminKey == "" {
+		http.NotFound(w, r)
+		return false
+	}
+	if s.adminKey != "" && validAdminKey(r, s.adminKey) {
+		return true
+	}
+	if s.tenants != nil {
+		if _, ok := s.tenants.TenantForAPIKey(r.Header.Get("X-API-Key")); ok {
+			return true
+		}
+	}
+	http.Error(w, "Missing or invalid API key", http.StatusUnauthorized)
+	return false
+}
+
+// writeRPCError replies a JSON-RPC error
+func writeRPCError(w http.ResponseWriter, status int, id json.RawMessage, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(RPCResponse{JSONRPC: "2.0", ID: rpcID(id), Error: RPCError{Code: code, Message: message}})
+}
+
+// rpcID returns the ID of a response, null when the request had none
+func rpcID(id json.RawMessage) json.RawMessage {
+	if len(id) == 0 {
+		return json.RawMessage("null")
+	}
+	return id
+}
+
+// ProxyRPC forwards a whitelisted JSON-RPC request to the node of the parser
+func (s *apiServer) ProxyRPC(w http.ResponseWriter, r *http.Request) {
+	if !s.checkRPCAccess(w, r) {
+		return
+	}
+	var request RPCRequest
+	decoder := json.NewDecoder(r.Body)
+	// Numeric parameters are forwarded as sent
+	decoder.UseNumber()
+	if err := decoder.Decode(&request); err != nil {
+		writeRPCError(w, http.StatusBadRequest, nil, rpcParseError, "Parse error, batch requests aren't supported")
+		return
+	}
+	if request.JSONRPC != "2.0" || request.Method == "" {
+		writeRPCError(w, http.StatusBadRequest, request.ID, rpcInvalidRequest, "Invalid request")
+		return
+	}
+
+	resp, err := s.ethParser.ProxyRequest(parser.JSONRPCRequest{JSONRPC: "2.0", Method: request.Method, Params: request.Params, ID: 1})
+	if errors.Is(err, parser.ErrMethodNotAllowed) {
+		writeRPCError(w, http.StatusForbidden, request.ID, rpcMethodNotFound, "Method not allowed: "+request.Method)
+		return
+	}
+	if err != nil {
+		log.Printf("Error proxying %s: %v\n", request.Method, err)
+		writeRPCError(w, http.StatusBadGateway, request.ID, rpcInternalError, "Node unavailable")
+		return
+	}
+	response := RPCResponse{JSONRPC: "2.0", ID: rpcID(request.ID), Error: resp.Error}
+	if resp.Error == nil {
+		if response.Result, err = json.Marshal(resp.Result); err != nil {
+			writeRPCError(w, http.StatusBadGateway, request.ID, rpcInternalError, "Invalid node response")
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"eth-parser/internal/parser"
+)
+
+// defaultShareLinkExpiry is the validity of a share link created without expiresIn
+const defaultShareLinkExpiry = 24 * time.Hour
+
+// WithShareLinks enables the signed read-only links of POST /subscriptions/{address}/share, built on baseURL,
+// the public URL of the API
+func WithShareLinks(signer parser.ShareLinkSigner, baseURL string) Option {
+	return func(s *apiServer) {
+		s.shareSigner = &signer
+		s.shareBaseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// CreateShareLink signs a time-limited read-only link to the transactions of a subscribed address
+func (s *apiServer) CreateShareLink(w http.ResponseWriter, r *http.Request) {
+	if s.shareSigner == nil {
+		http.NotFound(w, r)
+		return
+	}
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	address := r.PathValue("address")
+	if _, subscribed := p.GetSubscription(address); !subscribed {
+		http.Error(w, "Address not subscribed", http.StatusNotFound)
+		return
+	}
+	var request ShareLinkRequest
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+	expiry := defaultShareLinkExpiry
+	if request.ExpiresIn != "" {
+		parsed, err := time.ParseDuration(request.ExpiresIn)
+		if err != nil || parsed <= 0 || parsed > parser.MaxShareLinkExpiry {
+			http.Error(w, "Invalid expiresIn", http.StatusBadRequest)
+			return
+		}
+		expiry = parsed
+	}
+
+	grant := parser.ShareGrant{Address: address, ExpiresAt: time.Now().Add(expiry).UTC().Truncate(time.Second)}
+	if tenant, isTenant := p.(*parser.TenantParser); isTenant {
+		grant.Tenant = tenant.TenantID()
+	}
+	token := s.shareSigner.Sign(grant)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ShareLink{
+		Address:   address,
+		ExpiresAt: grant.ExpiresAt.Format(time.RFC3339),
+		Token:     token,
+		Url:       s.shareBaseURL + "/shared/" + url.PathEscape(token) + "/transactions",
+	})
+}
+
+// sharedParser verifies the token of a share link and returns the parser of its grant, replying 403 for an
+// invalid or expired link and 404 once the address isn't subscribed anymore
+func (s *apiServer) sharedParser(w http.ResponseWriter, r *http.Request) (parser.Parser, string, bool) {
+	if s.shareSigner == nil {
+		http.NotFound(w, r)
+		return nil, "", false
+	}
+	grant, err := s.shareSigner.Verify(r.PathValue("token"), time.Now())
+	if err != nil {
+		http.Error(w, "Invalid or expired share link", http.StatusForbidden)
+		return nil, "", false
+	}
+	var p parser.Parser = s.parser
+	if grant.Tenant != "" {
+		if s.tenants == nil {
+			http.Error(w, "Invalid or expired share link", http.StatusForbidden)
+			return nil, "", false
+		}
+		p = s.tenants.View(grant.Tenant)
+	}
+	if _, subscribed := p.GetSubscription(grant.Address); !subscribed {
+		http.Error(w, "Address not subscribed", http.StatusNotFound)
+		return nil, "", false
+	}
+	return p, grant.Address, true
+}
+
+// GetSharedTransactions returns the transaction history of the address of a share link
+func (s *apiServer) GetSharedTransactions(w http.ResponseWriter, r *http.Request) {
+	p, address, ok := s.sharedParser(w, r)
+	if !ok {
+		return
+	}
+	filters, ok := urlTxFilters(w, r)
+	if !ok {
+		return
+	}
+	units, ok := requestUnits(w, r)
+	if !ok {
+		return
+	}
+	s.writeTransactions(w, r, p, address, filters, units)
+}
+
+// WaitForSharedTransactions long-polls the new transactions of the address of a share link
+func (s *apiServer) WaitForSharedTransactions(w http.ResponseWriter, r *http.Request) {
+	p, address, ok := s.sharedParser(w, r)
+	if !ok {
+		return
+	}
+	s.waitTransactions(w, r, p, address)
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"eth-parser/internal/parser"
+)
+
+const (
+	// ndjsonContentType is the media type of the newline delimited JSON responses, one transaction per line
+	ndjsonContentType = "application/x-ndjson"
+	// streamFlushInterval is the longest time the encoded transactions wait in the response buffer
+	streamFlushInterval = 100 * time.Millisecond
+)
+
+// acceptsNDJSON reports whether the client asked for newline delimited JSON, with the Accept header or
+// format=ndjson
+func acceptsNDJSON(r *http.Request) bool {
+	return accepts(r, "ndjson", ndjsonContentType)
+}
+
+// accepts reports whether the client asked for a media type, with the Accept header or the format parameter
+func accepts(r *http.Request, format string, contentType string) bool {
+	if r.URL.Query().Get("format") == format {
+		return true
+	}
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted)); err == nil && mediaType == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// streamTransactions writes the transactions of an address with their direction and the value fields of the
+// units as a JSON array, or as NDJSON when the client accepts it. The transactions are encoded one at a time
+// and flushed every streamFlushInterval, so that a long history isn't buffered and the client reads the first
+// ones right away.
+func (s *apiServer) streamTransactions(w http.ResponseWriter, r *http.Request, address string, transactions []parser.Transaction, units parser.Units) {
+	ndjson := acceptsNDJSON(r)
+	if ndjson {
+		w.Header().Set("Content-Type", ndjsonContentType)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	controller := http.NewResponseController(w)
+	flushed := time.Now()
+	separator := []byte(",")
+	if ndjson {
+		separator = []byte("\n")
+	} else if _, err := w.Write([]byte("[")); err != nil {
+		return
+	}
+	for i, tx := range transactions {
+		tx.Direction = parser.TransactionDirection(tx, address)
+		data, err := json.Marshal(tx.WithUnits(units).WithNumberEncoding(s.numberEncoding))
+		if err != nil {
+			return
+		}
+		if i > 0 {
+			w.Write(separator)
+		}
+		if _, err := w.Write(data); err != nil {
+			// The client is gone
+			return
+		}
+		if time.Since(flushed) >= streamFlushInterval {
+			controller.Flush()
+			flushed = time.Now()
+		}
+	}
+	if ndjson {
+		w.Write([]byte("\n"))
+	} else {
+		w.Write([]byte("]\n"))
+	}
+}
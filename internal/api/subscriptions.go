@@ -0,0 +1,259 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"time"
+
+	"eth-parser/internal/parser"
+)
+
+// subscriptionError returns why a subscription request is invalid, empty when it's valid
+func subscriptionError(subscription parser.Subscription) string {
+	if subscription.Address == "" {
+		return "Address field is required"
+	}
+	if subscription.Email != nil && subscription.Email.Digest != "" &&
+		subscription.Email.Digest != parser.DigestHourly && subscription.Email.Digest != parser.DigestDaily {
+		return "Email digest must be hourly or daily"
+	}
+	if subscription.Email != nil {
+		if err := parser.ValidateEmailRecipients(subscription.Email.Recipients); err != nil {
+			return "Email recipients are invalid: " + err.Error()
+		}
+	}
+	if !parser.ValidPriority(subscription.Priority) {
+		return "Priority must be high, normal or low"
+	}
+	if subscription.StartBlock < 0 {
+		return "Start block must not be negative"
+	}
+	if subscription.Inactivity != nil {
+		if _, err := subscription.Inactivity.Period(); err != nil {
+			return "Inactivity after must be a positive duration"
+		}
+	}
+	if parser.ValidateSubscriptionMetadata(subscription.Metadata) != nil {
+		return fmt.Sprintf("Metadata must be a JSON value of at most %d bytes", parser.MaxSubscriptionMetadataSize)
+	}
+	return ""
+}
+
+// subscriptionFormat reads the file format from the format query parameter, else from the Content-Type,
+// replying 400 when it's invalid
+func subscriptionFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = parser.SubscriptionFormatJSON
+		if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && mediaType == "text/csv" {
+			format = parser.SubscriptionFormatCSV
+		}
+	}
+	if format != parser.SubscriptionFormatJSON && format != parser.SubscriptionFormatCSV {
+		http.Error(w, "Format must be json or csv", http.StatusBadRequest)
+		return "", false
+	}
+	return format, true
+}
+
+// ImportSubscriptions subscribes to the addresses of a JSON or CSV file. Rows already subscribed are skipped
+// and invalid rows are reported, the others are imported anyway. The new subscriptions with a start block are
+// backfilled with a rescan of the processed blocks from it.
+func (s *apiServer) ImportSubscriptions(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	format, ok := subscriptionFormat(w, r)
+	if !ok {
+		return
+	}
+	subscriptions, err := parser.ImportSubscriptions(r.Body, format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenant, isTenant := p.(*parser.TenantParser)
+	response := ImportSubscriptionsResponse{Errors: []SubscriptionImportError{}}
+	rows := make(map[string]int)
+	backfills := make(map[int][]string)
+	for i, subscription := range subscriptions {
+		row := i + 1
+		if message := subscriptionError(subscription); message != "" {
+			response.Errors = append(response.Errors, SubscriptionImportError{Row: row, Address: subscription.Address, Error: message})
+			continue
+		}
+		var subscribed bool
+		if isTenant {
+			subscribed, err = tenant.TrySubscribe(subscription)
+			if err == parser.ErrSubscriptionQuotaExceeded {
+				response.Errors = append(response.Errors, SubscriptionImportError{Row: row, Address: subscription.Address, Error: "Subscription quota exceeded"})
+				continue
+			}
+		} else {
+			subscription.Tenant = ""
+			subscribed = p.SubscribeWith(subscription)
+		}
+		if !subscribed {
+			response.Skipped++
+			continue
+		}
+		response.Imported++
+		s.recordAudit(p, r, parser.AuditEntry{Action: parser.AuditSubscribe, Address: subscription.Address, After: subscriptionState(p, subscription.Address)})
+		if subscription.StartBlock > 0 {
+			rows[subscription.Address] = row
+			backfills[subscription.StartBlock] = append(backfills[subscription.StartBlock], subscription.Address)
+		}
+	}
+
+	// One rescan per start block, the addresses sharing it are matched together
+	processed := s.ethParser.BackpressureStats().LastProcessedBlock
+	startBlocks := make([]int, 0, len(backfills))
+	for startBlock := range backfills {
+		startBlocks = append(startBlocks, startBlock)
+	}
+	sort.Ints(startBlocks)
+	for _, startBlock := range startBlocks {
+		if startBlock > processed {
+			// The live processing reaches it
+			continue
+		}
+		addresses := backfills[startBlock]
+		_, err := s.ethParser.Backfill(parser.RescanRequest{FromBlock: startBlock, ToBlock: processed, Addresses: addresses})
+		if err != nil {
+			log.Printf("Error backfilling blocks %d to %d: %v\n", startBlock, processed, err)
+			for _, address := range addresses {
+				response.Errors = append(response.Errors, SubscriptionImportError{Row: rows[address], Address: address, Error: "Subscribed, but the backfill failed: " + err.Error()})
+			}
+		}
+	}
+	sort.SliceStable(response.Errors, func(i, j int) bool { return response.Errors[i].Row < response.Errors[j].Row })
+	json.NewEncoder(w).Encode(response)
+}
+
+// ExportSubscriptions downloads the subscriptions as a JSON or CSV file
+func (s *apiServer) ExportSubscriptions(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	format, ok := subscriptionFormat(w, r)
+	if !ok {
+		return
+	}
+	if format == parser.SubscriptionFormatCSV {
+		w.Header().Set("Content-Type", "text/csv")
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="subscriptions.`+format+`"`)
+	if err := parser.ExportSubscriptions(w, p.Subscriptions(), format); err != nil {
+		log.Println("Error exporting the subscriptions:", err)
+	}
+}
+
+// UpdateSubscription replaces the notification settings of a subscribed address
+func (s *apiServer) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	var request parser.Subscription
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+	request.Address = r.PathValue("address")
+	if message := subscriptionError(request); message != "" {
+		http.Error(w, message, http.StatusBadRequest)
+		return
+	}
+	before := subscriptionState(p, request.Address)
+	success := p.UpdateSubscription(request)
+	if success {
+		s.recordAudit(p, r, parser.AuditEntry{Action: parser.AuditUpdateSubscription, Address: request.Address,
+			Before: before, After: subscriptionState(p, request.Address)})
+	}
+	json.NewEncoder(w).Encode(SuccessResponse{Success: success})
+}
+
+// Unsubscribe soft-deletes the subscription of an address, its stored transactions stay queryable
+func (s *apiServer) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	address := r.PathValue("address")
+	before := subscriptionState(p, address)
+	subscription, ok := p.Unsubscribe(address)
+	if !ok {
+		http.Error(w, "Address not subscribed", http.StatusNotFound)
+		return
+	}
+	s.recordAudit(p, r, parser.AuditEntry{Action: parser.AuditUnsubscribe, Address: address, Before: before})
+	json.NewEncoder(w).Encode(subscription)
+}
+
+// SendTestNotification delivers a synthetic transaction through the notifications of a subscribed address
+func (s *apiServer) SendTestNotification(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	tx, err := p.SendTestNotification(r.PathValue("address"))
+	if errors.Is(err, parser.ErrNotSubscribed) {
+		http.Error(w, "Address not subscribed", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Test notification failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	json.NewEncoder(w).Encode(tx.WithNumberEncoding(s.numberEncoding))
+}
+
+// MuteSubscription pauses the notifications of a subscribed address, for the duration query parameter or until
+// it's unmuted
+func (s *apiServer) MuteSubscription(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	var duration time.Duration
+	if value := r.URL.Query().Get("duration"); value != "" {
+		var err error
+		if duration, err = time.ParseDuration(value); err != nil || duration <= 0 {
+			http.Error(w, "Duration must be a positive duration, e.g. 2h", http.StatusBadRequest)
+			return
+		}
+	}
+	address := r.PathValue("address")
+	before := subscriptionState(p, address)
+	subscription, ok := p.Mute(address, duration)
+	if !ok {
+		http.Error(w, "Address not subscribed", http.StatusNotFound)
+		return
+	}
+	s.recordAudit(p, r, parser.AuditEntry{Action: parser.AuditMute, Address: address, Before: before, After: &subscription})
+	json.NewEncoder(w).Encode(subscription)
+}
+
+// UnmuteSubscription resumes the notifications of a muted address
+func (s *apiServer) UnmuteSubscription(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	address := r.PathValue("address")
+	before := subscriptionState(p, address)
+	subscription, ok := p.Unmute(address)
+	if !ok {
+		http.Error(w, "Address not subscribed", http.StatusNotFound)
+		return
+	}
+	s.recordAudit(p, r, parser.AuditEntry{Action: parser.AuditUnmute, Address: address, Before: before, After: &subscription})
+	json.NewEncoder(w).Encode(subscription)
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"eth-parser/internal/parser"
+)
+
+// parserFor returns the Parser serving a request: the whole parser when multi-tenancy is disabled,
+// the namespace of the tenant owning the X-API-Key header otherwise. It replies 401 when the key is
+// missing or unknown.
+func (s *apiServer) parserFor(w http.ResponseWriter, r *http.Request) (parser.Parser, bool) {
+	if s.tenants == nil {
+		return s.parser, true
+	}
+	tenantID, ok := s.tenants.TenantForAPIKey(r.Header.Get("X-API-Key"))
+	if !ok {
+		http.Error(w, "Missing or invalid API key", http.StatusUnauthorized)
+		return nil, false
+	}
+	return s.tenants.View(tenantID), true
+}
+
+// checkTenantAdmin replies 404 when multi-tenancy is disabled, then checks the admin key
+func (s *apiServer) checkTenantAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.tenants == nil {
+		http.NotFound(w, r)
+		return false
+	}
+	return s.checkAdmin(w, r)
+}
+
+// ListTenants returns the tenants with their subscription usage
+func (s *apiServer) ListTenants(w http.ResponseWriter, r *http.Request) {
+	if !s.checkTenantAdmin(w, r) {
+		return
+	}
+	json.NewEncoder(w).Encode(s.tenants.Tenants())
+}
+
+// CreateTenant creates a tenant and returns its API key
+func (s *apiServer) CreateTenant(w http.ResponseWriter, r *http.Request) {
+	if !s.checkTenantAdmin(w, r) {
+		return
+	}
+	var request CreateTenantRequest
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+	if request.Id == "" {
+		http.Error(w, "Id field is required", http.StatusBadRequest)
+		return
+	}
+	if request.MaxSubscriptions < 0 {
+		http.Error(w, "MaxSubscriptions must not be negative", http.StatusBadRequest)
+		return
+	}
+	apiKey, err := s.tenants.CreateTenant(request.Id, request.Name, request.MaxSubscriptions)
+	if err == parser.ErrInvalidTenantID {
+		http.Error(w, "Id must be 1 to 64 letters, digits, '_', '.' or '-'", http.StatusBadRequest)
+		return
+	}
+	if err == parser.ErrTenantExists {
+		http.Error(w, "Tenant already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Could not create the tenant", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(CreateTenantResponse{
+		Tenant: parser.Tenant{ID: request.Id, Name: request.Name, MaxSubscriptions: request.MaxSubscriptions},
+		ApiKey: apiKey,
+	})
+}
+
+// DeleteTenant deletes a tenant and revokes its API key
+func (s *apiServer) DeleteTenant(w http.ResponseWriter, r *http.Request) {
+	if !s.checkTenantAdmin(w, r) {
+		return
+	}
+	success := s.tenants.DeleteTenant(r.PathValue("id"))
+	json.NewEncoder(w).Encode(SuccessResponse{Success: success})
+}
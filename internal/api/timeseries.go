@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"eth-parser/internal/parser"
+)
+
+// GetTimeSeries returns the activity of an address bucketed by block time
+func (s *apiServer) GetTimeSeries(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	request := parser.TimeSeriesQuery{Metric: parser.MetricTransactionCount, Interval: time.Hour}
+	if value := query.Get("metric"); value != "" {
+		request.Metric = value
+	}
+	if value := query.Get("interval"); value != "" {
+		interval, err := parseInterval(value)
+		if err != nil {
+			http.Error(w, "Invalid interval", http.StatusBadRequest)
+			return
+		}
+		request.Interval = interval
+	}
+	for name, bound := range map[string]*time.Time{"from": &request.From, "to": &request.To} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return
+			}
+			*bound = parsed
+		}
+	}
+
+	address := strings.ToLower(r.PathValue("address"))
+	series, err := parser.ComputeTimeSeries(p.GetTransactions(address), address, request, s.numberEncoding)
+	if errors.Is(err, parser.ErrInvalidTimeSeries) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(series)
+}
+
+// parseInterval parses a Go duration, or a number of days such as 7d
+func parseInterval(value string) (time.Duration, error) {
+	if days, found := strings.CutSuffix(value, "d"); found {
+		count, err := strconv.Atoi(days)
+		if err != nil || count <= 0 {
+			return 0, errors.New("invalid number of days")
+		}
+		return time.Duration(count) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,309 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"eth-parser/internal/parser"
+)
+
+// maxValidatedBody caps the request bodies checked against their schema, larger ones are passed unchecked
+const maxValidatedBody = 1 << 20
+
+// specFormats are the checks of the string formats of openapi.json
+var specFormats = map[string]struct {
+	pattern *regexp.Regexp
+	message string
+}{
+	"address":  {regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`), "must be an address, 0x followed by 40 hex digits"},
+	"hash":     {regexp.MustCompile(`^0x[0-9a-fA-F]{64}$`), "must be a hash, 0x followed by 64 hex digits"},
+	"quantity": {regexp.MustCompile(`^([0-9]+|0x[0-9a-fA-F]+)$`), "must be a decimal or 0x prefixed hex quantity"},
+}
+
+// specSchema is a JSON Schema of openapi.json
+type specSchema = map[string]interface{}
+
+// specParameter is a parameter of an operation, or a reference to one of the components
+type specParameter struct {
+	Ref      string     `json:"$ref"`
+	Name     string     `json:"name"`
+	In       string     `json:"in"`
+	Required bool       `json:"required"`
+	Schema   specSchema `json:"schema"`
+}
+
+// specOperation is the part of an operation of openapi.json describing its request
+type specOperation struct {
+	SkipValidation bool            `json:"x-skip-validation"`
+	Parameters     []specParameter `json:"parameters"`
+	RequestBody    struct {
+		Content map[string]struct {
+			Schema specSchema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+// validationMiddleware checks the parameters and the JSON body of the requests against the schemas of their
+// operation in openapi.json, replying 422 with every violation instead of running the handler
+type validationMiddleware struct {
+	mux        *http.ServeMux
+	schemas    map[string]specSchema
+	parameters map[string]specParameter
+}
+
+// NewValidationMiddleware wraps next with the validation of the requests against openapi.json. The routes it
+// doesn't describe and the operations flagged x-skip-validation, which check their payload themselves, are
+// passed unchecked, as are the request bodies that aren't valid JSON, left to the handlers to reject.
+func NewValidationMiddleware(next http.Handler) http.Handler {
+	var spec struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Parameters map[string]specParameter `json:"parameters"`
+			Schemas    map[string]specSchema    `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		panic(fmt.Sprintf("invalid openapi.json: %v", err))
+	}
+	m := &validationMiddleware{mux: http.NewServeMux(), schemas: spec.Components.Schemas, parameters: spec.Components.Parameters}
+	for path, item := range spec.Paths {
+		for method, raw := range item {
+			var op specOperation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				panic(fmt.Sprintf("invalid operation %s %s of openapi.json: %v", method, path, err))
+			}
+			if op.SkipValidation {
+				continue
+			}
+			m.mux.Handle(strings.ToUpper(method)+" "+path, m.validated(op, next))
+		}
+	}
+	m.mux.Handle("/", next)
+	return m
+}
+
+// ServeHTTP routes the request to the validation of its operation
+func (m *validationMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mux.ServeHTTP(w, r)
+}
+
+// validated runs next once the request matches the schemas of op
+func (m *validationMiddleware) validated(op specOperation, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var violations []Violation
+		query := r.URL.Query()
+		for _, parameter := range op.Parameters {
+			if parameter.Ref != "" {
+				parameter = m.parameters[strings.TrimPrefix(parameter.Ref, "#/components/parameters/")]
+			}
+			var value string
+			switch parameter.In {
+			case "path":
+				value = r.PathValue(parameter.Name)
+			case "query":
+				value = query.Get(parameter.Name)
+			case "header":
+				value = r.Header.Get(parameter.Name)
+			}
+			if value == "" {
+				if parameter.Required {
+					violations = append(violations, Violation{In: parameter.In, Field: parameter.Name, Message: "is required"})
+				}
+				continue
+			}
+			violations = m.check(parameter.Schema, parameterValue(parameter.Schema, value), parameter.In, parameter.Name, violations)
+		}
+
+		if content, ok := op.RequestBody.Content["application/json"]; ok && r.Body != nil {
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxValidatedBody+1))
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+			var value interface{}
+			decoder := json.NewDecoder(bytes.NewReader(body))
+			decoder.UseNumber()
+			if err == nil && len(body) <= maxValidatedBody && decoder.Decode(&value) == nil {
+				violations = m.check(content.Schema, value, "body", "", violations)
+			}
+		}
+
+		if len(violations) > 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(ValidationError{Error: "Request validation failed", Violations: violations})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// parameterValue converts the value of a parameter to the JSON value of its schema type, keeping it as a
+// string when it doesn't convert so the type check reports it
+func parameterValue(schema specSchema, value string) interface{} {
+	switch schema["type"] {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return json.Number(value)
+		}
+	case "boolean":
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return value
+}
+
+// check appends the violations of value against schema to violations, field locating value in the request.
+// Null values are treated as absent.
+func (m *validationMiddleware) check(schema specSchema, value interface{}, in string, field string, violations []Violation) []Violation {
+	if ref, ok := schema["$ref"].(string); ok {
+		schema = m.schemas[strings.TrimPrefix(ref, "#/components/schemas/")]
+	}
+	if value == nil || schema == nil {
+		return violations
+	}
+	violation := func(format string, args ...interface{}) {
+		violations = append(violations, Violation{In: in, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, part := range allOf {
+			if part, ok := part.(specSchema); ok {
+				violations = m.check(part, value, in, field, violations)
+			}
+		}
+	}
+
+	if kind, ok := schema["type"].(string); ok && !hasType(value, kind) {
+		violation("must be %s %s", article(kind), kind)
+		return violations
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		options := make([]string, len(enum))
+		for i, option := range enum {
+			options[i] = fmt.Sprint(option)
+			found = found || options[i] == fmt.Sprint(value)
+		}
+		if !found {
+			violation("must be one of %s", strings.Join(options, ", "))
+		}
+	}
+
+	switch value := value.(type) {
+	case string:
+		if minLength, ok := schema["minLength"].(float64); ok && len(value) < int(minLength) {
+			violation("must have at least %d characters", int(minLength))
+		}
+		if maxLength, ok := schema["maxLength"].(float64); ok && len(value) > int(maxLength) {
+			violation("must have at most %d characters", int(maxLength))
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if matched, err := regexp.MatchString(pattern, value); err == nil && !matched {
+				violation("must match %s", pattern)
+			}
+		}
+		if format, ok := specFormats[fmt.Sprint(schema["format"])]; ok && !format.pattern.MatchString(value) {
+			violation("%s", format.message)
+		} else if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, value); err != nil {
+				violation("must be an RFC 3339 time")
+			}
+		} else if schema["format"] == "email" {
+			if err := parser.ValidateEmailRecipients([]string{value}); err != nil {
+				violation("must be an email address on a single line")
+			}
+		}
+	case json.Number:
+		number, _ := value.Float64()
+		if minimum, ok := schema["minimum"].(float64); ok && number < minimum {
+			violation("must be at least %v", minimum)
+		}
+		if maximum, ok := schema["maximum"].(float64); ok && number > maximum {
+			violation("must be at most %v", maximum)
+		}
+	case []interface{}:
+		if minItems, ok := schema["minItems"].(float64); ok && len(value) < int(minItems) {
+			violation("must have at least %d items", int(minItems))
+		}
+		if maxItems, ok := schema["maxItems"].(float64); ok && len(value) > int(maxItems) {
+			violation("must have at most %d items", int(maxItems))
+		}
+		if items, ok := schema["items"].(specSchema); ok {
+			for i, item := range value {
+				violations = m.check(items, item, in, fmt.Sprintf("%s[%d]", field, i), violations)
+			}
+		}
+	case map[string]interface{}:
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				if name, _ := name.(string); value[name] == nil {
+					violations = append(violations, Violation{In: in, Field: fieldPath(field, name), Message: "is required"})
+				}
+			}
+		}
+		properties, _ := schema["properties"].(specSchema)
+		additional, _ := schema["additionalProperties"].(specSchema)
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		// The unknown properties are ignored like the JSON decoding of the handlers does
+		for _, name := range names {
+			if property, ok := properties[name].(specSchema); ok {
+				if property["readOnly"] != true {
+					violations = m.check(property, value[name], in, fieldPath(field, name), violations)
+				}
+			} else if additional != nil {
+				violations = m.check(additional, value[name], in, fieldPath(field, name), violations)
+			}
+		}
+	}
+	return violations
+}
+
+// hasType tells whether a decoded JSON value is of a JSON Schema type
+func hasType(value interface{}, kind string) bool {
+	switch value := value.(type) {
+	case string:
+		return kind == "string"
+	case bool:
+		return kind == "boolean"
+	case json.Number:
+		if kind == "integer" {
+			_, err := strconv.ParseInt(value.String(), 10, 64)
+			return err == nil
+		}
+		return kind == "number"
+	case []interface{}:
+		return kind == "array"
+	case map[string]interface{}:
+		return kind == "object"
+	}
+	return false
+}
+
+// article is the indefinite article of a JSON Schema type in the violation messages
+func article(kind string) string {
+	if kind == "integer" || kind == "object" || kind == "array" {
+		return "an"
+	}
+	return "a"
+}
+
+// fieldPath is the path of the property name of the field
+func fieldPath(field string, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
@@ -0,0 +1,75 @@
+package api_test
+
+import (
+	"encoding/json"
+	"eth-parser/internal/api"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+const validAddress = "0x00000000219ab540356cbb839cbe05303d7705fa"
+
+func TestValidationMiddleware(t *testing.T) {
+	handler := api.NewValidationMiddleware(api.NewAPIHandler(newParser()))
+
+	violations := func(rec *httptest.ResponseRecorder, t *testing.T) []api.Violation {
+		t.Helper()
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("Expected 422, got %d: %s", rec.Code, rec.Body)
+		}
+		var body api.ValidationError
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("Invalid body: %v", err)
+		}
+		return body.Violations
+	}
+
+	// Every violation of the body is listed
+	rec := serve(handler, http.MethodPost, "/transactions/batch",
+		`{"addresses":["`+validAddress+`","0x12"],"direction":"sideways","fromBlock":-1,"minValue":"0xZZ"}`, nil)
+	want := []api.Violation{
+		{In: "body", Field: "addresses[1]", Message: "must be an address, 0x followed by 40 hex digits"},
+		{In: "body", Field: "direction", Message: "must be one of in, out, self"},
+		{In: "body", Field: "fromBlock", Message: "must be at least 0"},
+		{In: "body", Field: "minValue", Message: "must be a decimal or 0x prefixed hex quantity"},
+	}
+	if got := violations(rec, t); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	// The parameters are checked too, with their schema type
+	rec = serve(handler, http.MethodPost, "/transactions?limit=abc&order=up", `{"address":"`+validAddress+`"}`, nil)
+	want = []api.Violation{
+		{In: "query", Field: "order", Message: "must be one of asc, desc"},
+		{In: "query", Field: "limit", Message: "must be an integer"},
+	}
+	if got := violations(rec, t); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+	rec = serve(handler, http.MethodDelete, "/subscriptions/0x1", "", nil)
+	if got := violations(rec, t); len(got) != 1 || got[0].In != "path" || got[0].Field != "address" {
+		t.Errorf("Expected the path address to be rejected, got %+v", got)
+	}
+	rec = serve(handler, http.MethodPost, "/subscribe", `{}`, nil)
+	if got := violations(rec, t); len(got) != 1 || got[0].Field != "address" || got[0].Message != "is required" {
+		t.Errorf("Expected the missing address to be reported, got %+v", got)
+	}
+
+	// The valid requests reach the handlers, with their body
+	if rec := serve(handler, http.MethodPost, "/subscribe", `{"address":"`+validAddress+`"}`, nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(handler, http.MethodGet, "/current_block", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// The invalid JSON is left to the handlers, and the operations skipping the validation aren't checked
+	if rec := serve(handler, http.MethodPost, "/subscribe", `{"address":`, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(handler, http.MethodPost, "/subscriptions/import", `[{"address":"0x1"}]`, nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"eth-parser/internal/parser"
+)
+
+// maxWatchConfirmations bounds the confirmation target of a watched transaction
+const maxWatchConfirmations = 1000
+
+// WatchTransaction starts the confirmation tracking of a transaction by hash
+func (s *apiServer) WatchTransaction(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	var request parser.WatchRequest
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+	request.Hash = strings.ToLower(request.Hash)
+	if !isTransactionHash(request.Hash) {
+		http.Error(w, "Invalid transaction hash", http.StatusBadRequest)
+		return
+	}
+	if request.Confirmations < 0 || request.Confirmations > maxWatchConfirmations {
+		http.Error(w, "Invalid confirmations", http.StatusBadRequest)
+		return
+	}
+	watch, err := p.WatchTransaction(request)
+	if errors.Is(err, parser.ErrTooManyWatches) {
+		http.Error(w, "Too many watched transactions", http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Invalid dropAfter", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(watch)
+}
+
+// GetWatchedTransaction returns the status of a watched transaction
+func (s *apiServer) GetWatchedTransaction(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.parserFor(w, r)
+	if !ok {
+		return
+	}
+	hash := strings.ToLower(r.PathValue("hash"))
+	if !isTransactionHash(hash) {
+		http.Error(w, "Invalid transaction hash", http.StatusBadRequest)
+		return
+	}
+	watch, found := p.WatchedTransaction(hash)
+	if !found {
+		http.Error(w, "Transaction not watched", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(watch)
+}
@@ -0,0 +1,447 @@
+package parser
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ABIArgument is an input or an output of a contract method
+type ABIArgument struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// ABIMethod is a function of a contract ABI
+type ABIMethod struct {
+	Name            string        `json:"name"`
+	Inputs          []ABIArgument `json:"inputs"`
+	Outputs         []ABIArgument `json:"outputs"`
+	StateMutability string        `json:"stateMutability,omitempty"`
+}
+
+// ContractABI is the set of functions of a contract JSON ABI, the events and errors are ignored
+type ContractABI struct {
+	methods map[string][]ABIMethod // name -> overloads
+}
+
+// ParseABI parses a contract JSON ABI, as emitted by solc. Tuple types aren't supported.
+func ParseABI(data []byte) (*ContractABI, error) {
+	var entries []struct {
+		ABIMethod
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("decoding ABI: %w", err)
+	}
+	abi := &ContractABI{methods: make(map[string][]ABIMethod)}
+	for _, entry := range entries {
+		if entry.Type != "function" {
+			continue
+		}
+		for _, argument := range append(entry.Inputs, entry.Outputs...) {
+			if _, err := parseABIType(argument.Type); err != nil {
+				return nil, fmt.Errorf("method %s: %w", entry.Name, err)
+			}
+		}
+		abi.methods[entry.Name] = append(abi.methods[entry.Name], entry.ABIMethod)
+	}
+	return abi, nil
+}
+
+// Method returns the method called with the given name and number of arguments, name can also be the full
+// signature, e.g. balanceOf(address), to pick an overload
+func (a *ContractABI) Method(name string, arguments int) (ABIMethod, error) {
+	if open := strings.Index(name, "("); open > 0 {
+		for _, method := range a.methods[name[:open]] {
+			if method.Signature() == name {
+				return method, nil
+			}
+		}
+		return ABIMethod{}, fmt.Errorf("unknown method %s", name)
+	}
+	var candidates []ABIMethod
+	for _, method := range a.methods[name] {
+		if len(method.Inputs) == arguments {
+			candidates = append(candidates, method)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return ABIMethod{}, fmt.Errorf("unknown method %s with %d arguments", name, arguments)
+	case 1:
+		return candidates[0], nil
+	default:
+		return ABIMethod{}, fmt.Errorf("method %s is overloaded, call it by signature", name)
+	}
+}
+
+// Signature returns the canonical signature of the method, e.g. transfer(address,uint256)
+func (m ABIMethod) Signature() string {
+	types := make([]string, len(m.Inputs))
+	for i, input := range m.Inputs {
+		types[i] = input.Type
+	}
+	return m.Name + "(" + strings.Join(types, ",") + ")"
+}
+
+// Selector returns the 4-byte function selector of the method as 0x-prefixed hex
+func (m ABIMethod) Selector() string {
+	return "0x" + hex.EncodeToString(keccak256([]byte(m.Signature()))[:4])
+}
+
+// EncodeCall returns the call data of the method with the given arguments: strings or numbers for the
+// integers, hex strings for the addresses and bytes, and arrays for the array types
+func (m ABIMethod) EncodeCall(arguments []interface{}) (string, error) {
+	if len(arguments) != len(m.Inputs) {
+		return "", fmt.Errorf("%s expects %d arguments, got %d", m.Signature(), len(m.Inputs), len(arguments))
+	}
+	types, err := parseABITypes(m.Inputs)
+	if err != nil {
+		return "", err
+	}
+	encoded, err := encodeABITuple(types, arguments)
+	if err != nil {
+		return "", err
+	}
+	return m.Selector() + hex.EncodeToString(encoded), nil
+}
+
+// DecodeOutputs decodes the result of a call of the method. The integers are returned as decimal strings,
+// the addresses and bytes as hex strings.
+func (m ABIMethod) DecodeOutputs(result string) ([]interface{}, error) {
+	data, err := hex.DecodeString(trimHexPrefix(result))
+	if err != nil {
+		return nil, fmt.Errorf("invalid result %q", result)
+	}
+	types, err := parseABITypes(m.Outputs)
+	if err != nil {
+		return nil, err
+	}
+	return decodeABITuple(types, data)
+}
+
+// abiType is a parsed ABI type
+type abiType struct {
+	kind string // uint, int, address, bool, fixedBytes, bytes, string, array or slice
+	// size is the bit size of the integers, the byte size of the fixed bytes and the length of the arrays
+	size int
+	elem *abiType
+}
+
+// parseABITypes parses the types of the arguments
+func parseABITypes(arguments []ABIArgument) ([]abiType, error) {
+	types := make([]abiType, len(arguments))
+	for i, argument := range arguments {
+		t, err := parseABIType(argument.Type)
+		if err != nil {
+			return nil, err
+		}
+		types[i] = t
+	}
+	return types, nil
+}
+
+// parseABIType parses an ABI type such as uint256, bytes32 or address[]
+func parseABIType(s string) (abiType, error) {
+	if strings.HasSuffix(s, "]") {
+		open := strings.LastIndex(s, "[")
+		if open <= 0 {
+			return abiType{}, fmt.Errorf("invalid ABI type %q", s)
+		}
+		elem, err := parseABIType(s[:open])
+		if err != nil {
+			return abiType{}, err
+		}
+		if open == len(s)-2 {
+			return abiType{kind: "slice", elem: &elem}, nil
+		}
+		length, err := strconv.Atoi(s[open+1 : len(s)-1])
+		if err != nil || length <= 0 {
+			return abiType{}, fmt.Errorf("invalid ABI array length in %q", s)
+		}
+		return abiType{kind: "array", size: length, elem: &elem}, nil
+	}
+
+	switch s {
+	case "address", "bool", "string", "bytes":
+		return abiType{kind: s}, nil
+	case "uint", "int":
+		return abiType{kind: s, size: 256}, nil
+	}
+	for _, kind := range []string{"uint", "int", "bytes"} {
+		if !strings.HasPrefix(s, kind) {
+			continue
+		}
+		size, err := strconv.Atoi(s[len(kind):])
+		if err != nil {
+			break
+		}
+		if kind == "bytes" {
+			if size < 1 || size > 32 {
+				break
+			}
+			return abiType{kind: "fixedBytes", size: size}, nil
+		}
+		if size < 8 || size > 256 || size%8 != 0 {
+			break
+		}
+		return abiType{kind: kind, size: size}, nil
+	}
+	return abiType{}, fmt.Errorf("unsupported ABI type %q", s)
+}
+
+// dynamic reports whether the values of the type are encoded in the tail, after an offset
+func (t abiType) dynamic() bool {
+	switch t.kind {
+	case "bytes", "string", "slice":
+		return true
+	case "array":
+		return t.elem.dynamic()
+	}
+	return false
+}
+
+// headSize returns the size in the head of a value of the type
+func (t abiType) headSize() int {
+	if t.kind == "array" && !t.dynamic() {
+		return t.size * t.elem.headSize()
+	}
+	return 32
+}
+
+// encodeABITuple encodes the values as a tuple of the types: the static values and the offsets of the
+// dynamic ones in the head, followed by the dynamic values
+func encodeABITuple(types []abiType, values []interface{}) ([]byte, error) {
+	headSize := 0
+	for _, t := range types {
+		headSize += t.headSize()
+	}
+	var head, tail []byte
+	for i, t := range types {
+		encoded, err := encodeABIValue(t, values[i])
+		if err != nil {
+			return nil, err
+		}
+		if t.dynamic() {
+			head = append(head, abiWord(big.NewInt(int64(headSize+len(tail))))...)
+			tail = append(tail, encoded...)
+		} else {
+			head = append(head, encoded...)
+		}
+	}
+	return append(head, tail...), nil
+}
+
+// encodeABIValue encodes a JSON value as the type
+func encodeABIValue(t abiType, value interface{}) ([]byte, error) {
+	switch t.kind {
+	case "uint", "int":
+		n, err := abiInteger(value)
+		if err != nil {
+			return nil, err
+		}
+		limit := new(big.Int).Lsh(big.NewInt(1), uint(t.size))
+		min := new(big.Int)
+		if t.kind == "int" {
+			limit.Rsh(limit, 1)
+			min.Neg(limit)
+		}
+		if n.Cmp(min) < 0 || n.Cmp(limit) >= 0 {
+			return nil, fmt.Errorf("%s out of range for %s%d", n, t.kind, t.size)
+		}
+		if n.Sign() < 0 {
+			// Two's complement on 256 bits
+			n = new(big.Int).Add(n, new(big.Int).Lsh(big.NewInt(1), 256))
+		}
+		return abiWord(n), nil
+	case "address":
+		s, ok := value.(string)
+		b, err := hex.DecodeString(trimHexPrefix(s))
+		if !ok || err != nil || len(b) != 20 {
+			return nil, fmt.Errorf("invalid address %v", value)
+		}
+		return leftPad(b), nil
+	case "bool":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("invalid bool %v", value)
+		}
+		if b {
+			return abiWord(big.NewInt(1)), nil
+		}
+		return abiWord(new(big.Int)), nil
+	case "fixedBytes":
+		b, err := abiBytes(value)
+		if err != nil || len(b) != t.size {
+			return nil, fmt.Errorf("invalid bytes%d %v", t.size, value)
+		}
+		return rightPad(b), nil
+	case "bytes", "string":
+		var b []byte
+		var err error
+		if t.kind == "bytes" {
+			b, err = abiBytes(value)
+		} else if s, ok := value.(string); ok {
+			b = []byte(s)
+		} else {
+			err = fmt.Errorf("invalid string %v", value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return append(abiWord(big.NewInt(int64(len(b)))), rightPad(b)...), nil
+	case "array", "slice":
+		items, ok := value.([]interface{})
+		if !ok || (t.kind == "array" && len(items) != t.size) {
+			return nil, fmt.Errorf("invalid array %v", value)
+		}
+		types := make([]abiType, len(items))
+		for i := range types {
+			types[i] = *t.elem
+		}
+		encoded, err := encodeABITuple(types, items)
+		if err != nil {
+			return nil, err
+		}
+		if t.kind == "slice" {
+			encoded = append(abiWord(big.NewInt(int64(len(items)))), encoded...)
+		}
+		return encoded, nil
+	}
+	return nil, fmt.Errorf("unsupported ABI type %s", t.kind)
+}
+
+// decodeABITuple decodes a tuple of the types
+func decodeABITuple(types []abiType, data []byte) ([]interface{}, error) {
+	values := make([]interface{}, len(types))
+	position := 0
+	for i, t := range types {
+		if position+t.headSize() > len(data) {
+			return nil, fmt.Errorf("short ABI data")
+		}
+		start := position
+		if t.dynamic() {
+			offset := new(big.Int).SetBytes(data[position : position+32])
+			if !offset.IsInt64() || offset.Int64() > int64(len(data)) {
+				return nil, fmt.Errorf("invalid ABI offset %s", offset)
+			}
+			start = int(offset.Int64())
+		}
+		value, err := decodeABIValue(t, data[start:])
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+		position += t.headSize()
+	}
+	return values, nil
+}
+
+// decodeABIValue decodes a value of the type at the start of data
+func decodeABIValue(t abiType, data []byte) (interface{}, error) {
+	if len(data) < 32 && t.kind != "array" {
+		return nil, fmt.Errorf("short ABI data")
+	}
+	switch t.kind {
+	case "uint":
+		return new(big.Int).SetBytes(data[:32]).String(), nil
+	case "int":
+		n := new(big.Int).SetBytes(data[:32])
+		if data[0]&0x80 != 0 {
+			n.Sub(n, new(big.Int).Lsh(big.NewInt(1), 256))
+		}
+		return n.String(), nil
+	case "address":
+		return "0x" + hex.EncodeToString(data[12:32]), nil
+	case "bool":
+		return new(big.Int).SetBytes(data[:32]).Sign() != 0, nil
+	case "fixedBytes":
+		return "0x" + hex.EncodeToString(data[:t.size]), nil
+	case "bytes", "string":
+		length := new(big.Int).SetBytes(data[:32])
+		if !length.IsInt64() || length.Int64() > int64(len(data)-32) {
+			return nil, fmt.Errorf("invalid ABI length %s", length)
+		}
+		b := data[32 : 32+length.Int64()]
+		if t.kind == "string" {
+			return string(b), nil
+		}
+		return "0x" + hex.EncodeToString(b), nil
+	case "array", "slice":
+		length := t.size
+		if t.kind == "slice" {
+			n := new(big.Int).SetBytes(data[:32])
+			// Each element takes at least a word, which bounds the length by the data size
+			if !n.IsInt64() || n.Int64() > int64(len(data)/32) {
+				return nil, fmt.Errorf("invalid ABI length %s", n)
+			}
+			length = int(n.Int64())
+			data = data[32:]
+		}
+		types := make([]abiType, length)
+		for i := range types {
+			types[i] = *t.elem
+		}
+		return decodeABITuple(types, data)
+	}
+	return nil, fmt.Errorf("unsupported ABI type %s", t.kind)
+}
+
+// abiInteger reads an integer given as a decimal or 0x-prefixed hex string, or as a JSON number
+func abiInteger(value interface{}) (*big.Int, error) {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case json.Number:
+		s = v.String()
+	case float64:
+		if v != float64(int64(v)) {
+			return nil, fmt.Errorf("invalid integer %v", v)
+		}
+		return big.NewInt(int64(v)), nil
+	default:
+		return nil, fmt.Errorf("invalid integer %v", value)
+	}
+	digits, base := s, 10
+	if strings.HasPrefix(s, "0x") {
+		digits, base = s[2:], 16
+	}
+	n, ok := new(big.Int).SetString(digits, base)
+	if !ok {
+		return nil, fmt.Errorf("invalid integer %q", s)
+	}
+	return n, nil
+}
+
+// abiBytes reads bytes given as a 0x-prefixed hex string
+func abiBytes(value interface{}) ([]byte, error) {
+	s, ok := value.(string)
+	if !ok || !strings.HasPrefix(s, "0x") {
+		return nil, fmt.Errorf("invalid bytes %v", value)
+	}
+	return hex.DecodeString(s[2:])
+}
+
+// abiWord returns a non-negative integer as a 32-byte word
+func abiWord(n *big.Int) []byte {
+	return leftPad(n.Bytes())
+}
+
+// leftPad pads b with zeros on the left to 32 bytes
+func leftPad(b []byte) []byte {
+	word := make([]byte, 32)
+	copy(word[32-len(b):], b)
+	return word
+}
+
+// rightPad pads b with zeros on the right to a multiple of 32 bytes
+func rightPad(b []byte) []byte {
+	padded := make([]byte, (len(b)+31)/32*32)
+	copy(padded, b)
+	return padded
+}
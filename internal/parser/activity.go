@@ -0,0 +1,310 @@
+package parser
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// StageActivity is the pipeline stage adding the senders and recipients of the blocks to the activity index
+const StageActivity = "activity"
+
+// defaultActivityRangeBlocks is the number of blocks of a range of the activity index
+const defaultActivityRangeBlocks = 1000
+
+// activityFalsePositives is the false positive rate of the filter of a range
+const activityFalsePositives = 0.01
+
+// ErrActivityIndexDisabled is returned by LookupActivity without WithActivityIndex
+var ErrActivityIndexDisabled = errors.New("activity index disabled")
+
+// ActivityRange is a bloom filter of the addresses that sent or received a transaction in the blocks FromBlock to
+// ToBlock, all of them processed. The bits are the little-endian words of the filter, hashed like the bloom matcher.
+type ActivityRange struct {
+	FromBlock int    `json:"fromBlock"`
+	ToBlock   int    `json:"toBlock"`
+	Hashes    int    `json:"hashes"`
+	Bits      []byte `json:"bits"`
+}
+
+// filter returns the bloom filter of the range
+func (r ActivityRange) filter() *bloomMatcher {
+	words := make([]uint64, len(r.Bits)/8)
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint64(r.Bits[i*8:])
+	}
+	return &bloomMatcher{bits: words, size: uint64(len(words)) * 64, hashes: r.Hashes}
+}
+
+// ActivityIndexStore persists the ranges of the activity index, see WithActivityIndex
+type ActivityIndexStore interface {
+	// SaveActivityRange saves a range, replacing the one starting at the same block
+	SaveActivityRange(r ActivityRange) error
+	// ActivityRanges returns the ranges overlapping the blocks fromBlock to toBlock, in block order
+	ActivityRanges(fromBlock int, toBlock int) ([]ActivityRange, error)
+	// DeleteActivityRanges removes the ranges ending before the block
+	DeleteActivityRanges(beforeBlock int) error
+}
+
+// ActivitySpan is a span of blocks of an ActivityLookup
+type ActivitySpan struct {
+	FromBlock int `json:"fromBlock"`
+	ToBlock   int `json:"toBlock"`
+	// Indexed is false for the blocks the index doesn't cover, e.g. processed before it was enabled
+	Indexed bool `json:"indexed"`
+}
+
+// ActivityLookup tells where an address may have been active in a block range
+type ActivityLookup struct {
+	Address   string `json:"address"`
+	FromBlock int    `json:"fromBlock"`
+	ToBlock   int    `json:"toBlock"`
+	// Spans are the blocks where the address may have been active, in block order: the indexed ranges whose
+	// filter may contain it, false positives included, and the blocks not indexed. It's empty when the
+	// address was active in none of the blocks.
+	Spans []ActivitySpan `json:"spans"`
+	// SkippedBlocks counts the blocks where the index shows that the address wasn't active
+	SkippedBlocks int `json:"skippedBlocks"`
+}
+
+// openActivityRange is the range of the activity index being built, saved once its last block is processed or
+// when a block of another range is processed. Its addresses are exact, the filter is sized on completion.
+type openActivityRange struct {
+	fromBlock int
+	toBlock   int
+	addresses map[string]bool
+}
+
+// activityStage adds the senders and recipients of the block to the open range of the activity index. The
+// blocks of a range must be processed in a row: after a gap, e.g. a skipped block or a restart, the range only
+// covers the blocks from the gap on.
+func (p *EthParser) activityStage(_ context.Context, block *BlockContext) error {
+	if p.activityStore == nil {
+		return nil
+	}
+	start := block.Number - block.Number%p.activityRangeBlocks
+	p.mu.Lock()
+	open, completed := p.activityOpen, (*openActivityRange)(nil)
+	switch {
+	case open == nil || open.fromBlock-open.fromBlock%p.activityRangeBlocks != start:
+		// A block of another range, the open one is saved as it is
+		completed = open
+		open = &openActivityRange{fromBlock: block.Number, toBlock: block.Number, addresses: make(map[string]bool)}
+	case block.Number == open.toBlock+1:
+		open.toBlock = block.Number
+	case block.Number < open.fromBlock || block.Number > open.toBlock:
+		// The addresses seen before the gap are kept, the filter only matching more addresses
+		open.fromBlock, open.toBlock = block.Number, block.Number
+	}
+	for _, tx := range block.Block.Transactions {
+		for _, address := range []string{tx.From, tx.To} {
+			if address != "" {
+				open.addresses[strings.ToLower(address)] = true
+			}
+		}
+	}
+	if block.Number == start+p.activityRangeBlocks-1 {
+		completed, open = open, nil
+	}
+	p.activityOpen = open
+	p.mu.Unlock()
+
+	if completed == nil {
+		return nil
+	}
+	return p.saveActivityRange(completed)
+}
+
+// saveActivityRange saves the filter of a range and removes the ranges beyond the retention
+func (p *EthParser) saveActivityRange(open *openActivityRange) error {
+	filter := newBloomMatcher(open.addresses, activityFalsePositives)
+	bits := make([]byte, len(filter.bits)*8)
+	for i, word := range filter.bits {
+		binary.LittleEndian.PutUint64(bits[i*8:], word)
+	}
+	saved := ActivityRange{FromBlock: open.fromBlock, ToBlock: open.toBlock, Hashes: filter.hashes, Bits: bits}
+	if err := p.recordStorageWrite(p.activityStore.SaveActivityRange(saved)); err != nil {
+		return err
+	}
+	if p.activityRetain > 0 {
+		before := open.toBlock - open.toBlock%p.activityRangeBlocks - (p.activityRetain-1)*p.activityRangeBlocks
+		if err := p.activityStore.DeleteActivityRanges(before); err != nil {
+			log.Printf("Error removing the activity ranges before block %d: %v\n", before, err)
+		}
+	}
+	return nil
+}
+
+// activityRanges returns the ranges of the index overlapping the blocks, the open one included, and the exact
+// addresses of the open one by its first block
+func (p *EthParser) activityRanges(fromBlock int, toBlock int) ([]ActivityRange, map[int]map[string]bool, error) {
+	ranges, err := p.activityStore.ActivityRanges(fromBlock, toBlock)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The open range is exact, it's only in memory
+	exact := make(map[int]map[string]bool)
+	p.mu.Lock()
+	if open := p.activityOpen; open != nil && open.toBlock >= fromBlock && open.fromBlock <= toBlock {
+		ranges = append(ranges, ActivityRange{FromBlock: open.fromBlock, ToBlock: open.toBlock})
+		exact[open.fromBlock] = make(map[string]bool, len(open.addresses))
+		for address := range open.addresses {
+			exact[open.fromBlock][address] = true
+		}
+	}
+	p.mu.Unlock()
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].FromBlock < ranges[j].FromBlock })
+	return ranges, exact, nil
+}
+
+// inactiveBlocks returns the spans of the blocks fromBlock to toBlock where the activity index shows that none of
+// the addresses was active, in block order
+func (p *EthParser) inactiveBlocks(addresses map[string]bool, fromBlock int, toBlock int) ([]ActivitySpan, error) {
+	ranges, exact, err := p.activityRanges(fromBlock, toBlock)
+	if err != nil {
+		return nil, err
+	}
+	var inactive []ActivitySpan
+	for _, r := range ranges {
+		mayContain := func(address string) bool { return exact[r.FromBlock][address] }
+		if _, ok := exact[r.FromBlock]; !ok {
+			mayContain = r.filter().mayContain
+		}
+		active := false
+		for address := range addresses {
+			if mayContain(strings.ToLower(address)) {
+				active = true
+				break
+			}
+		}
+		if !active {
+			inactive = append(inactive, ActivitySpan{FromBlock: max(r.FromBlock, fromBlock), ToBlock: min(r.ToBlock, toBlock), Indexed: true})
+		}
+	}
+	return inactive, nil
+}
+
+// LookupActivity tells in which blocks of the range an address may have been active, from the activity index of
+// WithActivityIndex, e.g. before backfilling a newly subscribed address. The address doesn't need to be
+// subscribed. toBlock is the last processed block when zero.
+func (p *EthParser) LookupActivity(address string, fromBlock int, toBlock int) (ActivityLookup, error) {
+	if p.activityStore == nil {
+		return ActivityLookup{}, ErrActivityIndexDisabled
+	}
+	p.mu.Lock()
+	processed := p.lastProcessedBlock
+	p.mu.Unlock()
+	if toBlock == 0 {
+		toBlock = processed
+	}
+	if fromBlock < 0 || fromBlock > toBlock {
+		return ActivityLookup{}, fmt.Errorf("%w: block range %d-%d", ErrInvalidRescan, fromBlock, toBlock)
+	}
+	address = strings.ToLower(address)
+	lookup := ActivityLookup{Address: address, FromBlock: fromBlock, ToBlock: toBlock, Spans: []ActivitySpan{}}
+	ranges, exact, err := p.activityRanges(fromBlock, toBlock)
+	if err != nil {
+		return ActivityLookup{}, err
+	}
+	next := fromBlock
+	for _, r := range ranges {
+		from, to := max(r.FromBlock, next), min(r.ToBlock, toBlock)
+		if from > to {
+			continue
+		}
+		if from > next {
+			lookup.Spans = append(lookup.Spans, ActivitySpan{FromBlock: next, ToBlock: from - 1})
+		}
+		active := exact[r.FromBlock][address]
+		if _, ok := exact[r.FromBlock]; !ok {
+			active = r.filter().mayContain(address)
+		}
+		if active {
+			lookup.Spans = append(lookup.Spans, ActivitySpan{FromBlock: from, ToBlock: to, Indexed: true})
+		} else {
+			lookup.SkippedBlocks += to - from + 1
+		}
+		next = to + 1
+	}
+	if next <= toBlock {
+		lookup.Spans = append(lookup.Spans, ActivitySpan{FromBlock: next, ToBlock: toBlock})
+	}
+	return lookup, nil
+}
+
+// SaveActivityRange saves a range in memory
+func (s *MemoryStorage) SaveActivityRange(r ActivityRange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.activityRanges[r.FromBlock] = r
+	return nil
+}
+
+// ActivityRanges returns the ranges in memory overlapping the blocks, in block order
+func (s *MemoryStorage) ActivityRanges(fromBlock int, toBlock int) ([]ActivityRange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ranges := []ActivityRange{}
+	for _, r := range s.activityRanges {
+		if r.ToBlock >= fromBlock && r.FromBlock <= toBlock {
+			ranges = append(ranges, r)
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].FromBlock < ranges[j].FromBlock })
+	return ranges, nil
+}
+
+// DeleteActivityRanges removes the ranges in memory ending before the block
+func (s *MemoryStorage) DeleteActivityRanges(beforeBlock int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for from, r := range s.activityRanges {
+		if r.ToBlock < beforeBlock {
+			delete(s.activityRanges, from)
+		}
+	}
+	return nil
+}
+
+// SaveActivityRange upserts a range, its filter base64 encoded
+func (s *SQLStorage) SaveActivityRange(r ActivityRange) error {
+	_, err := s.db.Exec(`INSERT INTO activity_index (from_block, to_block, hashes, bits) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (from_block) DO UPDATE SET to_block = excluded.to_block, hashes = excluded.hashes, bits = excluded.bits`,
+		r.FromBlock, r.ToBlock, r.Hashes, base64.StdEncoding.EncodeToString(r.Bits))
+	return err
+}
+
+// ActivityRanges returns the ranges overlapping the blocks, in block order
+func (s *SQLStorage) ActivityRanges(fromBlock int, toBlock int) ([]ActivityRange, error) {
+	rows, err := s.db.Query(`SELECT from_block, to_block, hashes, bits FROM activity_index
+		WHERE to_block >= $1 AND from_block <= $2 ORDER BY from_block`, fromBlock, toBlock)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ranges := []ActivityRange{}
+	for rows.Next() {
+		var r ActivityRange
+		var bits string
+		if err := rows.Scan(&r.FromBlock, &r.ToBlock, &r.Hashes, &bits); err != nil {
+			return nil, err
+		}
+		if r.Bits, err = base64.StdEncoding.DecodeString(bits); err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, rows.Err()
+}
+
+// DeleteActivityRanges removes the ranges ending before the block
+func (s *SQLStorage) DeleteActivityRanges(beforeBlock int) error {
+	_, err := s.db.Exec(`DELETE FROM activity_index WHERE to_block < $1`, beforeBlock)
+	return err
+}
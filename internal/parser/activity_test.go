@@ -0,0 +1,70 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestActivityIndex(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	for i := 1; i <= 10; i++ {
+		transactions := []parser.Transaction{{Hash: fmt.Sprintf("0xa%d", i), From: "0x1", To: "0x2", Value: "0x1"}}
+		if i == 5 {
+			transactions = append(transactions, parser.Transaction{Hash: "0xb5", From: "0x9", To: "0x2", Value: "0x1"})
+		}
+		mockBlockchain.AddBlock(i, parser.Block{Number: fmt.Sprintf("0x%x", i), Transactions: transactions})
+	}
+	storage := parser.NewMemoryStorage()
+	ethParser := parser.NewEthParser(ctx, storage, 1, NewMockClient(mockBlockchain), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithActivityIndex(storage, 4, 0))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	// The completed ranges are saved, from the first processed block, the last one is still open
+	ranges, _ := storage.ActivityRanges(0, 10)
+	if len(ranges) != 2 || ranges[0].FromBlock != 1 || ranges[0].ToBlock != 3 || ranges[1].FromBlock != 4 || ranges[1].ToBlock != 7 {
+		t.Fatalf("Expected the ranges 1-3 and 4-7, got %+v", ranges)
+	}
+
+	lookup, err := ethParser.LookupActivity("0x9", 0, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := []parser.ActivitySpan{{FromBlock: 0, ToBlock: 0}, {FromBlock: 4, ToBlock: 7, Indexed: true}}
+	if !reflect.DeepEqual(lookup.Spans, want) || lookup.ToBlock != 10 || lookup.SkippedBlocks != 6 {
+		t.Errorf("Expected the activity of 0x9 in block 0, not indexed, and 4-7, got %+v", lookup)
+	}
+
+	// The backfill of a new address only fetches the range of its activity
+	ethParser.Subscribe("0x9")
+	result, err := ethParser.Backfill(parser.RescanRequest{FromBlock: 1, ToBlock: 10, Addresses: []string{"0x9"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Blocks != 10 || result.Skipped != 6 || result.Transactions != 1 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if transactions := ethParser.GetTransactions("0x9"); len(transactions) != 1 || transactions[0].Hash != "0xb5" {
+		t.Errorf("Expected the transaction of block 5, got %+v", transactions)
+	}
+
+	// An overwrite rescans every block
+	if result, _ := ethParser.Rescan(parser.RescanRequest{FromBlock: 1, ToBlock: 10, Addresses: []string{"0x9"}, Mode: parser.RescanOverwrite}); result.Skipped != 0 {
+		t.Errorf("Expected no skipped block, got %+v", result)
+	}
+}
+
+func TestActivityIndexDisabled(t *testing.T) {
+	ethParser := parser.New(NewMockStorage(), 1, NewMockClient(NewMockBlockchain()), func(string, []parser.Transaction) {})
+	if _, err := ethParser.LookupActivity("0x1", 0, 0); err != parser.ErrActivityIndexDisabled {
+		t.Errorf("Expected ErrActivityIndexDisabled, got %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// ApprovalTopic is the topic of the ERC-20 Approval(address indexed owner, address indexed spender, uint256 value) event
+const ApprovalTopic = "0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"
+
+// StageAllowances is the name of the pipeline stage tracking the approvals, see WithAllowanceTracking
+const StageAllowances = "allowances"
+
+// Event types of the allowance tracking
+const (
+	// EventUnlimitedApproval is sent when a subscribed address approves an unlimited amount of a token
+	EventUnlimitedApproval = "unlimited_approval"
+	// EventApprovalToUnknownSpender is sent when a subscribed address approves a spender missing from the labels
+	EventApprovalToUnknownSpender = "approval_to_unknown_spender"
+)
+
+// unlimitedAllowance is the amount from which an approval is considered unlimited: wallets approve
+// 2^256-1, some contracts decrease the allowance as it's spent, so half of it is the threshold
+var unlimitedAllowance = new(big.Int).Lsh(big.NewInt(1), 255)
+
+// Allowance is the current ERC-20 allowance of an owner for a spender on a token
+type Allowance struct {
+	Owner           string `json:"owner"`
+	Spender         string `json:"spender"`
+	Token           string `json:"token"`
+	Amount          string `json:"amount"` // decimal, in the token base unit
+	Unlimited       bool   `json:"unlimited"`
+	SpenderLabel    string `json:"spenderLabel,omitempty"`
+	BlockNumber     int    `json:"blockNumber"`
+	TransactionHash string `json:"transactionHash"`
+}
+
+// Log is an event log, as returned by eth_getLogs and in the transaction receipts
+type Log struct {
+	Address         string   `json:"address"`
+	Topics          []string `json:"topics"`
+	Data            string   `json:"data"`
+	TransactionHash string   `json:"transactionHash"`
+	Removed         bool     `json:"removed"`
+}
+
+// allowanceKey identifies an allowance of an owner
+type allowanceKey struct {
+	spender string
+	token   string
+}
+
+// GetAllowances returns the current non-zero allowances granted by an address, ordered by token and spender
+func (p *EthParser) GetAllowances(owner string) []Allowance {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	result := []Allowance{}
+	for _, allowance := range p.allowances[strings.ToLower(owner)] {
+		allowance.Owner = owner
+		result = append(result, allowance)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Token != result[j].Token {
+			return result[i].Token < result[j].Token
+		}
+		return result[i].Spender < result[j].Spender
+	})
+	return result
+}
+
+// allowancesStage applies the Approval events of the subscribed addresses in the block
+func (p *EthParser) allowancesStage(ctx context.Context, block *BlockContext) error {
+	if !p.trackAllowances || len(block.Subscribed) == 0 {
+		return nil
+	}
+
+	owners := make([]string, 0, len(block.Subscribed))
+	for address := range block.Subscribed {
+		owners = append(owners, addressTopic(address))
+	}
+	sort.Strings(owners)
+	blockNumber := fmt.Sprintf("0x%x", block.Number)
+	resp, err := p.client.SendRequest(JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "eth_getLogs",
+		Params: []interface{}{map[string]interface{}{
+			"fromBlock": blockNumber,
+			"toBlock":   blockNumber,
+			"topics":    []interface{}{ApprovalTopic, owners},
+		}},
+		ID: 1,
+	})
+	if err != nil {
+		return fmt.Errorf("fetching approvals: %w", err)
+	}
+	raw, err := json.Marshal(resp.Result)
+	if err != nil {
+		return err
+	}
+	var logs []Log
+	if err := json.Unmarshal(raw, &logs); err != nil {
+		return fmt.Errorf("parsing approvals: %w", err)
+	}
+
+	for _, entry := range logs {
+		// ERC-721 Approval has the same signature with the token ID as third indexed topic
+		if entry.Removed || len(entry.Topics) != 3 {
+			continue
+		}
+		amount, ok := new(big.Int).SetString(strings.TrimPrefix(entry.Data, "0x"), 16)
+		if !ok {
+			continue
+		}
+		p.applyApproval(Allowance{
+			Owner:           topicAddress(entry.Topics[1]),
+			Spender:         topicAddress(entry.Topics[2]),
+			Token:           strings.ToLower(entry.Address),
+			Amount:          amount.String(),
+			Unlimited:       amount.Cmp(unlimitedAllowance) >= 0,
+			BlockNumber:     block.Number,
+			TransactionHash: entry.TransactionHash,
+		}, amount.Sign() == 0)
+	}
+	return nil
+}
+
+// applyApproval updates the allowance view and alerts on risky approvals
+func (p *EthParser) applyApproval(allowance Allowance, revoked bool) {
+	if p.labels != nil {
+		if label, ok := p.labels.Label(allowance.Spender); ok {
+			allowance.SpenderLabel = label.Name
+		}
+	}
+
+	p.mu.Lock()
+	key := allowanceKey{spender: allowance.Spender, token: allowance.Token}
+	if revoked {
+		delete(p.allowances[allowance.Owner], key)
+	} else {
+		if p.allowances[allowance.Owner] == nil {
+			p.allowances[allowance.Owner] = make(map[allowanceKey]Allowance)
+		}
+		p.allowances[allowance.Owner][key] = allowance
+	}
+	p.mu.Unlock()
+
+	if revoked {
+		return
+	}
+	data := map[string]string{
+		"spender": allowance.Spender,
+		"token":   allowance.Token,
+		"amount":  allowance.Amount,
+		"hash":    allowance.TransactionHash,
+	}
+	if allowance.Unlimited {
+		p.emitEvent(Event{Type: EventUnlimitedApproval, Address: allowance.Owner, Data: data})
+	}
+	if allowance.SpenderLabel == "" {
+		p.emitEvent(Event{Type: EventApprovalToUnknownSpender, Address: allowance.Owner, Data: data})
+	}
+}
+
+// addressTopic left-pads an address to a 32 bytes log topic
+func addressTopic(address string) string {
+	return "0x" + strings.Repeat("0", 24) + strings.ToLower(strings.TrimPrefix(address, "0x"))
+}
+
+// topicAddress extracts the address of a 32 bytes log topic
+func topicAddress(topic string) string {
+	topic = strings.ToLower(strings.TrimPrefix(topic, "0x"))
+	if len(topic) < 40 {
+		return "0x" + topic
+	}
+	return "0x" + topic[len(topic)-40:]
+}
@@ -0,0 +1,95 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testOwner   = "0x00000000000000000000000000000000000000a1"
+	testToken   = "0x00000000000000000000000000000000000000c1"
+	testRouter  = "0x00000000000000000000000000000000000000d1"
+	testUnknown = "0x00000000000000000000000000000000000000e1"
+)
+
+// approvalClient answers eth_getLogs with the Approval logs of each block
+type approvalClient struct {
+	*MockClient
+	logs map[string][]interface{}
+}
+
+func (c *approvalClient) SendRequest(req parser.JSONRPCRequest) (parser.JSONRPCResponse, error) {
+	if req.Method != "eth_getLogs" {
+		return c.MockClient.SendRequest(req)
+	}
+	filter := req.Params[0].(map[string]interface{})
+	return parser.JSONRPCResponse{Result: c.logs[filter["fromBlock"].(string)]}, nil
+}
+
+// approvalLog builds an Approval log of testOwner for spender
+func approvalLog(spender, amount string, extraTopics ...interface{}) map[string]interface{} {
+	pad := func(address string) string { return "0x" + strings.Repeat("0", 24) + address[2:] }
+	topics := append([]interface{}{parser.ApprovalTopic, pad(testOwner), pad(spender)}, extraTopics...)
+	return map[string]interface{}{
+		"address":         testToken,
+		"topics":          topics,
+		"data":            "0x" + strings.Repeat("0", 64-len(amount)) + amount,
+		"transactionHash": "0xaa",
+	}
+}
+
+func TestEthParserAllowanceTracking(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1"})
+	client := &approvalClient{MockClient: NewMockClient(mockBlockchain), logs: map[string][]interface{}{
+		"0x1": {
+			approvalLog(testRouter, strings.Repeat("f", 64)),
+			approvalLog(testUnknown, "64"),
+			// ERC-721 approval, ignored
+			approvalLog(testUnknown, "", "0x01"),
+		},
+	}}
+
+	labels := parser.NewLabelDB()
+	labels.Add(testRouter, parser.AddressLabel{Name: "Router", Category: "dex"})
+	var events []parser.Event
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, client, func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithAllowanceTracking(), parser.WithLabels(labels),
+		parser.WithEventNotification(func(event parser.Event) { events = append(events, event) }))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe(testOwner)
+
+	ethParser.ProcessNextCycle()
+
+	allowances := ethParser.GetAllowances(testOwner)
+	if len(allowances) != 2 {
+		t.Fatalf("Expected 2 allowances, got %+v", allowances)
+	}
+	if allowances[0].Spender != testRouter || !allowances[0].Unlimited || allowances[0].SpenderLabel != "Router" {
+		t.Errorf("Unexpected router allowance %+v", allowances[0])
+	}
+	if allowances[1].Spender != testUnknown || allowances[1].Amount != "100" || allowances[1].Unlimited {
+		t.Errorf("Unexpected unknown spender allowance %+v", allowances[1])
+	}
+	if len(events) != 2 || events[0].Type != parser.EventUnlimitedApproval || events[1].Type != parser.EventApprovalToUnknownSpender ||
+		events[1].Data["spender"] != testUnknown {
+		t.Errorf("Expected an unlimited approval and an unknown spender alert, got %+v", events)
+	}
+
+	// Revoking the approval removes the allowance
+	client.logs["0x2"] = []interface{}{approvalLog(testUnknown, "0")}
+	mockBlockchain.AddBlock(2, parser.Block{Number: "0x2"})
+	ethParser.ProcessNextCycle()
+	if allowances := ethParser.GetAllowances(testOwner); len(allowances) != 1 || allowances[0].Spender != testRouter {
+		t.Fatalf("Expected only the router allowance after the revocation, got %+v", allowances)
+	}
+	if len(events) != 2 {
+		t.Errorf("A revocation must not alert, got %+v", events)
+	}
+}
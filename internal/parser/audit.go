@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Actions of the audit entries
+const (
+	AuditSubscribe          = "subscribe"
+	AuditUnsubscribe        = "unsubscribe"
+	AuditUpdateSubscription = "update_subscription"
+	AuditMute               = "mute"
+	AuditUnmute             = "unmute"
+	AuditAddToEntity        = "add_to_entity"
+	AuditRemoveFromEntity   = "remove_from_entity"
+)
+
+// AuditActorAnonymous is the actor of the changes made without API key
+const AuditActorAnonymous = "anonymous"
+
+// AuditEntry records a change of the subscriptions: who made it, when, and the subscription before and after
+type AuditEntry struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Actor is who made the change: the admin, the tenant of the API key, else anonymous
+	Actor      string `json:"actor"`
+	RemoteAddr string `json:"remoteAddr,omitempty"`
+	Tenant     string `json:"tenant,omitempty"`
+	Action     string `json:"action"`
+	Address    string `json:"address"`
+	Entity     string `json:"entity,omitempty"`
+	// Before and After are the subscription around the change, nil when it didn't exist
+	Before *Subscription `json:"before,omitempty"`
+	After  *Subscription `json:"after,omitempty"`
+}
+
+// AuditFilter selects audit entries, empty fields match all of them
+type AuditFilter struct {
+	Tenant  string
+	Address string
+	// Limit is the maximum number of entries, the most recent ones, zero returns all of them
+	Limit int
+}
+
+// matches reports whether an entry is selected by the filter
+func (f AuditFilter) matches(entry AuditEntry) bool {
+	return (f.Tenant == "" || entry.Tenant == f.Tenant) && (f.Address == "" || strings.EqualFold(entry.Address, f.Address))
+}
+
+// AuditStore persists the audit log
+type AuditStore interface {
+	AppendAuditEntry(entry AuditEntry) error
+	// AuditEntries returns the entries matching the filter, the most recent first
+	AuditEntries(filter AuditFilter) ([]AuditEntry, error)
+}
+
+// RecordAudit appends an entry to the audit log, if any, setting its ID and time
+func (p *EthParser) RecordAudit(entry AuditEntry) error {
+	if p.audit == nil {
+		return nil
+	}
+	if entry.Actor == "" {
+		entry.Actor = AuditActorAnonymous
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	entry.ID = hex.EncodeToString(id)
+	entry.Time = p.clock.Now()
+	return p.audit.AppendAuditEntry(entry)
+}
+
+// AuditLog returns the audit entries matching the filter, the most recent first
+func (p *EthParser) AuditLog(filter AuditFilter) ([]AuditEntry, error) {
+	if p.audit == nil {
+		return []AuditEntry{}, nil
+	}
+	return p.audit.AuditEntries(filter)
+}
+
+// AppendAuditEntry adds an entry to the audit log in memory
+func (s *MemoryStorage) AppendAuditEntry(entry AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auditLog = append(s.auditLog, entry)
+	return nil
+}
+
+// AuditEntries returns the entries matching the filter, the most recent first
+func (s *MemoryStorage) AuditEntries(filter AuditFilter) ([]AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entries := []AuditEntry{}
+	for i := len(s.auditLog) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && len(entries) == filter.Limit {
+			break
+		}
+		if filter.matches(s.auditLog[i]) {
+			entries = append(entries, s.auditLog[i])
+		}
+	}
+	return entries, nil
+}
+
+// AppendAuditEntry inserts an entry in the audit log, the subscriptions are sealed like the transaction payloads
+func (s *SQLStorage) AppendAuditEntry(entry AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	payload, err := sealField(s.cipher, string(data), "audit_log/"+entry.ID)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO audit_log (id, created_at, tenant, address, payload) VALUES ($1, $2, $3, $4, $5)`,
+		entry.ID, entry.Time.UnixNano(), entry.Tenant, strings.ToLower(entry.Address), payload)
+	return err
+}
+
+// AuditEntries returns the entries matching the filter, the most recent first
+func (s *SQLStorage) AuditEntries(filter AuditFilter) ([]AuditEntry, error) {
+	query := `SELECT id, payload FROM audit_log WHERE ($1 = '' OR tenant = $1) AND ($2 = '' OR address = $2)
+		ORDER BY created_at DESC`
+	args := []interface{}{filter.Tenant, strings.ToLower(filter.Address)}
+	if filter.Limit > 0 {
+		query += ` LIMIT $3`
+		args = append(args, filter.Limit)
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var id, payload string
+		if err := rows.Scan(&id, &payload); err != nil {
+			return nil, err
+		}
+		if payload, err = openField(s.cipher, payload, "audit_log/"+id); err != nil {
+			return nil, err
+		}
+		var entry AuditEntry
+		if err := json.Unmarshal([]byte(payload), &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,144 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestEthParserUnsubscribeKeepsHistory(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{{Hash: "0xa1", From: "0x1", To: "0x2", Value: "0x1"}}})
+	mockClient := NewMockClient(mockBlockchain)
+	clock := parser.NewManualClock(time.Now())
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, mockClient, func(string, []parser.Transaction) {}, parser.WithClock(clock))
+	defer ethParser.WaitForShutdown()
+	ethParser.SubscribeWith(parser.Subscription{Address: "0x1", Email: &parser.EmailConfig{Recipients: []string{"ops@example.com"}}})
+	ethParser.ProcessNextCycle()
+
+	subscription, ok := ethParser.Unsubscribe("0x1")
+	if !ok || subscription.UnsubscribedAt == nil || !subscription.UnsubscribedAt.Equal(clock.Now()) || subscription.Email == nil {
+		t.Fatalf("Expected the subscription stamped with its unsubscription time, got %+v", subscription)
+	}
+	if _, subscribed := ethParser.GetSubscription("0x1"); subscribed {
+		t.Error("Expected the address to be unsubscribed")
+	}
+	if _, ok := ethParser.Unsubscribe("0x1"); ok {
+		t.Error("Expected a second unsubscribe to fail")
+	}
+
+	// New blocks aren't matched anymore, the stored transactions stay
+	mockBlockchain.AddBlock(2, parser.Block{Number: "0x2", Transactions: []parser.Transaction{{Hash: "0xa2", From: "0x1", To: "0x2", Value: "0x1"}}})
+	ethParser.ProcessNextCycle()
+	if transactions := ethParser.GetTransactions("0x1"); len(transactions) != 1 || transactions[0].Hash != "0xa1" {
+		t.Fatalf("Expected the history of the unsubscribed address, got %+v", transactions)
+	}
+
+	// Subscribing again reactivates it
+	if !ethParser.Subscribe("0x1") {
+		t.Fatal("Expected the address to be subscribed again")
+	}
+	if subscription, _ := ethParser.GetSubscription("0x1"); subscription.UnsubscribedAt != nil {
+		t.Errorf("Expected an active subscription, got %+v", subscription)
+	}
+}
+
+func TestEthParserUpdateSubscription(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(NewMockBlockchain()), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+	if ethParser.UpdateSubscription(parser.Subscription{Address: "0x1"}) {
+		t.Error("Expected the update of an unsubscribed address to fail")
+	}
+	ethParser.SubscribeWith(parser.Subscription{Address: "0x1", StartBlock: 5})
+	email := &parser.EmailConfig{Recipients: []string{"ops@example.com"}, Digest: parser.DigestDaily}
+	if !ethParser.UpdateSubscription(parser.Subscription{Address: "0x1", Email: email}) {
+		t.Fatal("Expected the subscription to be updated")
+	}
+	if subscription, _ := ethParser.GetSubscription("0x1"); subscription.Email != email || subscription.StartBlock != 5 {
+		t.Errorf("Expected only the notification settings to change, got %+v", subscription)
+	}
+}
+
+func TestTenantUnsubscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{{Hash: "0xa1", From: "0x1", To: "0x2", Value: "0x1"}}})
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+	manager := parser.NewTenantManager(ethParser)
+	manager.CreateTenant("a", "A", 1)
+	manager.CreateTenant("b", "B", 0)
+	tenantA, tenantB := manager.View("a"), manager.View("b")
+	tenantA.Subscribe("0x1")
+	tenantB.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	if _, ok := tenantA.Unsubscribe("0x1"); !ok {
+		t.Fatal("Expected tenant a to unsubscribe")
+	}
+	if transactions := tenantA.GetTransactions("0x1"); len(transactions) != 1 {
+		t.Errorf("Expected tenant a to keep reading the history, got %+v", transactions)
+	}
+	if _, subscribed := ethParser.GetSubscription("0x1"); !subscribed {
+		t.Error("Expected the address to stay watched for tenant b")
+	}
+	// The quota is freed
+	if ok, err := tenantA.TrySubscribe(parser.Subscription{Address: "0x3"}); !ok || err != nil {
+		t.Errorf("Expected tenant a to subscribe within its quota, got %v %v", ok, err)
+	}
+
+	tenantB.Unsubscribe("0x1")
+	if _, subscribed := ethParser.GetSubscription("0x1"); subscribed {
+		t.Error("Expected the address to be unwatched once no tenant subscribes to it")
+	}
+	if transactions := manager.View("c").GetTransactions("0x1"); transactions != nil {
+		t.Errorf("Expected no history for other tenants, got %+v", transactions)
+	}
+}
+
+func TestEthParserAuditLog(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := parser.NewMemoryStorage()
+	ethParser := parser.NewEthParser(ctx, storage, 1, NewMockClient(NewMockBlockchain()), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithAuditLog(storage))
+	defer ethParser.WaitForShutdown()
+	manager := parser.NewTenantManager(ethParser)
+	manager.CreateTenant("a", "A", 0)
+	tenantA := manager.View("a")
+
+	ethParser.RecordAudit(parser.AuditEntry{Action: parser.AuditSubscribe, Address: "0x1", After: &parser.Subscription{Address: "0x1"}})
+	tenantA.RecordAudit(parser.AuditEntry{Action: parser.AuditSubscribe, Address: "0x2"})
+	tenantA.RecordAudit(parser.AuditEntry{Action: parser.AuditUnsubscribe, Address: "0x2", Actor: "admin"})
+
+	entries, err := ethParser.AuditLog(parser.AuditFilter{})
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %+v %v", entries, err)
+	}
+	if entries[0].Action != parser.AuditUnsubscribe || entries[0].Actor != "admin" || entries[2].Actor != parser.AuditActorAnonymous || entries[2].ID == "" {
+		t.Errorf("Expected the entries most recent first with their actor, got %+v", entries)
+	}
+
+	entries, _ = tenantA.AuditLog(parser.AuditFilter{Address: "0x2", Limit: 1})
+	if len(entries) != 1 || entries[0].Tenant != "a" || entries[0].Action != parser.AuditUnsubscribe {
+		t.Errorf("Expected the last entry of the tenant, got %+v", entries)
+	}
+	if entries, _ := tenantA.AuditLog(parser.AuditFilter{Address: "0x1"}); len(entries) != 0 {
+		t.Errorf("Expected no entry of other tenants, got %+v", entries)
+	}
+	if entries, _ := manager.View("a").AuditLog(parser.AuditFilter{}); entries[1].Actor != "a" {
+		t.Errorf("Expected the tenant as the default actor, got %+v", entries)
+	}
+}
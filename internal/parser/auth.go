@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// EndpointAuth authenticates the requests of a DefaultClient to a node provider, e.g. Alchemy, Infura or
+// QuickNode, besides the keys some of them take in the path of the node URL
+type EndpointAuth struct {
+	// Headers are sent with every request, e.g. {"X-Api-Key": "..."}
+	Headers map[string]string
+	// BearerToken is sent as the Authorization: Bearer header
+	BearerToken string
+	// Username and Password are sent with HTTP basic authentication, e.g. the project ID and secret of Infura
+	Username string
+	Password string
+	// QueryParams are added to the query of the node URL, e.g. {"apikey": "..."}
+	QueryParams map[string]string
+}
+
+// WithAuth authenticates every request of the client. The query keys aren't part of the endpoint of the
+// recorded requests, so a recording doesn't leak them.
+func (c *DefaultClient) WithAuth(auth EndpointAuth) (*DefaultClient, error) {
+	if auth.BearerToken != "" && (auth.Username != "" || auth.Password != "") {
+		return nil, errors.New("bearer token and basic authentication are exclusive")
+	}
+	for name := range auth.Headers {
+		if name == "" || strings.ContainsAny(name, " :\r\n") {
+			return nil, fmt.Errorf("invalid header name %q", name)
+		}
+		if strings.EqualFold(name, "Authorization") && (auth.BearerToken != "" || auth.Username != "") {
+			return nil, errors.New("the Authorization header is set by the bearer token or basic authentication")
+		}
+	}
+	requestURL := c.url
+	if len(auth.QueryParams) > 0 {
+		parsed, err := url.Parse(c.url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid node URL: %w", err)
+		}
+		query := parsed.Query()
+		for name, value := range auth.QueryParams {
+			query.Set(name, value)
+		}
+		parsed.RawQuery = query.Encode()
+		requestURL = parsed.String()
+	}
+	c.auth = &auth
+	c.requestURL = requestURL
+	return c, nil
+}
+
+// authenticate adds the credentials of the client, if any, to a request
+func (c *DefaultClient) authenticate(req *http.Request) {
+	if c.auth == nil {
+		return
+	}
+	for name, value := range c.auth.Headers {
+		req.Header.Set(name, value)
+	}
+	if c.auth.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.auth.BearerToken)
+	}
+	if c.auth.Username != "" || c.auth.Password != "" {
+		req.SetBasicAuth(c.auth.Username, c.auth.Password)
+	}
+}
+
+// ParseHeaderList parses a comma separated list of name=value headers, the values being percent-encoded when
+// they contain a comma, as in OTEL_EXPORTER_OTLP_HEADERS
+func ParseHeaderList(list string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for i, pair := range strings.Split(list, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		// The errors don't quote the pairs, which hold secrets
+		name, value, found := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid header %d, expected name=value", i+1)
+		}
+		decoded, err := url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid percent-encoding in the value of header %s", name)
+		}
+		headers[name] = decoded
+	}
+	return headers, nil
+}
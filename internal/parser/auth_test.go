@@ -0,0 +1,83 @@
+package parser_test
+
+import (
+	"eth-parser/internal/parser"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// exchangeRecorder keeps the recorded exchanges in memory
+type exchangeRecorder struct {
+	exchanges []parser.RPCExchange
+}
+
+func (r *exchangeRecorder) Record(exchange parser.RPCExchange) error {
+	r.exchanges = append(r.exchanges, exchange)
+	return nil
+}
+
+func TestDefaultClientAuth(t *testing.T) {
+	var requests []*http.Request
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":"0x10"}`)
+	}))
+	defer node.Close()
+
+	tests := []struct {
+		name  string
+		auth  parser.EndpointAuth
+		check func(r *http.Request) bool
+	}{
+		{"headers", parser.EndpointAuth{Headers: map[string]string{"X-Api-Key": "key1"}}, func(r *http.Request) bool {
+			return r.Header.Get("X-Api-Key") == "key1" && r.Header.Get("Authorization") == ""
+		}},
+		{"bearer", parser.EndpointAuth{BearerToken: "token1"}, func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "Bearer token1"
+		}},
+		{"basic", parser.EndpointAuth{Username: "project", Password: "secret"}, func(r *http.Request) bool {
+			username, password, ok := r.BasicAuth()
+			return ok && username == "project" && password == "secret"
+		}},
+		{"query", parser.EndpointAuth{QueryParams: map[string]string{"apikey": "key2"}}, func(r *http.Request) bool {
+			return r.URL.Query().Get("apikey") == "key2" && r.URL.Query().Get("chain") == "1"
+		}},
+	}
+	for _, tt := range tests {
+		requests = nil
+		recorder := &exchangeRecorder{}
+		client, err := parser.NewJsonRpcClientWithURL(node.URL + "/?chain=1").WithRecorder(recorder).WithAuth(tt.auth)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if _, err := client.SendRequest(parser.JSONRPCRequest{JSONRPC: "2.0", Method: "eth_blockNumber", ID: 1}); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(requests) != 1 || !tt.check(requests[0]) {
+			t.Errorf("%s: unexpected request %+v", tt.name, requests)
+		}
+		if len(recorder.exchanges) != 1 || strings.Contains(recorder.exchanges[0].Endpoint, "apikey") {
+			t.Errorf("%s: expected the endpoint recorded without the keys, got %+v", tt.name, recorder.exchanges)
+		}
+	}
+
+	if _, err := parser.NewJsonRpcClientWithURL(node.URL).WithAuth(parser.EndpointAuth{BearerToken: "t", Username: "u"}); err == nil {
+		t.Error("Expected an error for a bearer token with basic authentication")
+	}
+	if _, err := parser.NewJsonRpcClientWithURL(node.URL).WithAuth(parser.EndpointAuth{Headers: map[string]string{"X Key": "v"}}); err == nil {
+		t.Error("Expected an error for an invalid header name")
+	}
+}
+
+func TestParseHeaderList(t *testing.T) {
+	headers, err := parser.ParseHeaderList("X-Api-Key=abc, X-Tags=a%2Cb,")
+	if err != nil || len(headers) != 2 || headers["X-Api-Key"] != "abc" || headers["X-Tags"] != "a,b" {
+		t.Fatalf("Unexpected headers %v %v", headers, err)
+	}
+	if _, err := parser.ParseHeaderList("secret"); err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("Expected an error without the value, got %v", err)
+	}
+}
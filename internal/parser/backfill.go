@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"fmt"
+	"log"
+)
+
+// Backfill fills in the history of addresses over already processed blocks, e.g. for the start block of new
+// subscriptions. With the HistorySource of WithHistorySource the transactions are read from it, which is
+// much faster than scanning the blocks and has no range bound; the ones already stored are kept. Without a
+// source, or when the source fails, it's a merge Rescan, which skips the blocks where the index of
+// WithActivityIndex shows no activity of the addresses. The backfilled transactions are not notified.
+func (p *EthParser) Backfill(request RescanRequest) (RescanResult, error) {
+	if p.historySource == nil || request.Mode == RescanOverwrite {
+		return p.Rescan(request)
+	}
+	result, err := p.backfillFromSource(request)
+	if err == nil {
+		return result, nil
+	}
+	log.Printf("Error backfilling blocks %d to %d from the history source, rescanning them: %v\n", request.FromBlock, request.ToBlock, err)
+	return p.Rescan(request)
+}
+
+// backfillFromSource stores the transactions of the HistorySource missing from the storage
+func (p *EthParser) backfillFromSource(request RescanRequest) (RescanResult, error) {
+	p.cycleMu.Lock()
+	defer p.cycleMu.Unlock()
+
+	p.mu.Lock()
+	processed := p.lastProcessedBlock
+	addresses := request.Addresses
+	if len(addresses) == 0 {
+		for address := range p.subscriptions {
+			addresses = append(addresses, address)
+		}
+	}
+	for _, address := range addresses {
+		if _, ok := p.subscriptions[address]; !ok {
+			p.mu.Unlock()
+			return RescanResult{}, fmt.Errorf("%w: address %s not subscribed", ErrInvalidRescan, address)
+		}
+	}
+	p.mu.Unlock()
+	if request.FromBlock < 0 || request.FromBlock > request.ToBlock || request.ToBlock > processed {
+		return RescanResult{}, fmt.Errorf("%w: block range %d-%d, last processed is %d", ErrInvalidRescan, request.FromBlock, request.ToBlock, processed)
+	}
+
+	found := make(map[string][]Transaction, len(addresses))
+	for _, address := range addresses {
+		transactions, err := p.historySource.AddressTransactions(address, request.FromBlock, request.ToBlock)
+		if err != nil {
+			return RescanResult{}, fmt.Errorf("address %s: %w", address, err)
+		}
+		known := make(map[string]bool)
+		for _, tx := range p.storage.GetTransactions(address) {
+			known[tx.Hash] = true
+		}
+		for _, tx := range transactions {
+			if known[tx.Hash] || tx.BlockNumberDecimal < request.FromBlock || tx.BlockNumberDecimal > request.ToBlock {
+				continue
+			}
+			if p.classifier != nil && tx.Category == "" {
+				tx.Category = p.classifier.Classify(tx, nil)
+			}
+			tx.EventID = TransactionEventID(p.eventChainID(), tx)
+			tx.DiscoveredBlock = processed + 1
+			found[address] = append(found[address], tx)
+		}
+	}
+
+	result := RescanResult{Blocks: request.ToBlock - request.FromBlock + 1}
+	err := p.storage.WithTx(func(tx StorageTx) error {
+		for address, transactions := range found {
+			if err := tx.SaveTransactions(address, transactions); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err := p.recordStorageWrite(err); err != nil {
+		return RescanResult{}, err
+	}
+	for address, transactions := range found {
+		result.Transactions += len(transactions)
+		p.recordCounterparties(address, transactions)
+	}
+	log.Printf("Backfilled blocks %d to %d from the history source: %+v\n", request.FromBlock, request.ToBlock, result)
+	return result, nil
+}
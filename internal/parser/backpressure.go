@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"log"
+	"time"
+)
+
+// Defaults of the back-pressure between the head tracking and the fetch loop
+const (
+	// defaultMaxBlocksPerCycle bounds the blocks processed by a fetch cycle, the rest is left to the next ones
+	defaultMaxBlocksPerCycle = 100
+	// defaultMaxBlockLag is the lag from which the head polling is paused until the fetch loop catches up
+	defaultMaxBlockLag = 500
+	// defaultCycleDeadline bounds the duration of a fetch cycle, the rest is left to the next ones
+	defaultCycleDeadline = time.Minute
+)
+
+// BackpressureStats reports the lag of the fetch loop behind the chain head
+type BackpressureStats struct {
+	CurrentBlock       int `json:"currentBlock"`
+	LastProcessedBlock int `json:"lastProcessedBlock"`
+	// Lag is the number of known blocks not processed yet
+	Lag int `json:"lag"`
+	// MaxLag is the highest lag observed
+	MaxLag int `json:"maxLag"`
+	// CatchUpCycles counts the cycles that left blocks behind and were immediately followed by another one
+	CatchUpCycles int `json:"catchUpCycles"`
+	// ThrottledHeadPolls counts the head polls skipped because the lag exceeded the limit
+	ThrottledHeadPolls int `json:"throttledHeadPolls"`
+	// PrefetchedBlocks counts the blocks processed from a download made ahead, see WithPrefetch
+	PrefetchedBlocks int `json:"prefetchedBlocks"`
+}
+
+// BackpressureStats returns a snapshot of the lag of the fetch loop
+func (p *EthParser) BackpressureStats() BackpressureStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := p.backpressure
+	stats.CurrentBlock = p.currentBlock
+	stats.LastProcessedBlock = p.lastProcessedBlock
+	stats.Lag = p.lagLocked()
+	return stats
+}
+
+// lagLocked returns the blocks not processed yet, it's called with mu held
+func (p *EthParser) lagLocked() int {
+	if lag := p.currentBlock - p.lastProcessedBlock; lag > 0 {
+		return lag
+	}
+	return 0
+}
+
+// observeLagLocked records the lag in the stats, it's called with mu held
+func (p *EthParser) observeLagLocked() {
+	if lag := p.lagLocked(); lag > p.backpressure.MaxLag {
+		p.backpressure.MaxLag = lag
+	}
+}
+
+// scheduleFetch queues a fetch cycle. The queue holds one cycle: signals sent while a cycle is queued are
+// coalesced, since the queued cycle processes all the blocks known when it starts.
+func (p *EthParser) scheduleFetch() {
+	select {
+	case p.work <- struct{}{}:
+	default:
+	}
+}
+
+// headPollThrottled reports whether the head polling must be skipped because the fetch loop is too far behind:
+// the known head already gives it work for the next cycles
+func (p *EthParser) headPollThrottled() bool {
+	if p.maxBlockLag <= 0 {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.lagLocked() < p.maxBlockLag {
+		return false
+	}
+	p.backpressure.ThrottledHeadPolls++
+	log.Printf("Skipping head poll: %d blocks behind\n", p.lagLocked())
+	return true
+}
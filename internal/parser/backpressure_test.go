@@ -0,0 +1,65 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// gatedClient holds the block requests until the gate is closed
+type gatedClient struct {
+	*MockClient
+	gate chan struct{}
+}
+
+func (c *gatedClient) SendRequest(req parser.JSONRPCRequest) (parser.JSONRPCResponse, error) {
+	if req.Method == "eth_getBlockByNumber" {
+		<-c.gate
+	}
+	return c.MockClient.SendRequest(req)
+}
+
+// waitUntil polls cond until it holds or the deadline expires
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not met before the deadline")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestEthParserBackpressure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	for i := 1; i <= 25; i++ {
+		mockBlockchain.AddBlock(i, parser.Block{Number: fmt.Sprintf("0x%x", i)})
+	}
+	client := &gatedClient{MockClient: NewMockClient(mockBlockchain), gate: make(chan struct{})}
+	clock := parser.NewManualClock(time.Now())
+
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, client, func(string, []parser.Transaction) {},
+		parser.WithClock(clock), parser.WithBackpressure(3, 5))
+	defer ethParser.WaitForShutdown()
+
+	// The fetch loop is stuck on the first block: the head polling is paused while 10 blocks are behind
+	clock.Advance(time.Second)
+	waitUntil(t, func() bool { return ethParser.BackpressureStats().ThrottledHeadPolls == 1 })
+	if stats := ethParser.BackpressureStats(); stats.Lag != 10 || stats.CurrentBlock != 25 {
+		t.Fatalf("Expected a lag of 10 behind block 25, got %+v", stats)
+	}
+
+	// Once unblocked, a single tick drains the lag in bounded cycles
+	close(client.gate)
+	waitUntil(t, func() bool { return ethParser.BackpressureStats().LastProcessedBlock == 25 })
+	stats := ethParser.BackpressureStats()
+	if stats.Lag != 0 || stats.MaxLag != 10 || stats.CatchUpCycles != 3 {
+		t.Fatalf("Expected 3 catch-up cycles of 3 blocks and a max lag of 10, got %+v", stats)
+	}
+}
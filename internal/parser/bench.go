@@ -0,0 +1,286 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// BenchmarkConfig sets the synthetic chain and the subscriptions of RunBenchmark
+type BenchmarkConfig struct {
+	Blocks               int `json:"blocks"`
+	TransactionsPerBlock int `json:"transactionsPerBlock"`
+	// MatchRate is the share of the transactions sent to a subscribed address, between 0 and 1
+	MatchRate     float64 `json:"matchRate"`
+	Subscriptions int     `json:"subscriptions"`
+	// MaxBlocksPerCycle bounds the blocks of a fetch cycle, see WithBackpressure
+	MaxBlocksPerCycle int `json:"maxBlocksPerCycle"`
+	// Seed makes the synthetic chain reproducible, the same seed generates the same chain
+	Seed int64 `json:"seed"`
+}
+
+// BenchmarkReport is the outcome of RunBenchmark. The latency of a notification runs from the fetch of its
+// block to its delivery, so it covers the decoding, the matching, the storage and the outbox.
+type BenchmarkReport struct {
+	Config        BenchmarkConfig `json:"config"`
+	Duration      time.Duration   `json:"duration"`
+	Blocks        int             `json:"blocks"`
+	Transactions  int             `json:"transactions"`
+	Matched       int             `json:"matched"`
+	Notifications int             `json:"notifications"`
+	// Throughput over the whole run
+	BlocksPerSecond       float64 `json:"blocksPerSecond"`
+	TransactionsPerSecond float64 `json:"transactionsPerSecond"`
+	// Notification latency percentiles
+	LatencyP50 time.Duration `json:"latencyP50"`
+	LatencyP95 time.Duration `json:"latencyP95"`
+	LatencyP99 time.Duration `json:"latencyP99"`
+	LatencyMax time.Duration `json:"latencyMax"`
+	// Memory: the bytes and objects allocated during the run, and the peak of the live heap
+	AllocatedBytes      uint64 `json:"allocatedBytes"`
+	Allocations         uint64 `json:"allocations"`
+	BytesPerTransaction uint64 `json:"bytesPerTransaction"`
+	PeakHeapBytes       uint64 `json:"peakHeapBytes"`
+}
+
+// Write writes the report as an aligned text table
+func (r BenchmarkReport) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	rows := [][2]string{
+		{"chain", fmt.Sprintf("%d blocks x %d transactions, %.2f%% matched, %d subscriptions, seed %d", r.Config.Blocks,
+			r.Config.TransactionsPerBlock, r.Config.MatchRate*100, r.Config.Subscriptions, r.Config.Seed)},
+		{"duration", r.Duration.Round(time.Millisecond).String()},
+		{"processed", fmt.Sprintf("%d blocks, %d transactions, %d matched, %d notifications", r.Blocks, r.Transactions, r.Matched, r.Notifications)},
+		{"throughput", fmt.Sprintf("%.0f blocks/s, %.0f transactions/s", r.BlocksPerSecond, r.TransactionsPerSecond)},
+		{"latency", fmt.Sprintf("p50 %s, p95 %s, p99 %s, max %s", r.LatencyP50, r.LatencyP95, r.LatencyP99, r.LatencyMax)},
+		{"memory", fmt.Sprintf("%d MiB allocated in %d objects, %d B per transaction, peak heap %d MiB",
+			r.AllocatedBytes>>20, r.Allocations, r.BytesPerTransaction, r.PeakHeapBytes>>20)},
+	}
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%s\t%s\n", row[0], row[1])
+	}
+	return tw.Flush()
+}
+
+// RunBenchmark processes a synthetic chain end to end, from the JSON-RPC responses to the notifications, with
+// an in-memory storage, and measures the throughput, the memory and the notification latency. It's meant to
+// compare the pipeline between two versions on the same machine; the node and the network aren't part of it.
+func RunBenchmark(ctx context.Context, config BenchmarkConfig) (BenchmarkReport, error) {
+	switch {
+	case config.Blocks <= 0 || config.TransactionsPerBlock < 0:
+		return BenchmarkReport{}, fmt.Errorf("the blocks must be positive and the transactions per block not negative")
+	case config.MatchRate < 0 || config.MatchRate > 1:
+		return BenchmarkReport{}, fmt.Errorf("the match rate must be between 0 and 1")
+	case config.Subscriptions <= 0:
+		return BenchmarkReport{}, fmt.Errorf("at least one subscription is required")
+	}
+
+	chain := newSyntheticChain(config)
+	var latencyMu sync.Mutex
+	var latencies []time.Duration
+	notify := func(address string, transactions []Transaction) {
+		now := time.Now()
+		latencyMu.Lock()
+		defer latencyMu.Unlock()
+		for _, tx := range transactions {
+			latencies = append(latencies, now.Sub(chain.fetchedAt(tx.BlockNumberDecimal)))
+		}
+	}
+
+	parserCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ethParser := NewEthParser(parserCtx, NewMemoryStorage(), 3600, chain, notify,
+		WithBackpressure(config.MaxBlocksPerCycle, 0), WithCycleDeadline(0))
+	defer ethParser.WaitForShutdown()
+	for _, address := range chain.subscribed {
+		ethParser.Subscribe(address)
+	}
+
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	// The peak is sampled until sampled is closed
+	peak := before.HeapAlloc
+	sampled := make(chan struct{})
+	stopSampling := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		var stats runtime.MemStats
+		for {
+			select {
+			case <-ticker.C:
+				runtime.ReadMemStats(&stats)
+				peak = max(peak, stats.HeapAlloc)
+			case <-stopSampling:
+				return
+			}
+		}
+	}()
+
+	// The head moves to the last block at once: the parser catches up like after a downtime
+	start := time.Now()
+	chain.setHead(config.Blocks)
+	for ethParser.BackpressureStats().LastProcessedBlock < config.Blocks {
+		if err := ctx.Err(); err != nil {
+			close(stopSampling)
+			<-sampled
+			return BenchmarkReport{}, err
+		}
+		ethParser.ProcessNextCycle()
+	}
+	duration := time.Since(start)
+	close(stopSampling)
+	<-sampled
+
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	latencyMu.Lock()
+	latencies = append([]time.Duration(nil), latencies...)
+	latencyMu.Unlock()
+	report := BenchmarkReport{
+		Config:         config,
+		Duration:       duration,
+		Blocks:         config.Blocks,
+		Transactions:   config.Blocks * config.TransactionsPerBlock,
+		Matched:        chain.matchedTransactions(),
+		Notifications:  len(latencies),
+		AllocatedBytes: after.TotalAlloc - before.TotalAlloc,
+		Allocations:    after.Mallocs - before.Mallocs,
+		PeakHeapBytes:  max(peak, after.HeapAlloc),
+	}
+	if seconds := duration.Seconds(); seconds > 0 {
+		report.BlocksPerSecond = float64(report.Blocks) / seconds
+		report.TransactionsPerSecond = float64(report.Transactions) / seconds
+	}
+	if report.Transactions > 0 {
+		report.BytesPerTransaction = report.AllocatedBytes / uint64(report.Transactions)
+	}
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		percentile := func(p float64) time.Duration { return latencies[int(p*float64(len(latencies)-1))] }
+		report.LatencyP50, report.LatencyP95, report.LatencyP99 = percentile(0.50), percentile(0.95), percentile(0.99)
+		report.LatencyMax = latencies[len(latencies)-1]
+	}
+	return report, nil
+}
+
+// syntheticChain is a JsonRpcClient generating the blocks of a BenchmarkConfig on request, encoded and decoded
+// as JSON like the responses of a node
+type syntheticChain struct {
+	config     BenchmarkConfig
+	subscribed []string
+	mu         sync.Mutex
+	head       int
+	fetched    map[int]time.Time
+	matched    int
+}
+
+// newSyntheticChain creates the chain and the subscribed addresses of config, with the head at block 0
+func newSyntheticChain(config BenchmarkConfig) *syntheticChain {
+	rng := rand.New(rand.NewSource(config.Seed))
+	chain := &syntheticChain{config: config, fetched: make(map[int]time.Time)}
+	for i := 0; i < config.Subscriptions; i++ {
+		chain.subscribed = append(chain.subscribed, fmt.Sprintf("0x%040x", rng.Uint64()))
+	}
+	return chain
+}
+
+// setHead moves the head of the chain
+func (c *syntheticChain) setHead(number int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.head = number
+}
+
+// fetchedAt returns when a block was first fetched
+func (c *syntheticChain) fetchedAt(number int) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.fetched[number]
+}
+
+// matchedTransactions returns the number of generated transactions sent to a subscribed address
+func (c *syntheticChain) matchedTransactions() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.matched
+}
+
+// SendRequest answers eth_blockNumber and eth_getBlockByNumber, the other methods aren't supported
+func (c *syntheticChain) SendRequest(req JSONRPCRequest) (JSONRPCResponse, error) {
+	var result interface{}
+	switch req.Method {
+	case "eth_blockNumber":
+		c.mu.Lock()
+		result = fmt.Sprintf("0x%x", c.head)
+		c.mu.Unlock()
+	case "eth_getBlockByNumber":
+		tag, _ := req.Params[0].(string)
+		number, err := convertHexNumberToDecimal(tag)
+		if err != nil {
+			return JSONRPCResponse{}, err
+		}
+		result = c.block(number)
+	default:
+		rpcError := map[string]interface{}{"code": -32601, "message": "method not supported by the synthetic chain"}
+		return JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Error: rpcError}, fmt.Errorf("JSON-RPC error: %v", rpcError)
+	}
+	body, err := json.Marshal(JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+	if err != nil {
+		return JSONRPCResponse{}, err
+	}
+	return decodeRPCResponse(body)
+}
+
+// block generates a block, the same for a block number and a seed
+func (c *syntheticChain) block(number int) Block {
+	c.mu.Lock()
+	_, seen := c.fetched[number]
+	if !seen {
+		c.fetched[number] = time.Now()
+	}
+	c.mu.Unlock()
+
+	rng := rand.New(rand.NewSource(c.config.Seed ^ int64(number)<<20))
+	block := Block{
+		Number:       fmt.Sprintf("0x%x", number),
+		Hash:         fmt.Sprintf("0x%064x", number),
+		Timestamp:    fmt.Sprintf("0x%x", 1700000000+12*number),
+		Transactions: make([]Transaction, c.config.TransactionsPerBlock),
+	}
+	matched := 0
+	for i := range block.Transactions {
+		to := fmt.Sprintf("0x%040x", rng.Uint64())
+		if rng.Float64() < c.config.MatchRate {
+			to = c.subscribed[rng.Intn(len(c.subscribed))]
+			matched++
+		}
+		block.Transactions[i] = Transaction{
+			Hash:             fmt.Sprintf("0x%032x%032x", number, i),
+			From:             fmt.Sprintf("0x%040x", rng.Uint64()),
+			To:               to,
+			Value:            fmt.Sprintf("0x%x", rng.Int63()),
+			BlockNumber:      block.Number,
+			Nonce:            fmt.Sprintf("0x%x", rng.Intn(1000)),
+			TransactionIndex: fmt.Sprintf("0x%x", i),
+			BlockHash:        block.Hash,
+			Input:            "0x",
+			GasPrice:         "0x3b9aca00",
+		}
+	}
+	if !seen {
+		c.mu.Lock()
+		c.matched += matched
+		c.mu.Unlock()
+	}
+	return block
+}
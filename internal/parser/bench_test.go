@@ -0,0 +1,36 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"strings"
+	"testing"
+)
+
+func TestRunBenchmark(t *testing.T) {
+	config := parser.BenchmarkConfig{Blocks: 30, TransactionsPerBlock: 50, MatchRate: 0.1, Subscriptions: 20, MaxBlocksPerCycle: 10, Seed: 7}
+	report, err := parser.RunBenchmark(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Blocks != 30 || report.Transactions != 1500 || report.Matched == 0 || report.Notifications != report.Matched {
+		t.Fatalf("Expected every matched transaction to be notified, got %+v", report)
+	}
+	if report.LatencyMax < report.LatencyP50 || report.AllocatedBytes == 0 || report.TransactionsPerSecond <= 0 {
+		t.Errorf("Expected the latency, memory and throughput to be measured, got %+v", report)
+	}
+
+	// The same seed generates the same chain
+	again, _ := parser.RunBenchmark(context.Background(), config)
+	if again.Matched != report.Matched {
+		t.Errorf("Expected the chain to be reproducible, got %d and %d matches", report.Matched, again.Matched)
+	}
+
+	var text strings.Builder
+	if err := report.Write(&text); err != nil || !strings.Contains(text.String(), "transactions/s") {
+		t.Errorf("Unexpected report %q: %v", text.String(), err)
+	}
+	if _, err := parser.RunBenchmark(context.Background(), parser.BenchmarkConfig{Blocks: 1, MatchRate: 2, Subscriptions: 1}); err == nil {
+		t.Error("Expected an error for a match rate above 1")
+	}
+}
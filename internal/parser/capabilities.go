@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Features that can be disabled by the capability detection
+const (
+	FeaturePendingTracking   = "pending_tracking"
+	FeatureHeadSubscription  = "head_subscription"
+	FeatureChainlinkPrices   = "chainlink_prices"
+	FeatureAllowanceTracking = "allowance_tracking"
+)
+
+// logsProbeRanges are the eth_getLogs block ranges probed, from the largest
+var logsProbeRanges = []int{10000, 2000, 500, 100}
+
+// headProbeTimeout bounds the connection check of the head subscriber
+const headProbeTimeout = 5 * time.Second
+
+// Capabilities is the support matrix of the node, probed at startup with WithCapabilityDetection
+type Capabilities struct {
+	PendingBlock  bool `json:"pendingBlock"`
+	BlockReceipts bool `json:"blockReceipts"`
+	Call          bool `json:"call"`
+	Logs          bool `json:"logs"`
+	// MaxLogsBlockRange is the largest probed eth_getLogs block range accepted by the node
+	MaxLogsBlockRange int  `json:"maxLogsBlockRange"`
+	TraceAPI          bool `json:"traceApi"`
+	DebugAPI          bool `json:"debugApi"`
+	WebSocket         bool `json:"webSocket"`
+	// Disabled lists the configured features turned off because the node doesn't support them
+	Disabled []string `json:"disabled,omitempty"`
+}
+
+// HeadProber is implemented by the HeadSubscribers able to check their endpoint without subscribing
+type HeadProber interface {
+	Probe(ctx context.Context) error
+}
+
+// Probe checks that the WebSocket endpoint accepts connections
+func (s *WSHeadSubscriber) Probe(ctx context.Context) error {
+	conn, err := dialWebSocket(ctx, s.dialer, s.url)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// Capabilities returns the detected node capabilities, zero when the detection is disabled
+func (p *EthParser) Capabilities() Capabilities {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.capabilities
+}
+
+// detectCapabilities probes the node and disables the configured features it can't serve
+func (p *EthParser) detectCapabilities() {
+	caps := Capabilities{
+		PendingBlock:  p.probe("eth_getBlockByNumber", "pending", false),
+		BlockReceipts: p.probe("eth_getBlockReceipts", "0x0"),
+		Call:          p.probe("eth_call", map[string]string{"to": "0x0000000000000000000000000000000000000000", "data": "0x"}, "latest"),
+		TraceAPI:      p.probe("trace_block", "0x0"),
+		DebugAPI:      p.probe("debug_traceBlockByNumber", "0x0", map[string]string{"tracer": "callTracer"}),
+	}
+
+	if latest, err := p.fetchBlockNumber(); err == nil {
+		for _, blockRange := range logsProbeRanges {
+			from := latest - blockRange + 1
+			if from < 0 {
+				from = 0
+			}
+			// The zero address emits no logs, so the probe is cheap whatever the range
+			filter := map[string]interface{}{
+				"fromBlock": fmt.Sprintf("0x%x", from),
+				"toBlock":   fmt.Sprintf("0x%x", latest),
+				"address":   "0x0000000000000000000000000000000000000000",
+			}
+			if p.probe("eth_getLogs", filter) {
+				caps.Logs = true
+				caps.MaxLogsBlockRange = blockRange
+				break
+			}
+		}
+	}
+
+	if p.heads != nil {
+		caps.WebSocket = true
+		if prober, ok := p.heads.(HeadProber); ok {
+			ctx, cancel := context.WithTimeout(context.Background(), headProbeTimeout)
+			if err := prober.Probe(ctx); err != nil {
+				log.Println("Head subscriber unavailable:", err)
+				caps.WebSocket = false
+			}
+			cancel()
+		}
+	}
+
+	if p.trackPending && !caps.PendingBlock {
+		p.trackPending = false
+		caps.Disabled = append(caps.Disabled, FeaturePendingTracking)
+	}
+	if p.heads != nil && !caps.WebSocket {
+		p.heads = nil
+		caps.Disabled = append(caps.Disabled, FeatureHeadSubscription)
+	}
+	if _, chainlink := p.prices.(*ChainlinkPriceProvider); chainlink && !caps.Call {
+		p.prices = nil
+		caps.Disabled = append(caps.Disabled, FeatureChainlinkPrices)
+	}
+	if p.trackAllowances && !caps.Logs {
+		p.trackAllowances = false
+		caps.Disabled = append(caps.Disabled, FeatureAllowanceTracking)
+	}
+	for _, feature := range caps.Disabled {
+		log.Printf("Disabling %s: not supported by the node\n", feature)
+	}
+
+	p.mu.Lock()
+	p.capabilities = caps
+	p.mu.Unlock()
+}
+
+// probe reports whether the node answers a method without error
+func (p *EthParser) probe(method string, params ...interface{}) bool {
+	_, err := p.client.SendRequest(JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+		ID:      1,
+	})
+	return err == nil
+}
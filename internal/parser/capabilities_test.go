@@ -0,0 +1,63 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// limitedClient answers eth_getLogs for ranges up to maxLogsRange and rejects pending blocks when noPending is set
+type limitedClient struct {
+	*MockClient
+	maxLogsRange int
+	noPending    bool
+}
+
+func (c *limitedClient) SendRequest(req parser.JSONRPCRequest) (parser.JSONRPCResponse, error) {
+	switch req.Method {
+	case "eth_getLogs":
+		filter := req.Params[0].(map[string]interface{})
+		var from, to int
+		fmt.Sscanf(filter["fromBlock"].(string), "0x%x", &from)
+		fmt.Sscanf(filter["toBlock"].(string), "0x%x", &to)
+		if to-from+1 > c.maxLogsRange {
+			return parser.JSONRPCResponse{}, fmt.Errorf("block range too large")
+		}
+		return parser.JSONRPCResponse{Result: []interface{}{}}, nil
+	case "eth_getBlockByNumber":
+		if c.noPending && req.Params[0] == "pending" {
+			return parser.JSONRPCResponse{}, fmt.Errorf("pending block not available")
+		}
+	}
+	return c.MockClient.SendRequest(req)
+}
+
+func TestCapabilityDetection(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	// The chain must be longer than the probed ranges for the node limit to be observable
+	for i := 1; i <= 3000; i++ {
+		mockBlockchain.AddBlock(i, parser.Block{Number: fmt.Sprintf("0x%x", i)})
+	}
+	client := &limitedClient{MockClient: NewMockClient(mockBlockchain), maxLogsRange: 500, noPending: true}
+
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, client, func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithPendingTracking(),
+		parser.WithCapabilityDetection())
+	defer ethParser.WaitForShutdown()
+
+	caps := ethParser.Capabilities()
+	if caps.PendingBlock || caps.TraceAPI || caps.DebugAPI || caps.BlockReceipts {
+		t.Fatalf("Unexpected capabilities: %+v", caps)
+	}
+	if !caps.Logs || caps.MaxLogsBlockRange != 500 {
+		t.Fatalf("Unexpected eth_getLogs detection: %+v", caps)
+	}
+	if len(caps.Disabled) != 1 || caps.Disabled[0] != parser.FeaturePendingTracking {
+		t.Fatalf("Pending tracking must be disabled without pending block support, got %v", caps.Disabled)
+	}
+}
@@ -0,0 +1,187 @@
+package parser
+
+import (
+	"context"
+	"log"
+	"strings"
+)
+
+// Transaction categories set by the HeuristicClassifier
+const (
+	CategoryTransfer           = "transfer"
+	CategoryTokenTransfer      = "token_transfer"
+	CategorySwap               = "swap"
+	CategoryNFTMint            = "nft_mint"
+	CategoryBridgeDeposit      = "bridge_deposit"
+	CategoryContractDeployment = "contract_deployment"
+	CategoryContractCall       = "contract_call"
+)
+
+// Categories lists the categories of the HeuristicClassifier
+var Categories = []string{
+	CategoryTransfer, CategoryTokenTransfer, CategorySwap, CategoryNFTMint,
+	CategoryBridgeDeposit, CategoryContractDeployment, CategoryContractCall,
+}
+
+// StageCategorize is the name of the pipeline stage tagging the matched transactions, see WithClassifier
+const StageCategorize = "categorize"
+
+// TransactionClassifier tags a transaction with a category. It's called without logs first; when it returns
+// CategoryContractCall it's called again with the logs of the transaction receipt.
+type TransactionClassifier interface {
+	Classify(tx Transaction, logs []Log) string
+}
+
+// Function selectors and event topics recognized by the HeuristicClassifier
+var (
+	tokenTransferSelectors = map[string]bool{
+		"0xa9059cbb": true, // transfer(address,uint256)
+		"0x23b872dd": true, // transferFrom(address,address,uint256)
+	}
+	swapSelectors = map[string]bool{
+		"0x38ed1739": true, // swapExactTokensForTokens
+		"0x8803dbee": true, // swapTokensForExactTokens
+		"0x7ff36ab5": true, // swapExactETHForTokens
+		"0x18cbafe5": true, // swapExactTokensForETH
+		"0xfb3bdb41": true, // swapETHForExactTokens
+		"0x414bf389": true, // exactInputSingle
+		"0xc04b8d59": true, // exactInput
+		"0xdb3e2198": true, // exactOutputSingle
+		"0x3593564c": true, // execute (Uniswap Universal Router)
+		"0x12aa3caf": true, // swap (1inch)
+	}
+	mintSelectors = map[string]bool{
+		"0x1249c58b": true, // mint()
+		"0xa0712d68": true, // mint(uint256)
+		"0x40c10f19": true, // mint(address,uint256)
+		"0x40d097c3": true, // safeMint(address)
+	}
+	bridgeSelectors = map[string]bool{
+		"0xb1a1a882": true, // depositETH (Optimism standard bridge)
+		"0xe11013dd": true, // bridgeETHTo (OP Stack standard bridge)
+		"0xe9e05c42": true, // depositTransaction (OP Stack portal)
+		"0x439370b1": true, // depositEth (Arbitrum inbox)
+		"0xd2ce7d65": true, // outboundTransfer (Arbitrum gateway router)
+	}
+)
+
+const (
+	transferTopic    = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef" // Transfer(address,address,uint256)
+	swapV2Topic      = "0xd78ad95fa46c994b6551d0da85fc275fe613ce37657fb8d5e3d130840159d822" // Uniswap V2 Swap
+	swapV3Topic      = "0xc42079f94a6350d7e6235f29174924f928cc2ac818eb64fed8004e115fbcca67" // Uniswap V3 Swap
+	zeroAddressTopic = "0x0000000000000000000000000000000000000000000000000000000000000000"
+)
+
+// HeuristicClassifier classifies transactions from their input selector, their counterparty labels and their logs
+type HeuristicClassifier struct {
+	labels *LabelDB
+}
+
+// NewHeuristicClassifier creates a HeuristicClassifier using the categories of the labels (bridge, router) for
+// the counterparties, labels may be nil
+func NewHeuristicClassifier(labels *LabelDB) *HeuristicClassifier {
+	return &HeuristicClassifier{labels: labels}
+}
+
+// Classify implements TransactionClassifier
+func (c *HeuristicClassifier) Classify(tx Transaction, logs []Log) string {
+	if tx.To == "" {
+		return CategoryContractDeployment
+	}
+
+	var counterparty string
+	if c.labels != nil {
+		if label, ok := c.labels.Label(tx.To); ok {
+			counterparty = label.Category
+		}
+	}
+
+	selector := inputSelector(tx.Input)
+	switch {
+	case counterparty == "bridge" || bridgeSelectors[selector]:
+		return CategoryBridgeDeposit
+	case selector == "" || selector == "0x":
+		return CategoryTransfer
+	case swapSelectors[selector] || counterparty == "router":
+		return CategorySwap
+	case mintSelectors[selector]:
+		return CategoryNFTMint
+	case tokenTransferSelectors[selector]:
+		return CategoryTokenTransfer
+	}
+
+	for _, entry := range logs {
+		if len(entry.Topics) == 0 {
+			continue
+		}
+		switch {
+		case entry.Topics[0] == swapV2Topic || entry.Topics[0] == swapV3Topic:
+			return CategorySwap
+		case entry.Topics[0] == transferTopic && len(entry.Topics) == 4 && entry.Topics[1] == zeroAddressTopic:
+			// ERC-721 Transfer from the zero address, the token ID is the third indexed topic
+			return CategoryNFTMint
+		}
+	}
+	return CategoryContractCall
+}
+
+// IsCategory reports whether category is one of the Categories
+func IsCategory(category string) bool {
+	for _, known := range Categories {
+		if known == category {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterByCategory returns the transactions of the category
+func FilterByCategory(transactions []Transaction, category string) []Transaction {
+	filtered := []Transaction{}
+	for _, tx := range transactions {
+		if tx.Category == category {
+			filtered = append(filtered, tx)
+		}
+	}
+	return filtered
+}
+
+// categorizeStage tags the matched transactions, fetching the receipt logs of the ones the input doesn't classify
+func (p *EthParser) categorizeStage(ctx context.Context, block *BlockContext) error {
+	if p.classifier == nil {
+		return nil
+	}
+	categories := make(map[string]string) // hash -> category, a transaction may match twice
+	for _, transactions := range block.Matches {
+		for i := range transactions {
+			tx := &transactions[i]
+			category, ok := categories[tx.Hash]
+			if !ok {
+				category = p.classifier.Classify(*tx, nil)
+				if category == CategoryContractCall {
+					var receipt struct {
+						Logs []Log `json:"logs"`
+					}
+					found, err := p.callResult("eth_getTransactionReceipt", tx.Hash, &receipt)
+					if err != nil {
+						log.Printf("Error fetching the receipt of %s: %v\n", tx.Hash, err)
+					} else if found {
+						category = p.classifier.Classify(*tx, receipt.Logs)
+					}
+				}
+				categories[tx.Hash] = category
+			}
+			tx.Category = category
+		}
+	}
+	return nil
+}
+
+// inputSelector returns the lowercase function selector of a transaction input, the input itself when shorter
+func inputSelector(input string) string {
+	selector := strings.ToLower(input)
+	if len(selector) > 10 {
+		selector = selector[:10]
+	}
+	return selector
+}
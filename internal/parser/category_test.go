@@ -0,0 +1,94 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeuristicClassifier(t *testing.T) {
+	labels := parser.NewLabelDB()
+	labels.Add("0xbridge", parser.AddressLabel{Name: "Bridge", Category: "bridge"})
+	labels.Add("0xrouter", parser.AddressLabel{Name: "Router", Category: "router"})
+	classifier := parser.NewHeuristicClassifier(labels)
+
+	zero := "0x" + strings.Repeat("0", 64)
+	mintLog := parser.Log{Topics: []string{
+		"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef", zero, zero, "0x" + strings.Repeat("0", 63) + "1",
+	}}
+	tests := []struct {
+		name     string
+		tx       parser.Transaction
+		logs     []parser.Log
+		expected string
+	}{
+		{"plain transfer", parser.Transaction{To: "0x2", Input: "0x"}, nil, parser.CategoryTransfer},
+		{"deployment", parser.Transaction{Input: "0x6080"}, nil, parser.CategoryContractDeployment},
+		{"ETH to a bridge", parser.Transaction{To: "0xbridge"}, nil, parser.CategoryBridgeDeposit},
+		{"bridge selector", parser.Transaction{To: "0x3", Input: "0xb1a1a882" + zero[2:]}, nil, parser.CategoryBridgeDeposit},
+		{"swap selector", parser.Transaction{To: "0x3", Input: "0x7ff36ab5" + zero[2:]}, nil, parser.CategorySwap},
+		{"call to a router", parser.Transaction{To: "0xrouter", Input: "0xdeadbeef"}, nil, parser.CategorySwap},
+		{"mint selector", parser.Transaction{To: "0x3", Input: "0xa0712d68" + zero[2:]}, nil, parser.CategoryNFTMint},
+		{"ERC-20 transfer", parser.Transaction{To: "0x3", Input: "0xa9059cbb" + zero[2:]}, nil, parser.CategoryTokenTransfer},
+		{"mint from logs", parser.Transaction{To: "0x3", Input: "0xdeadbeef"}, []parser.Log{mintLog}, parser.CategoryNFTMint},
+		{"unknown call", parser.Transaction{To: "0x3", Input: "0xdeadbeef"}, nil, parser.CategoryContractCall},
+	}
+	for _, tt := range tests {
+		if category := classifier.Classify(tt.tx, tt.logs); category != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, category)
+		}
+	}
+}
+
+// receiptLogsClient returns the given logs in the receipts
+type receiptLogsClient struct {
+	*MockClient
+	logs     map[string][]interface{}
+	receipts []string
+}
+
+func (c *receiptLogsClient) SendRequest(req parser.JSONRPCRequest) (parser.JSONRPCResponse, error) {
+	if req.Method != "eth_getTransactionReceipt" {
+		return c.MockClient.SendRequest(req)
+	}
+	hash := req.Params[0].(string)
+	c.receipts = append(c.receipts, hash)
+	return parser.JSONRPCResponse{Result: map[string]interface{}{"status": "0x1", "logs": c.logs[hash]}}, nil
+}
+
+func TestEthParserCategorization(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	swapTopic := "0xd78ad95fa46c994b6551d0da85fc275fe613ce37657fb8d5e3d130840159d822"
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{
+		Number: "0x1",
+		Transactions: []parser.Transaction{
+			{Hash: "0xa1", From: "0x1", To: "0x2", Value: "100", Input: "0x"},
+			{Hash: "0xa2", From: "0x1", To: "0xpool", Value: "0", Input: "0x022c0d9f"},
+		},
+	})
+	client := &receiptLogsClient{MockClient: NewMockClient(mockBlockchain), logs: map[string][]interface{}{
+		"0xa2": {map[string]interface{}{"address": "0xpool", "topics": []string{swapTopic}}},
+	}}
+
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, client, func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithClassifier(parser.NewHeuristicClassifier(nil)))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	transactions := ethParser.GetTransactions("0x1")
+	if len(transactions) != 2 || transactions[0].Category != parser.CategoryTransfer || transactions[1].Category != parser.CategorySwap {
+		t.Fatalf("Expected a transfer and a swap, got %+v", transactions)
+	}
+	if len(client.receipts) != 1 || client.receipts[0] != "0xa2" {
+		t.Errorf("Only the call left unclassified by its input needs the receipt, fetched %v", client.receipts)
+	}
+	if swaps := parser.FilterByCategory(transactions, parser.CategorySwap); len(swaps) != 1 || swaps[0].Hash != "0xa2" {
+		t.Errorf("Expected the swap to be filtered, got %+v", swaps)
+	}
+}
@@ -0,0 +1,69 @@
+package parser
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// ChainIDMismatch reports whether the connected node is on a different chain than the configured one.
+// While it's true the parser refuses to process blocks.
+func (p *EthParser) ChainIDMismatch() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.chainIDMismatch
+}
+
+// verifyChainID checks the eth_chainId of the node against the configured chain ID, if any.
+// A mismatch stops block processing and is alerted once with an EventChainIDMismatch event.
+func (p *EthParser) verifyChainID() {
+	if p.expectedChainID == 0 {
+		return
+	}
+
+	chainID, err := p.fetchChainID()
+	if err != nil {
+		// Keep the last known state, a transient error is not a proof of a wrong network
+		log.Println("Error fetching chain ID:", err)
+		return
+	}
+
+	p.mu.Lock()
+	wasMismatch := p.chainIDMismatch
+	p.chainIDMismatch = chainID != p.expectedChainID
+	mismatch := p.chainIDMismatch
+	p.mu.Unlock()
+
+	switch {
+	case mismatch && !wasMismatch:
+		log.Printf("Chain ID mismatch: node is on chain %d, expected %d. Block processing is stopped\n", chainID, p.expectedChainID)
+		p.emitEvent(Event{
+			Type: EventChainIDMismatch,
+			Data: map[string]string{
+				"expected": strconv.FormatInt(p.expectedChainID, 10),
+				"actual":   strconv.FormatInt(chainID, 10),
+			},
+		})
+	case !mismatch && wasMismatch:
+		log.Printf("Chain ID %d verified, block processing resumed\n", chainID)
+	}
+}
+
+// fetchChainID fetches the chain ID of the node
+func (p *EthParser) fetchChainID() (int64, error) {
+	resp, err := p.client.SendRequest(JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "eth_chainId",
+		Params:  []interface{}{},
+		ID:      1,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	chainIDHex, ok := resp.Result.(string)
+	if !ok {
+		return 0, fmt.Errorf("unexpected result format")
+	}
+	return strconv.ParseInt(trimHexPrefix(chainIDHex), 16, 64)
+}
@@ -0,0 +1,45 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestEthParserChainIDMismatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.ChainID = 11155111
+	mockBlockchain.AddBlock(1, parser.Block{
+		Number:       "0x1",
+		Transactions: []parser.Transaction{{Hash: "0xabc", From: "0x1", To: "0x2", Value: "100"}},
+	})
+
+	var events []parser.Event
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain),
+		func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())),
+		parser.WithChainID(1),
+		parser.WithEventNotification(func(event parser.Event) { events = append(events, event) }))
+	defer ethParser.WaitForShutdown()
+
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	if !ethParser.ChainIDMismatch() || len(events) != 1 || events[0].Type != parser.EventChainIDMismatch {
+		t.Fatalf("Expected a single chain ID mismatch alert, got %v", events)
+	}
+	if transactions := ethParser.GetTransactions("0x1"); len(transactions) != 0 {
+		t.Fatalf("Blocks must not be processed on the wrong chain: %v", transactions)
+	}
+
+	// Once the node is on the right chain processing resumes
+	mockBlockchain.ChainID = 1
+	ethParser.ProcessNextCycle()
+	if ethParser.ChainIDMismatch() || len(ethParser.GetTransactions("0x1")) != 1 {
+		t.Fatal("Expected processing to resume on the configured chain")
+	}
+}
@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+)
+
+// FaultPolicy configures the faults a FaultInjectionClient injects in the requests, each with its probability
+// between 0 and 1. The faults imitate the misbehaviour of real providers, so that the error handling of an
+// embedder can be tested without one.
+type FaultPolicy struct {
+	// LatencyProbability delays the requests by a random duration up to Latency
+	LatencyProbability float64
+	Latency            time.Duration
+	// TimeoutProbability fails the requests after Timeout with the error of an HTTP client timeout
+	TimeoutProbability float64
+	Timeout            time.Duration
+	// MalformedProbability fails the requests with the decoding error of a truncated JSON response
+	MalformedProbability float64
+	// RateLimitProbability fails the requests with the JSON-RPC error -32005 of a provider rate limit
+	RateLimitProbability float64
+	// ReorgProbability returns, in place of a block of eth_getBlockByNumber, a sibling block with another
+	// hash and no transactions, as after a reorganization, and makes eth_blockNumber go back one block
+	ReorgProbability float64
+	// Methods restricts the faults to the requests of these methods, all of them when empty
+	Methods []string
+	// Seed makes the injected faults reproducible, a random seed is used when it's zero
+	Seed int64
+}
+
+// FaultStats counts the requests of a FaultInjectionClient and the injected faults
+type FaultStats struct {
+	Requests    int `json:"requests"`
+	Delayed     int `json:"delayed"`
+	TimedOut    int `json:"timedOut"`
+	Malformed   int `json:"malformed"`
+	RateLimited int `json:"rateLimited"`
+	Reorged     int `json:"reorged"`
+}
+
+// FaultInjectionClient is a JsonRpcClient decorator injecting faults in the requests of another client, for
+// resilience testing only
+type FaultInjectionClient struct {
+	client JsonRpcClient
+	policy FaultPolicy
+	mu     sync.Mutex
+	random *rand.Rand
+	stats  FaultStats
+}
+
+// truncatedResponse is the body of the malformed responses, cut in the middle of the result
+const truncatedResponse = `{"jsonrpc":"2.0","id":1,"result":{"number":"0x`
+
+// rateLimitResponse is the body of the rate limited responses, as returned by the common providers
+const rateLimitResponse = `{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"limit exceeded"}}`
+
+// NewFaultInjectionClient wraps client with the faults of the policy
+func NewFaultInjectionClient(client JsonRpcClient, policy FaultPolicy) (*FaultInjectionClient, error) {
+	for name, probability := range map[string]float64{
+		"latency":    policy.LatencyProbability,
+		"timeout":    policy.TimeoutProbability,
+		"malformed":  policy.MalformedProbability,
+		"rate limit": policy.RateLimitProbability,
+		"reorg":      policy.ReorgProbability,
+	} {
+		if probability < 0 || probability > 1 {
+			return nil, fmt.Errorf("invalid %s probability %v, expected between 0 and 1", name, probability)
+		}
+	}
+	if policy.Latency < 0 || policy.Timeout < 0 {
+		return nil, errors.New("invalid negative fault duration")
+	}
+	seed := policy.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &FaultInjectionClient{client: client, policy: policy, random: rand.New(rand.NewSource(seed))}, nil
+}
+
+// Stats returns the counts of the requests and of the injected faults
+func (c *FaultInjectionClient) Stats() FaultStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// roll reports whether a fault of the probability happens, counting it in stat
+func (c *FaultInjectionClient) roll(probability float64, stat *int) bool {
+	if probability <= 0 || c.random.Float64() >= probability {
+		return false
+	}
+	*stat++
+	return true
+}
+
+// SendRequest sends the request to the wrapped client, unless a fault replaces its response
+func (c *FaultInjectionClient) SendRequest(req JSONRPCRequest) (JSONRPCResponse, error) {
+	c.mu.Lock()
+	c.stats.Requests++
+	if len(c.policy.Methods) > 0 && !slices.Contains(c.policy.Methods, req.Method) {
+		c.mu.Unlock()
+		return c.client.SendRequest(req)
+	}
+	var delay time.Duration
+	if c.roll(c.policy.LatencyProbability, &c.stats.Delayed) && c.policy.Latency > 0 {
+		delay = time.Duration(c.random.Int63n(int64(c.policy.Latency)) + 1)
+	}
+	timeout := c.roll(c.policy.TimeoutProbability, &c.stats.TimedOut)
+	malformed := !timeout && c.roll(c.policy.MalformedProbability, &c.stats.Malformed)
+	rateLimited := !timeout && !malformed && c.roll(c.policy.RateLimitProbability, &c.stats.RateLimited)
+	reorg := !timeout && !malformed && !rateLimited && c.roll(c.policy.ReorgProbability, &c.stats.Reorged)
+	hash := fmt.Sprintf("0x%016x%016x%016x%016x", c.random.Uint64(), c.random.Uint64(), c.random.Uint64(), c.random.Uint64())
+	c.mu.Unlock()
+
+	time.Sleep(delay)
+	switch {
+	case timeout:
+		time.Sleep(c.policy.Timeout)
+		return JSONRPCResponse{}, &url.Error{Op: "Post", URL: "fault-injection", Err: context.DeadlineExceeded}
+	case malformed:
+		return decodeRPCResponse([]byte(truncatedResponse))
+	case rateLimited:
+		return decodeRPCResponse([]byte(rateLimitResponse))
+	}
+
+	resp, err := c.client.SendRequest(req)
+	if err != nil || !reorg {
+		return resp, err
+	}
+	return reorgResponse(req.Method, resp, hash), nil
+}
+
+// reorgResponse returns the response as after a reorganization: a sibling block with the hash and without
+// transactions, or a head one block back
+func reorgResponse(method string, resp JSONRPCResponse, hash string) JSONRPCResponse {
+	switch method {
+	case "eth_getBlockByNumber":
+		block, ok := resp.Result.(map[string]interface{})
+		if !ok {
+			return resp
+		}
+		sibling := make(map[string]interface{}, len(block))
+		for key, value := range block {
+			sibling[key] = value
+		}
+		sibling["hash"] = hash
+		sibling["transactions"] = []interface{}{}
+		resp.Result = sibling
+	case "eth_blockNumber":
+		head, ok := resp.Result.(string)
+		if !ok {
+			return resp
+		}
+		if number, err := convertHexNumberToDecimal(head); err == nil && number > 0 {
+			resp.Result = fmt.Sprintf("0x%x", number-1)
+		}
+	}
+	return resp
+}
@@ -0,0 +1,82 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFaultInjectionClientFaults(t *testing.T) {
+	blockchain := mockChain(3)
+	blockchain.AddBlock(3, parser.Block{Number: "0x3", Transactions: []parser.Transaction{{Hash: "0xa1", From: "0x1"}}})
+	getBlock := parser.JSONRPCRequest{JSONRPC: "2.0", Method: "eth_getBlockByNumber", Params: []interface{}{"0x3", true}, ID: 1}
+
+	for name, test := range map[string]struct {
+		policy parser.FaultPolicy
+		check  func(parser.JSONRPCResponse, error) bool
+	}{
+		"timeout": {parser.FaultPolicy{TimeoutProbability: 1}, func(_ parser.JSONRPCResponse, err error) bool {
+			return errors.Is(err, context.DeadlineExceeded)
+		}},
+		"malformed": {parser.FaultPolicy{MalformedProbability: 1}, func(_ parser.JSONRPCResponse, err error) bool {
+			return err != nil && strings.Contains(err.Error(), "unexpected EOF")
+		}},
+		"rate limit": {parser.FaultPolicy{RateLimitProbability: 1}, func(_ parser.JSONRPCResponse, err error) bool {
+			return err != nil && strings.Contains(err.Error(), "-32005")
+		}},
+		"reorg": {parser.FaultPolicy{ReorgProbability: 1, Seed: 1}, func(resp parser.JSONRPCResponse, err error) bool {
+			block, _ := resp.Result.(map[string]interface{})
+			return err == nil && block["number"] == "0x3" && len(block["transactions"].([]interface{})) == 0 && len(block["hash"].(string)) == 66
+		}},
+		"other method": {parser.FaultPolicy{TimeoutProbability: 1, Methods: []string{"eth_blockNumber"}}, func(_ parser.JSONRPCResponse, err error) bool {
+			return err == nil
+		}},
+	} {
+		client, err := parser.NewFaultInjectionClient(NewMockClient(blockchain), test.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !test.check(client.SendRequest(getBlock)) {
+			t.Errorf("%s: unexpected response", name)
+		}
+	}
+
+	// The wrapped block isn't modified by the reorg
+	if block, _ := blockchain.GetBlockByNumber(3); len(block.Transactions) != 1 {
+		t.Errorf("Expected the mock block to keep its transaction, got %+v", block)
+	}
+	if _, err := parser.NewFaultInjectionClient(NewMockClient(blockchain), parser.FaultPolicy{RateLimitProbability: 1.5}); err == nil {
+		t.Error("Expected an invalid probability to be rejected")
+	}
+}
+
+func TestFaultInjectionClientParserRecovers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	blockchain := mockChain(5)
+	blockchain.AddBlock(4, parser.Block{Number: "0x4", Transactions: []parser.Transaction{{Hash: "0xa4", From: "0x1"}}})
+	client, err := parser.NewFaultInjectionClient(NewMockClient(blockchain), parser.FaultPolicy{
+		RateLimitProbability: 0.3, MalformedProbability: 0.2, LatencyProbability: 0.5, Latency: time.Millisecond, Seed: 42,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, client, func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	for i := 0; i < 20 && len(ethParser.GetTransactions("0x1")) == 0; i++ {
+		ethParser.ProcessNextCycle()
+	}
+
+	if transactions := ethParser.GetTransactions("0x1"); len(transactions) != 1 {
+		t.Errorf("Expected the transaction despite the faults, got %+v", transactions)
+	}
+	if stats := client.Stats(); stats.RateLimited == 0 || stats.Malformed == 0 || stats.Requests <= stats.RateLimited+stats.Malformed {
+		t.Errorf("Expected injected faults, got %+v", stats)
+	}
+}
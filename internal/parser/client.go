@@ -2,10 +2,15 @@ package parser
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"sync"
+	"time"
 )
 
 // EthereumNodeURL Ethereum node URL for JSON-RPC requests
@@ -19,33 +24,190 @@ type JsonRpcClient interface {
 
 // DefaultClient is the default implementation JsonRpcClient
 type DefaultClient struct {
+	url        string
+	httpClient *http.Client
+	mu         sync.Mutex
+	ctx        context.Context // context of the in-flight requests, replaced by CancelRequests
+	cancel     context.CancelFunc
+	recorder   RPCRecorder
+	auth       *EndpointAuth
+	requestURL string // url with the query keys of auth
+	coalescer  requestCoalescer
+	pausedTill time.Time // after a 429, the requests fail without being sent until then
 }
 
-// NewJsonRpcClient is the default constructor for JsonRpcClient
+// NewJsonRpcClient is the default constructor for JsonRpcClient, sending the requests to EthereumNodeURL
 func NewJsonRpcClient() *DefaultClient {
-	return &DefaultClient{}
+	return NewJsonRpcClientWithURL(EthereumNodeURL)
 }
 
-// SendRequest is the default implementation for sending JSON-RPC requests
+// NewJsonRpcClientWithURL creates a JsonRpcClient sending the requests to the given node URL
+func NewJsonRpcClientWithURL(url string) *DefaultClient {
+	return &DefaultClient{url: url, httpClient: &http.Client{Transport: newRPCTransport()}}
+}
+
+// newRPCTransport returns the transport of the node connections: HTTP/2 is negotiated with the TLS endpoints
+// supporting it, multiplexing the concurrent requests on one connection, and more connections are kept idle
+// for the HTTP/1.1 ones
+func newRPCTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ForceAttemptHTTP2 = true
+	transport.MaxIdleConnsPerHost = 16
+	return transport
+}
+
+// NewJsonRpcClientWithEgress creates a JsonRpcClient sending the requests to the given node URL through the egress
+func NewJsonRpcClientWithEgress(url string, egress EgressConfig) (*DefaultClient, error) {
+	dialer, err := newEgressDialer(egress)
+	if err != nil {
+		return nil, err
+	}
+	return &DefaultClient{url: url, httpClient: dialer.httpClient()}, nil
+}
+
+// EthCall runs a read-only call of the contract at the given block number or tag with eth_call and returns
+// the ABI-encoded result
+func EthCall(client JsonRpcClient, to string, data string, block string) (string, error) {
+	resp, err := client.SendRequest(JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "eth_call",
+		Params:  []interface{}{map[string]string{"to": to, "data": data}, block},
+		ID:      1,
+	})
+	if err != nil {
+		return "", err
+	}
+	result, ok := resp.Result.(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected result format")
+	}
+	return result, nil
+}
+
+// WithRecorder records every request of the client with the raw response of the node, for audit and replay
+func (c *DefaultClient) WithRecorder(recorder RPCRecorder) *DefaultClient {
+	c.recorder = recorder
+	return c
+}
+
+// record sends an exchange to the recorder, if any. A failing recorder doesn't fail the request.
+func (c *DefaultClient) record(exchange RPCExchange) {
+	if c.recorder == nil {
+		return
+	}
+	exchange.Endpoint = c.url
+	exchange.Duration = time.Since(exchange.Time)
+	if err := c.recorder.Record(exchange); err != nil {
+		log.Println("Error recording a JSON-RPC request:", err)
+	}
+}
+
+// redactURL replaces the request URL of a transport error with the node URL, so that the query keys of the
+// auth aren't recorded, returned nor logged with the error
+func (c *DefaultClient) redactURL(err error) error {
+	urlErr, ok := err.(*url.Error)
+	if !ok || c.requestURL == "" {
+		return err
+	}
+	redacted := *urlErr
+	redacted.URL = c.url
+	return &redacted
+}
+
+// requestContext returns the context of the new requests
+func (c *DefaultClient) requestContext() context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ctx == nil {
+		c.ctx, c.cancel = context.WithCancel(context.Background())
+	}
+	return c.ctx
+}
+
+// CancelRequests aborts the in-flight requests, the next ones are sent normally
+func (c *DefaultClient) CancelRequests() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+}
+
+// CoalescedRequests returns the number of requests answered with the response of an identical request in flight
+func (c *DefaultClient) CoalescedRequests() int64 {
+	return c.coalescer.count()
+}
+
+// SendRequest is the default implementation for sending JSON-RPC requests. A request identical to one in flight,
+// with the same method and params, waits for its response instead of being sent again; the writes and the
+// filter methods are always sent.
 func (c *DefaultClient) SendRequest(req JSONRPCRequest) (JSONRPCResponse, error) {
+	return c.coalescer.do(req, c.send)
+}
+
+// send posts a request to the node. After a 429 the endpoint is paused for its Retry-After, the requests
+// meanwhile fail with a RateLimitError without being sent.
+func (c *DefaultClient) send(req JSONRPCRequest) (JSONRPCResponse, error) {
+	c.mu.Lock()
+	paused := time.Until(c.pausedTill)
+	c.mu.Unlock()
+	if paused > 0 {
+		return JSONRPCResponse{}, &RateLimitError{RetryAfter: paused}
+	}
+
 	reqBytes, err := json.Marshal(req)
 	if err != nil {
 		return JSONRPCResponse{}, err
 	}
 
-	resp, err := http.Post(EthereumNodeURL, "application/json", bytes.NewBuffer(reqBytes))
+	requestURL := c.url
+	if c.requestURL != "" {
+		requestURL = c.requestURL
+	}
+	httpReq, err := http.NewRequestWithContext(c.requestContext(), http.MethodPost, requestURL, bytes.NewBuffer(reqBytes))
 	if err != nil {
 		return JSONRPCResponse{}, err
 	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	c.authenticate(httpReq)
+	exchange := RPCExchange{Time: time.Now(), Request: string(reqBytes)}
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		err = c.redactURL(err)
+		exchange.Error = err.Error()
+		c.record(exchange)
+		return JSONRPCResponse{}, err
+	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
+		err = c.redactURL(err)
+		exchange.Error = err.Error()
+		c.record(exchange)
 		return JSONRPCResponse{}, err
 	}
+	exchange.Status = resp.StatusCode
+	exchange.Response = string(body)
+	c.record(exchange)
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		c.mu.Lock()
+		c.pausedTill = time.Now().Add(retryAfter)
+		c.mu.Unlock()
+		return JSONRPCResponse{}, &RateLimitError{RetryAfter: retryAfter}
+	}
+	return decodeRPCResponse(body)
+}
 
+// decodeRPCResponse decodes a JSON-RPC response body, returning an error for a JSON-RPC error
+func decodeRPCResponse(body []byte) (JSONRPCResponse, error) {
+	// Numbers in the result are kept as json.Number, so that large values never round-trip through float64
 	var rpcResp JSONRPCResponse
-	if err := json.Unmarshal(body, &rpcResp); err != nil {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&rpcResp); err != nil {
 		return JSONRPCResponse{}, err
 	}
 
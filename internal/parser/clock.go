@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock abstracts the time source of the background loops, so they can be driven without real time
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker is the subset of time.Ticker used by the parser
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// realClock implements Clock with the time package
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return &realTicker{ticker: time.NewTicker(d)}
+}
+
+// realTicker wraps a time.Ticker
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t *realTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t *realTicker) Stop() {
+	t.ticker.Stop()
+}
+
+// ManualClock is a Clock that only moves when Advance is called.
+// Combined with EthParser.ProcessNextCycle it allows running the parser deterministically.
+type ManualClock struct {
+	now     time.Time
+	tickers []*manualTicker
+	mu      sync.Mutex
+}
+
+// NewManualClock creates a ManualClock starting at the given time
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+// Now returns the current time of the clock
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker creates a ticker firing when the clock is advanced past its period
+func (c *ManualClock) NewTicker(d time.Duration) Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &manualTicker{c: make(chan time.Time, 1), period: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance moves the clock forward, firing the tickers whose period elapsed.
+// Like time.Ticker, ticks are dropped when the receiver is not keeping up.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		t.fire(c.now)
+	}
+}
+
+// manualTicker is the Ticker created by ManualClock
+type manualTicker struct {
+	c       chan time.Time
+	period  time.Duration
+	next    time.Time
+	stopped bool
+	mu      sync.Mutex
+}
+
+func (t *manualTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *manualTicker) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+}
+
+// fire sends a tick for every elapsed period up to now
+func (t *manualTicker) fire(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for !t.stopped && !t.next.After(now) {
+		select {
+		case t.c <- t.next:
+		default:
+		}
+		t.next = t.next.Add(t.period)
+	}
+}
@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// coalescedCall is a request in flight, shared by the identical requests sent before it completes
+type coalescedCall struct {
+	done     chan struct{}
+	response JSONRPCResponse
+	err      error
+}
+
+// requestCoalescer shares the response of a request in flight with the identical requests, e.g. two components
+// fetching the same block, so that the provider answers it once
+type requestCoalescer struct {
+	mu        sync.Mutex
+	calls     map[string]*coalescedCall
+	coalesced int64
+}
+
+// coalescable reports whether the identical requests of a method can share a response: the writes and the
+// filter methods change the state of the node, each of them is sent
+func coalescable(method string) bool {
+	switch {
+	case strings.HasPrefix(method, "eth_send"), strings.HasPrefix(method, "eth_new"),
+		method == "eth_getFilterChanges", method == "eth_uninstallFilter":
+		return false
+	}
+	return true
+}
+
+// do sends req with send, or waits for the identical request in flight and returns its response with the ID
+// of req. Requests are identical with the same method and params; their result is shared and mustn't be modified.
+func (g *requestCoalescer) do(req JSONRPCRequest, send func(JSONRPCRequest) (JSONRPCResponse, error)) (JSONRPCResponse, error) {
+	if !coalescable(req.Method) {
+		return send(req)
+	}
+	params, err := json.Marshal(req.Params)
+	if err != nil {
+		return send(req)
+	}
+	key := req.Method + string(params)
+
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*coalescedCall)
+	}
+	if call, inFlight := g.calls[key]; inFlight {
+		g.coalesced++
+		g.mu.Unlock()
+		<-call.done
+		response := call.response
+		response.ID = req.ID
+		return response, call.err
+	}
+	call := &coalescedCall{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	call.response, call.err = send(req)
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(call.done)
+	return call.response, call.err
+}
+
+// count returns the number of requests answered with the response of an identical one
+func (g *requestCoalescer) count() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.coalesced
+}
@@ -0,0 +1,88 @@
+package parser_test
+
+import (
+	"encoding/json"
+	"eth-parser/internal/parser"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// blockingNode answers every request with its ID once release is closed, counting the requests received
+func blockingNode(release chan struct{}, received *atomic.Int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req parser.JSONRPCRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		received.Add(1)
+		<-release
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"result":"0x%x"}`, req.ID, req.ID)
+	}))
+}
+
+func TestDefaultClientCoalescesIdenticalRequests(t *testing.T) {
+	release := make(chan struct{})
+	var received atomic.Int64
+	server := blockingNode(release, &received)
+	defer server.Close()
+	client := parser.NewJsonRpcClientWithURL(server.URL)
+
+	const callers = 5
+	responses := make([]parser.JSONRPCResponse, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i], _ = client.SendRequest(parser.JSONRPCRequest{
+				JSONRPC: "2.0", Method: "eth_getBlockByNumber", Params: []interface{}{"0x1", true}, ID: i + 1,
+			})
+		}(i)
+	}
+	waitUntil(t, func() bool { return received.Load() == 1 && client.CoalescedRequests() == callers-1 })
+	close(release)
+	wg.Wait()
+
+	if received.Load() != 1 {
+		t.Errorf("Expected a single request to the node, got %d", received.Load())
+	}
+	// Every caller gets its own ID with the shared result
+	for i, resp := range responses {
+		if resp.ID != i+1 || resp.Result == nil {
+			t.Errorf("Unexpected response for caller %d: %+v", i+1, resp)
+		}
+	}
+
+	// A completed request isn't shared with the next ones
+	if _, err := client.SendRequest(parser.JSONRPCRequest{JSONRPC: "2.0", Method: "eth_getBlockByNumber", Params: []interface{}{"0x1", true}, ID: 9}); err != nil {
+		t.Fatal(err)
+	}
+	if received.Load() != 2 {
+		t.Errorf("Expected the request after completion to be sent, got %d requests", received.Load())
+	}
+}
+
+func TestDefaultClientDoesNotCoalesceWrites(t *testing.T) {
+	release := make(chan struct{})
+	var received atomic.Int64
+	server := blockingNode(release, &received)
+	defer server.Close()
+	client := parser.NewJsonRpcClientWithURL(server.URL)
+
+	var wg sync.WaitGroup
+	for _, method := range []string{"eth_sendRawTransaction", "eth_sendRawTransaction", "eth_getFilterChanges", "eth_getFilterChanges"} {
+		wg.Add(1)
+		go func(method string) {
+			defer wg.Done()
+			client.SendRequest(parser.JSONRPCRequest{JSONRPC: "2.0", Method: method, Params: []interface{}{"0x01"}, ID: 1})
+		}(method)
+	}
+	waitUntil(t, func() bool { return received.Load() == 4 })
+	close(release)
+	wg.Wait()
+	if client.CoalescedRequests() != 0 {
+		t.Errorf("Expected no coalesced request, got %d", client.CoalescedRequests())
+	}
+}
@@ -0,0 +1,107 @@
+package parser_test
+
+import (
+	"encoding/json"
+	"errors"
+	"eth-parser/internal/parser"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func codecTransaction() parser.Transaction {
+	blockTime := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	decimals := 0
+	return parser.Transaction{
+		Hash:                "0x5c504ed432cb51138bcf09aa5e8a410dd4a1e204ef84bfed1be16dfba1b22060",
+		From:                "0xa7d9ddbe1f17865597fbd27ec712455208b6b76d",
+		To:                  "0xf02c1c8e6114b1dbe8937a39260b5b0a374432bb",
+		Value:               "0xf3dbb76162000",
+		BlockNumber:         "0x12d687",
+		BlockTime:           &blockTime,
+		Type:                "0x3",
+		Nonce:               "0x0",
+		TransactionIndex:    "0x1a",
+		BlockHash:           "0x4e3a3754410177e6937ef1f84bba68ea139e8d1a2258c5f85db9f1cd715a1bdd",
+		Input:               "0x",
+		EventID:             "1234567:26",
+		MaxFeePerGas:        "0x2540be400",
+		MaxFeePerBlobGas:    "0x3b9aca00",
+		BlobVersionedHashes: []string{"0x01b0761f87b081d5cf10757ccc89f12be355c70e2e29df288b65b30710dcbcd1"},
+		BlobTransaction:     true,
+		Links:               &parser.ExplorerLinks{Transaction: "https://etherscan.io/tx/0x5c50"},
+		Category:            "token_transfer",
+		Token:               &parser.TokenMetadata{Address: "0xdac17f958d2ee523a2206206994597c13d831ec7", Symbol: "USDT", Decimals: &decimals},
+		ScreeningFlag:       &parser.ScreeningFlag{Counterparty: "0xf02c1c8e6114b1dbe8937a39260b5b0a374432bb", Screener: "denylist"},
+		DiscoveredBlock:     1234568,
+		Historical:          true,
+		Metadata:            json.RawMessage(`{"customer":"acme"}`),
+	}
+}
+
+func TestProtobufCodecRoundTrip(t *testing.T) {
+	codec := parser.ProtobufCodec{}
+	tx := codecTransaction()
+	data, err := codec.Marshal(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded parser.Transaction
+	if err := codec.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, tx) {
+		t.Fatalf("Expected %+v, got %+v", tx, decoded)
+	}
+
+	// The values that aren't canonical hex are kept as is
+	fixture := parser.Transaction{Hash: "0xABC", From: "0x1", To: "alice", Value: "0x0064", BlockNumber: "0x0", BlobVersionedHashes: []string{"0x01", "0x2"}}
+	data, _ = codec.Marshal(&fixture)
+	decoded = parser.Transaction{}
+	if err := codec.Unmarshal(data, &decoded); err != nil || !reflect.DeepEqual(decoded, fixture) {
+		t.Fatalf("Expected %+v, got %+v, %v", fixture, decoded, err)
+	}
+
+	block := parser.Block{Number: "0x12d687", Hash: tx.BlockHash, Timestamp: "0x66321360", Transactions: []parser.Transaction{tx, fixture}}
+	data, _ = codec.Marshal(block)
+	var decodedBlock parser.Block
+	if err := codec.Unmarshal(data, &decodedBlock); err != nil || !reflect.DeepEqual(decodedBlock, block) {
+		t.Fatalf("Expected %+v, got %+v, %v", block, decodedBlock, err)
+	}
+
+	events := []parser.OutboxEvent{{ID: "1:0xabc", Address: "0xabc", BlockNumber: 1, Transactions: []parser.Transaction{tx}}, {ID: "2:0xabc", Address: "0xabc", BlockNumber: 2}}
+	data, _ = codec.Marshal(events)
+	var decodedEvents []parser.OutboxEvent
+	if err := codec.Unmarshal(data, &decodedEvents); err != nil || !reflect.DeepEqual(decodedEvents, events) {
+		t.Fatalf("Expected %+v, got %+v, %v", events, decodedEvents, err)
+	}
+}
+
+func TestProtobufCodecSize(t *testing.T) {
+	tx := codecTransaction()
+	tx.Links, tx.Token, tx.ScreeningFlag, tx.Metadata = nil, nil, nil, nil
+	tx.Input = "0xa9059cbb000000000000000000000000f02c1c8e6114b1dbe8937a39260b5b0a374432bb00000000000000000000000000000000000000000000000000000000000f4240"
+	jsonData, _ := parser.JSONCodec{}.Marshal(tx)
+	protobufData, _ := parser.ProtobufCodec{}.Marshal(tx)
+	if len(protobufData)*2 > len(jsonData) {
+		t.Fatalf("Expected the protobuf encoding to be at least twice smaller, got %d bytes against %d", len(protobufData), len(jsonData))
+	}
+}
+
+func TestProtobufCodecErrors(t *testing.T) {
+	codec, err := parser.CodecByName("protobuf")
+	if err != nil || codec.ContentType() != parser.ProtobufContentType {
+		t.Fatalf("Expected the protobuf codec, got %v, %v", codec, err)
+	}
+	if _, err := parser.CodecByName("xml"); err == nil {
+		t.Error("Expected an unknown codec to be rejected")
+	}
+	if _, err := codec.Marshal(map[string]string{}); !errors.Is(err, parser.ErrUnsupportedMessage) {
+		t.Errorf("Expected ErrUnsupportedMessage, got %v", err)
+	}
+	data, _ := codec.Marshal(codecTransaction())
+	var tx parser.Transaction
+	if err := codec.Unmarshal(data[:len(data)-3], &tx); err == nil {
+		t.Error("Expected a truncated message to be rejected")
+	}
+}
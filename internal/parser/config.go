@@ -0,0 +1,172 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrInvalidConfig is returned by UpdateConfig for a value out of its range
+var ErrInvalidConfig = errors.New("invalid config")
+
+// RuntimeConfig are the knobs of the parser tunable while it runs, e.g. to adapt to the conditions of the
+// provider during an incident without a restart losing the catch-up progress, see UpdateConfig
+type RuntimeConfig struct {
+	// FetchWorkers is the number of concurrent block downloads of the prefetcher, see WithFetchWorkers
+	FetchWorkers int `json:"fetchWorkers"`
+	// PrefetchDepth is the number of blocks downloaded ahead of the processing, zero disables the prefetching,
+	// see WithPrefetch
+	PrefetchDepth int `json:"prefetchDepth"`
+	// MaxBlocksPerCycle is the block batch size of a fetch cycle, zero for no bound, see WithBackpressure
+	MaxBlocksPerCycle int `json:"maxBlocksPerCycle"`
+	// RPCRateLimit caps the node requests of the parser per second, zero for no limit, see WithRPCRateLimit
+	RPCRateLimit float64 `json:"rpcRateLimit"`
+	// NotificationBatchSize is the number of outbox events read per dispatch round, see WithNotificationBatchSize
+	NotificationBatchSize int `json:"notificationBatchSize"`
+}
+
+// RuntimeConfigUpdate changes the set fields of the RuntimeConfig, the others are kept
+type RuntimeConfigUpdate struct {
+	FetchWorkers          *int     `json:"fetchWorkers,omitempty"`
+	PrefetchDepth         *int     `json:"prefetchDepth,omitempty"`
+	MaxBlocksPerCycle     *int     `json:"maxBlocksPerCycle,omitempty"`
+	RPCRateLimit          *float64 `json:"rpcRateLimit,omitempty"`
+	NotificationBatchSize *int     `json:"notificationBatchSize,omitempty"`
+}
+
+// Config returns the current RuntimeConfig
+func (p *EthParser) Config() RuntimeConfig {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return RuntimeConfig{
+		FetchWorkers:          p.fetchWorkers,
+		PrefetchDepth:         p.prefetchDepth,
+		MaxBlocksPerCycle:     p.maxBlocksPerCycle,
+		RPCRateLimit:          p.rpcLimiter.rate(),
+		NotificationBatchSize: p.outboxBatch,
+	}
+}
+
+// UpdateConfig applies an update of the RuntimeConfig and returns the new one. The update is rejected as a
+// whole with ErrInvalidConfig when a value is out of its range. The block settings apply from the next fetch
+// cycle, the rate limit from the next request and the batch size from the next dispatch round.
+func (p *EthParser) UpdateConfig(update RuntimeConfigUpdate) (RuntimeConfig, error) {
+	switch {
+	case update.FetchWorkers != nil && *update.FetchWorkers < 1:
+		return RuntimeConfig{}, fmt.Errorf("%w: fetchWorkers must be at least 1", ErrInvalidConfig)
+	case update.PrefetchDepth != nil && *update.PrefetchDepth < 0:
+		return RuntimeConfig{}, fmt.Errorf("%w: prefetchDepth must not be negative", ErrInvalidConfig)
+	case update.MaxBlocksPerCycle != nil && *update.MaxBlocksPerCycle < 0:
+		return RuntimeConfig{}, fmt.Errorf("%w: maxBlocksPerCycle must not be negative", ErrInvalidConfig)
+	case update.RPCRateLimit != nil && *update.RPCRateLimit < 0:
+		return RuntimeConfig{}, fmt.Errorf("%w: rpcRateLimit must not be negative", ErrInvalidConfig)
+	case update.NotificationBatchSize != nil && *update.NotificationBatchSize < 1:
+		return RuntimeConfig{}, fmt.Errorf("%w: notificationBatchSize must be at least 1", ErrInvalidConfig)
+	}
+
+	p.mu.Lock()
+	if update.FetchWorkers != nil {
+		p.fetchWorkers = *update.FetchWorkers
+	}
+	if update.PrefetchDepth != nil {
+		p.prefetchDepth = *update.PrefetchDepth
+	}
+	if update.MaxBlocksPerCycle != nil {
+		p.maxBlocksPerCycle = *update.MaxBlocksPerCycle
+	}
+	if update.NotificationBatchSize != nil {
+		p.outboxBatch = *update.NotificationBatchSize
+	}
+	p.mu.Unlock()
+	if update.RPCRateLimit != nil {
+		p.rpcLimiter.setRate(*update.RPCRateLimit)
+	}
+
+	config := p.Config()
+	log.Printf("Runtime config updated: %+v\n", config)
+	return config, nil
+}
+
+// rateLimitedClient is the JsonRpcClient decorator spacing the node requests of the parser so that they stay
+// within a rate, which can be changed while running. A request rejected by a provider rate limit pauses all
+// the requests for its Retry-After, they fail meanwhile with a RateLimitError.
+type rateLimitedClient struct {
+	client      JsonRpcClient
+	clock       Clock
+	mu          sync.Mutex
+	perSec      float64
+	interval    time.Duration // between two requests, zero when unlimited
+	next        time.Time     // earliest time of the next request
+	pausedTill  time.Time
+	rateLimited int
+}
+
+// SendRequest waits for the turn of the request, then sends it to the wrapped client
+func (c *rateLimitedClient) SendRequest(req JSONRPCRequest) (JSONRPCResponse, error) {
+	c.mu.Lock()
+	if paused := c.pausedTill.Sub(c.clock.Now()); paused > 0 {
+		c.mu.Unlock()
+		return JSONRPCResponse{}, &RateLimitError{RetryAfter: paused}
+	}
+	now := time.Now()
+	at := now
+	if c.interval > 0 {
+		if c.next.After(now) {
+			at = c.next
+		}
+		c.next = at.Add(c.interval)
+	}
+	c.mu.Unlock()
+	if wait := at.Sub(now); wait > 0 {
+		time.Sleep(wait)
+	}
+	resp, err := c.client.SendRequest(req)
+	if retryAfter, ok := rateLimitRetryAfter(err); ok {
+		c.mu.Lock()
+		c.rateLimited++
+		if until := c.clock.Now().Add(retryAfter); until.After(c.pausedTill) {
+			c.pausedTill = until
+		}
+		c.mu.Unlock()
+	}
+	return resp, err
+}
+
+// throttleState returns the pause after a rate limit, without the deferred blocks
+func (c *rateLimitedClient) throttleState() ThrottleState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state := ThrottleState{RateLimited: c.rateLimited}
+	if c.pausedTill.After(c.clock.Now()) {
+		until := c.pausedTill
+		state.Throttled, state.Until = true, &until
+	}
+	return state
+}
+
+// CancelRequests aborts the in-flight requests of the wrapped client, see RequestCanceler
+func (c *rateLimitedClient) CancelRequests() {
+	if canceler, ok := c.client.(RequestCanceler); ok {
+		canceler.CancelRequests()
+	}
+}
+
+// setRate sets the requests per second, zero removes the limit
+func (c *rateLimitedClient) setRate(perSec float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.perSec = perSec
+	c.interval = 0
+	if perSec > 0 {
+		c.interval = time.Duration(float64(time.Second) / perSec)
+	}
+}
+
+// rate returns the requests per second, zero when unlimited
+func (c *rateLimitedClient) rate() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.perSec
+}
@@ -0,0 +1,147 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// concurrencyClient records the maximum of concurrent block requests, holding each one until wantInFlight of
+// them are in flight or a second passed
+type concurrencyClient struct {
+	*MockClient
+	wantInFlight int
+	mu           sync.Mutex
+	inFlight     int
+	maxInFlight  int
+	times        []time.Time
+}
+
+func (c *concurrencyClient) SendRequest(req parser.JSONRPCRequest) (parser.JSONRPCResponse, error) {
+	c.mu.Lock()
+	c.times = append(c.times, time.Now())
+	c.mu.Unlock()
+	if req.Method != "eth_getBlockByNumber" || c.wantInFlight == 0 {
+		return c.MockClient.SendRequest(req)
+	}
+	c.mu.Lock()
+	c.inFlight++
+	c.maxInFlight = max(c.maxInFlight, c.inFlight)
+	c.mu.Unlock()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		c.mu.Lock()
+		reached := c.maxInFlight >= c.wantInFlight
+		c.mu.Unlock()
+		if reached {
+			break
+		}
+	}
+	defer func() {
+		c.mu.Lock()
+		c.inFlight--
+		c.mu.Unlock()
+	}()
+	return c.MockClient.SendRequest(req)
+}
+
+func TestUpdateConfig(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockChain(30)), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithBackpressure(4, 0))
+	defer ethParser.WaitForShutdown()
+
+	initial := ethParser.Config()
+	if initial.FetchWorkers != 1 || initial.MaxBlocksPerCycle != 4 || initial.NotificationBatchSize != 100 || initial.RPCRateLimit != 0 {
+		t.Fatalf("Unexpected initial config %+v", initial)
+	}
+	// The parser starts 10 blocks behind the head
+	ethParser.ProcessNextCycle()
+	if last := ethParser.BackpressureStats().LastProcessedBlock; last != 24 {
+		t.Fatalf("Expected the first cycle to stop at block 24, got %d", last)
+	}
+
+	// An invalid value rejects the whole update
+	workers, negative := 4, -1
+	if _, err := ethParser.UpdateConfig(parser.RuntimeConfigUpdate{FetchWorkers: &workers, MaxBlocksPerCycle: &negative}); !errors.Is(err, parser.ErrInvalidConfig) {
+		t.Fatalf("Expected ErrInvalidConfig, got %v", err)
+	}
+	if config := ethParser.Config(); config != initial {
+		t.Fatalf("Expected the config to be unchanged, got %+v", config)
+	}
+
+	blocks := 2
+	config, err := ethParser.UpdateConfig(parser.RuntimeConfigUpdate{MaxBlocksPerCycle: &blocks})
+	if err != nil || config.MaxBlocksPerCycle != 2 || config.FetchWorkers != 1 {
+		t.Fatalf("Expected only the block batch size to change, got %+v, %v", config, err)
+	}
+	ethParser.ProcessNextCycle()
+	if last := ethParser.BackpressureStats().LastProcessedBlock; last != 26 {
+		t.Errorf("Expected the next cycle to process 2 blocks, got up to %d", last)
+	}
+}
+
+func TestFetchWorkersDownloadConcurrently(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := &concurrencyClient{MockClient: NewMockClient(mockChain(12)), wantInFlight: 3}
+	var processed []string
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, client, func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithPrefetch(8), parser.WithFetchWorkers(3),
+		parser.WithBlockProcessor("order", parser.BlockProcessorFunc(func(ctx context.Context, block parser.Block) error {
+			processed = append(processed, block.Number)
+			return nil
+		})))
+	defer ethParser.WaitForShutdown()
+	ethParser.ProcessNextCycle()
+
+	if client.maxInFlight < 3 {
+		t.Errorf("Expected 3 concurrent downloads, got %d", client.maxInFlight)
+	}
+	// The parser starts 10 blocks behind the head
+	if len(processed) != 10 {
+		t.Fatalf("Expected 10 processed blocks, got %v", processed)
+	}
+	for i, number := range processed {
+		if want := fmt.Sprintf("0x%x", i+3); number != want {
+			t.Fatalf("Expected the blocks in order, got %v", processed)
+		}
+	}
+}
+
+func TestRPCRateLimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := &concurrencyClient{MockClient: NewMockClient(mockChain(3))}
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, client, func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+
+	rate := 50.0
+	if _, err := ethParser.UpdateConfig(parser.RuntimeConfigUpdate{RPCRateLimit: &rate}); err != nil {
+		t.Fatal(err)
+	}
+	client.mu.Lock()
+	client.times = nil
+	client.mu.Unlock()
+	ethParser.ProcessNextCycle()
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if len(client.times) < 3 {
+		t.Fatalf("Expected several requests, got %d", len(client.times))
+	}
+	for i := 1; i < len(client.times); i++ {
+		// 20ms between two requests, with some slack for the timer
+		if gap := client.times[i].Sub(client.times[i-1]); gap < 15*time.Millisecond {
+			t.Errorf("Expected the requests to be spaced by the rate limit, got %s between requests %d and %d", gap, i-1, i)
+		}
+	}
+}
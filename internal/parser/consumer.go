@@ -0,0 +1,456 @@
+package parser
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrConsumersDisabled is returned by the consumer methods when no ConsumerStore is configured
+	ErrConsumersDisabled = errors.New("consumers are disabled")
+	// ErrUnknownConsumer is returned for a consumer that isn't registered
+	ErrUnknownConsumer = errors.New("unknown consumer")
+	// ErrInvalidConsumer is returned when registering a consumer with an invalid name or address
+	ErrInvalidConsumer = errors.New("invalid consumer")
+)
+
+// consumerName is the format of a consumer name, used in the query strings and the storage keys
+var consumerName = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// Consumer is a client pulling the notifications instead of receiving them by webhook. Every event delivered
+// after its registration is retained for it until it acknowledges it.
+type Consumer struct {
+	Name string `json:"name"`
+	// Tenant is the tenant owning the consumer, whose names are unique per tenant. A tenant consumer only
+	// receives the events of the addresses the tenant subscribes to.
+	Tenant string `json:"tenant,omitempty"`
+	// Addresses restricts the consumer to the events of these addresses, all the subscribed addresses when empty
+	Addresses    []string  `json:"addresses,omitempty"`
+	RegisteredAt time.Time `json:"registeredAt"`
+}
+
+// Key identifies the consumer in the ConsumerStore: its name, prefixed with its tenant ID and a slash for the
+// consumers of a tenant, which the names can't contain
+func (c Consumer) Key() string {
+	return consumerKey(c.Tenant, c.Name)
+}
+
+// consumerKey returns the Key of the consumer of a tenant, the tenant being empty without multi-tenancy
+func consumerKey(tenant string, name string) string {
+	if tenant == "" {
+		return name
+	}
+	return tenant + "/" + name
+}
+
+// wants reports whether the consumer receives the events of an address
+func (c Consumer) wants(address string) bool {
+	if len(c.Addresses) == 0 {
+		return true
+	}
+	for _, candidate := range c.Addresses {
+		if candidate == address {
+			return true
+		}
+	}
+	return false
+}
+
+// ConsumerStore persists the consumers and their unacknowledged events, so that a restart doesn't lose them.
+// The consumers are identified by their Key.
+type ConsumerStore interface {
+	// SaveConsumer saves a consumer, replacing the one of the same key and keeping its events
+	SaveConsumer(consumer Consumer) error
+	Consumers() ([]Consumer, error)
+	// DeleteConsumer removes a consumer and its events
+	DeleteConsumer(name string) error
+	// AddConsumerEvent retains an event for a consumer, replacing the one with the same ID in place
+	AddConsumerEvent(consumer string, event OutboxEvent) error
+	// ConsumerEvents returns up to limit events of a consumer, the oldest first
+	ConsumerEvents(consumer string, limit int) ([]OutboxEvent, error)
+	// AckConsumerEvents removes events of a consumer, the unknown IDs are ignored
+	AckConsumerEvents(consumer string, ids []string) error
+	// TrimConsumerEvents drops the oldest events of a consumer beyond keep and returns how many were dropped
+	TrimConsumerEvents(consumer string, keep int) (int, error)
+}
+
+// loadConsumers reads the consumers registered before a restart from the ConsumerStore
+func (p *EthParser) loadConsumers() {
+	if p.consumers == nil {
+		return
+	}
+	consumers, err := p.consumers.Consumers()
+	if err != nil {
+		log.Println("Error loading the consumers:", err)
+		return
+	}
+	for _, consumer := range consumers {
+		p.consumerSet[consumer.Key()] = consumer
+	}
+}
+
+// RegisterConsumer registers a consumer, or updates the addresses of a registered one keeping its events
+func (p *EthParser) RegisterConsumer(consumer Consumer) (Consumer, error) {
+	if p.consumers == nil {
+		return Consumer{}, ErrConsumersDisabled
+	}
+	if !consumerName.MatchString(consumer.Name) {
+		return Consumer{}, fmt.Errorf("%w: the name must have 1 to 64 letters, digits, '_', '.' or '-'", ErrInvalidConsumer)
+	}
+	addresses := make([]string, 0, len(consumer.Addresses))
+	for _, address := range consumer.Addresses {
+		if len(address) != 42 || !strings.HasPrefix(address, "0x") {
+			return Consumer{}, fmt.Errorf("%w: invalid address %q", ErrInvalidConsumer, address)
+		}
+		addresses = append(addresses, strings.ToLower(address))
+	}
+	consumer.Addresses = addresses
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if registered, exists := p.consumerSet[consumer.Key()]; exists {
+		consumer.RegisteredAt = registered.RegisteredAt
+	} else {
+		consumer.RegisteredAt = p.clock.Now()
+	}
+	if err := p.recordStorageWrite(p.consumers.SaveConsumer(consumer)); err != nil {
+		return Consumer{}, err
+	}
+	p.consumerSet[consumer.Key()] = consumer
+	return consumer, nil
+}
+
+// Consumers returns the registered consumers, the ones of the tenants included
+func (p *EthParser) Consumers() ([]Consumer, error) {
+	if p.consumers == nil {
+		return []Consumer{}, nil
+	}
+	return p.consumers.Consumers()
+}
+
+// UnregisterConsumer removes a consumer and drops its unacknowledged events, name being the Key of the
+// consumers of a tenant
+func (p *EthParser) UnregisterConsumer(name string) error {
+	if p.consumers == nil {
+		return ErrConsumersDisabled
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, exists := p.consumerSet[name]; !exists {
+		return ErrUnknownConsumer
+	}
+	if err := p.recordStorageWrite(p.consumers.DeleteConsumer(name)); err != nil {
+		return err
+	}
+	delete(p.consumerSet, name)
+	return nil
+}
+
+// PullConsumerEvents returns up to max unacknowledged events of a consumer, the oldest first. The same events
+// are returned until they're acknowledged with AckConsumerEvents, so a consumer crashing while processing
+// them gets them again: the delivery is at-least-once, the event IDs tell the duplicates.
+func (p *EthParser) PullConsumerEvents(name string, max int) ([]OutboxEvent, error) {
+	if err := p.checkConsumer(name); err != nil {
+		return nil, err
+	}
+	events, err := p.consumers.ConsumerEvents(name, max)
+	if err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []OutboxEvent{}
+	}
+	return events, nil
+}
+
+// AckConsumerEvents acknowledges processed events of a consumer, which are then no longer retained for it
+func (p *EthParser) AckConsumerEvents(name string, ids []string) error {
+	if err := p.checkConsumer(name); err != nil {
+		return err
+	}
+	return p.recordStorageWrite(p.consumers.AckConsumerEvents(name, ids))
+}
+
+// checkConsumer returns an error unless the consumers are enabled and the consumer is registered
+func (p *EthParser) checkConsumer(name string) error {
+	if p.consumers == nil {
+		return ErrConsumersDisabled
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, exists := p.consumerSet[name]; !exists {
+		return ErrUnknownConsumer
+	}
+	return nil
+}
+
+// retainForConsumers retains an outbox event for the consumers of its address. When a consumer falls behind
+// by more than the retention its oldest events are dropped, so that it can't grow the storage without bound.
+func (p *EthParser) retainForConsumers(event OutboxEvent) {
+	if p.consumers == nil {
+		return
+	}
+	p.mu.Lock()
+	var candidates []Consumer
+	for _, consumer := range p.consumerSet {
+		if consumer.wants(event.Address) {
+			candidates = append(candidates, consumer)
+		}
+	}
+	retention := p.consumerKeep
+	tenants := p.tenants
+	p.mu.Unlock()
+
+	for _, consumer := range candidates {
+		// The consumers of a tenant only get the events of its own addresses
+		if consumer.Tenant != "" && (tenants == nil || !tenants.subscribes(consumer.Tenant, event.Address)) {
+			continue
+		}
+		name := consumer.Key()
+		if err := p.recordStorageWrite(p.consumers.AddConsumerEvent(name, event)); err != nil {
+			log.Printf("Error retaining event %s for consumer %s: %v\n", event.ID, name, err)
+			continue
+		}
+		if retention <= 0 {
+			continue
+		}
+		dropped, err := p.consumers.TrimConsumerEvents(name, retention)
+		if err != nil {
+			log.Printf("Error trimming the events of consumer %s: %v\n", name, err)
+		} else if dropped > 0 {
+			log.Printf("Dropped the %d oldest events of consumer %s, more than %d are unacknowledged\n", dropped, name, retention)
+		}
+	}
+}
+
+// SaveConsumer saves a consumer in memory
+func (s *MemoryStorage) SaveConsumer(consumer Consumer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.consumers[consumer.Key()]; !exists {
+		s.consumerOrder = append(s.consumerOrder, consumer.Key())
+	}
+	s.consumers[consumer.Key()] = consumer
+	return nil
+}
+
+// Consumers returns the consumers in registration order
+func (s *MemoryStorage) Consumers() ([]Consumer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	consumers := make([]Consumer, 0, len(s.consumerOrder))
+	for _, name := range s.consumerOrder {
+		consumers = append(consumers, s.consumers[name])
+	}
+	return consumers, nil
+}
+
+// DeleteConsumer removes a consumer and its events
+func (s *MemoryStorage) DeleteConsumer(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.consumers, name)
+	delete(s.consumerEvents, name)
+	for i, candidate := range s.consumerOrder {
+		if candidate == name {
+			s.consumerOrder = append(s.consumerOrder[:i], s.consumerOrder[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// AddConsumerEvent retains an event for a consumer, replacing the one with the same ID in place
+func (s *MemoryStorage) AddConsumerEvent(consumer string, event OutboxEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := s.consumerEvents[consumer]
+	for i := range events {
+		if events[i].ID == event.ID {
+			events[i] = event
+			return nil
+		}
+	}
+	s.consumerEvents[consumer] = append(events, event)
+	return nil
+}
+
+// ConsumerEvents returns up to limit events of a consumer, the oldest first
+func (s *MemoryStorage) ConsumerEvents(consumer string, limit int) ([]OutboxEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	events := s.consumerEvents[consumer]
+	if limit < len(events) {
+		events = events[:limit]
+	}
+	return append([]OutboxEvent(nil), events...), nil
+}
+
+// AckConsumerEvents removes events of a consumer
+func (s *MemoryStorage) AckConsumerEvents(consumer string, ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acked := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		acked[id] = true
+	}
+	kept := s.consumerEvents[consumer][:0]
+	for _, event := range s.consumerEvents[consumer] {
+		if !acked[event.ID] {
+			kept = append(kept, event)
+		}
+	}
+	s.consumerEvents[consumer] = kept
+	return nil
+}
+
+// TrimConsumerEvents drops the oldest events of a consumer beyond keep
+func (s *MemoryStorage) TrimConsumerEvents(consumer string, keep int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := s.consumerEvents[consumer]
+	if len(events) <= keep {
+		return 0, nil
+	}
+	dropped := len(events) - keep
+	s.consumerEvents[consumer] = append([]OutboxEvent(nil), events[dropped:]...)
+	return dropped, nil
+}
+
+// SaveConsumer upserts a consumer
+func (s *SQLStorage) SaveConsumer(consumer Consumer) error {
+	payload, err := json.Marshal(consumer)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO consumers (name, registered_at, payload) VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE SET payload = excluded.payload`,
+		consumer.Key(), consumer.RegisteredAt.UnixNano(), string(payload))
+	return err
+}
+
+// Consumers returns the consumers in registration order
+func (s *SQLStorage) Consumers() ([]Consumer, error) {
+	rows, err := s.db.Query(`SELECT payload FROM consumers ORDER BY registered_at, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	consumers := []Consumer{}
+	for rows.Next() {
+		var payload string
+		if err := rows.Scan(&payload); err != nil {
+			return nil, err
+		}
+		var consumer Consumer
+		if err := json.Unmarshal([]byte(payload), &consumer); err != nil {
+			return nil, err
+		}
+		consumers = append(consumers, consumer)
+	}
+	return consumers, rows.Err()
+}
+
+// DeleteConsumer removes a consumer and its events
+func (s *SQLStorage) DeleteConsumer(name string) error {
+	dbTx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer dbTx.Rollback()
+
+	if _, err := dbTx.Exec(`DELETE FROM consumer_events WHERE consumer = $1`, name); err != nil {
+		return err
+	}
+	if _, err := dbTx.Exec(`DELETE FROM consumers WHERE name = $1`, name); err != nil {
+		return err
+	}
+	return dbTx.Commit()
+}
+
+// AddConsumerEvent appends an event to the events of a consumer, a retained event with the same ID keeps its
+// position
+func (s *SQLStorage) AddConsumerEvent(consumer string, event OutboxEvent) error {
+	payload, err := marshalStored(s.codec, event)
+	if err != nil {
+		return err
+	}
+	sealed, err := sealField(s.cipher, payload, "consumer_events/"+consumer+"/"+event.ID)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO consumer_events (consumer, id, seq, payload)
+		VALUES ($1, $2, (SELECT COALESCE(MAX(seq), 0) + 1 FROM consumer_events WHERE consumer = $1), $3)
+		ON CONFLICT (consumer, id) DO UPDATE SET payload = excluded.payload`,
+		consumer, event.ID, sealed)
+	return err
+}
+
+// ConsumerEvents returns up to limit events of a consumer, the oldest first
+func (s *SQLStorage) ConsumerEvents(consumer string, limit int) ([]OutboxEvent, error) {
+	rows, err := s.db.Query(`SELECT id, payload FROM consumer_events WHERE consumer = $1 ORDER BY seq LIMIT $2`, consumer, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []OutboxEvent
+	for rows.Next() {
+		var id, payload string
+		if err := rows.Scan(&id, &payload); err != nil {
+			return nil, err
+		}
+		payload, err := openField(s.cipher, payload, "consumer_events/"+consumer+"/"+id)
+		if err != nil {
+			return nil, err
+		}
+		var event OutboxEvent
+		if err := unmarshalStored(payload, &event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// AckConsumerEvents deletes acknowledged events of a consumer
+func (s *SQLStorage) AckConsumerEvents(consumer string, ids []string) error {
+	dbTx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer dbTx.Rollback()
+
+	for _, id := range ids {
+		if _, err := dbTx.Exec(`DELETE FROM consumer_events WHERE consumer = $1 AND id = $2`, consumer, id); err != nil {
+			return err
+		}
+	}
+	return dbTx.Commit()
+}
+
+// TrimConsumerEvents deletes the oldest events of a consumer beyond keep
+func (s *SQLStorage) TrimConsumerEvents(consumer string, keep int) (int, error) {
+	var newest int64
+	err := s.db.QueryRow(`SELECT seq FROM consumer_events WHERE consumer = $1 ORDER BY seq DESC LIMIT 1 OFFSET $2`, consumer, keep).
+		Scan(&newest)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	result, err := s.db.Exec(`DELETE FROM consumer_events WHERE consumer = $1 AND seq <= $2`, consumer, newest)
+	if err != nil {
+		return 0, err
+	}
+	dropped, err := result.RowsAffected()
+	return int(dropped), err
+}
@@ -0,0 +1,171 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+const (
+	consumerAddressA = "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	consumerAddressB = "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+)
+
+// consumerChain sends a transaction to A in block 1 and to A and B in block 2
+func consumerChain() *MockBlockchain {
+	blockchain := NewMockBlockchain()
+	blockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{{Hash: "0xa1", From: "0x1", To: consumerAddressA, Value: "0x1"}}})
+	blockchain.AddBlock(2, parser.Block{Number: "0x2", Transactions: []parser.Transaction{
+		{Hash: "0xa2", From: "0x1", To: consumerAddressA, Value: "0x1"},
+		{Hash: "0xb2", From: "0x1", To: consumerAddressB, Value: "0x1"},
+	}})
+	return blockchain
+}
+
+func TestConsumerPullAndAck(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := parser.NewMemoryStorage()
+	ethParser := parser.NewEthParser(ctx, storage, 1, NewMockClient(consumerChain()), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithConsumers(storage, 0))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe(consumerAddressA)
+	ethParser.Subscribe(consumerAddressB)
+
+	if _, err := ethParser.RegisterConsumer(parser.Consumer{Name: "all"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ethParser.RegisterConsumer(parser.Consumer{Name: "only-b", Addresses: []string{"0xBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB"}}); err != nil {
+		t.Fatal(err)
+	}
+	ethParser.ProcessNextCycle()
+
+	events, err := ethParser.PullConsumerEvents("all", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].ID != "1:"+consumerAddressA || events[1].BlockNumber != 2 {
+		t.Fatalf("Expected the first 2 events oldest first, got %+v", events)
+	}
+	if err := ethParser.AckConsumerEvents("all", []string{events[0].ID, events[1].ID}); err != nil {
+		t.Fatal(err)
+	}
+	events, _ = ethParser.PullConsumerEvents("all", 100)
+	if len(events) != 1 {
+		t.Fatalf("Expected the unacknowledged event only, got %+v", events)
+	}
+	// An event stays until it's acknowledged
+	if again, _ := ethParser.PullConsumerEvents("all", 100); len(again) != 1 || again[0].ID != events[0].ID {
+		t.Fatalf("Expected the same event again, got %+v", again)
+	}
+
+	events, _ = ethParser.PullConsumerEvents("only-b", 100)
+	if len(events) != 1 || events[0].Address != consumerAddressB || events[0].Transactions[0].Hash != "0xb2" {
+		t.Fatalf("Expected the event of B only, got %+v", events)
+	}
+
+	// A restarted parser keeps the consumers and their events
+	restarted := parser.New(storage, 1, NewMockClient(consumerChain()), func(string, []parser.Transaction) {}, parser.WithConsumers(storage, 0))
+	if events, err := restarted.PullConsumerEvents("only-b", 100); err != nil || len(events) != 1 {
+		t.Fatalf("Expected the retained event after a restart, got %+v, %v", events, err)
+	}
+
+	if err := ethParser.UnregisterConsumer("only-b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ethParser.PullConsumerEvents("only-b", 100); !errors.Is(err, parser.ErrUnknownConsumer) {
+		t.Fatalf("Expected ErrUnknownConsumer, got %v", err)
+	}
+}
+
+func TestConsumerRetention(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := parser.NewMemoryStorage()
+	ethParser := parser.NewEthParser(ctx, storage, 1, NewMockClient(consumerChain()), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithConsumers(storage, 2))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe(consumerAddressA)
+	ethParser.Subscribe(consumerAddressB)
+	ethParser.RegisterConsumer(parser.Consumer{Name: "slow"})
+	ethParser.ProcessNextCycle()
+
+	events, _ := ethParser.PullConsumerEvents("slow", 100)
+	if len(events) != 2 || events[0].BlockNumber != 2 {
+		t.Fatalf("Expected the oldest event to be dropped, got %+v", events)
+	}
+}
+
+func TestConsumerValidation(t *testing.T) {
+	storage := parser.NewMemoryStorage()
+	disabled := parser.New(storage, 1, NewMockClient(consumerChain()), func(string, []parser.Transaction) {})
+	if _, err := disabled.RegisterConsumer(parser.Consumer{Name: "all"}); !errors.Is(err, parser.ErrConsumersDisabled) {
+		t.Fatalf("Expected ErrConsumersDisabled, got %v", err)
+	}
+
+	ethParser := parser.New(storage, 1, NewMockClient(consumerChain()), func(string, []parser.Transaction) {}, parser.WithConsumers(storage, 0))
+	for _, consumer := range []parser.Consumer{{Name: ""}, {Name: "a b"}, {Name: "ok", Addresses: []string{"0x1"}}} {
+		if _, err := ethParser.RegisterConsumer(consumer); !errors.Is(err, parser.ErrInvalidConsumer) {
+			t.Errorf("Expected ErrInvalidConsumer for %+v, got %v", consumer, err)
+		}
+	}
+}
+
+func TestTenantConsumers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := parser.NewMemoryStorage()
+	ethParser := parser.NewEthParser(ctx, storage, 1, NewMockClient(consumerChain()), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithConsumers(storage, 0))
+	defer ethParser.WaitForShutdown()
+	tenants := parser.NewTenantManager(ethParser)
+	tenants.CreateTenant("a", "Team A", 0)
+	tenants.CreateTenant("b", "Team B", 0)
+	teamA, teamB := tenants.View("a"), tenants.View("b")
+	teamA.Subscribe(consumerAddressA)
+	teamB.Subscribe(consumerAddressB)
+
+	// A tenant can't follow the addresses of another tenant
+	if _, err := teamA.RegisterConsumer(parser.Consumer{Name: "spy", Addresses: []string{consumerAddressB}}); !errors.Is(err, parser.ErrInvalidConsumer) {
+		t.Errorf("Expected ErrInvalidConsumer for an address of another tenant, got %v", err)
+	}
+	// The names are per tenant, and a consumer without addresses only gets the ones of its tenant
+	for _, tenant := range []*parser.TenantParser{teamA, teamB} {
+		if _, err := tenant.RegisterConsumer(parser.Consumer{Name: "billing"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ethParser.ProcessNextCycle()
+
+	eventsA, err := teamA.PullConsumerEvents("billing", 100)
+	if err != nil || len(eventsA) != 2 || eventsA[0].Address != consumerAddressA || eventsA[1].Address != consumerAddressA {
+		t.Fatalf("Expected the 2 events of A, got %+v, %v", eventsA, err)
+	}
+	eventsB, _ := teamB.PullConsumerEvents("billing", 100)
+	if len(eventsB) != 1 || eventsB[0].Address != consumerAddressB {
+		t.Fatalf("Expected the event of B, got %+v", eventsB)
+	}
+
+	// Acknowledging, listing and removing only reach the consumers of the tenant
+	teamA.AckConsumerEvents("billing", []string{eventsB[0].ID})
+	if events, _ := teamB.PullConsumerEvents("billing", 100); len(events) != 1 {
+		t.Errorf("Expected the event of B to stay, got %+v", events)
+	}
+	if consumers, _ := teamA.Consumers(); len(consumers) != 1 || consumers[0].Tenant != "a" {
+		t.Errorf("Expected the consumer of A only, got %+v", consumers)
+	}
+	if err := teamA.UnregisterConsumer("billing"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := teamB.PullConsumerEvents("billing", 100); err != nil {
+		t.Errorf("Expected the consumer of B to stay, got %v", err)
+	}
+	if _, err := teamA.PullConsumerEvents("b/billing", 100); !errors.Is(err, parser.ErrUnknownConsumer) {
+		t.Errorf("Expected ErrUnknownConsumer for the key of another tenant, got %v", err)
+	}
+}
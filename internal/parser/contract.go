@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// ERC20ABIName is the name of the bundled ERC-20 ABI, registered by default
+const ERC20ABIName = "erc20"
+
+// erc20ABI is the ABI of the read methods of the ERC-20 tokens
+const erc20ABI = `[
+	{"type": "function", "name": "name", "inputs": [], "outputs": [{"name": "", "type": "string"}], "stateMutability": "view"},
+	{"type": "function", "name": "symbol", "inputs": [], "outputs": [{"name": "", "type": "string"}], "stateMutability": "view"},
+	{"type": "function", "name": "decimals", "inputs": [], "outputs": [{"name": "", "type": "uint8"}], "stateMutability": "view"},
+	{"type": "function", "name": "totalSupply", "inputs": [], "outputs": [{"name": "", "type": "uint256"}], "stateMutability": "view"},
+	{"type": "function", "name": "balanceOf", "inputs": [{"name": "account", "type": "address"}], "outputs": [{"name": "", "type": "uint256"}], "stateMutability": "view"},
+	{"type": "function", "name": "allowance", "inputs": [{"name": "owner", "type": "address"}, {"name": "spender", "type": "address"}], "outputs": [{"name": "", "type": "uint256"}], "stateMutability": "view"}
+]`
+
+// bundledERC20ABI is the parsed erc20ABI
+var bundledERC20ABI = mustParseABI(erc20ABI)
+
+// ErrInvalidContractCall is returned for a contract call with an unknown ABI or method, or invalid arguments
+var ErrInvalidContractCall = errors.New("invalid contract call")
+
+// ContractCall is a read-only call of a contract method, run with eth_call
+type ContractCall struct {
+	Contract string `json:"contract"`
+	// ABI is the name of a registered ABI, see WithContractABI
+	ABI string `json:"abi"`
+	// Method is the method name, or its signature when it's overloaded
+	Method    string        `json:"method"`
+	Arguments []interface{} `json:"arguments,omitempty"`
+	// Block is a block number or tag, latest when empty
+	Block string `json:"block,omitempty"`
+}
+
+// ContractValue is a decoded output of a contract call
+type ContractValue struct {
+	Name  string      `json:"name,omitempty"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// ContractCallResult is the decoded result of a ContractCall
+type ContractCallResult struct {
+	Signature string          `json:"signature"`
+	Outputs   []ContractValue `json:"outputs"`
+	// Raw is the ABI-encoded result returned by the node
+	Raw string `json:"raw"`
+}
+
+// CallContract encodes the call of a registered ABI method, runs it with eth_call on the node and decodes
+// its outputs
+func (p *EthParser) CallContract(call ContractCall) (ContractCallResult, error) {
+	p.mu.Lock()
+	abi, ok := p.abis[call.ABI]
+	p.mu.Unlock()
+	if !ok {
+		return ContractCallResult{}, fmt.Errorf("%w: unknown ABI %q", ErrInvalidContractCall, call.ABI)
+	}
+	if len(call.Contract) != 42 || !isHexData(call.Contract) {
+		return ContractCallResult{}, fmt.Errorf("%w: invalid contract address %q", ErrInvalidContractCall, call.Contract)
+	}
+	method, err := abi.Method(call.Method, len(call.Arguments))
+	if err != nil {
+		return ContractCallResult{}, fmt.Errorf("%w: %v", ErrInvalidContractCall, err)
+	}
+	data, err := method.EncodeCall(call.Arguments)
+	if err != nil {
+		return ContractCallResult{}, fmt.Errorf("%w: %v", ErrInvalidContractCall, err)
+	}
+	block := call.Block
+	if block == "" {
+		block = "latest"
+	} else if number, err := strconv.ParseUint(block, 10, 64); err == nil {
+		block = fmt.Sprintf("0x%x", number)
+	}
+
+	raw, err := EthCall(p.client, call.Contract, data, block)
+	if err != nil {
+		return ContractCallResult{}, err
+	}
+	values, err := method.DecodeOutputs(raw)
+	if err != nil {
+		return ContractCallResult{}, fmt.Errorf("decoding the result of %s: %w", method.Signature(), err)
+	}
+	result := ContractCallResult{Signature: method.Signature(), Outputs: make([]ContractValue, len(values)), Raw: raw}
+	for i, value := range values {
+		result.Outputs[i] = ContractValue{Name: method.Outputs[i].Name, Type: method.Outputs[i].Type, Value: value}
+	}
+	return result, nil
+}
+
+// isHexData reports whether s is 0x-prefixed hex data
+func isHexData(s string) bool {
+	if len(s) < 2 || s[:2] != "0x" {
+		return false
+	}
+	_, err := hex.DecodeString(s[2:])
+	return err == nil
+}
+
+// LoadABIFile parses the JSON ABI file at path
+func LoadABIFile(path string) (*ContractABI, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseABI(data)
+}
+
+// mustParseABI parses a bundled ABI
+func mustParseABI(data string) *ContractABI {
+	abi, err := ParseABI([]byte(data))
+	if err != nil {
+		panic(err)
+	}
+	return abi
+}
@@ -0,0 +1,153 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tokenClient answers the eth_call requests of an ERC-20 token
+type tokenClient struct {
+	*MockClient
+	calls []string
+}
+
+func (c *tokenClient) SendRequest(req parser.JSONRPCRequest) (parser.JSONRPCResponse, error) {
+	if req.Method != "eth_call" {
+		return c.MockClient.SendRequest(req)
+	}
+	data := req.Params[0].(map[string]string)["data"]
+	c.calls = append(c.calls, data+"@"+req.Params[1].(string))
+	word := func(v int64) string { return fmt.Sprintf("%064x", v) }
+	switch data[:10] {
+	case "0x95d89b41": // symbol()
+		return parser.JSONRPCResponse{Result: "0x" + word(32) + word(4) + "55534443" + strings.Repeat("0", 56)}, nil
+	case "0x70a08231": // balanceOf(address)
+		return parser.JSONRPCResponse{Result: "0x" + word(1500000)}, nil
+	}
+	return parser.JSONRPCResponse{}, fmt.Errorf("execution reverted")
+}
+
+func TestEthParserCallContract(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := &tokenClient{MockClient: NewMockClient(NewMockBlockchain())}
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, client, func(string, []parser.Transaction) {})
+	defer ethParser.WaitForShutdown()
+	token := "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
+
+	symbol, err := ethParser.CallContract(parser.ContractCall{Contract: token, ABI: parser.ERC20ABIName, Method: "symbol"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if symbol.Signature != "symbol()" || len(symbol.Outputs) != 1 || symbol.Outputs[0].Value != "USDC" {
+		t.Errorf("Expected the USDC symbol, got %+v", symbol)
+	}
+
+	balance, err := ethParser.CallContract(parser.ContractCall{Contract: token, ABI: parser.ERC20ABIName, Method: "balanceOf",
+		Arguments: []interface{}{"0x000000000000000000000000000000000000dead"}, Block: "100"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if balance.Outputs[0].Value != "1500000" || balance.Outputs[0].Type != "uint256" {
+		t.Errorf("Expected a balance of 1500000, got %+v", balance)
+	}
+	expected := "0x70a08231000000000000000000000000000000000000000000000000000000000000dead@0x64"
+	if client.calls[1] != expected {
+		t.Errorf("Expected the call %s, got %s", expected, client.calls[1])
+	}
+
+	invalid := []parser.ContractCall{
+		{Contract: token, ABI: "unknown", Method: "symbol"},
+		{Contract: "0x1", ABI: parser.ERC20ABIName, Method: "symbol"},
+		{Contract: token, ABI: parser.ERC20ABIName, Method: "transfer"},
+		{Contract: token, ABI: parser.ERC20ABIName, Method: "balanceOf", Arguments: []interface{}{"0x1"}},
+	}
+	for _, call := range invalid {
+		if _, err := ethParser.CallContract(call); !errors.Is(err, parser.ErrInvalidContractCall) {
+			t.Errorf("Expected ErrInvalidContractCall for %+v, got %v", call, err)
+		}
+	}
+	if _, err := ethParser.CallContract(parser.ContractCall{Contract: token, ABI: parser.ERC20ABIName, Method: "decimals"}); err == nil {
+		t.Error("Expected the reverted call to fail")
+	}
+}
+
+func TestABIEncoding(t *testing.T) {
+	// The dynamic types example of the Solidity ABI specification
+	abi, err := parser.ParseABI([]byte(`[{"type": "function", "name": "f", "inputs": [
+		{"name": "a", "type": "uint256"}, {"name": "b", "type": "uint32[]"}, {"name": "c", "type": "bytes10"}, {"name": "d", "type": "bytes"}
+	], "outputs": [
+		{"name": "a", "type": "uint256"}, {"name": "b", "type": "uint32[]"}, {"name": "c", "type": "bytes10"}, {"name": "d", "type": "bytes"}
+	]}, {"type": "event", "name": "E", "inputs": []}]`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	method, err := abi.Method("f", 4)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	arguments := []interface{}{"0x123", []interface{}{"0x456", float64(0x789)}, "0x31323334353637383930", "0x48656c6c6f2c20776f726c6421"}
+	data, err := method.EncodeCall(arguments)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "0x8be65246" +
+		"0000000000000000000000000000000000000000000000000000000000000123" +
+		"0000000000000000000000000000000000000000000000000000000000000080" +
+		"3132333435363738393000000000000000000000000000000000000000000000" +
+		"00000000000000000000000000000000000000000000000000000000000000e0" +
+		"0000000000000000000000000000000000000000000000000000000000000002" +
+		"0000000000000000000000000000000000000000000000000000000000000456" +
+		"0000000000000000000000000000000000000000000000000000000000000789" +
+		"000000000000000000000000000000000000000000000000000000000000000d" +
+		"48656c6c6f2c20776f726c642100000000000000000000000000000000000000"
+	if data != expected {
+		t.Fatalf("Expected the call data\n%s, got\n%s", expected, data)
+	}
+
+	// The outputs have the same types, so the arguments decode back
+	decoded, err := method.DecodeOutputs("0x" + data[10:])
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := []interface{}{"291", []interface{}{"1110", "1929"}, "0x31323334353637383930", "0x48656c6c6f2c20776f726c6421"}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("Expected %v, got %v", want, decoded)
+	}
+}
+
+func TestABISignedIntegers(t *testing.T) {
+	abi, err := parser.ParseABI([]byte(`[{"type": "function", "name": "g", "inputs": [{"name": "x", "type": "int8"}, {"name": "flags", "type": "bool[2]"}],
+		"outputs": [{"name": "x", "type": "int8"}, {"name": "flags", "type": "bool[2]"}]}]`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	method, _ := abi.Method("g(int8,bool[2])", 0)
+	data, err := method.EncodeCall([]interface{}{"-2", []interface{}{true, false}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(data[10:], strings.Repeat("f", 63)+"e") {
+		t.Errorf("Expected -2 in two's complement, got %s", data)
+	}
+	decoded, err := method.DecodeOutputs("0x" + data[10:])
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := []interface{}{"-2", []interface{}{true, false}}; !reflect.DeepEqual(decoded, want) {
+		t.Errorf("Expected %v, got %v", want, decoded)
+	}
+
+	if _, err := method.EncodeCall([]interface{}{"128", []interface{}{true, false}}); err == nil {
+		t.Error("Expected an error for an int8 out of range")
+	}
+	if _, err := parser.ParseABI([]byte(`[{"type": "function", "name": "h", "inputs": [{"name": "t", "type": "tuple"}], "outputs": []}]`)); err == nil {
+		t.Error("Expected an error for an unsupported tuple type")
+	}
+}
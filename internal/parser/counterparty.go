@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"context"
+	"math/big"
+	"sort"
+)
+
+// StageCounterparties is the name of the pipeline stage aggregating the counterparties of the stored transactions
+const StageCounterparties = "counterparties"
+
+// Orders of the counterparties, see GetCounterparties
+const (
+	CounterpartyByCount = "count"
+	CounterpartyByValue = "value"
+)
+
+// Counterparty aggregates the transactions of a watched address with another address
+type Counterparty struct {
+	Address          string `json:"address"`
+	Label            string `json:"label,omitempty"`
+	TransactionCount int    `json:"transactionCount"`
+	Sent             int    `json:"sent"`       // transactions from the watched address to the counterparty
+	Received         int    `json:"received"`   // transactions from the counterparty to the watched address
+	TotalValue       string `json:"totalValue"` // decimal, in wei
+	FirstBlock       int    `json:"firstBlock"`
+	LastBlock        int    `json:"lastBlock"`
+}
+
+// counterpartyStats is the running aggregate of a Counterparty
+type counterpartyStats struct {
+	Counterparty
+	value *big.Int
+}
+
+// GetCounterparties returns the top counterparties of an address ordered by CounterpartyByCount or
+// CounterpartyByValue, at most limit of them when limit is positive
+func (p *EthParser) GetCounterparties(address string, orderBy string, limit int) []Counterparty {
+	p.mu.Lock()
+	result := make([]Counterparty, 0, len(p.counterparties[address]))
+	values := make(map[string]*big.Int, len(p.counterparties[address]))
+	for counterparty, stats := range p.counterparties[address] {
+		entry := stats.Counterparty
+		entry.TotalValue = stats.value.String()
+		values[counterparty] = stats.value
+		result = append(result, entry)
+	}
+	p.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		byCount := result[i].TransactionCount - result[j].TransactionCount
+		byValue := values[result[i].Address].Cmp(values[result[j].Address])
+		if orderBy == CounterpartyByValue {
+			byCount, byValue = byValue, byCount
+		}
+		if byCount != 0 {
+			return byCount > 0
+		}
+		if byValue != 0 {
+			return byValue > 0
+		}
+		return result[i].Address < result[j].Address
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	if p.labels != nil {
+		for i := range result {
+			if label, ok := p.labels.Label(result[i].Address); ok {
+				result[i].Label = label.Name
+			}
+		}
+	}
+	return result
+}
+
+// counterpartyOf returns the other party of a transaction of an address, and whether the address sent it
+func counterpartyOf(address string, tx Transaction) (counterparty string, sent bool) {
+	if tx.To == address {
+		return tx.From, false
+	}
+	return tx.To, true
+}
+
+// counterpartiesStage adds the stored transactions of the block to the counterparty aggregates
+func (p *EthParser) counterpartiesStage(ctx context.Context, block *BlockContext) error {
+	for address, transactions := range block.Matches {
+		p.recordCounterparties(address, transactions)
+	}
+	return nil
+}
+
+// recordCounterparties adds transactions of an address to its counterparty aggregates
+func (p *EthParser) recordCounterparties(address string, transactions []Transaction) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, tx := range transactions {
+		counterparty, sent := counterpartyOf(address, tx)
+		// Contract deployments have no counterparty, self transfers are not an interaction
+		if counterparty == "" || counterparty == address {
+			continue
+		}
+
+		if p.counterparties[address] == nil {
+			p.counterparties[address] = make(map[string]*counterpartyStats)
+		}
+		stats := p.counterparties[address][counterparty]
+		if stats == nil {
+			stats = &counterpartyStats{
+				Counterparty: Counterparty{Address: counterparty, FirstBlock: tx.BlockNumberDecimal},
+				value:        new(big.Int),
+			}
+			p.counterparties[address][counterparty] = stats
+		}
+		stats.TransactionCount++
+		if sent {
+			stats.Sent++
+		} else {
+			stats.Received++
+		}
+		if value, ok := parseQuantity(tx.Value); ok {
+			stats.value.Add(stats.value, value)
+		}
+		stats.FirstBlock = min(stats.FirstBlock, tx.BlockNumberDecimal)
+		stats.LastBlock = max(stats.LastBlock, tx.BlockNumberDecimal)
+	}
+}
@@ -0,0 +1,62 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestEthParserCounterparties(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{
+		Number: "0x1",
+		Transactions: []parser.Transaction{
+			{Hash: "0xa1", From: "0x1", To: "0x2", Value: "0x10"},
+			{Hash: "0xa2", From: "0x3", To: "0x1", Value: "0x100"},
+			{Hash: "0xa3", From: "0x1", To: "0x1", Value: "0x1"},
+		},
+	})
+	mockBlockchain.AddBlock(2, parser.Block{
+		Number: "0x2",
+		Transactions: []parser.Transaction{
+			{Hash: "0xa4", From: "0x2", To: "0x1", Value: "0x10"},
+			{Hash: "0xa5", From: "0x1", To: "", Value: "0x0"},
+		},
+	})
+	labels := parser.NewLabelDB()
+	labels.Add("0x3", parser.AddressLabel{Name: "Exchange"})
+
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithLabels(labels))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	// Self transfers and deployments are not counted
+	byCount := ethParser.GetCounterparties("0x1", parser.CounterpartyByCount, 0)
+	if len(byCount) != 2 {
+		t.Fatalf("Expected 2 counterparties, got %+v", byCount)
+	}
+	expected := parser.Counterparty{Address: "0x2", TransactionCount: 2, Sent: 1, Received: 1, TotalValue: "32", FirstBlock: 1, LastBlock: 2}
+	if byCount[0] != expected {
+		t.Errorf("Expected %+v, got %+v", expected, byCount[0])
+	}
+
+	byValue := ethParser.GetCounterparties("0x1", parser.CounterpartyByValue, 1)
+	expected = parser.Counterparty{Address: "0x3", Label: "Exchange", TransactionCount: 1, Received: 1, TotalValue: "256", FirstBlock: 1, LastBlock: 1}
+	if len(byValue) != 1 || byValue[0] != expected {
+		t.Errorf("Expected %+v, got %+v", expected, byValue)
+	}
+
+	// A rescan doesn't count the transactions already seen
+	if _, err := ethParser.Rescan(parser.RescanRequest{FromBlock: 1, ToBlock: 2}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if again := ethParser.GetCounterparties("0x1", parser.CounterpartyByCount, 0); again[0].TransactionCount != 2 {
+		t.Errorf("Expected the counts to be unchanged, got %+v", again)
+	}
+}
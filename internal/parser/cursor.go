@@ -0,0 +1,139 @@
+package parser
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidCursor is returned for a cursor token that can't be decoded or belongs to another list
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// unindexedOffset numbers the transactions without index after any index of a block
+const unindexedOffset = 1 << 30
+
+// TransactionCursor is a position in a list of transactions ordered by block and transaction index. The
+// transactions arriving later have greater positions, so paging from a cursor never skips nor repeats one.
+type TransactionCursor struct {
+	// Address is the address, or "entity:" entity ID, of the paged list
+	Address string `json:"a"`
+	Block   int    `json:"b"`
+	Index   int    `json:"i"`
+	// Desc is set on the cursors of the lists ordered from the most recent transaction, see TxQuery.Order
+	Desc bool `json:"d,omitempty"`
+}
+
+// Encode returns the cursor as an opaque URL-safe token
+func (c TransactionCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// passed reports whether the position was already returned up to the cursor, in the order of the cursor
+func (c TransactionCursor) passed(block int, index int) bool {
+	if c.Desc {
+		return c.Block < block || (c.Block == block && c.Index <= index)
+	}
+	return c.Block > block || (c.Block == block && c.Index >= index)
+}
+
+// DecodeTransactionCursor decodes a cursor token of the list of an address, see TransactionCursor.Address
+func DecodeTransactionCursor(token string, address string) (TransactionCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return TransactionCursor{}, ErrInvalidCursor
+	}
+	var cursor TransactionCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || !strings.EqualFold(cursor.Address, address) {
+		return TransactionCursor{}, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// PageTransactions returns up to limit transactions of the list of address after the cursor, the first ones
+// when after is nil, ordered by block and transaction index, with the cursor of the last returned one to
+// request the next page. On the last page the cursor can be kept to resume once new transactions arrive.
+func PageTransactions(transactions []Transaction, address string, after *TransactionCursor, limit int) ([]Transaction, TransactionCursor) {
+	items, next := pageItems(transactions, address, after, limit, false)
+	page := make([]Transaction, len(items))
+	for i, item := range items {
+		page[i] = transactions[item]
+	}
+	return page, next
+}
+
+// PageEntityTransactions is PageTransactions for the transactions of an entity, whose cursor address is
+// "entity:" followed by the entity ID
+func PageEntityTransactions(transactions []EntityTransaction, entityID string, after *TransactionCursor, limit int) ([]EntityTransaction, TransactionCursor) {
+	plain := make([]Transaction, len(transactions))
+	for i, tx := range transactions {
+		plain[i] = tx.Transaction
+	}
+	items, next := pageItems(plain, "entity:"+entityID, after, limit, false)
+	page := make([]EntityTransaction, len(items))
+	for i, item := range items {
+		page[i] = transactions[item]
+	}
+	return page, next
+}
+
+// pageItems returns the positions in the list of the transactions of the page with the cursor of the last one,
+// from the most recent one when desc is set. The transactions sharing a position, e.g. the internal transfers of
+// a transaction, end up in the same page since the cursor can't tell them apart.
+func pageItems(transactions []Transaction, address string, after *TransactionCursor, limit int, desc bool) ([]int, TransactionCursor) {
+	items := []int{}
+	next := TransactionCursor{Address: address, Desc: desc}
+	if after != nil {
+		next = *after
+	}
+	positions := transactionPositions(transactions)
+	if desc {
+		slices.Reverse(positions)
+	}
+	for _, position := range positions {
+		if after != nil && after.passed(position.block, position.index) {
+			continue
+		}
+		if len(items) >= limit && (position.block != next.Block || position.index != next.Index) {
+			break
+		}
+		items = append(items, position.item)
+		next = TransactionCursor{Address: address, Block: position.block, Index: position.index, Desc: desc}
+	}
+	return items, next
+}
+
+// transactionPosition is the block and the index of the transaction at a position of a list
+type transactionPosition struct {
+	item         int
+	block, index int
+}
+
+// transactionPositions returns the positions of the transactions in block and index order. The transactions
+// stored before their index was recorded are numbered in the stored order, after the indexed ones of their block.
+func transactionPositions(transactions []Transaction) []transactionPosition {
+	positions := make([]transactionPosition, len(transactions))
+	unindexed := make(map[int]int) // block -> unindexed transactions so far
+	for i, tx := range transactions {
+		position := transactionPosition{item: i, block: tx.BlockNumberDecimal}
+		index, err := strconv.ParseInt(trimHexPrefix(tx.TransactionIndex), 16, 64)
+		if tx.TransactionIndex != "" && err == nil {
+			position.index = int(index)
+		} else {
+			position.index = unindexedOffset + unindexed[tx.BlockNumberDecimal]
+			unindexed[tx.BlockNumberDecimal]++
+		}
+		positions[i] = position
+	}
+	sort.SliceStable(positions, func(i, j int) bool {
+		if positions[i].block != positions[j].block {
+			return positions[i].block < positions[j].block
+		}
+		return positions[i].index < positions[j].index
+	})
+	return positions
+}
@@ -0,0 +1,113 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestPageTransactionsWithConcurrentArrivals(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{
+		{Hash: "0xa1", From: "0x1", To: "0x2", Value: "0x1"},
+		{Hash: "0xa2", From: "0x1", To: "0x3", Value: "0x1"},
+		{Hash: "0xa3", From: "0x4", To: "0x1", Value: "0x1"},
+	}})
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	page, next := parser.PageTransactions(ethParser.GetTransactions("0x1"), "0x1", nil, 2)
+	if len(page) != 2 || page[0].Hash != "0xa1" || page[1].Hash != "0xa2" || page[1].TransactionIndex != "0x1" {
+		t.Fatalf("Expected the first 2 transactions in block order, got %+v", page)
+	}
+
+	// A block arriving between the requests doesn't shift the next page
+	mockBlockchain.AddBlock(2, parser.Block{Number: "0x2", Transactions: []parser.Transaction{{Hash: "0xb1", From: "0x1", To: "0x2", Value: "0x1"}}})
+	ethParser.ProcessNextCycle()
+	cursor, err := parser.DecodeTransactionCursor(next.Encode(), "0x1")
+	if err != nil {
+		t.Fatalf("Expected the cursor to decode, got %v", err)
+	}
+	var seen []string
+	for {
+		page, next = parser.PageTransactions(ethParser.GetTransactions("0x1"), "0x1", &cursor, 2)
+		if len(page) == 0 {
+			break
+		}
+		for _, tx := range page {
+			seen = append(seen, tx.Hash)
+		}
+		cursor = next
+	}
+	if len(seen) != 2 || seen[0] != "0xa3" || seen[1] != "0xb1" {
+		t.Errorf("Expected the remaining transactions once each, got %v", seen)
+	}
+	if next != cursor {
+		t.Errorf("Expected the cursor of the last page to be kept, got %+v", next)
+	}
+}
+
+func TestPageTransactionsWithoutIndex(t *testing.T) {
+	// Transactions stored before the index was recorded follow the indexed ones of their block
+	transactions := []parser.Transaction{
+		{Hash: "0xa1", BlockNumberDecimal: 1},
+		{Hash: "0xa2", BlockNumberDecimal: 1, TransactionIndex: "0x3"},
+		{Hash: "0xa3", BlockNumberDecimal: 1},
+		{Hash: "0xb1", BlockNumberDecimal: 0, TransactionIndex: "0x0"},
+	}
+	var hashes []string
+	var after *parser.TransactionCursor
+	for {
+		page, next := parser.PageTransactions(transactions, "0x1", after, 1)
+		if len(page) == 0 {
+			break
+		}
+		hashes = append(hashes, page[0].Hash)
+		after = &next
+	}
+	if len(hashes) != 4 || hashes[0] != "0xb1" || hashes[1] != "0xa2" || hashes[2] != "0xa1" || hashes[3] != "0xa3" {
+		t.Errorf("Unexpected page order %v", hashes)
+	}
+}
+
+func TestDecodeTransactionCursor(t *testing.T) {
+	token := parser.TransactionCursor{Address: "0xAB", Block: 3, Index: 1}.Encode()
+	if cursor, err := parser.DecodeTransactionCursor(token, "0xab"); err != nil || cursor.Block != 3 || cursor.Index != 1 {
+		t.Errorf("Expected the cursor to decode, got %+v %v", cursor, err)
+	}
+	for _, tt := range []struct{ token, address string }{
+		{token, "0xcd"},
+		{token, "entity:0xab"},
+		{"not a cursor", "0xab"},
+		{"bm90IGpzb24", "0xab"},
+	} {
+		if _, err := parser.DecodeTransactionCursor(tt.token, tt.address); err != parser.ErrInvalidCursor {
+			t.Errorf("Expected %q to be rejected for %s, got %v", tt.token, tt.address, err)
+		}
+	}
+}
+
+func TestPageEntityTransactions(t *testing.T) {
+	transactions := []parser.EntityTransaction{
+		{Transaction: parser.Transaction{Hash: "0xa2", BlockNumberDecimal: 2, TransactionIndex: "0x0"}},
+		{Transaction: parser.Transaction{Hash: "0xa1", BlockNumberDecimal: 1, TransactionIndex: "0x0"}, Internal: true},
+	}
+	page, next := parser.PageEntityTransactions(transactions, "treasury", nil, 1)
+	if len(page) != 1 || page[0].Hash != "0xa1" || !page[0].Internal || next.Address != "entity:treasury" {
+		t.Fatalf("Unexpected first page %+v %+v", page, next)
+	}
+	if _, err := parser.DecodeTransactionCursor(next.Encode(), "0x1"); err == nil {
+		t.Error("Expected the entity cursor to be rejected for an address list")
+	}
+	page, _ = parser.PageEntityTransactions(transactions, "treasury", &next, 1)
+	if len(page) != 1 || page[0].Hash != "0xa2" {
+		t.Errorf("Unexpected second page %+v", page)
+	}
+}
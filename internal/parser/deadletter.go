@@ -0,0 +1,230 @@
+package parser
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// EventBlockDeadLettered is sent when a block is dead-lettered
+const EventBlockDeadLettered = "block_dead_lettered"
+
+// ErrUnknownDeadLetter is returned when replaying a block that isn't dead-lettered
+var ErrUnknownDeadLetter = errors.New("unknown dead letter")
+
+// BlockDeadLetter is a block given up after failing the pipeline, kept until it's replayed
+type BlockDeadLetter struct {
+	Block    int       `json:"block"`
+	Stage    string    `json:"stage"`
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+	FailedAt time.Time `json:"failedAt"`
+	// Payload is the block as returned by the node, empty when it couldn't be fetched
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// BlockDeadLetterStore persists the dead-lettered blocks
+type BlockDeadLetterStore interface {
+	// SaveBlockDeadLetter saves a dead letter, replacing the one of the same block
+	SaveBlockDeadLetter(letter BlockDeadLetter) error
+	// BlockDeadLetters returns the dead letters in block order, without their payload
+	BlockDeadLetters() ([]BlockDeadLetter, error)
+	GetBlockDeadLetter(block int) (BlockDeadLetter, bool, error)
+	DeleteBlockDeadLetter(block int) error
+}
+
+// BlockDeadLetters returns the dead-lettered blocks, without their payload
+func (p *EthParser) BlockDeadLetters() ([]BlockDeadLetter, error) {
+	if p.blockDeadLetters == nil {
+		return []BlockDeadLetter{}, nil
+	}
+	return p.blockDeadLetters.BlockDeadLetters()
+}
+
+// GetBlockDeadLetter returns a dead-lettered block with its payload
+func (p *EthParser) GetBlockDeadLetter(block int) (BlockDeadLetter, bool, error) {
+	if p.blockDeadLetters == nil {
+		return BlockDeadLetter{}, false, nil
+	}
+	return p.blockDeadLetters.GetBlockDeadLetter(block)
+}
+
+// ReplayBlockDeadLetter runs the whole pipeline again on a dead-lettered block, e.g. after a fix, for the
+// addresses subscribed now. A replayed block is removed from the dead letters, a failed replay updates it.
+func (p *EthParser) ReplayBlockDeadLetter(number int) error {
+	letter, found, err := p.GetBlockDeadLetter(number)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrUnknownDeadLetter
+	}
+
+	p.cycleMu.Lock()
+	defer p.cycleMu.Unlock()
+	p.mu.Lock()
+	subscribed := make(map[string]bool, len(p.subscriptions))
+	for address := range p.subscriptions {
+		subscribed[address] = true
+	}
+	p.mu.Unlock()
+
+	block := &BlockContext{Number: number, Subscribed: subscribed, Matcher: p.newMatcher(subscribed), Matches: make(map[string][]Transaction)}
+	failed, err := p.runStagesFrom(p.pipeline, block, 0)
+	if failed >= 0 && err != nil {
+		letter.Attempts++
+		letter.Stage, letter.Error, letter.FailedAt = p.pipeline[failed].Name, err.Error(), p.clock.Now()
+		if block.Raw != nil {
+			letter.Payload = block.Raw
+		}
+		if saveErr := p.blockDeadLetters.SaveBlockDeadLetter(letter); saveErr != nil {
+			log.Printf("Error updating the dead letter of block %d: %v\n", number, saveErr)
+		}
+		return fmt.Errorf("replaying block %d: stage %s: %w", number, letter.Stage, err)
+	}
+	log.Printf("Replayed dead-lettered block %d\n", number)
+	return p.blockDeadLetters.DeleteBlockDeadLetter(number)
+}
+
+// runBlockWithRetries runs the pipeline on a block. With a dead letter store, a failing block is retried from
+// the failed stage up to the configured attempts, then dead-lettered; otherwise it's skipped at once.
+func (p *EthParser) runBlockWithRetries(block *BlockContext) {
+	from := 0
+	for attempt := 1; ; attempt++ {
+		failed, err := p.runStagesFrom(p.pipeline, block, from)
+		if failed < 0 || err == nil || p.blockDeadLetters == nil || block.rateLimited {
+			return
+		}
+		if attempt >= p.blockAttempts {
+			p.deadLetterBlock(BlockDeadLetter{
+				Block:    block.Number,
+				Stage:    p.pipeline[failed].Name,
+				Error:    err.Error(),
+				Attempts: attempt,
+				FailedAt: p.clock.Now(),
+				Payload:  block.Raw,
+			})
+			return
+		}
+		from = failed
+	}
+}
+
+// deadLetterBlock saves a dead-lettered block and sends its event
+func (p *EthParser) deadLetterBlock(letter BlockDeadLetter) {
+	log.Printf("Dead-lettering block %d after %d attempts: stage %s: %s\n", letter.Block, letter.Attempts, letter.Stage, letter.Error)
+	if err := p.recordStorageWrite(p.blockDeadLetters.SaveBlockDeadLetter(letter)); err != nil {
+		log.Printf("Error saving the dead letter of block %d: %v\n", letter.Block, err)
+		return
+	}
+	p.emitEvent(Event{
+		Type: EventBlockDeadLettered,
+		Data: map[string]string{"block": strconv.Itoa(letter.Block), "stage": letter.Stage, "error": letter.Error},
+	})
+}
+
+// SaveBlockDeadLetter saves a dead letter in memory
+func (s *MemoryStorage) SaveBlockDeadLetter(letter BlockDeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blockDeadLetters[letter.Block] = letter
+	return nil
+}
+
+// BlockDeadLetters returns the dead letters in block order, without their payload
+func (s *MemoryStorage) BlockDeadLetters() ([]BlockDeadLetter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	letters := make([]BlockDeadLetter, 0, len(s.blockDeadLetters))
+	for _, letter := range s.blockDeadLetters {
+		letter.Payload = nil
+		letters = append(letters, letter)
+	}
+	sort.Slice(letters, func(i, j int) bool { return letters[i].Block < letters[j].Block })
+	return letters, nil
+}
+
+// GetBlockDeadLetter returns the dead letter of a block
+func (s *MemoryStorage) GetBlockDeadLetter(block int) (BlockDeadLetter, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	letter, ok := s.blockDeadLetters[block]
+	return letter, ok, nil
+}
+
+// DeleteBlockDeadLetter removes the dead letter of a block
+func (s *MemoryStorage) DeleteBlockDeadLetter(block int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.blockDeadLetters, block)
+	return nil
+}
+
+// SaveBlockDeadLetter upserts the dead letter of a block
+func (s *SQLStorage) SaveBlockDeadLetter(letter BlockDeadLetter) error {
+	payload, err := sealField(s.cipher, string(letter.Payload), "block_dead_letters/"+strconv.Itoa(letter.Block))
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO block_dead_letters (block_number, stage, error, attempts, failed_at, payload)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (block_number) DO UPDATE SET stage = excluded.stage, error = excluded.error,
+		attempts = excluded.attempts, failed_at = excluded.failed_at, payload = excluded.payload`,
+		letter.Block, letter.Stage, letter.Error, letter.Attempts, letter.FailedAt.Unix(), payload)
+	return err
+}
+
+// BlockDeadLetters returns the dead letters in block order, without their payload
+func (s *SQLStorage) BlockDeadLetters() ([]BlockDeadLetter, error) {
+	rows, err := s.db.Query(`SELECT block_number, stage, error, attempts, failed_at FROM block_dead_letters ORDER BY block_number`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	letters := []BlockDeadLetter{}
+	for rows.Next() {
+		var letter BlockDeadLetter
+		var failedAt int64
+		if err := rows.Scan(&letter.Block, &letter.Stage, &letter.Error, &letter.Attempts, &failedAt); err != nil {
+			return nil, err
+		}
+		letter.FailedAt = time.Unix(failedAt, 0)
+		letters = append(letters, letter)
+	}
+	return letters, rows.Err()
+}
+
+// GetBlockDeadLetter returns the dead letter of a block
+func (s *SQLStorage) GetBlockDeadLetter(block int) (BlockDeadLetter, bool, error) {
+	letter := BlockDeadLetter{Block: block}
+	var failedAt int64
+	var payload string
+	err := s.db.QueryRow(`SELECT stage, error, attempts, failed_at, payload FROM block_dead_letters WHERE block_number = $1`, block).
+		Scan(&letter.Stage, &letter.Error, &letter.Attempts, &failedAt, &payload)
+	if err == sql.ErrNoRows {
+		return BlockDeadLetter{}, false, nil
+	}
+	if err != nil {
+		return BlockDeadLetter{}, false, err
+	}
+	if payload, err = openField(s.cipher, payload, "block_dead_letters/"+strconv.Itoa(block)); err != nil {
+		return BlockDeadLetter{}, false, err
+	}
+	if payload != "" {
+		letter.Payload = json.RawMessage(payload)
+	}
+	letter.FailedAt = time.Unix(failedAt, 0)
+	return letter, true, nil
+}
+
+// DeleteBlockDeadLetter removes the dead letter of a block
+func (s *SQLStorage) DeleteBlockDeadLetter(block int) error {
+	_, err := s.db.Exec(`DELETE FROM block_dead_letters WHERE block_number = $1`, block)
+	return err
+}
@@ -0,0 +1,77 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flakyClient fails the first block requests
+type flakyClient struct {
+	*MockClient
+	failures int
+}
+
+func (c *flakyClient) SendRequest(req parser.JSONRPCRequest) (parser.JSONRPCResponse, error) {
+	if req.Method == "eth_getBlockByNumber" && c.failures > 0 {
+		c.failures--
+		return parser.JSONRPCResponse{}, errors.New("connection reset")
+	}
+	return c.MockClient.SendRequest(req)
+}
+
+func TestEthParserBlockDeadLetters(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Block 1 is fetched at the second attempt, block 2 can't be decoded
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{{Hash: "0xa1", From: "0x1", To: "0x2", Value: "0x1"}}})
+	mockBlockchain.AddBlock(2, parser.Block{Number: "0xzz", Transactions: []parser.Transaction{{Hash: "0xa2", From: "0x1", To: "0x2", Value: "0x1"}}})
+	storage := parser.NewMemoryStorage()
+	notified := 0
+	ethParser := parser.NewEthParser(ctx, storage, 1, &flakyClient{MockClient: NewMockClient(mockBlockchain), failures: 1},
+		func(string, []parser.Transaction) { notified++ }, parser.WithClock(parser.NewManualClock(time.Now())),
+		parser.WithBlockDeadLetters(storage, 3))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	if transactions := ethParser.GetTransactions("0x1"); len(transactions) != 1 {
+		t.Fatalf("Expected the retried block to be processed, got %+v", transactions)
+	}
+	letters, _ := ethParser.BlockDeadLetters()
+	if len(letters) != 1 || letters[0].Block != 2 || letters[0].Stage != parser.StageDecode || letters[0].Attempts != 3 || letters[0].Payload != nil {
+		t.Fatalf("Expected block 2 to be dead-lettered by the decode stage, got %+v", letters)
+	}
+	letter, found, _ := ethParser.GetBlockDeadLetter(2)
+	if !found || !strings.Contains(string(letter.Payload), `"0xzz"`) {
+		t.Fatalf("Expected the raw block in the dead letter, got %s", letter.Payload)
+	}
+
+	// A replay failing again updates the dead letter
+	if err := ethParser.ReplayBlockDeadLetter(2); err == nil {
+		t.Fatal("Expected the replay to fail")
+	}
+	if letter, _, _ := ethParser.GetBlockDeadLetter(2); letter.Attempts != 4 {
+		t.Errorf("Expected the failed replay to be counted, got %+v", letter)
+	}
+
+	// Once fixed, the replayed block is processed and notified
+	mockBlockchain.AddBlock(2, parser.Block{Number: "0x2", Transactions: []parser.Transaction{{Hash: "0xa2", From: "0x1", To: "0x2", Value: "0x1"}}})
+	if err := ethParser.ReplayBlockDeadLetter(2); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if transactions := ethParser.GetTransactions("0x1"); len(transactions) != 2 || notified != 2 {
+		t.Errorf("Expected the replayed block to be stored and notified, got %+v and %d notifications", transactions, notified)
+	}
+	if letters, _ := ethParser.BlockDeadLetters(); len(letters) != 0 {
+		t.Errorf("Expected no dead letter left, got %+v", letters)
+	}
+	if err := ethParser.ReplayBlockDeadLetter(2); !errors.Is(err, parser.ErrUnknownDeadLetter) {
+		t.Errorf("Expected ErrUnknownDeadLetter, got %v", err)
+	}
+}
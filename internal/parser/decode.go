@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// maxDecodeFailures is the number of blocks with decoding errors kept by DecodeFailures
+const maxDecodeFailures = 100
+
+// BlockDecodeFailure reports the fields of a block that couldn't be decoded. The block was processed with
+// what could be salvaged: the other fields of its transactions, and the transactions that have a hash.
+type BlockDecodeFailure struct {
+	Block  string   `json:"block"`
+	Errors []string `json:"errors"`
+	// Fallback is set when the block was fetched again from the fallback client, Recovered when that one decoded
+	Fallback  bool `json:"fallback,omitempty"`
+	Recovered bool `json:"recovered,omitempty"`
+}
+
+// DecodeFailures returns the last blocks that failed to decode, oldest first
+func (p *EthParser) DecodeFailures() []BlockDecodeFailure {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]BlockDecodeFailure(nil), p.decodeFailures...)
+}
+
+// recordDecodeFailure keeps a decode failure, dropping the oldest beyond maxDecodeFailures
+func (p *EthParser) recordDecodeFailure(failure BlockDecodeFailure) {
+	log.Printf("Block %s partially decoded: %v\n", failure.Block, failure.Errors)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.decodeFailures = append(p.decodeFailures, failure)
+	if len(p.decodeFailures) > maxDecodeFailures {
+		p.decodeFailures = p.decodeFailures[len(p.decodeFailures)-maxDecodeFailures:]
+	}
+}
+
+// decodeBlock decodes a block, field by field when it doesn't fit the Block struct. It returns the salvaged
+// block with the decoding errors, and an error only when not even the block number can be decoded.
+func decodeBlock(raw []byte) (Block, []string, error) {
+	var block Block
+	if err := json.Unmarshal(raw, &block); err == nil {
+		return block, nil, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return Block{}, nil, fmt.Errorf("decoding block: %w", err)
+	}
+	var errs []string
+	transactions := fields["transactions"]
+	delete(fields, "transactions")
+	block = Block{}
+	errs = append(errs, decodeFields(fields, &block, "")...)
+	if block.Number == "" {
+		return Block{}, errs, fmt.Errorf("decoding block: no valid number: %v", errs)
+	}
+
+	var rawTransactions []json.RawMessage
+	if err := json.Unmarshal(transactions, &rawTransactions); transactions != nil && err != nil {
+		return block, append(errs, "transactions: "+err.Error()), nil
+	}
+	for i, rawTx := range rawTransactions {
+		var tx Transaction
+		if err := json.Unmarshal(rawTx, &tx); err != nil {
+			var txFields map[string]json.RawMessage
+			if err := json.Unmarshal(rawTx, &txFields); err != nil {
+				errs = append(errs, fmt.Sprintf("transactions[%d]: %v", i, err))
+				continue
+			}
+			tx = Transaction{}
+			errs = append(errs, decodeFields(txFields, &tx, fmt.Sprintf("transactions[%d].", i))...)
+		}
+		if tx.Hash == "" {
+			errs = append(errs, fmt.Sprintf("transactions[%d]: dropped without a hash", i))
+			continue
+		}
+		block.Transactions = append(block.Transactions, tx)
+	}
+	return block, errs, nil
+}
+
+// decodeFields decodes the fields one at a time into out, returning the errors prefixed with the field path
+func decodeFields(fields map[string]json.RawMessage, out interface{}, prefix string) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []string
+	for _, name := range names {
+		single, err := json.Marshal(map[string]json.RawMessage{name: fields[name]})
+		if err != nil {
+			errs = append(errs, prefix+name+": "+err.Error())
+			continue
+		}
+		if err := json.Unmarshal(single, out); err != nil {
+			errs = append(errs, prefix+name+": "+err.Error())
+		}
+	}
+	return errs
+}
@@ -0,0 +1,88 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// rawBlockClient serves the blocks as raw JSON objects, as a provider with its own encoding would
+type rawBlockClient struct {
+	blocks []map[string]interface{}
+}
+
+func (c *rawBlockClient) SendRequest(req parser.JSONRPCRequest) (parser.JSONRPCResponse, error) {
+	switch req.Method {
+	case "eth_blockNumber":
+		return parser.JSONRPCResponse{Result: fmt.Sprintf("0x%x", len(c.blocks))}, nil
+	case "eth_getBlockByNumber":
+		var number int
+		fmt.Sscanf(req.Params[0].(string), "0x%x", &number)
+		if number < 1 || number > len(c.blocks) {
+			return parser.JSONRPCResponse{}, fmt.Errorf("unknown block %v", req.Params[0])
+		}
+		return parser.JSONRPCResponse{Result: c.blocks[number-1]}, nil
+	}
+	return parser.JSONRPCResponse{}, fmt.Errorf("unsupported method: %s", req.Method)
+}
+
+// quirkyBlock is block 1 with a transaction of a new type encoding its nonce as a number, and one without a valid hash
+func quirkyBlock() map[string]interface{} {
+	return map[string]interface{}{
+		"number": "0x1",
+		"transactions": []interface{}{
+			map[string]interface{}{"hash": "0xa1", "from": "0x1", "to": "0x2", "value": "0x64", "type": "0x7e", "nonce": 5},
+			map[string]interface{}{"hash": 42, "from": "0x1", "to": "0x2", "value": "0x1"},
+		},
+	}
+}
+
+func TestEthParserSalvagesPartiallyDecodedBlocks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := &rawBlockClient{blocks: []map[string]interface{}{quirkyBlock()}}
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, client, func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	transactions := ethParser.GetTransactions("0x1")
+	if len(transactions) != 1 || transactions[0].Hash != "0xa1" || transactions[0].Value != "0x64" || transactions[0].Nonce != "" {
+		t.Fatalf("Expected the salvaged transaction without its nonce, got %+v", transactions)
+	}
+	failures := ethParser.DecodeFailures()
+	if len(failures) != 1 || failures[0].Block != "0x1" || len(failures[0].Errors) != 3 || failures[0].Fallback {
+		t.Fatalf("Expected the decoding errors of block 1, got %+v", failures)
+	}
+}
+
+func TestEthParserRetriesPartiallyDecodedBlocksOnFallback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := &rawBlockClient{blocks: []map[string]interface{}{quirkyBlock()}}
+	fallback := &rawBlockClient{blocks: []map[string]interface{}{{
+		"number": "0x1",
+		"transactions": []interface{}{
+			map[string]interface{}{"hash": "0xa1", "from": "0x1", "to": "0x2", "value": "0x64", "type": "0x7e", "nonce": "0x5"},
+			map[string]interface{}{"hash": "0xb1", "from": "0x1", "to": "0x2", "value": "0x1"},
+		},
+	}}}
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, client, func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithFallbackClient(fallback))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	transactions := ethParser.GetTransactions("0x1")
+	if len(transactions) != 2 || transactions[0].Nonce != "0x5" || transactions[1].Hash != "0xb1" {
+		t.Fatalf("Expected the block of the fallback client, got %+v", transactions)
+	}
+	if failures := ethParser.DecodeFailures(); len(failures) != 1 || !failures[0].Fallback || !failures[0].Recovered {
+		t.Fatalf("Expected the failure to be recovered by the fallback, got %+v", failures)
+	}
+}
@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"strconv"
+	"time"
+)
+
+// DeliveryFunc delivers the transactions of an outbox event, returning an error when the delivery failed
+// and must be retried
+type DeliveryFunc func(address string, transactions []Transaction) error
+
+// DeliveryPolicy defines how failed deliveries are retried. The events of an address are always delivered
+// in block order: while an event of an address is failing, the next events of the same address wait behind it,
+// the other addresses are not affected.
+type DeliveryPolicy struct {
+	// MaxAttempts is the number of attempts after which a failing event is dead-lettered, unblocking the next
+	// events of the address. Zero retries forever, blocking the address until the delivery succeeds.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry, doubled on every failure up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DeadLetter is an outbox event given up after DeliveryPolicy.MaxAttempts failed deliveries
+type DeadLetter struct {
+	Event     OutboxEvent `json:"event"`
+	Attempts  int         `json:"attempts"`
+	LastError string      `json:"lastError"`
+}
+
+// deliveryState holds the retries in progress, it's only accessed under dispatchMu
+type deliveryState struct {
+	attempts map[string]int       // outbox event ID -> failed attempts
+	retryAt  map[string]time.Time // address -> time of the next attempt
+	// cycle counts the fetch cycles, an address is retried at most once per cycle
+	cycle       int
+	failedCycle map[string]int // address -> cycle of the last failure
+}
+
+// startDeliveryCycle starts a new fetch cycle for the retries, and delivers the due notification summaries
+func (p *EthParser) startDeliveryCycle() {
+	p.dispatchMu.Lock()
+	defer p.dispatchMu.Unlock()
+	p.delivery.cycle++
+	p.flushThrottles()
+}
+
+// EventNotificationDeadLettered is sent when an outbox event is dead-lettered
+const EventNotificationDeadLettered = "notification_dead_lettered"
+
+// deliverEvent delivers an outbox event. It returns true when the event is done with, delivered or
+// dead-lettered, and false when the address is blocked until a retry.
+func (p *EthParser) deliverEvent(event OutboxEvent) bool {
+	now := p.clock.Now()
+	if retryAt, blocked := p.delivery.retryAt[event.Address]; blocked && now.Before(retryAt) {
+		return false
+	}
+	if failed, ok := p.delivery.failedCycle[event.Address]; ok && failed == p.delivery.cycle {
+		return false
+	}
+
+	err := p.deliverFor(event.Address)(event.Address, p.annotateTransactions(event.Address, event.Transactions))
+	if err == nil {
+		delete(p.delivery.attempts, event.ID)
+		delete(p.delivery.retryAt, event.Address)
+		delete(p.delivery.failedCycle, event.Address)
+		return true
+	}
+	p.delivery.failedCycle[event.Address] = p.delivery.cycle
+
+	p.delivery.attempts[event.ID]++
+	attempts := p.delivery.attempts[event.ID]
+	if p.deliveryPolicy.MaxAttempts > 0 && attempts >= p.deliveryPolicy.MaxAttempts {
+		delete(p.delivery.attempts, event.ID)
+		delete(p.delivery.retryAt, event.Address)
+		delete(p.delivery.failedCycle, event.Address)
+		p.mu.Lock()
+		p.deadLetters = append(p.deadLetters, DeadLetter{Event: event, Attempts: attempts, LastError: err.Error()})
+		p.mu.Unlock()
+		p.emitEvent(Event{
+			Type:    EventNotificationDeadLettered,
+			Address: event.Address,
+			Data:    map[string]string{"block": strconv.Itoa(event.BlockNumber), "error": err.Error()},
+		})
+		return true
+	}
+
+	p.delivery.retryAt[event.Address] = now.Add(p.deliveryPolicy.backoff(attempts))
+	return false
+}
+
+// backoff returns the delay before the retry following the given number of failed attempts
+func (d DeliveryPolicy) backoff(attempts int) time.Duration {
+	delay := d.InitialBackoff
+	for i := 1; i < attempts && delay < d.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if d.MaxBackoff > 0 && delay > d.MaxBackoff {
+		delay = d.MaxBackoff
+	}
+	return delay
+}
+
+// DeadLetters returns the outbox events given up after too many failed deliveries
+func (p *EthParser) DeadLetters() []DeadLetter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]DeadLetter(nil), p.deadLetters...)
+}
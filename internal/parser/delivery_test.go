@@ -0,0 +1,95 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+// newDeliveryTestParser returns a parser watching 0x1 and 0x2 over two blocks, delivering through deliver
+func newDeliveryTestParser(ctx context.Context, deliver parser.DeliveryFunc, policy parser.DeliveryPolicy) *parser.EthParser {
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{
+		Number: "0x1",
+		Transactions: []parser.Transaction{
+			{Hash: "0xa1", From: "0x1", To: "0x9", Value: "100"},
+			{Hash: "0xb1", From: "0x2", To: "0x9", Value: "100"},
+		},
+	})
+	mockBlockchain.AddBlock(2, parser.Block{
+		Number:       "0x2",
+		Transactions: []parser.Transaction{{Hash: "0xa2", From: "0x1", To: "0x9", Value: "200"}},
+	})
+
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain),
+		func(string, []parser.Transaction) {}, parser.WithClock(parser.NewManualClock(time.Now())),
+		parser.WithDelivery(deliver, policy))
+	ethParser.Subscribe("0x1")
+	ethParser.Subscribe("0x2")
+	return ethParser
+}
+
+func TestOrderedDeliveryBlocksOnFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var delivered []string
+	failures := 0
+	deliver := func(address string, transactions []parser.Transaction) error {
+		if address == "0x1" && failures < 2 {
+			failures++
+			return errors.New("endpoint unavailable")
+		}
+		for _, tx := range transactions {
+			delivered = append(delivered, tx.Hash)
+		}
+		return nil
+	}
+
+	ethParser := newDeliveryTestParser(ctx, deliver, parser.DeliveryPolicy{})
+	defer ethParser.WaitForShutdown()
+
+	ethParser.ProcessNextCycle()
+	if len(delivered) != 1 || delivered[0] != "0xb1" {
+		t.Fatalf("Only the other address must be delivered while 0x1 fails, got %v", delivered)
+	}
+
+	ethParser.ProcessNextCycle()
+	ethParser.ProcessNextCycle()
+	if len(delivered) != 3 || delivered[1] != "0xa1" || delivered[2] != "0xa2" {
+		t.Fatalf("Transactions of 0x1 must be delivered in block order after the retries, got %v", delivered)
+	}
+}
+
+func TestOrderedDeliveryDeadLetter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var delivered []string
+	deliver := func(address string, transactions []parser.Transaction) error {
+		if transactions[0].Hash == "0xa1" {
+			return errors.New("rejected")
+		}
+		delivered = append(delivered, transactions[0].Hash)
+		return nil
+	}
+
+	ethParser := newDeliveryTestParser(ctx, deliver, parser.DeliveryPolicy{MaxAttempts: 2})
+	defer ethParser.WaitForShutdown()
+
+	ethParser.ProcessNextCycle()
+	if len(ethParser.DeadLetters()) != 0 {
+		t.Fatal("Event dead-lettered before MaxAttempts")
+	}
+	ethParser.ProcessNextCycle()
+
+	deadLetters := ethParser.DeadLetters()
+	if len(deadLetters) != 1 || deadLetters[0].Event.Transactions[0].Hash != "0xa1" || deadLetters[0].Attempts != 2 {
+		t.Fatalf("Unexpected dead letters: %+v", deadLetters)
+	}
+	if len(delivered) != 2 || delivered[1] != "0xa2" {
+		t.Fatalf("The next event of 0x1 must be delivered once the failing one is dead-lettered, got %v", delivered)
+	}
+}
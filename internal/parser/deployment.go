@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// StageDeployments is the name of the pipeline stage detecting the contracts deployed by the subscribed
+// addresses, see WithDeploymentMonitoring
+const StageDeployments = "deployments"
+
+// EventContractDeployed is sent when a subscribed address deploys a contract
+const EventContractDeployed = "contract_deployed"
+
+// receiptStatusFailed is the status of the receipt of a reverted transaction
+const receiptStatusFailed = "0x0"
+
+// deploymentsStage sends an event for every contract deployed by a subscribed address of the block, and
+// subscribes the contract with the settings and in the entity of its deployer when enabled
+func (p *EthParser) deploymentsStage(ctx context.Context, block *BlockContext) error {
+	if !p.monitorDeployments {
+		return nil
+	}
+	var errs []error
+	for address, transactions := range block.Matches {
+		for _, tx := range transactions {
+			if tx.To != "" || !strings.EqualFold(tx.From, address) {
+				continue
+			}
+			contract, deployed, err := p.deployedContract(tx)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("transaction %s: %w", tx.Hash, err))
+				continue
+			}
+			if !deployed {
+				continue
+			}
+			p.onContractDeployed(address, contract, tx)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deployedContract returns the address of the contract created by a deployment transaction, from its
+// receipt, else computed from the sender and the nonce. It's false when the deployment reverted.
+func (p *EthParser) deployedContract(tx Transaction) (string, bool, error) {
+	var receipt TransactionReceipt
+	found, err := p.callResult("eth_getTransactionReceipt", tx.Hash, &receipt)
+	if err == nil && found {
+		if receipt.Status == receiptStatusFailed {
+			return "", false, nil
+		}
+		if receipt.ContractAddress != "" {
+			return strings.ToLower(receipt.ContractAddress), true, nil
+		}
+	}
+	contract, computeErr := ContractAddress(tx.From, tx.Nonce)
+	if computeErr != nil {
+		return "", false, errors.Join(err, computeErr)
+	}
+	return contract, true, nil
+}
+
+// onContractDeployed subscribes a contract deployed by a subscribed address, when enabled, and sends the event
+func (p *EthParser) onContractDeployed(deployer string, contract string, tx Transaction) {
+	p.mu.Lock()
+	entities := p.addressEntities[deployer]
+	// The event names the entity of the deployer, the one of its tenant when it's only in a tenant entity
+	entity := entities[""]
+	if len(entities) == 1 {
+		for namespace, entityID := range entities {
+			entity = entityKey{namespace, entityID}.String()
+		}
+	}
+	subscribed := false
+	if p.subscribeDeployments {
+		if _, exists := p.subscriptions[contract]; !exists {
+			subscription := Subscription{Address: contract}
+			if settings := p.subscriptions[deployer]; settings != nil {
+				subscription.Email = settings.Email
+				subscription.Tenant = settings.Tenant
+			}
+			p.subscriptions[contract] = &subscription
+			subscribed = true
+		}
+		// The contract joins the entities of the deployer, in each namespace where it has none yet
+		for namespace, entityID := range entities {
+			if _, exists := p.addressEntities[contract][namespace]; exists {
+				continue
+			}
+			p.entities[entityKey{namespace, entityID}][contract] = true
+			if p.addressEntities[contract] == nil {
+				p.addressEntities[contract] = make(map[string]string)
+			}
+			p.addressEntities[contract][namespace] = entityID
+		}
+	}
+	p.mu.Unlock()
+
+	p.emitEvent(Event{Type: EventContractDeployed, Address: deployer, Data: map[string]string{
+		"contract":   contract,
+		"hash":       tx.Hash,
+		"block":      fmt.Sprint(tx.BlockNumberDecimal),
+		"entity":     entity,
+		"subscribed": fmt.Sprint(subscribed),
+	}})
+}
+
+// ContractAddress computes the address of the contract created by a CREATE transaction: the last 20 bytes of
+// the keccak256 of the RLP list of the sender and its nonce, a hex quantity
+func ContractAddress(sender string, nonce string) (string, error) {
+	from, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(sender), "0x"))
+	if err != nil || len(from) != 20 {
+		return "", fmt.Errorf("invalid sender %q", sender)
+	}
+	if nonce == "" {
+		return "", errors.New("missing nonce")
+	}
+	n, err := decodeHeaderValue(nonce, true)
+	if err != nil {
+		return "", fmt.Errorf("invalid nonce %q: %w", nonce, err)
+	}
+	hash := keccak256(rlpEncodeList([][]byte{rlpEncodeBytes(from), rlpEncodeBytes(n)}))
+	return "0x" + hex.EncodeToString(hash[12:]), nil
+}
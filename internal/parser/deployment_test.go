@@ -0,0 +1,119 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+const testDeployer = "0x6ac7ea33f8831ea9dcc53393aaa88b25a785dbf0"
+
+// receiptClient returns the receipts of some transactions instead of the mock ones
+type receiptClient struct {
+	*MockClient
+	receipts map[string]map[string]interface{}
+}
+
+func (c *receiptClient) SendRequest(req parser.JSONRPCRequest) (parser.JSONRPCResponse, error) {
+	if req.Method == "eth_getTransactionReceipt" {
+		if receipt, ok := c.receipts[req.Params[0].(string)]; ok {
+			return parser.JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: receipt}, nil
+		}
+	}
+	return c.MockClient.SendRequest(req)
+}
+
+func TestContractAddress(t *testing.T) {
+	tests := []struct {
+		nonce    string
+		expected string
+	}{
+		{"0x0", "0xcd234a471b72ba2f1ccf0a70fcaba648a5eecd8d"},
+		{"0x1", "0x343c43a37d37dff08ae8c4a11544c718abb4fcf8"},
+		{"0x2", "0xf778b86fa74e846c4f0a1fbd1335fe81c00a0c91"},
+	}
+	for _, tt := range tests {
+		if address, err := parser.ContractAddress(testDeployer, tt.nonce); err != nil || address != tt.expected {
+			t.Errorf("Expected %s for nonce %s, got %s %v", tt.expected, tt.nonce, address, err)
+		}
+	}
+	if _, err := parser.ContractAddress("0x1", "0x0"); err == nil {
+		t.Error("Expected an error for an invalid sender")
+	}
+	if _, err := parser.ContractAddress(testDeployer, ""); err == nil {
+		t.Error("Expected an error for a missing nonce")
+	}
+}
+
+func TestEthParserDeploymentMonitoring(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The first deployment has no contract address in its receipt, the second reverts, the third has one
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{
+		{Hash: "0xd1", From: testDeployer, Value: "0x0", Nonce: "0x0", Input: "0x6080"},
+		{Hash: "0xd2", From: testDeployer, Value: "0x0", Nonce: "0x1", Input: "0x6080"},
+		{Hash: "0xd3", From: testDeployer, Value: "0x0", Nonce: "0x2", Input: "0x6080"},
+		{Hash: "0xa1", From: testDeployer, To: "0x2", Value: "0x1", Nonce: "0x3"},
+	}})
+	client := &receiptClient{MockClient: NewMockClient(mockBlockchain), receipts: map[string]map[string]interface{}{
+		"0xd2": {"status": "0x0", "gasUsed": "0x5208"},
+		"0xd3": {"status": "0x1", "gasUsed": "0x5208", "contractAddress": "0xC0FFEE0000000000000000000000000000000003"},
+	}}
+	var events []parser.Event
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, client, func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithDeploymentMonitoring(true),
+		parser.WithEventNotification(func(event parser.Event) { events = append(events, event) }))
+	defer ethParser.WaitForShutdown()
+	email := &parser.EmailConfig{Recipients: []string{"ops@example.com"}}
+	ethParser.SubscribeWith(parser.Subscription{Address: testDeployer, Email: email})
+	ethParser.AddToEntity("treasury", testDeployer)
+	ethParser.ProcessNextCycle()
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 deployment events, got %+v", events)
+	}
+	computed, fromReceipt := "0xcd234a471b72ba2f1ccf0a70fcaba648a5eecd8d", "0xc0ffee0000000000000000000000000000000003"
+	if events[0].Type != parser.EventContractDeployed || events[0].Address != testDeployer || events[0].Data["contract"] != computed ||
+		events[0].Data["hash"] != "0xd1" || events[0].Data["entity"] != "treasury" || events[0].Data["subscribed"] != "true" {
+		t.Errorf("Unexpected event of the computed deployment %+v", events[0])
+	}
+	if events[1].Data["contract"] != fromReceipt {
+		t.Errorf("Expected the contract address of the receipt, got %+v", events[1])
+	}
+	for _, contract := range []string{computed, fromReceipt} {
+		subscription, subscribed := ethParser.GetSubscription(contract)
+		if !subscribed || subscription.Email != email {
+			t.Errorf("Expected %s subscribed with the settings of the deployer, got %+v", contract, subscription)
+		}
+	}
+	if addresses := ethParser.GetEntityAddresses("treasury"); len(addresses) != 3 {
+		t.Errorf("Expected the contracts in the entity of the deployer, got %v", addresses)
+	}
+}
+
+func TestEthParserDeploymentMonitoringWithoutSubscription(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{
+		{Hash: "0xd1", From: testDeployer, Value: "0x0", Nonce: "0x0", Input: "0x6080"},
+	}})
+	var events []parser.Event
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithDeploymentMonitoring(false),
+		parser.WithEventNotification(func(event parser.Event) { events = append(events, event) }))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe(testDeployer)
+	ethParser.ProcessNextCycle()
+
+	if len(events) != 1 || events[0].Data["subscribed"] != "false" {
+		t.Fatalf("Expected a deployment event without subscription, got %+v", events)
+	}
+	if _, subscribed := ethParser.GetSubscription(events[0].Data["contract"]); subscribed {
+		t.Error("Expected the contract not to be subscribed")
+	}
+}
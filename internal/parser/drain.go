@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// defaultShutdownDrain bounds the drain of Drain, see WithShutdownDrain
+const defaultShutdownDrain = 10 * time.Second
+
+// Flusher is implemented by the notifiers buffering notifications, e.g. the email digests, flushed by Drain
+// when registered with WithFlusher
+type Flusher interface {
+	// Flush delivers the buffered notifications, it returns an error when some couldn't be delivered
+	Flush(ctx context.Context) error
+}
+
+// namedFlusher is a Flusher registered with WithFlusher
+type namedFlusher struct {
+	name    string
+	flusher Flusher
+}
+
+// Drain flushes what the stopped background tasks left behind, within the deadline of WithShutdownDrain: it
+// delivers the pending outbox events, the matched transactions saved but not notified yet, then flushes the
+// notifiers registered with WithFlusher. What couldn't be flushed is logged and returned as an error; the
+// outbox events left are delivered after the restart with a persistent storage. Call it after Stop.
+func (p *EthParser) Drain() error {
+	if p.shutdownDrain <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.shutdownDrain)
+	defer cancel()
+	log.Println("Draining the pending notifications...")
+
+	var errs []error
+	dispatched := make(chan struct{})
+	go func() {
+		defer close(dispatched)
+		p.dispatchOutbox()
+	}()
+	select {
+	case <-dispatched:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("outbox: drain deadline of %s exceeded", p.shutdownDrain))
+	}
+	if events, err := p.storage.PendingOutboxEvents(outboxBatchSize); err != nil {
+		errs = append(errs, fmt.Errorf("outbox: %w", err))
+	} else if len(events) > 0 {
+		count := fmt.Sprint(len(events))
+		if len(events) == outboxBatchSize {
+			count = "at least " + count
+		}
+		errs = append(errs, fmt.Errorf("outbox: %s notifications not delivered", count))
+	}
+
+	for _, registered := range p.flushers {
+		if ctx.Err() != nil {
+			errs = append(errs, fmt.Errorf("%s: drain deadline of %s exceeded", registered.name, p.shutdownDrain))
+			continue
+		}
+		if err := registered.flusher.Flush(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", registered.name, err))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		log.Printf("Error draining the pending notifications: %v\n", err)
+		return err
+	}
+	log.Println("Pending notifications drained")
+	return nil
+}
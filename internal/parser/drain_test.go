@@ -0,0 +1,89 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blockingFlusher counts its flushes and blocks until ctx is done when block is set
+type blockingFlusher struct {
+	block   bool
+	flushes int
+}
+
+func (f *blockingFlusher) Flush(ctx context.Context) error {
+	f.flushes++
+	if f.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func TestDrainDeliversPendingOutboxEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var notified []string
+	flusher := &blockingFlusher{}
+	storage := NewMockStorage()
+	ethParser := parser.NewEthParser(ctx, storage, 1, NewMockClient(NewMockBlockchain()),
+		func(address string, transactions []parser.Transaction) {
+			notified = append(notified, transactions[0].Hash)
+		},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithFlusher("test", flusher))
+	ethParser.Subscribe("0x1")
+
+	// A transaction saved by a cycle interrupted before its delivery
+	err := storage.WithTx(func(tx parser.StorageTx) error {
+		return tx.AddOutboxEvent(parser.OutboxEvent{ID: "1:0x1", Address: "0x1", BlockNumber: 1,
+			Transactions: []parser.Transaction{{Hash: "0xa1", From: "0x1", To: "0x2", Value: "100"}}})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ethParser.WaitForShutdown()
+	if len(notified) != 1 || notified[0] != "0xa1" {
+		t.Errorf("Expected the pending event to be delivered on shutdown, got %v", notified)
+	}
+	if flusher.flushes != 1 {
+		t.Errorf("Expected the flusher to be flushed once, got %d", flusher.flushes)
+	}
+	if events, _ := storage.PendingOutboxEvents(10); len(events) != 0 {
+		t.Errorf("Expected an empty outbox, got %v", events)
+	}
+}
+
+func TestDrainDeadline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	flusher := &blockingFlusher{block: true}
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(NewMockBlockchain()),
+		func(string, []parser.Transaction) {}, parser.WithClock(parser.NewManualClock(time.Now())),
+		parser.WithFlusher("blocking", flusher), parser.WithShutdownDrain(20*time.Millisecond))
+	ethParser.Stop(context.Background())
+
+	err := ethParser.Drain()
+	if err == nil || !strings.Contains(err.Error(), "blocking") {
+		t.Errorf("Expected the blocking flusher to be reported, got %v", err)
+	}
+}
+
+func TestDrainDisabled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	flusher := &blockingFlusher{}
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(NewMockBlockchain()),
+		func(string, []parser.Transaction) {}, parser.WithClock(parser.NewManualClock(time.Now())),
+		parser.WithFlusher("test", flusher), parser.WithShutdownDrain(0))
+	ethParser.WaitForShutdown()
+	if flusher.flushes != 0 {
+		t.Errorf("Expected no flush with the drain disabled, got %d", flusher.flushes)
+	}
+}
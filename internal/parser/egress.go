@@ -0,0 +1,289 @@
+package parser
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EgressConfig routes the connections to a node endpoint through a specific egress point
+type EgressConfig struct {
+	// ProxyURL is an http:// or socks5:// proxy, with optional user:password. socks5h:// lets the proxy
+	// resolve the node host, as required by Tor.
+	ProxyURL string
+	// DNSServer is the host:port of the DNS server resolving the node and proxy hosts, the system resolver when empty
+	DNSServer string
+	// SourceAddr is the local IP address the connections are bound to, e.g. the one of a specific interface
+	SourceAddr string
+}
+
+// egressDialer opens the connections of an EgressConfig
+type egressDialer struct {
+	proxy  *url.URL
+	dialer *net.Dialer
+}
+
+// newEgressDialer validates an EgressConfig and creates its dialer
+func newEgressDialer(config EgressConfig) (*egressDialer, error) {
+	d := &egressDialer{dialer: &net.Dialer{}}
+	if config.ProxyURL != "" {
+		proxy, err := url.Parse(config.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		switch proxy.Scheme {
+		case "http", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q, expected http, socks5 or socks5h", proxy.Scheme)
+		}
+		d.proxy = proxy
+	}
+	var sourceIP net.IP
+	if config.SourceAddr != "" {
+		sourceIP = net.ParseIP(config.SourceAddr)
+		if sourceIP == nil {
+			return nil, fmt.Errorf("invalid source address %q", config.SourceAddr)
+		}
+		d.dialer.LocalAddr = &net.TCPAddr{IP: sourceIP}
+	}
+	if config.DNSServer != "" {
+		if _, _, err := net.SplitHostPort(config.DNSServer); err != nil {
+			return nil, fmt.Errorf("invalid DNS server %q: %w", config.DNSServer, err)
+		}
+		d.dialer.Resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				// The queries are bound to the same source address as the connections
+				var dnsDialer net.Dialer
+				if sourceIP != nil && strings.HasPrefix(network, "udp") {
+					dnsDialer.LocalAddr = &net.UDPAddr{IP: sourceIP}
+				} else if sourceIP != nil {
+					dnsDialer.LocalAddr = &net.TCPAddr{IP: sourceIP}
+				}
+				return dnsDialer.DialContext(ctx, network, config.DNSServer)
+			},
+		}
+	}
+	return d, nil
+}
+
+// httpClient returns an HTTP client using the egress. HTTP proxies forward plain requests and tunnel the TLS ones,
+// SOCKS proxies tunnel every connection.
+func (d *egressDialer) httpClient() *http.Client {
+	transport := newRPCTransport()
+	transport.Proxy = nil
+	transport.DialContext = d.DialContext
+	if d.proxy != nil && d.proxy.Scheme == "http" {
+		transport.Proxy = http.ProxyURL(d.proxy)
+		transport.DialContext = d.dialer.DialContext
+	}
+	return &http.Client{Transport: transport}
+}
+
+// DialContext opens a TCP connection to addr, tunneled through the proxy when one is configured
+func (d *egressDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d.proxy == nil {
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+
+	proxyAddr := d.proxy.Host
+	if d.proxy.Port() == "" {
+		port := "1080"
+		if d.proxy.Scheme == "http" {
+			port = "80"
+		}
+		proxyAddr = net.JoinHostPort(d.proxy.Hostname(), port)
+	}
+	conn, err := d.dialer.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to proxy: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	switch d.proxy.Scheme {
+	case "http":
+		conn, err = d.httpConnect(conn, addr)
+	case "socks5":
+		// Resolve locally, with the configured resolver
+		var host, port string
+		host, port, err = net.SplitHostPort(addr)
+		if err == nil {
+			var ips []net.IPAddr
+			ips, err = d.resolver().LookupIPAddr(ctx, host)
+			if err == nil && len(ips) == 0 {
+				err = fmt.Errorf("no address for %s", host)
+			}
+			if err == nil {
+				err = d.socks5Connect(conn, net.JoinHostPort(ips[0].IP.String(), port))
+			}
+		}
+	default:
+		err = d.socks5Connect(conn, addr)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s: %w", d.proxy.Redacted(), err)
+	}
+	return conn, nil
+}
+
+// resolver returns the configured resolver or the system one
+func (d *egressDialer) resolver() *net.Resolver {
+	if d.dialer.Resolver != nil {
+		return d.dialer.Resolver
+	}
+	return net.DefaultResolver
+}
+
+// httpConnect opens a tunnel with the HTTP CONNECT method
+func (d *egressDialer) httpConnect(conn net.Conn, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if user := d.proxy.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CONNECT failed: %s", resp.Status)
+	}
+	if br.Buffered() > 0 {
+		return nil, errors.New("CONNECT failed: unexpected data after the response")
+	}
+	return conn, nil
+}
+
+// SOCKS5 protocol constants, see RFC 1928 and RFC 1929
+const (
+	socks5Version          = 0x05
+	socks5NoAuth           = 0x00
+	socks5UserPassAuth     = 0x02
+	socks5NoAcceptable     = 0xff
+	socks5Connect          = 0x01
+	socks5AddrIPv4         = 0x01
+	socks5AddrDomain       = 0x03
+	socks5AddrIPv6         = 0x04
+	socks5UserPassVersion  = 0x01
+	socks5Succeeded        = 0x00
+	socks5MaxDomainLength  = 255
+	socks5MaxCredentialLen = 255
+)
+
+// socks5Connect negotiates the authentication and opens a SOCKS5 tunnel to addr. Host names are sent to the
+// proxy, which resolves them.
+func (d *egressDialer) socks5Connect(conn net.Conn, addr string) error {
+	host, portString, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portString, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port %q", portString)
+	}
+
+	methods := []byte{socks5NoAuth}
+	if d.proxy.User != nil {
+		methods = []byte{socks5UserPassAuth}
+	}
+	if _, err := conn.Write(append([]byte{socks5Version, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version || reply[1] == socks5NoAcceptable || reply[1] != methods[0] {
+		return errors.New("no acceptable SOCKS5 authentication method")
+	}
+
+	if reply[1] == socks5UserPassAuth {
+		username := d.proxy.User.Username()
+		password, _ := d.proxy.User.Password()
+		if len(username) > socks5MaxCredentialLen || len(password) > socks5MaxCredentialLen {
+			return errors.New("SOCKS5 credentials too long")
+		}
+		auth := []byte{socks5UserPassVersion, byte(len(username))}
+		auth = append(auth, username...)
+		auth = append(auth, byte(len(password)))
+		auth = append(auth, password...)
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != socks5Succeeded {
+			return errors.New("SOCKS5 authentication failed")
+		}
+	}
+
+	req := []byte{socks5Version, socks5Connect, 0x00}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(append(req, socks5AddrIPv4), ip4...)
+		} else {
+			req = append(append(req, socks5AddrIPv6), ip.To16()...)
+		}
+	} else {
+		if len(host) > socks5MaxDomainLength {
+			return fmt.Errorf("host name too long: %s", host)
+		}
+		req = append(append(req, socks5AddrDomain, byte(len(host))), host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	// Reply: version, status, reserved, bound address type, bound address, bound port
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[1] != socks5Succeeded {
+		return fmt.Errorf("SOCKS5 connect failed with status %d", header[1])
+	}
+	var boundLength int
+	switch header[3] {
+	case socks5AddrIPv4:
+		boundLength = net.IPv4len
+	case socks5AddrIPv6:
+		boundLength = net.IPv6len
+	case socks5AddrDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return err
+		}
+		boundLength = int(length[0])
+	default:
+		return fmt.Errorf("invalid SOCKS5 address type %d", header[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, boundLength+2))
+	return err
+}
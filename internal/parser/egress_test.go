@@ -0,0 +1,163 @@
+package parser_test
+
+import (
+	"encoding/binary"
+	"eth-parser/internal/parser"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// nodeServer answers eth_blockNumber with 0x10
+func nodeServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":"0x10"}`)
+	}))
+}
+
+// socks5Server is a minimal SOCKS5 proxy with username/password authentication recording the requested targets
+type socks5Server struct {
+	listener net.Listener
+	mu       sync.Mutex
+	targets  []string
+}
+
+func newSOCKS5Server(t *testing.T, resolve map[string]string) *socks5Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &socks5Server{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, resolve)
+		}
+	}()
+	return s
+}
+
+func (s *socks5Server) serve(conn net.Conn, resolve map[string]string) {
+	defer conn.Close()
+	buf := make([]byte, 512)
+	// Greeting, username/password required
+	io.ReadFull(conn, buf[:2])
+	io.ReadFull(conn, buf[:buf[1]])
+	conn.Write([]byte{5, 2})
+	io.ReadFull(conn, buf[:2])
+	username := make([]byte, buf[1])
+	io.ReadFull(conn, username)
+	io.ReadFull(conn, buf[:1])
+	password := make([]byte, buf[0])
+	io.ReadFull(conn, password)
+	if string(username) != "user" || string(password) != "secret" {
+		conn.Write([]byte{1, 1})
+		return
+	}
+	conn.Write([]byte{1, 0})
+
+	// Connect request
+	io.ReadFull(conn, buf[:4])
+	var host string
+	switch buf[3] {
+	case 1:
+		io.ReadFull(conn, buf[:4])
+		host = net.IP(buf[:4]).String()
+	case 3:
+		io.ReadFull(conn, buf[:1])
+		name := make([]byte, buf[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	}
+	io.ReadFull(conn, buf[:2])
+	target := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(buf[:2]))))
+	s.mu.Lock()
+	s.targets = append(s.targets, target)
+	s.mu.Unlock()
+	if resolved, ok := resolve[host]; ok {
+		target = net.JoinHostPort(resolved, strconv.Itoa(int(binary.BigEndian.Uint16(buf[:2]))))
+	}
+
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer upstream.Close()
+	conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+	go io.Copy(upstream, conn)
+	io.Copy(conn, upstream)
+}
+
+func TestJsonRpcClientThroughSOCKS5(t *testing.T) {
+	node := nodeServer()
+	defer node.Close()
+	_, port, _ := net.SplitHostPort(node.Listener.Addr().String())
+
+	// socks5h sends the host name to the proxy, which resolves it, as Tor does
+	proxy := newSOCKS5Server(t, map[string]string{"node.onion": "127.0.0.1"})
+	defer proxy.listener.Close()
+
+	client, err := parser.NewJsonRpcClientWithEgress("http://node.onion:"+port,
+		parser.EgressConfig{ProxyURL: "socks5h://user:secret@" + proxy.listener.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.SendRequest(parser.JSONRPCRequest{JSONRPC: "2.0", Method: "eth_blockNumber", ID: 1})
+	if err != nil {
+		t.Fatalf("Request through the proxy failed: %v", err)
+	}
+	if resp.Result != "0x10" {
+		t.Fatalf("Unexpected result %v", resp.Result)
+	}
+	if len(proxy.targets) != 1 || proxy.targets[0] != "node.onion:"+port {
+		t.Fatalf("Expected the proxy to resolve node.onion, got %v", proxy.targets)
+	}
+
+	// Wrong credentials are rejected
+	client, _ = parser.NewJsonRpcClientWithEgress(node.URL,
+		parser.EgressConfig{ProxyURL: "socks5://user:wrong@" + proxy.listener.Addr().String()})
+	if _, err := client.SendRequest(parser.JSONRPCRequest{JSONRPC: "2.0", Method: "eth_blockNumber", ID: 1}); err == nil {
+		t.Fatal("Expected the authentication to fail")
+	}
+}
+
+func TestJsonRpcClientThroughHTTPProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":"0x20"}`)
+	}))
+	defer proxy.Close()
+
+	client, err := parser.NewJsonRpcClientWithEgress("http://node.example:8545", parser.EgressConfig{ProxyURL: proxy.URL, SourceAddr: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.SendRequest(parser.JSONRPCRequest{JSONRPC: "2.0", Method: "eth_blockNumber", ID: 1})
+	if err != nil || resp.Result != "0x20" {
+		t.Fatalf("Request through the proxy failed: %v %v", resp, err)
+	}
+	if len(proxied) != 1 || proxied[0] != "http://node.example:8545/" {
+		t.Fatalf("Expected the request to be forwarded by the proxy, got %v", proxied)
+	}
+}
+
+func TestEgressConfigValidation(t *testing.T) {
+	for _, egress := range []parser.EgressConfig{
+		{ProxyURL: "ftp://proxy:21"},
+		{SourceAddr: "not-an-ip"},
+		{DNSServer: "1.1.1.1"},
+	} {
+		if _, err := parser.NewJsonRpcClientWithEgress("http://node", egress); err == nil {
+			t.Errorf("Expected %+v to be rejected", egress)
+		}
+	}
+}
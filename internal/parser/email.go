@@ -0,0 +1,274 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Email digest schedules, an empty Digest sends one email per transaction
+const (
+	DigestHourly = "hourly"
+	DigestDaily  = "daily"
+)
+
+// MaxEmailRecipients caps the recipients of the email notifications of a subscription
+const MaxEmailRecipients = 10
+
+// EmailConfig is the email notification setting of a subscription
+type EmailConfig struct {
+	Recipients []string `json:"recipients"`
+	Digest     string   `json:"digest,omitempty"`
+}
+
+// ValidateEmailRecipients checks that there are 1 to MaxEmailRecipients recipients, each an RFC 5322 address
+// on a single line, so that they can't inject headers in the emails
+func ValidateEmailRecipients(recipients []string) error {
+	if len(recipients) == 0 || len(recipients) > MaxEmailRecipients {
+		return fmt.Errorf("1 to %d recipients are required", MaxEmailRecipients)
+	}
+	for _, recipient := range recipients {
+		if _, err := parseRecipient(recipient); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseRecipient parses an email recipient, rejecting the line breaks net/mail accepts in folded addresses
+func parseRecipient(recipient string) (*mail.Address, error) {
+	if strings.ContainsAny(recipient, "\r\n") {
+		return nil, fmt.Errorf("invalid recipient %q: line breaks aren't allowed", recipient)
+	}
+	address, err := mail.ParseAddress(recipient)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient %q: %w", recipient, err)
+	}
+	return address, nil
+}
+
+// SMTPConfig holds the SMTP server settings of the EmailNotifier
+type SMTPConfig struct {
+	Addr     string // host:port of the SMTP server
+	Username string
+	Password string
+	From     string
+}
+
+var transactionEmailTemplate = template.Must(template.New("transaction").Parse(`<html><body>
+<h2>New transaction for {{.Address}}</h2>
+<table>
+<tr><td>Hash</td><td>{{if .Link}}<a href="{{.Link}}">{{.Transaction.Hash}}</a>{{else}}{{.Transaction.Hash}}{{end}}</td></tr>
+<tr><td>From</td><td>{{.Transaction.From}}{{with .Transaction.FromLabel}} ({{.}}){{end}}</td></tr>
+<tr><td>To</td><td>{{.Transaction.To}}{{with .Transaction.ToLabel}} ({{.}}){{end}}</td></tr>
+<tr><td>Value</td><td>{{.Transaction.Value}}</td></tr>
+<tr><td>Block</td><td>{{.Transaction.BlockNumberDecimal}}</td></tr>
+{{with .Transaction.Metadata}}<tr><td>Metadata</td><td>{{printf "%s" .}}</td></tr>{{end}}
+</table>
+</body></html>`))
+
+var digestEmailTemplate = template.Must(template.New("digest").Parse(`<html><body>
+<h2>{{len .Transactions}} transactions for {{.Address}}</h2>
+<table>
+<tr><th>Block</th><th>Hash</th><th>From</th><th>To</th><th>Value</th></tr>
+{{range .Transactions}}<tr><td>{{.BlockNumberDecimal}}</td><td>{{$link := call $.Link .Hash}}{{if $link}}<a href="{{$link}}">{{.Hash}}</a>{{else}}{{.Hash}}{{end}}</td><td>{{.From}}{{with .FromLabel}} ({{.}}){{end}}</td><td>{{.To}}{{with .ToLabel}} ({{.}}){{end}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+</body></html>`))
+
+// EmailNotifier sends transaction notifications by email, immediately or as hourly/daily digests,
+// according to the EmailConfig of each subscription. Its Notify method is a NotificationFunc.
+type EmailNotifier struct {
+	config    SMTPConfig
+	network   Network
+	lookup    func(address string) []Subscription
+	clock     Clock
+	digests   map[string]*emailDigest
+	lastFlush map[string]time.Time
+	mu        sync.Mutex
+}
+
+// emailDigest buffers the transactions of a subscription until its digest is due
+type emailDigest struct {
+	subscription Subscription
+	transactions []Transaction
+}
+
+// NewEmailNotifier creates an EmailNotifier. lookup returns the subscriptions of an address, one per
+// tenant when multi-tenancy is enabled, see TenantManager.SubscriptionsFor.
+func NewEmailNotifier(config SMTPConfig, lookup func(address string) []Subscription) *EmailNotifier {
+	now := time.Now()
+	return &EmailNotifier{
+		config:    config,
+		lookup:    lookup,
+		clock:     realClock{},
+		digests:   make(map[string]*emailDigest),
+		lastFlush: map[string]time.Time{DigestHourly: now, DigestDaily: now},
+	}
+}
+
+// WithNetwork adds the explorer links of the network to the emails
+func (n *EmailNotifier) WithNetwork(network Network) *EmailNotifier {
+	n.network = network
+	return n
+}
+
+// Notify emails the transactions or buffers them for the digest, for every subscription of the address
+func (n *EmailNotifier) Notify(address string, transactions []Transaction) {
+	for _, subscription := range n.lookup(address) {
+		if subscription.Email == nil || len(subscription.Email.Recipients) == 0 {
+			continue
+		}
+		// The tenants mute their own subscriptions
+		if subscription.MutedAt(n.clock.Now()) && !isTestNotification(transactions) {
+			continue
+		}
+
+		// The metadata is the one of the tenant subscription
+		transactions := transactions
+		if subscription.Metadata != nil {
+			transactions = withMetadata(transactions, subscription.Metadata)
+		}
+
+		// Test notifications are sent right away to check the configuration
+		if subscription.Email.Digest != "" && !isTestNotification(transactions) {
+			key := subscription.Tenant + "/" + address
+			n.mu.Lock()
+			if n.digests[key] == nil {
+				n.digests[key] = &emailDigest{}
+			}
+			n.digests[key].subscription = subscription
+			n.digests[key].transactions = append(n.digests[key].transactions, transactions...)
+			n.mu.Unlock()
+			continue
+		}
+
+		for _, tx := range transactions {
+			err := n.send(subscription.Email.Recipients, "New transaction for "+address, transactionEmailTemplate,
+				map[string]interface{}{"Address": address, "Transaction": tx, "Link": n.network.TransactionURL(tx.Hash)})
+			if err != nil {
+				log.Printf("Error sending email for transaction %s: %v\n", tx.Hash, err)
+			}
+		}
+	}
+}
+
+// Run sends the digests when their schedule is due, until ctx is canceled
+func (n *EmailNotifier) Run(ctx context.Context) {
+	ticker := n.clock.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			n.flushDue(n.clock.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// flushDue sends the buffered digests of the schedules whose period boundary has passed since their last flush
+func (n *EmailNotifier) flushDue(now time.Time) {
+	due := make(map[string]bool)
+	toSend := []*emailDigest{}
+	n.mu.Lock()
+	for schedule, period := range map[string]time.Duration{DigestHourly: time.Hour, DigestDaily: 24 * time.Hour} {
+		if n.lastFlush[schedule].Before(now.Truncate(period)) {
+			due[schedule] = true
+			n.lastFlush[schedule] = now
+		}
+	}
+	for key, digest := range n.digests {
+		if due[digest.subscription.Email.Digest] {
+			toSend = append(toSend, digest)
+			delete(n.digests, key)
+		}
+	}
+	n.mu.Unlock()
+
+	for _, digest := range toSend {
+		if err := n.sendDigest(digest); err != nil {
+			log.Printf("Error sending email digest for address %s: %v\n", digest.subscription.Address, err)
+		}
+	}
+}
+
+// Flush sends the buffered digests before they're due, e.g. on shutdown, see WithFlusher. The digests not sent
+// when ctx is done, or failing, are dropped and returned in the error.
+func (n *EmailNotifier) Flush(ctx context.Context) error {
+	n.mu.Lock()
+	toSend := make([]*emailDigest, 0, len(n.digests))
+	for key, digest := range n.digests {
+		toSend = append(toSend, digest)
+		delete(n.digests, key)
+	}
+	n.mu.Unlock()
+
+	var errs []error
+	for _, digest := range toSend {
+		err := ctx.Err()
+		if err == nil {
+			err = n.sendDigest(digest)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("digest of %d transactions for address %s: %w", len(digest.transactions), digest.subscription.Address, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sendDigest emails a digest to the recipients of its subscription
+func (n *EmailNotifier) sendDigest(digest *emailDigest) error {
+	subscription := digest.subscription
+	return n.send(subscription.Email.Recipients,
+		fmt.Sprintf("%s digest: %d transactions for %s", subscription.Email.Digest, len(digest.transactions), subscription.Address),
+		digestEmailTemplate, map[string]interface{}{"Address": subscription.Address, "Transactions": digest.transactions, "Link": n.network.TransactionURL})
+}
+
+// send renders an HTML email and sends it through the SMTP server. The recipients are parsed again and the
+// headers checked for line breaks, for the subscriptions saved before their recipients were validated.
+func (n *EmailNotifier) send(to []string, subject string, tmpl *template.Template, data interface{}) error {
+	recipients := make([]string, len(to))
+	headers := make([]string, len(to))
+	for i, recipient := range to {
+		address, err := parseRecipient(recipient)
+		if err != nil {
+			return err
+		}
+		recipients[i], headers[i] = address.Address, address.String()
+	}
+	if strings.ContainsAny(subject, "\r\n") || strings.ContainsAny(n.config.From, "\r\n") {
+		return errors.New("line breaks aren't allowed in the email headers")
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, data); err != nil {
+		return err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(headers, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=\"UTF-8\"\r\n\r\n")
+	msg.Write(body.Bytes())
+
+	var auth smtp.Auth
+	if n.config.Username != "" {
+		host, _, err := net.SplitHostPort(n.config.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", n.config.Username, n.config.Password, host)
+	}
+	return smtp.SendMail(n.config.Addr, auth, n.config.From, recipients, msg.Bytes())
+}
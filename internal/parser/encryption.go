@@ -0,0 +1,103 @@
+package parser
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// encryptedPrefix marks the values sealed by a FieldCipher, the values without it are read as plaintext
+// so that encryption can be enabled on an existing database
+const encryptedPrefix = "enc:v1:"
+
+// ErrDecryption is returned when a sealed value can't be opened: wrong key, or tampered value
+var ErrDecryption = errors.New("decryption failed")
+
+// FieldCipher encrypts the fields stored at rest. The associated data binds a sealed value to its row,
+// so that a value copied to another row fails to open.
+type FieldCipher interface {
+	Seal(plaintext []byte, associatedData []byte) (string, error)
+	Open(sealed string, associatedData []byte) ([]byte, error)
+}
+
+// AESGCMCipher is a FieldCipher using AES-GCM with a random nonce per value
+type AESGCMCipher struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCMCipher creates an AESGCMCipher from a 16, 24 or 32 bytes key (AES-128, AES-192, AES-256)
+func NewAESGCMCipher(key []byte) (*AESGCMCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AESGCMCipher{aead: aead}, nil
+}
+
+// Seal encrypts plaintext, the result is the base64 of the nonce followed by the ciphertext
+func (c *AESGCMCipher) Seal(plaintext []byte, associatedData []byte) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plaintext)+c.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, plaintext, associatedData)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value returned by Seal with the same associated data
+func (c *AESGCMCipher) Open(sealed string, associatedData []byte) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, encryptedPrefix))
+	if err != nil || len(raw) < c.aead.NonceSize() {
+		return nil, ErrDecryption
+	}
+	plaintext, err := c.aead.Open(nil, raw[:c.aead.NonceSize()], raw[c.aead.NonceSize():], associatedData)
+	if err != nil {
+		return nil, ErrDecryption
+	}
+	return plaintext, nil
+}
+
+// KeyDecrypter unwraps a data key encrypted by a key management service, e.g. an adapter of the
+// Decrypt API of AWS KMS, GCP Cloud KMS or Vault transit
+type KeyDecrypter interface {
+	DecryptKey(ctx context.Context, wrappedKey []byte) ([]byte, error)
+}
+
+// NewKMSCipher creates an AESGCMCipher from a data key wrapped by a KMS (envelope encryption): only the
+// wrapped key is configured, the KMS decrypts it once at startup and the key stays in memory
+func NewKMSCipher(ctx context.Context, kms KeyDecrypter, wrappedKey []byte) (*AESGCMCipher, error) {
+	key, err := kms.DecryptKey(ctx, wrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping the data key: %w", err)
+	}
+	return NewAESGCMCipher(key)
+}
+
+// sealField encrypts a stored field, it's returned unchanged without cipher
+func sealField(c FieldCipher, plaintext string, associatedData string) (string, error) {
+	if c == nil {
+		return plaintext, nil
+	}
+	return c.Seal([]byte(plaintext), []byte(associatedData))
+}
+
+// openField decrypts a stored field sealed by sealField, plaintext fields are returned unchanged
+func openField(c FieldCipher, stored string, associatedData string) (string, error) {
+	if !strings.HasPrefix(stored, encryptedPrefix) {
+		return stored, nil
+	}
+	if c == nil {
+		return "", fmt.Errorf("%w: encrypted value without cipher", ErrDecryption)
+	}
+	plaintext, err := c.Open(stored, []byte(associatedData))
+	return string(plaintext), err
+}
@@ -0,0 +1,82 @@
+package parser_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"strings"
+	"testing"
+)
+
+func TestAESGCMCipher(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	cipher, err := parser.NewAESGCMCipher(key)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	sealed, err := cipher.Seal([]byte(`{"hash":"0xa1"}`), []byte("row-1"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Contains(sealed, "0xa1") {
+		t.Fatalf("Expected an encrypted value, got %s", sealed)
+	}
+	again, _ := cipher.Seal([]byte(`{"hash":"0xa1"}`), []byte("row-1"))
+	if again == sealed {
+		t.Error("Expected a random nonce per value")
+	}
+	plaintext, err := cipher.Open(sealed, []byte("row-1"))
+	if err != nil || string(plaintext) != `{"hash":"0xa1"}` {
+		t.Fatalf("Expected the payload back, got %q, %v", plaintext, err)
+	}
+
+	// A value moved to another row, or opened with another key, fails
+	if _, err := cipher.Open(sealed, []byte("row-2")); !errors.Is(err, parser.ErrDecryption) {
+		t.Errorf("Expected ErrDecryption for another row, got %v", err)
+	}
+	other, _ := parser.NewAESGCMCipher(bytes.Repeat([]byte{2}, 32))
+	if _, err := other.Open(sealed, []byte("row-1")); !errors.Is(err, parser.ErrDecryption) {
+		t.Errorf("Expected ErrDecryption for another key, got %v", err)
+	}
+
+	if _, err := parser.NewAESGCMCipher([]byte("short")); err == nil {
+		t.Error("Expected an error for an invalid key size")
+	}
+}
+
+// xorKMS is a KeyDecrypter unwrapping keys XORed with a master byte
+type xorKMS struct {
+	fail bool
+}
+
+func (k xorKMS) DecryptKey(ctx context.Context, wrappedKey []byte) ([]byte, error) {
+	if k.fail {
+		return nil, errors.New("access denied")
+	}
+	key := make([]byte, len(wrappedKey))
+	for i, b := range wrappedKey {
+		key[i] = b ^ 0x5a
+	}
+	return key, nil
+}
+
+func TestKMSCipher(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	wrapped, _ := xorKMS{}.DecryptKey(context.Background(), key)
+
+	kmsCipher, err := parser.NewKMSCipher(context.Background(), xorKMS{}, wrapped)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	localCipher, _ := parser.NewAESGCMCipher(key)
+	sealed, _ := kmsCipher.Seal([]byte("payload"), nil)
+	if plaintext, err := localCipher.Open(sealed, nil); err != nil || string(plaintext) != "payload" {
+		t.Errorf("Expected the unwrapped data key to be used, got %q, %v", plaintext, err)
+	}
+
+	if _, err := parser.NewKMSCipher(context.Background(), xorKMS{fail: true}, wrapped); err == nil {
+		t.Error("Expected the KMS error")
+	}
+}
@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// ENSRegistryAddress is the address of the ENS registry on mainnet and on the Sepolia and Holesky testnets
+const ENSRegistryAddress = "0x00000000000c2e074ec69a0dfb2997ba6c7d2e1e"
+
+// ensRetryPeriod is the time after which the name of an address whose resolution failed is tried again
+const ensRetryPeriod = time.Minute
+
+// ensABI is the ABI of the registry and resolver methods of the reverse resolution
+const ensABI = `[
+	{"type": "function", "name": "resolver", "inputs": [{"name": "node", "type": "bytes32"}], "outputs": [{"name": "", "type": "address"}], "stateMutability": "view"},
+	{"type": "function", "name": "name", "inputs": [{"name": "node", "type": "bytes32"}], "outputs": [{"name": "", "type": "string"}], "stateMutability": "view"},
+	{"type": "function", "name": "addr", "inputs": [{"name": "node", "type": "bytes32"}], "outputs": [{"name": "", "type": "address"}], "stateMutability": "view"}
+]`
+
+// bundledENSABI is the parsed ensABI
+var bundledENSABI = mustParseABI(ensABI)
+
+// zeroAddress is returned by the registry for a node without resolver
+const zeroAddress = "0x0000000000000000000000000000000000000000"
+
+// ensCacheEntry is the resolved primary name of an address, empty when it has none, or the error of its
+// resolution
+type ensCacheEntry struct {
+	name       string
+	err        error
+	resolvedAt time.Time
+}
+
+// ENSName returns the primary ENS name of an address, empty when it has none. The name of the reverse record
+// is only returned when it resolves back to the address, since anyone can set the reverse record of their
+// address to any name. Names are cached for the TTL of WithENSNames, failed resolutions for ensRetryPeriod.
+func (p *EthParser) ENSName(address string) (string, error) {
+	address = strings.ToLower(address)
+	now := p.clock.Now()
+	p.mu.Lock()
+	entry, ok := p.ensNames[address]
+	p.mu.Unlock()
+	if ok && now.Sub(entry.resolvedAt) < p.ensTTL && (entry.err == nil || now.Sub(entry.resolvedAt) < ensRetryPeriod) {
+		return entry.name, entry.err
+	}
+
+	name, err := p.resolveENSName(address)
+	p.mu.Lock()
+	p.ensNames[address] = ensCacheEntry{name: name, err: err, resolvedAt: now}
+	p.mu.Unlock()
+	return name, err
+}
+
+// resolveENSName reads the name of the reverse record of an address and checks that it resolves to it
+func (p *EthParser) resolveENSName(address string) (string, error) {
+	reverseNode := ensNamehash(strings.TrimPrefix(address, "0x") + ".addr.reverse")
+	resolver, err := p.callENS(ENSRegistryAddress, "resolver", reverseNode)
+	if err != nil || resolver == zeroAddress {
+		return "", err
+	}
+	name, err := p.callENS(resolver, "name", reverseNode)
+	if err != nil || name == "" {
+		return "", err
+	}
+
+	node := ensNamehash(name)
+	if resolver, err = p.callENS(ENSRegistryAddress, "resolver", node); err != nil || resolver == zeroAddress {
+		return "", err
+	}
+	resolved, err := p.callENS(resolver, "addr", node)
+	if err != nil || resolved != address {
+		return "", err
+	}
+	return name, nil
+}
+
+// callENS calls a method of the ENS registry or of a resolver with a node and returns its output
+func (p *EthParser) callENS(contract string, method string, node string) (string, error) {
+	abiMethod, _ := bundledENSABI.Method(method, 1)
+	data, err := abiMethod.EncodeCall([]interface{}{node})
+	if err != nil {
+		return "", err
+	}
+	raw, err := EthCall(p.client, contract, data, "latest")
+	if err != nil {
+		return "", err
+	}
+	if raw == "0x" {
+		// Not a contract, or a resolver without the method
+		return "", nil
+	}
+	values, err := abiMethod.DecodeOutputs(raw)
+	if err != nil {
+		return "", err
+	}
+	return values[0].(string), nil
+}
+
+// ensNamehash returns the hex namehash of an ENS name (EIP-137). The name is lowercased but not normalized
+// with UTS-46, which the names of the reverse records already are.
+func ensNamehash(name string) string {
+	node := make([]byte, 32)
+	if name != "" {
+		labels := strings.Split(strings.ToLower(name), ".")
+		for i := len(labels) - 1; i >= 0; i-- {
+			node = keccak256(append(node, keccak256([]byte(labels[i]))...))
+		}
+	}
+	return "0x" + hex.EncodeToString(node)
+}
+
+// annotateENSNames sets the primary ENS names of the sender and recipient of a transaction, when enabled
+func (p *EthParser) annotateENSNames(tx *Transaction) {
+	if p.ensTTL <= 0 {
+		return
+	}
+	if name, err := p.ENSName(tx.From); err == nil {
+		tx.FromENS = name
+	}
+	if tx.To == "" {
+		return
+	}
+	if name, err := p.ENSName(tx.To); err == nil {
+		tx.ToENS = name
+	}
+}
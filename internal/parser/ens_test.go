@@ -0,0 +1,73 @@
+package parser_test
+
+import (
+	"eth-parser/internal/parser"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	vitalikAddress = "0xd8da6bf26964af9d7eed9e03e53415d37aa96045"
+	spoofedAddress = "0x2222222222222222222222222222222222222222"
+	ensResolver    = "0x4976fb03c32e5b8cfe2b6ccb31c09ba78ebaba41"
+	// vitalikNode is the namehash of vitalik.eth
+	vitalikNode = "ee6c4522aab0003e8d14cd40a6af439055fd2577951148c14b6cea9a53475835"
+)
+
+// ensClient answers the ENS calls: every reverse record names vitalik.eth, which resolves to vitalikAddress
+type ensClient struct {
+	*MockClient
+	calls int
+}
+
+func (c *ensClient) SendRequest(req parser.JSONRPCRequest) (parser.JSONRPCResponse, error) {
+	if req.Method != "eth_call" {
+		return c.MockClient.SendRequest(req)
+	}
+	c.calls++
+	call := req.Params[0].(map[string]string)
+	selector, node := call["data"][:10], call["data"][10:]
+	switch {
+	case call["to"] == parser.ENSRegistryAddress && selector == "0x0178b8bf": // resolver(bytes32)
+		return parser.JSONRPCResponse{Result: "0x" + strings.Repeat("0", 24) + ensResolver[2:]}, nil
+	case call["to"] == ensResolver && selector == "0x691f3431": // name(bytes32)
+		return parser.JSONRPCResponse{Result: abiString("vitalik.eth")}, nil
+	case call["to"] == ensResolver && selector == "0x3b3b57de" && node == vitalikNode: // addr(bytes32)
+		return parser.JSONRPCResponse{Result: "0x" + strings.Repeat("0", 24) + vitalikAddress[2:]}, nil
+	}
+	return parser.JSONRPCResponse{}, fmt.Errorf("execution reverted")
+}
+
+func TestENSNames(t *testing.T) {
+	storage := NewMockStorage()
+	storage.SaveTransactions(vitalikAddress, []parser.Transaction{{Hash: "0xa1", From: vitalikAddress, To: spoofedAddress, Value: "0x1", BlockNumber: "0x1"}})
+	client := &ensClient{MockClient: NewMockClient(NewMockBlockchain())}
+	clock := parser.NewManualClock(time.Now())
+
+	disabled := parser.New(storage, 1, client, func(string, []parser.Transaction) {})
+	if tx := disabled.GetTransactions(vitalikAddress)[0]; tx.FromENS != "" || client.calls != 0 {
+		t.Fatalf("Expected no resolution without WithENSNames, got %+v after %d calls", tx, client.calls)
+	}
+
+	ethParser := parser.New(storage, 1, client, func(string, []parser.Transaction) {}, parser.WithClock(clock), parser.WithENSNames(time.Hour))
+	tx := ethParser.GetTransactions(vitalikAddress)[0]
+	if tx.FromENS != "vitalik.eth" {
+		t.Errorf("Expected the primary name of the sender, got %q", tx.FromENS)
+	}
+	// The reverse record of the recipient claims vitalik.eth, which doesn't resolve to it
+	if tx.ToENS != "" {
+		t.Errorf("Expected the unverified name of the recipient to be dropped, got %q", tx.ToENS)
+	}
+
+	calls := client.calls
+	ethParser.GetTransactions(vitalikAddress)
+	if client.calls != calls {
+		t.Errorf("Expected the names to be cached, got %d more calls", client.calls-calls)
+	}
+	clock.Advance(2 * time.Hour)
+	if ethParser.GetTransactions(vitalikAddress)[0].FromENS != "vitalik.eth" || client.calls == calls {
+		t.Errorf("Expected the names to be resolved again after the TTL, got %d calls", client.calls-calls)
+	}
+}
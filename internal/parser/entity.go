@@ -0,0 +1,174 @@
+package parser
+
+import "sort"
+
+// EntityTransaction is a transaction seen from the point of view of an entity.
+// Internal is true when both sender and recipient are member addresses of the entity.
+type EntityTransaction struct {
+	Transaction
+	Internal bool `json:"internal"`
+}
+
+// entityKey identifies an entity: its ID in its namespace, the tenant ID for the entities of a tenant or empty
+// for the ones of the shared parser. The entity IDs are free form, keying by the pair keeps the entities of a
+// namespace apart from the ones of the others whatever their IDs.
+type entityKey struct {
+	namespace string
+	id        string
+}
+
+// String names the entity in the notifications and the reports: its ID, prefixed with its tenant ID and a
+// slash for the entities of a tenant, which the tenant IDs can't contain
+func (k entityKey) String() string {
+	if k.namespace == "" {
+		return k.id
+	}
+	return k.namespace + "/" + k.id
+}
+
+// EntityNotificationFunc defines a function to send notifications at entity level
+type EntityNotificationFunc func(entityID string, transactions []EntityTransaction)
+
+// AddToEntity links an address to an entity and subscribes the address.
+// An address belongs to at most one entity; it returns false if it's already linked to one.
+func (p *EthParser) AddToEntity(entityID string, address string) bool {
+	return p.addToEntity("", entityID, address)
+}
+
+// addToEntity links an address to an entity of a namespace, an address belonging to at most one entity per
+// namespace, so that the entities of a tenant don't collide with the ones of the others
+func (p *EthParser) addToEntity(namespace string, entityID string, address string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, exists := p.addressEntities[address][namespace]; exists {
+		return false
+	}
+	key := entityKey{namespace, entityID}
+	if p.entities[key] == nil {
+		p.entities[key] = make(map[string]bool)
+	}
+	p.entities[key][address] = true
+	if p.addressEntities[address] == nil {
+		p.addressEntities[address] = make(map[string]string)
+	}
+	p.addressEntities[address][namespace] = entityID
+	if _, exists := p.subscriptions[address]; !exists {
+		p.subscriptions[address] = &Subscription{Address: address}
+	}
+	return true
+}
+
+// RemoveFromEntity unlinks an address from an entity, the address stays subscribed
+func (p *EthParser) RemoveFromEntity(entityID string, address string) bool {
+	return p.removeFromEntity("", entityID, address)
+}
+
+// removeFromEntity unlinks an address from an entity of a namespace
+func (p *EthParser) removeFromEntity(namespace string, entityID string, address string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if current, exists := p.addressEntities[address][namespace]; !exists || current != entityID {
+		return false
+	}
+	delete(p.addressEntities[address], namespace)
+	if len(p.addressEntities[address]) == 0 {
+		delete(p.addressEntities, address)
+	}
+	key := entityKey{namespace, entityID}
+	delete(p.entities[key], address)
+	if len(p.entities[key]) == 0 {
+		delete(p.entities, key)
+	}
+	return true
+}
+
+// GetEntityAddresses returns the sorted member addresses of an entity
+func (p *EthParser) GetEntityAddresses(entityID string) []string {
+	return p.entityAddresses(entityKey{id: entityID})
+}
+
+// entityAddresses returns the sorted member addresses of an entity of any namespace
+func (p *EthParser) entityAddresses(key entityKey) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	addresses := make([]string, 0, len(p.entities[key]))
+	for address := range p.entities[key] {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// GetEntityTransactions returns the transactions of all the member addresses of an entity, ordered by block.
+// Transfers between members appear once and are flagged as internal.
+func (p *EthParser) GetEntityTransactions(entityID string) []EntityTransaction {
+	return p.entityTransactions(entityKey{id: entityID})
+}
+
+// entityTransactions returns the transactions of an entity of any namespace
+func (p *EthParser) entityTransactions(key entityKey) []EntityTransaction {
+	p.mu.Lock()
+	members := make(map[string]bool, len(p.entities[key]))
+	for address := range p.entities[key] {
+		members[address] = true
+	}
+	p.mu.Unlock()
+
+	var result []EntityTransaction
+	seen := make(map[string]bool)
+	for address := range members {
+		for _, tx := range p.storage.GetTransactions(address) {
+			if seen[tx.Hash] {
+				continue
+			}
+			seen[tx.Hash] = true
+			result = append(result, EntityTransaction{Transaction: p.annotateTransaction(tx), Internal: members[tx.From] && members[tx.To]})
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].BlockNumberDecimal < result[j].BlockNumberDecimal
+	})
+	return result
+}
+
+// notifyEntities groups the matched transactions of a block per entity and sends entity level notifications
+func (p *EthParser) notifyEntities(transactionsForAddresses map[string][]Transaction) {
+	if p.notifyEntity == nil {
+		return
+	}
+
+	p.mu.Lock()
+	addressEntities := make(map[string]map[string]string, len(p.addressEntities))
+	for address, entities := range p.addressEntities {
+		addressEntities[address] = make(map[string]string, len(entities))
+		for namespace, entityID := range entities {
+			addressEntities[address][namespace] = entityID
+		}
+	}
+	p.mu.Unlock()
+
+	transactionsForEntities := make(map[entityKey][]EntityTransaction)
+	seen := make(map[entityKey]map[string]bool)
+	for address, transactions := range transactionsForAddresses {
+		for namespace, entityID := range addressEntities[address] {
+			key := entityKey{namespace, entityID}
+			if seen[key] == nil {
+				seen[key] = make(map[string]bool)
+			}
+			for _, tx := range transactions {
+				if seen[key][tx.Hash] {
+					continue
+				}
+				seen[key][tx.Hash] = true
+				internal := addressEntities[tx.From][namespace] == entityID && addressEntities[tx.To][namespace] == entityID
+				transactionsForEntities[key] = append(transactionsForEntities[key],
+					EntityTransaction{Transaction: p.annotateTransaction(tx).WithNumberEncoding(p.notificationEncoding), Internal: internal})
+			}
+		}
+	}
+
+	for key, transactions := range transactionsForEntities {
+		p.notifyEntity(key.String(), transactions)
+	}
+}
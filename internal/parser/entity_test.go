@@ -0,0 +1,61 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEthParserEntities(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{
+		Number: "0x1",
+		Transactions: []parser.Transaction{
+			{Hash: "0xabc", From: "0x1", To: "0x2", Value: "100"},
+			{Hash: "0xdef", From: "0x2", To: "0x3", Value: "200"},
+		},
+	})
+
+	notifications := make(map[string][]parser.EntityTransaction)
+	var mu sync.Mutex
+	notifyEntity := func(entityID string, transactions []parser.EntityTransaction) {
+		mu.Lock()
+		defer mu.Unlock()
+		notifications[entityID] = append(notifications[entityID], transactions...)
+	}
+
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain),
+		func(string, []parser.Transaction) {}, parser.WithEntityNotification(notifyEntity),
+		parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+
+	if !ethParser.AddToEntity("alice", "0x1") || !ethParser.AddToEntity("alice", "0x2") {
+		t.Fatal("Failed to add addresses to entity alice")
+	}
+	if ethParser.AddToEntity("bob", "0x2") {
+		t.Fatal("Address 0x2 must not belong to two entities")
+	}
+
+	ethParser.ProcessNextCycle()
+
+	transactions := ethParser.GetEntityTransactions("alice")
+	if len(transactions) != 2 {
+		t.Fatalf("Unexpected transactions for entity alice: %v", transactions)
+	}
+	for _, tx := range transactions {
+		if tx.Internal != (tx.Hash == "0xabc") {
+			t.Fatalf("Unexpected internal flag for transaction %s: %v", tx.Hash, tx.Internal)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(notifications["alice"]) != 2 {
+		t.Fatalf("Unexpected notifications for entity alice: %v", notifications["alice"])
+	}
+}
@@ -0,0 +1,393 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors of the EtherscanClient
+var (
+	ErrEtherscanRateLimited       = errors.New("etherscan rate limit reached")
+	ErrEtherscanUnsupportedMethod = errors.New("method not supported by the etherscan proxy module")
+)
+
+const (
+	// etherscanPageSize is the number of transactions requested per page of the account module
+	etherscanPageSize = 1000
+	// etherscanMaxResults is the number of results the account module pages through for a block range, the
+	// next ones are requested from the last block returned
+	etherscanMaxResults = 10000
+)
+
+// HistorySource returns the past transactions of an address without scanning the blocks, for the fast
+// backfills of Backfill, see WithHistorySource
+type HistorySource interface {
+	// AddressTransactions returns the transactions sent or received by the address in the block range, in
+	// block order
+	AddressTransactions(address string, fromBlock int, toBlock int) ([]Transaction, error)
+}
+
+// EtherscanClient reads the chain from an Etherscan-compatible HTTP API, e.g. Etherscan, its multichain V2
+// API with WithChainID, or a Blockscout instance. It's a JsonRpcClient through the proxy module for the
+// methods the module exposes, e.g. as the WithFallbackClient, and a HistorySource through the txlist and
+// tokentx actions of the account module. The requests are spaced to stay within the rate limit of the key.
+type EtherscanClient struct {
+	baseURL    string
+	apiKey     string
+	chainID    int64
+	httpClient *http.Client
+	interval   time.Duration // between two requests, zero when unlimited
+	mu         sync.Mutex
+	next       time.Time // earliest time of the next request
+	ctx        context.Context
+	cancel     context.CancelFunc
+}
+
+// NewEtherscanClient creates an EtherscanClient sending at most requestsPerSecond requests to the API at
+// baseURL, e.g. https://api.etherscan.io/api; zero disables the rate limiting
+func NewEtherscanClient(baseURL string, apiKey string, requestsPerSecond float64) *EtherscanClient {
+	client := &EtherscanClient{baseURL: baseURL, apiKey: apiKey, httpClient: http.DefaultClient}
+	if requestsPerSecond > 0 {
+		client.interval = time.Duration(float64(time.Second) / requestsPerSecond)
+	}
+	return client
+}
+
+// WithChainID selects the chain of a multichain API, such as https://api.etherscan.io/v2/api
+func (c *EtherscanClient) WithChainID(chainID int64) *EtherscanClient {
+	c.chainID = chainID
+	return c
+}
+
+// WithHTTPClient sends the requests with httpClient, e.g. one going through the egress proxy
+func (c *EtherscanClient) WithHTTPClient(httpClient *http.Client) *EtherscanClient {
+	c.httpClient = httpClient
+	return c
+}
+
+// CancelRequests aborts the in-flight requests, the next ones are sent normally
+func (c *EtherscanClient) CancelRequests() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+}
+
+// SendRequest sends a JSON-RPC request through the proxy module. A rate limited request is answered with
+// the JSON-RPC error -32005 of the providers, so that a MethodRouter falls back to the node.
+func (c *EtherscanClient) SendRequest(req JSONRPCRequest) (JSONRPCResponse, error) {
+	query, err := etherscanProxyQuery(req)
+	if err != nil {
+		return JSONRPCResponse{}, err
+	}
+	body, err := c.get(query)
+	if err != nil {
+		return JSONRPCResponse{}, err
+	}
+	// The API errors, e.g. an invalid key or the rate limit, have the shape of the account module
+	var status etherscanResponse
+	if json.Unmarshal(body, &status) == nil && status.Status == "0" {
+		err := status.err()
+		if errors.Is(err, ErrEtherscanRateLimited) {
+			rpcError := map[string]interface{}{"code": json.Number(strconv.Itoa(rateLimitCode)), "message": err.Error()}
+			return JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Error: rpcError}, fmt.Errorf("JSON-RPC error: %v", rpcError)
+		}
+		return JSONRPCResponse{}, err
+	}
+	resp, err := decodeRPCResponse(body)
+	resp.ID = req.ID
+	return resp, err
+}
+
+// AddressTransactions returns the transactions of the address listed by txlist. The token transfers of
+// tokentx annotate the calls of the address to their token contract, as the tokens stage does; the
+// transfers received from others aren't transactions of the address, the parser doesn't match them either.
+func (c *EtherscanClient) AddressTransactions(address string, fromBlock int, toBlock int) ([]Transaction, error) {
+	entries, err := c.accountEntries("txlist", address, fromBlock, toBlock)
+	if err != nil {
+		return nil, err
+	}
+	transfers, err := c.accountEntries("tokentx", address, fromBlock, toBlock)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make(map[string]etherscanTransaction)
+	for _, transfer := range transfers {
+		tokens[transfer.Hash] = transfer
+	}
+
+	transactions := make([]Transaction, 0, len(entries))
+	for _, entry := range entries {
+		tx, err := entry.transaction()
+		if err != nil {
+			return nil, fmt.Errorf("transaction %s: %w", entry.Hash, err)
+		}
+		if transfer, ok := tokens[tx.Hash]; ok && strings.EqualFold(transfer.ContractAddress, tx.To) {
+			tx.Category = CategoryTokenTransfer
+			tx.Token = transfer.token()
+		}
+		transactions = append(transactions, tx)
+	}
+	return transactions, nil
+}
+
+// accountEntries pages through the entries of an action of the account module in block order
+func (c *EtherscanClient) accountEntries(action string, address string, fromBlock int, toBlock int) ([]etherscanTransaction, error) {
+	var entries []etherscanTransaction
+	seen := make(map[etherscanTransaction]bool)
+	start := fromBlock
+	for {
+		lastBlock := start
+		for page := 1; page*etherscanPageSize <= etherscanMaxResults; page++ {
+			query := url.Values{
+				"module": {"account"}, "action": {action}, "address": {address}, "sort": {"asc"},
+				"startblock": {strconv.Itoa(start)}, "endblock": {strconv.Itoa(toBlock)},
+				"page": {strconv.Itoa(page)}, "offset": {strconv.Itoa(etherscanPageSize)},
+			}
+			body, err := c.get(query)
+			if err != nil {
+				return nil, err
+			}
+			var resp etherscanResponse
+			if err := json.Unmarshal(body, &resp); err != nil {
+				return nil, fmt.Errorf("decoding the %s response: %w", action, err)
+			}
+			if resp.Status != "1" {
+				// No transactions found is a status 0 with an empty result
+				if err := resp.err(); err != nil {
+					return nil, err
+				}
+				return entries, nil
+			}
+			var results []etherscanTransaction
+			if err := json.Unmarshal(resp.Result, &results); err != nil {
+				return nil, fmt.Errorf("decoding the %s result: %w", action, err)
+			}
+			for _, entry := range results {
+				if !seen[entry] {
+					seen[entry] = true
+					entries = append(entries, entry)
+				}
+				if number, err := strconv.Atoi(entry.BlockNumber); err == nil {
+					lastBlock = number
+				}
+			}
+			if len(results) < etherscanPageSize {
+				return entries, nil
+			}
+		}
+		// The results of the range are exhausted, go on from the last block, whose entries are deduplicated
+		if lastBlock <= start {
+			return nil, fmt.Errorf("more than %d %s entries in block %d", etherscanMaxResults, action, start)
+		}
+		start = lastBlock
+	}
+}
+
+// get sends a request to the API, after waiting for the rate limit
+func (c *EtherscanClient) get(query url.Values) ([]byte, error) {
+	ctx := c.wait()
+	if c.apiKey != "" {
+		query.Set("apikey", c.apiKey)
+	}
+	if c.chainID != 0 {
+		query.Set("chainid", strconv.FormatInt(c.chainID, 10))
+	}
+	separator := "?"
+	if strings.Contains(c.baseURL, "?") {
+		separator = "&"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+separator+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// The URL holds the API key
+		return nil, redactedError(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, ErrEtherscanRateLimited
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("etherscan replied %s", resp.Status)
+	}
+	return body, nil
+}
+
+// wait blocks until the next request is allowed by the rate limit and returns the context of the requests
+func (c *EtherscanClient) wait() context.Context {
+	c.mu.Lock()
+	if c.ctx == nil {
+		c.ctx, c.cancel = context.WithCancel(context.Background())
+	}
+	ctx := c.ctx
+	now := time.Now()
+	at := c.next
+	if at.Before(now) {
+		at = now
+	}
+	c.next = at.Add(c.interval)
+	c.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return ctx
+}
+
+// etherscanProxyQuery maps a JSON-RPC request to the query of the proxy module
+func etherscanProxyQuery(req JSONRPCRequest) (url.Values, error) {
+	query := url.Values{"module": {"proxy"}, "action": {req.Method}}
+	param := func(i int) string {
+		if i >= len(req.Params) {
+			return ""
+		}
+		switch value := req.Params[i].(type) {
+		case string:
+			return value
+		case bool:
+			return strconv.FormatBool(value)
+		default:
+			return fmt.Sprint(value)
+		}
+	}
+	switch req.Method {
+	case "eth_blockNumber", "eth_gasPrice":
+	case "eth_getBlockByNumber":
+		query.Set("tag", param(0))
+		query.Set("boolean", param(1))
+	case "eth_getBlockTransactionCountByNumber":
+		query.Set("tag", param(0))
+	case "eth_getTransactionByHash", "eth_getTransactionReceipt":
+		query.Set("txhash", param(0))
+	case "eth_getTransactionCount", "eth_getCode":
+		query.Set("address", param(0))
+		query.Set("tag", param(1))
+	case "eth_call":
+		var call struct {
+			To   string `json:"to"`
+			Data string `json:"data"`
+		}
+		if len(req.Params) > 0 {
+			raw, err := json.Marshal(req.Params[0])
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal(raw, &call); err != nil {
+				return nil, fmt.Errorf("eth_call: %w", err)
+			}
+		}
+		query.Set("to", call.To)
+		query.Set("data", call.Data)
+		query.Set("tag", param(1))
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrEtherscanUnsupportedMethod, req.Method)
+	}
+	return query, nil
+}
+
+// etherscanResponse is the envelope of the responses of the account module and of the API errors
+type etherscanResponse struct {
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	Result  json.RawMessage `json:"result"`
+}
+
+// err returns the error of a status 0 response, nil when it only found nothing
+func (r etherscanResponse) err() error {
+	var result string
+	json.Unmarshal(r.Result, &result)
+	switch {
+	case strings.HasPrefix(r.Message, "No transactions found") || strings.HasPrefix(r.Message, "No records found"):
+		return nil
+	case strings.Contains(strings.ToLower(result), "rate limit"):
+		return fmt.Errorf("%w: %s", ErrEtherscanRateLimited, result)
+	case result != "":
+		return fmt.Errorf("etherscan: %s: %s", r.Message, result)
+	}
+	return fmt.Errorf("etherscan: %s", r.Message)
+}
+
+// etherscanTransaction is an entry of txlist or tokentx, with decimal quantities
+type etherscanTransaction struct {
+	BlockNumber      string `json:"blockNumber"`
+	TimeStamp        string `json:"timeStamp"`
+	Hash             string `json:"hash"`
+	Nonce            string `json:"nonce"`
+	BlockHash        string `json:"blockHash"`
+	TransactionIndex string `json:"transactionIndex"`
+	From             string `json:"from"`
+	To               string `json:"to"`
+	Value            string `json:"value"`
+	GasPrice         string `json:"gasPrice"`
+	Input            string `json:"input"`
+	ContractAddress  string `json:"contractAddress"`
+	TokenName        string `json:"tokenName"`
+	TokenSymbol      string `json:"tokenSymbol"`
+	TokenDecimal     string `json:"tokenDecimal"`
+}
+
+// transaction converts a txlist entry to a Transaction with hex quantities, as returned by the node
+func (e etherscanTransaction) transaction() (Transaction, error) {
+	number, err := strconv.Atoi(e.BlockNumber)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("invalid block number %q", e.BlockNumber)
+	}
+	tx := Transaction{
+		Hash:               e.Hash,
+		From:               e.From,
+		To:                 e.To,
+		Value:              decimalToHex(e.Value),
+		BlockNumber:        fmt.Sprintf("0x%x", number),
+		BlockNumberDecimal: number,
+		Nonce:              decimalToHex(e.Nonce),
+		TransactionIndex:   decimalToHex(e.TransactionIndex),
+		BlockHash:          e.BlockHash,
+		Input:              e.Input,
+		GasPrice:           decimalToHex(e.GasPrice),
+	}
+	if timestamp, err := strconv.ParseInt(e.TimeStamp, 10, 64); err == nil {
+		blockTime := time.Unix(timestamp, 0).UTC()
+		tx.BlockTime = &blockTime
+	}
+	return tx, nil
+}
+
+// token returns the token metadata of a tokentx entry
+func (e etherscanTransaction) token() *TokenMetadata {
+	token := &TokenMetadata{Address: strings.ToLower(e.ContractAddress), Name: e.TokenName, Symbol: e.TokenSymbol}
+	if decimals, err := strconv.Atoi(e.TokenDecimal); err == nil {
+		token.Decimals = &decimals
+	}
+	return token
+}
+
+// decimalToHex converts a decimal quantity to hex, empty when it isn't one
+func decimalToHex(decimal string) string {
+	value, ok := new(big.Int).SetString(decimal, 10)
+	if !ok {
+		return ""
+	}
+	return "0x" + value.Text(16)
+}
@@ -0,0 +1,119 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// etherscanServer answers the txlist, tokentx and proxy requests of an Etherscan-compatible API
+func etherscanServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("apikey") != "KEY" {
+			fmt.Fprint(w, `{"status":"0","message":"NOTOK","result":"Invalid API Key"}`)
+			return
+		}
+		switch query.Get("action") {
+		case "txlist":
+			fmt.Fprint(w, `{"status":"1","message":"OK","result":[
+				{"blockNumber":"12","timeStamp":"1700000000","hash":"0xa1","nonce":"3","blockHash":"0xb12","transactionIndex":"0","from":"0x1","to":"0x2","value":"1000000000000000000","gasPrice":"10","input":"0x"},
+				{"blockNumber":"15","timeStamp":"1700000036","hash":"0xa2","nonce":"4","blockHash":"0xb15","transactionIndex":"2","from":"0x1","to":"0xtoken","value":"0","gasPrice":"10","input":"0xa9059cbb"}]}`)
+		case "tokentx":
+			fmt.Fprint(w, `{"status":"1","message":"OK","result":[
+				{"blockNumber":"15","hash":"0xa2","from":"0x1","to":"0x3","value":"5","contractAddress":"0xTOKEN","tokenName":"Token","tokenSymbol":"TKN","tokenDecimal":"6"}]}`)
+		case "eth_blockNumber":
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":83,"result":"0x14"}`)
+		case "eth_gasPrice":
+			fmt.Fprint(w, `{"status":"0","message":"NOTOK","result":"Max rate limit reached"}`)
+		default:
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+	}))
+}
+
+func TestEtherscanClientAddressTransactions(t *testing.T) {
+	server := etherscanServer(t)
+	defer server.Close()
+	client := parser.NewEtherscanClient(server.URL, "KEY", 0)
+
+	transactions, err := client.AddressTransactions("0x1", 10, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions) != 2 {
+		t.Fatalf("Expected 2 transactions, got %+v", transactions)
+	}
+	first := transactions[0]
+	if first.BlockNumber != "0xc" || first.BlockNumberDecimal != 12 || first.Value != "0xde0b6b3a7640000" || first.Nonce != "0x3" ||
+		first.BlockTime == nil || !first.BlockTime.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Expected the quantities in hex as returned by the node, got %+v", first)
+	}
+	if token := transactions[1].Token; transactions[1].Category != parser.CategoryTokenTransfer || token == nil || token.Symbol != "TKN" || *token.Decimals != 6 {
+		t.Errorf("Expected the token transfer to be annotated, got %+v", transactions[1])
+	}
+
+	if _, err := parser.NewEtherscanClient(server.URL, "WRONG", 0).AddressTransactions("0x1", 10, 20); err == nil {
+		t.Error("Expected an error for an invalid key")
+	}
+}
+
+func TestEtherscanClientProxy(t *testing.T) {
+	server := etherscanServer(t)
+	defer server.Close()
+	client := parser.NewEtherscanClient(server.URL, "KEY", 0)
+
+	resp, err := client.SendRequest(parser.JSONRPCRequest{JSONRPC: "2.0", Method: "eth_blockNumber", ID: 1})
+	if err != nil || resp.Result != "0x14" || resp.ID != 1 {
+		t.Fatalf("Expected the block number through the proxy module, got %+v %v", resp, err)
+	}
+	// The rate limit is the JSON-RPC error of the providers, for the MethodRouter to fall back
+	node := &endpointClient{}
+	router, _ := parser.NewMethodRouter(node, []parser.MethodRoute{{Name: "etherscan", Methods: []string{"eth_gasPrice"}, Client: client}})
+	if _, err := router.SendRequest(parser.JSONRPCRequest{JSONRPC: "2.0", Method: "eth_gasPrice", ID: 1}); err != nil || len(node.methods) != 1 {
+		t.Errorf("Expected the rate limited request to fall back to the node, got %v %v", node.methods, err)
+	}
+	if _, err := client.SendRequest(parser.JSONRPCRequest{Method: "eth_getLogs"}); err == nil {
+		t.Error("Expected an error for a method without proxy action")
+	}
+}
+
+// staticHistory is a HistorySource returning fixed transactions
+type staticHistory []parser.Transaction
+
+func (h staticHistory) AddressTransactions(address string, fromBlock int, toBlock int) ([]parser.Transaction, error) {
+	return h, nil
+}
+
+func TestEthParserBackfillFromHistorySource(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	blockchain := mockChain(20)
+	blockchain.AddBlock(12, parser.Block{Number: "0xc", Transactions: []parser.Transaction{{Hash: "0xa1", From: "0x1", To: "0x2"}}})
+	var notified int
+	history := staticHistory{
+		{Hash: "0xa1", From: "0x1", To: "0x2", BlockNumber: "0xc", BlockNumberDecimal: 12},
+		{Hash: "0xa0", From: "0x2", To: "0x1", BlockNumber: "0x5", BlockNumberDecimal: 5, BlockHash: "0xb5", TransactionIndex: "0x0"},
+	}
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(blockchain),
+		func(string, []parser.Transaction) { notified++ }, parser.WithClock(parser.NewManualClock(time.Now())),
+		parser.WithHistorySource(history))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+	notified = 0
+
+	result, err := ethParser.Backfill(parser.RescanRequest{FromBlock: 1, ToBlock: 20, Addresses: []string{"0x1"}})
+	if err != nil || result.Transactions != 1 {
+		t.Fatalf("Expected the missing transaction to be backfilled, got %+v %v", result, err)
+	}
+	transactions := ethParser.GetTransactions("0x1")
+	if len(transactions) != 2 || transactions[1].Hash != "0xa0" || transactions[1].EventID == "" || notified != 0 {
+		t.Errorf("Expected the backfilled transaction to be stored without notification, got %+v", transactions)
+	}
+}
@@ -0,0 +1,34 @@
+package parser
+
+import "log"
+
+// Event types sent through the EventNotificationFunc
+const (
+	// EventTransactionReplaced is sent when a pending transaction is replaced by another with the same nonce
+	EventTransactionReplaced = "transaction_replaced"
+	// EventChainIDMismatch is sent when the node is on a different chain than the configured one
+	EventChainIDMismatch = "chain_id_mismatch"
+)
+
+// Event is a notification about something else than newly matched transactions
+type Event struct {
+	Type    string            `json:"type"`
+	Address string            `json:"address,omitempty"`
+	Data    map[string]string `json:"data,omitempty"`
+}
+
+// EventNotificationFunc defines a function to send event notifications
+type EventNotificationFunc func(event Event)
+
+// NotifyEventOnConsole logs an event
+func NotifyEventOnConsole(event Event) {
+	log.Printf("Event Notification - Type: %s, Address: %s, Data: %v\n", event.Type, event.Address, event.Data)
+}
+
+// emitEvent sends an event to the configured EventNotificationFunc, if any
+func (p *EthParser) emitEvent(event Event) {
+	if p.notifyEvent == nil {
+		return
+	}
+	p.notifyEvent(event)
+}
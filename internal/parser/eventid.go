@@ -0,0 +1,31 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// TransactionEventID returns the deterministic ID of a transaction in its block, chainID:blockHash:index with
+// the decimal position in the block, or chainID:txHash when the block hash is unknown. The ID is the same
+// across the retries and the re-processing of a block, and differs when a reorg includes the transaction in
+// another block, so that the consumers deduplicate the notifications on it. The parser notifies transactions
+// rather than receipt logs, so there's no log index.
+func TransactionEventID(chainID int64, tx Transaction) string {
+	if tx.BlockHash == "" {
+		return fmt.Sprintf("%d:%s", chainID, tx.Hash)
+	}
+	index, err := strconv.ParseInt(trimHexPrefix(tx.TransactionIndex), 16, 64)
+	if err != nil {
+		return fmt.Sprintf("%d:%s", chainID, tx.Hash)
+	}
+	return fmt.Sprintf("%d:%s:%d", chainID, tx.BlockHash, index)
+}
+
+// eventChainID is the chain ID of the event IDs: the configured one, else the one of the network, 0 when
+// neither is set
+func (p *EthParser) eventChainID() int64 {
+	if p.expectedChainID != 0 {
+		return p.expectedChainID
+	}
+	return p.network.ChainID
+}
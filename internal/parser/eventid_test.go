@@ -0,0 +1,53 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestTransactionEventID(t *testing.T) {
+	tests := []struct {
+		tx       parser.Transaction
+		expected string
+	}{
+		{parser.Transaction{Hash: "0xa1", BlockHash: "0xb1", TransactionIndex: "0x1a"}, "1:0xb1:26"},
+		{parser.Transaction{Hash: "0xa1", TransactionIndex: "0x1a"}, "1:0xa1"},
+		{parser.Transaction{Hash: "0xa1", BlockHash: "0xb1"}, "1:0xa1"},
+	}
+	for _, test := range tests {
+		if id := parser.TransactionEventID(1, test.tx); id != test.expected {
+			t.Errorf("Expected %s for %+v, got %s", test.expected, test.tx, id)
+		}
+	}
+}
+
+func TestEthParserEventIDs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	blockchain := mockChain(1)
+	blockchain.AddBlock(1, parser.Block{Number: "0x1", Hash: "0xb1", Transactions: []parser.Transaction{
+		{Hash: "0xa1", From: "0x1", To: "0x2"},
+		{Hash: "0xa2", From: "0x3", To: "0x1", BlockHash: "0xb1", TransactionIndex: "0x1"},
+	}})
+	var notified []string
+	storage := NewMockStorage()
+	ethParser := parser.NewEthParser(ctx, storage, 1, NewMockClient(blockchain), func(address string, transactions []parser.Transaction) {
+		for _, tx := range transactions {
+			notified = append(notified, tx.EventID)
+		}
+	}, parser.WithClock(parser.NewManualClock(time.Now())), parser.WithNetwork(parser.Networks["sepolia"]))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	if len(notified) != 2 || notified[0] != "11155111:0xb1:0" || notified[1] != "11155111:0xb1:1" {
+		t.Fatalf("Expected the event IDs in the notification, got %v", notified)
+	}
+	stored := ethParser.GetTransactions("0x1")
+	if len(stored) != 2 || stored[0].EventID != notified[0] || stored[1].EventID != notified[1] {
+		t.Errorf("Expected the event IDs to be stored, got %+v", stored)
+	}
+}
@@ -0,0 +1,298 @@
+package parser
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Formats of the export jobs. Parquet isn't supported: the parser has no dependency to encode it, the
+// warehouses load the CSV and NDJSON files as well.
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+)
+
+// Statuses of the export jobs
+const (
+	ExportPending = "pending"
+	ExportRunning = "running"
+	ExportDone    = "done"
+	ExportFailed  = "failed"
+)
+
+const (
+	// maxRunningExports bounds the export jobs running at the same time
+	maxRunningExports = 2
+	// maxExportJobs is the number of export jobs kept for GetExport, the oldest finished ones are dropped
+	maxExportJobs = 100
+)
+
+// Errors of StartExport
+var (
+	ErrExportsDisabled     = errors.New("no export destination configured")
+	ErrInvalidExportFormat = errors.New("invalid export format")
+	ErrInvalidExportSource = errors.New("exactly one of address and entity is required")
+	ErrTooManyExports      = errors.New("too many running exports")
+)
+
+// exportCSVHeader is the header of the CSV exports, one row per transaction of the exported address
+var exportCSVHeader = []string{"address", "event_id", "hash", "block_number", "block_time", "transaction_index",
+	"from", "to", "value", "type", "category", "historical"}
+
+// ExportRequest selects the history to export: the transactions of an address, or of the members of an entity
+type ExportRequest struct {
+	Address string `json:"address,omitempty"`
+	Entity  string `json:"entity,omitempty"`
+	// Format is csv or ndjson, csv when empty
+	Format string `json:"format,omitempty"`
+}
+
+// ExportJob tracks an export written to the destination of WithExportDestination
+type ExportJob struct {
+	ID      string `json:"id"`
+	Address string `json:"address,omitempty"`
+	Entity  string `json:"entity,omitempty"`
+	Format  string `json:"format"`
+	Status  string `json:"status"`
+	// Key is the name of the file in the destination
+	Key   string `json:"key"`
+	Rows  int    `json:"rows"`
+	Bytes int64  `json:"bytes"`
+	Error string `json:"error,omitempty"`
+	// DownloadURL is a signed URL of the file, set by GetExport once the job is done
+	DownloadURL string     `json:"downloadUrl,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
+
+// StartExport starts an asynchronous export of the full history of an address or an entity, poll its
+// progress with GetExport. The file is written to a temporary file first, then uploaded to the destination.
+func (p *EthParser) StartExport(request ExportRequest) (ExportJob, error) {
+	if p.exportDestination == nil {
+		return ExportJob{}, ErrExportsDisabled
+	}
+	if (request.Address == "") == (request.Entity == "") {
+		return ExportJob{}, ErrInvalidExportSource
+	}
+	if request.Format == "" {
+		request.Format = ExportFormatCSV
+	}
+	if request.Format != ExportFormatCSV && request.Format != ExportFormatNDJSON {
+		return ExportJob{}, ErrInvalidExportFormat
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return ExportJob{}, err
+	}
+
+	p.exportsMu.Lock()
+	running := 0
+	for _, job := range p.exports {
+		if job.Status == ExportPending || job.Status == ExportRunning {
+			running++
+		}
+	}
+	if running >= maxRunningExports {
+		p.exportsMu.Unlock()
+		return ExportJob{}, ErrTooManyExports
+	}
+	source := request.Address
+	if source == "" {
+		source = "entity-" + request.Entity
+	}
+	job := &ExportJob{
+		ID:        hex.EncodeToString(id),
+		Address:   request.Address,
+		Entity:    request.Entity,
+		Format:    request.Format,
+		Status:    ExportPending,
+		CreatedAt: p.clock.Now(),
+	}
+	job.Key = fmt.Sprintf("%s-%s.%s", source, job.ID, job.Format)
+	p.exports = append(p.exports, job)
+	p.pruneExportsLocked()
+	started := *job
+	p.exportsMu.Unlock()
+
+	go p.runExport(job)
+	return started, nil
+}
+
+// GetExport returns an export job, with a download URL valid for the expiry of WithExportDestination once
+// it's done
+func (p *EthParser) GetExport(id string) (ExportJob, bool) {
+	p.exportsMu.Lock()
+	var found *ExportJob
+	for _, job := range p.exports {
+		if job.ID == id {
+			found = job
+			break
+		}
+	}
+	if found == nil {
+		p.exportsMu.Unlock()
+		return ExportJob{}, false
+	}
+	job := *found
+	p.exportsMu.Unlock()
+
+	if job.Status == ExportDone {
+		url, err := p.exportDestination.DownloadURL(job.Key, p.exportURLExpiry)
+		if err != nil {
+			log.Printf("Error signing the download URL of export %s: %v\n", job.ID, err)
+		}
+		job.DownloadURL = url
+	}
+	return job, true
+}
+
+// Exports returns the export jobs, the most recent first, without their download URL
+func (p *EthParser) Exports() []ExportJob {
+	p.exportsMu.Lock()
+	defer p.exportsMu.Unlock()
+	jobs := make([]ExportJob, len(p.exports))
+	for i, job := range p.exports {
+		jobs[len(p.exports)-1-i] = *job
+	}
+	return jobs
+}
+
+// ExportDestination returns the destination of the exports, nil when they're disabled
+func (p *EthParser) ExportDestination() ExportDestination {
+	return p.exportDestination
+}
+
+// pruneExportsLocked drops the oldest finished jobs over maxExportJobs, it's called with exportsMu held
+func (p *EthParser) pruneExportsLocked() {
+	for i := 0; len(p.exports) > maxExportJobs && i < len(p.exports); {
+		if status := p.exports[i].Status; status == ExportDone || status == ExportFailed {
+			p.exports = append(p.exports[:i], p.exports[i+1:]...)
+			continue
+		}
+		i++
+	}
+}
+
+// runExport writes the export to a temporary file and uploads it
+func (p *EthParser) runExport(job *ExportJob) {
+	p.updateExport(job, func(job *ExportJob) { job.Status = ExportRunning })
+	ctx := p.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	rows, size, err := p.writeExport(ctx, job)
+	p.updateExport(job, func(job *ExportJob) {
+		now := p.clock.Now()
+		job.Rows, job.Bytes, job.CompletedAt = rows, size, &now
+		if err != nil {
+			job.Status, job.Error = ExportFailed, err.Error()
+			return
+		}
+		job.Status = ExportDone
+	})
+	if err != nil {
+		log.Printf("Error exporting %s: %v\n", job.Key, err)
+		return
+	}
+	log.Printf("Exported %d transactions to %s\n", rows, job.Key)
+}
+
+// updateExport changes a job under exportsMu
+func (p *EthParser) updateExport(job *ExportJob, update func(job *ExportJob)) {
+	p.exportsMu.Lock()
+	defer p.exportsMu.Unlock()
+	update(job)
+}
+
+// writeExport encodes the transactions of the job to a temporary file and uploads it, it returns the
+// number of rows and the size of the file
+func (p *EthParser) writeExport(ctx context.Context, job *ExportJob) (int, int64, error) {
+	file, err := os.CreateTemp("", "eth-parser-export-*")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	rows, err := encodeExport(file, job.Format, p.exportTransactions(job))
+	if err != nil {
+		return 0, 0, fmt.Errorf("writing the export: %w", err)
+	}
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+	if err := p.exportDestination.Upload(ctx, job.Key, file, size); err != nil {
+		return rows, size, fmt.Errorf("uploading the export: %w", err)
+	}
+	return rows, size, nil
+}
+
+// exportedTransaction is a transaction with the address it was exported for
+type exportedTransaction struct {
+	Address string `json:"address"`
+	Transaction
+}
+
+// exportTransactions returns the transactions of the job in block order, with decimal quantities
+func (p *EthParser) exportTransactions(job *ExportJob) []exportedTransaction {
+	addresses := []string{job.Address}
+	if job.Entity != "" {
+		addresses = p.GetEntityAddresses(job.Entity)
+	}
+	var exported []exportedTransaction
+	for _, address := range addresses {
+		for _, tx := range p.GetTransactions(address) {
+			exported = append(exported, exportedTransaction{Address: address, Transaction: tx.WithNumberEncoding(NumberEncodingDecimal)})
+		}
+	}
+	sort.SliceStable(exported, func(i, j int) bool {
+		return exported[i].BlockNumberDecimal < exported[j].BlockNumberDecimal
+	})
+	return exported
+}
+
+// encodeExport writes the transactions as CSV or NDJSON and returns the number of rows
+func encodeExport(w io.Writer, format string, transactions []exportedTransaction) (int, error) {
+	if format == ExportFormatNDJSON {
+		encoder := json.NewEncoder(w)
+		for _, tx := range transactions {
+			if err := encoder.Encode(tx); err != nil {
+				return 0, err
+			}
+		}
+		return len(transactions), nil
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(exportCSVHeader); err != nil {
+		return 0, err
+	}
+	for _, tx := range transactions {
+		var blockTime string
+		if tx.BlockTime != nil {
+			blockTime = tx.BlockTime.UTC().Format(time.RFC3339)
+		}
+		record := []string{tx.Address, tx.EventID, tx.Hash, strconv.Itoa(tx.BlockNumberDecimal), blockTime, tx.TransactionIndex,
+			tx.From, tx.To, tx.Value, tx.Type, tx.Category, strconv.FormatBool(tx.Historical)}
+		if err := writer.Write(record); err != nil {
+			return 0, err
+		}
+	}
+	writer.Flush()
+	return len(transactions), writer.Error()
+}
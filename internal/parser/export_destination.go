@@ -0,0 +1,245 @@
+package parser
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportDestination stores the files of the export jobs and signs their download URLs
+type ExportDestination interface {
+	// Upload stores the size bytes of r under key
+	Upload(ctx context.Context, key string, r io.Reader, size int64) error
+	// DownloadURL returns a URL of the file of key valid for expiry
+	DownloadURL(key string, expiry time.Duration) (string, error)
+}
+
+// ErrInvalidDownloadSignature is returned by LocalDestination.Open for an expired or forged download URL
+var ErrInvalidDownloadSignature = errors.New("invalid or expired download signature")
+
+// LocalDestination writes the exports to a directory, e.g. a mounted volume shared with the warehouse loader.
+// The download URLs point to the /exports/download endpoint of the API, signed with an HMAC of Secret.
+type LocalDestination struct {
+	Dir string
+	// BaseURL is the public URL of the API, e.g. https://parser.example.com, the URLs are relative without it
+	BaseURL string
+	Secret  []byte
+}
+
+// Upload copies the file to Dir
+func (d *LocalDestination) Upload(ctx context.Context, key string, r io.Reader, size int64) error {
+	if err := os.MkdirAll(d.Dir, 0o755); err != nil {
+		return err
+	}
+	// Write under a temporary name, so that a loader watching Dir never sees a partial file
+	file, err := os.CreateTemp(d.Dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), filepath.Join(d.Dir, filepath.Base(key)))
+}
+
+// DownloadURL returns the signed URL of the /exports/download endpoint
+func (d *LocalDestination) DownloadURL(key string, expiry time.Duration) (string, error) {
+	expires := strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)
+	query := url.Values{"expires": {expires}, "signature": {d.sign(key, expires)}}
+	return strings.TrimSuffix(d.BaseURL, "/") + "/exports/download/" + url.PathEscape(key) + "?" + query.Encode(), nil
+}
+
+// Open verifies the signature of a download URL and opens the file of key
+func (d *LocalDestination) Open(key string, expires string, signature string) (*os.File, error) {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt ||
+		subtle.ConstantTimeCompare([]byte(d.sign(key, expires)), []byte(signature)) != 1 {
+		return nil, ErrInvalidDownloadSignature
+	}
+	return os.Open(filepath.Join(d.Dir, filepath.Base(key)))
+}
+
+// sign returns the hex HMAC of a key and its expiry
+func (d *LocalDestination) sign(key string, expires string) string {
+	mac := hmac.New(sha256.New, d.Secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// S3Destination uploads the exports to an S3 bucket, or to a compatible object storage such as GCS with HMAC
+// keys (Endpoint https://storage.googleapis.com) or MinIO. The requests are signed with AWS Signature V4 and
+// the download URLs are presigned GET URLs.
+type S3Destination struct {
+	// Endpoint is the base URL of the object storage, https://s3.<Region>.amazonaws.com by default. The
+	// objects are addressed path-style, Endpoint/Bucket/key, or Endpoint/key when Bucket is empty and Endpoint
+	// is the virtual-hosted URL of the bucket.
+	Endpoint  string
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	// Client sends the uploads, http.DefaultClient when nil
+	Client *http.Client
+}
+
+// s3UnsignedPayload is the payload hash of the requests whose body isn't signed, the body of the uploads
+// being protected by TLS
+const s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// Upload puts the object
+func (d *S3Destination) Upload(ctx context.Context, key string, r io.Reader, size int64) error {
+	objectURL, err := d.objectURL(key)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, objectURL.String(), r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	now := time.Now().UTC()
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", s3UnsignedPayload)
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + objectURL.Host + "\nx-amz-content-sha256:" + s3UnsignedPayload +
+		"\nx-amz-date:" + req.Header.Get("X-Amz-Date") + "\n"
+	signature := d.signature(now, http.MethodPut, objectURL.EscapedPath(), "", canonicalHeaders, signedHeaders)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		d.AccessKey, d.scope(now), signedHeaders, signature))
+
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("object storage replied %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// DownloadURL returns a presigned GET URL of the object
+func (d *S3Destination) DownloadURL(key string, expiry time.Duration) (string, error) {
+	return d.PresignedURL(key, time.Now(), expiry)
+}
+
+// PresignedURL returns the GET URL of the object presigned at a time, valid for expiry (7 days at most)
+func (d *S3Destination) PresignedURL(key string, at time.Time, expiry time.Duration) (string, error) {
+	objectURL, err := d.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+	at = at.UTC()
+	query := url.Values{
+		"X-Amz-Algorithm":     {"AWS4-HMAC-SHA256"},
+		"X-Amz-Credential":    {d.AccessKey + "/" + d.scope(at)},
+		"X-Amz-Date":          {at.Format("20060102T150405Z")},
+		"X-Amz-Expires":       {strconv.Itoa(int(expiry.Seconds()))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	canonicalQuery := awsCanonicalQuery(query)
+	signature := d.signature(at, http.MethodGet, objectURL.EscapedPath(), canonicalQuery, "host:"+objectURL.Host+"\n", "host")
+	return objectURL.String() + "?" + canonicalQuery + "&X-Amz-Signature=" + signature, nil
+}
+
+// objectURL returns the URL of the object of key
+func (d *S3Destination) objectURL(key string) (*url.URL, error) {
+	endpoint := d.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + d.Region + ".amazonaws.com"
+	}
+	base, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	path := base.Path
+	if d.Bucket != "" {
+		path += "/" + d.Bucket
+	}
+	if d.Prefix != "" {
+		path += "/" + strings.Trim(d.Prefix, "/")
+	}
+	path += "/" + key
+	base.Path, base.RawPath = path, awsURIEncode(path, false)
+	return base, nil
+}
+
+// scope is the credential scope of the requests signed at a time
+func (d *S3Destination) scope(at time.Time) string {
+	return at.Format("20060102") + "/" + d.Region + "/s3/aws4_request"
+}
+
+// signature computes the AWS Signature V4 of a canonical request
+func (d *S3Destination) signature(at time.Time, method, path, query, headers, signedHeaders string) string {
+	canonicalRequest := strings.Join([]string{method, path, query, headers, signedHeaders, s3UnsignedPayload}, "\n")
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + at.Format("20060102T150405Z") + "\n" + d.scope(at) + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + d.SecretKey)
+	for _, part := range []string{at.Format("20060102"), d.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsCanonicalQuery encodes the query sorted by name, as signed by AWS Signature V4
+func awsCanonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, awsURIEncode(name, true)+"="+awsURIEncode(query.Get(name), true))
+	}
+	return strings.Join(pairs, "&")
+}
+
+// awsURIEncode percent-encodes everything but the unreserved characters, and the slashes unless encodeSlash
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
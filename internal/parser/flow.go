@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Directions of a transaction relative to an address
+const (
+	DirectionIn   = "in"
+	DirectionOut  = "out"
+	DirectionSelf = "self"
+)
+
+// FlowSummary aggregates the native value of transactions relative to an address. The totals are strings
+// in wei, in the NumberEncoding of the response; token transfers move no native value.
+type FlowSummary struct {
+	TotalIn  string `json:"totalIn"`
+	TotalOut string `json:"totalOut"`
+	// Net is TotalIn minus TotalOut, negative when more value left the address
+	Net  string `json:"net"`
+	In   int    `json:"in"`
+	Out  int    `json:"out"`
+	Self int    `json:"self"`
+}
+
+// TransactionDirection returns the direction of a transaction relative to address: DirectionSelf for a
+// transfer to itself, DirectionOut when address sent it and DirectionIn otherwise
+func TransactionDirection(tx Transaction, address string) string {
+	from := strings.EqualFold(tx.From, address)
+	switch {
+	case from && strings.EqualFold(tx.To, address):
+		return DirectionSelf
+	case from:
+		return DirectionOut
+	default:
+		return DirectionIn
+	}
+}
+
+// TransactionsWithDirection returns a copy of the transactions with their Direction relative to address
+func TransactionsWithDirection(transactions []Transaction, address string) []Transaction {
+	if transactions == nil {
+		return nil
+	}
+	directed := make([]Transaction, len(transactions))
+	for i, tx := range transactions {
+		tx.Direction = TransactionDirection(tx, address)
+		directed[i] = tx
+	}
+	return directed
+}
+
+// ComputeFlow returns the flow of the transactions, as stored with hex values, relative to address
+func ComputeFlow(transactions []Transaction, address string, encoding string) FlowSummary {
+	var summary FlowSummary
+	totalIn, totalOut := new(big.Int), new(big.Int)
+	for _, tx := range transactions {
+		value, ok := parseQuantity(tx.Value)
+		if !ok {
+			value = new(big.Int)
+		}
+		switch TransactionDirection(tx, address) {
+		case DirectionSelf:
+			summary.Self++
+		case DirectionOut:
+			summary.Out++
+			totalOut.Add(totalOut, value)
+		default:
+			summary.In++
+			totalIn.Add(totalIn, value)
+		}
+	}
+	net := new(big.Int).Sub(totalIn, totalOut)
+	summary.TotalIn = encodeQuantity(totalIn, encoding)
+	summary.TotalOut = encodeQuantity(totalOut, encoding)
+	summary.Net = encodeQuantity(net, encoding)
+	return summary
+}
+
+// encodeQuantity formats a quantity in the given NumberEncoding, a negative hex quantity is -0x prefixed
+func encodeQuantity(value *big.Int, encoding string) string {
+	if encoding == NumberEncodingHex {
+		if value.Sign() < 0 {
+			return fmt.Sprintf("-0x%x", new(big.Int).Neg(value))
+		}
+		return fmt.Sprintf("0x%x", value)
+	}
+	return value.String()
+}
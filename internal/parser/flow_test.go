@@ -0,0 +1,34 @@
+package parser_test
+
+import (
+	"eth-parser/internal/parser"
+	"testing"
+)
+
+func TestComputeFlow(t *testing.T) {
+	transactions := []parser.Transaction{
+		{Hash: "0x1", From: "0xabc", To: "0xdef", Value: "0x64"},
+		{Hash: "0x2", From: "0xDEF", To: "0xABC", Value: "0x1f4"},
+		{Hash: "0x3", From: "0xabc", To: "0xabc", Value: "0x3e8"},
+		{Hash: "0x4", From: "0xabc", To: "0x123", Value: "0x258"},
+	}
+
+	directed := parser.TransactionsWithDirection(transactions, "0xabc")
+	for i, expected := range []string{parser.DirectionOut, parser.DirectionIn, parser.DirectionSelf, parser.DirectionOut} {
+		if directed[i].Direction != expected {
+			t.Errorf("Expected transaction %s to be %s, got %q", directed[i].Hash, expected, directed[i].Direction)
+		}
+	}
+	if transactions[0].Direction != "" {
+		t.Error("Expected the transactions to be copied")
+	}
+
+	flow := parser.ComputeFlow(transactions, "0xabc", parser.NumberEncodingDecimal)
+	expected := parser.FlowSummary{TotalIn: "500", TotalOut: "700", Net: "-200", In: 1, Out: 2, Self: 1}
+	if flow != expected {
+		t.Errorf("Expected %+v, got %+v", expected, flow)
+	}
+	if flow := parser.ComputeFlow(transactions, "0xabc", parser.NumberEncodingHex); flow.Net != "-0xc8" || flow.TotalIn != "0x1f4" {
+		t.Errorf("Expected the hex flow, got %+v", flow)
+	}
+}
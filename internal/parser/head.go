@@ -0,0 +1,139 @@
+package parser
+
+import (
+	"context"
+	"log"
+)
+
+// HeadSubscriber is implemented by sources pushing new chain heads, e.g. eth_subscribe("newHeads") over WebSocket
+type HeadSubscriber interface {
+	// SubscribeNewHeads returns a channel of new head block numbers, closed when the subscription ends
+	SubscribeNewHeads(ctx context.Context) (<-chan int, error)
+}
+
+const (
+	// HeadModePush means the current block is updated from the pushed new heads
+	HeadModePush = "push"
+	// HeadModePoll means the current block is updated by polling eth_blockNumber
+	HeadModePoll = "poll"
+
+	// maxPushHeadLag is the number of blocks the pushed head may lag behind the polled one before
+	// the push source is considered unhealthy
+	maxPushHeadLag = 2
+)
+
+// HeadTrackingStats reports how the current block is being tracked
+type HeadTrackingStats struct {
+	Mode          string `json:"mode"`
+	Disagreements int    `json:"disagreements"`
+	Switches      int    `json:"switches"`
+}
+
+// HeadTrackingStats returns a snapshot of the head tracking state
+func (p *EthParser) HeadTrackingStats() HeadTrackingStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.headStats
+}
+
+// runHeadTracking keeps the current block updated combining pushed heads and polling.
+// Pushed heads are used while the subscription is healthy; on every crossCheckTicker tick the head is also
+// polled, disagreements are logged and counted, and the tracker switches to polling when the push source
+// ends or lags behind, switching back once a new subscription keeps up again.
+func (p *EthParser) runHeadTracking(ctx context.Context, heartbeat *loopHeartbeat, pollTicker Ticker, crossCheckTicker Ticker) {
+	var heads <-chan int
+	cancelSubscription := func() {}
+	defer func() { cancelSubscription() }()
+
+	lastPushHead := 0
+
+	subscribe := func() {
+		subCtx, cancel := context.WithCancel(ctx)
+		ch, err := p.heads.SubscribeNewHeads(subCtx)
+		if err != nil {
+			cancel()
+			log.Println("Error subscribing to new heads:", err)
+			return
+		}
+		cancelSubscription()
+		cancelSubscription = cancel
+		heads = ch
+		lastPushHead = p.GetCurrentBlock()
+	}
+
+	subscribe()
+	if heads != nil {
+		p.setHeadMode(HeadModePush)
+	} else {
+		p.setHeadMode(HeadModePoll)
+	}
+
+	for {
+		heartbeat.idle()
+		select {
+		case head, ok := <-heads:
+			if !ok {
+				log.Println("New heads subscription closed")
+				heads = nil
+				p.setHeadMode(HeadModePoll)
+				continue
+			}
+			lastPushHead = head
+			if p.HeadTrackingStats().Mode == HeadModePush {
+				p.setCurrentBlock(head)
+				p.scheduleFetch()
+			}
+		case <-pollTicker.C():
+			if p.HeadTrackingStats().Mode == HeadModePoll && !p.headPollThrottled() {
+				heartbeat.busy(p.clock.Now())
+				log.Println("Updating current block")
+				p.updateCurrentBlock()
+				p.scheduleFetch()
+			}
+		case <-crossCheckTicker.C():
+			heartbeat.busy(p.clock.Now())
+			polled, err := p.fetchBlockNumber()
+			if err != nil {
+				log.Println("Error cross-checking block number:", err)
+				continue
+			}
+			if heads == nil {
+				subscribe()
+			}
+
+			lag := polled - lastPushHead
+			if heads != nil && lag != 0 {
+				log.Printf("Head sources disagree: polled %d, pushed %d\n", polled, lastPushHead)
+				p.mu.Lock()
+				p.headStats.Disagreements++
+				p.mu.Unlock()
+			}
+
+			pushHealthy := heads != nil && lag < maxPushHeadLag
+			switch mode := p.HeadTrackingStats().Mode; {
+			case mode == HeadModePush && !pushHealthy:
+				p.setHeadMode(HeadModePoll)
+				p.setCurrentBlock(polled)
+			case mode == HeadModePoll && pushHealthy:
+				p.setHeadMode(HeadModePush)
+			}
+		case <-ctx.Done():
+			log.Println("Stopping runHeadTracking")
+			return
+		}
+	}
+}
+
+// setHeadMode changes the head tracking mode, counting the switches after the initial one
+func (p *EthParser) setHeadMode(mode string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.headStats.Mode == mode {
+		return
+	}
+	if p.headStats.Mode != "" {
+		p.headStats.Switches++
+	}
+	log.Printf("Head tracking mode: %s\n", mode)
+	p.headStats.Mode = mode
+}
@@ -0,0 +1,39 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestEthParserHeadTracking(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1"})
+	mockBlockchain.AddBlock(2, parser.Block{Number: "0x2"})
+
+	heads := NewMockHeadSubscriber()
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain),
+		func(string, []parser.Transaction) {}, parser.WithHeadSubscriber(heads, 50*time.Millisecond))
+	defer ethParser.WaitForShutdown()
+
+	// Pushed heads drive the current block
+	heads.Heads <- 2
+	time.Sleep(200 * time.Millisecond)
+	if stats := ethParser.HeadTrackingStats(); stats.Mode != parser.HeadModePush {
+		t.Fatalf("Expected push mode, got %+v", stats)
+	}
+	if block := ethParser.GetCurrentBlock(); block != 2 {
+		t.Fatalf("Unexpected current block %d", block)
+	}
+
+	// When the subscription ends the tracker falls back to polling
+	close(heads.Heads)
+	time.Sleep(200 * time.Millisecond)
+	if stats := ethParser.HeadTrackingStats(); stats.Mode != parser.HeadModePoll || stats.Switches != 1 {
+		t.Fatalf("Expected a switch to poll mode, got %+v", stats)
+	}
+}
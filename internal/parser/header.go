@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ErrHeaderMismatch is returned when the hash recomputed from the header fields of a block differs from the
+// hash reported by the node
+var ErrHeaderMismatch = errors.New("block header hash mismatch")
+
+// headerField is a field of the RLP-encoded block header
+type headerField struct {
+	name string
+	// quantity fields are encoded as integers without leading zeros, the others as byte strings
+	quantity bool
+	// optional fields were added by later forks and are only encoded when the node returns them
+	optional bool
+}
+
+// headerFields are the block header fields in their RLP order, up to the Prague fork
+var headerFields = []headerField{
+	{name: "parentHash"},
+	{name: "sha3Uncles"},
+	{name: "miner"},
+	{name: "stateRoot"},
+	{name: "transactionsRoot"},
+	{name: "receiptsRoot"},
+	{name: "logsBloom"},
+	{name: "difficulty", quantity: true},
+	{name: "number", quantity: true},
+	{name: "gasLimit", quantity: true},
+	{name: "gasUsed", quantity: true},
+	{name: "timestamp", quantity: true},
+	{name: "extraData"},
+	{name: "mixHash"},
+	{name: "nonce"},
+	{name: "baseFeePerGas", quantity: true, optional: true},
+	{name: "withdrawalsRoot", optional: true},
+	{name: "blobGasUsed", quantity: true, optional: true},
+	{name: "excessBlobGas", quantity: true, optional: true},
+	{name: "parentBeaconBlockRoot", optional: true},
+	{name: "requestsHash", optional: true},
+}
+
+// VerifyBlockHeader recomputes the hash of a raw eth_getBlockByNumber result, keccak256 of the RLP-encoded
+// header, and checks it matches its hash field. It only covers the header: the transactions of the response
+// aren't checked against the transactionsRoot.
+func VerifyBlockHeader(raw json.RawMessage) error {
+	var header map[string]interface{}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return fmt.Errorf("decoding block header: %w", err)
+	}
+	reported, _ := header["hash"].(string)
+	if reported == "" {
+		return fmt.Errorf("%w: no hash reported", ErrHeaderMismatch)
+	}
+
+	var items [][]byte
+	for _, field := range headerFields {
+		value, ok := header[field.name].(string)
+		if !ok {
+			if field.optional {
+				continue
+			}
+			return fmt.Errorf("%w: missing field %s", ErrHeaderMismatch, field.name)
+		}
+		decoded, err := decodeHeaderValue(value, field.quantity)
+		if err != nil {
+			return fmt.Errorf("%w: field %s: %v", ErrHeaderMismatch, field.name, err)
+		}
+		items = append(items, rlpEncodeBytes(decoded))
+	}
+
+	computed := "0x" + hex.EncodeToString(keccak256(rlpEncodeList(items)))
+	if !strings.EqualFold(computed, reported) {
+		return fmt.Errorf("%w: reported %s, computed %s", ErrHeaderMismatch, reported, computed)
+	}
+	return nil
+}
+
+// decodeHeaderValue decodes a hex header value, stripping the leading zeros of the quantities
+func decodeHeaderValue(value string, quantity bool) ([]byte, error) {
+	digits := strings.TrimPrefix(value, "0x")
+	if len(digits)%2 == 1 {
+		digits = "0" + digits
+	}
+	decoded, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, err
+	}
+	if quantity {
+		for len(decoded) > 0 && decoded[0] == 0 {
+			decoded = decoded[1:]
+		}
+	}
+	return decoded, nil
+}
+
+// rlpEncodeBytes returns the RLP encoding of a byte string
+func rlpEncodeBytes(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return b
+	}
+	return append(rlpLength(len(b), 0x80), b...)
+}
+
+// rlpEncodeList returns the RLP encoding of a list of already encoded items
+func rlpEncodeList(items [][]byte) []byte {
+	var payload []byte
+	for _, item := range items {
+		payload = append(payload, item...)
+	}
+	return append(rlpLength(len(payload), 0xc0), payload...)
+}
+
+// rlpLength returns the RLP prefix of a payload of the given length, offset is 0x80 for strings and 0xc0 for lists
+func rlpLength(length int, offset byte) []byte {
+	if length < 56 {
+		return []byte{offset + byte(length)}
+	}
+	var size []byte
+	for n := length; n > 0; n >>= 8 {
+		size = append([]byte{byte(n)}, size...)
+	}
+	return append([]byte{offset + 55 + byte(len(size))}, size...)
+}
+
+// verifyHeader checks the header of a fetched block when the header verification is enabled, counting the
+// mismatches
+func (p *EthParser) verifyHeader(number int, raw json.RawMessage) error {
+	if !p.verifyHeaders {
+		return nil
+	}
+	if err := VerifyBlockHeader(raw); err != nil {
+		p.mu.Lock()
+		p.headerMismatches++
+		p.mu.Unlock()
+		log.Printf("Block %d failed the header verification: %v\n", number, err)
+		return err
+	}
+	return nil
+}
+
+// HeaderMismatches returns the number of fetched blocks that failed the header verification
+func (p *EthParser) HeaderMismatches() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.headerMismatches
+}
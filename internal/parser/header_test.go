@@ -0,0 +1,78 @@
+package parser_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"eth-parser/internal/parser"
+	"strings"
+	"testing"
+	"time"
+)
+
+// genesisHeader is the header of the Ethereum mainnet genesis block
+const genesisHeader = `{
+	"hash": "0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3",
+	"parentHash": "0x0000000000000000000000000000000000000000000000000000000000000000",
+	"sha3Uncles": "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347",
+	"miner": "0x0000000000000000000000000000000000000000",
+	"stateRoot": "0xd7f8974fb5ac78d9ac099b9ad5018bedc2ce0a72dad1827a1709da30580f0544",
+	"transactionsRoot": "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
+	"receiptsRoot": "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
+	"logsBloom": "0x` + "%s" + `",
+	"difficulty": "0x400000000",
+	"number": "0x0",
+	"gasLimit": "0x1388",
+	"gasUsed": "0x0",
+	"timestamp": "0x0",
+	"extraData": "0x11bbe8db4e347b4e8c937c1c8370e4b5ed33adb3db69cbdb7a38e1e50b1b82fa",
+	"mixHash": "0x0000000000000000000000000000000000000000000000000000000000000000",
+	"nonce": "0x0000000000000042",
+	"transactions": []
+}`
+
+func genesis() string {
+	return strings.Replace(genesisHeader, "%s", strings.Repeat("00", 256), 1)
+}
+
+func TestVerifyBlockHeader(t *testing.T) {
+	if err := parser.VerifyBlockHeader(json.RawMessage(genesis())); err != nil {
+		t.Fatalf("Expected the genesis header to verify, got %v", err)
+	}
+
+	tampered := strings.Replace(genesis(), `"gasLimit": "0x1388"`, `"gasLimit": "0x1389"`, 1)
+	if err := parser.VerifyBlockHeader(json.RawMessage(tampered)); !errors.Is(err, parser.ErrHeaderMismatch) {
+		t.Errorf("Expected ErrHeaderMismatch for a tampered field, got %v", err)
+	}
+	missing := strings.Replace(genesis(), `"nonce": "0x0000000000000042",`, "", 1)
+	if err := parser.VerifyBlockHeader(json.RawMessage(missing)); !errors.Is(err, parser.ErrHeaderMismatch) {
+		t.Errorf("Expected ErrHeaderMismatch for a missing field, got %v", err)
+	}
+	// The post-London fields are part of the hash when returned
+	extra := strings.Replace(genesis(), `"number": "0x0",`, `"number": "0x0", "baseFeePerGas": "0x7",`, 1)
+	if err := parser.VerifyBlockHeader(json.RawMessage(extra)); !errors.Is(err, parser.ErrHeaderMismatch) {
+		t.Errorf("Expected ErrHeaderMismatch for an added field, got %v", err)
+	}
+}
+
+func TestEthParserHeaderVerification(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The mock blocks have no header fields, so they all fail the verification
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{{Hash: "0xa1", From: "0x1", To: "0x2", Value: "0x1"}}})
+	storage := NewMockStorage()
+	ethParser := parser.NewEthParser(ctx, storage, 1, NewMockClient(mockBlockchain), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithHeaderVerification())
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	if mismatches := ethParser.HeaderMismatches(); mismatches != 1 {
+		t.Errorf("Expected 1 header mismatch, got %d", mismatches)
+	}
+	if transactions := ethParser.GetTransactions("0x1"); len(transactions) != 0 {
+		t.Errorf("Expected the unverified block not to be stored, got %+v", transactions)
+	}
+}
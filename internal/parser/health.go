@@ -0,0 +1,258 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Health statuses of the components, from the best to the worst. A degraded component keeps the parser
+// ready, a down one makes it unready.
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+	HealthDown     = "down"
+)
+
+// Defaults of the HealthThresholds
+const (
+	defaultHealthRPCFailures        = 5
+	defaultHealthStorageErrorWindow = 5 * time.Minute
+	defaultHealthOutboxBacklog      = 1000
+)
+
+// HealthThresholds sets when the built-in components of the parser are degraded or down, a zero field keeps
+// its default
+type HealthThresholds struct {
+	// RPCFailures is the number of consecutive failed node requests from which the node is down, as an open
+	// circuit; a single failure degrades it. 5 by default.
+	RPCFailures int
+	// StorageErrorWindow is how long a storage write error degrades the storage, 5 minutes by default
+	StorageErrorWindow time.Duration
+	// OutboxBacklog is the number of pending notifications from which the notifications are degraded, 1000
+	// by default
+	OutboxBacklog int
+}
+
+// ComponentHealth is the health of a component, with the reason when it isn't ok
+type ComponentHealth struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// HealthReport is the health of all the components, its status is the worst of theirs
+type HealthReport struct {
+	Status     string            `json:"status"`
+	Components []ComponentHealth `json:"components"`
+}
+
+// Ready reports whether no component is down
+func (r HealthReport) Ready() bool {
+	return r.Status != HealthDown
+}
+
+// Reasons lists the components that aren't ok with their reason, e.g. "rpc: 5 consecutive failed requests"
+func (r HealthReport) Reasons() string {
+	var reasons []string
+	for _, component := range r.Components {
+		if component.Status != HealthOK {
+			reasons = append(reasons, component.Name+": "+component.Reason)
+		}
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// HealthCheck returns the status of a component and the reason when it isn't ok
+type HealthCheck func() (status string, reason string)
+
+// HealthRegistry is the central registry of the health checks: each component registers its own, and the
+// readiness is derived from all of them
+type HealthRegistry struct {
+	mu     sync.Mutex
+	names  []string
+	checks map[string]HealthCheck
+}
+
+// NewHealthRegistry creates an empty HealthRegistry
+func NewHealthRegistry() *HealthRegistry {
+	return &HealthRegistry{checks: make(map[string]HealthCheck)}
+}
+
+// Register adds the health check of a component, replacing the one registered under the same name
+func (r *HealthRegistry) Register(name string, check HealthCheck) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.checks[name]; !exists {
+		r.names = append(r.names, name)
+	}
+	r.checks[name] = check
+}
+
+// Check runs the health checks, in registration order
+func (r *HealthRegistry) Check() HealthReport {
+	r.mu.Lock()
+	names := append([]string(nil), r.names...)
+	checks := make([]HealthCheck, len(names))
+	for i, name := range names {
+		checks[i] = r.checks[name]
+	}
+	r.mu.Unlock()
+
+	report := HealthReport{Status: HealthOK, Components: make([]ComponentHealth, len(names))}
+	for i, check := range checks {
+		status, reason := check()
+		report.Components[i] = ComponentHealth{Name: names[i], Status: status, Reason: reason}
+		if healthRank(status) > healthRank(report.Status) {
+			report.Status = status
+		}
+	}
+	return report
+}
+
+// healthRank orders the statuses, the highest is the worst
+func healthRank(status string) int {
+	switch status {
+	case HealthOK:
+		return 0
+	case HealthDegraded:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// healthState holds the failures seen by the built-in health checks
+type healthState struct {
+	mu               sync.Mutex
+	rpcFailures      int // consecutive failed node requests
+	rpcLastError     string
+	storageErrorAt   time.Time
+	storageLastError string
+	thresholds       HealthThresholds
+}
+
+// Health returns the health registry of the parser, with the checks of its components: recovery, warmup,
+// rpc, storage and notifications. Embedders register the checks of their own components to it.
+func (p *EthParser) Health() *HealthRegistry {
+	return p.healthRegistry
+}
+
+// registerHealthChecks registers the built-in health checks, called by New
+func (p *EthParser) registerHealthChecks() {
+	thresholds := &p.health.thresholds
+	if thresholds.RPCFailures <= 0 {
+		thresholds.RPCFailures = defaultHealthRPCFailures
+	}
+	if thresholds.StorageErrorWindow <= 0 {
+		thresholds.StorageErrorWindow = defaultHealthStorageErrorWindow
+	}
+	if thresholds.OutboxBacklog <= 0 {
+		thresholds.OutboxBacklog = defaultHealthOutboxBacklog
+	}
+	p.healthRegistry.Register("recovery", p.recoveryHealth)
+	p.healthRegistry.Register("warmup", p.warmUpHealth)
+	p.healthRegistry.Register("rpc", p.rpcHealth)
+	p.healthRegistry.Register("storage", p.storageHealth)
+	p.healthRegistry.Register("notifications", p.notificationsHealth)
+}
+
+// recoveryHealth is down while the startup recovery catches up
+func (p *EthParser) recoveryHealth() (string, string) {
+	if recovery := p.Recovery(); recovery.Active {
+		return HealthDown, recovery.String()
+	}
+	return HealthOK, ""
+}
+
+// warmUpHealth is down while a notifier isn't warmed up
+func (p *EthParser) warmUpHealth() (string, string) {
+	if pending := p.PendingWarmUps(); pending != "" {
+		return HealthDown, pending
+	}
+	return HealthOK, ""
+}
+
+// rpcHealth is down on a chain ID mismatch and after RPCFailures consecutive failed requests, degraded after
+// a failed one
+func (p *EthParser) rpcHealth() (string, string) {
+	if p.ChainIDMismatch() {
+		return HealthDown, "chain ID mismatch"
+	}
+	p.health.mu.Lock()
+	defer p.health.mu.Unlock()
+	switch {
+	case p.health.rpcFailures >= p.health.thresholds.RPCFailures:
+		return HealthDown, fmt.Sprintf("%d consecutive failed requests: %s", p.health.rpcFailures, p.health.rpcLastError)
+	case p.health.rpcFailures > 0:
+		return HealthDegraded, "last request failed: " + p.health.rpcLastError
+	}
+	return HealthOK, ""
+}
+
+// storageHealth is degraded for StorageErrorWindow after a write error
+func (p *EthParser) storageHealth() (string, string) {
+	p.health.mu.Lock()
+	defer p.health.mu.Unlock()
+	if p.health.storageErrorAt.IsZero() {
+		return HealthOK, ""
+	}
+	if since := p.clock.Now().Sub(p.health.storageErrorAt); since < p.health.thresholds.StorageErrorWindow {
+		return HealthDegraded, fmt.Sprintf("write error %s ago: %s", since.Round(time.Second), p.health.storageLastError)
+	}
+	return HealthOK, ""
+}
+
+// notificationsHealth is degraded while OutboxBacklog notifications or more are pending
+func (p *EthParser) notificationsHealth() (string, string) {
+	backlog := p.health.thresholds.OutboxBacklog
+	events, err := p.storage.PendingOutboxEvents(backlog)
+	if err != nil {
+		return HealthDegraded, "reading the outbox: " + err.Error()
+	}
+	if len(events) >= backlog {
+		return HealthDegraded, fmt.Sprintf("%d or more pending notifications", backlog)
+	}
+	return HealthOK, ""
+}
+
+// recordStorageWrite records the error of a storage write, if any, and returns it
+func (p *EthParser) recordStorageWrite(err error) error {
+	if err == nil {
+		return nil
+	}
+	p.health.mu.Lock()
+	p.health.storageErrorAt, p.health.storageLastError = p.clock.Now(), err.Error()
+	p.health.mu.Unlock()
+	return err
+}
+
+// healthClient is the JsonRpcClient decorator counting the consecutive failed requests of the parser. A
+// JSON-RPC error is an answer of the node, only the transport and decoding failures count.
+type healthClient struct {
+	client JsonRpcClient
+	health *healthState
+}
+
+// SendRequest sends the request to the wrapped client and records its outcome
+func (c *healthClient) SendRequest(req JSONRPCRequest) (JSONRPCResponse, error) {
+	resp, err := c.client.SendRequest(req)
+	c.health.mu.Lock()
+	if err != nil && resp.Error == nil {
+		c.health.rpcFailures++
+		// The node URL may hold the key of the provider
+		c.health.rpcLastError = redactedError(err).Error()
+	} else {
+		c.health.rpcFailures = 0
+	}
+	c.health.mu.Unlock()
+	return resp, err
+}
+
+// CancelRequests aborts the in-flight requests of the wrapped client, see RequestCanceler
+func (c *healthClient) CancelRequests() {
+	if canceler, ok := c.client.(RequestCanceler); ok {
+		canceler.CancelRequests()
+	}
+}
@@ -0,0 +1,95 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingStorage fails its storage transactions
+type failingStorage struct {
+	*MockStorage
+}
+
+func (s *failingStorage) WithTx(fn func(tx parser.StorageTx) error) error {
+	return errors.New("disk full")
+}
+
+func componentHealth(report parser.HealthReport, name string) parser.ComponentHealth {
+	for _, component := range report.Components {
+		if component.Name == name {
+			return component
+		}
+	}
+	return parser.ComponentHealth{}
+}
+
+func TestEthParserHealthRPC(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client, err := parser.NewFaultInjectionClient(NewMockClient(mockChain(1)), parser.FaultPolicy{TimeoutProbability: 1, Methods: []string{"eth_blockNumber"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, client, func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithHealthThresholds(parser.HealthThresholds{RPCFailures: 3}))
+	defer ethParser.WaitForShutdown()
+
+	// The request of Start failed
+	if health := componentHealth(ethParser.Health().Check(), "rpc"); health.Status != parser.HealthDegraded {
+		t.Fatalf("Expected a degraded node after a failure, got %+v", health)
+	}
+	ethParser.ProcessNextCycle()
+	ethParser.ProcessNextCycle()
+	report := ethParser.Health().Check()
+	if health := componentHealth(report, "rpc"); health.Status != parser.HealthDown || !strings.Contains(health.Reason, "3 consecutive failed requests") {
+		t.Fatalf("Expected the node to be down, got %+v", health)
+	}
+	if report.Ready() || !strings.HasPrefix(report.Reasons(), "rpc: ") {
+		t.Errorf("Expected the parser to be unready because of the node, got %+v", report)
+	}
+}
+
+func TestEthParserHealthStorage(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	blockchain := mockChain(1)
+	blockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{{Hash: "0xa1", From: "0x1", To: "0x2"}}})
+	clock := parser.NewManualClock(time.Now())
+	ethParser := parser.NewEthParser(ctx, &failingStorage{MockStorage: NewMockStorage()}, 1, NewMockClient(blockchain),
+		func(string, []parser.Transaction) {}, parser.WithClock(clock))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	report := ethParser.Health().Check()
+	if health := componentHealth(report, "storage"); health.Status != parser.HealthDegraded || !strings.Contains(health.Reason, "disk full") {
+		t.Fatalf("Expected a degraded storage, got %+v", health)
+	}
+	if !report.Ready() || report.Status != parser.HealthDegraded {
+		t.Errorf("A degraded storage keeps the parser ready, got %+v", report)
+	}
+
+	clock.Advance(5 * time.Minute)
+	if health := componentHealth(ethParser.Health().Check(), "storage"); health.Status != parser.HealthOK {
+		t.Errorf("Expected the error to be forgotten after the window, got %+v", health)
+	}
+}
+
+func TestHealthRegistry(t *testing.T) {
+	registry := parser.NewHealthRegistry()
+	registry.Register("broker", func() (string, string) { return parser.HealthDegraded, "reconnecting" })
+	registry.Register("cache", func() (string, string) { return parser.HealthOK, "" })
+	if report := registry.Check(); report.Status != parser.HealthDegraded || report.Reasons() != "broker: reconnecting" {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+	registry.Register("broker", func() (string, string) { return parser.HealthDown, "disconnected" })
+	if report := registry.Check(); report.Ready() || len(report.Components) != 2 || report.Components[0].Name != "broker" {
+		t.Errorf("Expected the replaced check to make the report unready, got %+v", report)
+	}
+}
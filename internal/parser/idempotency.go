@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"database/sql"
+	"time"
+)
+
+// IdempotencyRecord is the stored outcome of a write request sent with an idempotency key
+type IdempotencyRecord struct {
+	Key string `json:"key"`
+	// RequestHash identifies the request, so that a key reused for a different request can be rejected
+	RequestHash string    `json:"requestHash"`
+	StatusCode  int       `json:"statusCode"`
+	ContentType string    `json:"contentType"`
+	Body        []byte    `json:"body"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// IdempotencyStore keeps the outcome of the requests sent with an idempotency key, so that client retries
+// replay the first response instead of repeating the write
+type IdempotencyStore interface {
+	GetIdempotencyRecord(key string) (IdempotencyRecord, bool, error)
+	SaveIdempotencyRecord(record IdempotencyRecord) error
+	// DeleteIdempotencyRecordsBefore removes the records created before the given time
+	DeleteIdempotencyRecordsBefore(t time.Time) error
+}
+
+// GetIdempotencyRecord returns the record of an idempotency key
+func (s *MemoryStorage) GetIdempotencyRecord(key string) (IdempotencyRecord, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.idempotency[key]
+	return record, ok, nil
+}
+
+// SaveIdempotencyRecord stores the record of an idempotency key
+func (s *MemoryStorage) SaveIdempotencyRecord(record IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.idempotency[record.Key] = record
+	return nil
+}
+
+// DeleteIdempotencyRecordsBefore removes the records created before t
+func (s *MemoryStorage) DeleteIdempotencyRecordsBefore(t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, record := range s.idempotency {
+		if record.CreatedAt.Before(t) {
+			delete(s.idempotency, key)
+		}
+	}
+	return nil
+}
+
+// GetIdempotencyRecord returns the record of an idempotency key
+func (s *SQLStorage) GetIdempotencyRecord(key string) (IdempotencyRecord, bool, error) {
+	record := IdempotencyRecord{Key: key}
+	var body string
+	var createdAt int64
+	err := s.db.QueryRow(`SELECT request_hash, status_code, content_type, body, created_at FROM idempotency_keys WHERE key = $1`, key).
+		Scan(&record.RequestHash, &record.StatusCode, &record.ContentType, &body, &createdAt)
+	if err == sql.ErrNoRows {
+		return IdempotencyRecord{}, false, nil
+	}
+	if err != nil {
+		return IdempotencyRecord{}, false, err
+	}
+	if body, err = openField(s.cipher, body, "idempotency_keys/"+key); err != nil {
+		return IdempotencyRecord{}, false, err
+	}
+	record.Body = []byte(body)
+	record.CreatedAt = time.Unix(createdAt, 0)
+	return record, true, nil
+}
+
+// SaveIdempotencyRecord stores the record of an idempotency key
+func (s *SQLStorage) SaveIdempotencyRecord(record IdempotencyRecord) error {
+	body, err := sealField(s.cipher, string(record.Body), "idempotency_keys/"+record.Key)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO idempotency_keys (key, request_hash, status_code, content_type, body, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (key) DO UPDATE SET request_hash = excluded.request_hash, status_code = excluded.status_code,
+		content_type = excluded.content_type, body = excluded.body, created_at = excluded.created_at`,
+		record.Key, record.RequestHash, record.StatusCode, record.ContentType, body, record.CreatedAt.Unix())
+	return err
+}
+
+// DeleteIdempotencyRecordsBefore removes the records created before t
+func (s *SQLStorage) DeleteIdempotencyRecordsBefore(t time.Time) error {
+	_, err := s.db.Exec(`DELETE FROM idempotency_keys WHERE created_at < $1`, t.Unix())
+	return err
+}
@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Events sent when a subscribed address stays without transactions past its InactivityAlert, respectively
+// when it sees transactions again after the alert
+const (
+	EventAddressInactive = "address_inactive"
+	EventAddressActive   = "address_active"
+)
+
+// InactivityAlert requests an EventAddressInactive event when a subscribed address sees no transaction for a
+// period, e.g. a deposit expected within the hour that never arrived
+type InactivityAlert struct {
+	// After is the period without transactions, a Go duration such as "1h" or "30m"
+	After string `json:"after"`
+	// Repeat sends the alert again every period while the address stays inactive, it's sent once otherwise
+	Repeat bool `json:"repeat,omitempty"`
+}
+
+// Period returns the parsed inactivity period
+func (a InactivityAlert) Period() (time.Duration, error) {
+	period, err := time.ParseDuration(a.After)
+	if err != nil || period <= 0 {
+		return 0, fmt.Errorf("invalid inactivity period %q", a.After)
+	}
+	return period, nil
+}
+
+// inactivityTimer is the timer of the InactivityAlert of a subscription, it's only accessed under mu
+type inactivityTimer struct {
+	alert        InactivityAlert
+	period       time.Duration
+	lastActivity time.Time // transaction or subscription time
+	deadline     time.Time
+	alerted      bool
+}
+
+// setInactivityTimer starts, replaces or, without alert, stops the timer of the subscription of address for
+// tenant, empty without multi-tenancy. The period starts over from now.
+func (p *EthParser) setInactivityTimer(tenant string, address string, alert *InactivityAlert) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.setInactivityTimerLocked(tenant, address, alert)
+}
+
+// setInactivityTimerLocked is setInactivityTimer with mu held
+func (p *EthParser) setInactivityTimerLocked(tenant string, address string, alert *InactivityAlert) {
+	if alert == nil {
+		p.stopInactivityTimerLocked(tenant, address)
+		return
+	}
+	period, err := alert.Period()
+	if err != nil {
+		log.Printf("Ignoring the inactivity alert of address %s: %v\n", address, err)
+		p.stopInactivityTimerLocked(tenant, address)
+		return
+	}
+	timers := p.inactivity[address]
+	if timers == nil {
+		timers = make(map[string]*inactivityTimer)
+		p.inactivity[address] = timers
+	}
+	now := p.clock.Now()
+	timers[tenant] = &inactivityTimer{alert: *alert, period: period, lastActivity: now, deadline: now.Add(period)}
+}
+
+// stopInactivityTimer stops the timer of the subscription of address for tenant, if any
+func (p *EthParser) stopInactivityTimer(tenant string, address string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopInactivityTimerLocked(tenant, address)
+}
+
+// stopInactivityTimerLocked is stopInactivityTimer with mu held
+func (p *EthParser) stopInactivityTimerLocked(tenant string, address string) {
+	delete(p.inactivity[address], tenant)
+	if len(p.inactivity[address]) == 0 {
+		delete(p.inactivity, address)
+	}
+}
+
+// recordActivity restarts the timers of an address with matched transactions, sending an EventAddressActive
+// event for the ones that alerted
+func (p *EthParser) recordActivity(address string) {
+	p.mu.Lock()
+	now := p.clock.Now()
+	var events []Event
+	for tenant, timer := range p.inactivity[address] {
+		if timer.alerted {
+			events = append(events, Event{Type: EventAddressActive, Address: address, Data: map[string]string{
+				"tenant":       tenant,
+				"inactiveFor":  now.Sub(timer.lastActivity).Round(time.Second).String(),
+				"lastActivity": timer.lastActivity.UTC().Format(time.RFC3339),
+			}})
+		}
+		timer.lastActivity, timer.deadline, timer.alerted = now, now.Add(timer.period), false
+	}
+	p.mu.Unlock()
+
+	for _, event := range events {
+		p.emitEvent(event)
+	}
+}
+
+// checkInactivity sends an EventAddressInactive event for the timers past their deadline, at the end of
+// every fetch cycle, so that the alerts are as accurate as the fetch period
+func (p *EthParser) checkInactivity() {
+	p.mu.Lock()
+	now := p.clock.Now()
+	var events []Event
+	for address, timers := range p.inactivity {
+		for tenant, timer := range timers {
+			if now.Before(timer.deadline) || (timer.alerted && !timer.alert.Repeat) {
+				continue
+			}
+			events = append(events, Event{Type: EventAddressInactive, Address: address, Data: map[string]string{
+				"tenant":       tenant,
+				"after":        timer.alert.After,
+				"inactiveFor":  now.Sub(timer.lastActivity).Round(time.Second).String(),
+				"lastActivity": timer.lastActivity.UTC().Format(time.RFC3339),
+			}})
+			timer.alerted = true
+			timer.deadline = now.Add(timer.period)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, event := range events {
+		p.emitEvent(event)
+	}
+}
@@ -0,0 +1,100 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEthParserInactivityAlert(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	clock := parser.NewManualClock(time.Now())
+	var events []parser.Event
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain), func(string, []parser.Transaction) {},
+		parser.WithClock(clock), parser.WithEventNotification(func(event parser.Event) { events = append(events, event) }))
+	defer ethParser.WaitForShutdown()
+	ethParser.SubscribeWith(parser.Subscription{Address: "0x1", Inactivity: &parser.InactivityAlert{After: "1h"}})
+	ethParser.SubscribeWith(parser.Subscription{Address: "0x2", Inactivity: &parser.InactivityAlert{After: "1h", Repeat: true}})
+
+	clock.Advance(30 * time.Minute)
+	ethParser.ProcessNextCycle()
+	if len(events) != 0 {
+		t.Fatalf("Expected no alert before the period, got %+v", events)
+	}
+
+	clock.Advance(30 * time.Minute)
+	ethParser.ProcessNextCycle()
+	if len(events) != 2 || events[0].Type != parser.EventAddressInactive || events[0].Data["inactiveFor"] != "1h0m0s" {
+		t.Fatalf("Expected an alert for both addresses, got %+v", events)
+	}
+
+	// Only the repeated alert is sent again
+	events = nil
+	clock.Advance(time.Hour)
+	ethParser.ProcessNextCycle()
+	if len(events) != 1 || events[0].Address != "0x2" || events[0].Data["inactiveFor"] != "2h0m0s" {
+		t.Fatalf("Expected the alert of 0x2 only, got %+v", events)
+	}
+
+	// A transaction ends the inactivity
+	events = nil
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{{Hash: "0xa1", From: "0x1", To: "0x3", Value: "0x1"}}})
+	ethParser.ProcessNextCycle()
+	if len(events) != 1 || events[0].Type != parser.EventAddressActive || events[0].Address != "0x1" {
+		t.Fatalf("Expected 0x1 to be active again, got %+v", events)
+	}
+
+	// Unsubscribing stops the alerts
+	events = nil
+	ethParser.Unsubscribe("0x2")
+	clock.Advance(time.Hour)
+	ethParser.ProcessNextCycle()
+	if len(events) != 1 || events[0].Address != "0x1" {
+		t.Errorf("Expected the alert of 0x1 only, got %+v", events)
+	}
+}
+
+func TestTenantInactivityAlert(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := parser.NewManualClock(time.Now())
+	var events []parser.Event
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(NewMockBlockchain()), func(string, []parser.Transaction) {},
+		parser.WithClock(clock), parser.WithEventNotification(func(event parser.Event) { events = append(events, event) }))
+	defer ethParser.WaitForShutdown()
+	manager := parser.NewTenantManager(ethParser)
+	manager.CreateTenant("a", "A", 0)
+	manager.CreateTenant("b", "B", 0)
+	manager.View("a").TrySubscribe(parser.Subscription{Address: "0x1", Inactivity: &parser.InactivityAlert{After: "1h"}})
+	manager.View("b").TrySubscribe(parser.Subscription{Address: "0x1", Inactivity: &parser.InactivityAlert{After: "2h"}})
+
+	clock.Advance(time.Hour)
+	ethParser.ProcessNextCycle()
+	if len(events) != 1 || events[0].Data["tenant"] != "a" {
+		t.Fatalf("Expected the alert of tenant a, got %+v", events)
+	}
+
+	events = nil
+	manager.DeleteTenant("b")
+	clock.Advance(time.Hour)
+	ethParser.ProcessNextCycle()
+	if len(events) != 0 {
+		t.Errorf("Expected no alert of the deleted tenant, got %+v", events)
+	}
+}
+
+func TestImportSubscriptionsCSVInactivity(t *testing.T) {
+	imported, err := parser.ImportSubscriptions(strings.NewReader("address,inactivity_after\n0x1,72h\n"), parser.SubscriptionFormatCSV)
+	if err != nil || len(imported) != 1 || imported[0].Inactivity == nil || imported[0].Inactivity.After != "72h" {
+		t.Fatalf("Expected the inactivity alert to be imported, got %+v %v", imported, err)
+	}
+	if _, err := parser.ImportSubscriptions(strings.NewReader("address,inactivity_after\n0x1,-1h\n"), parser.SubscriptionFormatCSV); err == nil {
+		t.Error("Expected an error for an invalid inactivity period")
+	}
+}
@@ -0,0 +1,196 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// StageJournal is the name of the pipeline stage appending the matched transactions to the journal before
+// they're stored, see WithJournal
+const StageJournal = "journal"
+
+// JournalEntry is the journal record of a block, with the transactions matched for each address
+type JournalEntry struct {
+	Time    time.Time                `json:"time"`
+	Block   int                      `json:"block"`
+	Matches map[string][]Transaction `json:"matches"`
+}
+
+// Journal is an append-only file of the matched transactions, one JSON line per block synced to disk before
+// the block is stored and notified, so that the storage can be rebuilt with ReplayJournal after a crash or
+// with a MemoryStorage. The file isn't encrypted nor rotated.
+type Journal struct {
+	file *os.File
+	mu   sync.Mutex
+}
+
+// OpenJournal opens the journal file at path for appending, creating it if missing. A last line cut by a
+// crash during its write is truncated, so that the next entries start on their own line.
+func OpenJournal(path string) (*Journal, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := truncateTornEntry(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &Journal{file: file}, nil
+}
+
+// truncateTornEntry truncates the file after its last newline
+func truncateTornEntry(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	end := info.Size()
+	chunk := make([]byte, 4096)
+	for offset := end; offset > 0; {
+		n := int64(len(chunk))
+		if offset < n {
+			n = offset
+		}
+		offset -= n
+		if _, err := file.ReadAt(chunk[:n], offset); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(chunk[:n], '\n'); i >= 0 {
+			if last := offset + int64(i) + 1; last < end {
+				return file.Truncate(last)
+			}
+			return nil
+		}
+	}
+	if end > 0 {
+		return file.Truncate(0)
+	}
+	return nil
+}
+
+// Append writes an entry and syncs the file, the entry is durable once it returns nil
+func (j *Journal) Append(entry JournalEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.Write(line); err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+// Close closes the journal file
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+// journalStage appends the matches of the block to the journal. A failed append stops the block, so that no
+// transaction is stored nor notified without being journaled.
+func (p *EthParser) journalStage(ctx context.Context, block *BlockContext) error {
+	if p.journal == nil || len(block.Matches) == 0 {
+		return nil
+	}
+	if err := p.journal.Append(JournalEntry{Time: p.clock.Now(), Block: block.Number, Matches: block.Matches}); err != nil {
+		return fmt.Errorf("appending block %d to the journal: %w", block.Number, err)
+	}
+	return nil
+}
+
+// ReadJournal reads the entries of a journal file, in order. A last line cut by a crash during its write,
+// never synced hence never acknowledged, is ignored.
+func ReadJournal(path string) ([]JournalEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []JournalEntry
+	reader := bufio.NewReader(file)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("reading %s line %d: %w", path, lineNumber, err)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// JournalReplay is the outcome of ReplayJournal
+type JournalReplay struct {
+	Blocks int `json:"blocks"`
+	// Transactions counts the transactions saved, Skipped the ones the storage already had
+	Transactions int `json:"transactions"`
+	Skipped      int `json:"skipped"`
+}
+
+// ReplayJournal saves the transactions of a journal file missing from the storage, block by block. The
+// replayed transactions aren't notified again: the journal rebuilds the history, not the outbox.
+func ReplayJournal(path string, storage Storage) (JournalReplay, error) {
+	entries, err := ReadJournal(path)
+	if err != nil {
+		return JournalReplay{}, err
+	}
+	var replay JournalReplay
+	stored := make(map[string]map[string]bool) // address -> hashes in the storage
+	for _, entry := range entries {
+		err := storage.WithTx(func(tx StorageTx) error {
+			for address, transactions := range entry.Matches {
+				hashes := stored[address]
+				if hashes == nil {
+					hashes = make(map[string]bool)
+					for _, existing := range storage.GetTransactions(address) {
+						hashes[existing.Hash] = true
+					}
+					stored[address] = hashes
+				}
+				missing := make([]Transaction, 0, len(transactions))
+				for _, transaction := range transactions {
+					if hashes[transaction.Hash] {
+						replay.Skipped++
+						continue
+					}
+					hashes[transaction.Hash] = true
+					missing = append(missing, transaction)
+				}
+				if len(missing) == 0 {
+					continue
+				}
+				if err := tx.SaveTransactions(address, missing); err != nil {
+					return fmt.Errorf("saving transactions for address %s: %w", address, err)
+				}
+				replay.Transactions += len(missing)
+			}
+			return nil
+		})
+		if err != nil {
+			return replay, fmt.Errorf("replaying block %d: %w", entry.Block, err)
+		}
+		replay.Blocks++
+	}
+	return replay, nil
+}
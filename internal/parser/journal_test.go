@@ -0,0 +1,66 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEthParserJournalReplay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	journal, err := parser.OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{
+		{Hash: "0xa1", From: "0x1", To: "0x2", Value: "0x1"},
+		{Hash: "0xa2", From: "0x3", To: "0x4", Value: "0x1"},
+	}})
+	mockBlockchain.AddBlock(2, parser.Block{Number: "0x2", Transactions: []parser.Transaction{{Hash: "0xa3", From: "0x2", To: "0x1", Value: "0x1"}}})
+	ethParser := parser.NewEthParser(ctx, parser.NewMemoryStorage(), 1, NewMockClient(mockBlockchain), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithJournal(journal))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := parser.ReadJournal(path)
+	if err != nil || len(entries) != 2 || entries[0].Block != 1 || len(entries[0].Matches["0x1"]) != 1 {
+		t.Fatalf("Expected one entry per block with matches, got %+v %v", entries, err)
+	}
+
+	// A crash during a write leaves a partial line, ignored by the replay and truncated by the next open
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	file.WriteString(`{"time":"2024-01-01T00:00:00Z","block":3,"matc`)
+	file.Close()
+
+	// The replay rebuilds a fresh storage and skips the transactions already stored
+	storage := parser.NewMemoryStorage()
+	storage.SaveTransactions("0x1", []parser.Transaction{{Hash: "0xa1"}})
+	replay, err := parser.ReplayJournal(path, storage)
+	if err != nil || replay.Blocks != 2 || replay.Transactions != 1 || replay.Skipped != 1 {
+		t.Fatalf("Unexpected replay %+v %v", replay, err)
+	}
+	if transactions := storage.GetTransactions("0x1"); len(transactions) != 2 || transactions[1].Hash != "0xa3" {
+		t.Errorf("Expected the journaled transactions in the storage, got %+v", transactions)
+	}
+
+	journal, err = parser.OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal.Append(parser.JournalEntry{Block: 4, Matches: map[string][]parser.Transaction{"0x1": {{Hash: "0xa4"}}}})
+	journal.Close()
+	if entries, err := parser.ReadJournal(path); err != nil || len(entries) != 3 || entries[2].Block != 4 {
+		t.Errorf("Expected the entry appended after the partial line, got %+v %v", entries, err)
+	}
+}
@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// keccakRate is the rate in bytes of Keccak-256, the sponge absorbs the input in blocks of this size
+const keccakRate = 136
+
+// keccakRoundConstants are the iota constants of the 24 rounds of Keccak-f[1600]
+var keccakRoundConstants = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808A, 0x8000000080008000,
+	0x000000000000808B, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008A, 0x0000000000000088, 0x0000000080008009, 0x000000008000000A,
+	0x000000008000808B, 0x800000000000008B, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800A, 0x800000008000000A,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+// keccakRotations are the rho offsets of the lanes, indexed by x+5y
+var keccakRotations = [25]int{
+	0, 1, 62, 28, 27,
+	36, 44, 6, 55, 20,
+	3, 10, 43, 25, 39,
+	41, 45, 15, 21, 8,
+	18, 2, 61, 56, 14,
+}
+
+// keccak256 returns the legacy Keccak-256 hash used by Ethereum, which differs from the standardized SHA3-256
+// only by its padding. The standard library has no implementation of it.
+func keccak256(data []byte) []byte {
+	var state [25]uint64
+	for len(data) >= keccakRate {
+		keccakAbsorb(&state, data[:keccakRate])
+		data = data[keccakRate:]
+	}
+	last := make([]byte, keccakRate)
+	copy(last, data)
+	last[len(data)] ^= 0x01
+	last[keccakRate-1] ^= 0x80
+	keccakAbsorb(&state, last)
+
+	digest := make([]byte, 32)
+	for i := 0; i < 4; i++ {
+		binary.LittleEndian.PutUint64(digest[i*8:], state[i])
+	}
+	return digest
+}
+
+// keccakAbsorb xors a rate-sized block into the state and permutes it
+func keccakAbsorb(state *[25]uint64, block []byte) {
+	for i := 0; i < keccakRate/8; i++ {
+		state[i] ^= binary.LittleEndian.Uint64(block[i*8:])
+	}
+	keccakF1600(state)
+}
+
+// keccakF1600 is the Keccak-f[1600] permutation
+func keccakF1600(a *[25]uint64) {
+	var b [25]uint64
+	var c [5]uint64
+	for round := 0; round < 24; round++ {
+		// Theta
+		for x := 0; x < 5; x++ {
+			c[x] = a[x] ^ a[x+5] ^ a[x+10] ^ a[x+15] ^ a[x+20]
+		}
+		for x := 0; x < 5; x++ {
+			d := c[(x+4)%5] ^ bits.RotateLeft64(c[(x+1)%5], 1)
+			for y := 0; y < 25; y += 5 {
+				a[x+y] ^= d
+			}
+		}
+		// Rho and pi
+		for x := 0; x < 5; x++ {
+			for y := 0; y < 5; y++ {
+				b[y+5*((2*x+3*y)%5)] = bits.RotateLeft64(a[x+5*y], keccakRotations[x+5*y])
+			}
+		}
+		// Chi
+		for y := 0; y < 25; y += 5 {
+			for x := 0; x < 5; x++ {
+				a[x+y] = b[x+y] ^ (^b[(x+1)%5+y] & b[(x+2)%5+y])
+			}
+		}
+		// Iota
+		a[0] ^= keccakRoundConstants[round]
+	}
+}
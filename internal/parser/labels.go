@@ -0,0 +1,116 @@
+package parser
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// bundledLabels is the label database of well-known mainnet addresses shipped with the parser
+//
+//go:embed labels.json
+var bundledLabels []byte
+
+// AddressLabel is the human-readable name of a well-known address
+type AddressLabel struct {
+	Name string `json:"name"`
+	// Category is the kind of address: exchange, router, bridge, token, staking...
+	Category string `json:"category"`
+}
+
+// LabelDB maps addresses to their labels, lookups are case-insensitive
+type LabelDB struct {
+	labels map[string]AddressLabel
+	mu     sync.RWMutex
+}
+
+// NewLabelDB creates an empty LabelDB
+func NewLabelDB() *LabelDB {
+	return &LabelDB{labels: make(map[string]AddressLabel)}
+}
+
+// NewBundledLabelDB creates a LabelDB holding the bundled labels, which are mainnet addresses
+func NewBundledLabelDB() *LabelDB {
+	db := NewLabelDB()
+	if err := db.load(bundledLabels); err != nil {
+		panic(fmt.Sprintf("invalid bundled labels: %v", err))
+	}
+	return db
+}
+
+// Add labels an address, replacing its previous label
+func (db *LabelDB) Add(address string, label AddressLabel) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.labels[strings.ToLower(address)] = label
+}
+
+// LoadFile adds the labels of a JSON file mapping addresses to {"name", "category"} objects, in the format
+// of the bundled database. The labels of the file take precedence over the bundled ones.
+func (db *LabelDB) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := db.load(data); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return nil
+}
+
+// load adds the labels of a JSON document
+func (db *LabelDB) load(data []byte) error {
+	var labels map[string]AddressLabel
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return err
+	}
+	for address, label := range labels {
+		db.Add(address, label)
+	}
+	return nil
+}
+
+// Label returns the label of an address
+func (db *LabelDB) Label(address string) (AddressLabel, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	label, ok := db.labels[strings.ToLower(address)]
+	return label, ok
+}
+
+// annotateTransactions returns a copy of the transactions of an address with the labels of their
+// counterparties, their explorer links and the metadata of the subscription. They're set when reading and
+// notifying, not stored, so that changes of the database, of the explorer or of the metadata apply to the
+// whole history.
+func (p *EthParser) annotateTransactions(address string, transactions []Transaction) []Transaction {
+	metadata := p.subscriptionMetadata(address)
+	if (p.labels == nil && p.network == Network{} && metadata == nil && p.ensTTL <= 0) || len(transactions) == 0 {
+		return transactions
+	}
+	labeled := make([]Transaction, len(transactions))
+	for i, tx := range transactions {
+		labeled[i] = p.annotateTransaction(tx)
+		labeled[i].Metadata = metadata
+	}
+	return labeled
+}
+
+// annotateTransaction sets the labels and the ENS names of the sender and recipient of a transaction and its
+// explorer links
+func (p *EthParser) annotateTransaction(tx Transaction) Transaction {
+	tx.Links = p.network.TransactionLinks(tx)
+	p.annotateENSNames(&tx)
+	if p.labels == nil {
+		return tx
+	}
+	if label, ok := p.labels.Label(tx.From); ok {
+		tx.FromLabel = label.Name
+	}
+	if label, ok := p.labels.Label(tx.To); ok {
+		tx.ToLabel = label.Name
+	}
+	return tx
+}
@@ -0,0 +1,59 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLabelDB(t *testing.T) {
+	db := parser.NewBundledLabelDB()
+	if label, ok := db.Label("0x7A250D5630B4CF539739DF2C5DACB4C659F2488D"); !ok || label.Category != "router" {
+		t.Fatalf("Bundled label not found case-insensitively: %+v %v", label, ok)
+	}
+
+	path := filepath.Join(t.TempDir(), "labels.json")
+	os.WriteFile(path, []byte(`{"0x7a250d5630b4cf539739df2c5dacb4c659f2488d": {"name": "Router", "category": "router"},
+		"0x9": {"name": "Treasury", "category": "internal"}}`), 0o644)
+	if err := db.LoadFile(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if label, _ := db.Label("0x7a250d5630b4cf539739df2c5dacb4c659f2488d"); label.Name != "Router" {
+		t.Fatalf("User labels must take precedence, got %+v", label)
+	}
+}
+
+func TestTransactionsLabeled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{
+		Number:       "0x1",
+		Transactions: []parser.Transaction{{Hash: "0xabc", From: "0x1", To: "0x9", Value: "0x64"}},
+	})
+
+	labels := parser.NewLabelDB()
+	var notified []parser.Transaction
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain),
+		func(address string, transactions []parser.Transaction) { notified = append(notified, transactions...) },
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithLabels(labels))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+
+	labels.Add("0x9", parser.AddressLabel{Name: "Treasury", Category: "internal"})
+	ethParser.ProcessNextCycle()
+
+	if len(notified) != 1 || notified[0].ToLabel != "Treasury" || notified[0].FromLabel != "" {
+		t.Fatalf("Unexpected notified labels: %+v", notified)
+	}
+
+	// Labels are applied when reading, so later additions show up in the history
+	labels.Add("0x1", parser.AddressLabel{Name: "Hot wallet", Category: "internal"})
+	if transactions := ethParser.GetTransactions("0x1"); transactions[0].FromLabel != "Hot wallet" {
+		t.Fatalf("Unexpected labels: %+v", transactions)
+	}
+}
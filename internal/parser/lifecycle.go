@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrAlreadyRunning is returned by Start when the parser is running, or still stopping
+var ErrAlreadyRunning = errors.New("parser already running")
+
+// Start verifies the chain, detects the node capabilities when enabled, restores the saved subscriptions,
+// initializes the current block, warms up the notifiers and starts the background tasks, which run until Stop
+// or until ctx is canceled. A stopped parser can be started again.
+func (p *EthParser) Start(ctx context.Context) error {
+	p.lifecycleMu.Lock()
+	defer p.lifecycleMu.Unlock()
+	if p.running {
+		return ErrAlreadyRunning
+	}
+
+	p.restoreUsage()
+	p.verifyChainID()
+	if p.detect {
+		p.detectCapabilities()
+	}
+	p.restoreSubscriptions()
+	p.initializeCurrentBlock()
+
+	// Create a new Cancellable Context and set it in the parser the cancel() function
+	cancellableCtx, cancel := context.WithCancel(ctx)
+	p.cancel = cancel
+	p.ctx = cancellableCtx
+	p.running = true
+	p.stopped = make(chan struct{})
+	p.startWarmUps()
+
+	// Start the background tasks under the cancellableCtx
+	p.setupBackgroundUpdateTasks(cancellableCtx)
+
+	stopped := p.stopped
+	go func() {
+		p.wg.Wait()
+		p.lifecycleMu.Lock()
+		p.running = false
+		p.lifecycleMu.Unlock()
+		close(stopped)
+	}()
+	return nil
+}
+
+// Stop cancels the background tasks and waits for the running cycle to complete. It returns ctx.Err() when
+// ctx is done first, the tasks then keep draining and Running reports true until they're done.
+// Stopping a parser that isn't running does nothing.
+func (p *EthParser) Stop(ctx context.Context) error {
+	p.lifecycleMu.Lock()
+	if !p.running {
+		p.lifecycleMu.Unlock()
+		return nil
+	}
+	p.cancel()
+	stopped := p.stopped
+	p.lifecycleMu.Unlock()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Running reports whether the background tasks are running
+func (p *EthParser) Running() bool {
+	p.lifecycleMu.Lock()
+	defer p.lifecycleMu.Unlock()
+	return p.running
+}
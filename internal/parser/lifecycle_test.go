@@ -0,0 +1,108 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+// countingClient counts the requests sent to the node
+type countingClient struct {
+	*MockClient
+	requests int
+}
+
+func (c *countingClient) SendRequest(req parser.JSONRPCRequest) (parser.JSONRPCResponse, error) {
+	c.requests++
+	return c.MockClient.SendRequest(req)
+}
+
+// enteredClient signals when a block request reaches the gatedClient
+type enteredClient struct {
+	*gatedClient
+	entered chan struct{}
+}
+
+func (c *enteredClient) SendRequest(req parser.JSONRPCRequest) (parser.JSONRPCResponse, error) {
+	if req.Method == "eth_getBlockByNumber" {
+		select {
+		case c.entered <- struct{}{}:
+		default:
+		}
+	}
+	return c.gatedClient.SendRequest(req)
+}
+
+func TestEthParserLifecycle(t *testing.T) {
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1"})
+	client := &countingClient{MockClient: NewMockClient(mockBlockchain)}
+
+	ethParser := parser.New(NewMockStorage(), 1, client, func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())))
+	if ethParser.Running() || client.requests != 0 {
+		t.Fatalf("New must not start the parser nor call the node, %d requests", client.requests)
+	}
+
+	if err := ethParser.Start(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !ethParser.Running() || ethParser.GetCurrentBlock() != 1 {
+		t.Fatalf("Expected the parser to be running at block 1, got %d", ethParser.GetCurrentBlock())
+	}
+	if err := ethParser.Start(context.Background()); !errors.Is(err, parser.ErrAlreadyRunning) {
+		t.Errorf("Expected ErrAlreadyRunning, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := ethParser.Stop(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ethParser.Running() {
+		t.Fatal("Expected the parser to be stopped")
+	}
+	if err := ethParser.Stop(ctx); err != nil {
+		t.Errorf("Stopping a stopped parser must do nothing, got %v", err)
+	}
+
+	// A stopped parser can be started again
+	if err := ethParser.Start(context.Background()); err != nil {
+		t.Fatalf("Unexpected error restarting: %v", err)
+	}
+	ethParser.WaitForShutdown()
+	if ethParser.Running() {
+		t.Fatal("Expected the parser to be stopped")
+	}
+}
+
+func TestEthParserStopDeadline(t *testing.T) {
+	mockBlockchain := NewMockBlockchain()
+	for i := 1; i <= 3; i++ {
+		mockBlockchain.AddBlock(i, parser.Block{Number: "0x1"})
+	}
+	gate := make(chan struct{})
+	client := &enteredClient{gatedClient: &gatedClient{MockClient: NewMockClient(mockBlockchain), gate: gate}, entered: make(chan struct{}, 1)}
+	clock := parser.NewManualClock(time.Now())
+	ethParser := parser.New(NewMockStorage(), 1, client, func(string, []parser.Transaction) {}, parser.WithClock(clock))
+	if err := ethParser.Start(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// A cycle stuck on the node keeps the parser from stopping before the deadline
+	clock.Advance(time.Second)
+	<-client.entered
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := ethParser.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the deadline to expire, got %v", err)
+	}
+	if !ethParser.Running() {
+		t.Error("The parser is running until the cycle completes")
+	}
+
+	close(gate)
+	waitUntil(t, func() bool { return !ethParser.Running() })
+}
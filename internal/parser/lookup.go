@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Sources of a TransactionLookup
+const (
+	LookupSourceStorage = "storage"
+	LookupSourceNode    = "node"
+)
+
+// TransactionReceipt is the outcome of a mined transaction
+type TransactionReceipt struct {
+	Status            string `json:"status"`
+	GasUsed           string `json:"gasUsed"`
+	EffectiveGasPrice string `json:"effectiveGasPrice,omitempty"`
+	ContractAddress   string `json:"contractAddress,omitempty"`
+}
+
+// TransactionLookup is the result of a search by hash. Source tells whether the parser stored the
+// transaction for subscribed addresses, listed in Addresses, or it was fetched from the node.
+type TransactionLookup struct {
+	Transaction
+	Source    string              `json:"source"`
+	Addresses []string            `json:"addresses,omitempty"`
+	Pending   bool                `json:"pending"`
+	Receipt   *TransactionReceipt `json:"receipt,omitempty"`
+}
+
+// LookupTransaction searches a transaction by hash in the storage and, when the parser didn't store it,
+// on the node together with its receipt. found is false when neither knows the hash.
+func (p *EthParser) LookupTransaction(hash string) (TransactionLookup, bool, error) {
+	tx, addresses, found, err := p.storage.GetTransactionByHash(hash)
+	if err != nil {
+		return TransactionLookup{}, false, err
+	}
+	if found {
+		return TransactionLookup{Transaction: p.annotateTransaction(tx), Source: LookupSourceStorage, Addresses: addresses}, true, nil
+	}
+	return p.lookupTransactionOnNode(hash)
+}
+
+// lookupTransactionOnNode fetches a transaction and its receipt from the node
+func (p *EthParser) lookupTransactionOnNode(hash string) (TransactionLookup, bool, error) {
+	var tx Transaction
+	found, err := p.callResult("eth_getTransactionByHash", hash, &tx)
+	if err != nil || !found {
+		return TransactionLookup{}, false, err
+	}
+
+	lookup := TransactionLookup{Transaction: p.annotateTransaction(tx), Source: LookupSourceNode, Pending: tx.BlockNumber == ""}
+	if lookup.Pending {
+		return lookup, true, nil
+	}
+	if lookup.BlockNumberDecimal, err = convertHexNumberToDecimal(tx.BlockNumber); err != nil {
+		return TransactionLookup{}, false, err
+	}
+	lookup.BlobTransaction = lookup.IsBlobTransaction()
+
+	var receipt TransactionReceipt
+	if found, err = p.callResult("eth_getTransactionReceipt", hash, &receipt); err != nil {
+		return TransactionLookup{}, false, err
+	}
+	if found {
+		lookup.Receipt = &receipt
+	}
+	return lookup, true, nil
+}
+
+// callResult sends a request with a single parameter and decodes its object result into out.
+// It returns false when the node answers null.
+func (p *EthParser) callResult(method string, param string, out interface{}) (bool, error) {
+	resp, err := p.client.SendRequest(JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  []interface{}{param},
+		ID:      1,
+	})
+	if err != nil {
+		return false, err
+	}
+	if resp.Result == nil {
+		return false, nil
+	}
+	resultMap, ok := resp.Result.(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("unexpected result format")
+	}
+	resultBytes, err := json.Marshal(resultMap)
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(resultBytes, out)
+}
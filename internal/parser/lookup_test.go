@@ -0,0 +1,49 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestLookupTransaction(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{
+		Number: "0x1",
+		Transactions: []parser.Transaction{
+			{Hash: "0xabc", From: "0x1", To: "0x2", Value: "0x64"},
+			{Hash: "0xdef", From: "0x3", To: "0x4", Value: "0xc8"},
+		},
+	})
+
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain),
+		func(string, []parser.Transaction) {}, parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.Subscribe("0x2")
+	ethParser.ProcessNextCycle()
+
+	lookup, found, err := ethParser.LookupTransaction("0xabc")
+	if err != nil || !found {
+		t.Fatalf("Stored transaction not found: %v", err)
+	}
+	if lookup.Source != parser.LookupSourceStorage || len(lookup.Addresses) != 2 || lookup.Receipt != nil {
+		t.Fatalf("Unexpected lookup of a stored transaction: %+v", lookup)
+	}
+
+	lookup, found, err = ethParser.LookupTransaction("0xdef")
+	if err != nil || !found {
+		t.Fatalf("Node transaction not found: %v", err)
+	}
+	if lookup.Source != parser.LookupSourceNode || lookup.BlockNumberDecimal != 1 || lookup.Receipt == nil || lookup.Receipt.Status != "0x1" {
+		t.Fatalf("Unexpected lookup of a node transaction: %+v", lookup)
+	}
+
+	if _, found, err := ethParser.LookupTransaction("0x999"); err != nil || found {
+		t.Fatalf("Unexpected lookup of an unknown transaction: %v %v", found, err)
+	}
+}
@@ -0,0 +1,358 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownMatcher is returned by ParseMatcher for a spec whose name has no registered matcher
+var ErrUnknownMatcher = errors.New("unknown matcher")
+
+// Matcher selects the transactions of the filter stage: Match returns the addresses, among the sender and the
+// recipient, the transaction may be matched for. A probabilistic matcher may return addresses that aren't
+// subscribed, the filter stage keeps only the subscribed ones.
+type Matcher interface {
+	Match(tx Transaction) []string
+}
+
+// MatcherFactory builds the Matcher of the addresses subscribed when a cycle starts
+type MatcherFactory func(subscribed map[string]bool) Matcher
+
+// MatcherDriver returns the MatcherFactory configured by the parameters of a matcher spec
+type MatcherDriver func(params url.Values) (MatcherFactory, error)
+
+var (
+	matcherDriversMu sync.RWMutex
+	matcherDrivers   = make(map[string]MatcherDriver)
+)
+
+const (
+	// defaultBloomFalsePositives is the false positive rate of the bloom matcher
+	defaultBloomFalsePositives = 0.001
+	// defaultMatcherPrefixLength is the number of hex digits grouping the addresses of the prefix matcher
+	defaultMatcherPrefixLength = 4
+)
+
+func init() {
+	RegisterMatcher("exact", exactMatcherDriver)
+	RegisterMatcher("prefix", prefixMatcherDriver)
+	RegisterMatcher("bloom", bloomMatcherDriver)
+	RegisterMatcher("input", inputMatcherDriver)
+}
+
+// RegisterMatcher makes a matcher available to ParseMatcher under a name, e.g. from the init function of its
+// package. Like RegisterStorage, it panics when the name is already registered.
+func RegisterMatcher(name string, driver MatcherDriver) {
+	matcherDriversMu.Lock()
+	defer matcherDriversMu.Unlock()
+	if driver == nil {
+		panic("parser: RegisterMatcher driver is nil")
+	}
+	name = strings.ToLower(name)
+	if _, exists := matcherDrivers[name]; exists {
+		panic("parser: RegisterMatcher called twice for " + name)
+	}
+	matcherDrivers[name] = driver
+}
+
+// MatcherNames returns the registered matcher names, sorted
+func MatcherNames() []string {
+	matcherDriversMu.RLock()
+	defer matcherDriversMu.RUnlock()
+	names := make([]string, 0, len(matcherDrivers))
+	for name := range matcherDrivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseMatcher returns the MatcherFactory of a spec, a matcher name followed by its optional parameters:
+//   - exact, the set of the subscribed addresses, the default
+//   - prefix?length=4, the subscribed addresses sorted in groups of the same first hex digits, smaller than
+//     the set for the very large watchlists
+//   - bloom?fp=0.001, a bloom filter of the subscribed addresses with the false positive rate fp, the
+//     smallest for the watchlists of millions of addresses
+//   - input?pattern=^0xa9059cbb, the transactions of the subscribed addresses whose input data matches the
+//     regular expression, e.g. the calls of a function selector
+//
+// and the matchers added with RegisterMatcher
+func ParseMatcher(spec string) (MatcherFactory, error) {
+	name, query, _ := strings.Cut(spec, "?")
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid matcher parameters %q: %w", query, err)
+	}
+	matcherDriversMu.RLock()
+	driver := matcherDrivers[strings.ToLower(name)]
+	matcherDriversMu.RUnlock()
+	if driver == nil {
+		return nil, fmt.Errorf("%w %q, registered: %s", ErrUnknownMatcher, name, strings.Join(MatcherNames(), ", "))
+	}
+	return driver(params)
+}
+
+// MatchingStats measures the work of the filter stage against its matches, to quantify the benefit of a
+// cheaper matcher or fetching strategy
+type MatchingStats struct {
+	// BlocksScanned counts the blocks given to the filter stage, EmptyBlocks those without any match
+	BlocksScanned int `json:"blocksScanned"`
+	EmptyBlocks   int `json:"emptyBlocks"`
+	// TransactionsExamined counts the transactions of the scanned blocks, TransactionsMatched those matched
+	// for at least one address
+	TransactionsExamined int `json:"transactionsExamined"`
+	TransactionsMatched  int `json:"transactionsMatched"`
+	// Candidates counts the addresses proposed by the matcher, FalsePositives those that weren't subscribed
+	Candidates     int `json:"candidates"`
+	FalsePositives int `json:"falsePositives"`
+	// BytesDownloaded is the size of the scanned blocks as returned by the node
+	BytesDownloaded int64 `json:"bytesDownloaded"`
+}
+
+// BytesPerMatch returns the bytes downloaded per matched transaction, all of them when none matched
+func (s MatchingStats) BytesPerMatch() float64 {
+	if s.TransactionsMatched == 0 {
+		return float64(s.BytesDownloaded)
+	}
+	return float64(s.BytesDownloaded) / float64(s.TransactionsMatched)
+}
+
+// MatchingStats returns the totals of the work of the filter stage since the start
+func (p *EthParser) MatchingStats() MatchingStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.matching
+}
+
+// recordMatching adds the stats of a scanned block to the totals
+func (p *EthParser) recordMatching(block MatchingStats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.matching.BlocksScanned += block.BlocksScanned
+	if block.TransactionsMatched == 0 {
+		p.matching.EmptyBlocks++
+	}
+	p.matching.TransactionsExamined += block.TransactionsExamined
+	p.matching.TransactionsMatched += block.TransactionsMatched
+	p.matching.Candidates += block.Candidates
+	p.matching.FalsePositives += block.FalsePositives
+	p.matching.BytesDownloaded += block.BytesDownloaded
+}
+
+// newMatcher returns the Matcher of a cycle, from the matcher set with WithMatcher or the exact one
+func (p *EthParser) newMatcher(subscribed map[string]bool) Matcher {
+	if p.matcher == nil {
+		return exactMatcher(subscribed)
+	}
+	return p.matcher(subscribed)
+}
+
+// matchAddresses returns the sender and the recipient of a transaction accepted by accept, once for a
+// self-transfer whatever the case of its addresses
+func matchAddresses(tx Transaction, accept func(address string) bool) []string {
+	var addresses []string
+	fromMatched := tx.From != "" && accept(tx.From)
+	if fromMatched {
+		addresses = append(addresses, tx.From)
+	}
+	if tx.To != "" && !(fromMatched && isSelfTransfer(tx)) && accept(tx.To) {
+		addresses = append(addresses, tx.To)
+	}
+	return addresses
+}
+
+// rejectParams returns an error for the parameters of a spec other than the known ones
+func rejectParams(matcher string, params url.Values, known ...string) error {
+	for name := range params {
+		if !slices.Contains(known, name) {
+			return fmt.Errorf("unknown %s matcher parameter %q", matcher, name)
+		}
+	}
+	return nil
+}
+
+// exactMatcher matches the addresses of the subscribed set
+type exactMatcher map[string]bool
+
+// Match returns the subscribed sender and recipient
+func (m exactMatcher) Match(tx Transaction) []string {
+	return matchAddresses(tx, func(address string) bool { return m[address] })
+}
+
+// exactMatcherDriver is the driver of the exact matcher, without parameters
+func exactMatcherDriver(params url.Values) (MatcherFactory, error) {
+	if err := rejectParams("exact", params); err != nil {
+		return nil, err
+	}
+	return func(subscribed map[string]bool) Matcher { return exactMatcher(subscribed) }, nil
+}
+
+// prefixMatcher keeps the subscribed addresses in sorted slices of the addresses sharing their first digits,
+// without the per entry overhead of a map
+type prefixMatcher struct {
+	length int
+	groups map[string][]string // prefix -> sorted rest of the addresses
+}
+
+// newPrefixMatcher groups the subscribed addresses by their first length hex digits
+func newPrefixMatcher(subscribed map[string]bool, length int) *prefixMatcher {
+	m := &prefixMatcher{length: length, groups: make(map[string][]string)}
+	for address := range subscribed {
+		prefix, rest := m.split(address)
+		m.groups[prefix] = append(m.groups[prefix], rest)
+	}
+	for _, group := range m.groups {
+		sort.Strings(group)
+	}
+	return m
+}
+
+// split returns the prefix of an address and its rest
+func (m *prefixMatcher) split(address string) (string, string) {
+	digits := strings.TrimPrefix(address, "0x")
+	if len(digits) < m.length {
+		return digits, ""
+	}
+	return digits[:m.length], digits[m.length:]
+}
+
+// contains reports whether the address is one of the grouped ones
+func (m *prefixMatcher) contains(address string) bool {
+	prefix, rest := m.split(address)
+	group := m.groups[prefix]
+	i := sort.SearchStrings(group, rest)
+	return i < len(group) && group[i] == rest
+}
+
+// Match returns the subscribed sender and recipient
+func (m *prefixMatcher) Match(tx Transaction) []string {
+	return matchAddresses(tx, m.contains)
+}
+
+// prefixMatcherDriver is the driver of the prefix matcher, with the length parameter
+func prefixMatcherDriver(params url.Values) (MatcherFactory, error) {
+	if err := rejectParams("prefix", params, "length"); err != nil {
+		return nil, err
+	}
+	length := defaultMatcherPrefixLength
+	if value := params.Get("length"); value != "" {
+		var err error
+		if length, err = strconv.Atoi(value); err != nil || length < 1 || length > 40 {
+			return nil, fmt.Errorf("invalid prefix matcher length %q", value)
+		}
+	}
+	return func(subscribed map[string]bool) Matcher { return newPrefixMatcher(subscribed, length) }, nil
+}
+
+// bloomMatcher is a bloom filter of the subscribed addresses, its false positives are removed by the filter
+// stage
+type bloomMatcher struct {
+	bits   []uint64
+	size   uint64 // number of bits
+	hashes int
+}
+
+// newBloomMatcher sizes the filter of the subscribed addresses for the false positive rate
+func newBloomMatcher(subscribed map[string]bool, falsePositives float64) *bloomMatcher {
+	n := math.Max(float64(len(subscribed)), 1)
+	size := uint64(math.Ceil(-n * math.Log(falsePositives) / (math.Ln2 * math.Ln2)))
+	size = max(64, (size+63)/64*64)
+	m := &bloomMatcher{
+		bits:   make([]uint64, size/64),
+		size:   size,
+		hashes: max(1, int(math.Round(float64(size)/n*math.Ln2))),
+	}
+	for address := range subscribed {
+		m.add(address)
+	}
+	return m
+}
+
+// locations returns the two hashes of an address, combined into the bit locations by double hashing
+func (m *bloomMatcher) locations(address string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(address))
+	sum := h.Sum64()
+	return sum & math.MaxUint32, sum>>32 | 1
+}
+
+// add sets the bits of an address
+func (m *bloomMatcher) add(address string) {
+	h1, h2 := m.locations(address)
+	for i := 0; i < m.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % m.size
+		m.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// mayContain reports whether the address may have been added, false positives included
+func (m *bloomMatcher) mayContain(address string) bool {
+	h1, h2 := m.locations(address)
+	for i := 0; i < m.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % m.size
+		if m.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Match returns the sender and recipient that may be subscribed
+func (m *bloomMatcher) Match(tx Transaction) []string {
+	return matchAddresses(tx, m.mayContain)
+}
+
+// bloomMatcherDriver is the driver of the bloom matcher, with the fp parameter
+func bloomMatcherDriver(params url.Values) (MatcherFactory, error) {
+	if err := rejectParams("bloom", params, "fp"); err != nil {
+		return nil, err
+	}
+	falsePositives := defaultBloomFalsePositives
+	if value := params.Get("fp"); value != "" {
+		var err error
+		if falsePositives, err = strconv.ParseFloat(value, 64); err != nil || falsePositives <= 0 || falsePositives >= 1 {
+			return nil, fmt.Errorf("invalid bloom matcher false positive rate %q", value)
+		}
+	}
+	return func(subscribed map[string]bool) Matcher { return newBloomMatcher(subscribed, falsePositives) }, nil
+}
+
+// inputMatcher matches the subscribed addresses of the transactions whose input data matches a pattern
+type inputMatcher struct {
+	subscribed map[string]bool
+	pattern    *regexp.Regexp
+}
+
+// Match returns the subscribed sender and recipient of a transaction with a matching input
+func (m *inputMatcher) Match(tx Transaction) []string {
+	if !m.pattern.MatchString(tx.Input) {
+		return nil
+	}
+	return matchAddresses(tx, func(address string) bool { return m.subscribed[address] })
+}
+
+// inputMatcherDriver is the driver of the input matcher, with the required pattern parameter
+func inputMatcherDriver(params url.Values) (MatcherFactory, error) {
+	if err := rejectParams("input", params, "pattern"); err != nil {
+		return nil, err
+	}
+	if params.Get("pattern") == "" {
+		return nil, errors.New("missing input matcher pattern")
+	}
+	pattern, err := regexp.Compile(params.Get("pattern"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid input matcher pattern: %w", err)
+	}
+	return func(subscribed map[string]bool) Matcher {
+		return &inputMatcher{subscribed: subscribed, pattern: pattern}
+	}, nil
+}
@@ -0,0 +1,109 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestEthParserMatchers(t *testing.T) {
+	for spec, expected := range map[string]int{
+		"exact":                     2,
+		"prefix?length=2":           2,
+		"bloom?fp=0.01":             2,
+		"input?pattern=^0xa9059cbb": 1,
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		factory, err := parser.ParseMatcher(spec)
+		if err != nil {
+			t.Fatalf("%s: %v", spec, err)
+		}
+		blockchain := NewMockBlockchain()
+		blockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{
+			{Hash: "0xa1", From: "0xab01", To: "0xcd02", Input: "0xa9059cbb00"},
+			{Hash: "0xa2", From: "0xcd02", To: "0xab01", Input: "0x"},
+			{Hash: "0xa3", From: "0xab02", To: "0xcd02"},
+		}})
+		ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(blockchain), func(string, []parser.Transaction) {},
+			parser.WithClock(parser.NewManualClock(time.Now())), parser.WithMatcher(factory))
+		for i := 0; i < 100; i++ {
+			ethParser.Subscribe(fmt.Sprintf("0xab%03d", i+100))
+		}
+		ethParser.Subscribe("0xab01")
+		ethParser.ProcessNextCycle()
+
+		if transactions := ethParser.GetTransactions("0xab01"); len(transactions) != expected {
+			t.Errorf("%s: expected %d transactions, got %+v", spec, expected, transactions)
+		}
+		if transactions := ethParser.GetTransactions("0xab02"); len(transactions) != 0 {
+			t.Errorf("%s: expected no transaction of an unsubscribed address, got %+v", spec, transactions)
+		}
+		cancel()
+		ethParser.WaitForShutdown()
+	}
+}
+
+func TestEthParserMatchesSelfTransferOnce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A self-transfer is matched once, for its sender, even when its addresses differ in case
+	blockchain := NewMockBlockchain()
+	blockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{
+		{Hash: "0xa1", From: "0xAB01", To: "0xab01"},
+	}})
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(blockchain), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0xAB01")
+	ethParser.Subscribe("0xab01")
+	ethParser.ProcessNextCycle()
+
+	if transactions := ethParser.GetTransactions("0xAB01"); len(transactions) != 1 {
+		t.Errorf("Expected the self-transfer for its sender, got %+v", transactions)
+	}
+	if transactions := ethParser.GetTransactions("0xab01"); len(transactions) != 0 {
+		t.Errorf("Expected the self-transfer to be matched once, got %+v", transactions)
+	}
+}
+
+func TestParseMatcherErrors(t *testing.T) {
+	if _, err := parser.ParseMatcher("trie"); !errors.Is(err, parser.ErrUnknownMatcher) {
+		t.Errorf("Expected ErrUnknownMatcher, got %v", err)
+	}
+	for _, spec := range []string{"exact?length=2", "prefix?length=0", "bloom?fp=1", "input", "input?pattern=("} {
+		if _, err := parser.ParseMatcher(spec); err == nil {
+			t.Errorf("Expected an error for %s", spec)
+		}
+	}
+	if _, err := parser.ParseMatcher("BLOOM"); err != nil {
+		t.Errorf("Expected the name to be case insensitive, got %v", err)
+	}
+}
+
+func TestEthParserMatchingStats(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	blockchain := mockChain(3)
+	blockchain.AddBlock(2, parser.Block{Number: "0x2", Transactions: []parser.Transaction{
+		{Hash: "0xa1", From: "0x1", To: "0x1"}, {Hash: "0xa2", From: "0x2", To: "0x3"},
+	}})
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(blockchain), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	stats := ethParser.MatchingStats()
+	if stats.BlocksScanned != 3 || stats.EmptyBlocks != 2 || stats.TransactionsExamined != 2 || stats.TransactionsMatched != 1 ||
+		stats.FalsePositives != 0 || stats.BytesDownloaded == 0 {
+		t.Fatalf("Unexpected matching stats %+v", stats)
+	}
+	if stats.BytesPerMatch() != float64(stats.BytesDownloaded) {
+		t.Errorf("Expected the bytes of the only match, got %v", stats.BytesPerMatch())
+	}
+}
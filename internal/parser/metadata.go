@@ -0,0 +1,47 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MaxSubscriptionMetadataSize is the maximum size in bytes of the metadata of a subscription
+const MaxSubscriptionMetadataSize = 4096
+
+// ValidateSubscriptionMetadata checks that the metadata of a subscription is a JSON value within
+// MaxSubscriptionMetadataSize
+func ValidateSubscriptionMetadata(metadata json.RawMessage) error {
+	if len(metadata) == 0 {
+		return nil
+	}
+	if len(metadata) > MaxSubscriptionMetadataSize {
+		return fmt.Errorf("metadata must not exceed %d bytes", MaxSubscriptionMetadataSize)
+	}
+	if !json.Valid(metadata) {
+		return fmt.Errorf("metadata must be valid JSON")
+	}
+	return nil
+}
+
+// subscriptionMetadata returns the metadata of the subscription of an address, nil when it has none
+func (p *EthParser) subscriptionMetadata(address string) json.RawMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if subscription, exists := p.subscriptions[address]; exists {
+		return subscription.Metadata
+	}
+	return nil
+}
+
+// withMetadata returns a copy of the transactions with the metadata of a subscription
+func withMetadata(transactions []Transaction, metadata json.RawMessage) []Transaction {
+	if len(transactions) == 0 {
+		return transactions
+	}
+	echoed := make([]Transaction, len(transactions))
+	for i, tx := range transactions {
+		tx.Metadata = metadata
+		echoed[i] = tx
+	}
+	return echoed
+}
@@ -0,0 +1,78 @@
+package parser_test
+
+import (
+	"context"
+	"encoding/json"
+	"eth-parser/internal/parser"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEthParserSubscriptionMetadata(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	blockchain := mockChain(1)
+	blockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{{Hash: "0xa1", From: "0x1", To: "0x2"}}})
+	var notified []parser.Transaction
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(blockchain), func(address string, transactions []parser.Transaction) {
+		notified = append(notified, transactions...)
+	}, parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+	metadata := json.RawMessage(`{"userId":42,"orderId":"o-1"}`)
+	ethParser.SubscribeWith(parser.Subscription{Address: "0x1", Metadata: metadata})
+	ethParser.ProcessNextCycle()
+
+	if len(notified) != 1 || string(notified[0].Metadata) != string(metadata) {
+		t.Fatalf("Expected the notification to echo the metadata, got %+v", notified)
+	}
+	if transactions := ethParser.GetTransactions("0x1"); len(transactions) != 1 || string(transactions[0].Metadata) != string(metadata) {
+		t.Fatalf("Expected the transactions to echo the metadata, got %+v", transactions)
+	}
+
+	// The metadata isn't stored with the transactions, an update applies to the whole history
+	ethParser.UpdateSubscription(parser.Subscription{Address: "0x1", Metadata: json.RawMessage(`"v2"`)})
+	if transactions := ethParser.GetTransactions("0x1"); string(transactions[0].Metadata) != `"v2"` {
+		t.Errorf("Expected the updated metadata, got %s", transactions[0].Metadata)
+	}
+}
+
+func TestTenantSubscriptionMetadata(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	blockchain := mockChain(1)
+	blockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{{Hash: "0xa1", From: "0x1", To: "0x2"}}})
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(blockchain),
+		func(string, []parser.Transaction) {}, parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+	tenants := parser.NewTenantManager(ethParser)
+	tenants.CreateTenant("a", "Team A", 0)
+	tenants.CreateTenant("b", "Team B", 0)
+	tenantA, tenantB := tenants.View("a"), tenants.View("b")
+	tenantA.TrySubscribe(parser.Subscription{Address: "0x1", Metadata: json.RawMessage(`"a"`)})
+	tenantB.TrySubscribe(parser.Subscription{Address: "0x1", Metadata: json.RawMessage(`"b"`)})
+	ethParser.ProcessNextCycle()
+
+	if transactions := tenantA.GetTransactions("0x1"); len(transactions) != 1 || string(transactions[0].Metadata) != `"a"` {
+		t.Fatalf("Expected the metadata of tenant a, got %+v", transactions)
+	}
+	result := tenantB.QueryTransactions(parser.TxQuery{Addresses: []string{"0x1"}})
+	if transactions := result.Transactions["0x1"]; len(transactions) != 1 || string(transactions[0].Metadata) != `"b"` {
+		t.Errorf("Expected the metadata of tenant b, got %+v", result)
+	}
+}
+
+func TestImportSubscriptionsMetadata(t *testing.T) {
+	subscriptions, err := parser.ImportSubscriptions(strings.NewReader("address,metadata\n0x1,\"{\"\"user\"\":1}\"\n"), parser.SubscriptionFormatCSV)
+	if err != nil || len(subscriptions) != 1 || string(subscriptions[0].Metadata) != `{"user":1}` {
+		t.Fatalf("Expected the metadata column to be imported, got %+v %v", subscriptions, err)
+	}
+	if _, err := parser.ImportSubscriptions(strings.NewReader("address,metadata\n0x1,{user\n"), parser.SubscriptionFormatCSV); err == nil {
+		t.Error("Expected an error for invalid JSON metadata")
+	}
+	if err := parser.ValidateSubscriptionMetadata(json.RawMessage(`"` + strings.Repeat("x", parser.MaxSubscriptionMetadataSize) + `"`)); err == nil {
+		t.Error("Expected an error for oversized metadata")
+	}
+}
@@ -0,0 +1,78 @@
+package parser
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// Migration is a single versioned schema change for SQL storage backends
+type Migration struct {
+	Version     int
+	Description string
+	Statements  []string
+}
+
+// Migrate applies, in version order, every migration that has not been recorded yet in the
+// schema_migrations table. Each migration runs in its own database transaction together with
+// the insertion of its version row, so a failed migration leaves the schema at the previous version.
+func Migrate(ctx context.Context, db *sql.DB, migrations []Migration) error {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Version == sorted[i-1].Version {
+			return fmt.Errorf("duplicate migration version %d", sorted[i].Version)
+		}
+	}
+
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version     INTEGER PRIMARY KEY,
+		description TEXT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("creating schema_migrations table: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("reading current schema version: %w", err)
+	}
+
+	for _, m := range sorted {
+		if m.Version <= current {
+			continue
+		}
+		if err := applyMigration(ctx, db, m); err != nil {
+			return err
+		}
+		log.Printf("Applied migration %d: %s\n", m.Version, m.Description)
+	}
+
+	return nil
+}
+
+// applyMigration runs the statements of a migration and records its version atomically
+func applyMigration(ctx context.Context, db *sql.DB, m Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("migration %d: %w", m.Version, err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range m.Statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, description) VALUES ($1, $2)`,
+		m.Version, m.Description); err != nil {
+		return fmt.Errorf("migration %d: recording version: %w", m.Version, err)
+	}
+
+	return tx.Commit()
+}
@@ -1,9 +1,11 @@
 package parser_test
 
 import (
+	"context"
 	"encoding/json"
 	"eth-parser/internal/parser"
 	"fmt"
+	"sort"
 	"strconv"
 	"sync"
 )
@@ -14,8 +16,17 @@ import (
 
 // MockStorage implements the Storage interface for testing purposes
 type MockStorage struct {
-	data map[string][]parser.Transaction
-	mu   sync.Mutex
+	data      map[string][]parser.Transaction
+	outbox    []parser.OutboxEvent
+	deletions []mockDeletion
+	mu        sync.Mutex
+}
+
+// mockDeletion is a DeleteTransactions call, replayed by WithTx
+type mockDeletion struct {
+	address   string
+	fromBlock int
+	toBlock   int
 }
 
 // NewMockStorage creates a new instance of MockStorage
@@ -40,10 +51,118 @@ func (m *MockStorage) GetTransactions(address string) []parser.Transaction {
 	return m.data[address]
 }
 
+// GetTransactionByHash scans the mock storage for a hash
+func (m *MockStorage) GetTransactionByHash(hash string) (parser.Transaction, []string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var found parser.Transaction
+	var addresses []string
+	for address, transactions := range m.data {
+		for _, tx := range transactions {
+			if tx.Hash == hash {
+				found = tx
+				addresses = append(addresses, address)
+				break
+			}
+		}
+	}
+	sort.Strings(addresses)
+	return found, addresses, len(addresses) > 0, nil
+}
+
+// WithTx applies the writes of fn to the mock storage only when fn succeeds
+func (m *MockStorage) WithTx(fn func(tx parser.StorageTx) error) error {
+	staged := NewMockStorage()
+	if err := fn(staged); err != nil {
+		return err
+	}
+	for _, deletion := range staged.deletions {
+		m.DeleteTransactions(deletion.address, deletion.fromBlock, deletion.toBlock)
+	}
+	for address, transactions := range staged.data {
+		m.SaveTransactions(address, transactions)
+		if len(staged.deletions) > 0 {
+			m.mu.Lock()
+			sort.SliceStable(m.data[address], func(i, j int) bool {
+				return m.data[address][i].BlockNumberDecimal < m.data[address][j].BlockNumberDecimal
+			})
+			m.mu.Unlock()
+		}
+	}
+	for _, event := range staged.outbox {
+		m.AddOutboxEvent(event)
+	}
+	return nil
+}
+
+// DeleteTransactions removes the transactions of an address in a block range
+func (m *MockStorage) DeleteTransactions(address string, fromBlock int, toBlock int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deletions = append(m.deletions, mockDeletion{address: address, fromBlock: fromBlock, toBlock: toBlock})
+	var kept []parser.Transaction
+	for _, tx := range m.data[address] {
+		if tx.BlockNumberDecimal < fromBlock || tx.BlockNumberDecimal > toBlock {
+			kept = append(kept, tx)
+		}
+	}
+	m.data[address] = kept
+	return nil
+}
+
+// AddOutboxEvent appends an event to the mock outbox
+func (m *MockStorage) AddOutboxEvent(event parser.OutboxEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outbox = append(m.outbox, event)
+	return nil
+}
+
+// PendingOutboxEvents returns up to limit events of the mock outbox
+func (m *MockStorage) PendingOutboxEvents(limit int) ([]parser.OutboxEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if limit > len(m.outbox) {
+		limit = len(m.outbox)
+	}
+	return append([]parser.OutboxEvent(nil), m.outbox[:limit]...), nil
+}
+
+// AckOutboxEvents removes the acknowledged events from the mock outbox
+func (m *MockStorage) AckOutboxEvents(ids []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	acked := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		acked[id] = true
+	}
+	var pending []parser.OutboxEvent
+	for _, event := range m.outbox {
+		if !acked[event.ID] {
+			pending = append(pending, event)
+		}
+	}
+	m.outbox = pending
+	return nil
+}
+
+// Stats returns the number of addresses and transactions of the mock storage
+func (m *MockStorage) Stats() (parser.StorageStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := parser.StorageStats{Addresses: len(m.data)}
+	for _, transactions := range m.data {
+		stats.Transactions += len(transactions)
+	}
+	return stats, nil
+}
+
 // MockBlockchain simulates blockchain data for testing
 type MockBlockchain struct {
-	Blocks map[int]parser.Block
-	mu     sync.Mutex
+	Blocks  map[int]parser.Block
+	Pending parser.Block
+	ChainID int
+	mu      sync.Mutex
 }
 
 // ============================================
@@ -75,6 +194,21 @@ func (m *MockBlockchain) GetBlockByNumber(number int) (parser.Block, error) {
 	return block, nil
 }
 
+// FindTransaction returns a mined transaction by hash, with the number of its block
+func (m *MockBlockchain) FindTransaction(hash string) (parser.Transaction, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for number, block := range m.Blocks {
+		for _, tx := range block.Transactions {
+			if tx.Hash == hash {
+				tx.BlockNumber = fmt.Sprintf("0x%x", number)
+				return tx, true
+			}
+		}
+	}
+	return parser.Transaction{}, false
+}
+
 // ============================================
 // MOCK JSONRPC Client
 // ============================================
@@ -100,8 +234,24 @@ func (m *MockClient) SendRequest(req parser.JSONRPCRequest) (parser.JSONRPCRespo
 		}, nil
 	}
 
+	if req.Method == "eth_chainId" {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return parser.JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Result:  fmt.Sprintf("0x%x", m.ChainID),
+		}, nil
+	}
+
 	if req.Method == "eth_getBlockByNumber" {
 		blockNumberHex := req.Params[0].(string)
+		if blockNumberHex == "pending" {
+			m.mu.Lock()
+			pending := m.Pending
+			m.mu.Unlock()
+			return jsonResponse(req, pending)
+		}
 		blockNumber, err := strconv.ParseInt(blockNumberHex[2:], 16, 64)
 		if err != nil {
 			return parser.JSONRPCResponse{}, err
@@ -110,20 +260,64 @@ func (m *MockClient) SendRequest(req parser.JSONRPCRequest) (parser.JSONRPCRespo
 		if err != nil {
 			return parser.JSONRPCResponse{}, err
 		}
-		resultBytes, err := json.Marshal(block)
-		if err != nil {
-			return parser.JSONRPCResponse{}, err
+		return jsonResponse(req, block)
+	}
+
+	if req.Method == "eth_getTransactionByHash" || req.Method == "eth_getTransactionReceipt" {
+		tx, found := m.FindTransaction(req.Params[0].(string))
+		if !found {
+			return parser.JSONRPCResponse{JSONRPC: "2.0", ID: req.ID}, nil
 		}
-		var result interface{}
-		if err := json.Unmarshal(resultBytes, &result); err != nil {
-			return parser.JSONRPCResponse{}, err
+		if req.Method == "eth_getTransactionReceipt" {
+			return parser.JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: map[string]interface{}{
+				"status": "0x1", "gasUsed": "0x5208", "blockNumber": tx.BlockNumber, "from": tx.From,
+			}}, nil
 		}
-		return parser.JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      req.ID,
-			Result:  result,
-		}, nil
+		return jsonResponse(req, tx)
 	}
 
 	return parser.JSONRPCResponse{}, fmt.Errorf("unsupported method: %s", req.Method)
 }
+
+// jsonResponse builds a JSON-RPC response with the JSON object of result, as decoded from the node
+func jsonResponse(req parser.JSONRPCRequest, result interface{}) (parser.JSONRPCResponse, error) {
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return parser.JSONRPCResponse{}, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(resultBytes, &decoded); err != nil {
+		return parser.JSONRPCResponse{}, err
+	}
+	return parser.JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result:  decoded,
+	}, nil
+}
+
+// ============================================
+// MOCK Head Subscriber
+// ============================================
+
+// MockHeadSubscriber pushes the heads sent on Heads, a closed Heads ends the subscription
+type MockHeadSubscriber struct {
+	Heads chan int
+	mu    sync.Mutex
+	used  bool
+}
+
+func NewMockHeadSubscriber() *MockHeadSubscriber {
+	return &MockHeadSubscriber{Heads: make(chan int, 10)}
+}
+
+// SubscribeNewHeads returns the Heads channel once, later subscriptions fail
+func (m *MockHeadSubscriber) SubscribeNewHeads(ctx context.Context) (<-chan int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.used {
+		return nil, fmt.Errorf("subscription not available")
+	}
+	m.used = true
+	return m.Heads, nil
+}
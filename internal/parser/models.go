@@ -1,5 +1,10 @@
 package parser
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // JSONRPCRequest represents the structure of a JSON-RPC request
 type JSONRPCRequest struct {
 	JSONRPC string        `json:"jsonrpc"`
@@ -12,10 +17,13 @@ type JSONRPCRequest struct {
 type JSONRPCResponse struct {
 	JSONRPC string      `json:"jsonrpc"`
 	ID      int         `json:"id"`
-	Result  interface{} `json:"result"`
+	Result  interface{} `json:"result"` // JSON numbers are json.Number, see DefaultClient.SendRequest
 	Error   interface{} `json:"error"`
 }
 
+// BlobTransactionType is the EIP-2718 type of EIP-4844 blob-carrying transactions
+const BlobTransactionType = "0x3"
+
 // Transaction represents a simplified Ethereum transaction
 type Transaction struct {
 	Hash               string `json:"hash"`
@@ -24,10 +32,74 @@ type Transaction struct {
 	Value              string `json:"value"`
 	BlockNumber        string `json:"blockNumber"`
 	BlockNumberDecimal int    `json:"-"`
+	// BlockTime is the timestamp of the block, set when the block has one; the transactions stored before it was
+	// recorded have none
+	BlockTime        *time.Time `json:"blockTime,omitempty"`
+	Type             string     `json:"type,omitempty"`
+	Nonce            string     `json:"nonce,omitempty"`
+	TransactionIndex string     `json:"transactionIndex,omitempty"`
+	BlockHash        string     `json:"blockHash,omitempty"`
+	Input            string     `json:"input,omitempty"`
+	// EventID is the deterministic ID of the transaction in its block, see TransactionEventID
+	EventID string `json:"eventId,omitempty"`
+	// Fee fields, GasPrice for legacy transactions and MaxFeePerGas/MaxPriorityFeePerGas for EIP-1559 ones
+	GasPrice             string `json:"gasPrice,omitempty"`
+	MaxFeePerGas         string `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas string `json:"maxPriorityFeePerGas,omitempty"`
+	// EIP-4844 fields, only set on blob transactions
+	MaxFeePerBlobGas    string   `json:"maxFeePerBlobGas,omitempty"`
+	BlobVersionedHashes []string `json:"blobVersionedHashes,omitempty"`
+	BlobTransaction     bool     `json:"blobTransaction,omitempty"`
+	// Price enrichment, set when a PriceProvider is configured: the ETH/USD price at block time and the USD value
+	PriceUSD string `json:"priceUsd,omitempty"`
+	ValueUSD string `json:"valueUsd,omitempty"`
+	// Token price enrichment, set on the token transfers whose token symbol has a price: the token/USD price at
+	// block time and the USD value of the transferred amount
+	TokenPriceUSD string `json:"tokenPriceUsd,omitempty"`
+	TokenValueUSD string `json:"tokenValueUsd,omitempty"`
+	// Computed values in the units selected with ?units=, set when reading, see WithUnits
+	ValueWei       string `json:"valueWei,omitempty"`
+	ValueEth       string `json:"valueEth,omitempty"`
+	ValueFormatted string `json:"valueFormatted,omitempty"`
+	// Names of the sender and recipient in the label database of well-known addresses, see WithLabels
+	FromLabel string `json:"fromLabel,omitempty"`
+	ToLabel   string `json:"toLabel,omitempty"`
+	// Primary ENS names of the sender and recipient, set when reading like the labels, see WithENSNames
+	FromENS string `json:"fromEns,omitempty"`
+	ToENS   string `json:"toEns,omitempty"`
+	// Links are the block explorer links of the network, set when reading like the labels, see WithNetwork
+	Links *ExplorerLinks `json:"links,omitempty"`
+	// Category set by the TransactionClassifier, see WithClassifier
+	Category string `json:"category,omitempty"`
+	// Token is the metadata of the token of a token transfer, see WithTokenMetadata
+	Token *TokenMetadata `json:"token,omitempty"`
+	// ScreeningFlag is set when the counterparty is flagged by a Screener, see WithScreener
+	ScreeningFlag *ScreeningFlag `json:"screeningFlag,omitempty"`
+	// Direction relative to the queried address, only set in the API responses, see TransactionsWithDirection
+	Direction string `json:"direction,omitempty"`
+	// DiscoveredBlock is the block whose processing stored the transaction, a later one for the transactions
+	// found by a rescan or a retried dead letter, see TransactionChanges
+	DiscoveredBlock int `json:"discoveredBlock,omitempty"`
+	// Historical is set on the transactions of the blocks caught up by a startup recovery, see WithRecoveryNotifications
+	Historical bool `json:"historical,omitempty"`
+	// Test is set on the synthetic transaction of SendTestNotification
+	Test bool `json:"test,omitempty"`
+	// Metadata echoes the metadata of the subscription of the address, set when reading and notifying
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+}
+
+// IsBlobTransaction reports whether the transaction is an EIP-4844 blob transaction
+func (tx Transaction) IsBlobTransaction() bool {
+	return tx.Type == BlobTransactionType
 }
 
 // Block represents a simplified Ethereum block
 type Block struct {
 	Number       string        `json:"number"`
+	Hash         string        `json:"hash,omitempty"`
+	Timestamp    string        `json:"timestamp,omitempty"`
 	Transactions []Transaction `json:"transactions"`
+	// EIP-4844 header fields
+	BlobGasUsed   string `json:"blobGasUsed,omitempty"`
+	ExcessBlobGas string `json:"excessBlobGas,omitempty"`
 }
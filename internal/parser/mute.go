@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"log"
+	"time"
+)
+
+// MutedAt reports whether the notifications of the subscription are muted at a time
+func (s Subscription) MutedAt(now time.Time) bool {
+	return s.Muted && (s.MutedUntil == nil || now.Before(*s.MutedUntil))
+}
+
+// Mute pauses the notifications of a subscribed address for duration, until Unmute when it's zero, e.g. while
+// its owner makes planned large transfers. The address is still indexed: its transactions are stored and its
+// outbox events are acknowledged without being delivered. The test notifications are still sent.
+func (p *EthParser) Mute(address string, duration time.Duration) (Subscription, bool) {
+	var until *time.Time
+	if duration > 0 {
+		expiry := p.clock.Now().Add(duration)
+		until = &expiry
+	}
+	subscription, ok := p.setMute(address, true, until)
+	if ok {
+		log.Printf("Muted the notifications of %s until %s\n", address, muteEnd(until))
+	}
+	return subscription, ok
+}
+
+// Unmute resumes the notifications of a subscribed address, the events acknowledged while it was muted
+// aren't delivered
+func (p *EthParser) Unmute(address string) (Subscription, bool) {
+	subscription, ok := p.setMute(address, false, nil)
+	if ok {
+		log.Printf("Unmuted the notifications of %s\n", address)
+	}
+	return subscription, ok
+}
+
+// setMute sets the mute of a subscription and saves it, it reports false when the address isn't subscribed
+func (p *EthParser) setMute(address string, muted bool, until *time.Time) (Subscription, bool) {
+	p.mu.Lock()
+	subscription, exists := p.subscriptions[address]
+	if !exists {
+		p.mu.Unlock()
+		return Subscription{}, false
+	}
+	subscription.Muted, subscription.MutedUntil = muted, until
+	updated := *subscription
+	p.mu.Unlock()
+	p.persistSubscription(address)
+	return updated, true
+}
+
+// mutedAddress reports whether the notifications of an address are muted
+func (p *EthParser) mutedAddress(address string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	subscription, exists := p.subscriptions[address]
+	return exists && subscription.MutedAt(p.clock.Now())
+}
+
+// muteEnd describes the end of a mute in the logs
+func muteEnd(until *time.Time) string {
+	if until == nil {
+		return "unmuted"
+	}
+	return until.Format(time.RFC3339)
+}
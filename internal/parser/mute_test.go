@@ -0,0 +1,85 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestEthParserMute(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	blockchain := mockChain(1)
+	blockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{{Hash: "0xa1", From: "0x1", To: "0x2"}}})
+	var notified []string
+	clock := parser.NewManualClock(time.Now())
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(blockchain), func(address string, transactions []parser.Transaction) {
+		notified = append(notified, transactions[0].Hash)
+	}, parser.WithClock(clock))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	if _, ok := ethParser.Mute("0x2", 0); ok {
+		t.Fatal("Expected an unsubscribed address not to be muted")
+	}
+
+	if subscription, ok := ethParser.Mute("0x1", 0); !ok || !subscription.Muted || subscription.MutedUntil != nil {
+		t.Fatalf("Expected the address to be muted until unmuted, got %+v", subscription)
+	}
+	ethParser.ProcessNextCycle()
+	if len(notified) != 0 || len(ethParser.GetTransactions("0x1")) != 1 {
+		t.Fatalf("Expected the muted address to be indexed without notification, got %v", notified)
+	}
+
+	ethParser.Unmute("0x1")
+	blockchain.AddBlock(2, parser.Block{Number: "0x2", Transactions: []parser.Transaction{{Hash: "0xa2", From: "0x1", To: "0x2"}}})
+	ethParser.ProcessNextCycle()
+	if len(notified) != 1 || notified[0] != "0xa2" {
+		t.Fatalf("Expected only the transactions after the unmute to be notified, got %v", notified)
+	}
+
+	// A mute with a duration ends by itself
+	subscription, _ := ethParser.Mute("0x1", time.Hour)
+	if !subscription.MutedAt(clock.Now()) || subscription.MutedAt(clock.Now().Add(time.Hour)) {
+		t.Errorf("Expected the address to be muted for an hour, got %+v", subscription)
+	}
+	clock.Advance(time.Hour)
+	blockchain.AddBlock(3, parser.Block{Number: "0x3", Transactions: []parser.Transaction{{Hash: "0xa3", From: "0x1", To: "0x2"}}})
+	ethParser.ProcessNextCycle()
+	if len(notified) != 2 || notified[1] != "0xa3" {
+		t.Errorf("Expected the notifications to resume after the mute, got %v", notified)
+	}
+}
+
+func TestTenantMute(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockChain(1)),
+		func(string, []parser.Transaction) {}, parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+	tenants := parser.NewTenantManager(ethParser)
+	tenants.CreateTenant("a", "Team A", 0)
+	tenants.CreateTenant("b", "Team B", 0)
+	tenantA, tenantB := tenants.View("a"), tenants.View("b")
+	tenantA.Subscribe("0x1")
+	tenantB.Subscribe("0x1")
+
+	tenantA.Mute("0x1", time.Hour)
+	if shared, _ := ethParser.GetSubscription("0x1"); shared.Muted {
+		t.Fatal("Expected the shared address to be notified while a tenant isn't muted")
+	}
+	tenantB.Mute("0x1", 0)
+	if shared, _ := ethParser.GetSubscription("0x1"); !shared.Muted || shared.MutedUntil != nil {
+		t.Fatalf("Expected the shared address to be muted until unmuted, got %+v", shared)
+	}
+	if subscription, _ := tenantA.GetSubscription("0x1"); !subscription.Muted || subscription.MutedUntil == nil {
+		t.Errorf("Expected the tenant to keep its own mute, got %+v", subscription)
+	}
+
+	tenantB.Unsubscribe("0x1")
+	if shared, _ := ethParser.GetSubscription("0x1"); !shared.Muted || shared.MutedUntil == nil {
+		t.Errorf("Expected the mute of the remaining tenant, got %+v", shared)
+	}
+}
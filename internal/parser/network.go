@@ -0,0 +1,176 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Network is a preset of the settings of an Ethereum network
+type Network struct {
+	Name    string
+	RPCURL  string
+	ChainID int64
+	// BlockTime is the slot time of the network, the default fetch interval of the parsers
+	BlockTime time.Duration
+	// ExplorerURL is the block explorer base URL used for the links in the API responses and the notifications
+	ExplorerURL string
+	// Explorer overrides the link templates derived from ExplorerURL, for the explorers with other paths
+	Explorer ExplorerTemplates
+}
+
+// ExplorerTemplates are the link templates of a block explorer, with the {hash}, {address} and {block}
+// placeholders. An empty template disables the links of its kind.
+type ExplorerTemplates struct {
+	Transaction string
+	Address     string
+	Block       string
+}
+
+// ExplorerLinks are the block explorer links of a transaction, of its counterparties and of its block
+type ExplorerLinks struct {
+	Transaction string `json:"transaction"`
+	From        string `json:"from,omitempty"`
+	To          string `json:"to,omitempty"`
+	Block       string `json:"block,omitempty"`
+}
+
+// Networks are the built-in network presets
+var Networks = map[string]Network{
+	"mainnet": {
+		Name:        "mainnet",
+		RPCURL:      EthereumNodeURL,
+		ChainID:     1,
+		BlockTime:   12 * time.Second,
+		ExplorerURL: "https://etherscan.io",
+	},
+	"sepolia": {
+		Name:        "sepolia",
+		RPCURL:      "https://ethereum-sepolia-rpc.publicnode.com",
+		ChainID:     11155111,
+		BlockTime:   12 * time.Second,
+		ExplorerURL: "https://sepolia.etherscan.io",
+	},
+	"holesky": {
+		Name:        "holesky",
+		RPCURL:      "https://ethereum-holesky-rpc.publicnode.com",
+		ChainID:     17000,
+		BlockTime:   12 * time.Second,
+		ExplorerURL: "https://holesky.etherscan.io",
+	},
+	"polygon": {
+		Name:        "polygon",
+		RPCURL:      "https://polygon-bor-rpc.publicnode.com",
+		ChainID:     137,
+		BlockTime:   2 * time.Second,
+		ExplorerURL: "https://polygonscan.com",
+	},
+	"gnosis": {
+		Name:        "gnosis",
+		RPCURL:      "https://gnosis-rpc.publicnode.com",
+		ChainID:     100,
+		BlockTime:   5 * time.Second,
+		ExplorerURL: "https://gnosis.blockscout.com",
+	},
+	"arbitrum": {
+		Name:        "arbitrum",
+		RPCURL:      "https://arb1.arbitrum.io/rpc",
+		ChainID:     42161,
+		BlockTime:   250 * time.Millisecond,
+		ExplorerURL: "https://arbiscan.io",
+	},
+	"optimism": {
+		Name:        "optimism",
+		RPCURL:      "https://mainnet.optimism.io",
+		ChainID:     10,
+		BlockTime:   2 * time.Second,
+		ExplorerURL: "https://optimistic.etherscan.io",
+	},
+	"base": {
+		Name:        "base",
+		RPCURL:      "https://mainnet.base.org",
+		ChainID:     8453,
+		BlockTime:   2 * time.Second,
+		ExplorerURL: "https://basescan.org",
+	},
+}
+
+// LookupNetwork returns the preset of a network by name
+func LookupNetwork(name string) (Network, error) {
+	network, ok := Networks[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(Networks))
+		for known := range Networks {
+			names = append(names, known)
+		}
+		sort.Strings(names)
+		return Network{}, fmt.Errorf("unknown network %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+	return network, nil
+}
+
+// explorerTemplates returns the link templates of the network. Etherscan, its forks such as Polygonscan and
+// Blockscout share the /tx, /address and /block paths under their ExplorerURL.
+func (n Network) explorerTemplates() ExplorerTemplates {
+	if n.Explorer != (ExplorerTemplates{}) {
+		return n.Explorer
+	}
+	if n.ExplorerURL == "" {
+		return ExplorerTemplates{}
+	}
+	base := strings.TrimSuffix(n.ExplorerURL, "/")
+	return ExplorerTemplates{
+		Transaction: base + "/tx/{hash}",
+		Address:     base + "/address/{address}",
+		Block:       base + "/block/{block}",
+	}
+}
+
+// TransactionURL returns the explorer link of a transaction, empty when the network has no explorer
+func (n Network) TransactionURL(hash string) string {
+	return expandExplorerTemplate(n.explorerTemplates().Transaction, "{hash}", hash)
+}
+
+// AddressURL returns the explorer link of an address, empty when the network has no explorer
+func (n Network) AddressURL(address string) string {
+	return expandExplorerTemplate(n.explorerTemplates().Address, "{address}", address)
+}
+
+// BlockURL returns the explorer link of a block, empty when the network has no explorer
+func (n Network) BlockURL(number int) string {
+	return expandExplorerTemplate(n.explorerTemplates().Block, "{block}", strconv.Itoa(number))
+}
+
+// TransactionLinks returns the explorer links of a transaction, nil when the network has no explorer. A
+// pending transaction has no block link.
+func (n Network) TransactionLinks(tx Transaction) *ExplorerLinks {
+	links := ExplorerLinks{Transaction: n.TransactionURL(tx.Hash)}
+	if links.Transaction == "" {
+		return nil
+	}
+	if tx.From != "" {
+		links.From = n.AddressURL(tx.From)
+	}
+	if tx.To != "" {
+		links.To = n.AddressURL(tx.To)
+	}
+	// The transactions fetched from the node by hash only have the hex number
+	number := tx.BlockNumberDecimal
+	if parsed, err := strconv.ParseInt(trimHexPrefix(tx.BlockNumber), 16, 64); number == 0 && err == nil {
+		number = int(parsed)
+	}
+	if number > 0 {
+		links.Block = n.BlockURL(number)
+	}
+	return &links
+}
+
+// expandExplorerTemplate replaces the placeholder of a link template, empty when there's no template
+func expandExplorerTemplate(template string, placeholder string, value string) string {
+	if template == "" || value == "" {
+		return ""
+	}
+	return strings.ReplaceAll(template, placeholder, value)
+}
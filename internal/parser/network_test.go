@@ -0,0 +1,87 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestLookupNetwork(t *testing.T) {
+	network, err := parser.LookupNetwork("Sepolia")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if network.ChainID != 11155111 {
+		t.Fatalf("Unexpected Sepolia chain ID %d", network.ChainID)
+	}
+	if url := network.TransactionURL("0xabc"); url != "https://sepolia.etherscan.io/tx/0xabc" {
+		t.Fatalf("Unexpected transaction link %s", url)
+	}
+	if url := (parser.Network{}).TransactionURL("0xabc"); url != "" {
+		t.Fatalf("A network without explorer must not link transactions, got %s", url)
+	}
+
+	if _, err := parser.LookupNetwork("goerli"); err == nil {
+		t.Fatal("Expected an error for an unknown network")
+	}
+}
+
+func TestNetworkTransactionLinks(t *testing.T) {
+	polygon, _ := parser.LookupNetwork("polygon")
+	links := polygon.TransactionLinks(parser.Transaction{Hash: "0xabc", From: "0x1", BlockNumber: "0x10"})
+	if links == nil || links.Transaction != "https://polygonscan.com/tx/0xabc" || links.From != "https://polygonscan.com/address/0x1" ||
+		links.To != "" || links.Block != "https://polygonscan.com/block/16" {
+		t.Errorf("Unexpected Polygonscan links %+v", links)
+	}
+
+	custom := parser.Network{Explorer: parser.ExplorerTemplates{Transaction: "https://explorer.example/transactions/{hash}"}}
+	links = custom.TransactionLinks(parser.Transaction{Hash: "0xabc", From: "0x1", BlockNumber: "0x10"})
+	if links == nil || links.Transaction != "https://explorer.example/transactions/0xabc" || links.From != "" || links.Block != "" {
+		t.Errorf("Unexpected templated links %+v", links)
+	}
+	if links := (parser.Network{}).TransactionLinks(parser.Transaction{Hash: "0xabc"}); links != nil {
+		t.Errorf("A network without explorer must not link transactions, got %+v", links)
+	}
+}
+
+func TestEthParserExplorerLinks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{{Hash: "0xa1", From: "0x1", To: "0x2", Value: "0x1"}}})
+	gnosis, _ := parser.LookupNetwork("gnosis")
+	var notified []parser.Transaction
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain), func(address string, transactions []parser.Transaction) {
+		notified = append(notified, transactions...)
+	}, parser.WithClock(parser.NewManualClock(time.Now())), parser.WithNetwork(gnosis))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	if len(notified) != 1 || notified[0].Links == nil || notified[0].Links.Block != "https://gnosis.blockscout.com/block/1" {
+		t.Fatalf("Expected the notified transaction with its Blockscout links, got %+v", notified)
+	}
+	if transactions := ethParser.GetTransactions("0x1"); len(transactions) != 1 || transactions[0].Links == nil ||
+		transactions[0].Links.Transaction != "https://gnosis.blockscout.com/tx/0xa1" {
+		t.Errorf("Expected the stored transaction with its links, got %+v", transactions)
+	}
+}
+
+func TestEthParserFetchInterval(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	arbitrum, _ := parser.LookupNetwork("arbitrum")
+	clock := parser.NewManualClock(time.Now())
+	blockchain := mockChain(1)
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 0, NewMockClient(blockchain), func(string, []parser.Transaction) {},
+		parser.WithClock(clock), parser.WithNetwork(arbitrum))
+	defer ethParser.WaitForShutdown()
+
+	// The sub-second block time of the network is the fetch interval
+	blockchain.AddBlock(2, parser.Block{Number: "0x2"})
+	clock.Advance(arbitrum.BlockTime)
+	waitUntil(t, func() bool { return ethParser.GetCurrentBlock() == 2 })
+}
@@ -0,0 +1,59 @@
+package parser
+
+import (
+	"log"
+	"sort"
+)
+
+// NonceHistory is the view of the outgoing transactions of an address ordered by nonce.
+// MissingNonces lists the nonces never seen between the lowest and the highest one, DuplicateNonces the
+// nonces used by more than one transaction (e.g. a replaced transaction that was mined anyway after a reorg).
+type NonceHistory struct {
+	Address         string        `json:"address"`
+	Transactions    []Transaction `json:"transactions"`
+	MissingNonces   []int         `json:"missingNonces"`
+	DuplicateNonces []int         `json:"duplicateNonces"`
+}
+
+// GetNonceHistory returns the outgoing transactions of an address ordered by nonce, with gap detection
+func (p *EthParser) GetNonceHistory(address string) NonceHistory {
+	history := NonceHistory{
+		Address:         address,
+		Transactions:    []Transaction{},
+		MissingNonces:   []int{},
+		DuplicateNonces: []int{},
+	}
+
+	nonces := make(map[string]int)
+	for _, tx := range p.storage.GetTransactions(address) {
+		if tx.From != address || tx.Nonce == "" {
+			continue
+		}
+		nonce, err := convertHexNumberToDecimal(tx.Nonce)
+		if err != nil {
+			log.Printf("Skipping transaction %s with invalid nonce %s\n", tx.Hash, tx.Nonce)
+			continue
+		}
+		nonces[tx.Hash] = nonce
+		history.Transactions = append(history.Transactions, tx)
+	}
+
+	sort.SliceStable(history.Transactions, func(i, j int) bool {
+		return nonces[history.Transactions[i].Hash] < nonces[history.Transactions[j].Hash]
+	})
+
+	for i := 1; i < len(history.Transactions); i++ {
+		previous, current := nonces[history.Transactions[i-1].Hash], nonces[history.Transactions[i].Hash]
+		if current == previous {
+			if len(history.DuplicateNonces) == 0 || history.DuplicateNonces[len(history.DuplicateNonces)-1] != current {
+				history.DuplicateNonces = append(history.DuplicateNonces, current)
+			}
+			continue
+		}
+		for missing := previous + 1; missing < current; missing++ {
+			history.MissingNonces = append(history.MissingNonces, missing)
+		}
+	}
+
+	return history
+}
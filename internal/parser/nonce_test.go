@@ -0,0 +1,38 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestEthParserNonceHistory(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{
+		Number: "0x1",
+		Transactions: []parser.Transaction{
+			{Hash: "0xa3", From: "0x1", To: "0x2", Value: "1", Nonce: "0x3"},
+			{Hash: "0xa0", From: "0x1", To: "0x2", Value: "1", Nonce: "0x0"},
+			{Hash: "0xb0", From: "0x2", To: "0x1", Value: "1", Nonce: "0x0"},
+		},
+	})
+
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain),
+		func(string, []parser.Transaction) {}, parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	history := ethParser.GetNonceHistory("0x1")
+	if len(history.Transactions) != 2 || history.Transactions[0].Hash != "0xa0" || history.Transactions[1].Hash != "0xa3" {
+		t.Fatalf("Unexpected outgoing transactions: %v", history.Transactions)
+	}
+	if len(history.MissingNonces) != 2 || history.MissingNonces[0] != 1 || history.MissingNonces[1] != 2 {
+		t.Fatalf("Unexpected missing nonces: %v", history.MissingNonces)
+	}
+}
@@ -1,15 +1,102 @@
 package parser
 
-import "log"
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// ErrNotSubscribed is returned for an operation on an address that isn't subscribed
+var ErrNotSubscribed = errors.New("address not subscribed")
 
 // NotificationFunc defines a function to send notifications
 type NotificationFunc func(address string, transactions []Transaction)
 
+// SendTestNotification delivers a synthetic incoming transaction, marked Test, through the notifications of a
+// subscribed address, so that their configuration can be checked before real transactions. The transaction
+// is neither stored nor retried, the error of the delivery is returned.
+func (p *EthParser) SendTestNotification(address string) (Transaction, error) {
+	subscription, subscribed := p.GetSubscription(address)
+	if !subscribed {
+		return Transaction{}, ErrNotSubscribed
+	}
+	hash := make([]byte, 32)
+	if _, err := rand.Read(hash); err != nil {
+		return Transaction{}, err
+	}
+	block := p.GetCurrentBlock()
+	tx := Transaction{
+		Hash:               "0x" + hex.EncodeToString(hash),
+		From:               "0x0000000000000000000000000000000000000000",
+		To:                 address,
+		Value:              "0x0",
+		BlockNumber:        fmt.Sprintf("0x%x", block),
+		BlockNumberDecimal: block,
+		Test:               true,
+		Metadata:           subscription.Metadata,
+	}
+	return tx, p.deliverFor(address)(address, []Transaction{tx})
+}
+
+// isTestNotification reports whether the transactions are a notification of SendTestNotification
+func isTestNotification(transactions []Transaction) bool {
+	return len(transactions) == 1 && transactions[0].Test
+}
+
+// MultiNotify returns a NotificationFunc sending the notifications to all the given functions
+func MultiNotify(notifiers ...NotificationFunc) NotificationFunc {
+	return func(address string, transactions []Transaction) {
+		for _, notify := range notifiers {
+			notify(address, transactions)
+		}
+	}
+}
+
 // NotifyOnConsole simulates sending a notification about new transactions
 func NotifyOnConsole(address string, transactions []Transaction) {
+	notifyOnConsole(Network{}, address, transactions)
+}
+
+// NotifyOnConsoleFor returns a NotificationFunc like NotifyOnConsole adding the explorer links of the network
+func NotifyOnConsoleFor(network Network) NotificationFunc {
+	return func(address string, transactions []Transaction) {
+		notifyOnConsole(network, address, transactions)
+	}
+}
+
+// notifyOnConsole logs the transactions, with the counterparty labels and the explorer link when available
+func notifyOnConsole(network Network, address string, transactions []Transaction) {
 	// Simulate sending a notification (e.g., print to console)
 	for _, tx := range transactions {
-		log.Printf("Notification - Address: %s, Transaction: %s, From: %s, To: %s, Value: %s, Block: %s\n",
-			address, tx.Hash, tx.From, tx.To, tx.Value, tx.BlockNumber)
+		extra := ""
+		if tx.FromLabel != "" || tx.ToLabel != "" {
+			extra = fmt.Sprintf(", FromLabel: %s, ToLabel: %s", tx.FromLabel, tx.ToLabel)
+		}
+		if tx.Token != nil && tx.Token.Symbol != "" {
+			extra += ", Token: " + tx.Token.Symbol
+		}
+		if tx.Test {
+			extra += ", Test: true"
+		}
+		if url := network.TransactionURL(tx.Hash); url != "" {
+			extra += ", Link: " + url
+		}
+		if tx.BlobTransaction {
+			log.Printf("Notification - Address: %s, Blob Transaction: %s, From: %s, To: %s, Value: %s, Block: %s, Blobs: %d, MaxFeePerBlobGas: %s%s\n",
+				address, tx.Hash, tx.From, tx.To, tx.Value, tx.BlockNumber, len(tx.BlobVersionedHashes), tx.MaxFeePerBlobGas, extra)
+			continue
+		}
+		log.Printf("Notification - Address: %s, Transaction: %s, From: %s, To: %s, Value: %s, Block: %s%s\n",
+			address, tx.Hash, tx.From, tx.To, tx.Value, tx.BlockNumber, extra)
+	}
+}
+
+// NotifyEntityOnConsole logs the transactions matched for an entity
+func NotifyEntityOnConsole(entityID string, transactions []EntityTransaction) {
+	for _, tx := range transactions {
+		log.Printf("Entity Notification - Entity: %s, Transaction: %s, From: %s, To: %s, Value: %s, Block: %s, Internal: %t\n",
+			entityID, tx.Hash, tx.From, tx.To, tx.Value, tx.BlockNumber, tx.Internal)
 	}
 }
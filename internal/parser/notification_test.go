@@ -0,0 +1,49 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestSendTestNotification(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	deliveryErr := errors.New("webhook unreachable")
+	var delivered []parser.Transaction
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(NewMockBlockchain()), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())),
+		parser.WithDelivery(func(address string, transactions []parser.Transaction) error {
+			delivered = append(delivered, transactions...)
+			return deliveryErr
+		}, parser.DeliveryPolicy{}))
+	defer ethParser.WaitForShutdown()
+
+	if _, err := ethParser.SendTestNotification("0x1"); !errors.Is(err, parser.ErrNotSubscribed) {
+		t.Fatalf("Expected ErrNotSubscribed, got %v", err)
+	}
+	ethParser.Subscribe("0x1")
+	tx, err := ethParser.SendTestNotification("0x1")
+	if !errors.Is(err, deliveryErr) {
+		t.Errorf("Expected the delivery error, got %v", err)
+	}
+	if len(delivered) != 1 || delivered[0].Hash != tx.Hash || !tx.Test || tx.To != "0x1" || len(tx.Hash) != 66 {
+		t.Errorf("Expected the test transaction to be delivered, got %+v", delivered)
+	}
+	// The test transaction is not stored
+	if stored := ethParser.GetTransactions("0x1"); len(stored) != 0 {
+		t.Errorf("Expected no stored transaction, got %+v", stored)
+	}
+
+	// Addresses with a callback are notified through it
+	var notified []parser.Transaction
+	ethParser.SubscribeWithCallback("0x2", func(address string, transactions []parser.Transaction) {
+		notified = append(notified, transactions...)
+	})
+	if _, err := ethParser.SendTestNotification("0x2"); err != nil || len(notified) != 1 || !notified[0].Test {
+		t.Errorf("Expected the callback to receive the test transaction, got %+v (%v)", notified, err)
+	}
+}
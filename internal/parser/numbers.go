@@ -0,0 +1,58 @@
+package parser
+
+import "math/big"
+
+// Encodings of the quantities (values, fees, nonces, block numbers) of the transactions in the API responses
+const (
+	// NumberEncodingDecimal encodes the quantities as decimal strings, the default
+	NumberEncodingDecimal = "decimal"
+	// NumberEncodingHex keeps the quantities hex encoded, as returned by the node
+	NumberEncodingHex = "hex"
+)
+
+// WithNumberEncoding returns the transaction with its quantities in the given encoding. Quantities are kept
+// as strings whatever their size, so 256-bit values never go through float64; the transactions in storage
+// stay hex encoded. A quantity that isn't valid hex is returned unchanged.
+func (tx Transaction) WithNumberEncoding(encoding string) Transaction {
+	if encoding == NumberEncodingHex {
+		return tx
+	}
+	for _, quantity := range []*string{&tx.Value, &tx.BlockNumber, &tx.Nonce, &tx.TransactionIndex, &tx.GasPrice,
+		&tx.MaxFeePerGas, &tx.MaxPriorityFeePerGas, &tx.MaxFeePerBlobGas} {
+		*quantity = hexToDecimal(*quantity)
+	}
+	return tx
+}
+
+// TransactionsWithNumberEncoding returns a copy of the transactions with their quantities in the given encoding
+func TransactionsWithNumberEncoding(transactions []Transaction, encoding string) []Transaction {
+	if transactions == nil {
+		return nil
+	}
+	encoded := make([]Transaction, len(transactions))
+	for i, tx := range transactions {
+		encoded[i] = tx.WithNumberEncoding(encoding)
+	}
+	return encoded
+}
+
+// parseQuantity parses a quantity of a transaction, 0x prefixed hex as returned by the node or decimal as
+// accepted by the API. Every computation on the values and fees goes through it, so that they agree.
+func parseQuantity(quantity string) (*big.Int, bool) {
+	if len(quantity) >= 2 && quantity[0] == '0' && (quantity[1] == 'x' || quantity[1] == 'X') {
+		return new(big.Int).SetString(quantity[2:], 16)
+	}
+	return new(big.Int).SetString(quantity, 10)
+}
+
+// hexToDecimal converts a 0x prefixed hex quantity of any size to a decimal string
+func hexToDecimal(hex string) string {
+	if len(hex) < 3 || hex[0] != '0' || (hex[1] != 'x' && hex[1] != 'X') {
+		return hex
+	}
+	value, ok := new(big.Int).SetString(hex[2:], 16)
+	if !ok {
+		return hex
+	}
+	return value.String()
+}
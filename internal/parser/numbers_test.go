@@ -0,0 +1,94 @@
+package parser_test
+
+import (
+	"context"
+	"encoding/json"
+	"eth-parser/internal/parser"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransactionNumberEncoding(t *testing.T) {
+	maxUint256 := "0x" + strings.Repeat("f", 64)
+	tx := parser.Transaction{
+		Hash:         "0xa1",
+		Value:        maxUint256,
+		BlockNumber:  "0x12a05f200",
+		Nonce:        "0x0",
+		GasPrice:     "0x3b9aca00",
+		MaxFeePerGas: "not-hex",
+		Type:         "0x2",
+	}
+
+	encoded := tx.WithNumberEncoding(parser.NumberEncodingDecimal)
+	expected := "115792089237316195423570985008687907853269984665640564039457584007913129639935"
+	if encoded.Value != expected {
+		t.Errorf("Expected 2^256-1 to keep its precision, got %s", encoded.Value)
+	}
+	if encoded.BlockNumber != "5000000000" || encoded.Nonce != "0" || encoded.GasPrice != "1000000000" {
+		t.Errorf("Unexpected quantities %+v", encoded)
+	}
+	if encoded.MaxFeePerGas != "not-hex" || encoded.MaxPriorityFeePerGas != "" || encoded.Type != "0x2" {
+		t.Errorf("Expected the invalid, empty and non quantity fields to be unchanged, got %+v", encoded)
+	}
+	if tx.Value != maxUint256 {
+		t.Error("The original transaction must not be modified")
+	}
+
+	if hex := tx.WithNumberEncoding(parser.NumberEncodingHex); hex.Value != maxUint256 || hex.BlockNumber != tx.BlockNumber {
+		t.Errorf("Expected the hex encoding to keep the node values, got %+v", hex)
+	}
+	if encoded := parser.TransactionsWithNumberEncoding([]parser.Transaction{tx}, parser.NumberEncodingDecimal); encoded[0].Value != expected {
+		t.Errorf("Unexpected encoded transactions %+v", encoded)
+	}
+}
+
+func TestDefaultClientKeepsLargeNumbers(t *testing.T) {
+	// 2^256-1 as a JSON number would be rounded by float64
+	big := "115792089237316195423570985008687907853269984665640564039457584007913129639935"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"value":` + big + `}}`))
+	}))
+	defer server.Close()
+
+	resp, err := parser.NewJsonRpcClientWithURL(server.URL).SendRequest(parser.JSONRPCRequest{JSONRPC: "2.0", Method: "test", ID: 1})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	raw, err := json.Marshal(resp.Result)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(raw) != `{"value":`+big+`}` {
+		t.Errorf("Expected the number to round-trip exactly, got %s", raw)
+	}
+}
+
+func TestNotificationNumberEncoding(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{
+		Number:       "0x1",
+		Transactions: []parser.Transaction{{Hash: "0xa1", From: "0x9", To: "0x1", Value: "0x64", BlockNumber: "0x1"}},
+	})
+	var notified []parser.Transaction
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain),
+		func(_ string, transactions []parser.Transaction) { notified = append(notified, transactions...) },
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithNotificationNumberEncoding(parser.NumberEncodingDecimal))
+	defer ethParser.WaitForShutdown()
+
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+	if len(notified) != 1 || notified[0].Value != "100" || notified[0].BlockNumber != "1" {
+		t.Fatalf("Expected the decimal quantities in the notification, got %+v", notified)
+	}
+	// The stored transactions keep the node encoding
+	if stored := ethParser.GetTransactions("0x1"); len(stored) != 1 || stored[0].Value != "0x64" {
+		t.Errorf("Expected the stored value to stay hex, got %+v", stored)
+	}
+}
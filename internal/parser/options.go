@@ -0,0 +1,493 @@
+package parser
+
+import "time"
+
+// Option configures optional behaviour of an EthParser at construction time
+type Option func(*EthParser)
+
+// WithEntityNotification sets the function called with the matched transactions of an entity
+func WithEntityNotification(notify EntityNotificationFunc) Option {
+	return func(p *EthParser) {
+		p.notifyEntity = notify
+	}
+}
+
+// WithHeadSubscriber tracks the current block with the heads pushed by sub, cross-checking them
+// against polling every crossCheckPeriod and falling back to polling when the push source is unhealthy
+func WithHeadSubscriber(sub HeadSubscriber, crossCheckPeriod time.Duration) Option {
+	return func(p *EthParser) {
+		p.heads = sub
+		p.headCrossCheckPeriod = crossCheckPeriod
+	}
+}
+
+// WithClock sets the time source of the background loops, see ManualClock
+func WithClock(clock Clock) Option {
+	return func(p *EthParser) {
+		p.clock = clock
+	}
+}
+
+// WithEventNotification sets the function called with the parser events, e.g. replaced transactions
+func WithEventNotification(notify EventNotificationFunc) Option {
+	return func(p *EthParser) {
+		p.notifyEvent = notify
+	}
+}
+
+// WithPendingTracking enables the tracking of the pending outgoing transactions of the subscribed addresses,
+// used to detect replaced (speed-up or canceled) transactions
+func WithPendingTracking() Option {
+	return func(p *EthParser) {
+		p.trackPending = true
+	}
+}
+
+// WithChainID sets the chain ID the node must be on. It's verified at startup and on every fetch cycle,
+// and blocks are not processed while the node reports a different chain.
+func WithChainID(chainID int64) Option {
+	return func(p *EthParser) {
+		p.expectedChainID = chainID
+	}
+}
+
+// WithBlockProcessor registers a BlockProcessor at construction time, see EthParser.RegisterBlockProcessor
+func WithBlockProcessor(name string, processor BlockProcessor) Option {
+	return func(p *EthParser) {
+		p.processors = append(p.processors, &registeredProcessor{
+			processor: processor,
+			stats:     BlockProcessorStats{Name: name},
+		})
+	}
+}
+
+// WithDelivery delivers the outbox events with deliver instead of the NotificationFunc of the constructor,
+// retrying failed deliveries according to policy while keeping the block order of each address
+func WithDelivery(deliver DeliveryFunc, policy DeliveryPolicy) Option {
+	return func(p *EthParser) {
+		p.deliver = deliver
+		p.deliveryPolicy = policy
+	}
+}
+
+// WithPriceProvider annotates the matched transactions with the ETH/USD price at block time and their USD value
+func WithPriceProvider(provider PriceProvider) Option {
+	return func(p *EthParser) {
+		p.prices = provider
+	}
+}
+
+// WithCapabilityDetection probes the node at startup and disables the configured features it doesn't support,
+// see Capabilities
+func WithCapabilityDetection() Option {
+	return func(p *EthParser) {
+		p.detect = true
+	}
+}
+
+// WithLabels annotates the counterparties of the returned and notified transactions with the labels of db
+func WithLabels(db *LabelDB) Option {
+	return func(p *EthParser) {
+		p.labels = db
+	}
+}
+
+// WithENSNames annotates the counterparties of the returned and notified transactions with their primary ENS
+// name, read with eth_call and cached for ttl, see ENSName. The first resolution of an address costs up to four
+// eth_call requests.
+func WithENSNames(ttl time.Duration) Option {
+	return func(p *EthParser) {
+		p.ensTTL = ttl
+	}
+}
+
+// WithPipeline configures the block processing pipeline: configure receives the default stages (see
+// defaultStages) and returns the stages to run, which may be reordered, replaced or extended
+func WithPipeline(configure func(defaults []PipelineStage) []PipelineStage) Option {
+	return func(p *EthParser) {
+		p.setPipeline(configure(p.defaultStages()))
+	}
+}
+
+// WithAllowanceTracking enables the tracking of the ERC-20 approvals granted by the subscribed addresses,
+// see GetAllowances. Unlimited approvals and approvals to spenders missing from the labels are sent as events.
+func WithAllowanceTracking() Option {
+	return func(p *EthParser) {
+		p.trackAllowances = true
+	}
+}
+
+// WithPrefetch downloads the blocks of a fetch cycle in a background goroutine, up to depth blocks ahead of
+// the one being processed, so that the node latency overlaps with the processing when catching up. A failed
+// download is fetched again by the fetch stage, which a pipeline replacing it doesn't use.
+func WithPrefetch(depth int) Option {
+	return func(p *EthParser) {
+		p.prefetchDepth = depth
+	}
+}
+
+// WithFetchWorkers downloads up to n blocks at once in the prefetcher of WithPrefetch, one by default; the
+// blocks are still processed in order. It can be changed while running, see UpdateConfig.
+func WithFetchWorkers(n int) Option {
+	return func(p *EthParser) {
+		p.fetchWorkers = n
+	}
+}
+
+// WithRPCRateLimit caps the node requests of the parser to requestsPerSecond, spacing them, zero for no limit.
+// It can be changed while running, see UpdateConfig.
+func WithRPCRateLimit(requestsPerSecond float64) Option {
+	return func(p *EthParser) {
+		p.rpcLimiter.setRate(requestsPerSecond)
+	}
+}
+
+// WithNotificationBatchSize sets the number of outbox events read per dispatch round, 100 by default. It can be
+// changed while running, see UpdateConfig.
+func WithNotificationBatchSize(n int) Option {
+	return func(p *EthParser) {
+		p.outboxBatch = n
+	}
+}
+
+// WithDeploymentMonitoring sends an EventContractDeployed event for every contract deployed by a subscribed
+// address. With autoSubscribe the contract is also subscribed with the notification settings of its deployer,
+// and linked to the entity of the deployer, if any.
+func WithDeploymentMonitoring(autoSubscribe bool) Option {
+	return func(p *EthParser) {
+		p.monitorDeployments = true
+		p.subscribeDeployments = autoSubscribe
+	}
+}
+
+// WithNotificationThrottle limits the notifications of an address to maxNotifications per window. Past the
+// limit the address is throttled: an EventNotificationsThrottled event is sent and its transactions are
+// delivered as a single summary notification at the end of every window, until a window stays within the
+// limit. The entity notifications aren't throttled.
+func WithNotificationThrottle(maxNotifications int, window time.Duration) Option {
+	return func(p *EthParser) {
+		p.throttlePolicy = ThrottlePolicy{MaxNotifications: maxNotifications, Window: window}
+	}
+}
+
+// WithRPCProxy sets the JSON-RPC methods forwarded by ProxyRequest, DefaultProxyMethods by default. No
+// method disables the proxy.
+func WithRPCProxy(methods ...string) Option {
+	return func(p *EthParser) {
+		p.proxyMethods = proxyMethodSet(methods)
+	}
+}
+
+// WithJournal appends the matched transactions of every block to the journal, synced to disk, before they're
+// stored and notified. A block whose append fails stops before the store stage, like on a storage error it's
+// retried and dead-lettered by WithBlockDeadLetters, skipped otherwise.
+func WithJournal(journal *Journal) Option {
+	return func(p *EthParser) {
+		p.journal = journal
+	}
+}
+
+// WithNetwork sets the network of the explorer links of the returned and notified transactions, see
+// Network.TransactionLinks. Its block time is the fetch interval when the constructor has no fetch period.
+func WithNetwork(network Network) Option {
+	return func(p *EthParser) {
+		p.network = network
+	}
+}
+
+// WithFetchInterval replaces the fetch period in seconds of the constructor, e.g. with a sub-second interval
+// for the chains with fast blocks. By default the interval is the block time of the network, see WithNetwork.
+func WithFetchInterval(interval time.Duration) Option {
+	return func(p *EthParser) {
+		p.fetchInterval = interval
+	}
+}
+
+// WithHealthThresholds sets when the components of the parser are reported degraded or down, see Health
+func WithHealthThresholds(thresholds HealthThresholds) Option {
+	return func(p *EthParser) {
+		p.health.thresholds = thresholds
+	}
+}
+
+// WithStaleHeadDetection sends the requests of the parser to the first of its client and the providers of the
+// policy whose head advances: when the head of the active provider hasn't advanced for policy.StallAfter, the
+// next provider ahead of it becomes the active one and an EventProviderDegraded event is sent.
+func WithStaleHeadDetection(policy StaleHeadPolicy) Option {
+	return func(p *EthParser) {
+		if policy.StallAfter <= 0 {
+			policy.StallAfter = defaultStallAfter
+		}
+		if policy.MinLead <= 0 {
+			policy.MinLead = 1
+		}
+		providers := &providerSwitch{providers: append([]RPCProvider{{Name: "primary", Client: p.client}}, policy.Providers...)}
+		p.staleHead = &staleHeadState{policy: policy, providers: providers}
+		p.client = providers
+	}
+}
+
+// WithBackpressure bounds the blocks processed by a fetch cycle and sets the lag from which the head polling
+// is paused until the fetch loop catches up. Zero disables the bound, respectively the pause.
+func WithBackpressure(maxBlocksPerCycle int, maxBlockLag int) Option {
+	return func(p *EthParser) {
+		p.maxBlocksPerCycle = maxBlocksPerCycle
+		p.maxBlockLag = maxBlockLag
+	}
+}
+
+// WithCycleDeadline bounds the duration of a fetch cycle, one minute by default: a cycle over a long range
+// stops after the block processed when it's exceeded and leaves the rest to the next cycles, like the
+// WithBackpressure bound. Zero disables the deadline. A shutdown stops the cycle after its current block anyway.
+func WithCycleDeadline(deadline time.Duration) Option {
+	return func(p *EthParser) {
+		p.cycleDeadline = deadline
+	}
+}
+
+// WithShutdownDrain bounds the drain of the pending notifications on shutdown, ten seconds by default, see
+// Drain. Zero skips the drain.
+func WithShutdownDrain(deadline time.Duration) Option {
+	return func(p *EthParser) {
+		p.shutdownDrain = deadline
+	}
+}
+
+// WithFlusher flushes a notifier buffering notifications in the shutdown drain, see Drain. The notifiers are
+// flushed in the order of the options.
+func WithFlusher(name string, flusher Flusher) Option {
+	return func(p *EthParser) {
+		p.flushers = append(p.flushers, namedFlusher{name: name, flusher: flusher})
+	}
+}
+
+// WithCheckpointInterval saves the checkpoint once every n blocks processed by a fetch cycle instead of after each
+// block, to spare the storage some writes; a crash reprocesses the blocks since the last one, at most n-1.
+// The checkpoint is always saved at the end of a cycle.
+func WithCheckpointInterval(n int) Option {
+	return func(p *EthParser) {
+		if n > 0 {
+			p.checkpointInterval = n
+		}
+	}
+}
+
+// WithClassifier tags the matched transactions with the category returned by the classifier before they're
+// stored, see NewHeuristicClassifier
+func WithClassifier(classifier TransactionClassifier) Option {
+	return func(p *EthParser) {
+		p.classifier = classifier
+	}
+}
+
+// WithFallbackClient sets the client blocks are fetched again from when the primary one returns a block
+// that can't be fully decoded, e.g. a provider with a different encoding of new transaction types
+func WithFallbackClient(client JsonRpcClient) Option {
+	return func(p *EthParser) {
+		p.fallbackClient = client
+	}
+}
+
+// WithHistorySource reads the backfills of Backfill from source instead of scanning the blocks, e.g. an
+// EtherscanClient
+func WithHistorySource(source HistorySource) Option {
+	return func(p *EthParser) {
+		p.historySource = source
+	}
+}
+
+// WithCheckpoint saves the last processed block after every cycle and resumes from it at startup, e.g. with
+// the SQLStorage. A restart after a long downtime catches up as a recovery phase, see Recovery.
+func WithCheckpoint(store CheckpointStore) Option {
+	return func(p *EthParser) {
+		p.checkpoints = store
+	}
+}
+
+// WithSubscriptionStore saves the subscriptions made with Subscribe and SubscribeWith to store and restores
+// them on Start, e.g. with the SQLStorage. The subscriptions with a callback aren't saved.
+func WithSubscriptionStore(store SubscriptionStore) Option {
+	return func(p *EthParser) {
+		p.subscriptionStore = store
+	}
+}
+
+// WithWarmUp warms up a notifier on Start, see Warmer. The outbox waits until all the notifiers are warm, the
+// failing warm ups being retried by the fetch cycles; a notifier still failing after timeout is given up and
+// the notifications are delivered anyway. Zero waits for it forever.
+func WithWarmUp(name string, warmer Warmer, timeout time.Duration) Option {
+	return func(p *EthParser) {
+		p.warmUps = append(p.warmUps, &notifierWarmUp{WarmUpStatus: WarmUpStatus{Name: name}, warmer: warmer, timeout: timeout})
+	}
+}
+
+// WithExportDestination enables the export jobs of StartExport, writing their files to destination. The
+// download URLs of the finished jobs are valid for urlExpiry.
+func WithExportDestination(destination ExportDestination, urlExpiry time.Duration) Option {
+	return func(p *EthParser) {
+		p.exportDestination = destination
+		p.exportURLExpiry = urlExpiry
+	}
+}
+
+// WithRecoveryNotifications sets how the transactions caught up by the startup recovery are notified:
+// RecoveryNotifyDeliver (the default), RecoveryNotifySuppress or RecoveryNotifyHistorical
+func WithRecoveryNotifications(mode string) Option {
+	return func(p *EthParser) {
+		p.recoveryNotify = mode
+	}
+}
+
+// WithScreener screens the counterparties of the matched transactions with screener, e.g. a DenylistScreener
+// or an APIScreener, see StageScreening. The transactions with a flagged counterparty are tagged with a
+// ScreeningFlag and alerted with an EventFlaggedCounterparty event. The screeners are queried in the order of
+// the options, until one flags the counterparty.
+func WithScreener(name string, screener Screener) Option {
+	return func(p *EthParser) {
+		p.screeners = append(p.screeners, namedScreener{name: name, screener: screener})
+	}
+}
+
+// WithSelfTransfers sets how the transactions sent by a subscribed address to itself are handled:
+// TransactionsDeliver (the default), TransactionsSuppress or TransactionsSkip. A self-transfer is stored and
+// notified once for the address in any case.
+func WithSelfTransfers(mode string) Option {
+	return func(p *EthParser) {
+		p.selfTransfers = mode
+	}
+}
+
+// WithZeroValueTransactions sets how the transactions transferring no ether are handled: TransactionsDeliver
+// (the default), TransactionsSuppress or TransactionsSkip. Most contract calls, e.g. the token transfers, are
+// zero-value transactions.
+func WithZeroValueTransactions(mode string) Option {
+	return func(p *EthParser) {
+		p.zeroValue = mode
+	}
+}
+
+// WithBlockDeadLetters retries a block failing the pipeline from the failed stage, up to attempts times in
+// total, then saves it to store with its raw payload instead of skipping it, see ReplayBlockDeadLetter
+func WithBlockDeadLetters(store BlockDeadLetterStore, attempts int) Option {
+	return func(p *EthParser) {
+		p.blockDeadLetters = store
+		p.blockAttempts = attempts
+	}
+}
+
+// WithConsumers enables the pull consumers of RegisterConsumer, persisted with their unacknowledged events in
+// store. A consumer keeps at most retention unacknowledged events (zero for unlimited), its oldest events are
+// dropped beyond.
+func WithConsumers(store ConsumerStore, retention int) Option {
+	return func(p *EthParser) {
+		p.consumers = store
+		p.consumerKeep = retention
+	}
+}
+
+// WithHeaderVerification recomputes the hash of every fetched block from its header fields and fails the
+// fetch stage on a mismatch, detecting corrupted or tampered responses of an untrusted node, see VerifyBlockHeader
+func WithHeaderVerification() Option {
+	return func(p *EthParser) {
+		p.verifyHeaders = true
+	}
+}
+
+// WithContractABI registers a contract ABI under name for CallContract, the ERC-20 ABI is registered as
+// ERC20ABIName by default
+func WithContractABI(name string, abi *ContractABI) Option {
+	return func(p *EthParser) {
+		p.abis[name] = abi
+	}
+}
+
+// WithTokenMetadata annotates the matched token transfers with the name, symbol and decimals of the token,
+// read with eth_call and cached, see TokenMetadata. It requires a classifier to detect the token transfers.
+func WithTokenMetadata() Option {
+	return func(p *EthParser) {
+		p.resolveTokens = true
+	}
+}
+
+// WithWatchdog restarts a background loop whose iteration runs for longer than deadline, e.g. blocked on a
+// node request without timeout, interrupting the in-flight requests of the clients implementing
+// RequestCanceler, and sends an EventLoopStuck event
+func WithWatchdog(deadline time.Duration) Option {
+	return func(p *EthParser) {
+		p.watchdogDeadline = deadline
+	}
+}
+
+// WithReports generates a report of the activity of every address and entity with transactions at the end of
+// each ReportDaily or ReportWeekly period, sent as an EventReportGenerated event and retained for Reports,
+// the last retain ones when retain is positive. The fee spend reads the receipts of the sent transactions.
+func WithReports(schedule string, retain int) Option {
+	return func(p *EthParser) {
+		p.reportSchedule = schedule
+		if retain > 0 {
+			p.reportRetention = retain
+		}
+	}
+}
+
+// WithMatcher sets the matcher of the filter stage, built from the subscribed addresses at the start of every
+// cycle, see ParseMatcher. The default exact matcher is the set of the subscribed addresses.
+func WithMatcher(factory MatcherFactory) Option {
+	return func(p *EthParser) {
+		p.matcher = factory
+	}
+}
+
+// WithAuditLog records the subscription changes made through the API to store, see RecordAudit
+func WithAuditLog(store AuditStore) Option {
+	return func(p *EthParser) {
+		p.audit = store
+	}
+}
+
+// WithUsagePlan projects the monthly usage of the node against the quota and prices of the plan of the
+// provider, see UsageReport, and sends an EventUsageProjectionExceeded event when it's above the quota
+func WithUsagePlan(plan UsagePlan) Option {
+	return func(p *EthParser) {
+		p.usagePlan = &plan
+	}
+}
+
+// WithUsageStore saves the counters of the node requests in store, so that the usage of the month survives
+// the restarts
+func WithUsageStore(store UsageStore) Option {
+	return func(p *EthParser) {
+		p.usageStore = store
+	}
+}
+
+// WithActivityIndex keeps in store a bloom filter of the senders and recipients of every range of rangeBlocks
+// blocks (1000 when zero or less), the last retain ranges when retain is positive. The merge rescans and the
+// backfills skip the ranges without activity of their addresses, see LookupActivity.
+func WithActivityIndex(store ActivityIndexStore, rangeBlocks int, retain int) Option {
+	return func(p *EthParser) {
+		p.activityStore = store
+		if rangeBlocks > 0 {
+			p.activityRangeBlocks = rangeBlocks
+		}
+		p.activityRetain = retain
+	}
+}
+
+// WithNotificationNumberEncoding delivers the notified transactions, of the addresses and of the entities, with
+// their quantities in the given NumberEncoding. They're delivered hex encoded, as returned by the node, by default.
+func WithNotificationNumberEncoding(encoding string) Option {
+	return func(p *EthParser) {
+		p.notificationEncoding = encoding
+	}
+}
+
+// WithPendingTTL sets how long a tracked pending transaction is kept when it's neither mined nor replaced, e.g.
+// evicted from the mempool, one hour by default. See WithPendingTracking.
+func WithPendingTTL(ttl time.Duration) Option {
+	return func(p *EthParser) {
+		if ttl > 0 {
+			p.pendingTTL = ttl
+		}
+	}
+}
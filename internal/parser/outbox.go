@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"fmt"
+	"log"
+)
+
+// outboxBatchSize is the default number of outbox events dispatched per round, see WithNotificationBatchSize
+const outboxBatchSize = 100
+
+// OutboxEvent is a pending notification written in the same storage transaction as the
+// transactions it notifies about, and removed once the notification has been delivered
+type OutboxEvent struct {
+	ID           string        `json:"id"`
+	Address      string        `json:"address"`
+	BlockNumber  int           `json:"blockNumber"`
+	Transactions []Transaction `json:"transactions"`
+}
+
+// outboxEventID returns the deterministic ID of the event of an address in a block, so that
+// re-processing a block overwrites its event instead of duplicating it
+func outboxEventID(blockNumber int, address string) string {
+	return fmt.Sprintf("%d:%s", blockNumber, address)
+}
+
+// dispatchOutbox delivers the pending outbox events in order and acknowledges them after delivery.
+// Delivery is at-least-once: a crash between the notification and the acknowledgment re-sends the event.
+// The events of an address are delivered in block order: once one of them fails, the following events of the
+// same address are held back until it's delivered or dead-lettered, see DeliveryPolicy. The events of a block
+// are delivered by subscription priority, and the low priority ones are held back while catching up. The
+// events of the muted addresses are acknowledged without being delivered.
+func (p *EthParser) dispatchOutbox() {
+	p.dispatchMu.Lock()
+	defer p.dispatchMu.Unlock()
+	if !p.warmUp() {
+		log.Println("Holding the outbox back:", p.PendingWarmUps())
+		return
+	}
+
+	p.mu.Lock()
+	batchSize := p.outboxBatch
+	p.mu.Unlock()
+	seen := make(map[string]bool)
+	blocked := make(map[string]bool)
+	held := 0
+	deferLow := p.deferringLowPriority()
+	for {
+		// Held back events stay at the head of the outbox, read past them to reach the other addresses
+		events, err := p.storage.PendingOutboxEvents(held + batchSize)
+		if err != nil {
+			log.Println("Error reading outbox:", err)
+			return
+		}
+		ranks := p.priorityRanks(events)
+		orderByPriority(events, ranks)
+
+		ids := make([]string, 0, len(events))
+		transactionsForAddresses := make(map[string][]Transaction)
+		blockNumber := -1
+		fresh := 0
+		for _, event := range events {
+			if seen[event.ID] {
+				continue
+			}
+			seen[event.ID] = true
+			fresh++
+
+			// Entity notifications are grouped per block, so that internal transfers are notified once
+			if event.BlockNumber != blockNumber && len(transactionsForAddresses) > 0 {
+				p.notifyEntities(transactionsForAddresses)
+				transactionsForAddresses = make(map[string][]Transaction)
+			}
+			blockNumber = event.BlockNumber
+
+			if blocked[event.Address] {
+				held++
+				continue
+			}
+			if deferLow && ranks[event.Address] == priorityRank(PriorityLow) {
+				blocked[event.Address] = true
+				held++
+				continue
+			}
+			if p.mutedAddress(event.Address) {
+				log.Printf("Dropping the notification of %d transactions for muted address %s in block %d\n", len(event.Transactions), event.Address, event.BlockNumber)
+				ids = append(ids, event.ID)
+				continue
+			}
+			// The consumers get the event once, whether the webhook delivery succeeds or is retried
+			if p.delivery.attempts[event.ID] == 0 {
+				p.retainForConsumers(event)
+			}
+			// A retried event was already counted by the throttling
+			if p.delivery.attempts[event.ID] == 0 && p.throttleEvent(event) {
+				ids = append(ids, event.ID)
+				transactionsForAddresses[event.Address] = event.Transactions
+				continue
+			}
+			log.Printf("Found %d transactions for address %s in block %d\n", len(event.Transactions), event.Address, event.BlockNumber)
+			if !p.deliverEvent(event) {
+				blocked[event.Address] = true
+				held++
+				continue
+			}
+			ids = append(ids, event.ID)
+			transactionsForAddresses[event.Address] = event.Transactions
+		}
+		if len(transactionsForAddresses) > 0 {
+			p.notifyEntities(transactionsForAddresses)
+		}
+		if fresh == 0 {
+			return
+		}
+
+		if err := p.recordStorageWrite(p.storage.AckOutboxEvents(ids)); err != nil {
+			log.Println("Error acknowledging outbox events:", err)
+			return
+		}
+	}
+}
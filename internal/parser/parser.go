@@ -13,26 +13,180 @@ import (
 // initialLookBackBlocksCount specifies the number of blocks to check backwards from the current block when the app starts for the first time
 const initialLookBackBlocksCount = 10
 
+// defaultFetchInterval is the fetch interval of a parser without fetch period nor network, the mainnet block time
+const defaultFetchInterval = 12 * time.Second
+
 // Parser defines the interface for the Ethereum parser
 type Parser interface {
 	GetCurrentBlock() int
 	Subscribe(address string) bool
+	SubscribeWith(subscription Subscription) bool
+	Unsubscribe(address string) (Subscription, bool)
+	UpdateSubscription(subscription Subscription) bool
+	GetSubscription(address string) (Subscription, bool)
+	Subscriptions() []Subscription
 	GetTransactions(address string) []Transaction
+	QueryTransactions(query TxQuery) TxResult
+	LookupTransaction(hash string) (TransactionLookup, bool, error)
+	WatchTransaction(request WatchRequest) (WatchedTransaction, error)
+	WatchedTransaction(hash string) (WatchedTransaction, bool)
+	CallContract(call ContractCall) (ContractCallResult, error)
+	TokenMetadata(address string) (TokenMetadata, error)
+	SendTestNotification(address string) (Transaction, error)
+	Mute(address string, duration time.Duration) (Subscription, bool)
+	Unmute(address string) (Subscription, bool)
+	TransactionsTruncated(address string) bool
+	WaitForTransactions(ctx context.Context, address string, cursor int) ([]Transaction, int, error)
+	TransactionChanges(address string, sinceBlock int) ([]Transaction, int)
+	GetNonceHistory(address string) NonceHistory
+	GetPendingTransactions(address string) []PendingTransaction
+	GetAllowances(owner string) []Allowance
+	GetCounterparties(address string, orderBy string, limit int) []Counterparty
+	AddToEntity(entityID string, address string) bool
+	RemoveFromEntity(entityID string, address string) bool
+	GetEntityAddresses(entityID string) []string
+	GetEntityTransactions(entityID string) []EntityTransaction
+	Reports() []Report
+	GetReport(id int) (Report, bool)
+	RecordAudit(entry AuditEntry) error
+	AuditLog(filter AuditFilter) ([]AuditEntry, error)
+	RegisterConsumer(consumer Consumer) (Consumer, error)
+	Consumers() ([]Consumer, error)
+	UnregisterConsumer(name string) error
+	PullConsumerEvents(name string, max int) ([]OutboxEvent, error)
+	AckConsumerEvents(name string, ids []string) error
 	WaitForShutdown()
 }
 
 // EthParser implements the Parser interface
 type EthParser struct {
-	currentBlock       int
-	lastProcessedBlock int
-	subscriptions      map[string]bool
-	storage            Storage
-	fetchPeriod        int
-	client             JsonRpcClient
-	notify             NotificationFunc
-	mu                 sync.Mutex
-	wg                 sync.WaitGroup
-	cancel             context.CancelFunc
+	currentBlock         int
+	lastProcessedBlock   int
+	subscriptions        map[string]*Subscription
+	callbacks            map[string]NotificationFunc // per-address notifications, see SubscribeWithCallback
+	entities             map[entityKey]map[string]bool
+	addressEntities      map[string]map[string]string // address -> entity namespace, the tenant ID or empty -> entity ID
+	storage              Storage
+	fetchInterval        time.Duration
+	client               JsonRpcClient
+	notify               NotificationFunc
+	notifyEntity         EntityNotificationFunc
+	notifyEvent          EventNotificationFunc
+	deliver              DeliveryFunc
+	deliveryPolicy       DeliveryPolicy
+	delivery             deliveryState
+	throttlePolicy       ThrottlePolicy
+	throttles            map[string]*throttleState // address -> notification rate, see WithNotificationThrottle
+	deadLetters          []DeadLetter
+	prices               PriceProvider
+	labels               *LabelDB
+	network              Network // explorer of the links, see WithNetwork
+	classifier           TransactionClassifier
+	fallbackClient       JsonRpcClient
+	historySource        HistorySource   // see WithHistorySource
+	staleHead            *staleHeadState // nil without WithStaleHeadDetection
+	proxyMethods         map[string]bool // JSON-RPC methods forwarded by ProxyRequest
+	proxyStats           ProxyStats
+	lifecycleMu          sync.Mutex // guards running and stopped, see Start and Stop
+	running              bool
+	stopped              chan struct{} // closed when the background tasks started by Start are done
+	decodeFailures       []BlockDecodeFailure
+	checkpoints          CheckpointStore
+	subscriptionStore    SubscriptionStore
+	subscriptionStoreMu  sync.Mutex // orders the writes of the subscription store
+	warmUps              []*notifierWarmUp
+	warmUpMu             sync.Mutex
+	health               healthState
+	healthRegistry       *HealthRegistry
+	exportDestination    ExportDestination
+	exportURLExpiry      time.Duration
+	exports              []*ExportJob
+	exportsMu            sync.Mutex
+	blockDeadLetters     BlockDeadLetterStore
+	audit                AuditStore
+	journal              *Journal
+	blockAttempts        int
+	verifyHeaders        bool
+	loopsMu              sync.Mutex
+	loops                map[string]*supervisedLoop // background loops by name, see startLoop
+	watchdogDeadline     time.Duration
+	headerMismatches     int
+	abis                 map[string]*ContractABI // registered contract ABIs by name, see CallContract
+	resolveTokens        bool
+	tokens               map[string]tokenCacheEntry // lowercase token address -> metadata
+	ensTTL               time.Duration              // see WithENSNames, 0 doesn't resolve the names
+	ensNames             map[string]ensCacheEntry   // lowercase address -> primary name
+	recovery             RecoveryStatus
+	recoveryNotify       string
+	selfTransfers        string // see WithSelfTransfers
+	zeroValue            string // see WithZeroValueTransactions
+	screeners            []namedScreener
+	screeningMu          sync.Mutex
+	screened             map[string]screeningVerdict // counterparty -> cached verdict, see WithScreener
+	detect               bool
+	capabilities         Capabilities
+	trackPending         bool
+	pendingTTL           time.Duration // see WithPendingTTL
+	notificationEncoding string        // see WithNotificationNumberEncoding
+	trackAllowances      bool
+	monitorDeployments   bool
+	subscribeDeployments bool
+	allowances           map[string]map[allowanceKey]Allowance    // lowercase owner -> current allowances
+	counterparties       map[string]map[string]*counterpartyStats // address -> counterparty -> aggregate
+	inactivity           map[string]map[string]*inactivityTimer   // address -> tenant -> timer, see InactivityAlert
+	reportsMu            sync.Mutex
+	reportSchedule       string
+	reportRetention      int
+	reportsSince         time.Time                // start of the current report period
+	reportPeriods        map[string]*reportPeriod // address, or "entity:" namespace "/" entity ID -> current period
+	reports              []Report
+	reportSeq            int
+	expectedChainID      int64
+	chainIDMismatch      bool
+	pending              map[string]*PendingTransaction
+	pendingByNonce       map[string]string
+	watches              map[watchKey]*WatchedTransaction // see WatchTransaction
+	heads                HeadSubscriber
+	headCrossCheckPeriod time.Duration
+	headStats            HeadTrackingStats
+	clock                Clock
+	processors           []*registeredProcessor
+	pipeline             []*pipelineStage
+	matcher              MatcherFactory
+	matching             MatchingStats
+	processed            chan struct{} // closed and replaced at the end of every fetch cycle, see WaitForTransactions
+	work                 chan struct{} // fetch cycles queued by the head tracking and by the catch-up, see scheduleFetch
+	maxBlocksPerCycle    int
+	maxBlockLag          int
+	cycleDeadline        time.Duration // see WithCycleDeadline
+	checkpointInterval   int           // blocks between the checkpoints of a cycle, see WithCheckpointInterval
+	shutdownDrain        time.Duration // see WithShutdownDrain
+	flushers             []namedFlusher
+	prefetchDepth        int
+	fetchWorkers         int                // concurrent downloads of the prefetcher, see WithFetchWorkers
+	rpcLimiter           *rateLimitedClient // see WithRPCRateLimit
+	usage                *usageClient       // counts the node requests, see UsageReport
+	usagePlan            *UsagePlan         // see WithUsagePlan
+	usageStore           UsageStore         // see WithUsageStore
+	usageAlerted         string             // month of the last EventUsageProjectionExceeded, only used by the fetch cycles
+	activityStore        ActivityIndexStore // see WithActivityIndex
+	activityRangeBlocks  int
+	activityRetain       int
+	activityOpen         *openActivityRange
+	outboxBatch          int           // outbox events per dispatch round, see WithNotificationBatchSize
+	deferredBlocks       int           // blocks rescheduled after a rate limit, see ThrottleState
+	consumers            ConsumerStore // see WithConsumers
+	consumerSet          map[string]Consumer
+	consumerKeep         int
+	tenants              *TenantManager // set by NewTenantManager, scopes the consumers of the tenants
+	backpressure         BackpressureStats
+	catchingUp           bool // the cycle left blocks behind, the low priority notifications are deferred
+	ctx                  context.Context
+	cycleMu              sync.Mutex
+	dispatchMu           sync.Mutex
+	mu                   sync.Mutex
+	wg                   sync.WaitGroup
+	cancel               context.CancelFunc
 }
 
 // NewEthParser creates a new EthParser instance with initial settings and begins background tasks
@@ -40,15 +194,18 @@ type EthParser struct {
 // a storage interface to interact with the storage layer, and an fetchPeriod which defines the frequency
 // of updates in seconds. The function initializes an EthParser with a map to manage subscriptions, the provided
 // storage, and initialize the lastProcessedBlock and currentBlock.
-// It returns a pointer to the newly created EthParser instance.
+// It returns a pointer to the newly created EthParser instance. It's New followed by Start, embedders
+// controlling the lifecycle can call them separately.
 //
 // Parameters:
 //   - ctx: Parent context to which a new cancellable context is derived for background task management.
 //     It allows the background tasks to be stopped externally.
 //   - storage: Storage interface that the parser uses to interact with the underlying storage mechanism.
-//   - fetchPeriod: The interval in seconds at which the parser updates its data from the blockchain.
+//   - fetchPeriod: The interval in seconds at which the parser updates its data from the blockchain. Zero or
+//     less uses the block time of the network, see WithFetchInterval for sub-second intervals.
 //   - client: A function type for sending JSON-RPC requests
 //   - notify: a function to send custom notifications
+//   - opts: optional settings, see the With* functions in options.go
 //
 // Returns:
 //   - *EthParser: A pointer to the newly created EthParser instance.
@@ -59,72 +216,229 @@ func NewEthParser(
 	storage Storage,
 	fetchPeriod int,
 	client JsonRpcClient,
-	notify NotificationFunc) *EthParser {
+	notify NotificationFunc,
+	opts ...Option) *EthParser {
+	parser := New(storage, fetchPeriod, client, notify, opts...)
+	// A new parser is never running
+	_ = parser.Start(cancellableCtx)
+	return parser
+}
+
+// New creates an EthParser without starting it: no request is sent to the node and no goroutine is started
+// until Start. The parameters are the ones of NewEthParser.
+func New(storage Storage, fetchPeriod int, client JsonRpcClient, notify NotificationFunc, opts ...Option) *EthParser {
 	parser := &EthParser{
-		subscriptions:      make(map[string]bool),
-		storage:            storage,
-		lastProcessedBlock: 0,
-		fetchPeriod:        fetchPeriod,
-		client:             client,
-		notify:             notify,
+		subscriptions:        make(map[string]*Subscription),
+		callbacks:            make(map[string]NotificationFunc),
+		entities:             make(map[entityKey]map[string]bool),
+		addressEntities:      make(map[string]map[string]string),
+		pending:              make(map[string]*PendingTransaction),
+		pendingByNonce:       make(map[string]string),
+		pendingTTL:           defaultPendingTTL,
+		notificationEncoding: NumberEncodingHex,
+		watches:              make(map[watchKey]*WatchedTransaction),
+		storage:              storage,
+		lastProcessedBlock:   0,
+		processed:            make(chan struct{}),
+		work:                 make(chan struct{}, 1),
+		maxBlocksPerCycle:    defaultMaxBlocksPerCycle,
+		fetchWorkers:         1,
+		rpcLimiter:           &rateLimitedClient{},
+		activityRangeBlocks:  defaultActivityRangeBlocks,
+		usage:                &usageClient{counts: make(map[usageKey]int64), pending: make(map[usageKey]int64)},
+		outboxBatch:          outboxBatchSize,
+		maxBlockLag:          defaultMaxBlockLag,
+		cycleDeadline:        defaultCycleDeadline,
+		checkpointInterval:   1,
+		shutdownDrain:        defaultShutdownDrain,
+		allowances:           make(map[string]map[allowanceKey]Allowance),
+		counterparties:       make(map[string]map[string]*counterpartyStats),
+		reportRetention:      defaultReportRetention,
+		reportPeriods:        make(map[string]*reportPeriod),
+		abis:                 map[string]*ContractABI{ERC20ABIName: bundledERC20ABI},
+		tokens:               make(map[string]tokenCacheEntry),
+		ensNames:             make(map[string]ensCacheEntry),
+		loops:                make(map[string]*supervisedLoop),
+		throttles:            make(map[string]*throttleState),
+		inactivity:           make(map[string]map[string]*inactivityTimer),
+		proxyMethods:         proxyMethodSet(DefaultProxyMethods),
+		fetchInterval:        time.Duration(fetchPeriod) * time.Second,
+		client:               client,
+		notify:               notify,
+		clock:                realClock{},
+		recoveryNotify:       RecoveryNotifyDeliver,
+		selfTransfers:        TransactionsDeliver,
+		zeroValue:            TransactionsDeliver,
+		screened:             make(map[string]screeningVerdict),
+		consumerSet:          make(map[string]Consumer),
+		ctx:                  context.Background(),
+		cancel:               func() {},
+		delivery: deliveryState{
+			attempts:    make(map[string]int),
+			retryAt:     make(map[string]time.Time),
+			failedCycle: make(map[string]int),
+		},
 	}
 
-	parser.initializeCurrentBlock()
-
-	// Create a new Cancellable Context and set it in the parser the cancel() function
-	cancellableCtx, cancel := context.WithCancel(cancellableCtx)
-	parser.cancel = cancel
-
-	// Start the background tasks under the cancellableCtx
-	parser.setupBackgroundUpdateTasks(cancellableCtx)
-
+	for _, opt := range opts {
+		opt(parser)
+	}
+	if parser.fetchInterval <= 0 {
+		parser.fetchInterval = parser.network.BlockTime
+	}
+	if parser.fetchInterval <= 0 {
+		parser.fetchInterval = defaultFetchInterval
+	}
+	// The node requests of all the components count in the health of the rpc component, in the rate limit and,
+	// once sent, in the usage
+	parser.usage.client, parser.usage.clock, parser.usage.started = parser.client, parser.clock, parser.clock.Now()
+	parser.rpcLimiter.client, parser.rpcLimiter.clock = parser.usage, parser.clock
+	parser.client = &healthClient{client: parser.rpcLimiter, health: &parser.health}
+	parser.loadConsumers()
+	parser.healthRegistry = NewHealthRegistry()
+	parser.registerHealthChecks()
+	if parser.pipeline == nil {
+		parser.setPipeline(parser.defaultStages())
+	}
+	if parser.deliver == nil {
+		parser.deliver = func(address string, transactions []Transaction) error {
+			parser.notify(address, transactions)
+			return nil
+		}
+	}
 	return parser
 }
 
 func (p *EthParser) setupBackgroundUpdateTasks(cancelCtx context.Context) {
-	p.wg.Add(2)
+	// Tickers are created before starting the goroutines so that a ManualClock advanced right after
+	// the constructor returns already fires them
+	p.startLoop(cancelCtx, LoopHead, p.newHeadLoop)
+	p.startLoop(cancelCtx, LoopFetch, p.newFetchLoop)
+	if p.watchdogDeadline > 0 {
+		watchdogTicker := p.clock.NewTicker(p.watchdogDeadline / 2)
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.runWatchdog(cancelCtx, watchdogTicker)
+		}()
+	}
+	if p.reportSchedule != "" {
+		p.reportsMu.Lock()
+		p.reportsSince = p.clock.Now()
+		p.reportsMu.Unlock()
+		reportTicker := p.clock.NewTicker(time.Minute)
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.runReports(cancelCtx, reportTicker)
+		}()
+	}
+}
 
-	// updates the current block number periodically
-	go func() {
-		defer p.wg.Done()
-		ticker := time.NewTicker(time.Second * time.Duration(p.fetchPeriod))
-		defer ticker.Stop()
+// newHeadLoop returns the loop updating the current block number periodically, or tracking the pushed heads
+func (p *EthParser) newHeadLoop() loopBody {
+	blockTicker := p.clock.NewTicker(p.fetchInterval)
+	var crossCheckTicker Ticker
+	if p.heads != nil {
+		crossCheckTicker = p.clock.NewTicker(p.headCrossCheckPeriod)
+	}
+	return func(cancelCtx context.Context, heartbeat *loopHeartbeat) {
+		defer blockTicker.Stop()
+		if p.heads != nil {
+			defer crossCheckTicker.Stop()
+			p.runHeadTracking(cancelCtx, heartbeat, blockTicker, crossCheckTicker)
+			return
+		}
 		for {
+			heartbeat.idle()
+			if cancelCtx.Err() != nil {
+				log.Println("Stopping runUpdateCurrentBlock")
+				return
+			}
 			select {
-			case <-ticker.C:
+			case <-blockTicker.C():
+				if p.headPollThrottled() {
+					continue
+				}
+				heartbeat.busy(p.clock.Now())
 				log.Println("Updating current block")
 				p.updateCurrentBlock()
+				p.scheduleFetch()
 			case <-cancelCtx.Done():
 				log.Println("Stopping runUpdateCurrentBlock")
 				return
 			}
 		}
-	}()
+	}
+}
 
-	// fetches transactions for subscribed addresses periodically
-	go func() {
-		defer p.wg.Done()
-		ticker := time.NewTicker(time.Second * time.Duration(p.fetchPeriod))
-		defer ticker.Stop()
+// newFetchLoop returns the loop fetching the transactions for subscribed addresses periodically, and as soon
+// as the head tracking queues new blocks
+func (p *EthParser) newFetchLoop() loopBody {
+	fetchTicker := p.clock.NewTicker(p.fetchInterval)
+	return func(cancelCtx context.Context, heartbeat *loopHeartbeat) {
+		defer fetchTicker.Stop()
+		runCycle := func() {
+			heartbeat.busy(p.clock.Now())
+			log.Println("Fetching new transactions")
+			p.verifyChainID()
+			if p.trackPending {
+				p.trackPendingTransactions()
+			}
+			if more := p.fetchTransactions(); more {
+				// Catch up without waiting for the next tick
+				p.scheduleFetch()
+			}
+			p.pollWatchedTransactions()
+			p.dispatchOutbox()
+		}
 		for {
+			heartbeat.idle()
+			// A run replaced by the watchdog exits once its blocking call returned
+			if cancelCtx.Err() != nil {
+				log.Println("Stopping runFetchTransactions")
+				return
+			}
 			select {
-			case <-ticker.C:
-				log.Println("Fetching new transactions")
-				p.fetchTransactions()
+			case <-fetchTicker.C():
+				runCycle()
+			case <-p.work:
+				runCycle()
 			case <-cancelCtx.Done():
 				log.Println("Stopping runFetchTransactions")
 				return
 			}
 		}
-	}()
+	}
 }
 
-// WaitForShutdown waits for the background jobs to complete
+// ProcessNextCycle synchronously runs one background cycle: it updates the current block, fetches the
+// transactions of the new blocks for the subscribed addresses, polls the watched transactions and delivers
+// the pending notifications. Together with a ManualClock it lets embedders and tests drive the parser
+// deterministically. A cycle processes at most the blocks set by WithBackpressure.
+func (p *EthParser) ProcessNextCycle() {
+	p.verifyChainID()
+	p.updateCurrentBlock()
+	if p.trackPending {
+		p.trackPendingTransactions()
+	}
+	p.fetchTransactions()
+	p.pollWatchedTransactions()
+	p.dispatchOutbox()
+}
+
+// WaitForShutdown stops the background jobs, waits for them to complete and drains the pending notifications,
+// see Stop and Drain
 func (p *EthParser) WaitForShutdown() {
 	log.Println("Waiting for background jobs to complete...")
-	p.cancel()
-	p.wg.Wait()
+	p.Stop(context.Background())
 	log.Println("Background jobs stopped")
+	p.Drain()
+}
+
+// Storage returns the storage of the parser
+func (p *EthParser) Storage() Storage {
+	return p.storage
 }
 
 // GetCurrentBlock returns the last parsed block number
@@ -136,18 +450,85 @@ func (p *EthParser) GetCurrentBlock() int {
 
 // Subscribe adds an address to the list of subscriptions
 func (p *EthParser) Subscribe(address string) bool {
+	return p.SubscribeWith(Subscription{Address: address})
+}
+
+// SubscribeWith adds an address to the list of subscriptions together with its settings
+func (p *EthParser) SubscribeWith(subscription Subscription) bool {
+	return p.subscribeWith(subscription, nil)
+}
+
+// subscribeWith adds a subscription, notifying its transactions with callback when not nil. The callback is
+// registered with the subscription so that no transaction of the address is notified without it.
+func (p *EthParser) subscribeWith(subscription Subscription, callback NotificationFunc) bool {
 	p.mu.Lock()
-	defer p.mu.Unlock()
-	if _, exists := p.subscriptions[address]; exists {
+	if _, exists := p.subscriptions[subscription.Address]; exists {
+		p.mu.Unlock()
 		return false
 	}
-	p.subscriptions[address] = true
+	subscription.UnsubscribedAt = nil
+	p.subscriptions[subscription.Address] = &subscription
+	if callback != nil {
+		p.callbacks[subscription.Address] = callback
+	}
+	if subscription.Inactivity != nil {
+		p.setInactivityTimerLocked("", subscription.Address, subscription.Inactivity)
+	}
+	p.mu.Unlock()
+	p.persistSubscription(subscription.Address)
 	return true
 }
 
+// SubscribeWithCallback adds an address to the list of subscriptions and notifies its transactions with fn
+// instead of the NotificationFunc, or the DeliveryFunc, of the parser. Like Subscribe, it returns false
+// and leaves the subscription unchanged when the address is already subscribed.
+func (p *EthParser) SubscribeWithCallback(address string, fn NotificationFunc) bool {
+	return p.subscribeWith(Subscription{Address: address}, fn)
+}
+
+// deliverFor returns the delivery of the notifications of an address, in the notification number encoding
+func (p *EthParser) deliverFor(address string) DeliveryFunc {
+	p.mu.Lock()
+	callback, hasCallback := p.callbacks[address]
+	p.mu.Unlock()
+	deliver := p.deliver
+	if hasCallback {
+		deliver = func(address string, transactions []Transaction) error {
+			callback(address, transactions)
+			return nil
+		}
+	}
+	if p.notificationEncoding == NumberEncodingHex {
+		return deliver
+	}
+	return func(address string, transactions []Transaction) error {
+		return deliver(address, TransactionsWithNumberEncoding(transactions, p.notificationEncoding))
+	}
+}
+
+// GetSubscription returns the subscription of an address
+func (p *EthParser) GetSubscription(address string) (Subscription, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	subscription, exists := p.subscriptions[address]
+	if !exists {
+		return Subscription{}, false
+	}
+	return *subscription, true
+}
+
 // GetTransactions returns the list of transactions for a given address
 func (p *EthParser) GetTransactions(address string) []Transaction {
-	return p.storage.GetTransactions(address)
+	return p.annotateTransactions(address, p.storage.GetTransactions(address))
+}
+
+// TransactionsTruncated reports whether the storage evicted transactions of the address, in which case
+// GetTransactions may not return its full history
+func (p *EthParser) TransactionsTruncated(address string) bool {
+	if reporter, ok := p.storage.(interface{ Truncated(address string) bool }); ok {
+		return reporter.Truncated(address)
+	}
+	return false
 }
 
 // initializeCurrentBlock initialize the current block and last processed block
@@ -155,46 +536,64 @@ func (p *EthParser) initializeCurrentBlock() {
 	if p.lastProcessedBlock == 0 {
 		p.updateCurrentBlock()
 		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.resumeFromCheckpointLocked() {
+			return
+		}
 		p.lastProcessedBlock = p.currentBlock - initialLookBackBlocksCount
 
 		// Ensure lastProcessedBlock is not negative
 		if p.lastProcessedBlock < 0 {
 			p.lastProcessedBlock = 0
 		}
-
-		p.mu.Unlock()
 	}
 }
 
 // updateCurrentBlock fetches and updates the current block number from the Ethereum blockchain
 func (p *EthParser) updateCurrentBlock() {
-	req := JSONRPCRequest{
-		JSONRPC: "2.0",
-		Method:  "eth_blockNumber",
-		Params:  []interface{}{},
-		ID:      1,
-	}
-
-	resp, err := p.client.SendRequest(req)
+	blockNumber, err := p.fetchBlockNumber()
 	if err != nil {
 		log.Println("Error fetching block number:", err)
 		return
 	}
+	p.setCurrentBlock(blockNumber)
+}
 
-	blockNumberHex := resp.Result.(string) // ex. 0x4b7
-	blockNumberDecimal, err := convertHexNumberToDecimal(blockNumberHex)
-	if err != nil {
-		log.Println("Error parsing block number:", err)
-		return
-	}
-
+// setCurrentBlock sets the current block number
+func (p *EthParser) setCurrentBlock(blockNumber int) {
 	p.mu.Lock()
-	p.currentBlock = blockNumberDecimal
+	p.currentBlock = blockNumber
 	p.mu.Unlock()
 }
 
-// fetchTransactions fetches transactions for all subscribed addresses
-func (p *EthParser) fetchTransactions() {
+// fetchBlockNumber fetches the current block number from the Ethereum blockchain
+func (p *EthParser) fetchBlockNumber() (int, error) {
+	head, err := blockNumber(p.client)
+	if err != nil {
+		return 0, err
+	}
+	// A stalled provider is replaced by one ahead of it, see WithStaleHeadDetection
+	return p.checkStaleHead(head), nil
+}
+
+// fetchTransactions runs the processing pipeline on the new blocks, see pipeline.go. It reports whether
+// blocks were left for the next cycle because of the maxBlocksPerCycle bound, the cycle deadline or a
+// shutdown. The progress is checkpointed as the blocks are processed, so that a crash only loses the blocks
+// since the last checkpoint.
+func (p *EthParser) fetchTransactions() (more bool) {
+	// Cycles started by the background loop and by ProcessNextCycle must not overlap
+	p.cycleMu.Lock()
+	defer p.cycleMu.Unlock()
+
+	if p.ChainIDMismatch() {
+		log.Println("Refusing to fetch transactions: chain ID mismatch")
+		return
+	}
+	if throttle := p.rpcLimiter.throttleState(); throttle.Throttled {
+		log.Printf("Holding fetchTransactions back until %s: rate limited by the node\n", throttle.Until.Format(time.RFC3339))
+		return
+	}
+
 	log.Println("Starting fetchTransactions")
 
 	p.mu.Lock()
@@ -202,90 +601,149 @@ func (p *EthParser) fetchTransactions() {
 	for address := range p.subscriptions {
 		subscribedAddresses[address] = true
 	}
+	matcher := p.newMatcher(subscribedAddresses)
 	startBlock := p.lastProcessedBlock + 1
 	currentBlock := p.currentBlock
+	if p.maxBlocksPerCycle > 0 && currentBlock-startBlock+1 > p.maxBlocksPerCycle {
+		currentBlock = startBlock + p.maxBlocksPerCycle - 1
+		more = true
+		p.backpressure.CatchUpCycles++
+	}
+	p.catchingUp = more
+	p.observeLagLocked()
+	prefetchDepth, fetchWorkers := p.prefetchDepth, p.fetchWorkers
 	p.mu.Unlock()
 
 	log.Printf("Fetching transactions from block %d to %d\n", startBlock, currentBlock)
 
+	p.startDeliveryCycle()
+	var prefetch *prefetcher
+	if prefetchDepth > 0 && currentBlock > startBlock {
+		prefetch = p.startPrefetch(startBlock, currentBlock, prefetchDepth, fetchWorkers)
+		defer prefetch.stop()
+	}
+	var deadline time.Time
+	if p.cycleDeadline > 0 {
+		deadline = p.clock.Now().Add(p.cycleDeadline)
+	}
+	processed := startBlock - 1
 	for i := startBlock; i <= currentBlock; i++ {
-		block, err := p.getBlockByNumber(i)
-		if err != nil {
-			log.Println("Error fetching block number:", i, err)
-			continue
+		block := &BlockContext{Number: i, Subscribed: subscribedAddresses, Matcher: matcher, Matches: make(map[string][]Transaction)}
+		if prefetch != nil {
+			block.prefetched = prefetch.next()
 		}
-
-		blockNumberDecimal, err := convertHexNumberToDecimal(block.Number)
-		if err != nil {
-			log.Println("Error parsing block number:", err)
-			continue
+		p.runPipeline(block)
+		if block.rateLimited {
+			// The block isn't skipped: the cycle stops and the next one starts from it once the pause is over
+			log.Printf("Rescheduling blocks %d to %d after a rate limit of the node\n", i, currentBlock)
+			p.mu.Lock()
+			p.deferredBlocks += currentBlock - i + 1
+			p.mu.Unlock()
+			more = false
+			break
 		}
-
-		transactionsForAddresses := make(map[string][]Transaction)
-
-		for _, tx := range block.Transactions {
-			if subscribedAddresses[tx.From] || subscribedAddresses[tx.To] {
-				tx.BlockNumberDecimal = blockNumberDecimal
-				if subscribedAddresses[tx.From] {
-					transactionsForAddresses[tx.From] = append(transactionsForAddresses[tx.From], tx)
-				}
-				if subscribedAddresses[tx.To] {
-					transactionsForAddresses[tx.To] = append(transactionsForAddresses[tx.To], tx)
-				}
-			}
+		for address := range block.Matches {
+			p.recordActivity(address)
 		}
-
-		for address, transactions := range transactionsForAddresses {
-			log.Printf("Found %d transactions for address %s in block %d\n", len(transactions), address, i)
-			p.notify(address, transactions)
-			err := p.storage.SaveTransactions(address, transactions)
-			if err != nil {
-				log.Printf("error saving transaction for addres %s", address)
-			}
+		processed = i
+		if i == currentBlock {
+			break
+		}
+		p.checkpointBlock(i, i-startBlock+1)
+		if reason := p.cycleInterrupted(deadline); reason != "" {
+			log.Printf("Stopping fetchTransactions after block %d of %d: %s\n", i, currentBlock, reason)
+			more = true
+			break
 		}
 	}
+	p.checkInactivity()
+	p.saveUsage()
+	p.checkUsagePlan()
 
 	p.mu.Lock()
-	p.lastProcessedBlock = currentBlock
+	p.lastProcessedBlock = processed
+	if more {
+		p.catchingUp = true
+	}
+	p.updateRecoveryLocked()
+	p.notifyProcessed()
 	p.mu.Unlock()
+	p.saveCheckpoint(processed)
 
 	log.Println("Completed fetchTransactions")
+	return more
+}
+
+// getBlockByNumber fetches a block by its number, together with the block as returned by the node
+func (p *EthParser) getBlockByNumber(number int) (Block, json.RawMessage, error) {
+	return p.getRawBlock(fmt.Sprintf("0x%x", number))
 }
 
-// getBlockByNumber fetches a block by its number
-func (p *EthParser) getBlockByNumber(number int) (Block, error) {
-	numberHex := fmt.Sprintf("0x%x", number)
+// getBlock fetches a block by its hex number or by tag (e.g. "pending"). A block that can only be partially
+// decoded is fetched again from the fallback client, if any, and its decoding errors are recorded.
+func (p *EthParser) getBlock(numberOrTag string) (Block, error) {
+	block, _, err := p.getRawBlock(numberOrTag)
+	return block, err
+}
+
+// getRawBlock is getBlock also returning the block as returned by the node whose decoding was kept
+func (p *EthParser) getRawBlock(numberOrTag string) (Block, json.RawMessage, error) {
+	block, raw, errs, err := p.fetchBlock(p.client, numberOrTag)
+	if err != nil || len(errs) == 0 {
+		return block, raw, err
+	}
+
+	failure := BlockDecodeFailure{Block: numberOrTag, Errors: errs}
+	if p.fallbackClient != nil {
+		failure.Fallback = true
+		fallbackBlock, fallbackRaw, fallbackErrs, err := p.fetchBlock(p.fallbackClient, numberOrTag)
+		switch {
+		case err != nil:
+			log.Printf("Error fetching block %s from the fallback client: %v\n", numberOrTag, err)
+		case len(fallbackErrs) == 0:
+			failure.Recovered = true
+			block, raw = fallbackBlock, fallbackRaw
+		case len(fallbackErrs) < len(errs):
+			block, raw = fallbackBlock, fallbackRaw
+		}
+	}
+	p.recordDecodeFailure(failure)
+	return block, raw, nil
+}
+
+// fetchBlock fetches a block from a client, returning the raw block and the fields that couldn't be decoded
+func (p *EthParser) fetchBlock(client JsonRpcClient, numberOrTag string) (Block, json.RawMessage, []string, error) {
 	req := JSONRPCRequest{
 		JSONRPC: "2.0",
 		Method:  "eth_getBlockByNumber",
-		Params:  []interface{}{numberHex, true},
+		Params:  []interface{}{numberOrTag, true},
 		ID:      1,
 	}
 
-	resp, err := p.client.SendRequest(req)
+	resp, err := client.SendRequest(req)
 	if err != nil {
-		return Block{}, err
+		return Block{}, nil, nil, err
 	}
 
 	resultMap, ok := resp.Result.(map[string]interface{})
 	if !ok {
-		return Block{}, fmt.Errorf("unexpected result format")
+		return Block{}, nil, nil, fmt.Errorf("unexpected result format")
 	}
 
-	var block Block
 	resultBytes, err := json.Marshal(resultMap)
 	if err != nil {
-		return Block{}, err
-	}
-	err = json.Unmarshal(resultBytes, &block)
-	if err != nil {
-		return Block{}, err
+		return Block{}, nil, nil, err
 	}
-
-	return block, nil
+	block, errs, err := decodeBlock(resultBytes)
+	return block, resultBytes, errs, err
 }
 
 func convertHexNumberToDecimal(hexNumber string) (int, error) {
+	if len(hexNumber) < 3 || hexNumber[0] != '0' || (hexNumber[1] != 'x' && hexNumber[1] != 'X') {
+		err := fmt.Errorf("invalid hex number %q", hexNumber)
+		log.Println("Error parsing hex number:", err)
+		return -1, err
+	}
 	blockNumber, err := strconv.ParseInt(hexNumber[2:], 16, 64)
 	if err != nil {
 		log.Println("Error parsing hex number:", err)
@@ -42,7 +42,9 @@ func TestEthParser(t *testing.T) {
 		notifications[address] = append(notifications[address], transactions...)
 	}
 
-	ethParser := parser.NewEthParser(ctx, storage, 1, NewMockClient(mockBlockchain), notifyFunc)
+	ethParser := parser.NewEthParser(ctx, storage, 1, NewMockClient(mockBlockchain), notifyFunc,
+		parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
 
 	// Subscribe to addresses
 	if !ethParser.Subscribe("0x1") {
@@ -52,8 +54,8 @@ func TestEthParser(t *testing.T) {
 		t.Fatal("Failed to subscribe to address 0x2")
 	}
 
-	// Wait for background tasks to process the mock data
-	time.Sleep(2 * time.Second)
+	// Process the mock data
+	ethParser.ProcessNextCycle()
 
 	// Check transactions for subscribed addresses
 	transactions := ethParser.GetTransactions("0x1")
@@ -76,3 +78,102 @@ func TestEthParser(t *testing.T) {
 		t.Fatalf("Unexpected notifications for address 0x2: %v", notifications["0x2"])
 	}
 }
+
+func TestEthParserBlobTransactions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{
+		Number: "0x1",
+		Transactions: []parser.Transaction{
+			{Hash: "0xabc", From: "0x1", To: "0x2", Value: "100"},
+			{Hash: "0xdef", From: "0x2", To: "0x3", Value: "200", Type: parser.BlobTransactionType,
+				MaxFeePerBlobGas: "0x1", BlobVersionedHashes: []string{"0x01aa"}},
+		},
+	})
+
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain),
+		func(string, []parser.Transaction) {}, parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x2")
+	ethParser.ProcessNextCycle()
+
+	// Only the type 3 transaction is tagged, with its blob fields
+	transactions := ethParser.GetTransactions("0x2")
+	if len(transactions) != 2 || transactions[0].BlobTransaction || !transactions[1].BlobTransaction ||
+		transactions[1].MaxFeePerBlobGas != "0x1" || len(transactions[1].BlobVersionedHashes) != 1 {
+		t.Fatalf("Unexpected blob transaction decoding for address 0x2: %v", transactions)
+	}
+}
+
+func TestEthParserManualClock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1"})
+
+	clock := parser.NewManualClock(time.Now())
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain),
+		func(string, []parser.Transaction) {}, parser.WithClock(clock))
+	defer ethParser.WaitForShutdown()
+
+	mockBlockchain.AddBlock(2, parser.Block{Number: "0x2"})
+
+	// The background loops only run when the clock moves
+	if block := ethParser.GetCurrentBlock(); block != 1 {
+		t.Fatalf("Unexpected current block %d", block)
+	}
+
+	clock.Advance(time.Second)
+	deadline := time.Now().Add(time.Second)
+	for ethParser.GetCurrentBlock() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("Current block not updated after advancing the clock")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestEthParserSubscribeWithCallback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{
+		Number: "0x1",
+		Transactions: []parser.Transaction{
+			{Hash: "0xa1", From: "0xorders", To: "0x9", Value: "0x1"},
+			{Hash: "0xa2", From: "0xalerts", To: "0x9", Value: "0x1"},
+			{Hash: "0xa3", From: "0xplain", To: "0x9", Value: "0x1"},
+		},
+	})
+
+	notified := make(map[string][]string)
+	record := func(name string) parser.NotificationFunc {
+		return func(address string, transactions []parser.Transaction) {
+			for _, tx := range transactions {
+				notified[name] = append(notified[name], tx.Hash)
+			}
+		}
+	}
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain), record("default"),
+		parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+
+	if !ethParser.SubscribeWithCallback("0xorders", record("orders")) || !ethParser.SubscribeWithCallback("0xalerts", record("alerts")) {
+		t.Fatal("Expected the addresses to be subscribed")
+	}
+	if ethParser.SubscribeWithCallback("0xorders", record("alerts")) {
+		t.Error("Expected an already subscribed address to be refused")
+	}
+	ethParser.Subscribe("0xplain")
+	ethParser.ProcessNextCycle()
+
+	for name, hash := range map[string]string{"orders": "0xa1", "alerts": "0xa2", "default": "0xa3"} {
+		if len(notified[name]) != 1 || notified[name][0] != hash {
+			t.Errorf("Expected %s to be notified to %s, got %v", hash, name, notified[name])
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package parser
+
+import (
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// Pending transaction statuses
+const (
+	PendingStatusPending = "pending"
+	PendingStatusDropped = "dropped"
+)
+
+// defaultPendingTTL is how long a pending transaction is tracked without being mined nor replaced
+const defaultPendingTTL = time.Hour
+
+// PendingTransaction is an outgoing transaction of a subscribed address seen in the pending block.
+// A replaced transaction is marked as dropped and links the hash of its replacement, until a transaction of its
+// nonce is mined.
+type PendingTransaction struct {
+	Transaction
+	Status     string `json:"status"`
+	ReplacedBy string `json:"replacedBy,omitempty"`
+	seenAt     time.Time
+}
+
+// GetPendingTransactions returns the tracked pending and dropped outgoing transactions of an address, ordered by nonce
+func (p *EthParser) GetPendingTransactions(address string) []PendingTransaction {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	result := []PendingTransaction{}
+	for _, pending := range p.pending {
+		if pending.From == address {
+			result = append(result, *pending)
+		}
+	}
+	nonces := make(map[string]*big.Int, len(result))
+	for _, pending := range result {
+		if nonces[pending.Hash], _ = parseQuantity(pending.Nonce); nonces[pending.Hash] == nil {
+			nonces[pending.Hash] = new(big.Int)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		cmp := nonces[result[i].Hash].Cmp(nonces[result[j].Hash])
+		return cmp < 0 || (cmp == 0 && result[i].Status < result[j].Status)
+	})
+	return result
+}
+
+// trackPendingTransactions fetches the pending block and records the outgoing transactions of the subscribed
+// addresses, detecting replacements: a new transaction with the same sender and nonce and a higher fee.
+func (p *EthParser) trackPendingTransactions() {
+	p.prunePendingTransactions()
+	block, err := p.getBlock("pending")
+	if err != nil {
+		log.Println("Error fetching pending block:", err)
+		return
+	}
+
+	for _, tx := range block.Transactions {
+		p.mu.Lock()
+		_, subscribed := p.subscriptions[tx.From]
+		p.mu.Unlock()
+		if !subscribed || tx.Nonce == "" {
+			continue
+		}
+		p.recordPendingTransaction(tx)
+	}
+}
+
+// recordPendingTransaction tracks a pending transaction, replacing the one with the same nonce if it pays more
+func (p *EthParser) recordPendingTransaction(tx Transaction) {
+	key := pendingKey(tx)
+
+	p.mu.Lock()
+	previousHash, exists := p.pendingByNonce[key]
+	if exists && previousHash == tx.Hash {
+		// Still in the mempool, the TTL starts over
+		p.pending[tx.Hash].seenAt = p.clock.Now()
+		p.mu.Unlock()
+		return
+	}
+	if exists && transactionFee(tx).Cmp(transactionFee(p.pending[previousHash].Transaction)) <= 0 {
+		// Not a valid replacement, nodes keep the transaction already in the pool
+		p.mu.Unlock()
+		return
+	}
+	p.pending[tx.Hash] = &PendingTransaction{Transaction: tx, Status: PendingStatusPending, seenAt: p.clock.Now()}
+	p.pendingByNonce[key] = tx.Hash
+	p.mu.Unlock()
+
+	if exists {
+		p.markReplaced(previousHash, tx)
+	}
+}
+
+// resolvePendingTransaction is called for every mined outgoing transaction: it marks as replaced a different
+// pending transaction that used the same nonce, then stops tracking the transactions of the nonce, whose
+// replacements were all reported
+func (p *EthParser) resolvePendingTransaction(tx Transaction) {
+	if tx.Nonce == "" {
+		return
+	}
+	key := pendingKey(tx)
+
+	p.mu.Lock()
+	pendingHash, exists := p.pendingByNonce[key]
+	if exists {
+		delete(p.pendingByNonce, key)
+	}
+	delete(p.pending, tx.Hash)
+	p.mu.Unlock()
+
+	if exists && pendingHash != tx.Hash {
+		p.markReplaced(pendingHash, tx)
+	}
+
+	p.mu.Lock()
+	for hash, pending := range p.pending {
+		if pendingKey(pending.Transaction) == key {
+			delete(p.pending, hash)
+		}
+	}
+	p.mu.Unlock()
+}
+
+// prunePendingTransactions stops tracking the transactions seen for longer than the pending TTL, which left the
+// mempool without being mined
+func (p *EthParser) prunePendingTransactions() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cutoff := p.clock.Now().Add(-p.pendingTTL)
+	for hash, pending := range p.pending {
+		if !pending.seenAt.Before(cutoff) {
+			continue
+		}
+		delete(p.pending, hash)
+		if key := pendingKey(pending.Transaction); p.pendingByNonce[key] == hash {
+			delete(p.pendingByNonce, key)
+		}
+	}
+}
+
+// markReplaced marks a pending transaction as dropped in favour of replacement and notifies it
+func (p *EthParser) markReplaced(oldHash string, replacement Transaction) {
+	p.mu.Lock()
+	old, ok := p.pending[oldHash]
+	if ok {
+		old.Status = PendingStatusDropped
+		old.ReplacedBy = replacement.Hash
+	}
+	p.mu.Unlock()
+
+	log.Printf("Transaction %s replaced by %s\n", oldHash, replacement.Hash)
+	p.emitEvent(Event{
+		Type:    EventTransactionReplaced,
+		Address: replacement.From,
+		Data: map[string]string{
+			"oldHash": oldHash,
+			"newHash": replacement.Hash,
+			"nonce":   replacement.Nonce,
+		},
+	})
+}
+
+// pendingKey identifies the pending transaction slot of a sender
+func pendingKey(tx Transaction) string {
+	return fmt.Sprintf("%s:%s", tx.From, tx.Nonce)
+}
+
+// transactionFee returns the maximum fee per gas a transaction is willing to pay
+func transactionFee(tx Transaction) *big.Int {
+	fee := tx.MaxFeePerGas
+	if fee == "" {
+		fee = tx.GasPrice
+	}
+	value, ok := parseQuantity(fee)
+	if !ok {
+		return new(big.Int)
+	}
+	return value
+}
+
+// trimHexPrefix removes the 0x prefix of a hex string
+func trimHexPrefix(hex string) string {
+	if len(hex) >= 2 && hex[0] == '0' && (hex[1] == 'x' || hex[1] == 'X') {
+		return hex[2:]
+	}
+	return hex
+}
@@ -0,0 +1,115 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestEthParserReplacedTransaction(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1"})
+	mockBlockchain.Pending = parser.Block{
+		Transactions: []parser.Transaction{
+			{Hash: "0xold", From: "0x1", To: "0x2", Nonce: "0x5", MaxFeePerGas: "0x10"},
+		},
+	}
+
+	var events []parser.Event
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain),
+		func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())),
+		parser.WithPendingTracking(),
+		parser.WithEventNotification(func(event parser.Event) { events = append(events, event) }))
+	defer ethParser.WaitForShutdown()
+
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	// A replacement paying less is ignored, one paying more replaces the pending transaction
+	mockBlockchain.Pending = parser.Block{
+		Transactions: []parser.Transaction{
+			{Hash: "0xcheap", From: "0x1", To: "0x2", Nonce: "0x5", MaxFeePerGas: "0x8"},
+			{Hash: "0xnew", From: "0x1", To: "0x2", Nonce: "0x5", MaxFeePerGas: "0x20"},
+		},
+	}
+	ethParser.ProcessNextCycle()
+
+	if len(events) != 1 || events[0].Type != parser.EventTransactionReplaced ||
+		events[0].Data["oldHash"] != "0xold" || events[0].Data["newHash"] != "0xnew" {
+		t.Fatalf("Unexpected events: %v", events)
+	}
+
+	pending := ethParser.GetPendingTransactions("0x1")
+	if len(pending) != 2 {
+		t.Fatalf("Unexpected pending transactions: %v", pending)
+	}
+	for _, tx := range pending {
+		if tx.Hash == "0xold" && (tx.Status != parser.PendingStatusDropped || tx.ReplacedBy != "0xnew") {
+			t.Fatalf("Expected 0xold to be dropped in favour of 0xnew: %+v", tx)
+		}
+	}
+}
+
+func TestEthParserPendingPruning(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1"})
+	mockBlockchain.Pending = parser.Block{
+		Transactions: []parser.Transaction{
+			{Hash: "0xold", From: "0x1", To: "0x2", Nonce: "0x5", MaxFeePerGas: "0x10"},
+			{Hash: "0xstuck", From: "0x1", To: "0x2", Nonce: "0x6", MaxFeePerGas: "0x10"},
+			{Hash: "0xodd", From: "0x1", To: "0x2", Nonce: "7", MaxFeePerGas: "0x10"},
+		},
+	}
+	clock := parser.NewManualClock(time.Now())
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain),
+		func(string, []parser.Transaction) {}, parser.WithClock(clock), parser.WithPendingTracking(),
+		parser.WithPendingTTL(10*time.Minute))
+	defer ethParser.WaitForShutdown()
+
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+	// A nonce without 0x is ordered, not sliced
+	if pending := ethParser.GetPendingTransactions("0x1"); len(pending) != 3 || pending[2].Hash != "0xodd" {
+		t.Fatalf("Unexpected pending transactions: %+v", pending)
+	}
+
+	// The replaced transaction is dropped until its replacement is mined
+	mockBlockchain.Pending = parser.Block{
+		Transactions: []parser.Transaction{
+			{Hash: "0xnew", From: "0x1", To: "0x2", Nonce: "0x5", MaxFeePerGas: "0x20"},
+			{Hash: "0xstuck", From: "0x1", To: "0x2", Nonce: "0x6", MaxFeePerGas: "0x10"},
+		},
+	}
+	ethParser.ProcessNextCycle()
+	if pending := ethParser.GetPendingTransactions("0x1"); len(pending) != 4 || pending[0].Status != parser.PendingStatusDropped {
+		t.Fatalf("Unexpected pending transactions: %+v", pending)
+	}
+	mockBlockchain.AddBlock(2, parser.Block{
+		Number:       "0x2",
+		Transactions: []parser.Transaction{{Hash: "0xnew", From: "0x1", To: "0x2", Nonce: "0x5", MaxFeePerGas: "0x20"}},
+	})
+	mockBlockchain.Pending = parser.Block{
+		Transactions: []parser.Transaction{{Hash: "0xstuck", From: "0x1", To: "0x2", Nonce: "0x6", MaxFeePerGas: "0x10"}},
+	}
+	ethParser.ProcessNextCycle()
+	if pending := ethParser.GetPendingTransactions("0x1"); len(pending) != 2 || pending[0].Hash != "0xstuck" || pending[1].Hash != "0xodd" {
+		t.Fatalf("Expected the nonce of the mined transaction to be pruned, got %+v", pending)
+	}
+
+	// The transactions left the mempool expire, the ones still seen are kept
+	clock.Advance(11 * time.Minute)
+	ethParser.ProcessNextCycle()
+	clock.Advance(11 * time.Minute)
+	ethParser.ProcessNextCycle()
+	if pending := ethParser.GetPendingTransactions("0x1"); len(pending) != 1 || pending[0].Hash != "0xstuck" {
+		t.Fatalf("Expected the expired transactions to be pruned, got %+v", pending)
+	}
+}
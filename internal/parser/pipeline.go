@@ -0,0 +1,340 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Names of the default pipeline stages, in their default order
+const (
+	StageFetch      = "fetch"
+	StageProcessors = "processors"
+	StageDecode     = "decode"
+	StageFilter     = "filter"
+	StageEnrich     = "enrich"
+	StageStore      = "store"
+	StageNotify     = "notify"
+)
+
+// StageErrorPolicy defines what happens to a block when one of its stages fails
+type StageErrorPolicy int
+
+const (
+	// StageErrorSkipBlock stops the pipeline for the block, the following stages don't run
+	StageErrorSkipBlock StageErrorPolicy = iota
+	// StageErrorContinue logs the error and runs the following stages
+	StageErrorContinue
+)
+
+// BlockContext is a block flowing through the processing pipeline, each stage reads and completes it
+type BlockContext struct {
+	// Number is the decimal number of the block being processed
+	Number int
+	// Block is the block fetched from the node, set by the fetch stage
+	Block Block
+	// Raw is the block as returned by the node, set by the fetch stage
+	Raw json.RawMessage
+	// Subscribed is the set of the addresses subscribed when the cycle started
+	Subscribed map[string]bool
+	// Matcher proposes the addresses of the transactions for the filter stage, the exact matcher of Subscribed
+	// when nil, see WithMatcher
+	Matcher Matcher
+	// Matches are the transactions of the block per subscribed address, set by the filter stage
+	Matches map[string][]Transaction
+	// Done stops the pipeline for the block without error, e.g. a stage filtering the block out
+	Done bool
+
+	prefetched  *prefetchedBlock // downloaded ahead by the prefetcher, see WithPrefetch
+	rateLimited bool             // the fetch was rejected by a rate limit, the block is rescheduled
+}
+
+// Stage is a step of the block processing pipeline
+type Stage interface {
+	Process(ctx context.Context, block *BlockContext) error
+}
+
+// StageFunc adapts a function to the Stage interface
+type StageFunc func(ctx context.Context, block *BlockContext) error
+
+// Process calls f(ctx, block)
+func (f StageFunc) Process(ctx context.Context, block *BlockContext) error {
+	return f(ctx, block)
+}
+
+// PipelineStage is a named Stage with its error policy
+type PipelineStage struct {
+	Name    string
+	Stage   Stage
+	OnError StageErrorPolicy
+}
+
+// StageStats reports the activity of a pipeline stage
+type StageStats struct {
+	Name          string        `json:"name"`
+	Processed     int           `json:"processed"`
+	Errors        int           `json:"errors"`
+	Panics        int           `json:"panics"`
+	TotalDuration time.Duration `json:"totalDuration"`
+	LastError     string        `json:"lastError,omitempty"`
+}
+
+// pipelineStage is a PipelineStage with its stats
+type pipelineStage struct {
+	PipelineStage
+	stats StageStats
+}
+
+// StageIndex returns the index of the named stage, or -1
+func StageIndex(stages []PipelineStage, name string) int {
+	for i, stage := range stages {
+		if stage.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// defaultStages returns the built-in pipeline: fetch, processors, decode, activity, filter, categorize, tokens,
+// enrich, journal, store, counterparties, allowances, deployments, reports, notify
+func (p *EthParser) defaultStages() []PipelineStage {
+	return []PipelineStage{
+		{Name: StageFetch, Stage: StageFunc(p.fetchStage)},
+		{Name: StageProcessors, Stage: StageFunc(p.processorsStage), OnError: StageErrorContinue},
+		{Name: StageDecode, Stage: StageFunc(p.decodeStage)},
+		{Name: StageActivity, Stage: StageFunc(p.activityStage), OnError: StageErrorContinue},
+		{Name: StageFilter, Stage: StageFunc(p.filterStage)},
+		{Name: StageCategorize, Stage: StageFunc(p.categorizeStage), OnError: StageErrorContinue},
+		{Name: StageTokens, Stage: StageFunc(p.tokensStage), OnError: StageErrorContinue},
+		{Name: StageEnrich, Stage: StageFunc(p.enrichStage), OnError: StageErrorContinue},
+		{Name: StageScreening, Stage: StageFunc(p.screeningStage), OnError: StageErrorContinue},
+		{Name: StageJournal, Stage: StageFunc(p.journalStage)},
+		{Name: StageStore, Stage: StageFunc(p.storeStage)},
+		{Name: StageCounterparties, Stage: StageFunc(p.counterpartiesStage), OnError: StageErrorContinue},
+		{Name: StageAllowances, Stage: StageFunc(p.allowancesStage), OnError: StageErrorContinue},
+		{Name: StageDeployments, Stage: StageFunc(p.deploymentsStage), OnError: StageErrorContinue},
+		{Name: StageReports, Stage: StageFunc(p.reportsStage), OnError: StageErrorContinue},
+		{Name: StageNotify, Stage: StageFunc(p.notifyStage), OnError: StageErrorContinue},
+	}
+}
+
+// setPipeline installs the stages of the pipeline
+func (p *EthParser) setPipeline(stages []PipelineStage) {
+	p.pipeline = make([]*pipelineStage, 0, len(stages))
+	for _, stage := range stages {
+		p.pipeline = append(p.pipeline, &pipelineStage{PipelineStage: stage, stats: StageStats{Name: stage.Name}})
+	}
+}
+
+// PipelineStats returns the stats of the pipeline stages, in pipeline order
+func (p *EthParser) PipelineStats() []StageStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make([]StageStats, 0, len(p.pipeline))
+	for _, stage := range p.pipeline {
+		stats = append(stats, stage.stats)
+	}
+	return stats
+}
+
+// runPipeline runs the stages on a block, see runBlockWithRetries
+func (p *EthParser) runPipeline(block *BlockContext) {
+	p.runBlockWithRetries(block)
+}
+
+// runStages runs stages on a block, applying their error policy. It reports whether all the stages ran.
+func (p *EthParser) runStages(stages []*pipelineStage, block *BlockContext) bool {
+	failed, _ := p.runStagesFrom(stages, block, 0)
+	return failed < 0
+}
+
+// runStagesFrom runs the stages from the index from on, it returns the index and the error of the stage
+// that stopped the block, -1 when all the stages ran, or the index of the stage setting Done with a nil error
+func (p *EthParser) runStagesFrom(stages []*pipelineStage, block *BlockContext, from int) (int, error) {
+	for i := from; i < len(stages); i++ {
+		stage := stages[i]
+		start := time.Now()
+		panicked, err := runStage(p.ctx, stage.Stage, block)
+		elapsed := time.Since(start)
+
+		p.mu.Lock()
+		stage.stats.Processed++
+		stage.stats.TotalDuration += elapsed
+		if err != nil {
+			stage.stats.Errors++
+			stage.stats.LastError = err.Error()
+		}
+		if panicked {
+			stage.stats.Panics++
+		}
+		p.mu.Unlock()
+
+		if err != nil {
+			log.Printf("Stage %s failed on block %d: %v\n", stage.Name, block.Number, err)
+			if stage.OnError == StageErrorSkipBlock {
+				return i, err
+			}
+		}
+		if block.Done {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// runStage calls a stage turning a panic into an error
+func runStage(ctx context.Context, stage Stage, block *BlockContext) (panicked bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked, err = true, fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return false, stage.Process(ctx, block)
+}
+
+// fetchStage fetches the block from the node
+func (p *EthParser) fetchStage(ctx context.Context, block *BlockContext) error {
+	prefetched, ok := p.takePrefetched(block)
+	fetched, raw, err := prefetched.block, prefetched.raw, prefetched.err
+	if !ok {
+		fetched, raw, err = p.getBlockByNumber(block.Number)
+	}
+	block.Raw = raw
+	if err != nil {
+		_, block.rateLimited = rateLimitRetryAfter(err)
+		return fmt.Errorf("fetching block: %w", err)
+	}
+	if err := p.verifyHeader(block.Number, raw); err != nil {
+		return fmt.Errorf("verifying block header: %w", err)
+	}
+	block.Block = fetched
+	return nil
+}
+
+// processorsStage runs the registered BlockProcessors, which isolate their own errors
+func (p *EthParser) processorsStage(ctx context.Context, block *BlockContext) error {
+	p.runBlockProcessors(block.Block)
+	return nil
+}
+
+// decodeStage sets the fields derived from the raw transactions
+func (p *EthParser) decodeStage(ctx context.Context, block *BlockContext) error {
+	number, err := convertHexNumberToDecimal(block.Block.Number)
+	if err != nil {
+		return fmt.Errorf("parsing block number: %w", err)
+	}
+	block.Number = number
+	var blockTime *time.Time
+	if timestamp, err := blockTimestamp(block.Block); block.Block.Timestamp != "" && err == nil {
+		blockTime = &timestamp
+	}
+	for i := range block.Block.Transactions {
+		tx := &block.Block.Transactions[i]
+		tx.BlockNumberDecimal = number
+		tx.BlockTime = blockTime
+		tx.BlobTransaction = tx.IsBlobTransaction()
+		if tx.TransactionIndex == "" {
+			// The position in the block, for the nodes omitting it
+			tx.TransactionIndex = fmt.Sprintf("0x%x", i)
+		}
+		if tx.BlockHash == "" {
+			tx.BlockHash = block.Block.Hash
+		}
+		tx.EventID = TransactionEventID(p.eventChainID(), *tx)
+	}
+	return nil
+}
+
+// filterStage keeps the transactions of the subscribed addresses and resolves the tracked pending ones. The
+// addresses proposed by the matcher are verified against the subscribed set, removing the false positives of
+// the probabilistic ones. The self-transfers and the zero-value transactions are dropped in the skip mode, see
+// WithSelfTransfers.
+func (p *EthParser) filterStage(ctx context.Context, block *BlockContext) error {
+	matcher := block.Matcher
+	if matcher == nil {
+		matcher = exactMatcher(block.Subscribed)
+	}
+	stats := MatchingStats{BlocksScanned: 1, TransactionsExamined: len(block.Block.Transactions), BytesDownloaded: int64(len(block.Raw))}
+	for _, tx := range block.Block.Transactions {
+		if p.trackPending && block.Subscribed[tx.From] {
+			p.resolvePendingTransaction(tx)
+		}
+		if p.transactionHandling(tx) == TransactionsSkip {
+			continue
+		}
+		matched := false
+		for _, address := range matcher.Match(tx) {
+			stats.Candidates++
+			if !block.Subscribed[address] {
+				stats.FalsePositives++
+				continue
+			}
+			block.Matches[address] = append(block.Matches[address], tx)
+			matched = true
+		}
+		if matched {
+			stats.TransactionsMatched++
+		}
+	}
+	p.recordMatching(stats)
+	return nil
+}
+
+// enrichStage annotates the matched transactions with their price
+func (p *EthParser) enrichStage(ctx context.Context, block *BlockContext) error {
+	annotatePrice := p.priceAnnotator(block.Block, block.Number)
+	for _, transactions := range block.Matches {
+		for i := range transactions {
+			annotatePrice(&transactions[i])
+		}
+	}
+	return nil
+}
+
+// storeStage saves all the matches of the block, together with their outbox events, in a single storage transaction
+func (p *EthParser) storeStage(ctx context.Context, block *BlockContext) error {
+	recovering := p.recoveryNotify != RecoveryNotifyDeliver && p.recoveringBlock(block.Number)
+	discovered := p.discoveryBlock(block.Number)
+	return p.recordStorageWrite(p.storage.WithTx(func(tx StorageTx) error {
+		for address, transactions := range block.Matches {
+			for i := range transactions {
+				transactions[i].DiscoveredBlock = discovered
+			}
+			if recovering && p.recoveryNotify == RecoveryNotifyHistorical {
+				for i := range transactions {
+					transactions[i].Historical = true
+				}
+			}
+			if err := tx.SaveTransactions(address, transactions); err != nil {
+				return fmt.Errorf("saving transactions for address %s: %w", address, err)
+			}
+			if recovering && p.recoveryNotify == RecoveryNotifySuppress {
+				continue
+			}
+			notified := p.notifiedTransactions(transactions)
+			if len(notified) == 0 {
+				continue
+			}
+			event := OutboxEvent{
+				ID:           outboxEventID(block.Number, address),
+				Address:      address,
+				BlockNumber:  block.Number,
+				Transactions: notified,
+			}
+			if err := tx.AddOutboxEvent(event); err != nil {
+				return fmt.Errorf("adding outbox event for address %s: %w", address, err)
+			}
+		}
+		return nil
+	}))
+}
+
+// notifyStage delivers the outbox events of the block, the failed deliveries are retried by the next cycles
+func (p *EthParser) notifyStage(ctx context.Context, block *BlockContext) error {
+	if len(block.Matches) > 0 {
+		p.dispatchOutbox()
+	}
+	return nil
+}
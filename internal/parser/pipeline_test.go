@@ -0,0 +1,112 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestEthParserPipelineCustomStages(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{
+		Number: "0x1",
+		Transactions: []parser.Transaction{
+			{Hash: "0xa1", From: "0x1", To: "0x9", Value: "100"},
+			{Hash: "0xa2", From: "0x1", To: "0x9", Value: "0"},
+		},
+	})
+	mockBlockchain.AddBlock(2, parser.Block{
+		Number:       "0x2",
+		Transactions: []parser.Transaction{{Hash: "0xa3", From: "0x1", To: "0x9", Value: "200"}},
+	})
+	storage := NewMockStorage()
+
+	// Drop the zero value transactions after the filter, and fail the enrich stage, which must not stop the block
+	dropZeroValue := parser.StageFunc(func(ctx context.Context, block *parser.BlockContext) error {
+		for address, transactions := range block.Matches {
+			kept := transactions[:0]
+			for _, tx := range transactions {
+				if tx.Value != "0" {
+					kept = append(kept, tx)
+				}
+			}
+			block.Matches[address] = kept
+		}
+		return nil
+	})
+	failingStage := parser.StageFunc(func(ctx context.Context, block *parser.BlockContext) error {
+		if block.Number == 2 {
+			panic("broken enricher")
+		}
+		return errors.New("price unavailable")
+	})
+	configure := func(stages []parser.PipelineStage) []parser.PipelineStage {
+		filter := parser.StageIndex(stages, parser.StageFilter)
+		stages = append(stages[:filter+1], append([]parser.PipelineStage{{Name: "drop-zero-value", Stage: dropZeroValue}}, stages[filter+1:]...)...)
+		stages[parser.StageIndex(stages, parser.StageEnrich)].Stage = failingStage
+		return stages
+	}
+
+	ethParser := parser.NewEthParser(ctx, storage, 1, NewMockClient(mockBlockchain), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithPipeline(configure))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	transactions := ethParser.GetTransactions("0x1")
+	if len(transactions) != 2 || transactions[0].Hash != "0xa1" || transactions[1].Hash != "0xa3" {
+		t.Fatalf("Expected the non-zero transactions to be stored, got %v", transactions)
+	}
+
+	stats := map[string]parser.StageStats{}
+	for _, stage := range ethParser.PipelineStats() {
+		stats[stage.Name] = stage
+	}
+	if stats["drop-zero-value"].Processed != 2 {
+		t.Errorf("Expected the custom stage to process 2 blocks, got %+v", stats["drop-zero-value"])
+	}
+	if enrich := stats[parser.StageEnrich]; enrich.Errors != 2 || enrich.Panics != 1 {
+		t.Errorf("Expected 2 errors and 1 panic on the enrich stage, got %+v", enrich)
+	}
+	if store := stats[parser.StageStore]; store.Processed != 2 || store.Errors != 0 {
+		t.Errorf("Expected the store stage to run on both blocks, got %+v", store)
+	}
+}
+
+func TestEthParserPipelineSkipsBlockOnFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{
+		Number:       "0x1",
+		Transactions: []parser.Transaction{{Hash: "0xa1", From: "0x1", To: "0x9", Value: "100"}},
+	})
+
+	configure := func(stages []parser.PipelineStage) []parser.PipelineStage {
+		stages[parser.StageIndex(stages, parser.StageFilter)].Stage = parser.StageFunc(func(ctx context.Context, block *parser.BlockContext) error {
+			return errors.New("filter unavailable")
+		})
+		return stages
+	}
+
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithPipeline(configure))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	if transactions := ethParser.GetTransactions("0x1"); len(transactions) != 0 {
+		t.Fatalf("The block must be skipped when a stage with the skip policy fails, got %v", transactions)
+	}
+	for _, stage := range ethParser.PipelineStats() {
+		if stage.Name == parser.StageStore && stage.Processed != 0 {
+			t.Errorf("The store stage must not run after a failed filter, got %+v", stage)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package parser
+
+import "encoding/json"
+
+// prefetchedBlock is a block downloaded ahead of its processing, see WithPrefetch
+type prefetchedBlock struct {
+	block Block
+	raw   json.RawMessage
+	err   error
+}
+
+// prefetcher downloads the blocks of a fetch cycle in the background with several workers, while the pipeline
+// processes the previous ones, and hands them over in order, holding at most the prefetch depth of downloaded
+// blocks
+type prefetcher struct {
+	blocks chan prefetchedBlock
+	done   chan struct{}
+}
+
+// startPrefetch starts downloading the blocks from..to, up to workers at once
+func (p *EthParser) startPrefetch(from int, to int, depth int, workers int) *prefetcher {
+	prefetch := &prefetcher{blocks: make(chan prefetchedBlock, depth), done: make(chan struct{})}
+	// The downloads in flight, in block order, at most workers of them
+	downloads := make(chan chan prefetchedBlock, max(workers, 1)-1)
+	go func() {
+		defer close(downloads)
+		for number := from; number <= to; number++ {
+			download := make(chan prefetchedBlock, 1)
+			select {
+			case downloads <- download:
+			case <-prefetch.done:
+				return
+			}
+			go func(number int) {
+				block, raw, err := p.getBlockByNumber(number)
+				download <- prefetchedBlock{block: block, raw: raw, err: err}
+			}(number)
+		}
+	}()
+	go func() {
+		defer close(prefetch.blocks)
+		for download := range downloads {
+			select {
+			case prefetch.blocks <- <-download:
+			case <-prefetch.done:
+				return
+			}
+		}
+	}()
+	return prefetch
+}
+
+// next returns the next downloaded block, waiting for it, nil when the prefetcher stopped
+func (f *prefetcher) next() *prefetchedBlock {
+	fetched, ok := <-f.blocks
+	if !ok {
+		return nil
+	}
+	return &fetched
+}
+
+// stop ends the downloads, the one in flight is discarded
+func (f *prefetcher) stop() {
+	close(f.done)
+}
+
+// takePrefetched returns the block downloaded ahead for the fetch stage, false when there's none or its
+// download failed. It's consumed once: a retry of the stage fetches the block again.
+func (p *EthParser) takePrefetched(block *BlockContext) (prefetchedBlock, bool) {
+	prefetched := block.prefetched
+	block.prefetched = nil
+	if prefetched == nil || prefetched.err != nil {
+		return prefetchedBlock{}, false
+	}
+	p.mu.Lock()
+	p.backpressure.PrefetchedBlocks++
+	p.mu.Unlock()
+	return *prefetched, true
+}
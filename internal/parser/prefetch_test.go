@@ -0,0 +1,80 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockRequestClient counts the block requests and fails the first request of some blocks
+type blockRequestClient struct {
+	*MockClient
+	requests map[string]int
+	failures map[string]bool
+	mu       sync.Mutex
+}
+
+func (c *blockRequestClient) SendRequest(req parser.JSONRPCRequest) (parser.JSONRPCResponse, error) {
+	if req.Method == "eth_getBlockByNumber" {
+		number := req.Params[0].(string)
+		c.mu.Lock()
+		c.requests[number]++
+		fail := c.failures[number] && c.requests[number] == 1
+		c.mu.Unlock()
+		if fail {
+			return parser.JSONRPCResponse{}, errors.New("connection reset")
+		}
+	}
+	return c.MockClient.SendRequest(req)
+}
+
+func (c *blockRequestClient) requested(number string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requests[number]
+}
+
+func TestEthParserPrefetch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	for i := 1; i <= 5; i++ {
+		mockBlockchain.AddBlock(i, parser.Block{Number: fmt.Sprintf("0x%x", i), Transactions: []parser.Transaction{
+			{Hash: fmt.Sprintf("0xa%d", i), From: "0x1", To: "0x2", Value: "0x1"},
+		}})
+	}
+	client := &blockRequestClient{MockClient: NewMockClient(mockBlockchain), requests: make(map[string]int), failures: map[string]bool{"0x3": true}}
+	var processed []string
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, client, func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithPrefetch(2),
+		parser.WithBlockProcessor("overlap", parser.BlockProcessorFunc(func(ctx context.Context, block parser.Block) error {
+			if block.Number == "0x1" {
+				// The next blocks are downloaded while the first one is processed
+				waitUntil(t, func() bool { return client.requested("0x2") == 1 && client.requested("0x3") == 1 })
+			}
+			processed = append(processed, block.Number)
+			return nil
+		})))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	if fmt.Sprint(processed) != "[0x1 0x2 0x3 0x4 0x5]" {
+		t.Fatalf("Expected the blocks processed in order, got %v", processed)
+	}
+	if transactions := ethParser.GetTransactions("0x1"); len(transactions) != 5 {
+		t.Fatalf("Expected the transactions of all the blocks, got %+v", transactions)
+	}
+	// The failed download of block 3 is fetched again by the fetch stage
+	if client.requested("0x3") != 2 || client.requested("0x4") != 1 {
+		t.Errorf("Expected block 3 to be fetched twice and the others once, got %v", client.requests)
+	}
+	if stats := ethParser.BackpressureStats(); stats.PrefetchedBlocks != 4 {
+		t.Errorf("Expected 4 prefetched blocks, got %+v", stats)
+	}
+}
@@ -0,0 +1,258 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AssetETH is the asset of the native transaction values
+const AssetETH = "ETH"
+
+// PriceProvider returns historical USD prices. Assets are ticker symbols (ETH, USDC, ...), so token
+// prices can be looked up with the same provider as the native currency.
+type PriceProvider interface {
+	// PriceUSD returns the USD price of one unit of the asset at the given block
+	PriceUSD(asset string, blockNumber int, blockTime time.Time) (*big.Rat, error)
+}
+
+// weiPerEther is the number of wei in one ether
+var weiPerEther = new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil))
+
+// priceAnnotator returns a function setting the ETH/USD price of a block and the USD value on the
+// transactions of that block, and for the token transfers with the symbol and decimals of their token the
+// token/USD price and the USD value of the transferred amount. The prices are only looked up for blocks with
+// matched transactions, once per asset, and a lookup failure leaves the transactions without price rather
+// than delaying their processing.
+func (p *EthParser) priceAnnotator(block Block, blockNumber int) func(tx *Transaction) {
+	if p.prices == nil {
+		return func(*Transaction) {}
+	}
+
+	var blockTime time.Time
+	timed := false
+	prices := make(map[string]*big.Rat)
+	price := func(asset string) *big.Rat {
+		if price, ok := prices[asset]; ok {
+			return price
+		}
+		if !timed {
+			timed = true
+			var err error
+			if blockTime, err = blockTimestamp(block); err != nil {
+				log.Printf("Error parsing timestamp of block %d: %v\n", blockNumber, err)
+			}
+		}
+		if blockTime.IsZero() {
+			return nil
+		}
+		price, err := p.prices.PriceUSD(asset, blockNumber, blockTime)
+		if err != nil {
+			log.Printf("Error fetching %s price of block %d: %v\n", asset, blockNumber, err)
+			price = nil
+		}
+		prices[asset] = price
+		return price
+	}
+
+	return func(tx *Transaction) {
+		if ethPrice := price(AssetETH); ethPrice != nil {
+			tx.PriceUSD = ethPrice.FloatString(8)
+			if value, ok := parseQuantity(tx.Value); ok {
+				valueUSD := new(big.Rat).Mul(new(big.Rat).SetInt(value), ethPrice)
+				tx.ValueUSD = valueUSD.Quo(valueUSD, weiPerEther).FloatString(2)
+			}
+		}
+
+		if tx.Token == nil || tx.Token.Symbol == "" || tx.Token.Decimals == nil {
+			return
+		}
+		amount, ok := tokenTransferAmount(tx.Input)
+		if !ok {
+			return
+		}
+		tokenPrice := price(tx.Token.Symbol)
+		if tokenPrice == nil {
+			return
+		}
+		tx.TokenPriceUSD = tokenPrice.FloatString(8)
+		unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(*tx.Token.Decimals)), nil)
+		valueUSD := new(big.Rat).Mul(new(big.Rat).SetInt(amount), tokenPrice)
+		tx.TokenValueUSD = valueUSD.Quo(valueUSD, new(big.Rat).SetInt(unit)).FloatString(2)
+	}
+}
+
+// blockTimestamp returns the time of a block from its hex encoded timestamp
+func blockTimestamp(block Block) (time.Time, error) {
+	seconds, err := strconv.ParseInt(trimHexPrefix(block.Timestamp), 16, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// CoinGeckoPriceProvider returns the daily historical prices of the CoinGecko API
+type CoinGeckoPriceProvider struct {
+	apiKey  string
+	coinIDs map[string]string
+	client  *http.Client
+	cache   map[string]*big.Rat
+	mu      sync.Mutex
+}
+
+// NewCoinGeckoPriceProvider creates a CoinGeckoPriceProvider. coinIDs maps the asset symbols to the CoinGecko
+// coin IDs, ETH is always mapped to "ethereum". apiKey is the demo API key, optional.
+func NewCoinGeckoPriceProvider(apiKey string, coinIDs map[string]string) *CoinGeckoPriceProvider {
+	ids := map[string]string{AssetETH: "ethereum"}
+	for asset, id := range coinIDs {
+		ids[asset] = id
+	}
+	return &CoinGeckoPriceProvider{
+		apiKey:  apiKey,
+		coinIDs: ids,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		cache:   make(map[string]*big.Rat),
+	}
+}
+
+// PriceUSD returns the price of the asset on the day of the block, cached per asset and day
+func (c *CoinGeckoPriceProvider) PriceUSD(asset string, blockNumber int, blockTime time.Time) (*big.Rat, error) {
+	id, ok := c.coinIDs[asset]
+	if !ok {
+		return nil, fmt.Errorf("no CoinGecko coin ID for asset %s", asset)
+	}
+	date := blockTime.UTC().Format("02-01-2006")
+	key := id + "/" + date
+
+	c.mu.Lock()
+	price, cached := c.cache[key]
+	c.mu.Unlock()
+	if cached {
+		return price, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet,
+		fmt.Sprintf("https://api.coingecko.com/api/v3/coins/%s/history?date=%s&localization=false", id, date), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.apiKey != "" {
+		req.Header.Set("x-cg-demo-api-key", c.apiKey)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CoinGecko responded %s", resp.Status)
+	}
+
+	var history struct {
+		MarketData struct {
+			CurrentPrice map[string]json.Number `json:"current_price"`
+		} `json:"market_data"`
+	}
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&history); err != nil {
+		return nil, err
+	}
+	usd, ok := history.MarketData.CurrentPrice["usd"]
+	if !ok {
+		return nil, fmt.Errorf("no USD price for %s on %s", id, date)
+	}
+	price, ok = new(big.Rat).SetString(usd.String())
+	if !ok {
+		return nil, fmt.Errorf("invalid USD price %q", usd)
+	}
+
+	c.mu.Lock()
+	c.cache[key] = price
+	c.mu.Unlock()
+	return price, nil
+}
+
+// Chainlink price feed selectors
+const (
+	chainlinkLatestRoundData = "0xfeaf968c"
+	chainlinkDecimals        = "0x313ce567"
+)
+
+// ChainlinkETHUSDFeed is the address of the Chainlink ETH/USD price feed on mainnet
+const ChainlinkETHUSDFeed = "0x5f4eC3Df9cbd43714FE2740f5E3616155c5b8419"
+
+// ChainlinkPriceProvider reads the on-chain Chainlink price feeds as of the block, through the node
+type ChainlinkPriceProvider struct {
+	client   JsonRpcClient
+	feeds    map[string]string
+	decimals map[string]int
+	mu       sync.Mutex
+}
+
+// NewChainlinkPriceProvider creates a ChainlinkPriceProvider. feeds maps the asset symbols to the addresses
+// of their USD price feeds, ETH is mapped to ChainlinkETHUSDFeed unless overridden.
+func NewChainlinkPriceProvider(client JsonRpcClient, feeds map[string]string) *ChainlinkPriceProvider {
+	addresses := map[string]string{AssetETH: ChainlinkETHUSDFeed}
+	for asset, feed := range feeds {
+		addresses[asset] = feed
+	}
+	return &ChainlinkPriceProvider{client: client, feeds: addresses, decimals: make(map[string]int)}
+}
+
+// PriceUSD returns the latest answer of the asset feed at the block
+func (c *ChainlinkPriceProvider) PriceUSD(asset string, blockNumber int, blockTime time.Time) (*big.Rat, error) {
+	feed, ok := c.feeds[asset]
+	if !ok {
+		return nil, fmt.Errorf("no Chainlink feed for asset %s", asset)
+	}
+	block := fmt.Sprintf("0x%x", blockNumber)
+
+	c.mu.Lock()
+	decimals, known := c.decimals[feed]
+	c.mu.Unlock()
+	if !known {
+		result, err := c.call(feed, chainlinkDecimals, block)
+		if err != nil {
+			return nil, fmt.Errorf("reading decimals of feed %s: %w", feed, err)
+		}
+		decimals = int(result.Int64())
+		c.mu.Lock()
+		c.decimals[feed] = decimals
+		c.mu.Unlock()
+	}
+
+	answer, err := c.call(feed, chainlinkLatestRoundData, block)
+	if err != nil {
+		return nil, fmt.Errorf("reading feed %s: %w", feed, err)
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	return new(big.Rat).SetFrac(answer, scale), nil
+}
+
+// call runs an eth_call on the feed and returns the answer word: the first word of the result for
+// decimals(), the second one (answer) for latestRoundData()
+func (c *ChainlinkPriceProvider) call(feed string, selector string, block string) (*big.Int, error) {
+	result, err := EthCall(c.client, feed, selector, block)
+	if err != nil {
+		return nil, err
+	}
+	data := trimHexPrefix(result)
+	word := 0
+	if selector == chainlinkLatestRoundData {
+		word = 1
+	}
+	if len(data) < (word+1)*64 {
+		return nil, fmt.Errorf("short result %q", result)
+	}
+	value, ok := new(big.Int).SetString(data[word*64:(word+1)*64], 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid result %q", result)
+	}
+	return value, nil
+}
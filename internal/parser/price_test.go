@@ -0,0 +1,185 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixedPriceProvider returns the same price for every block and records the looked up blocks
+type fixedPriceProvider struct {
+	price  *big.Rat
+	blocks []int
+	times  []time.Time
+}
+
+func (f *fixedPriceProvider) PriceUSD(asset string, blockNumber int, blockTime time.Time) (*big.Rat, error) {
+	f.blocks = append(f.blocks, blockNumber)
+	f.times = append(f.times, blockTime)
+	return f.price, nil
+}
+
+func TestPriceEnrichment(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{
+		Number:    "0x1",
+		Timestamp: "0x65000000",
+		Transactions: []parser.Transaction{
+			// 1.5 ETH
+			{Hash: "0xabc", From: "0x1", To: "0x2", Value: "0x14d1120d7b160000"},
+			{Hash: "0xdef", From: "0x1", To: "0x3", Value: "0x0"},
+		},
+	})
+
+	prices := &fixedPriceProvider{price: big.NewRat(250050, 100)}
+	storage := NewMockStorage()
+	ethParser := parser.NewEthParser(ctx, storage, 1, NewMockClient(mockBlockchain),
+		func(string, []parser.Transaction) {}, parser.WithClock(parser.NewManualClock(time.Now())),
+		parser.WithPriceProvider(prices))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+
+	ethParser.ProcessNextCycle()
+
+	if len(prices.blocks) != 1 || prices.blocks[0] != 1 || !prices.times[0].Equal(time.Unix(0x65000000, 0)) {
+		t.Fatalf("The price must be looked up once per block at block time, got %v %v", prices.blocks, prices.times)
+	}
+	transactions := storage.GetTransactions("0x1")
+	if len(transactions) != 2 {
+		t.Fatalf("Unexpected transactions: %v", transactions)
+	}
+	if transactions[0].PriceUSD != "2500.50000000" || transactions[0].ValueUSD != "3750.75" {
+		t.Fatalf("Unexpected price annotation: %s %s", transactions[0].PriceUSD, transactions[0].ValueUSD)
+	}
+	if transactions[1].ValueUSD != "0.00" {
+		t.Fatalf("Unexpected value of a zero value transaction: %s", transactions[1].ValueUSD)
+	}
+}
+
+// assetPriceProvider returns the prices of the assets it knows and records the looked up assets
+type assetPriceProvider struct {
+	prices map[string]*big.Rat
+	assets []string
+}
+
+func (a *assetPriceProvider) PriceUSD(asset string, blockNumber int, blockTime time.Time) (*big.Rat, error) {
+	a.assets = append(a.assets, asset)
+	if price, ok := a.prices[asset]; ok {
+		return price, nil
+	}
+	return nil, fmt.Errorf("no price for %s", asset)
+}
+
+func TestTokenPriceEnrichment(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 1234.5 USDC
+	transfer := "0xa9059cbb" + strings.Repeat("0", 64) + fmt.Sprintf("%064x", 1234500000)
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1", Timestamp: "0x65000000", Transactions: []parser.Transaction{
+		{Hash: "0xa1", From: "0x1", To: "0xtoken", Value: "0x0", Input: transfer},
+		{Hash: "0xa2", From: "0x1", To: "0xtoken", Value: "0x0", Input: transfer},
+		{Hash: "0xa3", From: "0x1", To: "0x2", Value: "0x14d1120d7b160000", Input: "0x"},
+	}})
+	client := &metadataClient{MockClient: NewMockClient(mockBlockchain), results: map[string]string{
+		"0xtoken0x06fdde03": abiString("USD Coin"),
+		"0xtoken0x95d89b41": abiString("USDC"),
+		"0xtoken0x313ce567": fmt.Sprintf("0x%064x", 6),
+	}}
+	prices := &assetPriceProvider{prices: map[string]*big.Rat{
+		parser.AssetETH: big.NewRat(250050, 100),
+		"USDC":          big.NewRat(9998, 10000),
+	}}
+	storage := NewMockStorage()
+	ethParser := parser.NewEthParser(ctx, storage, 1, client, func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithClassifier(parser.NewHeuristicClassifier(nil)),
+		parser.WithTokenMetadata(), parser.WithPriceProvider(prices))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	// Each asset is looked up once per block
+	if len(prices.assets) != 2 || prices.assets[0] != parser.AssetETH || prices.assets[1] != "USDC" {
+		t.Fatalf("Expected one lookup of ETH and USDC, got %v", prices.assets)
+	}
+	transactions := storage.GetTransactions("0x1")
+	if len(transactions) != 3 {
+		t.Fatalf("Unexpected transactions: %v", transactions)
+	}
+	for _, tx := range transactions {
+		if tx.Hash == "0xa3" {
+			if tx.TokenPriceUSD != "" || tx.TokenValueUSD != "" || tx.ValueUSD != "3750.75" {
+				t.Errorf("Unexpected price annotation of an ETH transfer: %+v", tx)
+			}
+			continue
+		}
+		if tx.PriceUSD != "2500.50000000" || tx.TokenPriceUSD != "0.99980000" || tx.TokenValueUSD != "1234.25" {
+			t.Errorf("Unexpected price annotation of %s: %s %s %s", tx.Hash, tx.PriceUSD, tx.TokenPriceUSD, tx.TokenValueUSD)
+		}
+	}
+
+	// A token without price is left without token valuation
+	prices.prices = map[string]*big.Rat{parser.AssetETH: big.NewRat(250050, 100)}
+	mockBlockchain.AddBlock(2, parser.Block{Number: "0x2", Timestamp: "0x65000010", Transactions: []parser.Transaction{
+		{Hash: "0xa4", From: "0x1", To: "0xtoken", Value: "0x0", Input: transfer},
+	}})
+	ethParser.ProcessNextCycle()
+	transactions = storage.GetTransactions("0x1")
+	if len(transactions) != 4 {
+		t.Fatalf("Unexpected transactions: %v", transactions)
+	}
+	for _, tx := range transactions {
+		if tx.Hash == "0xa4" && (tx.PriceUSD != "2500.50000000" || tx.TokenPriceUSD != "" || tx.TokenValueUSD != "") {
+			t.Errorf("Unexpected price annotation of a token without price: %+v", tx)
+		}
+	}
+}
+
+// feedClient answers the eth_call requests of a Chainlink feed
+type feedClient struct {
+	decimals int64
+	answer   int64
+	blocks   []string
+}
+
+func (f *feedClient) SendRequest(req parser.JSONRPCRequest) (parser.JSONRPCResponse, error) {
+	if req.Method != "eth_call" {
+		return parser.JSONRPCResponse{}, fmt.Errorf("unexpected method %s", req.Method)
+	}
+	f.blocks = append(f.blocks, req.Params[1].(string))
+	call := req.Params[0].(map[string]string)
+	word := func(v int64) string { return fmt.Sprintf("%064x", v) }
+	if call["data"] == "0x313ce567" {
+		return parser.JSONRPCResponse{Result: "0x" + word(f.decimals)}, nil
+	}
+	return parser.JSONRPCResponse{Result: "0x" + word(7) + word(f.answer) + strings.Repeat("0", 64*3)}, nil
+}
+
+func TestChainlinkPriceProvider(t *testing.T) {
+	client := &feedClient{decimals: 8, answer: 250050000000}
+	provider := parser.NewChainlinkPriceProvider(client, nil)
+
+	price, err := provider.PriceUSD(parser.AssetETH, 0x10, time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if price.FloatString(2) != "2500.50" {
+		t.Fatalf("Unexpected price %s", price.FloatString(2))
+	}
+	for _, block := range client.blocks {
+		if block != "0x10" {
+			t.Fatalf("Feed must be read as of the block, got %s", block)
+		}
+	}
+	if _, err := provider.PriceUSD("UNKNOWN", 0x10, time.Now()); err == nil {
+		t.Fatal("Expected an error for an asset without feed")
+	}
+}
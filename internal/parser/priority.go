@@ -0,0 +1,82 @@
+package parser
+
+import "sort"
+
+// Priorities of the subscriptions, an empty priority is normal
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+// ValidPriority reports whether the priority is one of the subscription priorities, or empty
+func ValidPriority(priority string) bool {
+	switch priority {
+	case "", PriorityHigh, PriorityNormal, PriorityLow:
+		return true
+	}
+	return false
+}
+
+// priorityRank orders the priorities, the lowest rank is notified first
+func priorityRank(priority string) int {
+	switch priority {
+	case PriorityHigh:
+		return 0
+	case PriorityLow:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// priorityRanks returns the rank of the subscription of each address of the events, the addresses that
+// aren't subscribed anymore are normal
+func (p *EthParser) priorityRanks(events []OutboxEvent) map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ranks := make(map[string]int)
+	for _, event := range events {
+		if _, ok := ranks[event.Address]; ok {
+			continue
+		}
+		rank := priorityRank("")
+		if subscription, exists := p.subscriptions[event.Address]; exists {
+			rank = priorityRank(subscription.Priority)
+		}
+		ranks[event.Address] = rank
+	}
+	return ranks
+}
+
+// orderByPriority orders the consecutive events of a block by the priority of their address, high first. The
+// blocks keep their order, and so do the events of an address, whose priority is the same in all of them.
+func orderByPriority(events []OutboxEvent, ranks map[string]int) {
+	for start := 0; start < len(events); {
+		end := start + 1
+		for end < len(events) && events[end].BlockNumber == events[start].BlockNumber {
+			end++
+		}
+		block := events[start:end]
+		sort.SliceStable(block, func(i, j int) bool { return ranks[block[i].Address] < ranks[block[j].Address] })
+		start = end
+	}
+}
+
+// deferringLowPriority reports whether the notifications of the low priority subscriptions wait in the
+// outbox: while the fetch loop catches up with a backlog, they're delivered once it's done with
+func (p *EthParser) deferringLowPriority() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.catchingUp
+}
+
+// setPriority sets the priority of a subscribed address, the tenants share the highest one of their
+// subscriptions
+func (p *EthParser) setPriority(address string, priority string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if subscription, exists := p.subscriptions[address]; exists {
+		subscription.Priority = priority
+	}
+}
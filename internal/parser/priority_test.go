@@ -0,0 +1,62 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEthParserSubscriptionPriorities(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	blockchain := NewMockBlockchain()
+	for i := 1; i <= 4; i++ {
+		blockchain.AddBlock(i, parser.Block{Number: fmt.Sprintf("0x%x", i), Transactions: []parser.Transaction{
+			{Hash: fmt.Sprintf("0xa%d", i), From: "0xlow", To: "0x0"},
+			{Hash: fmt.Sprintf("0xb%d", i), From: "0xnormal", To: "0x0"},
+			{Hash: fmt.Sprintf("0xc%d", i), From: "0xhigh", To: "0x0"},
+		}})
+	}
+	var notified []string
+	deliver := func(address string, transactions []parser.Transaction) error {
+		notified = append(notified, transactions[0].Hash)
+		return nil
+	}
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(blockchain), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithDelivery(deliver, parser.DeliveryPolicy{}), parser.WithBackpressure(2, 0))
+	defer ethParser.WaitForShutdown()
+	ethParser.SubscribeWith(parser.Subscription{Address: "0xlow", Priority: parser.PriorityLow})
+	ethParser.SubscribeWith(parser.Subscription{Address: "0xnormal"})
+	ethParser.SubscribeWith(parser.Subscription{Address: "0xhigh", Priority: parser.PriorityHigh})
+
+	// The first cycle leaves blocks behind, the low priority notifications wait
+	ethParser.ProcessNextCycle()
+	if expected := []string{"0xc1", "0xb1", "0xc2", "0xb2"}; !reflect.DeepEqual(notified, expected) {
+		t.Fatalf("Expected %v while catching up, got %v", expected, notified)
+	}
+
+	notified = nil
+	ethParser.ProcessNextCycle()
+	if expected := []string{"0xa1", "0xa2", "0xc3", "0xb3", "0xa3", "0xc4", "0xb4", "0xa4"}; !reflect.DeepEqual(notified, expected) {
+		t.Errorf("Expected %v once caught up, got %v", expected, notified)
+	}
+}
+
+func TestImportSubscriptionsPriority(t *testing.T) {
+	csv := "address,priority\n0x1,HIGH\n0x2,\n"
+	subscriptions, err := parser.ImportSubscriptions(strings.NewReader(csv), parser.SubscriptionFormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subscriptions[0].Priority != parser.PriorityHigh || subscriptions[1].Priority != "" {
+		t.Errorf("Unexpected priorities %+v", subscriptions)
+	}
+	if _, err := parser.ImportSubscriptions(strings.NewReader("address,priority\n0x1,urgent\n"), parser.SubscriptionFormatCSV); err == nil {
+		t.Error("Expected an error for an invalid priority")
+	}
+}
@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// BlockProcessor runs custom logic on every processed block (e.g. MEV detection, custom indexing),
+// in addition to the built-in address matching
+type BlockProcessor interface {
+	ProcessBlock(ctx context.Context, block Block) error
+}
+
+// BlockProcessorFunc adapts a function to the BlockProcessor interface
+type BlockProcessorFunc func(ctx context.Context, block Block) error
+
+// ProcessBlock calls f(ctx, block)
+func (f BlockProcessorFunc) ProcessBlock(ctx context.Context, block Block) error {
+	return f(ctx, block)
+}
+
+// BlockProcessorStats reports the activity of a registered BlockProcessor
+type BlockProcessorStats struct {
+	Name          string        `json:"name"`
+	Processed     int           `json:"processed"`
+	Errors        int           `json:"errors"`
+	Panics        int           `json:"panics"`
+	TotalDuration time.Duration `json:"totalDuration"`
+	LastError     string        `json:"lastError,omitempty"`
+}
+
+// registeredProcessor is a BlockProcessor with its stats
+type registeredProcessor struct {
+	processor BlockProcessor
+	stats     BlockProcessorStats
+}
+
+// RegisterBlockProcessor adds a BlockProcessor run, in registration order, on every processed block.
+// Processor errors and panics are isolated: they are logged and counted, and neither the other processors
+// nor the address matching are affected.
+func (p *EthParser) RegisterBlockProcessor(name string, processor BlockProcessor) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.processors = append(p.processors, &registeredProcessor{
+		processor: processor,
+		stats:     BlockProcessorStats{Name: name},
+	})
+}
+
+// BlockProcessorStats returns the stats of the registered block processors
+func (p *EthParser) BlockProcessorStats() []BlockProcessorStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make([]BlockProcessorStats, 0, len(p.processors))
+	for _, registered := range p.processors {
+		stats = append(stats, registered.stats)
+	}
+	return stats
+}
+
+// runBlockProcessors runs all the registered processors on a block
+func (p *EthParser) runBlockProcessors(block Block) {
+	p.mu.Lock()
+	processors := make([]*registeredProcessor, len(p.processors))
+	copy(processors, p.processors)
+	p.mu.Unlock()
+
+	for _, registered := range processors {
+		start := time.Now()
+		panicked, err := runBlockProcessor(p.ctx, registered.processor, block)
+		elapsed := time.Since(start)
+
+		p.mu.Lock()
+		registered.stats.Processed++
+		registered.stats.TotalDuration += elapsed
+		if err != nil {
+			registered.stats.Errors++
+			registered.stats.LastError = err.Error()
+		}
+		if panicked {
+			registered.stats.Panics++
+		}
+		p.mu.Unlock()
+
+		if err != nil {
+			log.Printf("Block processor %s failed on block %s: %v\n", registered.stats.Name, block.Number, err)
+		}
+	}
+}
+
+// runBlockProcessor calls a processor turning a panic into an error
+func runBlockProcessor(ctx context.Context, processor BlockProcessor, block Block) (panicked bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked, err = true, fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return false, processor.ProcessBlock(ctx, block)
+}
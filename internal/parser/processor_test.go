@@ -0,0 +1,54 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestEthParserBlockProcessors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1"})
+	mockBlockchain.AddBlock(2, parser.Block{
+		Number:       "0x2",
+		Transactions: []parser.Transaction{{Hash: "0xabc", From: "0x1", To: "0x2", Value: "100"}},
+	})
+
+	var seen []string
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain),
+		func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())),
+		parser.WithBlockProcessor("panics", parser.BlockProcessorFunc(func(ctx context.Context, block parser.Block) error {
+			panic("boom")
+		})))
+	defer ethParser.WaitForShutdown()
+
+	ethParser.RegisterBlockProcessor("failing", parser.BlockProcessorFunc(func(ctx context.Context, block parser.Block) error {
+		return errors.New("failed")
+	}))
+	ethParser.RegisterBlockProcessor("recorder", parser.BlockProcessorFunc(func(ctx context.Context, block parser.Block) error {
+		seen = append(seen, block.Number)
+		return nil
+	}))
+
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	// Failing processors don't prevent the others nor the address matching from running
+	if len(seen) != 2 {
+		t.Fatalf("Unexpected blocks seen by the recorder: %v", seen)
+	}
+	if transactions := ethParser.GetTransactions("0x1"); len(transactions) != 1 {
+		t.Fatalf("Unexpected transactions for address 0x1: %v", transactions)
+	}
+
+	stats := ethParser.BlockProcessorStats()
+	if len(stats) != 3 || stats[0].Panics != 2 || stats[1].Errors != 2 || stats[2].Processed != 2 || stats[2].Errors != 0 {
+		t.Fatalf("Unexpected processor stats: %+v", stats)
+	}
+}
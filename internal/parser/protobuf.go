@@ -0,0 +1,393 @@
+package parser
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Wire types of the protobuf encoding of ethparser.proto, written without generated code so that the module
+// keeps no dependencies
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+	// protoTextOffset is the offset of the string field keeping a hex value that isn't canonical
+	protoTextOffset = 100
+)
+
+var errProtoTruncated = errors.New("truncated protobuf message")
+
+// protoWriter appends the fields of a protobuf message, the fields of zero value being omitted like proto3
+type protoWriter struct {
+	buf []byte
+}
+
+func (w *protoWriter) key(num int, wire int) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(num)<<3|uint64(wire))
+}
+
+// optionalVarint writes an integer field even when it's zero, for the optional fields
+func (w *protoWriter) optionalVarint(num int, v int64) {
+	w.key(num, protoVarint)
+	w.buf = binary.AppendUvarint(w.buf, uint64(v))
+}
+
+func (w *protoWriter) varint(num int, v int64) {
+	if v != 0 {
+		w.optionalVarint(num, v)
+	}
+}
+
+func (w *protoWriter) bool(num int, v bool) {
+	if v {
+		w.optionalVarint(num, 1)
+	}
+}
+
+func (w *protoWriter) bytes(num int, b []byte) {
+	w.key(num, protoBytes)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *protoWriter) string(num int, s string) {
+	if s != "" {
+		w.bytes(num, []byte(s))
+	}
+}
+
+// message writes an embedded message encoded by encode
+func (w *protoWriter) message(num int, encode func(w *protoWriter)) {
+	var inner protoWriter
+	encode(&inner)
+	w.bytes(num, inner.buf)
+}
+
+// hex writes a hex value as bytes, or as is in its text field when it isn't canonical
+func (w *protoWriter) hex(field protoHexField) {
+	if *field.value == "" {
+		return
+	}
+	decode := hexData
+	if field.quantity {
+		decode = hexQuantity
+	}
+	if b, ok := decode(*field.value); ok {
+		w.bytes(field.num, b)
+		return
+	}
+	w.string(field.num+protoTextOffset, *field.value)
+}
+
+func (w *protoWriter) fields(hexFields []protoHexField, stringFields []protoStringField) {
+	for _, field := range hexFields {
+		w.hex(field)
+	}
+	for _, field := range stringFields {
+		w.string(field.num, *field.value)
+	}
+}
+
+// protoValue is a field read from a protobuf message, varint or bytes depending on its wire type
+type protoValue struct {
+	varint uint64
+	bytes  []byte
+}
+
+// readProto calls field with the fields of a protobuf message in order, skipping the fixed size ones since
+// ethparser.proto has none
+func readProto(data []byte, field func(num int, value protoValue) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errProtoTruncated
+		}
+		data = data[n:]
+		var value protoValue
+		switch wire := key & 7; wire {
+		case protoVarint:
+			if value.varint, n = binary.Uvarint(data); n <= 0 {
+				return errProtoTruncated
+			}
+			data = data[n:]
+		case protoBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return errProtoTruncated
+			}
+			value.bytes, data = data[n:n+int(length)], data[n+int(length):]
+		case protoFixed64, protoFixed32:
+			size := 8
+			if wire == protoFixed32 {
+				size = 4
+			}
+			if len(data) < size {
+				return errProtoTruncated
+			}
+			data = data[size:]
+			continue
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", wire)
+		}
+		if err := field(int(key>>3), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// protoHexField is a hex value of the node encoded as bytes, data or quantity
+type protoHexField struct {
+	num      int
+	value    *string
+	quantity bool
+}
+
+type protoStringField struct {
+	num   int
+	value *string
+}
+
+// setField sets the field num read from a message, false when it isn't any of the fields
+func setField(hexFields []protoHexField, stringFields []protoStringField, num int, value protoValue) bool {
+	for _, field := range hexFields {
+		switch num {
+		case field.num:
+			if field.quantity {
+				*field.value = quantityHex(value.bytes)
+			} else {
+				*field.value = dataHex(value.bytes)
+			}
+			return true
+		case field.num + protoTextOffset:
+			*field.value = string(value.bytes)
+			return true
+		}
+	}
+	for _, field := range stringFields {
+		if num == field.num {
+			*field.value = string(value.bytes)
+			return true
+		}
+	}
+	return false
+}
+
+// readFields reads a message made of hex and string fields only
+func readFields(data []byte, hexFields []protoHexField, stringFields []protoStringField) error {
+	return readProto(data, func(num int, value protoValue) error {
+		setField(hexFields, stringFields, num, value)
+		return nil
+	})
+}
+
+// hexData returns the bytes of lowercase 0x prefixed hex data, false when it isn't canonical
+func hexData(s string) ([]byte, bool) {
+	digits, found := strings.CutPrefix(s, "0x")
+	if !found || len(digits)%2 != 0 || strings.ToLower(digits) != digits {
+		return nil, false
+	}
+	b, err := hex.DecodeString(digits)
+	return b, err == nil
+}
+
+// hexQuantity returns the big-endian bytes of a lowercase 0x prefixed quantity without leading zeros, empty
+// for zero, false when it isn't canonical
+func hexQuantity(s string) ([]byte, bool) {
+	digits, found := strings.CutPrefix(s, "0x")
+	if !found || digits == "" || strings.ToLower(digits) != digits || (digits[0] == '0' && digits != "0") {
+		return nil, false
+	}
+	if digits == "0" {
+		return []byte{}, true
+	}
+	if len(digits)%2 != 0 {
+		digits = "0" + digits
+	}
+	b, err := hex.DecodeString(digits)
+	return b, err == nil
+}
+
+func dataHex(b []byte) string {
+	return "0x" + hex.EncodeToString(b)
+}
+
+func quantityHex(b []byte) string {
+	digits := strings.TrimLeft(hex.EncodeToString(b), "0")
+	if digits == "" {
+		digits = "0"
+	}
+	return "0x" + digits
+}
+
+func transactionHexFields(tx *Transaction) []protoHexField {
+	return []protoHexField{
+		{1, &tx.Hash, false}, {2, &tx.From, false}, {3, &tx.To, false}, {4, &tx.Value, true},
+		{5, &tx.BlockNumber, true}, {7, &tx.Type, true}, {8, &tx.Nonce, true}, {9, &tx.TransactionIndex, true},
+		{10, &tx.BlockHash, false}, {11, &tx.Input, false}, {13, &tx.GasPrice, true}, {14, &tx.MaxFeePerGas, true},
+		{15, &tx.MaxPriorityFeePerGas, true}, {16, &tx.MaxFeePerBlobGas, true},
+	}
+}
+
+func transactionStringFields(tx *Transaction) []protoStringField {
+	return []protoStringField{
+		{12, &tx.EventID}, {19, &tx.PriceUSD}, {20, &tx.ValueUSD}, {21, &tx.ValueWei}, {22, &tx.ValueEth},
+		{23, &tx.ValueFormatted}, {24, &tx.FromLabel}, {25, &tx.ToLabel}, {27, &tx.Category}, {30, &tx.Direction},
+		{35, &tx.FromENS}, {36, &tx.ToENS}, {37, &tx.TokenPriceUSD}, {38, &tx.TokenValueUSD},
+	}
+}
+
+func linksFields(links *ExplorerLinks) []protoStringField {
+	return []protoStringField{{1, &links.Transaction}, {2, &links.From}, {3, &links.To}, {4, &links.Block}}
+}
+
+func screeningFlagFields(flag *ScreeningFlag) []protoStringField {
+	return []protoStringField{{1, &flag.Counterparty}, {2, &flag.Screener}, {3, &flag.Reason}}
+}
+
+func encodeProtoTransaction(w *protoWriter, tx Transaction) {
+	w.fields(transactionHexFields(&tx), transactionStringFields(&tx))
+	if tx.BlockTime != nil {
+		w.optionalVarint(6, tx.BlockTime.UnixNano())
+	}
+	canonical := true
+	for _, hash := range tx.BlobVersionedHashes {
+		if _, ok := hexData(hash); !ok {
+			canonical = false
+		}
+	}
+	for _, hash := range tx.BlobVersionedHashes {
+		if b, _ := hexData(hash); canonical {
+			w.bytes(17, b)
+		} else {
+			w.bytes(17+protoTextOffset, []byte(hash))
+		}
+	}
+	w.bool(18, tx.BlobTransaction)
+	if tx.Links != nil {
+		w.message(26, func(w *protoWriter) { w.fields(nil, linksFields(tx.Links)) })
+	}
+	if tx.Token != nil {
+		w.message(28, func(w *protoWriter) {
+			w.fields([]protoHexField{{1, &tx.Token.Address, false}}, []protoStringField{{2, &tx.Token.Name}, {3, &tx.Token.Symbol}})
+			if tx.Token.Decimals != nil {
+				w.optionalVarint(4, int64(*tx.Token.Decimals))
+			}
+		})
+	}
+	if tx.ScreeningFlag != nil {
+		w.message(29, func(w *protoWriter) { w.fields(nil, screeningFlagFields(tx.ScreeningFlag)) })
+	}
+	w.varint(31, int64(tx.DiscoveredBlock))
+	w.bool(32, tx.Historical)
+	w.bool(33, tx.Test)
+	if len(tx.Metadata) > 0 {
+		w.bytes(34, tx.Metadata)
+	}
+}
+
+func decodeProtoTransaction(data []byte, tx *Transaction) error {
+	hexFields, stringFields := transactionHexFields(tx), transactionStringFields(tx)
+	return readProto(data, func(num int, value protoValue) error {
+		if setField(hexFields, stringFields, num, value) {
+			return nil
+		}
+		switch num {
+		case 6:
+			blockTime := time.Unix(0, int64(value.varint)).UTC()
+			tx.BlockTime = &blockTime
+		case 17:
+			tx.BlobVersionedHashes = append(tx.BlobVersionedHashes, dataHex(value.bytes))
+		case 17 + protoTextOffset:
+			tx.BlobVersionedHashes = append(tx.BlobVersionedHashes, string(value.bytes))
+		case 18:
+			tx.BlobTransaction = value.varint != 0
+		case 26:
+			tx.Links = &ExplorerLinks{}
+			return readFields(value.bytes, nil, linksFields(tx.Links))
+		case 28:
+			tx.Token = &TokenMetadata{}
+			return readProto(value.bytes, func(num int, value protoValue) error {
+				if num == 4 {
+					decimals := int(int64(value.varint))
+					tx.Token.Decimals = &decimals
+				}
+				setField([]protoHexField{{1, &tx.Token.Address, false}}, []protoStringField{{2, &tx.Token.Name}, {3, &tx.Token.Symbol}}, num, value)
+				return nil
+			})
+		case 29:
+			tx.ScreeningFlag = &ScreeningFlag{}
+			return readFields(value.bytes, nil, screeningFlagFields(tx.ScreeningFlag))
+		case 31:
+			tx.DiscoveredBlock = int(int64(value.varint))
+		case 32:
+			tx.Historical = value.varint != 0
+		case 33:
+			tx.Test = value.varint != 0
+		case 34:
+			tx.Metadata = append(json.RawMessage(nil), value.bytes...)
+		}
+		return nil
+	})
+}
+
+func blockHexFields(block *Block) []protoHexField {
+	return []protoHexField{
+		{1, &block.Number, true}, {2, &block.Hash, false}, {3, &block.Timestamp, true},
+		{5, &block.BlobGasUsed, true}, {6, &block.ExcessBlobGas, true},
+	}
+}
+
+func encodeProtoBlock(w *protoWriter, block Block) {
+	w.fields(blockHexFields(&block), nil)
+	for _, tx := range block.Transactions {
+		w.message(4, func(w *protoWriter) { encodeProtoTransaction(w, tx) })
+	}
+}
+
+func decodeProtoBlock(data []byte, block *Block) error {
+	hexFields := blockHexFields(block)
+	return readProto(data, func(num int, value protoValue) error {
+		if num == 4 {
+			var tx Transaction
+			if err := decodeProtoTransaction(value.bytes, &tx); err != nil {
+				return err
+			}
+			block.Transactions = append(block.Transactions, tx)
+		}
+		setField(hexFields, nil, num, value)
+		return nil
+	})
+}
+
+func encodeProtoOutboxEvent(w *protoWriter, event OutboxEvent) {
+	w.fields(nil, []protoStringField{{1, &event.ID}, {2, &event.Address}})
+	w.varint(3, int64(event.BlockNumber))
+	for _, tx := range event.Transactions {
+		w.message(4, func(w *protoWriter) { encodeProtoTransaction(w, tx) })
+	}
+}
+
+func decodeProtoOutboxEvent(data []byte, event *OutboxEvent) error {
+	stringFields := []protoStringField{{1, &event.ID}, {2, &event.Address}}
+	return readProto(data, func(num int, value protoValue) error {
+		switch num {
+		case 3:
+			event.BlockNumber = int(int64(value.varint))
+		case 4:
+			var tx Transaction
+			if err := decodeProtoTransaction(value.bytes, &tx); err != nil {
+				return err
+			}
+			event.Transactions = append(event.Transactions, tx)
+		}
+		setField(nil, stringFields, num, value)
+		return nil
+	})
+}
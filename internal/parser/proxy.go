@@ -0,0 +1,110 @@
+package parser
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ErrMethodNotAllowed is returned by ProxyRequest for a method outside the proxy whitelist
+var ErrMethodNotAllowed = errors.New("method not allowed")
+
+// DefaultProxyMethods are the read-only JSON-RPC methods forwarded by ProxyRequest unless WithRPCProxy sets others
+var DefaultProxyMethods = []string{
+	"eth_blockNumber",
+	"eth_call",
+	"eth_chainId",
+	"eth_estimateGas",
+	"eth_feeHistory",
+	"eth_gasPrice",
+	"eth_getBalance",
+	"eth_getBlockByHash",
+	"eth_getBlockByNumber",
+	"eth_getCode",
+	"eth_getLogs",
+	"eth_getStorageAt",
+	"eth_getTransactionByHash",
+	"eth_getTransactionCount",
+	"eth_getTransactionReceipt",
+	"eth_maxPriorityFeePerGas",
+	"net_version",
+}
+
+// ProxyStats counts the requests forwarded by ProxyRequest
+type ProxyStats struct {
+	Requests int `json:"requests"`
+	// Rejected counts the requests for methods outside the whitelist
+	Rejected int `json:"rejected"`
+	// Errors counts the requests that neither the node nor the fallback node answered
+	Errors int `json:"errors"`
+	// Fallbacks counts the requests answered by the fallback node after a failure of the node
+	Fallbacks int `json:"fallbacks"`
+}
+
+// ProxyMethods returns the JSON-RPC methods forwarded by ProxyRequest, sorted
+func (p *EthParser) ProxyMethods() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	methods := make([]string, 0, len(p.proxyMethods))
+	for method := range p.proxyMethods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// ProxyRequest forwards a JSON-RPC request of a whitelisted method to the node, through the same client as
+// the parser, so that it's recorded and goes through the egress settings. When the node can't be reached
+// the request is sent to the fallback node, if any. A JSON-RPC error of the node is returned in the response,
+// not as an error, so that it reaches the caller unchanged.
+func (p *EthParser) ProxyRequest(req JSONRPCRequest) (JSONRPCResponse, error) {
+	p.mu.Lock()
+	allowed := p.proxyMethods[req.Method]
+	p.proxyStats.Requests++
+	if !allowed {
+		p.proxyStats.Rejected++
+	}
+	p.mu.Unlock()
+	if !allowed {
+		return JSONRPCResponse{}, ErrMethodNotAllowed
+	}
+	if req.Params == nil {
+		req.Params = []interface{}{}
+	}
+	resp, err := p.client.SendRequest(req)
+	if err == nil || resp.Error != nil {
+		return resp, nil
+	}
+	if p.fallbackClient != nil {
+		fallbackResp, fallbackErr := p.fallbackClient.SendRequest(req)
+		if fallbackErr == nil || fallbackResp.Error != nil {
+			p.mu.Lock()
+			p.proxyStats.Fallbacks++
+			p.mu.Unlock()
+			return fallbackResp, nil
+		}
+		err = errors.Join(err, fallbackErr)
+	}
+	p.mu.Lock()
+	p.proxyStats.Errors++
+	p.mu.Unlock()
+	return JSONRPCResponse{}, err
+}
+
+// ProxyStats returns the counters of the requests forwarded by ProxyRequest
+func (p *EthParser) ProxyStats() ProxyStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.proxyStats
+}
+
+// proxyMethodSet returns the whitelist of the given methods, trimmed
+func proxyMethodSet(methods []string) map[string]bool {
+	set := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		if method = strings.TrimSpace(method); method != "" {
+			set[method] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,59 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestEthParserProxyRequest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{{Hash: "0xa1", From: "0x1", To: "0x2", Value: "0x1"}}})
+	primary := &flakyClient{MockClient: NewMockClient(mockBlockchain), failures: 1}
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, primary, func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithFallbackClient(NewMockClient(mockBlockchain)))
+	defer ethParser.WaitForShutdown()
+
+	resp, err := ethParser.ProxyRequest(parser.JSONRPCRequest{JSONRPC: "2.0", Method: "eth_blockNumber", ID: 1})
+	if err != nil || resp.Result != "0x1" {
+		t.Fatalf("Expected the block number of the node, got %+v %v", resp, err)
+	}
+
+	// The node fails the first block request, the fallback node answers it
+	resp, err = ethParser.ProxyRequest(parser.JSONRPCRequest{JSONRPC: "2.0", Method: "eth_getBlockByNumber", Params: []interface{}{"0x1", false}, ID: 1})
+	if err != nil || resp.Result.(map[string]interface{})["number"] != "0x1" {
+		t.Fatalf("Expected the block from the fallback node, got %+v %v", resp, err)
+	}
+
+	if _, err := ethParser.ProxyRequest(parser.JSONRPCRequest{JSONRPC: "2.0", Method: "eth_sendRawTransaction", Params: []interface{}{"0x00"}, ID: 1}); !errors.Is(err, parser.ErrMethodNotAllowed) {
+		t.Errorf("Expected a method outside the whitelist to be rejected, got %v", err)
+	}
+	if _, err := ethParser.ProxyRequest(parser.JSONRPCRequest{JSONRPC: "2.0", Method: "eth_getBalance", Params: []interface{}{"0x1", "latest"}, ID: 1}); err == nil {
+		t.Error("Expected an error when neither node answers")
+	}
+
+	stats := ethParser.ProxyStats()
+	if stats.Requests != 4 || stats.Rejected != 1 || stats.Fallbacks != 1 || stats.Errors != 1 {
+		t.Errorf("Unexpected proxy stats %+v", stats)
+	}
+}
+
+func TestEthParserProxyMethods(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(NewMockBlockchain()), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithRPCProxy(" eth_chainId", "eth_blockNumber", ""))
+	defer ethParser.WaitForShutdown()
+	if methods := ethParser.ProxyMethods(); len(methods) != 2 || methods[0] != "eth_blockNumber" || methods[1] != "eth_chainId" {
+		t.Errorf("Unexpected whitelist %v", methods)
+	}
+	if _, err := ethParser.ProxyRequest(parser.JSONRPCRequest{JSONRPC: "2.0", Method: "eth_call", ID: 1}); !errors.Is(err, parser.ErrMethodNotAllowed) {
+		t.Errorf("Expected eth_call to be rejected, got %v", err)
+	}
+}
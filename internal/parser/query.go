@@ -0,0 +1,256 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// sqlBatchAddresses caps the addresses of one query, within the placeholder limits of the drivers
+const sqlBatchAddresses = 500
+
+// Orders of the transactions of a TxQuery
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// ErrInvalidQuery is returned by TxQuery.Validate, wrapped with the invalid field
+var ErrInvalidQuery = errors.New("invalid query")
+
+// TxQuery selects the transactions of one or several addresses. It's shared by the storages, the parser and
+// the API, so that every filter is implemented once, by Match.
+type TxQuery struct {
+	Addresses []string
+	// FromBlock and ToBlock are the range of blocks included, zero for no bound
+	FromBlock int
+	ToBlock   int
+	// FromTime and ToTime are the range of block times included, zero for no bound. The transactions stored
+	// without BlockTime don't match a time range.
+	FromTime time.Time
+	ToTime   time.Time
+	// Direction keeps the transactions with this direction relative to their address, see TransactionDirection
+	Direction string
+	// MinValue keeps the transactions of at least this value in wei
+	MinValue *big.Int
+	// Category keeps only the transactions of the category, see WithClassifier
+	Category string
+	// After is the cursor of the previous page, it requires a single address
+	After *TransactionCursor
+	// Limit is the number of transactions per address, zero for all of them
+	Limit int
+	// Order is OrderAsc, the default, from the oldest block, or OrderDesc from the most recent one
+	Order string
+}
+
+// TxResult is the result of a TxQuery
+type TxResult struct {
+	// Transactions of each address, the addresses without transactions are left out
+	Transactions map[string][]Transaction
+	// Next is the cursor of the next page of each address, see PageTransactions
+	Next map[string]TransactionCursor
+}
+
+// TransactionQuerier is implemented by the storages selecting the transactions of a TxQuery themselves,
+// e.g. with one SQL query for all its addresses, instead of a GetTransactions per address
+type TransactionQuerier interface {
+	// QueryTransactions returns the transactions of the addresses matching the filters of the query, in block
+	// order. It may apply the limit when it applies every filter, the parser pages the results.
+	QueryTransactions(query TxQuery) (map[string][]Transaction, error)
+}
+
+// Validate returns an error wrapping ErrInvalidQuery for an inconsistent query
+func (q TxQuery) Validate() error {
+	switch {
+	case len(q.Addresses) == 0:
+		return fmt.Errorf("%w: no address", ErrInvalidQuery)
+	case q.FromBlock < 0 || q.ToBlock < 0 || (q.ToBlock > 0 && q.ToBlock < q.FromBlock):
+		return fmt.Errorf("%w: block range", ErrInvalidQuery)
+	case !q.ToTime.IsZero() && q.ToTime.Before(q.FromTime):
+		return fmt.Errorf("%w: time range", ErrInvalidQuery)
+	case q.Direction != "" && q.Direction != DirectionIn && q.Direction != DirectionOut && q.Direction != DirectionSelf:
+		return fmt.Errorf("%w: direction", ErrInvalidQuery)
+	case q.MinValue != nil && q.MinValue.Sign() < 0:
+		return fmt.Errorf("%w: min value", ErrInvalidQuery)
+	case q.Category != "" && !IsCategory(q.Category):
+		return fmt.Errorf("%w: category", ErrInvalidQuery)
+	case q.Limit < 0:
+		return fmt.Errorf("%w: limit", ErrInvalidQuery)
+	case q.Order != "" && q.Order != OrderAsc && q.Order != OrderDesc:
+		return fmt.Errorf("%w: order", ErrInvalidQuery)
+	case q.After != nil && (len(q.Addresses) != 1 || !strings.EqualFold(q.After.Address, q.Addresses[0]) || q.After.Desc != q.desc()):
+		return fmt.Errorf("%w: cursor", ErrInvalidQuery)
+	}
+	return nil
+}
+
+// desc reports whether the query lists the most recent transactions first
+func (q TxQuery) desc() bool {
+	return q.Order == OrderDesc
+}
+
+// Match reports whether a transaction of address matches the filters of the query, the pagination aside
+func (q TxQuery) Match(tx Transaction, address string) bool {
+	if (q.FromBlock > 0 && tx.BlockNumberDecimal < q.FromBlock) || (q.ToBlock > 0 && tx.BlockNumberDecimal > q.ToBlock) {
+		return false
+	}
+	if !q.FromTime.IsZero() || !q.ToTime.IsZero() {
+		if tx.BlockTime == nil || (!q.FromTime.IsZero() && tx.BlockTime.Before(q.FromTime)) || (!q.ToTime.IsZero() && tx.BlockTime.After(q.ToTime)) {
+			return false
+		}
+	}
+	if q.Direction != "" && TransactionDirection(tx, address) != q.Direction {
+		return false
+	}
+	if q.MinValue != nil {
+		value, ok := parseQuantity(tx.Value)
+		if !ok || value.Cmp(q.MinValue) < 0 {
+			return false
+		}
+	}
+	return q.Category == "" || tx.Category == q.Category
+}
+
+// filtersInBlocks reports whether the only filter of the query is the block range, which the SQL storage
+// applies itself; the other fields are in the payload, which may be encrypted
+func (q TxQuery) filtersInBlocks() bool {
+	return q.FromTime.IsZero() && q.ToTime.IsZero() && q.Direction == "" && q.MinValue == nil && q.Category == ""
+}
+
+// filter returns the transactions of address matching the query
+func (q TxQuery) filter(transactions []Transaction, address string) []Transaction {
+	var matching []Transaction
+	for _, tx := range transactions {
+		if q.Match(tx, address) {
+			matching = append(matching, tx)
+		}
+	}
+	return matching
+}
+
+// page returns the page of the matching transactions of address with its next cursor
+func (q TxQuery) page(transactions []Transaction, address string) ([]Transaction, TransactionCursor) {
+	limit := q.Limit
+	if limit == 0 {
+		limit = len(transactions)
+	}
+	items, next := pageItems(transactions, address, q.After, limit, q.desc())
+	page := make([]Transaction, len(items))
+	for i, item := range items {
+		page[i] = transactions[item]
+	}
+	return page, next
+}
+
+// QueryTransactions returns the transactions of the addresses of a valid TxQuery, with the TransactionQuerier
+// of the storage when it has one
+func (p *EthParser) QueryTransactions(query TxQuery) TxResult {
+	result := TxResult{Transactions: make(map[string][]Transaction), Next: make(map[string]TransactionCursor)}
+	matching := make(map[string][]Transaction, len(query.Addresses))
+	if querier, ok := p.storage.(TransactionQuerier); ok {
+		var err error
+		if matching, err = querier.QueryTransactions(query); err != nil {
+			log.Printf("Error querying the transactions of %d addresses: %v\n", len(query.Addresses), err)
+			return result
+		}
+	} else {
+		for _, address := range query.Addresses {
+			matching[address] = query.filter(p.storage.GetTransactions(address), address)
+		}
+	}
+	for _, address := range query.Addresses {
+		transactions, next := query.page(matching[address], address)
+		result.Next[address] = next
+		if len(transactions) > 0 {
+			result.Transactions[address] = p.annotateTransactions(address, transactions)
+		}
+	}
+	return result
+}
+
+// QueryTransactions selects the transactions of the addresses with one lock of the storage
+func (s *MemoryStorage) QueryTransactions(query TxQuery) (map[string][]Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	matching := make(map[string][]Transaction, len(query.Addresses))
+	for _, address := range query.Addresses {
+		if transactions := query.filter(s.data[address], address); len(transactions) > 0 {
+			matching[address] = transactions
+		}
+	}
+	return matching, nil
+}
+
+// QueryTransactions selects the transactions of the addresses with one query per 500 addresses. The database
+// applies the block range and, when it's the only filter of a first page, the limit; the other filters are
+// applied by TxQuery.Match after decoding the payloads.
+func (s *SQLStorage) QueryTransactions(query TxQuery) (map[string][]Transaction, error) {
+	matching := make(map[string][]Transaction, len(query.Addresses))
+	for start := 0; start < len(query.Addresses); start += sqlBatchAddresses {
+		end := min(start+sqlBatchAddresses, len(query.Addresses))
+		if err := s.queryTransactions(query.Addresses[start:end], query, matching); err != nil {
+			return nil, err
+		}
+	}
+	for address, transactions := range matching {
+		if matching[address] = query.filter(transactions, address); len(matching[address]) == 0 {
+			delete(matching, address)
+		}
+	}
+	return matching, nil
+}
+
+// queryTransactions adds the transactions of the addresses to matching
+func (s *SQLStorage) queryTransactions(addresses []string, query TxQuery, matching map[string][]Transaction) error {
+	placeholders := make([]string, len(addresses))
+	args := make([]interface{}, 0, len(addresses)+3)
+	for i, address := range addresses {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args = append(args, address)
+	}
+	where := "address IN (" + strings.Join(placeholders, ", ") + ")"
+	if query.FromBlock > 0 {
+		args = append(args, query.FromBlock)
+		where += fmt.Sprintf(" AND block_number_decimal >= $%d", len(args))
+	}
+	if query.ToBlock > 0 {
+		args = append(args, query.ToBlock)
+		where += fmt.Sprintf(" AND block_number_decimal <= $%d", len(args))
+	}
+	sqlQuery := `SELECT address, hash, from_address, to_address, value, block_number, block_number_decimal, payload
+		FROM transactions WHERE ` + where + ` ORDER BY address, block_number_decimal`
+	if query.Limit > 0 && query.After == nil && query.filtersInBlocks() {
+		// The blocks of the first or last rows of each address, supported by PostgreSQL and SQLite 3.25+. Whole
+		// blocks are kept since the rows are paged by their index in the block, which is in the payload.
+		order := "ASC"
+		if query.desc() {
+			order = "DESC"
+		}
+		args = append(args, query.Limit)
+		sqlQuery = `SELECT address, hash, from_address, to_address, value, block_number, block_number_decimal, payload FROM (
+			SELECT *, RANK() OVER (PARTITION BY address ORDER BY block_number_decimal ` + order + `) AS block_rank
+			FROM transactions WHERE ` + where + `) ranked WHERE block_rank <= $` + fmt.Sprint(len(args)) + `
+		ORDER BY address, block_number_decimal`
+	}
+	rows, err := s.db.Query(sqlQuery, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var address, payload string
+		var tx Transaction
+		if err := rows.Scan(&address, &tx.Hash, &tx.From, &tx.To, &tx.Value, &tx.BlockNumber, &tx.BlockNumberDecimal, &payload); err != nil {
+			return err
+		}
+		if err := s.decodePayload(address, &tx, payload); err != nil {
+			return fmt.Errorf("decoding transaction %s for address %s: %w", tx.Hash, address, err)
+		}
+		matching[address] = append(matching[address], tx)
+	}
+	return rows.Err()
+}
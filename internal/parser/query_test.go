@@ -0,0 +1,149 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestEthParserQueryTransactions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The memory storage selects the transactions itself, the mock one is read per address
+	for _, storage := range []parser.Storage{parser.NewMemoryStorage(), NewMockStorage()} {
+		ethParser := parser.NewEthParser(ctx, storage, 1, NewMockClient(NewMockBlockchain()), func(string, []parser.Transaction) {},
+			parser.WithClock(parser.NewManualClock(time.Now())))
+		storage.SaveTransactions("0x1", []parser.Transaction{
+			{Hash: "0xa1", From: "0x1", To: "0x9", Value: "0x64", BlockNumberDecimal: 1},
+			{Hash: "0xa2", From: "0x9", To: "0x1", Value: "0x1", BlockNumberDecimal: 2, Category: parser.CategorySwap},
+			{Hash: "0xa3", From: "0x9", To: "0x1", Value: "0xc8", BlockNumberDecimal: 3},
+		})
+		storage.SaveTransactions("0x2", []parser.Transaction{{Hash: "0xb1", BlockNumberDecimal: 1}})
+
+		result := ethParser.QueryTransactions(parser.TxQuery{Addresses: []string{"0x1", "0x2", "0x3"}})
+		if len(result.Transactions) != 2 || len(result.Transactions["0x1"]) != 3 || len(result.Transactions["0x2"]) != 1 {
+			t.Errorf("%T: expected the transactions of both addresses, got %+v", storage, result.Transactions)
+		}
+		result = ethParser.QueryTransactions(parser.TxQuery{Addresses: []string{"0x1", "0x2"}, FromBlock: 2, Limit: 1, Order: parser.OrderDesc})
+		if len(result.Transactions) != 1 || len(result.Transactions["0x1"]) != 1 || result.Transactions["0x1"][0].Hash != "0xa3" {
+			t.Errorf("%T: expected the most recent transaction in range, got %+v", storage, result.Transactions)
+		}
+		result = ethParser.QueryTransactions(parser.TxQuery{Addresses: []string{"0x1"}, ToBlock: 2, Category: parser.CategorySwap})
+		if len(result.Transactions["0x1"]) != 1 || result.Transactions["0x1"][0].Hash != "0xa2" {
+			t.Errorf("%T: expected the swap, got %+v", storage, result.Transactions)
+		}
+		result = ethParser.QueryTransactions(parser.TxQuery{Addresses: []string{"0x1"}, Direction: parser.DirectionIn, MinValue: big.NewInt(100)})
+		if len(result.Transactions["0x1"]) != 1 || result.Transactions["0x1"][0].Hash != "0xa3" {
+			t.Errorf("%T: expected the incoming transaction of at least 100 wei, got %+v", storage, result.Transactions)
+		}
+		// A decimal value, e.g. imported, isn't read as hex
+		storage.SaveTransactions("0x4", []parser.Transaction{
+			{Hash: "0xc1", From: "0x9", To: "0x4", Value: "99", BlockNumberDecimal: 1},
+			{Hash: "0xc2", From: "0x9", To: "0x4", Value: "0x64", BlockNumberDecimal: 2},
+		})
+		result = ethParser.QueryTransactions(parser.TxQuery{Addresses: []string{"0x4"}, MinValue: big.NewInt(100)})
+		if len(result.Transactions["0x4"]) != 1 || result.Transactions["0x4"][0].Hash != "0xc2" {
+			t.Errorf("%T: expected the transaction of 0x64 wei only, got %+v", storage, result.Transactions)
+		}
+		ethParser.WaitForShutdown()
+	}
+}
+
+func TestEthParserQueryTransactionsTimeRange(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := parser.NewMemoryStorage()
+	ethParser := parser.NewEthParser(ctx, storage, 1, NewMockClient(NewMockBlockchain()), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	blockTime := func(offset time.Duration) *time.Time {
+		at := start.Add(offset)
+		return &at
+	}
+	storage.SaveTransactions("0x1", []parser.Transaction{
+		{Hash: "0xa0", BlockNumberDecimal: 1},
+		{Hash: "0xa1", BlockNumberDecimal: 2, BlockTime: blockTime(0)},
+		{Hash: "0xa2", BlockNumberDecimal: 3, BlockTime: blockTime(time.Hour)},
+		{Hash: "0xa3", BlockNumberDecimal: 4, BlockTime: blockTime(2 * time.Hour)},
+	})
+
+	// The transaction without block time doesn't match a time range
+	result := ethParser.QueryTransactions(parser.TxQuery{Addresses: []string{"0x1"}, FromTime: start, ToTime: start.Add(time.Hour)})
+	if transactions := result.Transactions["0x1"]; len(transactions) != 2 || transactions[0].Hash != "0xa1" || transactions[1].Hash != "0xa2" {
+		t.Errorf("Expected the transactions of the first hour, got %+v", transactions)
+	}
+}
+
+func TestEthParserQueryTransactionsDescendingPages(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := parser.NewMemoryStorage()
+	ethParser := parser.NewEthParser(ctx, storage, 1, NewMockClient(NewMockBlockchain()), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+	storage.SaveTransactions("0x1", []parser.Transaction{
+		{Hash: "0xa1", BlockNumberDecimal: 1},
+		{Hash: "0xa2", BlockNumberDecimal: 2},
+		{Hash: "0xa3", BlockNumberDecimal: 3},
+	})
+
+	query := parser.TxQuery{Addresses: []string{"0x1"}, Limit: 2, Order: parser.OrderDesc}
+	var hashes []string
+	for page := 0; page < 3; page++ {
+		result := ethParser.QueryTransactions(query)
+		for _, tx := range result.Transactions["0x1"] {
+			hashes = append(hashes, tx.Hash)
+		}
+		next := result.Next["0x1"]
+		query.After = &next
+	}
+	if len(hashes) != 3 || hashes[0] != "0xa3" || hashes[1] != "0xa2" || hashes[2] != "0xa1" {
+		t.Errorf("Expected the transactions from the most recent, got %v", hashes)
+	}
+}
+
+func TestTxQueryValidate(t *testing.T) {
+	cursor := parser.TransactionCursor{Address: "0x1"}
+	for name, query := range map[string]parser.TxQuery{
+		"block range": {Addresses: []string{"0x1"}, FromBlock: 3, ToBlock: 2},
+		"time range":  {Addresses: []string{"0x1"}, FromTime: time.Unix(10, 0), ToTime: time.Unix(5, 0)},
+		"direction":   {Addresses: []string{"0x1"}, Direction: "sideways"},
+		"min value":   {Addresses: []string{"0x1"}, MinValue: big.NewInt(-1)},
+		"order":       {Addresses: []string{"0x1"}, Order: "random"},
+		"cursor":      {Addresses: []string{"0x1"}, After: &cursor, Order: parser.OrderDesc},
+	} {
+		if err := query.Validate(); !errors.Is(err, parser.ErrInvalidQuery) || err.Error() != "invalid query: "+name {
+			t.Errorf("Expected an invalid %s, got %v", name, err)
+		}
+	}
+	if err := (parser.TxQuery{Addresses: []string{"0x1"}, After: &cursor}).Validate(); err != nil {
+		t.Errorf("Expected a valid query, got %v", err)
+	}
+}
+
+func TestTenantQueryTransactions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := parser.NewMemoryStorage()
+	ethParser := parser.NewEthParser(ctx, storage, 1, NewMockClient(NewMockBlockchain()), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+	storage.SaveTransactions("0x1", []parser.Transaction{{Hash: "0xa1", BlockNumberDecimal: 1}})
+	storage.SaveTransactions("0x2", []parser.Transaction{{Hash: "0xb1", BlockNumberDecimal: 1}})
+	manager := parser.NewTenantManager(ethParser)
+	manager.CreateTenant("a", "A", 0)
+	manager.View("a").Subscribe("0x1")
+
+	result := manager.View("a").QueryTransactions(parser.TxQuery{Addresses: []string{"0x1", "0x2"}})
+	if len(result.Transactions) != 1 || len(result.Transactions["0x1"]) != 1 {
+		t.Errorf("Expected only the transactions of the tenant addresses, got %+v", result.Transactions)
+	}
+}
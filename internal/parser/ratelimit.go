@@ -0,0 +1,66 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pause after a 429 without a usable Retry-After header, and the longest pause honored
+const (
+	defaultRetryAfter = 5 * time.Second
+	maxRetryAfter     = 10 * time.Minute
+)
+
+// RateLimitError is returned for a request rejected by a provider rate limit, an HTTP 429, or not sent because
+// the endpoint is paused until RetryAfter has elapsed
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited by the node, retry after %s", e.RetryAfter)
+}
+
+// ThrottleState is the pause of the node requests of the parser after a rate limit, see ThrottleState
+type ThrottleState struct {
+	// Throttled is set while the requests are paused, until Until
+	Throttled bool       `json:"throttled"`
+	Until     *time.Time `json:"until,omitempty"`
+	// RateLimited counts the requests rejected by a rate limit, DeferredBlocks the blocks rescheduled because of it
+	RateLimited    int `json:"rateLimited"`
+	DeferredBlocks int `json:"deferredBlocks"`
+}
+
+// ThrottleState returns the pause of the node requests after a rate limit
+func (p *EthParser) ThrottleState() ThrottleState {
+	state := p.rpcLimiter.throttleState()
+	p.mu.Lock()
+	state.DeferredBlocks = p.deferredBlocks
+	p.mu.Unlock()
+	return state
+}
+
+// rateLimitRetryAfter returns the pause of a rate limit error, false for the other errors
+func rateLimitRetryAfter(err error) (time.Duration, bool) {
+	var rateLimited *RateLimitError
+	if !errors.As(err, &rateLimited) {
+		return 0, false
+	}
+	return rateLimited.RetryAfter, true
+}
+
+// parseRetryAfter reads a Retry-After header, in seconds or as an HTTP date, bounded by maxRetryAfter
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	header = strings.TrimSpace(header)
+	wait := defaultRetryAfter
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		wait = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(header); err == nil {
+		wait = max(at.Sub(now), 0)
+	}
+	return min(wait, maxRetryAfter)
+}
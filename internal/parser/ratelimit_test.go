@@ -0,0 +1,89 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDefaultClientHonorsRetryAfter(t *testing.T) {
+	var requests atomic.Int64
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer node.Close()
+
+	client := parser.NewJsonRpcClientWithURL(node.URL)
+	req := parser.JSONRPCRequest{JSONRPC: "2.0", Method: "eth_blockNumber", ID: 1}
+	var rateLimited *parser.RateLimitError
+	if _, err := client.SendRequest(req); !errors.As(err, &rateLimited) || rateLimited.RetryAfter != 2*time.Minute {
+		t.Fatalf("Expected a rate limit error for 2m, got %v", err)
+	}
+	// The endpoint is paused: the request fails without being sent
+	if _, err := client.SendRequest(req); !errors.As(err, &rateLimited) || rateLimited.RetryAfter > 2*time.Minute {
+		t.Fatalf("Expected the pause to be honored, got %v", err)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("Expected 1 request sent to the node, got %d", got)
+	}
+}
+
+// rateLimitedNode rejects the request of a block once with a rate limit
+type rateLimitedNode struct {
+	*MockClient
+	block string
+	done  bool
+}
+
+func (c *rateLimitedNode) SendRequest(req parser.JSONRPCRequest) (parser.JSONRPCResponse, error) {
+	if req.Method == "eth_getBlockByNumber" && req.Params[0] == c.block && !c.done {
+		c.done = true
+		return parser.JSONRPCResponse{}, &parser.RateLimitError{RetryAfter: 30 * time.Second}
+	}
+	return c.MockClient.SendRequest(req)
+}
+
+func TestEthParserReschedulesRateLimitedBlocks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := parser.NewManualClock(time.Now())
+	storage := parser.NewMemoryStorage()
+	ethParser := parser.NewEthParser(ctx, storage, 1, &rateLimitedNode{MockClient: NewMockClient(mockChain(30)), block: "0x19"},
+		func(string, []parser.Transaction) {}, parser.WithClock(clock), parser.WithBlockDeadLetters(storage, 3))
+	defer ethParser.WaitForShutdown()
+
+	ethParser.ProcessNextCycle()
+	if last := ethParser.BackpressureStats().LastProcessedBlock; last != 24 {
+		t.Fatalf("Expected the cycle to stop before block 25, got %d", last)
+	}
+	throttle := ethParser.ThrottleState()
+	if !throttle.Throttled || throttle.RateLimited != 1 || throttle.DeferredBlocks != 6 {
+		t.Fatalf("Expected the requests to be paused with 6 deferred blocks, got %+v", throttle)
+	}
+
+	// Paused: the cycle doesn't fetch anything
+	ethParser.ProcessNextCycle()
+	if last := ethParser.BackpressureStats().LastProcessedBlock; last != 24 {
+		t.Fatalf("Expected no block processed while paused, got %d", last)
+	}
+
+	clock.Advance(30 * time.Second)
+	ethParser.ProcessNextCycle()
+	if last := ethParser.BackpressureStats().LastProcessedBlock; last != 30 {
+		t.Fatalf("Expected the rescheduled blocks to be processed, got %d", last)
+	}
+	if letters, _ := ethParser.BlockDeadLetters(); len(letters) != 0 {
+		t.Errorf("Expected no dead-lettered block, got %+v", letters)
+	}
+	if ethParser.ThrottleState().Throttled {
+		t.Error("Expected the pause to be over")
+	}
+}
@@ -0,0 +1,206 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNotRecorded is returned by the ReplayClient for a request missing from the recording
+var ErrNotRecorded = errors.New("request not recorded")
+
+// recordingFilePattern matches the files written by the FileRecorder, their names sort chronologically
+const recordingFilePattern = "rpc-*.jsonl"
+
+// RPCExchange is a JSON-RPC request sent to a node with the raw response it returned, as recorded by an
+// RPCRecorder. Error is set instead of the response when the request failed before any response.
+type RPCExchange struct {
+	Time     time.Time     `json:"time"`
+	Endpoint string        `json:"endpoint"`
+	Request  string        `json:"request"`
+	Status   int           `json:"status,omitempty"`
+	Response string        `json:"response,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// RPCRecorder records the exchanges of a DefaultClient, see DefaultClient.WithRecorder. FileRecorder writes
+// them to disk, embedders can implement it to ship them to an object storage.
+type RPCRecorder interface {
+	Record(exchange RPCExchange) error
+}
+
+// FileRecorder is an RPCRecorder writing the exchanges as JSON lines to the files of a directory, starting a
+// new file when the current one reaches maxSize bytes and deleting the oldest ones beyond maxFiles
+type FileRecorder struct {
+	dir      string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+	mu       sync.Mutex
+}
+
+// NewFileRecorder creates a FileRecorder writing to dir, created if missing. A maxSize or maxFiles of zero
+// disables the rotation or the deletion.
+func NewFileRecorder(dir string, maxSize int64, maxFiles int) (*FileRecorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileRecorder{dir: dir, maxSize: maxSize, maxFiles: maxFiles}, nil
+}
+
+// Record appends an exchange to the current file, rotating it first when it's full
+func (r *FileRecorder) Record(exchange RPCExchange) error {
+	line, err := json.Marshal(exchange)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file != nil && r.maxSize > 0 && r.size+int64(len(line)) > r.maxSize {
+		if err := r.file.Close(); err != nil {
+			return err
+		}
+		r.file = nil
+	}
+	if r.file == nil {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := r.file.Write(line)
+	r.size += int64(n)
+	return err
+}
+
+// rotate opens a new file and deletes the oldest ones beyond maxFiles, mu must be held
+func (r *FileRecorder) rotate() error {
+	name := "rpc-" + time.Now().UTC().Format("20060102T150405.000000000") + ".jsonl"
+	file, err := os.OpenFile(filepath.Join(r.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	r.file, r.size = file, 0
+	if r.maxFiles <= 0 {
+		return nil
+	}
+	files, err := RecordingFiles(r.dir)
+	if err != nil {
+		return err
+	}
+	for len(files) > r.maxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// Close closes the current file, the next Record opens a new one
+func (r *FileRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// RecordingFiles returns the files written by a FileRecorder in dir, the oldest first
+func RecordingFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, recordingFilePattern))
+	sort.Strings(files)
+	return files, err
+}
+
+// LoadRPCRecording reads the exchanges of the recording files, in order
+func LoadRPCRecording(paths ...string) ([]RPCExchange, error) {
+	var exchanges []RPCExchange
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		decoder := json.NewDecoder(file)
+		for {
+			var exchange RPCExchange
+			err := decoder.Decode(&exchange)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				file.Close()
+				return nil, fmt.Errorf("reading %s: %w", path, err)
+			}
+			exchanges = append(exchanges, exchange)
+		}
+		file.Close()
+	}
+	return exchanges, nil
+}
+
+// ReplayClient is a JsonRpcClient answering with the responses of a recording, to run the parser again on
+// the exact data a node returned. The responses to the same method and parameters are returned in the
+// recorded order, the last one is repeated once they're exhausted.
+type ReplayClient struct {
+	responses map[string][]RPCExchange
+	mu        sync.Mutex
+}
+
+// NewReplayClient creates a ReplayClient from recorded exchanges
+func NewReplayClient(exchanges []RPCExchange) *ReplayClient {
+	c := &ReplayClient{responses: make(map[string][]RPCExchange)}
+	for _, exchange := range exchanges {
+		var req JSONRPCRequest
+		if err := json.Unmarshal([]byte(exchange.Request), &req); err != nil {
+			continue
+		}
+		key := replayKey(req)
+		c.responses[key] = append(c.responses[key], exchange)
+	}
+	return c
+}
+
+// SendRequest returns the next recorded response to the request, ErrNotRecorded when there's none
+func (c *ReplayClient) SendRequest(req JSONRPCRequest) (JSONRPCResponse, error) {
+	key := replayKey(req)
+	c.mu.Lock()
+	recorded := c.responses[key]
+	if len(recorded) == 0 {
+		c.mu.Unlock()
+		return JSONRPCResponse{}, fmt.Errorf("%w: %s", ErrNotRecorded, req.Method)
+	}
+	exchange := recorded[0]
+	if len(recorded) > 1 {
+		c.responses[key] = recorded[1:]
+	}
+	c.mu.Unlock()
+
+	if exchange.Error != "" {
+		return JSONRPCResponse{}, errors.New(exchange.Error)
+	}
+	return decodeRPCResponse([]byte(exchange.Response))
+}
+
+// replayKey identifies a request by method and parameters, the parameters going through a JSON round trip so
+// that the recorded and the live ones compare equal
+func replayKey(req JSONRPCRequest) string {
+	params, _ := json.Marshal(req.Params)
+	var decoded interface{}
+	if json.Unmarshal(params, &decoded) == nil {
+		params, _ = json.Marshal(decoded)
+	}
+	return req.Method + string(params)
+}
@@ -0,0 +1,117 @@
+package parser_test
+
+import (
+	"encoding/json"
+	"errors"
+	"eth-parser/internal/parser"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplayRPC(t *testing.T) {
+	block := 0
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req parser.JSONRPCRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		switch req.Method {
+		case "eth_blockNumber":
+			block++
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":"0x%x"}`, block)
+		default:
+			io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":{"number":"0x1","gasLimit":123456789012345678901234567890}}`)
+		}
+	}))
+	defer node.Close()
+
+	dir := t.TempDir()
+	recorder, err := parser.NewFileRecorder(dir, 200, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	client := parser.NewJsonRpcClientWithURL(node.URL).WithRecorder(recorder)
+	requests := []parser.JSONRPCRequest{
+		{JSONRPC: "2.0", Method: "eth_blockNumber", ID: 1},
+		{JSONRPC: "2.0", Method: "eth_getBlockByNumber", Params: []interface{}{"0x1", true}, ID: 1},
+		{JSONRPC: "2.0", Method: "eth_blockNumber", ID: 1},
+	}
+	var live []parser.JSONRPCResponse
+	for _, req := range requests {
+		resp, err := client.SendRequest(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		live = append(live, resp)
+	}
+	recorder.Close()
+
+	// Every exchange is larger than the maximum size, the oldest file is rotated out
+	files, err := parser.RecordingFiles(dir)
+	if err != nil || len(files) != 2 {
+		t.Fatalf("Expected 2 recording files, got %v (%v)", files, err)
+	}
+	exchanges, err := parser.LoadRPCRecording(files...)
+	if err != nil || len(exchanges) != 2 {
+		t.Fatalf("Expected the last 2 exchanges, got %+v (%v)", exchanges, err)
+	}
+	if exchanges[0].Endpoint != node.URL || exchanges[0].Status != http.StatusOK {
+		t.Errorf("Unexpected exchange %+v", exchanges[0])
+	}
+
+	replay := parser.NewReplayClient(exchanges)
+	for i, req := range requests[1:] {
+		resp, err := replay.SendRequest(req)
+		if err != nil {
+			t.Fatalf("Unexpected error replaying %s: %v", req.Method, err)
+		}
+		// The numbers are replayed exactly
+		expected, _ := json.Marshal(live[i+1].Result)
+		if replayed, _ := json.Marshal(resp.Result); string(replayed) != string(expected) {
+			t.Errorf("Expected %s, got %s", expected, replayed)
+		}
+	}
+	// The last response is repeated
+	if resp, err := replay.SendRequest(requests[2]); err != nil || resp.Result != "0x2" {
+		t.Errorf("Expected the last block number again, got %v (%v)", resp.Result, err)
+	}
+	if _, err := replay.SendRequest(parser.JSONRPCRequest{Method: "eth_chainId"}); !errors.Is(err, parser.ErrNotRecorded) {
+		t.Errorf("Expected ErrNotRecorded, got %v", err)
+	}
+}
+
+func TestRecordUnreachableNode(t *testing.T) {
+	node := httptest.NewServer(http.NotFoundHandler())
+	node.Close()
+
+	dir := t.TempDir()
+	recorder, err := parser.NewFileRecorder(dir, 0, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	client, err := parser.NewJsonRpcClientWithURL(node.URL).WithRecorder(recorder).
+		WithAuth(parser.EndpointAuth{QueryParams: map[string]string{"apikey": "secret-key"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_, err = client.SendRequest(parser.JSONRPCRequest{JSONRPC: "2.0", Method: "eth_blockNumber", ID: 1})
+	if err == nil {
+		t.Fatal("Expected an error for an unreachable node")
+	}
+	recorder.Close()
+
+	// The query keys are neither returned nor recorded with the error
+	if strings.Contains(err.Error(), "secret-key") || !strings.Contains(err.Error(), node.URL) {
+		t.Errorf("Expected the error to name the node URL only, got %v", err)
+	}
+	files, _ := parser.RecordingFiles(dir)
+	exchanges, err := parser.LoadRPCRecording(files...)
+	if err != nil || len(exchanges) != 1 {
+		t.Fatalf("Expected 1 exchange, got %+v (%v)", exchanges, err)
+	}
+	if exchanges[0].Error == "" || strings.Contains(exchanges[0].Error, "secret-key") {
+		t.Errorf("Expected the recorded error without the query keys, got %q", exchanges[0].Error)
+	}
+}
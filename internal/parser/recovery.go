@@ -0,0 +1,160 @@
+package parser
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Notification modes of the blocks processed during a startup recovery, see WithRecoveryNotifications
+const (
+	// RecoveryNotifyDeliver notifies the recovered transactions as the live ones
+	RecoveryNotifyDeliver = "deliver"
+	// RecoveryNotifySuppress stores the recovered transactions without notifying them
+	RecoveryNotifySuppress = "suppress"
+	// RecoveryNotifyHistorical notifies the recovered transactions with Historical set
+	RecoveryNotifyHistorical = "historical"
+)
+
+// CheckpointStore persists the last processed block, so that a restarted parser resumes where it stopped
+type CheckpointStore interface {
+	// LoadCheckpoint returns the last saved block, found is false when none was saved yet
+	LoadCheckpoint() (blockNumber int, found bool, err error)
+	SaveCheckpoint(blockNumber int) error
+}
+
+// RecoveryStatus reports the progress of the catch-up between the checkpoint and the head seen at startup
+type RecoveryStatus struct {
+	// Active is true until the blocks up to TargetBlock are processed
+	Active          bool `json:"active"`
+	FromBlock       int  `json:"fromBlock"`
+	TargetBlock     int  `json:"targetBlock"`
+	ProcessedBlocks int  `json:"processedBlocks"`
+	TotalBlocks     int  `json:"totalBlocks"`
+}
+
+// String describes the progress, e.g. "syncing 120/5000 blocks"
+func (s RecoveryStatus) String() string {
+	return fmt.Sprintf("syncing %d/%d blocks", s.ProcessedBlocks, s.TotalBlocks)
+}
+
+// Recovery returns the status of the startup recovery, inactive when the parser started close to the head
+func (p *EthParser) Recovery() RecoveryStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.recovery
+}
+
+// resumeFromCheckpointLocked starts from the saved checkpoint instead of the last blocks. A gap longer than
+// the blocks looked back by a fresh start is a recovery phase, reported until the startup head is reached.
+func (p *EthParser) resumeFromCheckpointLocked() bool {
+	if p.checkpoints == nil {
+		return false
+	}
+	checkpoint, found, err := p.checkpoints.LoadCheckpoint()
+	if err != nil {
+		log.Println("Error loading the checkpoint:", err)
+		return false
+	}
+	if !found || checkpoint <= 0 || checkpoint > p.currentBlock {
+		return false
+	}
+
+	p.lastProcessedBlock = checkpoint
+	if gap := p.currentBlock - checkpoint; gap > initialLookBackBlocksCount {
+		p.recovery = RecoveryStatus{Active: true, FromBlock: checkpoint, TargetBlock: p.currentBlock, TotalBlocks: gap}
+		log.Printf("Recovering from checkpoint %d: %s\n", checkpoint, p.recovery)
+	}
+	return true
+}
+
+// saveCheckpoint persists the last processed block
+func (p *EthParser) saveCheckpoint(blockNumber int) {
+	if p.checkpoints == nil {
+		return
+	}
+	if err := p.recordStorageWrite(p.checkpoints.SaveCheckpoint(blockNumber)); err != nil {
+		log.Printf("Error saving the checkpoint %d: %v\n", blockNumber, err)
+	}
+}
+
+// checkpointBlock records the progress of a cycle after a block, the count-th of the cycle: the block is
+// processed for the readers right away, and saved as the checkpoint every checkpointInterval blocks
+func (p *EthParser) checkpointBlock(number int, count int) {
+	p.mu.Lock()
+	p.lastProcessedBlock = number
+	p.notifyProcessed()
+	p.mu.Unlock()
+	if count%p.checkpointInterval == 0 {
+		p.saveCheckpoint(number)
+	}
+}
+
+// cycleInterrupted returns why a cycle must stop before its last block, empty when it goes on: the parser
+// is stopping, or the deadline of the cycle passed
+func (p *EthParser) cycleInterrupted(deadline time.Time) string {
+	if p.ctx.Err() != nil {
+		return "shutting down"
+	}
+	if !deadline.IsZero() && !p.clock.Now().Before(deadline) {
+		return fmt.Sprintf("cycle deadline of %s exceeded", p.cycleDeadline)
+	}
+	return ""
+}
+
+// updateRecoveryLocked reports the progress of the recovery after a cycle
+func (p *EthParser) updateRecoveryLocked() {
+	if !p.recovery.Active {
+		return
+	}
+	p.recovery.ProcessedBlocks = min(p.lastProcessedBlock, p.recovery.TargetBlock) - p.recovery.FromBlock
+	if p.lastProcessedBlock >= p.recovery.TargetBlock {
+		p.recovery.Active = false
+		log.Printf("Recovery completed: %d blocks processed\n", p.recovery.TotalBlocks)
+		return
+	}
+	log.Printf("Recovery: %s\n", p.recovery)
+}
+
+// recoveringBlock reports whether a block is processed as part of the recovery
+func (p *EthParser) recoveringBlock(number int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.recovery.Active && number <= p.recovery.TargetBlock
+}
+
+// LoadCheckpoint returns the checkpoint saved in memory, which only survives a Stop and Start of the parser
+func (s *MemoryStorage) LoadCheckpoint() (int, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.checkpoint, s.hasCheckpoint, nil
+}
+
+// SaveCheckpoint saves the checkpoint in memory
+func (s *MemoryStorage) SaveCheckpoint(blockNumber int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkpoint, s.hasCheckpoint = blockNumber, true
+	return nil
+}
+
+// LoadCheckpoint returns the checkpoint row
+func (s *SQLStorage) LoadCheckpoint() (int, bool, error) {
+	var blockNumber int
+	err := s.db.QueryRow(`SELECT block_number FROM checkpoint WHERE id = 1`).Scan(&blockNumber)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return blockNumber, true, nil
+}
+
+// SaveCheckpoint upserts the checkpoint row
+func (s *SQLStorage) SaveCheckpoint(blockNumber int) error {
+	_, err := s.db.Exec(`INSERT INTO checkpoint (id, block_number) VALUES (1, $1)
+		ON CONFLICT (id) DO UPDATE SET block_number = excluded.block_number`, blockNumber)
+	return err
+}
@@ -0,0 +1,183 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// recoveryBlockchain returns a chain of blocks with a transaction to 0x1 in blocks 7 and 41, 41 not mined yet
+func recoveryBlockchain() *MockBlockchain {
+	mockBlockchain := NewMockBlockchain()
+	for i := 1; i <= 40; i++ {
+		block := parser.Block{Number: fmt.Sprintf("0x%x", i)}
+		if i == 7 {
+			block.Transactions = []parser.Transaction{{Hash: "0xa7", From: "0x2", To: "0x1", Value: "0x1"}}
+		}
+		mockBlockchain.AddBlock(i, block)
+	}
+	return mockBlockchain
+}
+
+func TestEthParserRecovery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := recoveryBlockchain()
+	storage := parser.NewMemoryStorage()
+	storage.SaveCheckpoint(5)
+	var notified []parser.Transaction
+	ethParser := parser.NewEthParser(ctx, storage, 1, NewMockClient(mockBlockchain),
+		func(address string, transactions []parser.Transaction) { notified = append(notified, transactions...) },
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithBackpressure(20, 0),
+		parser.WithCheckpoint(storage), parser.WithRecoveryNotifications(parser.RecoveryNotifyHistorical))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+
+	expected := parser.RecoveryStatus{Active: true, FromBlock: 5, TargetBlock: 40, TotalBlocks: 35}
+	if recovery := ethParser.Recovery(); recovery != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, recovery)
+	}
+
+	ethParser.ProcessNextCycle()
+	if recovery := ethParser.Recovery(); !recovery.Active || recovery.String() != "syncing 20/35 blocks" {
+		t.Errorf("Expected the recovery to be in progress, got %+v", recovery)
+	}
+	if checkpoint, _, _ := storage.LoadCheckpoint(); checkpoint != 25 {
+		t.Errorf("Expected the checkpoint at block 25, got %d", checkpoint)
+	}
+	if len(notified) != 1 || !notified[0].Historical {
+		t.Fatalf("Expected the recovered transaction to be notified as historical, got %+v", notified)
+	}
+
+	ethParser.ProcessNextCycle()
+	if recovery := ethParser.Recovery(); recovery.Active || recovery.ProcessedBlocks != 35 {
+		t.Errorf("Expected the recovery to be completed, got %+v", recovery)
+	}
+
+	// The blocks after the startup head are live
+	mockBlockchain.AddBlock(41, parser.Block{Number: "0x29", Transactions: []parser.Transaction{{Hash: "0xa41", From: "0x1", To: "0x3", Value: "0x1"}}})
+	ethParser.ProcessNextCycle()
+	if len(notified) != 2 || notified[1].Historical {
+		t.Errorf("Expected a live notification, got %+v", notified)
+	}
+}
+
+func TestEthParserRecoverySuppressed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := parser.NewMemoryStorage()
+	storage.SaveCheckpoint(5)
+	notifications := 0
+	ethParser := parser.NewEthParser(ctx, storage, 1, NewMockClient(recoveryBlockchain()),
+		func(string, []parser.Transaction) { notifications++ }, parser.WithClock(parser.NewManualClock(time.Now())),
+		parser.WithCheckpoint(storage), parser.WithRecoveryNotifications(parser.RecoveryNotifySuppress))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	if notifications != 0 {
+		t.Errorf("Expected the recovered transactions not to be notified, got %d notifications", notifications)
+	}
+	if transactions := ethParser.GetTransactions("0x1"); len(transactions) != 1 || transactions[0].Historical {
+		t.Errorf("Expected the recovered transaction to be stored, got %+v", transactions)
+	}
+}
+
+func TestEthParserCheckpointWithoutRecovery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A gap within the blocks looked back by a fresh start is a plain restart
+	storage := parser.NewMemoryStorage()
+	storage.SaveCheckpoint(35)
+	ethParser := parser.NewEthParser(ctx, storage, 1, NewMockClient(recoveryBlockchain()), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithCheckpoint(storage))
+	defer ethParser.WaitForShutdown()
+
+	if recovery := ethParser.Recovery(); recovery.Active {
+		t.Errorf("Expected no recovery, got %+v", recovery)
+	}
+	if stats := ethParser.BackpressureStats(); stats.LastProcessedBlock != 35 {
+		t.Errorf("Expected to resume from the checkpoint, got %+v", stats)
+	}
+}
+
+// recordingCheckpoints records the saved checkpoints
+type recordingCheckpoints struct {
+	*parser.MemoryStorage
+	saved []int
+}
+
+func (s *recordingCheckpoints) SaveCheckpoint(blockNumber int) error {
+	s.saved = append(s.saved, blockNumber)
+	return s.MemoryStorage.SaveCheckpoint(blockNumber)
+}
+
+// blockHookClient calls hook before fetching a block
+type blockHookClient struct {
+	parser.JsonRpcClient
+	hook func(number string)
+}
+
+func (c *blockHookClient) SendRequest(req parser.JSONRPCRequest) (parser.JSONRPCResponse, error) {
+	if req.Method == "eth_getBlockByNumber" {
+		c.hook(req.Params[0].(string))
+	}
+	return c.JsonRpcClient.SendRequest(req)
+}
+
+func TestEthParserCheckpointInterval(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := &recordingCheckpoints{MemoryStorage: parser.NewMemoryStorage()}
+	storage.MemoryStorage.SaveCheckpoint(5)
+	ethParser := parser.NewEthParser(ctx, storage, 1, NewMockClient(recoveryBlockchain()), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithBackpressure(20, 0),
+		parser.WithCheckpoint(storage), parser.WithCheckpointInterval(5))
+	defer ethParser.WaitForShutdown()
+
+	ethParser.ProcessNextCycle()
+	if fmt.Sprint(storage.saved) != "[10 15 20 25]" {
+		t.Errorf("Expected a checkpoint every 5 blocks and at the end of the cycle, got %v", storage.saved)
+	}
+}
+
+func TestEthParserCycleDeadline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := parser.NewMemoryStorage()
+	storage.SaveCheckpoint(5)
+	clock := parser.NewManualClock(time.Now())
+	var stopAt string
+	client := &blockHookClient{JsonRpcClient: NewMockClient(recoveryBlockchain()), hook: func(number string) {
+		clock.Advance(time.Second)
+		if number == stopAt {
+			cancel()
+		}
+	}}
+	ethParser := parser.NewEthParser(ctx, storage, 1, client, func(string, []parser.Transaction) {},
+		parser.WithClock(clock), parser.WithBackpressure(20, 0), parser.WithCheckpoint(storage),
+		parser.WithCycleDeadline(5*time.Second))
+	defer ethParser.WaitForShutdown()
+
+	ethParser.ProcessNextCycle()
+	if checkpoint, _, _ := storage.LoadCheckpoint(); checkpoint != 10 {
+		t.Fatalf("Expected the cycle to stop at its deadline after block 10, got %d", checkpoint)
+	}
+	if stats := ethParser.BackpressureStats(); stats.LastProcessedBlock != 10 {
+		t.Errorf("Expected the processed blocks to be kept, got %+v", stats)
+	}
+
+	// A shutdown stops the cycle after its current block
+	stopAt = "0xc"
+	ethParser.ProcessNextCycle()
+	if checkpoint, _, _ := storage.LoadCheckpoint(); checkpoint != 12 {
+		t.Errorf("Expected the cycle to stop after block 12 on shutdown, got %d", checkpoint)
+	}
+}
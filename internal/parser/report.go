@@ -0,0 +1,349 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StageReports is the name of the pipeline stage accumulating the activity of the addresses for the reports
+const StageReports = "reports"
+
+// EventReportGenerated is sent for every generated report, with its summary
+const EventReportGenerated = "report_generated"
+
+// Report schedules, see WithReports. Weeks start on Monday, periods are in UTC.
+const (
+	ReportDaily  = "daily"
+	ReportWeekly = "weekly"
+)
+
+const (
+	defaultReportRetention  = 500
+	reportTopCounterparties = 5
+)
+
+// reportPeriods are the lengths of the report schedules
+var reportPeriods = map[string]time.Duration{
+	ReportDaily:  24 * time.Hour,
+	ReportWeekly: 7 * 24 * time.Hour,
+}
+
+// Report summarizes the activity of an address, or of an entity grouping addresses, over a period.
+// Values are decimal strings in wei; the transfers between the addresses of an entity are counted in
+// TransactionCount but not in the totals.
+type Report struct {
+	ID       int    `json:"id"`
+	Schedule string `json:"schedule"`
+	Address  string `json:"address,omitempty"`
+	Entity   string `json:"entity,omitempty"`
+	// Tenant is the tenant of the reported entity, empty for the entities of the shared parser
+	Tenant            string               `json:"tenant,omitempty"`
+	PeriodStart       time.Time            `json:"periodStart"`
+	PeriodEnd         time.Time            `json:"periodEnd"`
+	FromBlock         int                  `json:"fromBlock"`
+	ToBlock           int                  `json:"toBlock"`
+	TransactionCount  int                  `json:"transactionCount"`
+	Sent              int                  `json:"sent"`
+	Received          int                  `json:"received"`
+	TotalIn           string               `json:"totalIn"`
+	TotalOut          string               `json:"totalOut"`
+	Net               string               `json:"net"`
+	FeeSpend          string               `json:"feeSpend"`
+	UnknownFees       int                  `json:"unknownFees,omitempty"` // sent transactions whose receipt could not be read
+	TopCounterparties []ReportCounterparty `json:"topCounterparties"`
+}
+
+// ReportCounterparty is a counterparty of the period of a Report, ordered by transaction count
+type ReportCounterparty struct {
+	Address          string `json:"address"`
+	Label            string `json:"label,omitempty"`
+	TransactionCount int    `json:"transactionCount"`
+	Value            string `json:"value"`
+}
+
+// reportPeriod is the running aggregate of the current period of an address or entity
+type reportPeriod struct {
+	fromBlock, toBlock int
+	transactions       int
+	sent, received     int
+	in, out, fees      *big.Int
+	unknownFees        int
+	counterparties     map[string]*reportCounterpartyStats
+	hashes             map[string]bool // transactions already counted, an entity sees them once per member
+	entity             entityKey
+	subjectAddress     string
+}
+
+// reportCounterpartyStats is the running aggregate of a ReportCounterparty
+type reportCounterpartyStats struct {
+	count int
+	value *big.Int
+}
+
+// Reports returns the generated reports, the most recent first
+func (p *EthParser) Reports() []Report {
+	p.reportsMu.Lock()
+	defer p.reportsMu.Unlock()
+	reports := make([]Report, len(p.reports))
+	for i, report := range p.reports {
+		reports[len(p.reports)-1-i] = report
+	}
+	return reports
+}
+
+// GetReport returns a generated report by ID, false when it's unknown or no longer retained
+func (p *EthParser) GetReport(id int) (Report, bool) {
+	p.reportsMu.Lock()
+	defer p.reportsMu.Unlock()
+	for _, report := range p.reports {
+		if report.ID == id {
+			return report, true
+		}
+	}
+	return Report{}, false
+}
+
+// reportsStage adds the matched transactions of the block to the current period of their address and entity
+func (p *EthParser) reportsStage(ctx context.Context, block *BlockContext) error {
+	if p.reportSchedule == "" {
+		return nil
+	}
+	p.mu.Lock()
+	entities := make(map[string][]entityKey, len(block.Matches))
+	members := make(map[entityKey]map[string]bool)
+	for address := range block.Matches {
+		for namespace, entityID := range p.addressEntities[address] {
+			entity := entityKey{namespace, entityID}
+			entities[address] = append(entities[address], entity)
+			members[entity] = make(map[string]bool, len(p.entities[entity]))
+			for member := range p.entities[entity] {
+				members[entity][strings.ToLower(member)] = true
+			}
+		}
+	}
+	p.mu.Unlock()
+
+	for address, transactions := range block.Matches {
+		isAddress := func(other string) bool { return strings.EqualFold(other, address) }
+		memberOf := func(entity entityKey) func(string) bool {
+			return func(other string) bool { return members[entity][strings.ToLower(other)] }
+		}
+		for _, tx := range transactions {
+			// The fee is paid by the sender, it's read before locking
+			var fee *big.Int
+			paid := isAddress(tx.From)
+			for _, entity := range entities[address] {
+				paid = paid || memberOf(entity)(tx.From)
+			}
+			if paid {
+				fee = p.transactionFee(tx)
+			}
+			p.reportsMu.Lock()
+			p.reportPeriodFor(address, entityKey{}).add(tx, isAddress, fee)
+			for _, entity := range entities[address] {
+				p.reportPeriodFor("", entity).add(tx, memberOf(entity), fee)
+			}
+			p.reportsMu.Unlock()
+		}
+	}
+	return nil
+}
+
+// reportPeriodFor returns the current period of an address or of an entity, reportsMu must be held
+func (p *EthParser) reportPeriodFor(address string, entity entityKey) *reportPeriod {
+	key := address
+	if entity.id != "" {
+		key = "entity:" + entity.namespace + "/" + entity.id
+	}
+	period := p.reportPeriods[key]
+	if period == nil {
+		period = &reportPeriod{
+			in:             new(big.Int),
+			out:            new(big.Int),
+			fees:           new(big.Int),
+			counterparties: make(map[string]*reportCounterpartyStats),
+			hashes:         make(map[string]bool),
+			entity:         entity,
+			subjectAddress: address,
+		}
+		p.reportPeriods[key] = period
+	}
+	return period
+}
+
+// add counts a transaction in the period, subject reports whether an address is the reported address or a
+// member of the reported entity. fee is nil when the fee of a sent transaction could not be read.
+func (r *reportPeriod) add(tx Transaction, subject func(string) bool, fee *big.Int) {
+	if r.hashes[tx.Hash] {
+		return
+	}
+	r.hashes[tx.Hash] = true
+	if r.transactions == 0 || tx.BlockNumberDecimal < r.fromBlock {
+		r.fromBlock = tx.BlockNumberDecimal
+	}
+	r.toBlock = max(r.toBlock, tx.BlockNumberDecimal)
+	r.transactions++
+
+	sent := subject(tx.From)
+	counterparty := tx.To
+	if !sent {
+		counterparty = tx.From
+	}
+	if sent && fee != nil {
+		r.fees.Add(r.fees, fee)
+	} else if sent {
+		r.unknownFees++
+	}
+	// Self and internal transfers move no value in or out
+	if counterparty != "" && subject(counterparty) {
+		return
+	}
+	value, ok := parseQuantity(tx.Value)
+	if !ok {
+		value = new(big.Int)
+	}
+	if sent {
+		r.sent++
+		r.out.Add(r.out, value)
+	} else {
+		r.received++
+		r.in.Add(r.in, value)
+	}
+	if counterparty == "" {
+		// Contract deployments have no counterparty
+		return
+	}
+	stats := r.counterparties[counterparty]
+	if stats == nil {
+		stats = &reportCounterpartyStats{value: new(big.Int)}
+		r.counterparties[counterparty] = stats
+	}
+	stats.count++
+	stats.value.Add(stats.value, value)
+}
+
+// transactionFee returns the fee paid by a transaction from its receipt, nil when it can't be read
+func (p *EthParser) transactionFee(tx Transaction) *big.Int {
+	var receipt TransactionReceipt
+	found, err := p.callResult("eth_getTransactionReceipt", tx.Hash, &receipt)
+	if err != nil || !found {
+		return nil
+	}
+	price := receipt.EffectiveGasPrice
+	if price == "" {
+		// Nodes before London only return the gas price of the transaction
+		price = tx.GasPrice
+	}
+	gasUsed, okGas := parseQuantity(receipt.GasUsed)
+	gasPrice, okPrice := parseQuantity(price)
+	if !okGas || !okPrice {
+		return nil
+	}
+	return gasUsed.Mul(gasUsed, gasPrice)
+}
+
+// runReports generates the reports when their period ends, until ctx is canceled
+func (p *EthParser) runReports(ctx context.Context, ticker Ticker) {
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			p.generateDueReports(p.clock.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// generateDueReports closes the current period when the schedule boundary has passed since it started,
+// turning the aggregates with activity into reports retained for download and sent as events
+func (p *EthParser) generateDueReports(now time.Time) {
+	p.reportsMu.Lock()
+	if !p.reportsSince.Before(now.Truncate(reportPeriods[p.reportSchedule])) {
+		p.reportsMu.Unlock()
+		return
+	}
+	start := p.reportsSince
+	periods := p.reportPeriods
+	p.reportsSince = now
+	p.reportPeriods = make(map[string]*reportPeriod)
+	p.reportsMu.Unlock()
+
+	keys := make([]string, 0, len(periods))
+	for key := range periods {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		report := p.buildReport(periods[key], start, now)
+		p.reportsMu.Lock()
+		p.reportSeq++
+		report.ID = p.reportSeq
+		p.reports = append(p.reports, report)
+		if len(p.reports) > p.reportRetention {
+			p.reports = p.reports[len(p.reports)-p.reportRetention:]
+		}
+		p.reportsMu.Unlock()
+
+		p.emitEvent(Event{Type: EventReportGenerated, Address: report.Address, Data: map[string]string{
+			"id":               fmt.Sprint(report.ID),
+			"schedule":         report.Schedule,
+			"entity":           report.Entity,
+			"tenant":           report.Tenant,
+			"periodStart":      report.PeriodStart.Format(time.RFC3339),
+			"periodEnd":        report.PeriodEnd.Format(time.RFC3339),
+			"transactionCount": fmt.Sprint(report.TransactionCount),
+			"totalIn":          report.TotalIn,
+			"totalOut":         report.TotalOut,
+			"net":              report.Net,
+			"feeSpend":         report.FeeSpend,
+		}})
+	}
+}
+
+// buildReport turns the aggregate of a period into a Report
+func (p *EthParser) buildReport(period *reportPeriod, start time.Time, end time.Time) Report {
+	report := Report{
+		Schedule:          p.reportSchedule,
+		Address:           period.subjectAddress,
+		Entity:            period.entity.id,
+		Tenant:            period.entity.namespace,
+		PeriodStart:       start.UTC(),
+		PeriodEnd:         end.UTC(),
+		FromBlock:         period.fromBlock,
+		ToBlock:           period.toBlock,
+		TransactionCount:  period.transactions,
+		Sent:              period.sent,
+		Received:          period.received,
+		TotalIn:           period.in.String(),
+		TotalOut:          period.out.String(),
+		Net:               new(big.Int).Sub(period.in, period.out).String(),
+		FeeSpend:          period.fees.String(),
+		UnknownFees:       period.unknownFees,
+		TopCounterparties: []ReportCounterparty{},
+	}
+	for address, stats := range period.counterparties {
+		counterparty := ReportCounterparty{Address: address, TransactionCount: stats.count, Value: stats.value.String()}
+		if p.labels != nil {
+			if label, ok := p.labels.Label(address); ok {
+				counterparty.Label = label.Name
+			}
+		}
+		report.TopCounterparties = append(report.TopCounterparties, counterparty)
+	}
+	sort.Slice(report.TopCounterparties, func(i, j int) bool {
+		a, b := report.TopCounterparties[i], report.TopCounterparties[j]
+		if a.TransactionCount != b.TransactionCount {
+			return a.TransactionCount > b.TransactionCount
+		}
+		return a.Address < b.Address
+	})
+	if len(report.TopCounterparties) > reportTopCounterparties {
+		report.TopCounterparties = report.TopCounterparties[:reportTopCounterparties]
+	}
+	return report
+}
@@ -0,0 +1,82 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEthParserDailyReports(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{
+		Number: "0x1",
+		Transactions: []parser.Transaction{
+			{Hash: "0xa1", From: "0x1", To: "0x2", Value: "0x10", GasPrice: "0x2"},
+			{Hash: "0xa2", From: "0x3", To: "0x1", Value: "0x100"},
+			{Hash: "0xa3", From: "0x1", To: "0x4", Value: "0x5", GasPrice: "0x1"},
+			{Hash: "0xa4", From: "0x3", To: "0x1", Value: "0x1"},
+		},
+	})
+	var mu sync.Mutex
+	var events []parser.Event
+	clock := parser.NewManualClock(time.Date(2024, 1, 1, 22, 0, 0, 0, time.UTC))
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain), func(string, []parser.Transaction) {},
+		parser.WithClock(clock), parser.WithReports(parser.ReportDaily, 0),
+		parser.WithEventNotification(func(event parser.Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, event)
+		}))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.AddToEntity("treasury", "0x1")
+	ethParser.AddToEntity("treasury", "0x4")
+	ethParser.ProcessNextCycle()
+
+	// Nothing is generated before the end of the day
+	clock.Advance(time.Hour)
+	time.Sleep(10 * time.Millisecond)
+	if reports := ethParser.Reports(); len(reports) != 0 {
+		t.Fatalf("Expected no report before midnight, got %+v", reports)
+	}
+	clock.Advance(time.Hour)
+	waitUntil(t, func() bool { return len(ethParser.Reports()) == 3 })
+
+	reports := make(map[string]parser.Report)
+	for _, report := range ethParser.Reports() {
+		reports[report.Address+report.Entity] = report
+	}
+	// The receipts of the mock node use 21000 gas
+	address := reports["0x1"]
+	if address.TransactionCount != 4 || address.Sent != 2 || address.Received != 2 || address.TotalIn != "257" ||
+		address.TotalOut != "21" || address.Net != "236" || address.FeeSpend != "63000" || address.FromBlock != 1 {
+		t.Errorf("Unexpected address report %+v", address)
+	}
+	if len(address.TopCounterparties) != 3 || address.TopCounterparties[0] != (parser.ReportCounterparty{Address: "0x3", TransactionCount: 2, Value: "257"}) {
+		t.Errorf("Expected the counterparties by transaction count, got %+v", address.TopCounterparties)
+	}
+	if !address.PeriodEnd.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)) || address.Schedule != parser.ReportDaily {
+		t.Errorf("Unexpected period %v - %v", address.PeriodStart, address.PeriodEnd)
+	}
+
+	// The transfer to the other member of the entity is not in its totals
+	entity := reports["treasury"]
+	if entity.TransactionCount != 4 || entity.Sent != 1 || entity.TotalOut != "16" || entity.TotalIn != "257" || entity.FeeSpend != "63000" {
+		t.Errorf("Unexpected entity report %+v", entity)
+	}
+
+	report, found := ethParser.GetReport(address.ID)
+	if !found || report.Address != "0x1" {
+		t.Errorf("Expected report %d, got %+v", address.ID, report)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 3 || events[0].Type != parser.EventReportGenerated {
+		t.Errorf("Expected a report event per report, got %+v", events)
+	}
+}
@@ -0,0 +1,230 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// Rescan modes
+const (
+	// RescanMerge keeps the stored transactions of the range and updates the rescanned ones
+	RescanMerge = "merge"
+	// RescanOverwrite replaces the stored transactions of the range with the rescanned ones
+	RescanOverwrite = "overwrite"
+)
+
+// maxRescanBlocks bounds the block range of a rescan
+const maxRescanBlocks = 10000
+
+// ErrInvalidRescan is returned for a rescan request with an invalid range, mode or address
+var ErrInvalidRescan = errors.New("invalid rescan request")
+
+// rescanSkippedStages are the pipeline stages with side effects that a rescan doesn't run again: the block
+// processors, the activity index, the allowances, the deployments, the reports and the notifications already saw
+// the blocks, and the rescan stores itself and only counts the transactions it found in the counterparties
+var rescanSkippedStages = map[string]bool{
+	StageProcessors:     true,
+	StageActivity:       true,
+	StageStore:          true,
+	StageCounterparties: true,
+	StageAllowances:     true,
+	StageDeployments:    true,
+	StageReports:        true,
+	StageNotify:         true,
+}
+
+// RescanRequest is a request to run the matching again over already processed blocks
+type RescanRequest struct {
+	FromBlock int `json:"fromBlock"`
+	ToBlock   int `json:"toBlock"`
+	// Addresses to rescan, all the subscribed addresses when empty
+	Addresses []string `json:"addresses,omitempty"`
+	// Mode is RescanMerge, the default, or RescanOverwrite
+	Mode string `json:"mode,omitempty"`
+}
+
+// RescanResult reports the outcome of a rescan
+type RescanResult struct {
+	Blocks int `json:"blocks"`
+	// Failed counts the blocks skipped because a stage failed, their stored transactions are left untouched
+	Failed       int `json:"failed"`
+	Transactions int `json:"transactions"`
+	// Skipped counts the blocks of a merge rescan where the activity index shows that none of the addresses was
+	// active, they're not fetched
+	Skipped int `json:"skipped,omitempty"`
+}
+
+// Rescan runs the pipeline again, without the stages with side effects, over already processed blocks and
+// stores the result, e.g. after changing the filters or adding an enrichment. The rescanned blocks are not
+// notified again. It waits for the running fetch cycle and blocks the next one until it completes.
+func (p *EthParser) Rescan(request RescanRequest) (RescanResult, error) {
+	if request.Mode == "" {
+		request.Mode = RescanMerge
+	}
+	if request.Mode != RescanMerge && request.Mode != RescanOverwrite {
+		return RescanResult{}, fmt.Errorf("%w: unknown mode %q", ErrInvalidRescan, request.Mode)
+	}
+
+	p.cycleMu.Lock()
+	defer p.cycleMu.Unlock()
+
+	p.mu.Lock()
+	processed := p.lastProcessedBlock
+	subscribed := make(map[string]bool)
+	if len(request.Addresses) == 0 {
+		for address := range p.subscriptions {
+			subscribed[address] = true
+		}
+	}
+	for _, address := range request.Addresses {
+		if _, ok := p.subscriptions[address]; !ok {
+			p.mu.Unlock()
+			return RescanResult{}, fmt.Errorf("%w: address %s not subscribed", ErrInvalidRescan, address)
+		}
+		subscribed[address] = true
+	}
+	matcher := p.newMatcher(subscribed)
+	p.mu.Unlock()
+
+	switch {
+	case request.FromBlock < 0 || request.FromBlock > request.ToBlock:
+		return RescanResult{}, fmt.Errorf("%w: block range %d-%d", ErrInvalidRescan, request.FromBlock, request.ToBlock)
+	case request.ToBlock > processed:
+		return RescanResult{}, fmt.Errorf("%w: block %d not processed yet, last processed is %d", ErrInvalidRescan, request.ToBlock, processed)
+	case request.ToBlock-request.FromBlock+1 > maxRescanBlocks:
+		return RescanResult{}, fmt.Errorf("%w: more than %d blocks", ErrInvalidRescan, maxRescanBlocks)
+	}
+
+	var stages []*pipelineStage
+	for _, stage := range p.pipeline {
+		if !rescanSkippedStages[stage.Name] {
+			stages = append(stages, stage)
+		}
+	}
+
+	// Merging the transactions of a block without activity of the addresses changes nothing
+	var inactive []ActivitySpan
+	if request.Mode == RescanMerge && p.activityStore != nil {
+		var err error
+		if inactive, err = p.inactiveBlocks(subscribed, request.FromBlock, request.ToBlock); err != nil {
+			log.Printf("Error reading the activity index, rescanning all the blocks: %v\n", err)
+		}
+	}
+
+	result := RescanResult{}
+	discovered := processed + 1
+	for number := request.FromBlock; number <= request.ToBlock; number++ {
+		for len(inactive) > 0 && inactive[0].ToBlock < number {
+			inactive = inactive[1:]
+		}
+		if len(inactive) > 0 && inactive[0].FromBlock <= number {
+			result.Blocks++
+			result.Skipped++
+			continue
+		}
+		block := &BlockContext{Number: number, Subscribed: subscribed, Matcher: matcher, Matches: make(map[string][]Transaction)}
+		result.Blocks++
+		if !p.runStages(stages, block) && !block.Done {
+			result.Failed++
+			continue
+		}
+		found := make(map[string][]Transaction)
+		err := p.storage.WithTx(func(tx StorageTx) error {
+			for address := range subscribed {
+				stored := p.storage.GetTransactions(address)
+				transactions := withDiscoveredBlock(stored, number, block.Matches[address], discovered)
+				found[address] = unstoredTransactions(stored, number, transactions)
+				if request.Mode == RescanMerge {
+					transactions = mergeRescanned(stored, number, transactions)
+				}
+				if err := tx.DeleteTransactions(address, number, number); err != nil {
+					return err
+				}
+				if len(transactions) > 0 {
+					if err := tx.SaveTransactions(address, transactions); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err := p.recordStorageWrite(err); err != nil {
+			log.Printf("Error storing rescanned block %d: %v\n", number, err)
+			result.Failed++
+			continue
+		}
+		for _, transactions := range block.Matches {
+			result.Transactions += len(transactions)
+		}
+		for address, transactions := range found {
+			p.recordCounterparties(address, transactions)
+		}
+	}
+	log.Printf("Rescanned blocks %d to %d: %+v\n", request.FromBlock, request.ToBlock, result)
+	return result, nil
+}
+
+// unstoredTransactions returns the rescanned transactions missing from the stored ones of the block
+func unstoredTransactions(stored []Transaction, blockNumber int, rescanned []Transaction) []Transaction {
+	known := make(map[string]bool)
+	for _, tx := range stored {
+		if tx.BlockNumberDecimal == blockNumber {
+			known[tx.Hash] = true
+		}
+	}
+	var missing []Transaction
+	for _, tx := range rescanned {
+		if !known[tx.Hash] {
+			missing = append(missing, tx)
+		}
+	}
+	return missing
+}
+
+// withDiscoveredBlock returns the rescanned transactions with the DiscoveredBlock of their stored copy, discovered
+// for the ones not stored yet
+func withDiscoveredBlock(stored []Transaction, blockNumber int, rescanned []Transaction, discovered int) []Transaction {
+	known := make(map[string]int)
+	for _, tx := range stored {
+		if tx.BlockNumberDecimal == blockNumber {
+			known[tx.Hash] = tx.DiscoveredBlock
+		}
+	}
+	annotated := make([]Transaction, len(rescanned))
+	for i, tx := range rescanned {
+		tx.DiscoveredBlock = discovered
+		if block, ok := known[tx.Hash]; ok {
+			tx.DiscoveredBlock = block
+		}
+		annotated[i] = tx
+	}
+	return annotated
+}
+
+// mergeRescanned returns the stored transactions of the block updated with the rescanned ones, and the
+// rescanned ones not stored yet
+func mergeRescanned(stored []Transaction, blockNumber int, rescanned []Transaction) []Transaction {
+	byHash := make(map[string]Transaction, len(rescanned))
+	for _, tx := range rescanned {
+		byHash[tx.Hash] = tx
+	}
+	merged := []Transaction{}
+	seen := make(map[string]bool)
+	for _, tx := range stored {
+		if tx.BlockNumberDecimal != blockNumber {
+			continue
+		}
+		if updated, ok := byHash[tx.Hash]; ok {
+			tx = updated
+			seen[tx.Hash] = true
+		}
+		merged = append(merged, tx)
+	}
+	for _, tx := range rescanned {
+		if !seen[tx.Hash] {
+			merged = append(merged, tx)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,76 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestEthParserRescan(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{
+		Number:       "0x1",
+		Transactions: []parser.Transaction{{Hash: "0xa1", From: "0x1", To: "0x2", Value: "100"}},
+	})
+	mockBlockchain.AddBlock(2, parser.Block{
+		Number:       "0x2",
+		Transactions: []parser.Transaction{{Hash: "0xa2", From: "0x2", To: "0x1", Value: "200"}},
+	})
+	storage := parser.NewMemoryStorage()
+	notifications := 0
+	ethParser := parser.NewEthParser(ctx, storage, 1, NewMockClient(mockBlockchain),
+		func(string, []parser.Transaction) { notifications++ }, parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	// An address subscribed later gets the history of the rescanned blocks, without notifications
+	ethParser.Subscribe("0x2")
+	result, err := ethParser.Rescan(parser.RescanRequest{FromBlock: 1, ToBlock: 2, Addresses: []string{"0x2"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Blocks != 2 || result.Transactions != 2 || result.Failed != 0 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if transactions := ethParser.GetTransactions("0x2"); len(transactions) != 2 || transactions[0].Hash != "0xa1" {
+		t.Fatalf("Expected the history of 0x2, got %+v", transactions)
+	}
+	if notifications != 2 {
+		t.Errorf("The rescan must not notify, got %d notifications", notifications)
+	}
+
+	// Merge keeps the stored transactions the rescan doesn't find and doesn't duplicate the others
+	storage.SaveTransactions("0x1", []parser.Transaction{{Hash: "0xstale", BlockNumberDecimal: 1}})
+	if _, err := ethParser.Rescan(parser.RescanRequest{FromBlock: 1, ToBlock: 2}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	transactions := ethParser.GetTransactions("0x1")
+	if len(transactions) != 3 || transactions[0].Hash != "0xa1" || transactions[1].Hash != "0xstale" || transactions[2].Hash != "0xa2" {
+		t.Fatalf("Expected the merged transactions in block order, got %+v", transactions)
+	}
+
+	// Overwrite replaces them
+	if _, err := ethParser.Rescan(parser.RescanRequest{FromBlock: 1, ToBlock: 1, Mode: parser.RescanOverwrite}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if transactions := ethParser.GetTransactions("0x1"); len(transactions) != 2 || transactions[0].Hash != "0xa1" || transactions[1].Hash != "0xa2" {
+		t.Fatalf("Expected the stale transaction to be overwritten, got %+v", transactions)
+	}
+
+	for _, request := range []parser.RescanRequest{
+		{FromBlock: 1, ToBlock: 3},
+		{FromBlock: 2, ToBlock: 1},
+		{FromBlock: 1, ToBlock: 1, Addresses: []string{"0x9"}},
+		{FromBlock: 1, ToBlock: 1, Mode: "replace"},
+	} {
+		if _, err := ethParser.Rescan(request); !errors.Is(err, parser.ErrInvalidRescan) {
+			t.Errorf("Expected %+v to be rejected, got %v", request, err)
+		}
+	}
+}
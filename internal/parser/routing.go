@@ -0,0 +1,133 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// rateLimitCode is the JSON-RPC error code of the provider rate limits
+const rateLimitCode = -32005
+
+// MethodRoute sends the requests of some JSON-RPC methods to another endpoint than the node, e.g. a provider
+// whose free tier is generous for eth_getLogs
+type MethodRoute struct {
+	// Name identifies the endpoint in the logs and the stats, e.g. its host; a key in its URL mustn't be in it
+	Name    string
+	Methods []string
+	Client  JsonRpcClient
+}
+
+// RouteStats counts the requests of an endpoint of a MethodRouter and those that fell back to the node
+type RouteStats struct {
+	Endpoint  string `json:"endpoint"`
+	Requests  int    `json:"requests"`
+	Fallbacks int    `json:"fallbacks"`
+}
+
+// MethodRouter is a JsonRpcClient decorator routing each JSON-RPC method to the endpoint configured for it, so
+// that the quotas of several providers add up. The other methods go to the node, which also serves the
+// requests failing on their endpoint, for a transport error or a rate limit.
+type MethodRouter struct {
+	node      JsonRpcClient
+	endpoints []*MethodRoute
+	routes    map[string]*MethodRoute // per method
+	mu        sync.Mutex
+	stats     map[string]*RouteStats // per endpoint name
+}
+
+// NewMethodRouter routes the methods of the routes to their endpoint and the others to node. A method can
+// only be routed to one endpoint.
+func NewMethodRouter(node JsonRpcClient, routes []MethodRoute) (*MethodRouter, error) {
+	router := &MethodRouter{node: node, routes: make(map[string]*MethodRoute), stats: make(map[string]*RouteStats)}
+	for i := range routes {
+		route := &routes[i]
+		if route.Client == nil || route.Name == "" {
+			return nil, fmt.Errorf("route %d has no endpoint", i+1)
+		}
+		for _, method := range route.Methods {
+			if existing, ok := router.routes[method]; ok {
+				return nil, fmt.Errorf("method %s is routed to both %s and %s", method, existing.Name, route.Name)
+			}
+			router.routes[method] = route
+		}
+		router.endpoints = append(router.endpoints, route)
+		router.stats[route.Name] = &RouteStats{Endpoint: route.Name}
+	}
+	return router, nil
+}
+
+// SendRequest sends the request to the endpoint of its method, falling back to the node when it fails
+func (r *MethodRouter) SendRequest(req JSONRPCRequest) (JSONRPCResponse, error) {
+	route, ok := r.routes[req.Method]
+	if !ok {
+		return r.node.SendRequest(req)
+	}
+	resp, err := route.Client.SendRequest(req)
+	fallback := err != nil && (resp.Error == nil || rateLimited(resp))
+	r.mu.Lock()
+	r.stats[route.Name].Requests++
+	if fallback {
+		r.stats[route.Name].Fallbacks++
+	}
+	r.mu.Unlock()
+	if !fallback {
+		return resp, err
+	}
+	log.Printf("Error sending %s to %s, falling back to the node: %v\n", req.Method, route.Name, redactedError(err))
+	return r.node.SendRequest(req)
+}
+
+// Stats returns the stats of the endpoints ordered by name
+func (r *MethodRouter) Stats() []RouteStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := make([]RouteStats, 0, len(r.stats))
+	for _, endpoint := range r.stats {
+		stats = append(stats, *endpoint)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Endpoint < stats[j].Endpoint })
+	return stats
+}
+
+// CancelRequests aborts the in-flight requests of the node and of the endpoints, see RequestCanceler
+func (r *MethodRouter) CancelRequests() {
+	clients := []JsonRpcClient{r.node}
+	for _, route := range r.endpoints {
+		clients = append(clients, route.Client)
+	}
+	for _, client := range clients {
+		if canceler, ok := client.(RequestCanceler); ok {
+			canceler.CancelRequests()
+		}
+	}
+}
+
+// rateLimited reports whether a response is the JSON-RPC error of a provider rate limit
+func rateLimited(resp JSONRPCResponse) bool {
+	rpcError, ok := resp.Error.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	switch code := rpcError["code"].(type) {
+	case json.Number:
+		value, err := code.Int64()
+		return err == nil && value == rateLimitCode
+	case float64:
+		return code == rateLimitCode
+	}
+	return false
+}
+
+// redactedError strips the URL of an HTTP client error, which may hold the key of the provider
+func redactedError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package parser_test
+
+import (
+	"errors"
+	"eth-parser/internal/parser"
+	"testing"
+)
+
+// endpointClient records the methods it's sent and answers them, or fails them with err or a JSON-RPC error
+type endpointClient struct {
+	methods  []string
+	err      error
+	rpcError interface{}
+}
+
+func (c *endpointClient) SendRequest(req parser.JSONRPCRequest) (parser.JSONRPCResponse, error) {
+	c.methods = append(c.methods, req.Method)
+	if c.rpcError != nil {
+		return parser.JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Error: c.rpcError}, errors.New("JSON-RPC error")
+	}
+	if c.err != nil {
+		return parser.JSONRPCResponse{}, c.err
+	}
+	return parser.JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: "0x1"}, nil
+}
+
+func TestMethodRouter(t *testing.T) {
+	node, logs, blocks := &endpointClient{}, &endpointClient{}, &endpointClient{}
+	router, err := parser.NewMethodRouter(node, []parser.MethodRoute{
+		{Name: "logs.example", Methods: []string{"eth_getLogs"}, Client: logs},
+		{Name: "blocks.example", Methods: []string{"eth_getBlockByNumber", "eth_blockNumber"}, Client: blocks},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, method := range []string{"eth_getLogs", "eth_blockNumber", "eth_getBlockByNumber", "eth_call"} {
+		if _, err := router.SendRequest(parser.JSONRPCRequest{JSONRPC: "2.0", Method: method, ID: 1}); err != nil {
+			t.Fatalf("Unexpected error for %s: %v", method, err)
+		}
+	}
+	if len(logs.methods) != 1 || len(blocks.methods) != 2 || len(node.methods) != 1 || node.methods[0] != "eth_call" {
+		t.Fatalf("Unexpected routing: logs %v, blocks %v, node %v", logs.methods, blocks.methods, node.methods)
+	}
+
+	// A rate limited or unreachable endpoint falls back to the node, an error of the node method doesn't
+	logs.rpcError = map[string]interface{}{"code": float64(-32005), "message": "limit exceeded"}
+	blocks.err = errors.New("connection refused")
+	router.SendRequest(parser.JSONRPCRequest{Method: "eth_getLogs"})
+	router.SendRequest(parser.JSONRPCRequest{Method: "eth_blockNumber"})
+	logs.rpcError = map[string]interface{}{"code": float64(-32000), "message": "query returned more than 10000 results"}
+	if _, err := router.SendRequest(parser.JSONRPCRequest{Method: "eth_getLogs"}); err == nil {
+		t.Error("Expected the error of the endpoint to be returned")
+	}
+	if len(node.methods) != 3 {
+		t.Errorf("Expected the node to serve the 2 failed requests, got %v", node.methods)
+	}
+	stats := router.Stats()
+	if len(stats) != 2 || stats[0].Endpoint != "blocks.example" || stats[0].Requests != 3 || stats[0].Fallbacks != 1 || stats[1].Fallbacks != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	if _, err := parser.NewMethodRouter(node, []parser.MethodRoute{
+		{Name: "a", Methods: []string{"eth_getLogs"}, Client: logs},
+		{Name: "b", Methods: []string{"eth_getLogs"}, Client: blocks},
+	}); err == nil {
+		t.Error("Expected a method routed twice to be refused")
+	}
+}
@@ -0,0 +1,259 @@
+package parser
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StageScreening is the name of the pipeline stage screening the counterparties, see WithScreener
+const StageScreening = "screening"
+
+// EventFlaggedCounterparty is sent, with the PriorityHigh priority, when a subscribed address transacts with a
+// counterparty flagged by a Screener
+const EventFlaggedCounterparty = "flagged_counterparty"
+
+// screeningTTL is how long the verdict on a counterparty is kept before it's screened again, so that an
+// address added to a list is flagged within a day
+const screeningTTL = 24 * time.Hour
+
+// Screener screens the counterparties of the subscribed addresses, e.g. against a sanctions list such as the
+// OFAC SDN list, see WithScreener
+type Screener interface {
+	Screen(ctx context.Context, address string) (ScreeningResult, error)
+}
+
+// ScreeningResult is the verdict of a Screener on an address
+type ScreeningResult struct {
+	Flagged bool
+	// Reason is why the address is flagged, e.g. the list naming it
+	Reason string
+}
+
+// ScreeningFlag tags a transaction with a counterparty flagged by a Screener
+type ScreeningFlag struct {
+	Counterparty string `json:"counterparty"`
+	Screener     string `json:"screener"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// namedScreener is a Screener registered with WithScreener
+type namedScreener struct {
+	name     string
+	screener Screener
+}
+
+// screeningVerdict is the cached verdict on a counterparty, flag is nil for a clean one
+type screeningVerdict struct {
+	flag       *ScreeningFlag
+	screenedAt time.Time
+}
+
+// screeningStage screens the counterparties of the matched transactions, tags the transactions with a flagged
+// counterparty and alerts on them. The counterparties are screened when first seen, then once a day; a
+// failing screener leaves the transactions untagged.
+func (p *EthParser) screeningStage(ctx context.Context, block *BlockContext) error {
+	if len(p.screeners) == 0 {
+		return nil
+	}
+	var errs []error
+	for address, transactions := range block.Matches {
+		for i := range transactions {
+			tx := &transactions[i]
+			counterparty, _ := counterpartyOf(address, *tx)
+			if counterparty == "" || counterparty == address {
+				continue
+			}
+			flag, err := p.screen(ctx, counterparty)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if flag == nil {
+				continue
+			}
+			tx.ScreeningFlag = flag
+			p.emitEvent(Event{Type: EventFlaggedCounterparty, Address: address, Data: map[string]string{
+				"counterparty": flag.Counterparty,
+				"screener":     flag.Screener,
+				"reason":       flag.Reason,
+				"hash":         tx.Hash,
+				"priority":     PriorityHigh,
+			}})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// screen returns the flag of a counterparty, nil when no screener flags it
+func (p *EthParser) screen(ctx context.Context, counterparty string) (*ScreeningFlag, error) {
+	key := strings.ToLower(counterparty)
+	now := p.clock.Now()
+	p.screeningMu.Lock()
+	verdict, screened := p.screened[key]
+	p.screeningMu.Unlock()
+	if screened && now.Sub(verdict.screenedAt) < screeningTTL {
+		return verdict.flag, nil
+	}
+
+	verdict = screeningVerdict{screenedAt: now}
+	for _, registered := range p.screeners {
+		result, err := registered.screener.Screen(ctx, counterparty)
+		if err != nil {
+			return nil, fmt.Errorf("screening %s with %s: %w", counterparty, registered.name, err)
+		}
+		if result.Flagged {
+			verdict.flag = &ScreeningFlag{Counterparty: key, Screener: registered.name, Reason: result.Reason}
+			break
+		}
+	}
+	p.screeningMu.Lock()
+	p.screened[key] = verdict
+	p.screeningMu.Unlock()
+	return verdict.flag, nil
+}
+
+// DenylistScreener flags the addresses of a local denylist file, e.g. the sanctioned addresses of the OFAC
+// SDN list. The file has an address per line, optionally followed by a comma and the reason; the empty lines
+// and the ones starting with # are ignored.
+type DenylistScreener struct {
+	path    string
+	mu      sync.RWMutex
+	entries map[string]string // address -> reason
+}
+
+// NewDenylistScreener loads the denylist file at path
+func NewDenylistScreener(path string) (*DenylistScreener, error) {
+	s := &DenylistScreener{path: path}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload reads the denylist file again, e.g. after an update of the list. The current entries are kept when
+// it fails.
+func (s *DenylistScreener) Reload() error {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	entries := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		address, reason, _ := strings.Cut(text, ",")
+		address = strings.ToLower(strings.TrimSpace(address))
+		if len(address) != 42 || !strings.HasPrefix(address, "0x") {
+			return fmt.Errorf("parsing %s: invalid address %q on line %d", s.path, address, line)
+		}
+		reason = strings.TrimSpace(reason)
+		if reason == "" {
+			reason = "denylisted"
+		}
+		entries[address] = reason
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading %s: %w", s.path, err)
+	}
+
+	s.mu.Lock()
+	s.entries = entries
+	s.mu.Unlock()
+	return nil
+}
+
+// Screen flags the addresses of the denylist
+func (s *DenylistScreener) Screen(ctx context.Context, address string) (ScreeningResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	reason, flagged := s.entries[strings.ToLower(address)]
+	return ScreeningResult{Flagged: flagged, Reason: reason}, nil
+}
+
+// APIScreener screens the addresses with an external screening API. The {address} placeholder of the URL is
+// replaced by the address, e.g. https://public.chainalysis.com/api/v1/address/{address}, and the API key is
+// sent in the X-API-Key header. The response is either {"flagged": bool, "reason": string} or, like the
+// Chainalysis sanctions API, {"identifications": [{"category", "name"}]}, an address with an identification
+// being flagged.
+type APIScreener struct {
+	url        string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewAPIScreener creates an APIScreener for the URL, apiKey is optional
+func NewAPIScreener(url string, apiKey string) *APIScreener {
+	return &APIScreener{url: url, apiKey: apiKey, httpClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// WithHTTPClient replaces the HTTP client of the requests
+func (s *APIScreener) WithHTTPClient(client *http.Client) *APIScreener {
+	s.httpClient = client
+	return s
+}
+
+// Screen queries the API for an address
+func (s *APIScreener) Screen(ctx context.Context, address string) (ScreeningResult, error) {
+	requestURL := strings.ReplaceAll(s.url, "{address}", url.PathEscape(address))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return ScreeningResult{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("X-API-Key", s.apiKey)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return ScreeningResult{}, redactedError(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return ScreeningResult{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return ScreeningResult{}, fmt.Errorf("screening API replied %s", resp.Status)
+	}
+
+	var verdict struct {
+		Flagged         bool   `json:"flagged"`
+		Reason          string `json:"reason"`
+		Identifications []struct {
+			Category string `json:"category"`
+			Name     string `json:"name"`
+		} `json:"identifications"`
+	}
+	if err := json.Unmarshal(body, &verdict); err != nil {
+		return ScreeningResult{}, fmt.Errorf("decoding the screening API response: %w", err)
+	}
+	result := ScreeningResult{Flagged: verdict.Flagged, Reason: verdict.Reason}
+	if len(verdict.Identifications) > 0 {
+		identification := verdict.Identifications[0]
+		result.Flagged = true
+		if result.Reason == "" {
+			result.Reason = identification.Category
+			if identification.Name != "" && result.Reason != "" {
+				result.Reason += ": " + identification.Name
+			} else if identification.Name != "" {
+				result.Reason = identification.Name
+			}
+		}
+	}
+	return result, nil
+}
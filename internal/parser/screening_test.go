@@ -0,0 +1,133 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingScreener counts its calls and flags nothing
+type countingScreener struct{ calls int }
+
+func (s *countingScreener) Screen(ctx context.Context, address string) (parser.ScreeningResult, error) {
+	s.calls++
+	return parser.ScreeningResult{}, nil
+}
+
+func TestScreeningFlagsDenylistedCounterparty(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	content := "# OFAC SDN\n0x000000000000000000000000000000000000bAd1, SDN: Example Mixer\n\n0x000000000000000000000000000000000000bad2\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	denylist, err := parser.NewDenylistScreener(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{
+		Number: "0x1",
+		Transactions: []parser.Transaction{
+			{Hash: "0xflagged", From: "0x000000000000000000000000000000000000bad1", To: "0x1", Value: "0x10"},
+			{Hash: "0xclean", From: "0x1", To: "0x2", Value: "0x10"},
+		},
+	})
+	var events []parser.Event
+	var notified []parser.Transaction
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain),
+		func(address string, transactions []parser.Transaction) { notified = append(notified, transactions...) },
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithScreener("denylist", denylist),
+		parser.WithEventNotification(func(event parser.Event) { events = append(events, event) }))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	if len(notified) != 2 {
+		t.Fatalf("Expected 2 notified transactions, got %v", notified)
+	}
+	for _, tx := range notified {
+		flagged := tx.ScreeningFlag != nil
+		if flagged != (tx.Hash == "0xflagged") {
+			t.Errorf("Unexpected screening flag on %s: %+v", tx.Hash, tx.ScreeningFlag)
+		}
+	}
+	if stored := ethParser.GetTransactions("0x1"); stored[0].ScreeningFlag == nil || stored[0].ScreeningFlag.Reason != "SDN: Example Mixer" {
+		t.Errorf("Expected the stored transaction to be tagged, got %+v", stored[0].ScreeningFlag)
+	}
+	if len(events) != 1 || events[0].Type != parser.EventFlaggedCounterparty || events[0].Data["priority"] != parser.PriorityHigh ||
+		events[0].Data["hash"] != "0xflagged" {
+		t.Errorf("Expected a high priority alert for the flagged transaction, got %v", events)
+	}
+}
+
+func TestScreeningCachesVerdicts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	for i := 1; i <= 3; i++ {
+		mockBlockchain.AddBlock(i, parser.Block{
+			Number:       fmt.Sprintf("0x%x", i),
+			Transactions: []parser.Transaction{{Hash: fmt.Sprintf("0xa%d", i), From: "0x1", To: "0x2", Value: "0x10"}},
+		})
+	}
+	screener := &countingScreener{}
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain),
+		func(string, []parser.Transaction) {}, parser.WithClock(parser.NewManualClock(time.Now())),
+		parser.WithScreener("counting", screener))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	if screener.calls != 1 {
+		t.Errorf("Expected the counterparty to be screened once, got %d calls", screener.calls)
+	}
+}
+
+func TestAPIScreener(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/0xbad") {
+			w.Write([]byte(`{"identifications":[{"category":"sanctions","name":"SANCTIONS: OFAC SDN"}]}`))
+			return
+		}
+		w.Write([]byte(`{"identifications":[]}`))
+	}))
+	defer server.Close()
+
+	screener := parser.NewAPIScreener(server.URL+"/api/v1/address/{address}", "secret")
+	result, err := screener.Screen(context.Background(), "0xbad")
+	if err != nil || !result.Flagged || result.Reason != "sanctions: SANCTIONS: OFAC SDN" {
+		t.Errorf("Expected the address to be flagged, got %+v, %v", result, err)
+	}
+	if result, err := screener.Screen(context.Background(), "0xgood"); err != nil || result.Flagged {
+		t.Errorf("Expected the address to be clean, got %+v, %v", result, err)
+	}
+	if _, err := parser.NewAPIScreener(server.URL+"/{address}", "").Screen(context.Background(), "0xbad"); err == nil {
+		t.Error("Expected an error without the API key")
+	}
+}
+
+func TestDenylistScreenerRejectsInvalidAddress(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	if err := os.WriteFile(path, []byte("not-an-address\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.NewDenylistScreener(path); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("Expected the invalid line to be reported, got %v", err)
+	}
+}
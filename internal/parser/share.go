@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// MaxShareLinkExpiry is the longest validity of a share link
+const MaxShareLinkExpiry = 30 * 24 * time.Hour
+
+// ErrInvalidShareLink is returned by ShareLinkSigner.Verify for an expired or forged share link
+var ErrInvalidShareLink = errors.New("invalid or expired share link")
+
+// ShareGrant is the read-only access to the transactions of an address granted by a share link
+type ShareGrant struct {
+	Address string `json:"address"`
+	// Tenant is the tenant whose subscription is shared, empty without multi-tenancy
+	Tenant    string    `json:"tenant,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// ShareLinkSigner signs the tokens of the share links with an HMAC of Secret, so that a link can't be forged or
+// extended. The tokens are stateless: a link stays valid until it expires, changing Secret revokes them all.
+type ShareLinkSigner struct {
+	Secret []byte
+}
+
+// Sign returns the token of a grant, its JSON encoding followed by its signature
+func (s ShareLinkSigner) Sign(grant ShareGrant) string {
+	grant.ExpiresAt = grant.ExpiresAt.UTC().Truncate(time.Second)
+	payload, _ := json.Marshal(grant)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.sign(encoded)
+}
+
+// Verify returns the grant of a token, ErrInvalidShareLink when its signature is invalid or it expired
+func (s ShareLinkSigner) Verify(token string, now time.Time) (ShareGrant, error) {
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found || subtle.ConstantTimeCompare([]byte(s.sign(encoded)), []byte(signature)) != 1 {
+		return ShareGrant{}, ErrInvalidShareLink
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return ShareGrant{}, ErrInvalidShareLink
+	}
+	var grant ShareGrant
+	if err := json.Unmarshal(payload, &grant); err != nil || !now.Before(grant.ExpiresAt) {
+		return ShareGrant{}, ErrInvalidShareLink
+	}
+	return grant, nil
+}
+
+// sign returns the hex HMAC of an encoded grant
+func (s ShareLinkSigner) sign(encoded string) string {
+	mac := hmac.New(sha256.New, s.Secret)
+	mac.Write([]byte("share\n" + encoded))
+	return hex.EncodeToString(mac.Sum(nil))
+}
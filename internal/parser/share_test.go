@@ -0,0 +1,31 @@
+package parser_test
+
+import (
+	"errors"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestShareLinkSigner(t *testing.T) {
+	signer := parser.ShareLinkSigner{Secret: []byte("secret")}
+	now := time.Now()
+	token := signer.Sign(parser.ShareGrant{Address: "0xabc", Tenant: "acme", ExpiresAt: now.Add(time.Hour)})
+
+	grant, err := signer.Verify(token, now)
+	if err != nil || grant.Address != "0xabc" || grant.Tenant != "acme" {
+		t.Fatalf("Expected the signed grant, got %+v, %v", grant, err)
+	}
+	if _, err := signer.Verify(token, now.Add(2*time.Hour)); !errors.Is(err, parser.ErrInvalidShareLink) {
+		t.Errorf("Expected an expired link to be rejected, got %v", err)
+	}
+	other := parser.ShareLinkSigner{Secret: []byte("rotated")}
+	if _, err := other.Verify(token, now); !errors.Is(err, parser.ErrInvalidShareLink) {
+		t.Errorf("Expected a link signed with another secret to be rejected, got %v", err)
+	}
+	// Extending the expiry of a grant invalidates its signature
+	extended := other.Sign(parser.ShareGrant{Address: "0xabc", Tenant: "acme", ExpiresAt: now.Add(48 * time.Hour)})
+	if _, err := signer.Verify(extended[:len(extended)-64]+token[len(token)-64:], now); !errors.Is(err, parser.ErrInvalidShareLink) {
+		t.Errorf("Expected a forged link to be rejected, got %v", err)
+	}
+}
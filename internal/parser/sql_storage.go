@@ -0,0 +1,449 @@
+package parser
+
+import (
+	"context"
+	"database/sql"
+	"log"
+)
+
+// sqlMigrations is the versioned schema of SQLStorage. New releases must only append to it.
+var sqlMigrations = []Migration{
+	{
+		Version:     1,
+		Description: "create transactions table",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS transactions (
+				address      TEXT    NOT NULL,
+				hash         TEXT    NOT NULL,
+				from_address TEXT    NOT NULL,
+				to_address   TEXT    NOT NULL,
+				value        TEXT    NOT NULL,
+				block_number TEXT    NOT NULL,
+				block_number_decimal INTEGER NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_transactions_address ON transactions (address, block_number_decimal)`,
+		},
+	},
+	{
+		// The full transaction is kept as JSON so new fields (e.g. EIP-4844 blob data) survive a round trip
+		Version:     2,
+		Description: "add transaction payload column",
+		Statements: []string{
+			`ALTER TABLE transactions ADD COLUMN payload TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		Version:     3,
+		Description: "create notification outbox table",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS outbox (
+				id           TEXT    PRIMARY KEY,
+				address      TEXT    NOT NULL,
+				block_number INTEGER NOT NULL,
+				payload      TEXT    NOT NULL
+			)`,
+		},
+	},
+	{
+		Version:     4,
+		Description: "create idempotency keys table",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS idempotency_keys (
+				key          TEXT    PRIMARY KEY,
+				request_hash TEXT    NOT NULL,
+				status_code  INTEGER NOT NULL,
+				content_type TEXT    NOT NULL,
+				body         TEXT    NOT NULL,
+				created_at   INTEGER NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys (created_at)`,
+		},
+	},
+	{
+		Version:     5,
+		Description: "index transactions by hash",
+		Statements: []string{
+			`CREATE INDEX IF NOT EXISTS idx_transactions_hash ON transactions (hash)`,
+		},
+	},
+	{
+		Version:     6,
+		Description: "create checkpoint table",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS checkpoint (
+				id           INTEGER PRIMARY KEY,
+				block_number INTEGER NOT NULL
+			)`,
+		},
+	},
+	{
+		Version:     7,
+		Description: "create block dead letters table",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS block_dead_letters (
+				block_number INTEGER PRIMARY KEY,
+				stage        TEXT    NOT NULL,
+				error        TEXT    NOT NULL,
+				attempts     INTEGER NOT NULL,
+				failed_at    INTEGER NOT NULL,
+				payload      TEXT    NOT NULL
+			)`,
+		},
+	},
+	{
+		Version:     8,
+		Description: "create audit log table",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS audit_log (
+				id         TEXT    PRIMARY KEY,
+				created_at INTEGER NOT NULL,
+				tenant     TEXT    NOT NULL,
+				address    TEXT    NOT NULL,
+				payload    TEXT    NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_audit_log_address ON audit_log (address)`,
+		},
+	},
+	{
+		Version:     9,
+		Description: "create subscriptions table",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS subscriptions (
+				address TEXT PRIMARY KEY,
+				payload TEXT NOT NULL
+			)`,
+		},
+	},
+	{
+		Version:     10,
+		Description: "create consumer tables",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS consumers (
+				name          TEXT    PRIMARY KEY,
+				registered_at INTEGER NOT NULL,
+				payload       TEXT    NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS consumer_events (
+				consumer TEXT    NOT NULL,
+				id       TEXT    NOT NULL,
+				seq      INTEGER NOT NULL,
+				payload  TEXT    NOT NULL,
+				PRIMARY KEY (consumer, id)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_consumer_events_seq ON consumer_events (consumer, seq)`,
+		},
+	},
+	{
+		Version:     11,
+		Description: "create rpc usage table",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS rpc_usage (
+				day    TEXT    NOT NULL,
+				method TEXT    NOT NULL,
+				calls  INTEGER NOT NULL,
+				PRIMARY KEY (day, method)
+			)`,
+		},
+	},
+	{
+		Version:     12,
+		Description: "create activity index table",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS activity_index (
+				from_block INTEGER PRIMARY KEY,
+				to_block   INTEGER NOT NULL,
+				hashes     INTEGER NOT NULL,
+				bits       TEXT    NOT NULL
+			)`,
+		},
+	},
+	{
+		Version:     13,
+		Description: "create tenants table",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS tenants (
+				id      TEXT PRIMARY KEY,
+				payload TEXT NOT NULL
+			)`,
+		},
+	},
+	{
+		// Re-processing a block after a crash inserted its transactions again, the table is rebuilt without the
+		// duplicates, keeping one row per address and hash
+		Version:     14,
+		Description: "make the transactions unique per address",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS transactions_unique (
+				address      TEXT    NOT NULL,
+				hash         TEXT    NOT NULL,
+				from_address TEXT    NOT NULL,
+				to_address   TEXT    NOT NULL,
+				value        TEXT    NOT NULL,
+				block_number TEXT    NOT NULL,
+				block_number_decimal INTEGER NOT NULL,
+				payload      TEXT    NOT NULL DEFAULT '',
+				UNIQUE (address, hash)
+			)`,
+			`INSERT INTO transactions_unique
+				(address, hash, from_address, to_address, value, block_number, block_number_decimal, payload)
+				SELECT address, hash, MIN(from_address), MIN(to_address), MIN(value), MIN(block_number),
+					MIN(block_number_decimal), MIN(payload)
+				FROM transactions GROUP BY address, hash`,
+			`DROP TABLE transactions`,
+			`ALTER TABLE transactions_unique RENAME TO transactions`,
+			`CREATE INDEX IF NOT EXISTS idx_transactions_address ON transactions (address, block_number_decimal)`,
+			`CREATE INDEX IF NOT EXISTS idx_transactions_hash ON transactions (hash)`,
+		},
+	},
+}
+
+// SQLStorage implements the Storage interface on top of a database/sql connection.
+// The driver is chosen by the caller, queries use $N placeholders (PostgreSQL, SQLite).
+type SQLStorage struct {
+	db     *sql.DB
+	cipher FieldCipher // encrypts the payloads at rest, nil stores them in plaintext
+	codec  Codec       // encodes the payloads, nil stores them in JSON
+}
+
+// NewSQLStorage creates a SQLStorage and applies pending schema migrations before returning
+func NewSQLStorage(ctx context.Context, db *sql.DB) (*SQLStorage, error) {
+	if err := Migrate(ctx, db, sqlMigrations); err != nil {
+		return nil, err
+	}
+	return &SQLStorage{db: db}, nil
+}
+
+// Close closes the database of the storage
+func (s *SQLStorage) Close() error {
+	return s.db.Close()
+}
+
+// NewEncryptedSQLStorage is NewSQLStorage encrypting the transaction payloads, the outbox events and the
+// idempotent responses with cipher. Only the columns used by the queries (watched address, hash, block
+// numbers) are kept in plaintext; the rows written before encryption was enabled are still readable.
+func NewEncryptedSQLStorage(ctx context.Context, db *sql.DB, cipher FieldCipher) (*SQLStorage, error) {
+	storage, err := NewSQLStorage(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	storage.cipher = cipher
+	return storage, nil
+}
+
+// WithCodec stores the new transaction payloads, outbox events and consumer events with codec, JSONCodec or
+// ProtobufCodec. The rows written with the other codec stay readable.
+func (s *SQLStorage) WithCodec(codec Codec) *SQLStorage {
+	s.codec = codec
+	return s
+}
+
+// SaveTransactions saves transactions for a given address
+func (s *SQLStorage) SaveTransactions(address string, transactions []Transaction) error {
+	return s.WithTx(func(tx StorageTx) error {
+		return tx.SaveTransactions(address, transactions)
+	})
+}
+
+// GetTransactions retrieves transactions for a given address ordered by block
+func (s *SQLStorage) GetTransactions(address string) []Transaction {
+	rows, err := s.db.Query(`SELECT hash, from_address, to_address, value, block_number, block_number_decimal, payload
+		FROM transactions WHERE address = $1 ORDER BY block_number_decimal`, address)
+	if err != nil {
+		log.Printf("error querying transactions for address %s: %v\n", address, err)
+		return nil
+	}
+	defer rows.Close()
+
+	var transactions []Transaction
+	for rows.Next() {
+		var tx Transaction
+		var payload string
+		if err := rows.Scan(&tx.Hash, &tx.From, &tx.To, &tx.Value, &tx.BlockNumber, &tx.BlockNumberDecimal, &payload); err != nil {
+			log.Printf("error scanning transaction for address %s: %v\n", address, err)
+			return nil
+		}
+		if err := s.decodePayload(address, &tx, payload); err != nil {
+			log.Printf("error decoding transaction %s for address %s: %v\n", tx.Hash, address, err)
+			return nil
+		}
+		transactions = append(transactions, tx)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("error reading transactions for address %s: %v\n", address, err)
+		return nil
+	}
+	return transactions
+}
+
+// Stats returns the size of the transactions table
+func (s *SQLStorage) Stats() (StorageStats, error) {
+	var stats StorageStats
+	err := s.db.QueryRow(`SELECT COUNT(DISTINCT address), COUNT(*), COALESCE(SUM(LENGTH(payload)), 0),
+		COALESCE(MIN(block_number_decimal), 0), COALESCE(MAX(block_number_decimal), 0) FROM transactions`).
+		Scan(&stats.Addresses, &stats.Transactions, &stats.ApproximateBytes, &stats.OldestBlock, &stats.NewestBlock)
+	return stats, err
+}
+
+// GetTransactionByHash returns a stored transaction and the addresses it's stored for
+func (s *SQLStorage) GetTransactionByHash(hash string) (Transaction, []string, bool, error) {
+	rows, err := s.db.Query(`SELECT address, hash, from_address, to_address, value, block_number, block_number_decimal, payload
+		FROM transactions WHERE hash = $1 ORDER BY address`, hash)
+	if err != nil {
+		return Transaction{}, nil, false, err
+	}
+	defer rows.Close()
+
+	var found Transaction
+	var addresses []string
+	for rows.Next() {
+		var address, payload string
+		var tx Transaction
+		if err := rows.Scan(&address, &tx.Hash, &tx.From, &tx.To, &tx.Value, &tx.BlockNumber, &tx.BlockNumberDecimal, &payload); err != nil {
+			return Transaction{}, nil, false, err
+		}
+		if err := s.decodePayload(address, &tx, payload); err != nil {
+			return Transaction{}, nil, false, err
+		}
+		found = tx
+		addresses = append(addresses, address)
+	}
+	if err := rows.Err(); err != nil {
+		return Transaction{}, nil, false, err
+	}
+	return found, addresses, len(addresses) > 0, nil
+}
+
+// decodePayload completes a transaction read from the columns with its stored payload
+func (s *SQLStorage) decodePayload(address string, tx *Transaction, payload string) error {
+	if payload == "" {
+		return nil
+	}
+	payload, err := openField(s.cipher, payload, transactionAssociatedData(address, tx.Hash))
+	if err != nil {
+		return err
+	}
+	blockNumberDecimal := tx.BlockNumberDecimal
+	if err := unmarshalStored(payload, tx); err != nil {
+		return err
+	}
+	tx.BlockNumberDecimal = blockNumberDecimal
+	return nil
+}
+
+// transactionAssociatedData binds an encrypted payload to its address and transaction
+func transactionAssociatedData(address string, hash string) string {
+	return "transactions/" + address + "/" + hash
+}
+
+// WithTx runs fn inside a database transaction, committing when fn returns nil
+func (s *SQLStorage) WithTx(fn func(tx StorageTx) error) error {
+	dbTx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer dbTx.Rollback()
+
+	if err := fn(&sqlTx{tx: dbTx, cipher: s.cipher, codec: s.codec}); err != nil {
+		return err
+	}
+	return dbTx.Commit()
+}
+
+// PendingOutboxEvents returns up to limit pending outbox events in block order
+func (s *SQLStorage) PendingOutboxEvents(limit int) ([]OutboxEvent, error) {
+	rows, err := s.db.Query(`SELECT id, payload FROM outbox ORDER BY block_number, id LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []OutboxEvent
+	for rows.Next() {
+		var id, payload string
+		if err := rows.Scan(&id, &payload); err != nil {
+			return nil, err
+		}
+		payload, err := openField(s.cipher, payload, "outbox/"+id)
+		if err != nil {
+			return nil, err
+		}
+		var event OutboxEvent
+		if err := unmarshalStored(payload, &event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// AckOutboxEvents deletes delivered events from the outbox
+func (s *SQLStorage) AckOutboxEvents(ids []string) error {
+	dbTx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer dbTx.Rollback()
+
+	for _, id := range ids {
+		if _, err := dbTx.Exec(`DELETE FROM outbox WHERE id = $1`, id); err != nil {
+			return err
+		}
+	}
+	return dbTx.Commit()
+}
+
+// sqlTx implements StorageTx on a database transaction
+type sqlTx struct {
+	tx     *sql.Tx
+	cipher FieldCipher
+	codec  Codec
+}
+
+// SaveTransactions inserts transactions for a given address, the ones already stored for the address are kept
+func (t *sqlTx) SaveTransactions(address string, transactions []Transaction) error {
+	for _, tx := range transactions {
+		stored, err := marshalStored(t.codec, tx)
+		if err != nil {
+			return err
+		}
+		from, to, value := tx.From, tx.To, tx.Value
+		if t.cipher != nil {
+			// The counterparties and the value are only kept in the encrypted payload
+			from, to, value = "", "", ""
+			if stored, err = sealField(t.cipher, stored, transactionAssociatedData(address, tx.Hash)); err != nil {
+				return err
+			}
+		}
+		_, err = t.tx.Exec(`INSERT INTO transactions
+			(address, hash, from_address, to_address, value, block_number, block_number_decimal, payload)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT (address, hash) DO NOTHING`,
+			address, tx.Hash, from, to, value, tx.BlockNumber, tx.BlockNumberDecimal, stored)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteTransactions deletes the transactions of an address in a block range
+func (t *sqlTx) DeleteTransactions(address string, fromBlock int, toBlock int) error {
+	_, err := t.tx.Exec(`DELETE FROM transactions WHERE address = $1 AND block_number_decimal BETWEEN $2 AND $3`,
+		address, fromBlock, toBlock)
+	return err
+}
+
+// AddOutboxEvent inserts an outbox event, replacing a pending event with the same ID
+func (t *sqlTx) AddOutboxEvent(event OutboxEvent) error {
+	payload, err := marshalStored(t.codec, event)
+	if err != nil {
+		return err
+	}
+	stored, err := sealField(t.cipher, payload, "outbox/"+event.ID)
+	if err != nil {
+		return err
+	}
+	_, err = t.tx.Exec(`INSERT INTO outbox (id, address, block_number, payload) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE SET payload = excluded.payload`,
+		event.ID, event.Address, event.BlockNumber, stored)
+	return err
+}
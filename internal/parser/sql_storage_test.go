@@ -0,0 +1,737 @@
+package parser_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"eth-parser/internal/parser"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSQLDriver is a database/sql driver keeping its tables in memory. It only understands the statements
+// SQLStorage runs in these tests, so that the SQL code runs under go test without a database server.
+type fakeSQLDriver struct{}
+
+// fakeDatabases are the databases of the fake driver by data source name
+var fakeDatabases sync.Map
+
+func init() {
+	sql.Register("fakesql", fakeSQLDriver{})
+}
+
+func (fakeSQLDriver) Open(name string) (driver.Conn, error) {
+	database, _ := fakeDatabases.LoadOrStore(name, &fakeDatabase{tables: make(map[string]*fakeTable)})
+	return &fakeConn{database: database.(*fakeDatabase)}, nil
+}
+
+// fakeDatabase is a database of the fake driver, snapshot holding the tables as of the open transaction
+type fakeDatabase struct {
+	mu       sync.Mutex
+	tables   map[string]*fakeTable
+	snapshot map[string]*fakeTable
+}
+
+// fakeTable is a table of the fake driver, keys being its primary key and unique constraints
+type fakeTable struct {
+	columns  []string
+	defaults map[string]driver.Value
+	keys     [][]string
+	rows     [][]driver.Value
+}
+
+func (t *fakeTable) clone() *fakeTable {
+	clone := &fakeTable{columns: append([]string(nil), t.columns...), defaults: make(map[string]driver.Value), keys: t.keys}
+	for column, value := range t.defaults {
+		clone.defaults[column] = value
+	}
+	for _, row := range t.rows {
+		clone.rows = append(clone.rows, append([]driver.Value(nil), row...))
+	}
+	return clone
+}
+
+func (t *fakeTable) column(name string) (int, error) {
+	for i, column := range t.columns {
+		if column == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("fakesql: no column %s", name)
+}
+
+// conflict returns the index of the row with the same key as row, -1 when there's none
+func (t *fakeTable) conflict(row []driver.Value) int {
+	for i, stored := range t.rows {
+		for _, key := range t.keys {
+			same := true
+			for _, name := range key {
+				index, _ := t.column(name)
+				same = same && fmt.Sprint(stored[index]) == fmt.Sprint(row[index])
+			}
+			if same {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+type fakeConn struct {
+	database *fakeDatabase
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{database: c.database, query: strings.Join(strings.Fields(query), " ")}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.database.mu.Lock()
+	defer c.database.mu.Unlock()
+	c.database.snapshot = make(map[string]*fakeTable, len(c.database.tables))
+	for name, table := range c.database.tables {
+		c.database.snapshot[name] = table.clone()
+	}
+	return &fakeTx{database: c.database}, nil
+}
+
+type fakeTx struct {
+	database *fakeDatabase
+}
+
+func (t *fakeTx) Commit() error {
+	t.database.mu.Lock()
+	defer t.database.mu.Unlock()
+	t.database.snapshot = nil
+	return nil
+}
+
+func (t *fakeTx) Rollback() error {
+	t.database.mu.Lock()
+	defer t.database.mu.Unlock()
+	if t.database.snapshot != nil {
+		t.database.tables, t.database.snapshot = t.database.snapshot, nil
+	}
+	return nil
+}
+
+type fakeStmt struct {
+	database *fakeDatabase
+	query    string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.database.mu.Lock()
+	defer s.database.mu.Unlock()
+	affected, _, err := s.database.run(s.query, args)
+	return driver.RowsAffected(affected), err
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.database.mu.Lock()
+	defer s.database.mu.Unlock()
+	_, rows, err := s.database.run(s.query, args)
+	if err == nil && rows == nil {
+		err = fmt.Errorf("fakesql: %s returns no rows", s.query)
+	}
+	return rows, err
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+var (
+	fakeCreateTable = regexp.MustCompile(`^CREATE TABLE IF NOT EXISTS (\w+) \((.*)\)$`)
+	fakeCreateIndex = regexp.MustCompile(`^CREATE INDEX IF NOT EXISTS \w+ ON (\w+) \(.*\)$`)
+	fakeAddColumn   = regexp.MustCompile(`^ALTER TABLE (\w+) ADD COLUMN (\w+) .*?( DEFAULT '')?$`)
+	fakeRename      = regexp.MustCompile(`^ALTER TABLE (\w+) RENAME TO (\w+)$`)
+	fakeDropTable   = regexp.MustCompile(`^DROP TABLE (\w+)$`)
+	fakeInsert      = regexp.MustCompile(`^INSERT INTO (\w+) \(([^)]*)\) VALUES \(([^)]*)\)( ON CONFLICT \([^)]*\) DO (NOTHING|UPDATE SET (\w+) = excluded\.\w+))?$`)
+	fakeInsertGroup = regexp.MustCompile(`^INSERT INTO (\w+) \(([^)]*)\) SELECT (.*) FROM (\w+) GROUP BY (.*)$`)
+	fakeDelete      = regexp.MustCompile(`^DELETE FROM (\w+)(?: WHERE (.*))?$`)
+	fakeMax         = regexp.MustCompile(`^SELECT COALESCE\(MAX\((\w+)\), 0\) FROM (\w+)$`)
+	fakeSelect      = regexp.MustCompile(`^SELECT (.*?) FROM (\w+)(?: WHERE (.*?))?(?: ORDER BY (.*?))?(?: LIMIT \$(\d+))?$`)
+	fakeCondition   = regexp.MustCompile(`^(\w+) = \$(\d+)$`)
+)
+
+// run runs a statement, returning the affected rows of the changes and the rows of the queries
+func (d *fakeDatabase) run(query string, args []driver.Value) (int64, driver.Rows, error) {
+	table := func(name string) (*fakeTable, error) {
+		if t, exists := d.tables[name]; exists {
+			return t, nil
+		}
+		return nil, fmt.Errorf("fakesql: no table %s", name)
+	}
+	if match := fakeCreateTable.FindStringSubmatch(query); match != nil {
+		if _, exists := d.tables[match[1]]; !exists {
+			d.tables[match[1]] = newFakeTable(match[2])
+		}
+		return 0, nil, nil
+	}
+	if match := fakeCreateIndex.FindStringSubmatch(query); match != nil {
+		_, err := table(match[1])
+		return 0, nil, err
+	}
+	if match := fakeAddColumn.FindStringSubmatch(query); match != nil {
+		t, err := table(match[1])
+		if err != nil {
+			return 0, nil, err
+		}
+		var value driver.Value
+		if match[3] != "" {
+			value = ""
+			t.defaults[match[2]] = value
+		}
+		t.columns = append(t.columns, match[2])
+		for i := range t.rows {
+			t.rows[i] = append(t.rows[i], value)
+		}
+		return 0, nil, nil
+	}
+	if match := fakeRename.FindStringSubmatch(query); match != nil {
+		t, err := table(match[1])
+		if err != nil {
+			return 0, nil, err
+		}
+		delete(d.tables, match[1])
+		d.tables[match[2]] = t
+		return 0, nil, nil
+	}
+	if match := fakeDropTable.FindStringSubmatch(query); match != nil {
+		if _, err := table(match[1]); err != nil {
+			return 0, nil, err
+		}
+		delete(d.tables, match[1])
+		return 0, nil, nil
+	}
+	if match := fakeInsert.FindStringSubmatch(query); match != nil {
+		t, err := table(match[1])
+		if err != nil {
+			return 0, nil, err
+		}
+		values := make(map[string]driver.Value)
+		placeholders := strings.Split(match[3], ", ")
+		for i, column := range strings.Split(match[2], ", ") {
+			if values[column], err = fakeArgument(placeholders[i], args); err != nil {
+				return 0, nil, err
+			}
+		}
+		return t.insert(values, match[5], match[6])
+	}
+	if match := fakeInsertGroup.FindStringSubmatch(query); match != nil {
+		return d.insertGroups(match[1], strings.Split(match[2], ", "), strings.Split(match[3], ", "), match[4], strings.Split(match[5], ", "))
+	}
+	if match := fakeDelete.FindStringSubmatch(query); match != nil {
+		t, err := table(match[1])
+		if err != nil {
+			return 0, nil, err
+		}
+		kept := t.rows[:0]
+		deleted := int64(0)
+		for _, row := range t.rows {
+			matched, err := t.matches(row, match[2], args)
+			if err != nil {
+				return 0, nil, err
+			}
+			if matched {
+				deleted++
+			} else {
+				kept = append(kept, row)
+			}
+		}
+		t.rows = kept
+		return deleted, nil, nil
+	}
+	if match := fakeMax.FindStringSubmatch(query); match != nil {
+		t, err := table(match[2])
+		if err != nil {
+			return 0, nil, err
+		}
+		index, err := t.column(match[1])
+		if err != nil {
+			return 0, nil, err
+		}
+		maximum := int64(0)
+		for _, row := range t.rows {
+			if value, ok := row[index].(int64); ok && value > maximum {
+				maximum = value
+			}
+		}
+		return 0, &fakeRows{columns: []string{"max"}, rows: [][]driver.Value{{maximum}}}, nil
+	}
+	if match := fakeSelect.FindStringSubmatch(query); match != nil {
+		return d.selectRows(match, args)
+	}
+	return 0, nil, fmt.Errorf("fakesql: unsupported statement %s", query)
+}
+
+// newFakeTable creates a table from the column and constraint definitions of a CREATE TABLE statement
+func newFakeTable(definitions string) *fakeTable {
+	t := &fakeTable{defaults: make(map[string]driver.Value)}
+	depth, start := 0, 0
+	var parts []string
+	for i, r := range definitions {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(definitions[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	parts = append(parts, strings.TrimSpace(definitions[start:]))
+	for _, part := range parts {
+		if key, ok := strings.CutPrefix(part, "PRIMARY KEY ("); ok {
+			t.keys = append(t.keys, strings.Split(strings.TrimSuffix(key, ")"), ", "))
+			continue
+		}
+		if key, ok := strings.CutPrefix(part, "UNIQUE ("); ok {
+			t.keys = append(t.keys, strings.Split(strings.TrimSuffix(key, ")"), ", "))
+			continue
+		}
+		name := strings.Fields(part)[0]
+		t.columns = append(t.columns, name)
+		if strings.Contains(part, "PRIMARY KEY") {
+			t.keys = append(t.keys, []string{name})
+		}
+		if strings.Contains(part, "DEFAULT ''") {
+			t.defaults[name] = ""
+		}
+	}
+	return t
+}
+
+// fakeArgument returns the argument of a $N placeholder
+func fakeArgument(placeholder string, args []driver.Value) (driver.Value, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(placeholder, "$"))
+	if err != nil || n < 1 || n > len(args) {
+		return nil, fmt.Errorf("fakesql: invalid placeholder %s", placeholder)
+	}
+	return args[n-1], nil
+}
+
+// insert inserts a row, onConflict being empty, NOTHING or the UPDATE of updated
+func (t *fakeTable) insert(values map[string]driver.Value, onConflict string, updated string) (int64, driver.Rows, error) {
+	row := make([]driver.Value, len(t.columns))
+	for i, column := range t.columns {
+		value, given := values[column]
+		if !given {
+			if value, given = t.defaults[column]; !given {
+				return 0, nil, fmt.Errorf("fakesql: NOT NULL constraint failed: %s", column)
+			}
+		}
+		row[i] = value
+	}
+	if existing := t.conflict(row); existing >= 0 {
+		switch {
+		case onConflict == "NOTHING":
+			return 0, nil, nil
+		case updated != "":
+			index, err := t.column(updated)
+			if err != nil {
+				return 0, nil, err
+			}
+			t.rows[existing][index] = row[index]
+			return 1, nil, nil
+		}
+		return 0, nil, errors.New("fakesql: UNIQUE constraint failed")
+	}
+	t.rows = append(t.rows, row)
+	return 1, nil, nil
+}
+
+// matches reports whether a row matches the conditions of a WHERE clause, equalities joined by AND
+func (t *fakeTable) matches(row []driver.Value, where string, args []driver.Value) (bool, error) {
+	if where == "" {
+		return true, nil
+	}
+	for _, condition := range strings.Split(where, " AND ") {
+		match := fakeCondition.FindStringSubmatch(condition)
+		if match == nil {
+			return false, fmt.Errorf("fakesql: unsupported condition %s", condition)
+		}
+		index, err := t.column(match[1])
+		if err != nil {
+			return false, err
+		}
+		value, err := fakeArgument("$"+match[2], args)
+		if err != nil {
+			return false, err
+		}
+		if fmt.Sprint(row[index]) != fmt.Sprint(value) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// fakeLess orders two values, numerically when both are integers
+func fakeLess(a driver.Value, b driver.Value) bool {
+	x, xInt := a.(int64)
+	y, yInt := b.(int64)
+	if xInt && yInt {
+		return x < y
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+// insertGroups runs an INSERT ... SELECT grouping the rows of source by the group columns, the selected
+// expressions being grouped columns or MIN of a column
+func (d *fakeDatabase) insertGroups(target string, columns []string, expressions []string, source string, group []string) (int64, driver.Rows, error) {
+	from, exists := d.tables[source]
+	into, intoExists := d.tables[target]
+	if !exists || !intoExists {
+		return 0, nil, fmt.Errorf("fakesql: no table %s or %s", source, target)
+	}
+	groups := make(map[string][]driver.Value)
+	var order []string
+	for _, row := range from.rows {
+		var key []string
+		for _, column := range group {
+			index, err := from.column(column)
+			if err != nil {
+				return 0, nil, err
+			}
+			key = append(key, fmt.Sprint(row[index]))
+		}
+		selected := make([]driver.Value, len(expressions))
+		for i, expression := range expressions {
+			index, err := from.column(strings.TrimSuffix(strings.TrimPrefix(expression, "MIN("), ")"))
+			if err != nil {
+				return 0, nil, err
+			}
+			selected[i] = row[index]
+		}
+		current, seen := groups[strings.Join(key, "\x00")]
+		if !seen {
+			order = append(order, strings.Join(key, "\x00"))
+			groups[strings.Join(key, "\x00")] = selected
+			continue
+		}
+		for i, expression := range expressions {
+			if strings.HasPrefix(expression, "MIN(") && fakeLess(selected[i], current[i]) {
+				current[i] = selected[i]
+			}
+		}
+	}
+	inserted := int64(0)
+	for _, key := range order {
+		values := make(map[string]driver.Value)
+		for i, column := range columns {
+			values[column] = groups[key][i]
+		}
+		if _, _, err := into.insert(values, "", ""); err != nil {
+			return 0, nil, err
+		}
+		inserted++
+	}
+	return inserted, nil, nil
+}
+
+// selectRows runs a SELECT of columns with optional WHERE, ORDER BY and LIMIT clauses
+func (d *fakeDatabase) selectRows(match []string, args []driver.Value) (int64, driver.Rows, error) {
+	t, exists := d.tables[match[2]]
+	if !exists {
+		return 0, nil, fmt.Errorf("fakesql: no table %s", match[2])
+	}
+	columns := strings.Split(match[1], ", ")
+	var rows [][]driver.Value
+	for _, row := range t.rows {
+		matched, err := t.matches(row, match[3], args)
+		if err != nil {
+			return 0, nil, err
+		}
+		if matched {
+			rows = append(rows, row)
+		}
+	}
+	if match[4] != "" {
+		var order []int
+		for _, column := range strings.Split(match[4], ", ") {
+			index, err := t.column(column)
+			if err != nil {
+				return 0, nil, err
+			}
+			order = append(order, index)
+		}
+		sort.SliceStable(rows, func(i, j int) bool {
+			for _, index := range order {
+				if fakeLess(rows[i][index], rows[j][index]) {
+					return true
+				}
+				if fakeLess(rows[j][index], rows[i][index]) {
+					return false
+				}
+			}
+			return false
+		})
+	}
+	if match[5] != "" {
+		limit, err := fakeArgument("$"+match[5], args)
+		if err != nil {
+			return 0, nil, err
+		}
+		if n, ok := limit.(int64); ok && int(n) < len(rows) {
+			rows = rows[:n]
+		}
+	}
+	result := &fakeRows{columns: columns}
+	for _, row := range rows {
+		selected := make([]driver.Value, len(columns))
+		for i, column := range columns {
+			index, err := t.column(column)
+			if err != nil {
+				return 0, nil, err
+			}
+			selected[i] = row[index]
+		}
+		result.rows = append(result.rows, selected)
+	}
+	return 0, result, nil
+}
+
+// openFakeSQL opens an empty database of the fake driver, on a single connection as its transactions apply to
+// the whole database
+func openFakeSQL(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("fakesql", t.Name())
+	if err != nil {
+		t.Fatalf("Failed to open the fake database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		db.Close()
+		fakeDatabases.Delete(t.Name())
+	})
+	return db
+}
+
+// schemaVersion returns the versions recorded in schema_migrations
+func schemaVersion(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var version int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		t.Fatalf("Failed to read the schema version: %v", err)
+	}
+	return version
+}
+
+func TestSQLStorageMigrations(t *testing.T) {
+	ctx := context.Background()
+	db := openFakeSQL(t)
+
+	if _, err := parser.NewSQLStorage(ctx, db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	version := schemaVersion(t, db)
+	if version < 14 {
+		t.Fatalf("Expected every migration to be applied, got version %d", version)
+	}
+
+	// Migrating up again applies nothing and keeps the stored rows
+	storage, err := parser.NewSQLStorage(ctx, db)
+	if err != nil {
+		t.Fatalf("Failed to migrate again: %v", err)
+	}
+	if err := storage.SaveTransactions("0x1", []parser.Transaction{{Hash: "0xa1", From: "0x1", To: "0x2", Value: "0x1", BlockNumber: "0x1", BlockNumberDecimal: 1}}); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	if _, err := parser.NewSQLStorage(ctx, db); err != nil {
+		t.Fatalf("Failed to migrate a third time: %v", err)
+	}
+	if got := schemaVersion(t, db); got != version {
+		t.Errorf("Expected the schema to stay at version %d, got %d", version, got)
+	}
+	if got := storage.GetTransactions("0x1"); len(got) != 1 {
+		t.Errorf("Expected the stored transaction to be kept, got %+v", got)
+	}
+
+	// A failed migration leaves the schema at the previous version, and duplicate versions are rejected
+	failing := []parser.Migration{{Version: version + 1, Description: "broken", Statements: []string{`CREATE TABLE IF NOT EXISTS ok (id TEXT PRIMARY KEY)`, `NOT SQL`}}}
+	if err := parser.Migrate(ctx, db, failing); err == nil {
+		t.Fatal("Expected the broken migration to fail")
+	}
+	if got := schemaVersion(t, db); got != version {
+		t.Errorf("Expected the schema to stay at version %d after a failure, got %d", version, got)
+	}
+	if _, err := db.Query(`SELECT id FROM ok`); err == nil {
+		t.Error("Expected the statements of the failed migration to be rolled back")
+	}
+	duplicate := []parser.Migration{{Version: 100, Description: "a"}, {Version: 100, Description: "b"}}
+	if err := parser.Migrate(ctx, db, duplicate); err == nil {
+		t.Error("Expected the duplicate versions to be rejected")
+	}
+}
+
+func TestSQLStorageDeduplicatesTransactions(t *testing.T) {
+	ctx := context.Background()
+	db := openFakeSQL(t)
+
+	// A table of version 13 with the rows of a block processed twice
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, description TEXT NOT NULL)`,
+		`CREATE TABLE IF NOT EXISTS transactions (address TEXT NOT NULL, hash TEXT NOT NULL, from_address TEXT NOT NULL,
+			to_address TEXT NOT NULL, value TEXT NOT NULL, block_number TEXT NOT NULL,
+			block_number_decimal INTEGER NOT NULL, payload TEXT NOT NULL DEFAULT '')`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("Failed to create the table: %v", err)
+		}
+	}
+	for version := 1; version <= 13; version++ {
+		if _, err := db.Exec(`INSERT INTO schema_migrations (version, description) VALUES ($1, $2)`, version, "applied"); err != nil {
+			t.Fatalf("Failed to record version %d: %v", version, err)
+		}
+	}
+	for _, hash := range []string{"0xa1", "0xa1", "0xa2"} {
+		if _, err := db.Exec(`INSERT INTO transactions (address, hash, from_address, to_address, value, block_number, block_number_decimal, payload)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`, "0x1", hash, "0x1", "0x2", "0x1", "0x1", 1, ""); err != nil {
+			t.Fatalf("Failed to insert %s: %v", hash, err)
+		}
+	}
+
+	storage, err := parser.NewSQLStorage(ctx, db)
+	if err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if got := storage.GetTransactions("0x1"); len(got) != 2 || got[0].Hash == got[1].Hash {
+		t.Fatalf("Expected the duplicates to be removed, got %+v", got)
+	}
+
+	// Saving a stored transaction again keeps one row, as for another address it's stored again
+	tx := parser.Transaction{Hash: "0xa1", From: "0x1", To: "0x2", Value: "0x1", BlockNumber: "0x1", BlockNumberDecimal: 1}
+	if err := storage.SaveTransactions("0x1", []parser.Transaction{tx}); err != nil {
+		t.Fatalf("Failed to save again: %v", err)
+	}
+	if err := storage.SaveTransactions("0x2", []parser.Transaction{tx}); err != nil {
+		t.Fatalf("Failed to save for the recipient: %v", err)
+	}
+	if got := storage.GetTransactions("0x1"); len(got) != 2 {
+		t.Errorf("Expected the transactions to stay unique, got %+v", got)
+	}
+	if _, addresses, found, err := storage.GetTransactionByHash("0xa1"); err != nil || !found || len(addresses) != 2 {
+		t.Errorf("Expected the transaction for both addresses, got %v %v %v", addresses, found, err)
+	}
+}
+
+func TestSQLStorageWithTxRollback(t *testing.T) {
+	storage, err := parser.NewSQLStorage(context.Background(), openFakeSQL(t))
+	if err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	failure := errors.New("delivery failed")
+	err = storage.WithTx(func(tx parser.StorageTx) error {
+		if err := tx.SaveTransactions("0x1", []parser.Transaction{{Hash: "0xa1", BlockNumber: "0x1", BlockNumberDecimal: 1}}); err != nil {
+			return err
+		}
+		if err := tx.AddOutboxEvent(parser.OutboxEvent{ID: "1:0x1", Address: "0x1", BlockNumber: 1}); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Expected the error of the function, got %v", err)
+	}
+	if got := storage.GetTransactions("0x1"); len(got) != 0 {
+		t.Errorf("Expected the transactions to be rolled back, got %+v", got)
+	}
+	if events, err := storage.PendingOutboxEvents(10); err != nil || len(events) != 0 {
+		t.Errorf("Expected the outbox event to be rolled back, got %+v %v", events, err)
+	}
+}
+
+func TestSQLStorageOutbox(t *testing.T) {
+	key := make([]byte, 32)
+	cipher, err := parser.NewAESGCMCipher(key)
+	if err != nil {
+		t.Fatalf("Failed to create the cipher: %v", err)
+	}
+	db := openFakeSQL(t)
+	storage, err := parser.NewEncryptedSQLStorage(context.Background(), db, cipher)
+	if err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	storage.WithCodec(parser.ProtobufCodec{})
+
+	tx := parser.Transaction{Hash: "0xa1", From: "0x1", To: "0x2", Value: "0x10", BlockNumber: "0x2", BlockNumberDecimal: 2}
+	err = storage.WithTx(func(storageTx parser.StorageTx) error {
+		if err := storageTx.SaveTransactions("0x1", []parser.Transaction{tx}); err != nil {
+			return err
+		}
+		for _, event := range []parser.OutboxEvent{
+			{ID: "2:0x1", Address: "0x1", BlockNumber: 2},
+			{ID: "1:0x1", Address: "0x1", BlockNumber: 1},
+			// Re-processing block 2 replaces its event
+			{ID: "2:0x1", Address: "0x1", BlockNumber: 2, Transactions: []parser.Transaction{tx}},
+		} {
+			if err := storageTx.AddOutboxEvent(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	events, err := storage.PendingOutboxEvents(10)
+	if err != nil || len(events) != 2 || events[0].ID != "1:0x1" || events[1].ID != "2:0x1" {
+		t.Fatalf("Expected the events in block order, got %+v %v", events, err)
+	}
+	if len(events[1].Transactions) != 1 || events[1].Transactions[0].Hash != "0xa1" {
+		t.Errorf("Expected the replaced event of block 2, got %+v", events[1])
+	}
+	if events, err := storage.PendingOutboxEvents(1); err != nil || len(events) != 1 {
+		t.Errorf("Expected the limit to apply, got %+v %v", events, err)
+	}
+	if err := storage.AckOutboxEvents([]string{"1:0x1"}); err != nil {
+		t.Fatalf("Failed to acknowledge: %v", err)
+	}
+	if events, err := storage.PendingOutboxEvents(10); err != nil || len(events) != 1 || events[0].ID != "2:0x1" {
+		t.Errorf("Expected only the unacknowledged event, got %+v %v", events, err)
+	}
+
+	// The encrypted payloads are read back, the counterparties and value only being in the payload
+	if got := storage.GetTransactions("0x1"); len(got) != 1 || got[0].From != "0x1" || got[0].Value != "0x10" || got[0].BlockNumberDecimal != 2 {
+		t.Errorf("Unexpected transactions read back: %+v", got)
+	}
+	var from, payload string
+	if err := db.QueryRow(`SELECT from_address, payload FROM transactions WHERE hash = $1`, "0xa1").Scan(&from, &payload); err != nil {
+		t.Fatalf("Failed to read the row: %v", err)
+	}
+	if from != "" || strings.Contains(payload, "0x10") {
+		t.Errorf("Expected the row to be encrypted, got %q %q", from, payload)
+	}
+}
@@ -0,0 +1,158 @@
+package parser
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventProviderDegraded is sent when the head of the active provider stops advancing, with the provider it
+// switched to, if any was ahead
+const EventProviderDegraded = "provider_degraded"
+
+// defaultStallAfter is the time without new head after which the active provider is suspected stale
+const defaultStallAfter = time.Minute
+
+// RPCProvider is a named node provider
+type RPCProvider struct {
+	Name   string
+	Client JsonRpcClient
+}
+
+// StaleHeadPolicy switches between providers when the head of the active one stops advancing
+type StaleHeadPolicy struct {
+	// Providers are the secondary providers, in order of preference, compared with the active one when its head
+	// stalls. The client of the parser is the "primary" provider.
+	Providers []RPCProvider
+	// StallAfter is the time without new head after which the active provider is suspected stale, e.g. a few
+	// block times of the network, one minute by default
+	StallAfter time.Duration
+	// MinLead is the number of blocks a provider must be ahead of the stalled head to be switched to, 1 by default
+	MinLead int
+}
+
+// ProviderStats reports the provider serving the requests of the parser
+type ProviderStats struct {
+	Active   string `json:"active"`
+	Switches int    `json:"switches"`
+}
+
+// providerSwitch is the JsonRpcClient of the parser with a StaleHeadPolicy, sending the requests to the
+// active provider
+type providerSwitch struct {
+	providers []RPCProvider
+	active    atomic.Int32
+}
+
+// SendRequest sends the request to the active provider
+func (s *providerSwitch) SendRequest(req JSONRPCRequest) (JSONRPCResponse, error) {
+	return s.providers[s.active.Load()].Client.SendRequest(req)
+}
+
+// CancelRequests aborts the in-flight requests of every provider, see RequestCanceler
+func (s *providerSwitch) CancelRequests() {
+	for _, provider := range s.providers {
+		if canceler, ok := provider.Client.(RequestCanceler); ok {
+			canceler.CancelRequests()
+		}
+	}
+}
+
+// staleHeadState tracks the head of the active provider, only accessed under mu
+type staleHeadState struct {
+	mu          sync.Mutex
+	policy      StaleHeadPolicy
+	providers   *providerSwitch
+	head        int
+	lastAdvance time.Time
+	degraded    bool // the degradation of the current stall was reported
+	switches    int
+}
+
+// ProviderStats returns the active provider and the number of switches, empty without StaleHeadPolicy
+func (p *EthParser) ProviderStats() ProviderStats {
+	if p.staleHead == nil {
+		return ProviderStats{}
+	}
+	p.staleHead.mu.Lock()
+	defer p.staleHead.mu.Unlock()
+	return ProviderStats{
+		Active:   p.staleHead.providers.providers[p.staleHead.providers.active.Load()].Name,
+		Switches: p.staleHead.switches,
+	}
+}
+
+// checkStaleHead records the head polled from the active provider. When it hasn't advanced for StallAfter,
+// the other providers are asked for their head and the first one ahead of it becomes the active provider.
+// It returns the head to use, the one of the new provider after a switch.
+func (p *EthParser) checkStaleHead(head int) int {
+	state := p.staleHead
+	if state == nil {
+		return head
+	}
+	state.mu.Lock()
+	now := p.clock.Now()
+	if head > state.head || state.lastAdvance.IsZero() {
+		state.head, state.lastAdvance, state.degraded = head, now, false
+		state.mu.Unlock()
+		return head
+	}
+	stalledFor := now.Sub(state.lastAdvance)
+	if stalledFor < state.policy.StallAfter || state.degraded {
+		state.mu.Unlock()
+		return head
+	}
+
+	active := int(state.providers.active.Load())
+	stale := state.providers.providers[active].Name
+	switchedTo := ""
+	for offset := 1; offset < len(state.providers.providers); offset++ {
+		candidate := (active + offset) % len(state.providers.providers)
+		provider := state.providers.providers[candidate]
+		providerHead, err := blockNumber(provider.Client)
+		if err != nil {
+			log.Printf("Error fetching the head of provider %s: %v\n", provider.Name, err)
+			continue
+		}
+		if providerHead >= state.head+state.policy.MinLead {
+			state.providers.active.Store(int32(candidate))
+			state.switches++
+			state.head, state.lastAdvance = providerHead, now
+			switchedTo = provider.Name
+			head = providerHead
+			break
+		}
+	}
+	if switchedTo != "" {
+		log.Printf("Head of provider %s stalled for %s, switched to %s\n", stale, stalledFor, switchedTo)
+	} else {
+		// Either every provider stalled, e.g. the chain halted, or none could be reached
+		log.Printf("Head of provider %s stalled for %s, no provider is ahead\n", stale, stalledFor)
+		state.degraded = true
+	}
+	state.mu.Unlock()
+
+	p.emitEvent(Event{Type: EventProviderDegraded, Data: map[string]string{
+		"provider":   stale,
+		"head":       strconv.Itoa(head),
+		"stalledFor": stalledFor.Round(time.Second).String(),
+		"switchedTo": switchedTo,
+	}})
+	return head
+}
+
+// blockNumber returns the head of a provider with eth_blockNumber
+func blockNumber(client JsonRpcClient) (int, error) {
+	resp, err := client.SendRequest(JSONRPCRequest{JSONRPC: "2.0", Method: "eth_blockNumber", Params: []interface{}{}, ID: 1})
+	if err != nil {
+		return 0, err
+	}
+	blockNumberHex, ok := resp.Result.(string)
+	if !ok {
+		return 0, fmt.Errorf("unexpected result format")
+	}
+	return convertHexNumberToDecimal(blockNumberHex)
+}
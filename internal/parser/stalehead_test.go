@@ -0,0 +1,78 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// mockChain returns a mock blockchain with the given number of blocks
+func mockChain(blocks int) *MockBlockchain {
+	blockchain := NewMockBlockchain()
+	for i := 1; i <= blocks; i++ {
+		blockchain.AddBlock(i, parser.Block{Number: fmt.Sprintf("0x%x", i)})
+	}
+	return blockchain
+}
+
+func TestEthParserStaleHeadSwitch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := parser.NewManualClock(time.Now())
+	var events []parser.Event
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockChain(2)), func(string, []parser.Transaction) {},
+		parser.WithClock(clock), parser.WithEventNotification(func(event parser.Event) { events = append(events, event) }),
+		parser.WithStaleHeadDetection(parser.StaleHeadPolicy{
+			Providers:  []parser.RPCProvider{{Name: "lagging", Client: NewMockClient(mockChain(2))}, {Name: "backup", Client: NewMockClient(mockChain(5))}},
+			StallAfter: time.Minute,
+		}))
+	defer ethParser.WaitForShutdown()
+	ethParser.ProcessNextCycle()
+
+	clock.Advance(30 * time.Second)
+	ethParser.ProcessNextCycle()
+	if len(events) != 0 {
+		t.Fatalf("Expected no event before StallAfter, got %+v", events)
+	}
+
+	clock.Advance(30 * time.Second)
+	ethParser.ProcessNextCycle()
+	if len(events) != 1 || events[0].Type != parser.EventProviderDegraded || events[0].Data["provider"] != "primary" ||
+		events[0].Data["switchedTo"] != "backup" || events[0].Data["stalledFor"] != "1m0s" {
+		t.Fatalf("Expected a switch to the provider ahead, got %+v", events)
+	}
+	if block := ethParser.GetCurrentBlock(); block != 5 {
+		t.Errorf("Expected the head of the new provider, got %d", block)
+	}
+	if stats := ethParser.ProviderStats(); stats.Active != "backup" || stats.Switches != 1 {
+		t.Errorf("Unexpected provider stats %+v", stats)
+	}
+}
+
+func TestEthParserStaleHeadWithoutProviderAhead(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := parser.NewManualClock(time.Now())
+	var events []parser.Event
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockChain(2)), func(string, []parser.Transaction) {},
+		parser.WithClock(clock), parser.WithEventNotification(func(event parser.Event) { events = append(events, event) }),
+		parser.WithStaleHeadDetection(parser.StaleHeadPolicy{Providers: []parser.RPCProvider{{Name: "backup", Client: NewMockClient(mockChain(2))}}}))
+	defer ethParser.WaitForShutdown()
+	ethParser.ProcessNextCycle()
+
+	// The default StallAfter is one minute, the degradation is reported once per stall
+	for i := 0; i < 2; i++ {
+		clock.Advance(time.Minute)
+		ethParser.ProcessNextCycle()
+	}
+	if len(events) != 1 || events[0].Data["switchedTo"] != "" {
+		t.Fatalf("Expected one degradation event without switch, got %+v", events)
+	}
+	if stats := ethParser.ProviderStats(); stats.Active != "primary" || stats.Switches != 0 {
+		t.Errorf("Expected the primary provider to stay active, got %+v", stats)
+	}
+}
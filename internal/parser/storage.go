@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"sort"
 	"sync"
 )
 
@@ -8,32 +9,329 @@ import (
 type Storage interface {
 	SaveTransactions(address string, transactions []Transaction) error
 	GetTransactions(address string) []Transaction
+	// GetTransactionByHash returns a stored transaction and the addresses it's stored for, found is false when
+	// no address has it
+	GetTransactionByHash(hash string) (tx Transaction, addresses []string, found bool, err error)
+	// WithTx runs fn inside a storage transaction. Writes staged through tx become visible
+	// atomically when fn returns nil and are discarded when fn returns an error.
+	WithTx(fn func(tx StorageTx) error) error
+	// PendingOutboxEvents returns up to limit not yet acknowledged outbox events, oldest first
+	PendingOutboxEvents(limit int) ([]OutboxEvent, error)
+	// AckOutboxEvents removes delivered events from the outbox
+	AckOutboxEvents(ids []string) error
+	// Stats returns the size of the stored data
+	Stats() (StorageStats, error)
+}
+
+// StorageStats describes the size of the data held by a Storage. A transaction between two watched
+// addresses is stored, and counted, once per address.
+type StorageStats struct {
+	Addresses    int `json:"addresses"`
+	Transactions int `json:"transactions"`
+	// ApproximateBytes is an estimate of the size of the stored transactions, not of the backend overhead
+	ApproximateBytes int64 `json:"approximateBytes"`
+	// OldestBlock and NewestBlock are the range of the stored blocks, zero when the storage is empty
+	OldestBlock int `json:"oldestBlock"`
+	NewestBlock int `json:"newestBlock"`
+}
+
+// StorageTx is the set of write operations available inside Storage.WithTx
+type StorageTx interface {
+	SaveTransactions(address string, transactions []Transaction) error
+	// DeleteTransactions removes the transactions of the address in the blocks fromBlock to toBlock included,
+	// it applies before the transactions saved in the same storage transaction
+	DeleteTransactions(address string, fromBlock int, toBlock int) error
+	// AddOutboxEvent adds an event to the outbox, replacing a pending event with the same ID
+	AddOutboxEvent(event OutboxEvent) error
 }
 
 // MemoryStorage implements the Storage interface using in-memory storage
 type MemoryStorage struct {
-	data map[string][]Transaction
-	mu   sync.RWMutex
+	data        map[string][]Transaction
+	outbox      []OutboxEvent
+	idempotency map[string]IdempotencyRecord
+	mu          sync.RWMutex
+
+	checkpoint       int
+	hasCheckpoint    bool
+	blockDeadLetters map[int]BlockDeadLetter
+	auditLog         []AuditEntry
+	subscriptions    map[string]Subscription
+	consumers        map[string]Consumer
+	consumerOrder    []string
+	consumerEvents   map[string][]OutboxEvent
+	usage            map[usageKey]int64
+	activityRanges   map[int]ActivityRange
+	tenants          map[string]TenantRecord
+
+	// Bounded mode, a zero cap means unlimited
+	perAddressCap int
+	globalCap     int
+	total         int
+	evictions     int
+	truncated     map[string]bool
 }
 
 // NewMemoryStorage creates a new instance of MemoryStorage
 func NewMemoryStorage() *MemoryStorage {
 	return &MemoryStorage{
-		data: make(map[string][]Transaction),
+		data:             make(map[string][]Transaction),
+		idempotency:      make(map[string]IdempotencyRecord),
+		truncated:        make(map[string]bool),
+		blockDeadLetters: make(map[int]BlockDeadLetter),
+		subscriptions:    make(map[string]Subscription),
+		consumers:        make(map[string]Consumer),
+		consumerEvents:   make(map[string][]OutboxEvent),
+		usage:            make(map[usageKey]int64),
+		activityRanges:   make(map[int]ActivityRange),
+		tenants:          make(map[string]TenantRecord),
 	}
 }
 
+// NewBoundedMemoryStorage creates a MemoryStorage keeping at most perAddressCap transactions per address and
+// globalCap transactions overall (zero means unlimited). When a cap is exceeded the transactions of the
+// oldest blocks are evicted first and the affected addresses are reported as truncated.
+func NewBoundedMemoryStorage(perAddressCap int, globalCap int) *MemoryStorage {
+	s := NewMemoryStorage()
+	s.perAddressCap = perAddressCap
+	s.globalCap = globalCap
+	return s
+}
+
 // SaveTransactions saves transactions for a given address
 func (s *MemoryStorage) SaveTransactions(address string, transactions []Transaction) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.data[address] = append(s.data[address], transactions...)
+	s.appendTransactions(address, transactions)
 	return nil
 }
 
+// Truncated reports whether transactions of the address have been evicted by the bounded mode
+func (s *MemoryStorage) Truncated(address string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.truncated[address]
+}
+
+// Evictions returns the number of transactions evicted by the bounded mode
+func (s *MemoryStorage) Evictions() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.evictions
+}
+
+// appendTransactions stores transactions and enforces the caps, the caller holds the lock
+func (s *MemoryStorage) appendTransactions(address string, transactions []Transaction) {
+	s.data[address] = append(s.data[address], transactions...)
+	s.total += len(transactions)
+
+	if s.perAddressCap > 0 && len(s.data[address]) > s.perAddressCap {
+		s.evict(address, len(s.data[address])-s.perAddressCap)
+	}
+
+	for s.globalCap > 0 && s.total > s.globalCap {
+		// Evict the transactions of the oldest block stored, whatever the address
+		oldest := ""
+		for candidate, stored := range s.data {
+			if len(stored) > 0 && (oldest == "" || stored[0].BlockNumberDecimal < s.data[oldest][0].BlockNumberDecimal) {
+				oldest = candidate
+			}
+		}
+		count := 0
+		for count < len(s.data[oldest]) && s.data[oldest][count].BlockNumberDecimal == s.data[oldest][0].BlockNumberDecimal {
+			count++
+		}
+		s.evict(oldest, count)
+	}
+}
+
+// evict removes the first count transactions of an address, the caller holds the lock
+func (s *MemoryStorage) evict(address string, count int) {
+	remaining := s.data[address][count:]
+	if cap(remaining) > 2*len(remaining) {
+		// Release the memory of the evicted transactions
+		remaining = append([]Transaction(nil), remaining...)
+	}
+	s.data[address] = remaining
+	s.total -= count
+	s.evictions += count
+	s.truncated[address] = true
+}
+
 // GetTransactions retrieves transactions for a given address
 func (s *MemoryStorage) GetTransactions(address string) []Transaction {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	return s.data[address]
 }
+
+// Stats returns the size of the stored transactions
+func (s *MemoryStorage) Stats() (StorageStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var stats StorageStats
+	for _, transactions := range s.data {
+		if len(transactions) == 0 {
+			continue
+		}
+		stats.Addresses++
+		stats.Transactions += len(transactions)
+		for _, tx := range transactions {
+			stats.ApproximateBytes += approximateSize(tx)
+			if stats.OldestBlock == 0 || tx.BlockNumberDecimal < stats.OldestBlock {
+				stats.OldestBlock = tx.BlockNumberDecimal
+			}
+			if tx.BlockNumberDecimal > stats.NewestBlock {
+				stats.NewestBlock = tx.BlockNumberDecimal
+			}
+		}
+	}
+	return stats, nil
+}
+
+// transactionOverhead approximates the fixed size of a Transaction value, string headers and scalars
+const transactionOverhead = 256
+
+// approximateSize returns the approximate memory size of a transaction
+func approximateSize(tx Transaction) int64 {
+	size := transactionOverhead + len(tx.Hash) + len(tx.From) + len(tx.To) + len(tx.Value) + len(tx.BlockNumber) +
+		len(tx.Type) + len(tx.Nonce) + len(tx.GasPrice) + len(tx.MaxFeePerGas) + len(tx.MaxPriorityFeePerGas) +
+		len(tx.MaxFeePerBlobGas) + len(tx.PriceUSD) + len(tx.ValueUSD)
+	for _, hash := range tx.BlobVersionedHashes {
+		size += len(hash)
+	}
+	return int64(size)
+}
+
+// GetTransactionByHash scans the stored transactions for a hash
+func (s *MemoryStorage) GetTransactionByHash(hash string) (Transaction, []string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var found Transaction
+	var addresses []string
+	for address, transactions := range s.data {
+		for _, tx := range transactions {
+			if tx.Hash == hash {
+				found = tx
+				addresses = append(addresses, address)
+				break
+			}
+		}
+	}
+	sort.Strings(addresses)
+	return found, addresses, len(addresses) > 0, nil
+}
+
+// WithTx stages the writes done by fn and applies them under a single lock once fn succeeds
+func (s *MemoryStorage) WithTx(fn func(tx StorageTx) error) error {
+	tx := &memoryTx{pending: make(map[string][]Transaction)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rewritten := make(map[string]bool)
+	for _, deletion := range tx.deletions {
+		kept := []Transaction{}
+		for _, stored := range s.data[deletion.address] {
+			if stored.BlockNumberDecimal < deletion.fromBlock || stored.BlockNumberDecimal > deletion.toBlock {
+				kept = append(kept, stored)
+			}
+		}
+		s.total -= len(s.data[deletion.address]) - len(kept)
+		s.data[deletion.address] = kept
+		rewritten[deletion.address] = true
+	}
+	for address, transactions := range tx.pending {
+		if rewritten[address] {
+			// Transactions saved back into a deleted range must keep the block order the eviction relies on
+			merged := append(s.data[address], transactions...)
+			sort.SliceStable(merged, func(i, j int) bool {
+				return merged[i].BlockNumberDecimal < merged[j].BlockNumberDecimal
+			})
+			s.data[address] = merged
+			s.total += len(transactions)
+			transactions = nil
+		}
+		s.appendTransactions(address, transactions)
+	}
+	for _, event := range tx.outbox {
+		s.addOutboxEvent(event)
+	}
+	return nil
+}
+
+// PendingOutboxEvents returns up to limit pending outbox events, oldest first
+func (s *MemoryStorage) PendingOutboxEvents(limit int) ([]OutboxEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if limit > len(s.outbox) {
+		limit = len(s.outbox)
+	}
+	events := make([]OutboxEvent, limit)
+	copy(events, s.outbox)
+	return events, nil
+}
+
+// AckOutboxEvents removes delivered events from the outbox
+func (s *MemoryStorage) AckOutboxEvents(ids []string) error {
+	acked := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		acked[id] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending := s.outbox[:0]
+	for _, event := range s.outbox {
+		if !acked[event.ID] {
+			pending = append(pending, event)
+		}
+	}
+	s.outbox = pending
+	return nil
+}
+
+// addOutboxEvent appends an event or replaces the pending one with the same ID, the caller holds the lock
+func (s *MemoryStorage) addOutboxEvent(event OutboxEvent) {
+	for i := range s.outbox {
+		if s.outbox[i].ID == event.ID {
+			s.outbox[i] = event
+			return
+		}
+	}
+	s.outbox = append(s.outbox, event)
+}
+
+// memoryTx buffers writes until MemoryStorage.WithTx commits them
+type memoryTx struct {
+	pending   map[string][]Transaction
+	deletions []blockRangeDeletion
+	outbox    []OutboxEvent
+}
+
+// blockRangeDeletion is a DeleteTransactions staged by a memoryTx
+type blockRangeDeletion struct {
+	address   string
+	fromBlock int
+	toBlock   int
+}
+
+// DeleteTransactions stages the deletion of the transactions of an address in a block range
+func (t *memoryTx) DeleteTransactions(address string, fromBlock int, toBlock int) error {
+	t.deletions = append(t.deletions, blockRangeDeletion{address: address, fromBlock: fromBlock, toBlock: toBlock})
+	return nil
+}
+
+// SaveTransactions stages transactions for a given address
+func (t *memoryTx) SaveTransactions(address string, transactions []Transaction) error {
+	t.pending[address] = append(t.pending[address], transactions...)
+	return nil
+}
+
+// AddOutboxEvent stages an outbox event
+func (t *memoryTx) AddOutboxEvent(event OutboxEvent) error {
+	t.outbox = append(t.outbox, event)
+	return nil
+}
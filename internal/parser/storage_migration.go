@@ -0,0 +1,337 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync/atomic"
+)
+
+// AddressLister is implemented by the storages listing the addresses they hold transactions for, which
+// MigrateStorage requires of its source
+type AddressLister interface {
+	StoredAddresses() ([]string, error)
+}
+
+// StorageMigrationOptions configures MigrateStorage
+type StorageMigrationOptions struct {
+	// Progress is called after each copied address
+	Progress func(progress StorageMigrationProgress)
+	// Verify checks, once copied, that the target holds every transaction of the source
+	Verify bool
+}
+
+// StorageMigrationProgress reports the progress of MigrateStorage
+type StorageMigrationProgress struct {
+	Addresses      int // addresses copied so far
+	TotalAddresses int
+	Transactions   int // transactions saved in the target so far
+	Skipped        int // transactions of the source already in the target
+}
+
+// StorageMigrationReport is the result of MigrateStorage
+type StorageMigrationReport struct {
+	StorageMigrationProgress
+	// Checkpoint is the checkpoint copied, zero when the target had a later one or the source none
+	Checkpoint int
+	// Mismatches are the addresses whose transactions the verification didn't find all in the target
+	Mismatches []string
+}
+
+// MigrateStorage copies the stored addresses with their transactions, and the checkpoint, from one storage
+// backend to another, e.g. from SQLite to PostgreSQL. The transactions the target already has, by hash, are
+// skipped, so it can run again after a failure or while a DualWriteStorage writes the new blocks to both
+// storages; a later checkpoint of the target is kept. The subscriptions are held by the parser, not by the
+// storages, only the addresses with transactions are migrated.
+func MigrateStorage(ctx context.Context, source Storage, target Storage, opts StorageMigrationOptions) (StorageMigrationReport, error) {
+	var report StorageMigrationReport
+	lister, ok := source.(AddressLister)
+	if !ok {
+		return report, fmt.Errorf("the source storage %T can't list its addresses", source)
+	}
+	addresses, err := lister.StoredAddresses()
+	if err != nil {
+		return report, fmt.Errorf("listing the addresses of the source: %w", err)
+	}
+	report.TotalAddresses = len(addresses)
+
+	for _, address := range addresses {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		transactions := source.GetTransactions(address)
+		missing := missingTransactions(transactions, target.GetTransactions(address))
+		report.Skipped += len(transactions) - len(missing)
+		if len(missing) > 0 {
+			if err := target.SaveTransactions(address, missing); err != nil {
+				return report, fmt.Errorf("saving the transactions of address %s: %w", address, err)
+			}
+			report.Transactions += len(missing)
+		}
+		report.Addresses++
+		if opts.Progress != nil {
+			opts.Progress(report.StorageMigrationProgress)
+		}
+	}
+
+	if report.Checkpoint, err = migrateCheckpoint(source, target); err != nil {
+		return report, err
+	}
+	if opts.Verify {
+		for _, address := range addresses {
+			if len(missingTransactions(source.GetTransactions(address), target.GetTransactions(address))) > 0 {
+				report.Mismatches = append(report.Mismatches, address)
+			}
+		}
+	}
+	return report, nil
+}
+
+// missingTransactions returns the transactions of source whose hash isn't in target. The transactions sharing
+// a hash, e.g. the internal transfers of a transaction, are copied together.
+func missingTransactions(source []Transaction, target []Transaction) []Transaction {
+	stored := make(map[string]bool, len(target))
+	for _, tx := range target {
+		stored[tx.Hash] = true
+	}
+	var missing []Transaction
+	for _, tx := range source {
+		if !stored[tx.Hash] {
+			missing = append(missing, tx)
+		}
+	}
+	return missing
+}
+
+// migrateCheckpoint copies the checkpoint of the source unless the target has a later one, returning the
+// copied block
+func migrateCheckpoint(source Storage, target Storage) (int, error) {
+	from, ok := source.(CheckpointStore)
+	to, canSave := target.(CheckpointStore)
+	if !ok || !canSave {
+		return 0, nil
+	}
+	block, found, err := from.LoadCheckpoint()
+	if err != nil || !found {
+		return 0, err
+	}
+	current, found, err := to.LoadCheckpoint()
+	if err != nil {
+		return 0, fmt.Errorf("loading the checkpoint of the target: %w", err)
+	}
+	if found && current >= block {
+		return 0, nil
+	}
+	if err := to.SaveCheckpoint(block); err != nil {
+		return 0, fmt.Errorf("saving the checkpoint: %w", err)
+	}
+	return block, nil
+}
+
+// StoredAddresses returns the addresses with transactions, sorted
+func (s *MemoryStorage) StoredAddresses() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	addresses := make([]string, 0, len(s.data))
+	for address, transactions := range s.data {
+		if len(transactions) > 0 {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Strings(addresses)
+	return addresses, nil
+}
+
+// StoredAddresses returns the addresses of the transactions table, sorted
+func (s *SQLStorage) StoredAddresses() ([]string, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT address FROM transactions ORDER BY address`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var addresses []string
+	for rows.Next() {
+		var address string
+		if err := rows.Scan(&address); err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, rows.Err()
+}
+
+// DualWriteStorage is the Storage of a cutover between backends: the reads are served by the primary storage
+// and the writes are applied to the primary, then to the secondary. A failed write of the secondary is logged
+// and counted, not returned, so the parser keeps running on the primary; MigrateStorage then copies
+// what the secondary missed.
+type DualWriteStorage struct {
+	primary   Storage
+	secondary Storage
+	failures  atomic.Int64
+}
+
+// NewDualWriteStorage creates a DualWriteStorage writing to both storages and reading from the primary
+func NewDualWriteStorage(primary Storage, secondary Storage) *DualWriteStorage {
+	return &DualWriteStorage{primary: primary, secondary: secondary}
+}
+
+// SecondaryFailures returns the number of writes that failed on the secondary storage
+func (s *DualWriteStorage) SecondaryFailures() int {
+	return int(s.failures.Load())
+}
+
+// secondaryFailed records a failed write of the secondary storage
+func (s *DualWriteStorage) secondaryFailed(operation string, err error) {
+	s.failures.Add(1)
+	log.Printf("Error mirroring %s to the secondary storage: %v\n", operation, err)
+}
+
+// SaveTransactions saves the transactions in both storages
+func (s *DualWriteStorage) SaveTransactions(address string, transactions []Transaction) error {
+	return s.WithTx(func(tx StorageTx) error {
+		return tx.SaveTransactions(address, transactions)
+	})
+}
+
+// GetTransactions returns the transactions of the primary storage
+func (s *DualWriteStorage) GetTransactions(address string) []Transaction {
+	return s.primary.GetTransactions(address)
+}
+
+// GetTransactionByHash returns the transaction of the primary storage
+func (s *DualWriteStorage) GetTransactionByHash(hash string) (Transaction, []string, bool, error) {
+	return s.primary.GetTransactionByHash(hash)
+}
+
+// WithTx runs fn in a transaction of the primary storage and, once committed, replays its writes in a
+// transaction of the secondary
+func (s *DualWriteStorage) WithTx(fn func(tx StorageTx) error) error {
+	var writes []func(tx StorageTx) error
+	err := s.primary.WithTx(func(tx StorageTx) error {
+		recorder := &recordingTx{tx: tx}
+		// The writes of a failed attempt are discarded with it
+		writes = nil
+		if err := fn(recorder); err != nil {
+			return err
+		}
+		writes = recorder.writes
+		return nil
+	})
+	if err != nil || len(writes) == 0 {
+		return err
+	}
+	err = s.secondary.WithTx(func(tx StorageTx) error {
+		for _, write := range writes {
+			if err := write(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		s.secondaryFailed("a storage transaction", err)
+	}
+	return nil
+}
+
+// PendingOutboxEvents returns the pending events of the primary storage
+func (s *DualWriteStorage) PendingOutboxEvents(limit int) ([]OutboxEvent, error) {
+	return s.primary.PendingOutboxEvents(limit)
+}
+
+// AckOutboxEvents removes the delivered events from both storages, so that the secondary doesn't deliver
+// them again after the cutover
+func (s *DualWriteStorage) AckOutboxEvents(ids []string) error {
+	if err := s.primary.AckOutboxEvents(ids); err != nil {
+		return err
+	}
+	if err := s.secondary.AckOutboxEvents(ids); err != nil {
+		s.secondaryFailed("the outbox acknowledgements", err)
+	}
+	return nil
+}
+
+// Stats returns the stats of the primary storage
+func (s *DualWriteStorage) Stats() (StorageStats, error) {
+	return s.primary.Stats()
+}
+
+// StoredAddresses returns the addresses of the primary storage
+func (s *DualWriteStorage) StoredAddresses() ([]string, error) {
+	lister, ok := s.primary.(AddressLister)
+	if !ok {
+		return nil, fmt.Errorf("the primary storage %T can't list its addresses", s.primary)
+	}
+	return lister.StoredAddresses()
+}
+
+// LoadCheckpoint returns the checkpoint of the primary storage, none when it doesn't store checkpoints
+func (s *DualWriteStorage) LoadCheckpoint() (int, bool, error) {
+	if checkpoints, ok := s.primary.(CheckpointStore); ok {
+		return checkpoints.LoadCheckpoint()
+	}
+	return 0, false, nil
+}
+
+// SaveCheckpoint saves the checkpoint in the storages storing checkpoints
+func (s *DualWriteStorage) SaveCheckpoint(blockNumber int) error {
+	if checkpoints, ok := s.primary.(CheckpointStore); ok {
+		if err := checkpoints.SaveCheckpoint(blockNumber); err != nil {
+			return err
+		}
+	}
+	if checkpoints, ok := s.secondary.(CheckpointStore); ok {
+		if err := checkpoints.SaveCheckpoint(blockNumber); err != nil {
+			s.secondaryFailed("the checkpoint", err)
+		}
+	}
+	return nil
+}
+
+// Close closes both storages
+func (s *DualWriteStorage) Close() error {
+	var errs []error
+	for _, storage := range []Storage{s.primary, s.secondary} {
+		if closer, ok := storage.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// recordingTx applies the writes to a StorageTx and records them to be replayed on another one
+type recordingTx struct {
+	tx     StorageTx
+	writes []func(tx StorageTx) error
+}
+
+// SaveTransactions saves the transactions and records the write
+func (r *recordingTx) SaveTransactions(address string, transactions []Transaction) error {
+	if err := r.tx.SaveTransactions(address, transactions); err != nil {
+		return err
+	}
+	r.writes = append(r.writes, func(tx StorageTx) error { return tx.SaveTransactions(address, transactions) })
+	return nil
+}
+
+// DeleteTransactions deletes the block range and records the write
+func (r *recordingTx) DeleteTransactions(address string, fromBlock int, toBlock int) error {
+	if err := r.tx.DeleteTransactions(address, fromBlock, toBlock); err != nil {
+		return err
+	}
+	r.writes = append(r.writes, func(tx StorageTx) error { return tx.DeleteTransactions(address, fromBlock, toBlock) })
+	return nil
+}
+
+// AddOutboxEvent adds the event and records the write
+func (r *recordingTx) AddOutboxEvent(event OutboxEvent) error {
+	if err := r.tx.AddOutboxEvent(event); err != nil {
+		return err
+	}
+	r.writes = append(r.writes, func(tx StorageTx) error { return tx.AddOutboxEvent(event) })
+	return nil
+}
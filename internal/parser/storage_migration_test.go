@@ -0,0 +1,66 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"testing"
+)
+
+func TestMigrateStorage(t *testing.T) {
+	source, target := parser.NewMemoryStorage(), parser.NewMemoryStorage()
+	source.SaveTransactions("0x1", []parser.Transaction{{Hash: "0xa1", BlockNumberDecimal: 1}, {Hash: "0xa2", BlockNumberDecimal: 2}})
+	source.SaveTransactions("0x2", []parser.Transaction{{Hash: "0xb1", BlockNumberDecimal: 2}})
+	source.SaveCheckpoint(2)
+	// Written to both storages by a dual write before the migration
+	target.SaveTransactions("0x1", []parser.Transaction{{Hash: "0xa2", BlockNumberDecimal: 2}})
+
+	var progress []parser.StorageMigrationProgress
+	report, err := parser.MigrateStorage(context.Background(), source, target, parser.StorageMigrationOptions{
+		Verify:   true,
+		Progress: func(p parser.StorageMigrationProgress) { progress = append(progress, p) },
+	})
+	if err != nil {
+		t.Fatalf("Migration failed: %v", err)
+	}
+	if report.Addresses != 2 || report.Transactions != 2 || report.Skipped != 1 || report.Checkpoint != 2 || len(report.Mismatches) != 0 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if len(progress) != 2 || progress[1].TotalAddresses != 2 {
+		t.Errorf("Expected the progress of each address, got %+v", progress)
+	}
+	if block, _, _ := target.LoadCheckpoint(); block != 2 || len(target.GetTransactions("0x1")) != 2 || len(target.GetTransactions("0x2")) != 1 {
+		t.Errorf("Expected the target to hold the source, got checkpoint %d and %+v", block, target.GetTransactions("0x1"))
+	}
+
+	// Running it again copies nothing and keeps the later checkpoint of the target
+	target.SaveCheckpoint(5)
+	report, _ = parser.MigrateStorage(context.Background(), source, target, parser.StorageMigrationOptions{})
+	if report.Transactions != 0 || report.Skipped != 3 || report.Checkpoint != 0 {
+		t.Errorf("Expected a second run to skip everything, got %+v", report)
+	}
+}
+
+func TestDualWriteStorage(t *testing.T) {
+	primary, secondary := parser.NewMemoryStorage(), parser.NewMemoryStorage()
+	storage := parser.NewDualWriteStorage(primary, secondary)
+
+	storage.WithTx(func(tx parser.StorageTx) error {
+		tx.SaveTransactions("0x1", []parser.Transaction{{Hash: "0xa1", BlockNumberDecimal: 1}})
+		return tx.AddOutboxEvent(parser.OutboxEvent{ID: "e1"})
+	})
+	storage.SaveCheckpoint(1)
+	if len(secondary.GetTransactions("0x1")) != 1 {
+		t.Errorf("Expected the transaction to be mirrored, got %+v", secondary.GetTransactions("0x1"))
+	}
+	if block, found, _ := secondary.LoadCheckpoint(); !found || block != 1 {
+		t.Errorf("Expected the checkpoint to be mirrored, got %d", block)
+	}
+
+	storage.AckOutboxEvents([]string{"e1"})
+	if events, _ := secondary.PendingOutboxEvents(10); len(events) != 0 {
+		t.Errorf("Expected the acknowledgement to be mirrored, got %+v", events)
+	}
+	if failures := storage.SecondaryFailures(); failures != 0 {
+		t.Errorf("Expected no failure, got %d", failures)
+	}
+}
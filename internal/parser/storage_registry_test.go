@@ -0,0 +1,63 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestOpenMemoryStorage(t *testing.T) {
+	storage, err := parser.OpenStorage(context.Background(), "memory://?max_tx_per_address=1")
+	if err != nil {
+		t.Fatalf("Expected the memory storage, got %v", err)
+	}
+	storage.SaveTransactions("0x1", []parser.Transaction{{Hash: "0xa1", BlockNumberDecimal: 1}, {Hash: "0xa2", BlockNumberDecimal: 2}})
+	if transactions := storage.GetTransactions("0x1"); len(transactions) != 1 || transactions[0].Hash != "0xa2" {
+		t.Errorf("Expected the cap of the DSN to apply, got %+v", transactions)
+	}
+	if _, err := parser.OpenStorage(context.Background(), "MEMORY://"); err != nil {
+		t.Errorf("Expected the scheme to be case insensitive, got %v", err)
+	}
+	for _, dsn := range []string{"memory://?max_tx=-1", "memory://?max_tx=many", "memory://?cap=1"} {
+		if _, err := parser.OpenStorage(context.Background(), dsn); err == nil {
+			t.Errorf("Expected an error for %s", dsn)
+		}
+	}
+}
+
+func TestOpenStorageErrors(t *testing.T) {
+	if _, err := parser.OpenStorage(context.Background(), "redis://localhost:6379"); !errors.Is(err, parser.ErrUnknownStorageScheme) {
+		t.Errorf("Expected ErrUnknownStorageScheme, got %v", err)
+	}
+	if _, err := parser.OpenStorage(context.Background(), "/var/lib/eth.db"); err == nil {
+		t.Error("Expected an error for a DSN without scheme")
+	}
+	// No SQL driver is linked in the tests
+	if _, err := parser.OpenStorage(context.Background(), "sqlite://eth.db"); err == nil || !strings.Contains(err.Error(), "sqlite3") {
+		t.Errorf("Expected a missing driver error, got %v", err)
+	}
+}
+
+func TestRegisterStorage(t *testing.T) {
+	var opened string
+	parser.RegisterStorage("test-backend", func(ctx context.Context, dsn string) (parser.Storage, error) {
+		opened = dsn
+		return parser.NewMemoryStorage(), nil
+	})
+	if _, err := parser.OpenStorage(context.Background(), "test-backend://bucket/prefix"); err != nil || opened != "test-backend://bucket/prefix" {
+		t.Fatalf("Expected the registered factory to open the DSN, got %q %v", opened, err)
+	}
+	if !slices.Contains(parser.StorageSchemes(), "test-backend") {
+		t.Errorf("Expected the scheme to be listed, got %v", parser.StorageSchemes())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic registering a scheme twice")
+		}
+	}()
+	parser.RegisterStorage("memory", func(ctx context.Context, dsn string) (parser.Storage, error) { return nil, nil })
+}
@@ -0,0 +1,150 @@
+package parser_test
+
+import (
+	"errors"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestMemoryStorageWithTx(t *testing.T) {
+	storage := parser.NewMemoryStorage()
+
+	// A failing transaction must not leave partial writes behind
+	err := storage.WithTx(func(tx parser.StorageTx) error {
+		if err := tx.SaveTransactions("0x1", []parser.Transaction{{Hash: "0xabc"}}); err != nil {
+			return err
+		}
+		return errors.New("boom")
+	})
+	if err == nil {
+		t.Fatal("Expected the transaction error to be returned")
+	}
+	if transactions := storage.GetTransactions("0x1"); len(transactions) != 0 {
+		t.Fatalf("Unexpected transactions after rollback: %v", transactions)
+	}
+
+	// A successful transaction commits every staged write
+	err = storage.WithTx(func(tx parser.StorageTx) error {
+		if err := tx.SaveTransactions("0x1", []parser.Transaction{{Hash: "0xabc"}}); err != nil {
+			return err
+		}
+		return tx.SaveTransactions("0x2", []parser.Transaction{{Hash: "0xabc"}})
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(storage.GetTransactions("0x1")) != 1 || len(storage.GetTransactions("0x2")) != 1 {
+		t.Fatal("Expected committed transactions for both addresses")
+	}
+}
+
+func TestMemoryStorageOutbox(t *testing.T) {
+	storage := parser.NewMemoryStorage()
+
+	err := storage.WithTx(func(tx parser.StorageTx) error {
+		if err := tx.AddOutboxEvent(parser.OutboxEvent{ID: "1:0x1", Address: "0x1", BlockNumber: 1}); err != nil {
+			return err
+		}
+		return tx.AddOutboxEvent(parser.OutboxEvent{ID: "2:0x1", Address: "0x1", BlockNumber: 2})
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Re-adding an event with the same ID replaces it instead of duplicating it
+	err = storage.WithTx(func(tx parser.StorageTx) error {
+		return tx.AddOutboxEvent(parser.OutboxEvent{ID: "1:0x1", Address: "0x1", BlockNumber: 1,
+			Transactions: []parser.Transaction{{Hash: "0xabc"}}})
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	events, _ := storage.PendingOutboxEvents(10)
+	if len(events) != 2 || events[0].ID != "1:0x1" || len(events[0].Transactions) != 1 {
+		t.Fatalf("Unexpected outbox events: %v", events)
+	}
+
+	if err := storage.AckOutboxEvents([]string{"1:0x1"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	events, _ = storage.PendingOutboxEvents(10)
+	if len(events) != 1 || events[0].ID != "2:0x1" {
+		t.Fatalf("Unexpected outbox events after ack: %v", events)
+	}
+}
+
+func TestBoundedMemoryStorage(t *testing.T) {
+	storage := parser.NewBoundedMemoryStorage(2, 3)
+
+	storage.SaveTransactions("0x1", []parser.Transaction{
+		{Hash: "0xa1", BlockNumberDecimal: 1},
+		{Hash: "0xa2", BlockNumberDecimal: 2},
+		{Hash: "0xa3", BlockNumberDecimal: 3},
+	})
+
+	// The per address cap evicts the oldest block
+	transactions := storage.GetTransactions("0x1")
+	if len(transactions) != 2 || transactions[0].Hash != "0xa2" || !storage.Truncated("0x1") {
+		t.Fatalf("Unexpected transactions after per address eviction: %v", transactions)
+	}
+
+	// The global cap evicts the oldest block across addresses
+	storage.SaveTransactions("0x2", []parser.Transaction{
+		{Hash: "0xb4", BlockNumberDecimal: 4},
+		{Hash: "0xb5", BlockNumberDecimal: 5},
+	})
+	if transactions := storage.GetTransactions("0x1"); len(transactions) != 1 || transactions[0].Hash != "0xa3" {
+		t.Fatalf("Unexpected transactions after global eviction: %v", transactions)
+	}
+	if storage.Truncated("0x2") || len(storage.GetTransactions("0x2")) != 2 {
+		t.Fatal("Address 0x2 must be untouched")
+	}
+	if evictions := storage.Evictions(); evictions != 2 {
+		t.Fatalf("Unexpected evictions count %d", evictions)
+	}
+}
+
+func TestMemoryStorageStats(t *testing.T) {
+	storage := parser.NewMemoryStorage()
+	if stats, _ := storage.Stats(); stats != (parser.StorageStats{}) {
+		t.Fatalf("Unexpected stats of an empty storage: %+v", stats)
+	}
+
+	storage.SaveTransactions("0x1", []parser.Transaction{{Hash: "0xa", BlockNumberDecimal: 5}, {Hash: "0xb", BlockNumberDecimal: 9}})
+	storage.SaveTransactions("0x2", []parser.Transaction{{Hash: "0xb", BlockNumberDecimal: 9}})
+
+	stats, err := storage.Stats()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stats.Addresses != 2 || stats.Transactions != 3 || stats.OldestBlock != 5 || stats.NewestBlock != 9 {
+		t.Fatalf("Unexpected stats: %+v", stats)
+	}
+	if stats.ApproximateBytes <= 0 {
+		t.Fatalf("Unexpected approximate size %d", stats.ApproximateBytes)
+	}
+}
+
+func TestMemoryStorageIdempotencyRecords(t *testing.T) {
+	storage := parser.NewMemoryStorage()
+	now := time.Now()
+	storage.SaveIdempotencyRecord(parser.IdempotencyRecord{Key: "old", StatusCode: 200, CreatedAt: now.Add(-2 * time.Hour)})
+	storage.SaveIdempotencyRecord(parser.IdempotencyRecord{Key: "new", StatusCode: 200, Body: []byte(`{"success":true}`), CreatedAt: now})
+
+	record, found, err := storage.GetIdempotencyRecord("new")
+	if err != nil || !found || string(record.Body) != `{"success":true}` {
+		t.Fatalf("Unexpected record: %+v %v %v", record, found, err)
+	}
+
+	if err := storage.DeleteIdempotencyRecordsBefore(now.Add(-time.Hour)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, found, _ := storage.GetIdempotencyRecord("old"); found {
+		t.Fatal("Expired record not deleted")
+	}
+	if _, found, _ := storage.GetIdempotencyRecord("new"); !found {
+		t.Fatal("Record within the window deleted")
+	}
+}
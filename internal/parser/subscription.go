@@ -0,0 +1,218 @@
+package parser
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Subscription is a watched address together with its per-subscription settings
+type Subscription struct {
+	Address string       `json:"address"`
+	Email   *EmailConfig `json:"email,omitempty"`
+	// Tenant is the ID of the tenant owning the subscription, empty when multi-tenancy is disabled
+	Tenant string `json:"tenant,omitempty"`
+	// StartBlock is the first block of interest, the blocks already processed from it are rescanned for the
+	// address when it's subscribed through the API
+	StartBlock int `json:"startBlock,omitempty"`
+	// Inactivity sends an EventAddressInactive event when the address sees no transaction for a period
+	Inactivity *InactivityAlert `json:"inactivity,omitempty"`
+	// Priority is high, normal or low, empty meaning normal. The high priority addresses of a block are
+	// notified first, and while the parser catches up with a backlog the notifications of the low priority
+	// ones wait until it's done.
+	Priority string `json:"priority,omitempty"`
+	// Muted pauses the notifications of the address until MutedUntil, or until it's unmuted when MutedUntil is
+	// nil, see Mute
+	Muted      bool       `json:"muted,omitempty"`
+	MutedUntil *time.Time `json:"mutedUntil,omitempty"`
+	// Metadata is an opaque JSON value of the client, e.g. its user or order ID, echoed verbatim in the
+	// notifications and the transactions of the address, see ValidateSubscriptionMetadata
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	// UnsubscribedAt is set on the subscriptions soft-deleted by Unsubscribe
+	UnsubscribedAt *time.Time `json:"unsubscribedAt,omitempty"`
+}
+
+// File formats of ImportSubscriptions and ExportSubscriptions
+const (
+	SubscriptionFormatJSON = "json"
+	SubscriptionFormatCSV  = "csv"
+)
+
+// ErrInvalidSubscriptionFormat is returned for a file format other than json and csv
+var ErrInvalidSubscriptionFormat = errors.New("invalid subscription file format")
+
+// subscriptionCSVHeader is the header of the CSV files, the email recipients are separated by semicolons
+var subscriptionCSVHeader = []string{"address", "email_recipients", "email_digest", "start_block", "inactivity_after", "priority", "metadata"}
+
+// Subscriptions returns the subscriptions ordered by address
+func (p *EthParser) Subscriptions() []Subscription {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	subscriptions := make([]Subscription, 0, len(p.subscriptions))
+	for _, subscription := range p.subscriptions {
+		subscriptions = append(subscriptions, *subscription)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].Address < subscriptions[j].Address })
+	return subscriptions
+}
+
+// Unsubscribe stops watching an address and returns its subscription stamped with UnsubscribedAt, false
+// when it isn't subscribed. It's a soft delete: the stored transactions and the entity membership of the
+// address stay queryable.
+func (p *EthParser) Unsubscribe(address string) (Subscription, bool) {
+	p.mu.Lock()
+	subscription, exists := p.subscriptions[address]
+	if !exists {
+		p.mu.Unlock()
+		return Subscription{}, false
+	}
+	now := p.clock.Now()
+	subscription.UnsubscribedAt = &now
+	delete(p.subscriptions, address)
+	delete(p.callbacks, address)
+	p.stopInactivityTimerLocked("", address)
+	p.mu.Unlock()
+	p.persistSubscription(address)
+	return *subscription, true
+}
+
+// UpdateSubscription replaces the notification settings, the inactivity alert and the priority of a
+// subscribed address, false when it isn't subscribed. The inactivity period starts over.
+func (p *EthParser) UpdateSubscription(subscription Subscription) bool {
+	p.mu.Lock()
+	current, exists := p.subscriptions[subscription.Address]
+	if !exists {
+		p.mu.Unlock()
+		return false
+	}
+	current.Email = subscription.Email
+	current.Inactivity = subscription.Inactivity
+	current.Priority = subscription.Priority
+	current.Metadata = subscription.Metadata
+	p.setInactivityTimerLocked("", subscription.Address, subscription.Inactivity)
+	p.mu.Unlock()
+	p.persistSubscription(subscription.Address)
+	return true
+}
+
+// ExportSubscriptions writes the subscriptions as a JSON array or as CSV, without their tenant
+func ExportSubscriptions(w io.Writer, subscriptions []Subscription, format string) error {
+	switch format {
+	case SubscriptionFormatJSON:
+		exported := make([]Subscription, len(subscriptions))
+		for i, subscription := range subscriptions {
+			subscription.Tenant = ""
+			exported[i] = subscription
+		}
+		return json.NewEncoder(w).Encode(exported)
+	case SubscriptionFormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(subscriptionCSVHeader); err != nil {
+			return err
+		}
+		for _, subscription := range subscriptions {
+			var recipients, digest, startBlock, inactivity string
+			if subscription.Email != nil {
+				recipients = strings.Join(subscription.Email.Recipients, ";")
+				digest = subscription.Email.Digest
+			}
+			if subscription.StartBlock > 0 {
+				startBlock = strconv.Itoa(subscription.StartBlock)
+			}
+			if subscription.Inactivity != nil {
+				inactivity = subscription.Inactivity.After
+			}
+			if err := writer.Write([]string{subscription.Address, recipients, digest, startBlock, inactivity, subscription.Priority, string(subscription.Metadata)}); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		return ErrInvalidSubscriptionFormat
+	}
+}
+
+// ImportSubscriptions reads the subscriptions of a file written by ExportSubscriptions. The CSV columns are
+// matched by header name, so that columns can be omitted or reordered; only address is required.
+func ImportSubscriptions(r io.Reader, format string) ([]Subscription, error) {
+	switch format {
+	case SubscriptionFormatJSON:
+		var subscriptions []Subscription
+		if err := json.NewDecoder(r).Decode(&subscriptions); err != nil {
+			return nil, fmt.Errorf("decoding subscriptions: %w", err)
+		}
+		return subscriptions, nil
+	case SubscriptionFormatCSV:
+		return importSubscriptionsCSV(r)
+	default:
+		return nil, ErrInvalidSubscriptionFormat
+	}
+}
+
+// importSubscriptionsCSV reads a CSV file of subscriptions with a header row
+func importSubscriptionsCSV(r io.Reader) ([]Subscription, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading the CSV header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["address"]; !ok {
+		return nil, fmt.Errorf("the CSV header has no address column")
+	}
+
+	var subscriptions []Subscription
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return subscriptions, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		subscription := Subscription{Address: field("address")}
+		if recipients := field("email_recipients"); recipients != "" {
+			subscription.Email = &EmailConfig{Recipients: strings.Split(recipients, ";"), Digest: field("email_digest")}
+		}
+		if startBlock := field("start_block"); startBlock != "" {
+			if subscription.StartBlock, err = strconv.Atoi(startBlock); err != nil || subscription.StartBlock < 0 {
+				return nil, fmt.Errorf("line %d: invalid start block %q", line, startBlock)
+			}
+		}
+		if after := field("inactivity_after"); after != "" {
+			subscription.Inactivity = &InactivityAlert{After: after}
+			if _, err := subscription.Inactivity.Period(); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		if subscription.Priority = strings.ToLower(field("priority")); !ValidPriority(subscription.Priority) {
+			return nil, fmt.Errorf("line %d: invalid priority %q", line, subscription.Priority)
+		}
+		if metadata := field("metadata"); metadata != "" {
+			subscription.Metadata = json.RawMessage(metadata)
+			if err := ValidateSubscriptionMetadata(subscription.Metadata); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+}
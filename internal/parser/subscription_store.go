@@ -0,0 +1,145 @@
+package parser
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+)
+
+// SubscriptionStore persists the subscriptions, so that a restarted parser watches the same addresses
+type SubscriptionStore interface {
+	// SaveSubscription saves a subscription, replacing the one of the same address
+	SaveSubscription(subscription Subscription) error
+	DeleteSubscription(address string) error
+	LoadSubscriptions() ([]Subscription, error)
+}
+
+// restoreSubscriptions subscribes the addresses of the subscription store on Start, the addresses already
+// subscribed keep their subscription
+func (p *EthParser) restoreSubscriptions() {
+	if p.subscriptionStore == nil {
+		return
+	}
+	subscriptions, err := p.subscriptionStore.LoadSubscriptions()
+	if err != nil {
+		log.Println("Error loading the subscriptions:", err)
+		return
+	}
+	restored := 0
+	p.mu.Lock()
+	for _, subscription := range subscriptions {
+		if _, exists := p.subscriptions[subscription.Address]; exists {
+			continue
+		}
+		subscription.UnsubscribedAt = nil
+		p.subscriptions[subscription.Address] = &subscription
+		if subscription.Inactivity != nil {
+			p.setInactivityTimerLocked("", subscription.Address, subscription.Inactivity)
+		}
+		restored++
+	}
+	p.mu.Unlock()
+	log.Printf("Restored %d subscriptions\n", restored)
+}
+
+// persistSubscription saves the subscription of an address to the subscription store, or deletes it when the
+// address isn't subscribed anymore
+func (p *EthParser) persistSubscription(address string) {
+	if p.subscriptionStore == nil {
+		return
+	}
+	p.subscriptionStoreMu.Lock()
+	defer p.subscriptionStoreMu.Unlock()
+	p.mu.Lock()
+	subscription, subscribed := p.subscriptions[address]
+	var saved Subscription
+	if subscribed {
+		saved = *subscription
+	}
+	p.mu.Unlock()
+
+	var err error
+	if subscribed {
+		err = p.subscriptionStore.SaveSubscription(saved)
+	} else {
+		err = p.subscriptionStore.DeleteSubscription(address)
+	}
+	if err := p.recordStorageWrite(err); err != nil {
+		log.Printf("Error saving the subscription of %s: %v\n", address, err)
+	}
+}
+
+// SaveSubscription keeps the subscription in memory, it only survives a Stop and Start of the parser
+func (s *MemoryStorage) SaveSubscription(subscription Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscriptions[subscription.Address] = subscription
+	return nil
+}
+
+// DeleteSubscription removes the subscription of an address
+func (s *MemoryStorage) DeleteSubscription(address string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subscriptions, address)
+	return nil
+}
+
+// LoadSubscriptions returns the saved subscriptions ordered by address
+func (s *MemoryStorage) LoadSubscriptions() ([]Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	subscriptions := make([]Subscription, 0, len(s.subscriptions))
+	for _, subscription := range s.subscriptions {
+		subscriptions = append(subscriptions, subscription)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].Address < subscriptions[j].Address })
+	return subscriptions, nil
+}
+
+// SaveSubscription upserts the subscription row of the address
+func (s *SQLStorage) SaveSubscription(subscription Subscription) error {
+	data, err := json.Marshal(subscription)
+	if err != nil {
+		return err
+	}
+	payload, err := sealField(s.cipher, string(data), "subscriptions/"+subscription.Address)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO subscriptions (address, payload) VALUES ($1, $2)
+		ON CONFLICT (address) DO UPDATE SET payload = excluded.payload`, subscription.Address, payload)
+	return err
+}
+
+// DeleteSubscription deletes the subscription row of the address
+func (s *SQLStorage) DeleteSubscription(address string) error {
+	_, err := s.db.Exec(`DELETE FROM subscriptions WHERE address = $1`, address)
+	return err
+}
+
+// LoadSubscriptions returns the saved subscriptions ordered by address
+func (s *SQLStorage) LoadSubscriptions() ([]Subscription, error) {
+	rows, err := s.db.Query(`SELECT address, payload FROM subscriptions ORDER BY address`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var subscriptions []Subscription
+	for rows.Next() {
+		var address, payload string
+		if err := rows.Scan(&address, &payload); err != nil {
+			return nil, err
+		}
+		data, err := openField(s.cipher, payload, "subscriptions/"+address)
+		if err != nil {
+			return nil, err
+		}
+		var subscription Subscription
+		if err := json.Unmarshal([]byte(data), &subscription); err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, rows.Err()
+}
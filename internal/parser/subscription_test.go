@@ -0,0 +1,62 @@
+package parser_test
+
+import (
+	"bytes"
+	"context"
+	"eth-parser/internal/parser"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSubscriptionsExportImportRoundTrip(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(NewMockBlockchain()), func(string, []parser.Transaction) {})
+	defer ethParser.WaitForShutdown()
+	ethParser.SubscribeWith(parser.Subscription{Address: "0x2", StartBlock: 5})
+	ethParser.SubscribeWith(parser.Subscription{Address: "0x1",
+		Email: &parser.EmailConfig{Recipients: []string{"a@example.com", "b@example.com"}, Digest: parser.DigestDaily}})
+
+	subscriptions := ethParser.Subscriptions()
+	if len(subscriptions) != 2 || subscriptions[0].Address != "0x1" || subscriptions[1].Address != "0x2" {
+		t.Fatalf("Expected the subscriptions ordered by address, got %+v", subscriptions)
+	}
+	for _, format := range []string{parser.SubscriptionFormatJSON, parser.SubscriptionFormatCSV} {
+		var file bytes.Buffer
+		if err := parser.ExportSubscriptions(&file, subscriptions, format); err != nil {
+			t.Fatalf("Exporting %s: %v", format, err)
+		}
+		imported, err := parser.ImportSubscriptions(&file, format)
+		if err != nil {
+			t.Fatalf("Importing %s: %v", format, err)
+		}
+		if !reflect.DeepEqual(imported, subscriptions) {
+			t.Errorf("Expected the %s round trip to keep %+v, got %+v", format, subscriptions, imported)
+		}
+	}
+}
+
+func TestImportSubscriptionsCSV(t *testing.T) {
+	// Columns are matched by name, so they can be reordered or omitted
+	file := "start_block,address\n12,0x1\n,0x2\n"
+	imported, err := parser.ImportSubscriptions(strings.NewReader(file), parser.SubscriptionFormatCSV)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []parser.Subscription{{Address: "0x1", StartBlock: 12}, {Address: "0x2"}}
+	if !reflect.DeepEqual(imported, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, imported)
+	}
+
+	if _, err := parser.ImportSubscriptions(strings.NewReader("email_recipients\na@example.com\n"), parser.SubscriptionFormatCSV); err == nil {
+		t.Error("Expected an error without an address column")
+	}
+	if _, err := parser.ImportSubscriptions(strings.NewReader("address,start_block\n0x1,abc\n"), parser.SubscriptionFormatCSV); err == nil {
+		t.Error("Expected an error for an invalid start block")
+	}
+	if _, err := parser.ImportSubscriptions(strings.NewReader("[]"), "xml"); err != parser.ErrInvalidSubscriptionFormat {
+		t.Errorf("Expected ErrInvalidSubscriptionFormat, got %v", err)
+	}
+}
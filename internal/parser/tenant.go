@@ -0,0 +1,952 @@
+package parser
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrTenantExists is returned when creating a tenant with an ID already in use
+var ErrTenantExists = errors.New("tenant already exists")
+
+// ErrInvalidTenantID is returned when creating a tenant with an ID out of tenantIDFormat
+var ErrInvalidTenantID = errors.New("invalid tenant ID")
+
+// tenantIDFormat is the format of a tenant ID, which prefixes the entity names and the consumer keys of the
+// tenant with a slash it can't contain
+var tenantIDFormat = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// ErrSubscriptionQuotaExceeded is returned when a tenant reached its maximum number of subscriptions
+var ErrSubscriptionQuotaExceeded = errors.New("subscription quota exceeded")
+
+// Tenant is an isolated namespace of a shared deployment
+type Tenant struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// MaxSubscriptions is the subscription quota of the tenant, zero means unlimited
+	MaxSubscriptions int `json:"maxSubscriptions"`
+	Subscriptions    int `json:"subscriptions"`
+}
+
+// TenantManager isolates several tenants on a single EthParser. Each tenant, identified by its API key,
+// only sees its own subscriptions, notification settings and entities, and can only read the transactions
+// of the addresses it subscribed to. Blocks are fetched and matched once for all the tenants.
+type TenantManager struct {
+	parser  *EthParser
+	store   TenantStore // see WithStore, nil keeps the tenants in memory only
+	tenants map[string]*tenantState
+	apiKeys map[string]string // sha256 of the API key -> tenant ID
+	mu      sync.Mutex
+}
+
+// TenantStore persists the tenants, the hashes of their API keys and their subscriptions, so that a restart
+// keeps them and the issued keys stay valid
+type TenantStore interface {
+	// SaveTenant saves a tenant, replacing the one with the same ID
+	SaveTenant(record TenantRecord) error
+	// TenantRecords returns the tenants ordered by ID
+	TenantRecords() ([]TenantRecord, error)
+	DeleteTenant(id string) error
+}
+
+// TenantRecord is the persisted state of a tenant
+type TenantRecord struct {
+	Tenant        Tenant         `json:"tenant"`
+	APIKeyHash    string         `json:"apiKeyHash"`
+	Subscriptions []Subscription `json:"subscriptions"`
+	// Unsubscribed are the soft-deleted subscriptions, whose history the tenant can still read
+	Unsubscribed []Subscription `json:"unsubscribed,omitempty"`
+}
+
+// tenantState holds the namespace of a tenant
+type tenantState struct {
+	Tenant
+	apiKeyHash    string
+	subscriptions map[string]Subscription
+	unsubscribed  map[string]Subscription // soft-deleted subscriptions, whose history the tenant can still read
+}
+
+// NewTenantManager creates a TenantManager on top of a parser
+func NewTenantManager(parser *EthParser) *TenantManager {
+	m := &TenantManager{
+		parser:  parser,
+		tenants: make(map[string]*tenantState),
+		apiKeys: make(map[string]string),
+	}
+	parser.mu.Lock()
+	parser.tenants = m
+	parser.mu.Unlock()
+	return m
+}
+
+// WithStore saves the tenants to store from then on, and restores the ones it holds: their API keys are
+// valid again and the shared parser watches their addresses. The entities of the tenants, like the ones of
+// the parser, live in memory only.
+func (m *TenantManager) WithStore(store TenantStore) *TenantManager {
+	records, err := store.TenantRecords()
+	if err != nil {
+		log.Println("Error loading the tenants:", err)
+	}
+
+	m.mu.Lock()
+	m.store = store
+	addresses := make(map[string]bool)
+	for _, record := range records {
+		tenant := &tenantState{
+			Tenant:        record.Tenant,
+			apiKeyHash:    record.APIKeyHash,
+			subscriptions: make(map[string]Subscription, len(record.Subscriptions)),
+			unsubscribed:  make(map[string]Subscription, len(record.Unsubscribed)),
+		}
+		for _, subscription := range record.Subscriptions {
+			tenant.subscriptions[subscription.Address] = subscription
+			addresses[subscription.Address] = true
+		}
+		for _, subscription := range record.Unsubscribed {
+			tenant.unsubscribed[subscription.Address] = subscription
+		}
+		m.tenants[record.Tenant.ID] = tenant
+		m.apiKeys[record.APIKeyHash] = record.Tenant.ID
+	}
+	type sharedState struct {
+		priority   string
+		muted      bool
+		mutedUntil *time.Time
+	}
+	shared := make(map[string]sharedState, len(addresses))
+	for address := range addresses {
+		muted, mutedUntil := m.sharedMuteLocked(address)
+		shared[address] = sharedState{priority: m.sharedPriorityLocked(address), muted: muted, mutedUntil: mutedUntil}
+	}
+	var inactivity []Subscription
+	for _, tenant := range m.tenants {
+		for _, subscription := range tenant.subscriptions {
+			if subscription.Inactivity != nil {
+				inactivity = append(inactivity, subscription)
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	for address, state := range shared {
+		m.parser.Subscribe(address)
+		m.parser.setPriority(address, state.priority)
+		m.parser.setMute(address, state.muted, state.mutedUntil)
+	}
+	for _, subscription := range inactivity {
+		m.parser.setInactivityTimer(subscription.Tenant, subscription.Address, subscription.Inactivity)
+	}
+	return m
+}
+
+// record returns the persisted state of a tenant, it's called with mu held
+func (t *tenantState) record() TenantRecord {
+	record := TenantRecord{Tenant: t.Tenant, APIKeyHash: t.apiKeyHash, Subscriptions: make([]Subscription, 0, len(t.subscriptions))}
+	for _, subscription := range t.subscriptions {
+		record.Subscriptions = append(record.Subscriptions, subscription)
+	}
+	for _, subscription := range t.unsubscribed {
+		record.Unsubscribed = append(record.Unsubscribed, subscription)
+	}
+	sort.Slice(record.Subscriptions, func(i, j int) bool { return record.Subscriptions[i].Address < record.Subscriptions[j].Address })
+	sort.Slice(record.Unsubscribed, func(i, j int) bool { return record.Unsubscribed[i].Address < record.Unsubscribed[j].Address })
+	return record
+}
+
+// persistLocked saves a tenant to the store, it's called with mu held
+func (m *TenantManager) persistLocked(tenant *tenantState) error {
+	if m.store == nil {
+		return nil
+	}
+	return m.parser.recordStorageWrite(m.store.SaveTenant(tenant.record()))
+}
+
+// saveLocked saves a tenant after a change of its subscriptions, logging the errors. It's called with mu held.
+func (m *TenantManager) saveLocked(tenant *tenantState) {
+	if err := m.persistLocked(tenant); err != nil {
+		log.Printf("Error saving tenant %s: %v\n", tenant.ID, err)
+	}
+}
+
+// CreateTenant creates a tenant and returns its API key. The key is only stored hashed,
+// so it can't be retrieved later. The ID is 1 to 64 letters, digits, '_', '.' or '-'.
+func (m *TenantManager) CreateTenant(id string, name string, maxSubscriptions int) (string, error) {
+	if !tenantIDFormat.MatchString(id) {
+		return "", ErrInvalidTenantID
+	}
+	keyBytes := make([]byte, 32)
+	if _, err := rand.Read(keyBytes); err != nil {
+		return "", err
+	}
+	apiKey := hex.EncodeToString(keyBytes)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.tenants[id]; exists {
+		return "", ErrTenantExists
+	}
+	keyHash := hashAPIKey(apiKey)
+	tenant := &tenantState{
+		Tenant:        Tenant{ID: id, Name: name, MaxSubscriptions: maxSubscriptions},
+		apiKeyHash:    keyHash,
+		subscriptions: make(map[string]Subscription),
+		unsubscribed:  make(map[string]Subscription),
+	}
+	// The key is only returned once the tenant is saved, it would be lost on restart otherwise
+	if err := m.persistLocked(tenant); err != nil {
+		return "", err
+	}
+	m.tenants[id] = tenant
+	m.apiKeys[keyHash] = id
+	return apiKey, nil
+}
+
+// DeleteTenant removes a tenant and revokes its API key. The parser keeps watching its addresses,
+// but the tenant data isn't reachable anymore and its inactivity alerts are stopped.
+func (m *TenantManager) DeleteTenant(id string) bool {
+	m.mu.Lock()
+	tenant, exists := m.tenants[id]
+	if !exists {
+		m.mu.Unlock()
+		return false
+	}
+	delete(m.apiKeys, tenant.apiKeyHash)
+	delete(m.tenants, id)
+	if m.store != nil {
+		if err := m.parser.recordStorageWrite(m.store.DeleteTenant(id)); err != nil {
+			log.Printf("Error deleting tenant %s: %v\n", id, err)
+		}
+	}
+	m.mu.Unlock()
+
+	for address := range tenant.subscriptions {
+		m.parser.stopInactivityTimer(id, address)
+	}
+	return true
+}
+
+// Tenants returns the tenants sorted by ID
+func (m *TenantManager) Tenants() []Tenant {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tenants := make([]Tenant, 0, len(m.tenants))
+	for _, tenant := range m.tenants {
+		info := tenant.Tenant
+		info.Subscriptions = len(tenant.subscriptions)
+		tenants = append(tenants, info)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants
+}
+
+// TenantForAPIKey returns the ID of the tenant owning an API key
+func (m *TenantManager) TenantForAPIKey(apiKey string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id, ok := m.apiKeys[hashAPIKey(apiKey)]
+	return id, ok
+}
+
+// SubscriptionsFor returns the subscriptions of all the tenants to an address, used by the notifiers
+// to deliver each tenant its own notifications
+func (m *TenantManager) SubscriptionsFor(address string) []Subscription {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var subscriptions []Subscription
+	for _, tenant := range m.tenants {
+		if subscription, ok := tenant.subscriptions[address]; ok {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].Tenant < subscriptions[j].Tenant })
+	return subscriptions
+}
+
+// subscribes reports whether a tenant subscribes to an address, whatever the case of its hex digits
+func (m *TenantManager) subscribes(tenantID string, address string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tenant, exists := m.tenants[tenantID]
+	if !exists {
+		return false
+	}
+	if _, ok := tenant.subscriptions[address]; ok {
+		return true
+	}
+	for subscribed := range tenant.subscriptions {
+		if strings.EqualFold(subscribed, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// View returns the Parser scoped to a tenant
+func (m *TenantManager) View(tenantID string) *TenantParser {
+	return &TenantParser{manager: m, tenantID: tenantID}
+}
+
+// sharedPriorityLocked returns the priority the shared parser gives to an address, the highest one of the
+// tenant subscriptions. It's called with mu held.
+func (m *TenantManager) sharedPriorityLocked(address string) string {
+	priority := PriorityLow
+	for _, tenant := range m.tenants {
+		if subscription, ok := tenant.subscriptions[address]; ok && priorityRank(subscription.Priority) < priorityRank(priority) {
+			priority = subscription.Priority
+		}
+	}
+	if priority == "" || priority == PriorityNormal {
+		return ""
+	}
+	return priority
+}
+
+// sharedMuteLocked returns the mute the shared parser gives to an address: muted while every tenant
+// subscription is, until the last of them ends. It's called with mu held.
+func (m *TenantManager) sharedMuteLocked(address string) (bool, *time.Time) {
+	muted := false
+	var until *time.Time
+	for _, tenant := range m.tenants {
+		subscription, ok := tenant.subscriptions[address]
+		if !ok {
+			continue
+		}
+		if !subscription.Muted {
+			return false, nil
+		}
+		if !muted || (until != nil && (subscription.MutedUntil == nil || subscription.MutedUntil.After(*until))) {
+			until = subscription.MutedUntil
+		}
+		muted = true
+	}
+	return muted, until
+}
+
+// hashAPIKey returns the hex sha256 of an API key
+func hashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// TenantParser implements the Parser interface restricted to the namespace of a tenant
+type TenantParser struct {
+	manager  *TenantManager
+	tenantID string
+}
+
+// owns reports whether the tenant subscribed to the address
+func (t *TenantParser) owns(address string) bool {
+	t.manager.mu.Lock()
+	defer t.manager.mu.Unlock()
+	tenant, exists := t.manager.tenants[t.tenantID]
+	if !exists {
+		return false
+	}
+	_, ok := tenant.subscriptions[address]
+	return ok
+}
+
+// ownsHistory reports whether the tenant subscribes, or subscribed before unsubscribing, to the address
+func (t *TenantParser) ownsHistory(address string) bool {
+	t.manager.mu.Lock()
+	defer t.manager.mu.Unlock()
+	tenant, exists := t.manager.tenants[t.tenantID]
+	if !exists {
+		return false
+	}
+	_, subscribed := tenant.subscriptions[address]
+	_, unsubscribed := tenant.unsubscribed[address]
+	return subscribed || unsubscribed
+}
+
+// metadata returns the metadata of the tenant subscription of an address, the shared parser holding none.
+// The soft-deleted subscriptions keep theirs for the history.
+func (t *TenantParser) metadata(address string) json.RawMessage {
+	t.manager.mu.Lock()
+	defer t.manager.mu.Unlock()
+	tenant, exists := t.manager.tenants[t.tenantID]
+	if !exists {
+		return nil
+	}
+	if subscription, subscribed := tenant.subscriptions[address]; subscribed {
+		return subscription.Metadata
+	}
+	return tenant.unsubscribed[address].Metadata
+}
+
+// entityKey namespaces an entity ID with the tenant ID
+func (t *TenantParser) entityKey(entityID string) entityKey {
+	return entityKey{t.tenantID, entityID}
+}
+
+// TenantID returns the ID of the tenant of the view
+func (t *TenantParser) TenantID() string {
+	return t.tenantID
+}
+
+// GetCurrentBlock returns the last parsed block number
+func (t *TenantParser) GetCurrentBlock() int {
+	return t.manager.parser.GetCurrentBlock()
+}
+
+// Subscribe adds an address to the tenant subscriptions
+func (t *TenantParser) Subscribe(address string) bool {
+	ok, _ := t.TrySubscribe(Subscription{Address: address})
+	return ok
+}
+
+// SubscribeWith adds an address to the tenant subscriptions together with its settings
+func (t *TenantParser) SubscribeWith(subscription Subscription) bool {
+	ok, _ := t.TrySubscribe(subscription)
+	return ok
+}
+
+// TrySubscribe is SubscribeWith reporting ErrSubscriptionQuotaExceeded when the tenant is over quota
+func (t *TenantParser) TrySubscribe(subscription Subscription) (bool, error) {
+	subscription.Tenant = t.tenantID
+
+	t.manager.mu.Lock()
+	tenant, exists := t.manager.tenants[t.tenantID]
+	if !exists {
+		t.manager.mu.Unlock()
+		return false, nil
+	}
+	if _, subscribed := tenant.subscriptions[subscription.Address]; subscribed {
+		t.manager.mu.Unlock()
+		return false, nil
+	}
+	if tenant.MaxSubscriptions > 0 && len(tenant.subscriptions) >= tenant.MaxSubscriptions {
+		t.manager.mu.Unlock()
+		return false, ErrSubscriptionQuotaExceeded
+	}
+	subscription.UnsubscribedAt = nil
+	tenant.subscriptions[subscription.Address] = subscription
+	delete(tenant.unsubscribed, subscription.Address)
+	t.manager.saveLocked(tenant)
+	priority := t.manager.sharedPriorityLocked(subscription.Address)
+	muted, mutedUntil := t.manager.sharedMuteLocked(subscription.Address)
+	t.manager.mu.Unlock()
+
+	// The shared parser watches the address once, whatever the number of tenants
+	t.manager.parser.Subscribe(subscription.Address)
+	t.manager.parser.setPriority(subscription.Address, priority)
+	t.manager.parser.setMute(subscription.Address, muted, mutedUntil)
+	if subscription.Inactivity != nil {
+		t.manager.parser.setInactivityTimer(t.tenantID, subscription.Address, subscription.Inactivity)
+	}
+	return true, nil
+}
+
+// Unsubscribe soft-deletes the tenant subscription of an address, freeing its quota. The tenant can still
+// read the stored transactions of the address, which the shared parser stops watching once no tenant
+// subscribes to it.
+func (t *TenantParser) Unsubscribe(address string) (Subscription, bool) {
+	return t.removeSubscription(address, true, nil)
+}
+
+// removeSubscription removes the tenant subscription of an address. A soft delete keeps it for the history,
+// otherwise the history of the address is restored to history, nil for none.
+func (t *TenantParser) removeSubscription(address string, softDelete bool, history *Subscription) (Subscription, bool) {
+	t.manager.mu.Lock()
+	tenant, exists := t.manager.tenants[t.tenantID]
+	if !exists {
+		t.manager.mu.Unlock()
+		return Subscription{}, false
+	}
+	subscription, subscribed := tenant.subscriptions[address]
+	if !subscribed {
+		t.manager.mu.Unlock()
+		return Subscription{}, false
+	}
+	delete(tenant.subscriptions, address)
+	if softDelete {
+		now := t.manager.parser.clock.Now()
+		subscription.UnsubscribedAt = &now
+		tenant.unsubscribed[address] = subscription
+	} else if history != nil {
+		tenant.unsubscribed[address] = *history
+	}
+	t.manager.saveLocked(tenant)
+	shared := false
+	for _, other := range t.manager.tenants {
+		if _, ok := other.subscriptions[address]; ok {
+			shared = true
+			break
+		}
+	}
+	priority := t.manager.sharedPriorityLocked(address)
+	muted, mutedUntil := t.manager.sharedMuteLocked(address)
+	t.manager.mu.Unlock()
+
+	t.manager.parser.stopInactivityTimer(t.tenantID, address)
+	if !shared {
+		t.manager.parser.Unsubscribe(address)
+	} else {
+		t.manager.parser.setPriority(address, priority)
+		t.manager.parser.setMute(address, muted, mutedUntil)
+	}
+	return subscription, true
+}
+
+// UpdateSubscription replaces the notification settings, the inactivity alert and the priority of a tenant
+// subscription
+func (t *TenantParser) UpdateSubscription(subscription Subscription) bool {
+	t.manager.mu.Lock()
+	tenant, exists := t.manager.tenants[t.tenantID]
+	if !exists {
+		t.manager.mu.Unlock()
+		return false
+	}
+	current, subscribed := tenant.subscriptions[subscription.Address]
+	if !subscribed {
+		t.manager.mu.Unlock()
+		return false
+	}
+	current.Email = subscription.Email
+	current.Inactivity = subscription.Inactivity
+	current.Priority = subscription.Priority
+	current.Metadata = subscription.Metadata
+	tenant.subscriptions[subscription.Address] = current
+	t.manager.saveLocked(tenant)
+	priority := t.manager.sharedPriorityLocked(subscription.Address)
+	t.manager.mu.Unlock()
+
+	t.manager.parser.setInactivityTimer(t.tenantID, subscription.Address, subscription.Inactivity)
+	t.manager.parser.setPriority(subscription.Address, priority)
+	return true
+}
+
+// Mute pauses the notifications of the tenant subscription of an address, see EthParser.Mute. The shared
+// parser only stops notifying the address once every tenant muted it, the notifiers skip the muted tenants.
+func (t *TenantParser) Mute(address string, duration time.Duration) (Subscription, bool) {
+	var until *time.Time
+	if duration > 0 {
+		expiry := t.manager.parser.clock.Now().Add(duration)
+		until = &expiry
+	}
+	return t.setMute(address, true, until)
+}
+
+// Unmute resumes the notifications of the tenant subscription of an address
+func (t *TenantParser) Unmute(address string) (Subscription, bool) {
+	return t.setMute(address, false, nil)
+}
+
+// setMute sets the mute of the tenant subscription of an address and the shared mute
+func (t *TenantParser) setMute(address string, muted bool, until *time.Time) (Subscription, bool) {
+	t.manager.mu.Lock()
+	tenant, exists := t.manager.tenants[t.tenantID]
+	if !exists {
+		t.manager.mu.Unlock()
+		return Subscription{}, false
+	}
+	subscription, subscribed := tenant.subscriptions[address]
+	if !subscribed {
+		t.manager.mu.Unlock()
+		return Subscription{}, false
+	}
+	subscription.Muted, subscription.MutedUntil = muted, until
+	tenant.subscriptions[address] = subscription
+	t.manager.saveLocked(tenant)
+	sharedMuted, sharedUntil := t.manager.sharedMuteLocked(address)
+	t.manager.mu.Unlock()
+
+	t.manager.parser.setMute(address, sharedMuted, sharedUntil)
+	return subscription, true
+}
+
+// GetSubscription returns the tenant subscription of an address
+func (t *TenantParser) GetSubscription(address string) (Subscription, bool) {
+	t.manager.mu.Lock()
+	defer t.manager.mu.Unlock()
+	tenant, exists := t.manager.tenants[t.tenantID]
+	if !exists {
+		return Subscription{}, false
+	}
+	subscription, ok := tenant.subscriptions[address]
+	return subscription, ok
+}
+
+// Subscriptions returns the subscriptions of the tenant ordered by address
+func (t *TenantParser) Subscriptions() []Subscription {
+	t.manager.mu.Lock()
+	defer t.manager.mu.Unlock()
+	tenant, exists := t.manager.tenants[t.tenantID]
+	if !exists {
+		return []Subscription{}
+	}
+	subscriptions := make([]Subscription, 0, len(tenant.subscriptions))
+	for _, subscription := range tenant.subscriptions {
+		subscriptions = append(subscriptions, subscription)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].Address < subscriptions[j].Address })
+	return subscriptions
+}
+
+// GetTransactions returns the transactions of an address subscribed, now or before, by the tenant
+func (t *TenantParser) GetTransactions(address string) []Transaction {
+	if !t.ownsHistory(address) {
+		return nil
+	}
+	return withMetadata(t.manager.parser.GetTransactions(address), t.metadata(address))
+}
+
+// QueryTransactions returns the transactions of the addresses subscribed, now or before, by the tenant, see
+// EthParser.QueryTransactions. The other addresses are left out of the result.
+func (t *TenantParser) QueryTransactions(query TxQuery) TxResult {
+	owned := make([]string, 0, len(query.Addresses))
+	t.manager.mu.Lock()
+	if tenant, exists := t.manager.tenants[t.tenantID]; exists {
+		for _, address := range query.Addresses {
+			_, subscribed := tenant.subscriptions[address]
+			_, unsubscribed := tenant.unsubscribed[address]
+			if subscribed || unsubscribed {
+				owned = append(owned, address)
+			}
+		}
+	}
+	t.manager.mu.Unlock()
+	query.Addresses = owned
+	result := t.manager.parser.QueryTransactions(query)
+	for address, transactions := range result.Transactions {
+		result.Transactions[address] = withMetadata(transactions, t.metadata(address))
+	}
+	return result
+}
+
+// WaitForTransactions waits for the transactions of an address of the tenant, see EthParser.WaitForTransactions
+func (t *TenantParser) WaitForTransactions(ctx context.Context, address string, cursor int) ([]Transaction, int, error) {
+	if !t.owns(address) {
+		return nil, cursor, nil
+	}
+	transactions, next, err := t.manager.parser.WaitForTransactions(ctx, address, cursor)
+	return withMetadata(transactions, t.metadata(address)), next, err
+}
+
+// TransactionChanges returns the changes of an address of the tenant, see EthParser.TransactionChanges
+func (t *TenantParser) TransactionChanges(address string, sinceBlock int) ([]Transaction, int) {
+	if !t.owns(address) {
+		return nil, sinceBlock
+	}
+	transactions, next := t.manager.parser.TransactionChanges(address, sinceBlock)
+	return withMetadata(transactions, t.metadata(address)), next
+}
+
+// CallContract runs a read-only contract call, which isn't scoped to the tenant
+func (t *TenantParser) CallContract(call ContractCall) (ContractCallResult, error) {
+	return t.manager.parser.CallContract(call)
+}
+
+// SendTestNotification delivers a synthetic transaction through the notifications of an address subscribed
+// by the tenant
+func (t *TenantParser) SendTestNotification(address string) (Transaction, error) {
+	if !t.owns(address) {
+		return Transaction{}, ErrNotSubscribed
+	}
+	tx, err := t.manager.parser.SendTestNotification(address)
+	tx.Metadata = t.metadata(address)
+	return tx, err
+}
+
+// TokenMetadata returns the metadata of a token contract, shared by the tenants
+func (t *TenantParser) TokenMetadata(address string) (TokenMetadata, error) {
+	return t.manager.parser.TokenMetadata(address)
+}
+
+// LookupTransaction searches a transaction by hash. A transaction stored only for the addresses of
+// other tenants is looked up on the node, as if the parser didn't store it.
+func (t *TenantParser) LookupTransaction(hash string) (TransactionLookup, bool, error) {
+	lookup, found, err := t.manager.parser.LookupTransaction(hash)
+	if err != nil || !found || lookup.Source != LookupSourceStorage {
+		return lookup, found, err
+	}
+	var owned []string
+	for _, address := range lookup.Addresses {
+		if t.ownsHistory(address) {
+			owned = append(owned, address)
+		}
+	}
+	if len(owned) == 0 {
+		return t.manager.parser.lookupTransactionOnNode(hash)
+	}
+	lookup.Addresses = owned
+	return lookup, true, nil
+}
+
+// WatchTransaction starts the confirmation tracking of a transaction for the tenant, within its own quota of
+// watches
+func (t *TenantParser) WatchTransaction(request WatchRequest) (WatchedTransaction, error) {
+	return t.manager.parser.watchTransaction(t.tenantID, request)
+}
+
+// WatchedTransaction returns the watch of a hash by the tenant
+func (t *TenantParser) WatchedTransaction(hash string) (WatchedTransaction, bool) {
+	return t.manager.parser.watchedTransaction(t.tenantID, hash)
+}
+
+// TransactionsTruncated reports whether the storage evicted transactions of the address
+func (t *TenantParser) TransactionsTruncated(address string) bool {
+	return t.ownsHistory(address) && t.manager.parser.TransactionsTruncated(address)
+}
+
+// GetNonceHistory returns the nonce history of an address subscribed, now or before, by the tenant
+func (t *TenantParser) GetNonceHistory(address string) NonceHistory {
+	if !t.ownsHistory(address) {
+		return NonceHistory{Address: address, Transactions: []Transaction{}, MissingNonces: []int{}, DuplicateNonces: []int{}}
+	}
+	return t.manager.parser.GetNonceHistory(address)
+}
+
+// GetPendingTransactions returns the pending transactions of an address subscribed by the tenant
+func (t *TenantParser) GetPendingTransactions(address string) []PendingTransaction {
+	if !t.owns(address) {
+		return []PendingTransaction{}
+	}
+	return t.manager.parser.GetPendingTransactions(address)
+}
+
+// GetAllowances returns the allowances granted by an address subscribed by the tenant
+func (t *TenantParser) GetAllowances(owner string) []Allowance {
+	if !t.owns(owner) {
+		return []Allowance{}
+	}
+	return t.manager.parser.GetAllowances(owner)
+}
+
+// GetCounterparties returns the top counterparties of an address subscribed, now or before, by the tenant
+func (t *TenantParser) GetCounterparties(address string, orderBy string, limit int) []Counterparty {
+	if !t.ownsHistory(address) {
+		return []Counterparty{}
+	}
+	return t.manager.parser.GetCounterparties(address, orderBy, limit)
+}
+
+// AddToEntity links an address to a tenant entity, subscribing the address for the tenant. An address belongs
+// to at most one entity of the tenant, the entities of the other tenants don't matter. The subscription made
+// for the link is rolled back when it fails.
+func (t *TenantParser) AddToEntity(entityID string, address string) bool {
+	if t.owns(address) {
+		return t.manager.parser.addToEntity(t.tenantID, entityID, address)
+	}
+	history := t.history(address)
+	if ok, _ := t.TrySubscribe(Subscription{Address: address}); !ok {
+		return false
+	}
+	if t.manager.parser.addToEntity(t.tenantID, entityID, address) {
+		return true
+	}
+	t.removeSubscription(address, false, history)
+	return false
+}
+
+// history returns the soft-deleted subscription of the tenant to an address, nil when there's none
+func (t *TenantParser) history(address string) *Subscription {
+	t.manager.mu.Lock()
+	defer t.manager.mu.Unlock()
+	tenant, exists := t.manager.tenants[t.tenantID]
+	if !exists {
+		return nil
+	}
+	if subscription, unsubscribed := tenant.unsubscribed[address]; unsubscribed {
+		return &subscription
+	}
+	return nil
+}
+
+// RemoveFromEntity unlinks an address from a tenant entity
+func (t *TenantParser) RemoveFromEntity(entityID string, address string) bool {
+	return t.manager.parser.removeFromEntity(t.tenantID, entityID, address)
+}
+
+// GetEntityAddresses returns the member addresses of a tenant entity
+func (t *TenantParser) GetEntityAddresses(entityID string) []string {
+	return t.manager.parser.entityAddresses(t.entityKey(entityID))
+}
+
+// GetEntityTransactions returns the transactions of a tenant entity
+func (t *TenantParser) GetEntityTransactions(entityID string) []EntityTransaction {
+	return t.manager.parser.entityTransactions(t.entityKey(entityID))
+}
+
+// Reports returns the reports of the addresses and entities of the tenant, the most recent first
+func (t *TenantParser) Reports() []Report {
+	reports := []Report{}
+	for _, report := range t.manager.parser.Reports() {
+		if report, ok := t.tenantReport(report); ok {
+			reports = append(reports, report)
+		}
+	}
+	return reports
+}
+
+// GetReport returns a report of an address or entity of the tenant by ID
+func (t *TenantParser) GetReport(id int) (Report, bool) {
+	report, found := t.manager.parser.GetReport(id)
+	if !found {
+		return Report{}, false
+	}
+	return t.tenantReport(report)
+}
+
+// tenantReport returns the report, false when it isn't one of the tenant: an entity report of another
+// namespace or the report of an address the tenant never subscribed to
+func (t *TenantParser) tenantReport(report Report) (Report, bool) {
+	if report.Entity != "" {
+		return report, report.Tenant == t.tenantID
+	}
+	return report, t.ownsHistory(report.Address)
+}
+
+// RecordAudit appends an entry of the tenant to the audit log
+func (t *TenantParser) RecordAudit(entry AuditEntry) error {
+	entry.Tenant = t.tenantID
+	if entry.Actor == "" {
+		entry.Actor = t.tenantID
+	}
+	return t.manager.parser.RecordAudit(entry)
+}
+
+// AuditLog returns the audit entries of the tenant, the most recent first
+func (t *TenantParser) AuditLog(filter AuditFilter) ([]AuditEntry, error) {
+	filter.Tenant = t.tenantID
+	return t.manager.parser.AuditLog(filter)
+}
+
+// RegisterConsumer registers a consumer of the tenant, see EthParser.RegisterConsumer. Its addresses must be
+// subscribed by the tenant, without them it receives the events of all the addresses of the tenant.
+func (t *TenantParser) RegisterConsumer(consumer Consumer) (Consumer, error) {
+	consumer.Tenant = t.tenantID
+	for _, address := range consumer.Addresses {
+		if !t.manager.subscribes(t.tenantID, address) {
+			return Consumer{}, fmt.Errorf("%w: address %q isn't subscribed", ErrInvalidConsumer, address)
+		}
+	}
+	return t.manager.parser.RegisterConsumer(consumer)
+}
+
+// Consumers returns the consumers of the tenant
+func (t *TenantParser) Consumers() ([]Consumer, error) {
+	consumers, err := t.manager.parser.Consumers()
+	if err != nil {
+		return nil, err
+	}
+	owned := []Consumer{}
+	for _, consumer := range consumers {
+		if consumer.Tenant == t.tenantID {
+			owned = append(owned, consumer)
+		}
+	}
+	return owned, nil
+}
+
+// UnregisterConsumer removes a consumer of the tenant
+func (t *TenantParser) UnregisterConsumer(name string) error {
+	return t.manager.parser.UnregisterConsumer(t.consumerKey(name))
+}
+
+// PullConsumerEvents returns the unacknowledged events of a consumer of the tenant
+func (t *TenantParser) PullConsumerEvents(name string, max int) ([]OutboxEvent, error) {
+	return t.manager.parser.PullConsumerEvents(t.consumerKey(name), max)
+}
+
+// AckConsumerEvents acknowledges events of a consumer of the tenant
+func (t *TenantParser) AckConsumerEvents(name string, ids []string) error {
+	return t.manager.parser.AckConsumerEvents(t.consumerKey(name), ids)
+}
+
+// consumerKey returns the Key of a consumer of the tenant. The names with a slash, which a consumer can't
+// have, are left unknown rather than reaching the consumers of another tenant.
+func (t *TenantParser) consumerKey(name string) string {
+	if strings.Contains(name, "/") {
+		return ""
+	}
+	return consumerKey(t.tenantID, name)
+}
+
+// WaitForShutdown does nothing, the shared parser is shut down by its owner
+func (t *TenantParser) WaitForShutdown() {}
+
+// SaveTenant saves a tenant in memory
+func (s *MemoryStorage) SaveTenant(record TenantRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tenants[record.Tenant.ID] = record
+	return nil
+}
+
+// TenantRecords returns the tenants ordered by ID
+func (s *MemoryStorage) TenantRecords() ([]TenantRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	records := make([]TenantRecord, 0, len(s.tenants))
+	for _, record := range s.tenants {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Tenant.ID < records[j].Tenant.ID })
+	return records, nil
+}
+
+// DeleteTenant removes a tenant
+func (s *MemoryStorage) DeleteTenant(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tenants, id)
+	return nil
+}
+
+// SaveTenant saves a tenant, the payload holding the subscriptions is sealed with the field cipher
+func (s *SQLStorage) SaveTenant(record TenantRecord) error {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	sealed, err := sealField(s.cipher, string(payload), "tenants/"+record.Tenant.ID)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO tenants (id, payload) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET payload = excluded.payload`, record.Tenant.ID, sealed)
+	return err
+}
+
+// TenantRecords returns the tenants ordered by ID
+func (s *SQLStorage) TenantRecords() ([]TenantRecord, error) {
+	rows, err := s.db.Query(`SELECT id, payload FROM tenants ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []TenantRecord{}
+	for rows.Next() {
+		var id, payload string
+		if err := rows.Scan(&id, &payload); err != nil {
+			return nil, err
+		}
+		if payload, err = openField(s.cipher, payload, "tenants/"+id); err != nil {
+			return nil, err
+		}
+		var record TenantRecord
+		if err := json.Unmarshal([]byte(payload), &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// DeleteTenant removes a tenant
+func (s *SQLStorage) DeleteTenant(id string) error {
+	_, err := s.db.Exec(`DELETE FROM tenants WHERE id = $1`, id)
+	return err
+}
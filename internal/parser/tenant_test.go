@@ -0,0 +1,262 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{
+		Number:       "0x1",
+		Transactions: []parser.Transaction{{Hash: "0xabc", From: "0x1", To: "0x2", Value: "100"}},
+	})
+
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain),
+		func(string, []parser.Transaction) {}, parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+
+	tenants := parser.NewTenantManager(ethParser)
+	keyA, err := tenants.CreateTenant("a", "Team A", 1)
+	if err != nil {
+		t.Fatalf("Failed to create tenant a: %v", err)
+	}
+	if _, err := tenants.CreateTenant("b", "Team B", 0); err != nil {
+		t.Fatalf("Failed to create tenant b: %v", err)
+	}
+	if _, err := tenants.CreateTenant("a", "Team A", 0); err != parser.ErrTenantExists {
+		t.Fatalf("Expected ErrTenantExists, got %v", err)
+	}
+
+	tenantID, ok := tenants.TenantForAPIKey(keyA)
+	if !ok || tenantID != "a" {
+		t.Fatalf("Unexpected tenant for API key: %q %v", tenantID, ok)
+	}
+	if _, ok := tenants.TenantForAPIKey("invalid"); ok {
+		t.Fatal("Invalid API key must not resolve to a tenant")
+	}
+
+	viewA, viewB := tenants.View("a"), tenants.View("b")
+	if !viewA.SubscribeWith(parser.Subscription{Address: "0x1", Email: &parser.EmailConfig{Recipients: []string{"a@example.com"}}}) {
+		t.Fatal("Failed to subscribe tenant a")
+	}
+	if _, err := viewA.TrySubscribe(parser.Subscription{Address: "0x2"}); err != parser.ErrSubscriptionQuotaExceeded {
+		t.Fatalf("Expected ErrSubscriptionQuotaExceeded, got %v", err)
+	}
+
+	ethParser.ProcessNextCycle()
+
+	if len(viewA.GetTransactions("0x1")) != 1 {
+		t.Fatalf("Unexpected transactions for tenant a: %v", viewA.GetTransactions("0x1"))
+	}
+	if len(viewB.GetTransactions("0x1")) != 0 {
+		t.Fatal("Tenant b must not read the transactions of tenant a")
+	}
+	if _, ok := viewB.GetSubscription("0x1"); ok {
+		t.Fatal("Tenant b must not see the subscriptions of tenant a")
+	}
+
+	if !viewB.SubscribeWith(parser.Subscription{Address: "0x1"}) {
+		t.Fatal("Failed to subscribe tenant b")
+	}
+	subscriptions := tenants.SubscriptionsFor("0x1")
+	if len(subscriptions) != 2 || subscriptions[0].Tenant != "a" || subscriptions[0].Email == nil || subscriptions[1].Email != nil {
+		t.Fatalf("Unexpected subscriptions for address 0x1: %+v", subscriptions)
+	}
+
+	if !tenants.DeleteTenant("a") {
+		t.Fatal("Failed to delete tenant a")
+	}
+	if _, ok := tenants.TenantForAPIKey(keyA); ok {
+		t.Fatal("API key of a deleted tenant must be revoked")
+	}
+	if len(tenants.Tenants()) != 1 || tenants.Tenants()[0].Subscriptions != 1 {
+		t.Fatalf("Unexpected tenants: %+v", tenants.Tenants())
+	}
+}
+
+func TestTenantPersistence(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := parser.NewMemoryStorage()
+	newParser := func() *parser.EthParser {
+		return parser.NewEthParser(ctx, storage, 1, NewMockClient(NewMockBlockchain()),
+			func(string, []parser.Transaction) {}, parser.WithClock(parser.NewManualClock(time.Now())))
+	}
+	ethParser := newParser()
+	defer ethParser.WaitForShutdown()
+	tenants := parser.NewTenantManager(ethParser).WithStore(storage)
+	keyA, err := tenants.CreateTenant("a", "Team A", 2)
+	if err != nil {
+		t.Fatalf("Failed to create tenant a: %v", err)
+	}
+	keyB, _ := tenants.CreateTenant("b", "Team B", 0)
+	tenants.View("a").SubscribeWith(parser.Subscription{Address: "0x1", Priority: parser.PriorityHigh})
+	tenants.View("a").Subscribe("0x2")
+	tenants.View("a").Unsubscribe("0x2")
+	tenants.DeleteTenant("b")
+
+	// A restart keeps the tenants, their API keys and their subscriptions
+	restarted := newParser()
+	defer restarted.WaitForShutdown()
+	tenants = parser.NewTenantManager(restarted).WithStore(storage)
+	if id, ok := tenants.TenantForAPIKey(keyA); !ok || id != "a" {
+		t.Fatalf("Expected the API key of tenant a to be valid, got %q %v", id, ok)
+	}
+	if _, ok := tenants.TenantForAPIKey(keyB); ok {
+		t.Fatal("The API key of a deleted tenant must stay revoked")
+	}
+	if got := tenants.Tenants(); len(got) != 1 || got[0].Name != "Team A" || got[0].MaxSubscriptions != 2 || got[0].Subscriptions != 1 {
+		t.Fatalf("Unexpected tenants: %+v", got)
+	}
+	if subscription, ok := tenants.View("a").GetSubscription("0x1"); !ok || subscription.Priority != parser.PriorityHigh {
+		t.Fatalf("Unexpected subscription of tenant a: %+v %v", subscription, ok)
+	}
+	if _, ok := restarted.GetSubscription("0x1"); !ok {
+		t.Fatal("The shared parser must watch the addresses of the restored tenants")
+	}
+	if _, ok := restarted.GetSubscription("0x2"); ok {
+		t.Fatal("The unsubscribed addresses must not be watched")
+	}
+}
+
+func TestTenantEntities(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(NewMockBlockchain()),
+		func(string, []parser.Transaction) {}, parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+	tenants := parser.NewTenantManager(ethParser)
+	tenants.CreateTenant("a", "Team A", 0)
+	tenants.CreateTenant("b", "Team B", 0)
+	viewA, viewB := tenants.View("a"), tenants.View("b")
+
+	// The entities of the tenants don't collide, nor with the ones of the shared parser
+	if !ethParser.AddToEntity("treasury", "0x1") || !viewA.AddToEntity("treasury", "0x1") || !viewB.AddToEntity("ops", "0x1") {
+		t.Fatal("Expected every tenant to link the address to its own entity")
+	}
+	if viewA.AddToEntity("ops", "0x1") {
+		t.Fatal("An address belongs to at most one entity of a tenant")
+	}
+	if got := viewB.GetEntityAddresses("ops"); len(got) != 1 || got[0] != "0x1" {
+		t.Fatalf("Unexpected addresses of the entity of tenant b: %v", got)
+	}
+	if !viewA.RemoveFromEntity("treasury", "0x1") || len(viewB.GetEntityAddresses("ops")) != 1 {
+		t.Fatal("Removing an address from an entity must only affect the tenant")
+	}
+
+	// A failed link rolls back the subscription it made, keeping the history of the address
+	viewA.AddToEntity("payroll", "0x2")
+	if _, ok := viewA.Unsubscribe("0x2"); !ok {
+		t.Fatal("Failed to unsubscribe 0x2")
+	}
+	if viewA.AddToEntity("ops", "0x2") {
+		t.Fatal("An unsubscribed address stays in its entity")
+	}
+	if _, ok := viewA.GetSubscription("0x2"); ok {
+		t.Fatal("The subscription made for a failed link must be rolled back")
+	}
+	if got := viewA.GetEntityAddresses("payroll"); len(got) != 1 || got[0] != "0x2" {
+		t.Fatalf("Unexpected addresses of the entity of tenant a: %v", got)
+	}
+}
+
+func TestTenantEntityCollisions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{
+		{Hash: "0xa1", From: "0x1", To: "0x9", Value: "0x1"},
+		{Hash: "0xa2", From: "0x2", To: "0x9", Value: "0x2"},
+	}})
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain),
+		func(string, []parser.Transaction) {}, parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+	tenants := parser.NewTenantManager(ethParser)
+
+	// Tenant "a" with entity "b/x" and tenant "a/b" with entity "x" would name the same entity
+	if _, err := tenants.CreateTenant("a", "Team A", 0); err != nil {
+		t.Fatalf("Failed to create tenant a: %v", err)
+	}
+	if _, err := tenants.CreateTenant("a/b", "Team A/B", 0); !errors.Is(err, parser.ErrInvalidTenantID) {
+		t.Fatalf("Expected a tenant ID with a slash to be rejected, got %v", err)
+	}
+	if _, err := tenants.CreateTenant("b", "Team B", 0); err != nil {
+		t.Fatalf("Failed to create tenant b: %v", err)
+	}
+	viewA, viewB := tenants.View("a"), tenants.View("b")
+
+	// The entities are keyed by namespace and ID, the entity "a/b/x" of the shared parser isn't the entity
+	// "b/x" of tenant a, nor are the entities of the same ID of two tenants
+	if !viewA.AddToEntity("b/x", "0x1") || !ethParser.AddToEntity("a/b/x", "0x2") || !viewB.AddToEntity("b/x", "0x2") {
+		t.Fatal("Expected every namespace to link an address to its own entity")
+	}
+	ethParser.ProcessNextCycle()
+	if got := viewA.GetEntityAddresses("b/x"); len(got) != 1 || got[0] != "0x1" {
+		t.Fatalf("Unexpected addresses of the entity of tenant a: %v", got)
+	}
+	if got := ethParser.GetEntityAddresses("a/b/x"); len(got) != 1 || got[0] != "0x2" {
+		t.Fatalf("Unexpected addresses of the entity of the shared parser: %v", got)
+	}
+	if got := viewA.GetEntityTransactions("b/x"); len(got) != 1 || got[0].Hash != "0xa1" {
+		t.Fatalf("Expected only the transactions of tenant a, got %+v", got)
+	}
+	if got := viewB.GetEntityTransactions("b/x"); len(got) != 1 || got[0].Hash != "0xa2" {
+		t.Fatalf("Expected only the transactions of tenant b, got %+v", got)
+	}
+}
+
+func TestTenantReports(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{
+		{Hash: "0xa1", From: "0x1", To: "0x9", Value: "0x1"},
+		{Hash: "0xa2", From: "0x2", To: "0x9", Value: "0x2"},
+	}})
+	clock := parser.NewManualClock(time.Date(2024, 1, 1, 22, 0, 0, 0, time.UTC))
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain),
+		func(string, []parser.Transaction) {}, parser.WithClock(clock), parser.WithReports(parser.ReportDaily, 0))
+	defer ethParser.WaitForShutdown()
+	tenants := parser.NewTenantManager(ethParser)
+	tenants.CreateTenant("a", "Team A", 0)
+	if _, err := tenants.CreateTenant("a/b", "Team A/B", 0); !errors.Is(err, parser.ErrInvalidTenantID) {
+		t.Fatalf("Expected a tenant ID with a slash to be rejected, got %v", err)
+	}
+	viewA := tenants.View("a")
+
+	// A prefix match on "a/" would take the entity "a/x" of the shared parser for the entity "x" of tenant a
+	viewA.AddToEntity("x", "0x1")
+	ethParser.AddToEntity("a/x", "0x2")
+	ethParser.ProcessNextCycle()
+	clock.Advance(2 * time.Hour)
+	waitUntil(t, func() bool { return len(ethParser.Reports()) == 4 })
+
+	var entities []parser.Report
+	for _, report := range viewA.Reports() {
+		if report.Entity != "" {
+			entities = append(entities, report)
+		}
+	}
+	if len(entities) != 1 || entities[0].Entity != "x" || entities[0].Tenant != "a" || entities[0].TransactionCount != 1 {
+		t.Fatalf("Expected only the entity report of tenant a, got %+v", entities)
+	}
+	for _, report := range ethParser.Reports() {
+		if report.Entity == "a/x" && report.Tenant != "" {
+			t.Errorf("Unexpected tenant of a shared entity report: %+v", report)
+		}
+		if _, ok := viewA.GetReport(report.ID); ok && report.Entity == "a/x" {
+			t.Errorf("The shared entity report must not be one of tenant a: %+v", report)
+		}
+	}
+}
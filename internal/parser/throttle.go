@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"log"
+	"strconv"
+	"time"
+)
+
+// Events sent when the notifications of an address are throttled, respectively back to normal
+const (
+	EventNotificationsThrottled = "notifications_throttled"
+	EventNotificationsResumed   = "notifications_resumed"
+)
+
+// ThrottlePolicy bounds the notifications of an address, see WithNotificationThrottle
+type ThrottlePolicy struct {
+	// MaxNotifications is the number of notifications of an address allowed per window, zero disables the throttling
+	MaxNotifications int
+	Window           time.Duration
+}
+
+// throttleState is the notification rate of an address, only accessed under dispatchMu
+type throttleState struct {
+	windowStart time.Time
+	count       int // outbox events of the address in the current window
+	throttled   bool
+	suppressed  int           // events folded into the summary since the throttling engaged
+	summary     []Transaction // transactions of the suppressed events not delivered yet
+}
+
+// throttleEvent reports whether an outbox event exceeds the rate of its address, in which case its
+// transactions are kept for the summary delivered at the end of the window instead of being notified.
+// The first event over the limit sends an EventNotificationsThrottled event.
+func (p *EthParser) throttleEvent(event OutboxEvent) bool {
+	if p.throttlePolicy.MaxNotifications <= 0 {
+		return false
+	}
+	now := p.clock.Now()
+	state := p.throttles[event.Address]
+	if state == nil {
+		state = &throttleState{windowStart: now}
+		p.throttles[event.Address] = state
+	}
+	if !now.Before(state.windowStart.Add(p.throttlePolicy.Window)) {
+		p.endThrottleWindow(event.Address, state, now)
+	}
+	state.count++
+	if !state.throttled && state.count <= p.throttlePolicy.MaxNotifications {
+		return false
+	}
+	if !state.throttled {
+		state.throttled = true
+		log.Printf("Throttling the notifications of address %s: more than %d in %s, switching to summaries\n",
+			event.Address, p.throttlePolicy.MaxNotifications, p.throttlePolicy.Window)
+		p.emitEvent(Event{Type: EventNotificationsThrottled, Address: event.Address, Data: map[string]string{
+			"limit":  strconv.Itoa(p.throttlePolicy.MaxNotifications),
+			"window": p.throttlePolicy.Window.String(),
+			"block":  strconv.Itoa(event.BlockNumber),
+		}})
+	}
+	state.suppressed++
+	state.summary = append(state.summary, event.Transactions...)
+	return true
+}
+
+// flushThrottles ends the elapsed windows, delivering the summaries of the throttled addresses
+func (p *EthParser) flushThrottles() {
+	now := p.clock.Now()
+	for address, state := range p.throttles {
+		if now.Before(state.windowStart.Add(p.throttlePolicy.Window)) {
+			continue
+		}
+		p.endThrottleWindow(address, state, now)
+		if !state.throttled && len(state.summary) == 0 {
+			delete(p.throttles, address)
+		}
+	}
+}
+
+// endThrottleWindow delivers the summary of a throttled address at the end of a window, and lifts the
+// throttling when the window stayed within the limit. A failed summary is delivered with the next one.
+func (p *EthParser) endThrottleWindow(address string, state *throttleState, now time.Time) {
+	if len(state.summary) > 0 {
+		if err := p.deliverFor(address)(address, p.annotateTransactions(address, state.summary)); err != nil {
+			log.Printf("Error delivering the notification summary of address %s: %v\n", address, err)
+		} else {
+			state.summary = nil
+		}
+	}
+	if state.throttled && state.count <= p.throttlePolicy.MaxNotifications && len(state.summary) == 0 {
+		state.throttled = false
+		p.emitEvent(Event{Type: EventNotificationsResumed, Address: address, Data: map[string]string{
+			"suppressed": strconv.Itoa(state.suppressed),
+		}})
+		state.suppressed = 0
+	}
+	state.windowStart = now
+	state.count = 0
+}
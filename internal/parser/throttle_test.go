@@ -0,0 +1,75 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNotificationThrottle(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	for i := 1; i <= 4; i++ {
+		mockBlockchain.AddBlock(i, parser.Block{Number: fmt.Sprintf("0x%x", i), Transactions: []parser.Transaction{
+			{Hash: fmt.Sprintf("0xa%d", i), From: "0x1", To: "0x9", Value: "0x1"},
+			{Hash: fmt.Sprintf("0xb%d", i), From: "0x2", To: "0x9", Value: "0x1"},
+		}})
+	}
+	var notifications [][]parser.Transaction
+	other := 0
+	var events []parser.Event
+	clock := parser.NewManualClock(time.Now())
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain), func(address string, transactions []parser.Transaction) {
+		if address == "0x1" {
+			notifications = append(notifications, transactions)
+		} else {
+			other++
+		}
+	}, parser.WithClock(clock), parser.WithNotificationThrottle(2, time.Minute),
+		parser.WithEventNotification(func(event parser.Event) {
+			if event.Address == "0x1" {
+				events = append(events, event)
+			}
+		}))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.Subscribe("0x2")
+	ethParser.ProcessNextCycle()
+
+	if len(notifications) != 2 {
+		t.Fatalf("Expected the notifications within the limit, got %+v", notifications)
+	}
+	if len(events) != 1 || events[0].Type != parser.EventNotificationsThrottled || events[0].Address != "0x1" || events[0].Data["block"] != "3" {
+		t.Fatalf("Expected one throttling alert for 0x1, got %+v", events)
+	}
+	if other != 2 {
+		t.Errorf("Expected the other address to be throttled separately, got %d notifications", other)
+	}
+
+	// The suppressed transactions are summarized at the end of the window, the address stays throttled
+	clock.Advance(time.Minute)
+	mockBlockchain.AddBlock(5, parser.Block{Number: "0x5", Transactions: []parser.Transaction{{Hash: "0xa5", From: "0x1", To: "0x9", Value: "0x1"}}})
+	ethParser.ProcessNextCycle()
+	if len(notifications) != 3 || len(notifications[2]) != 2 || notifications[2][0].Hash != "0xa3" || notifications[2][1].Hash != "0xa4" {
+		t.Fatalf("Expected a summary of the suppressed transactions, got %+v", notifications)
+	}
+
+	// A window within the limit lifts the throttling
+	clock.Advance(time.Minute)
+	ethParser.ProcessNextCycle()
+	if len(notifications) != 4 || len(notifications[3]) != 1 || notifications[3][0].Hash != "0xa5" {
+		t.Fatalf("Expected the summary of the last window, got %+v", notifications)
+	}
+	if len(events) != 2 || events[1].Type != parser.EventNotificationsResumed || events[1].Data["suppressed"] != "3" {
+		t.Fatalf("Expected the throttling to be lifted, got %+v", events)
+	}
+	mockBlockchain.AddBlock(6, parser.Block{Number: "0x6", Transactions: []parser.Transaction{{Hash: "0xa6", From: "0x1", To: "0x9", Value: "0x1"}}})
+	ethParser.ProcessNextCycle()
+	if len(notifications) != 5 || notifications[4][0].Hash != "0xa6" {
+		t.Errorf("Expected the transactions to be notified again, got %+v", notifications)
+	}
+}
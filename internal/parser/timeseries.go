@@ -0,0 +1,116 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Metrics of a time series
+const (
+	MetricTransactionCount = "tx_count"
+	MetricVolume           = "volume"
+)
+
+// Bounds of a time series: the interval of its buckets and their number
+const (
+	MinTimeSeriesInterval = time.Minute
+	MaxTimeSeriesBuckets  = 1000
+)
+
+// ErrInvalidTimeSeries is returned by ComputeTimeSeries for an invalid metric, interval or range
+var ErrInvalidTimeSeries = errors.New("invalid time series")
+
+// TimeSeriesQuery selects the metric, the interval and the range of a time series. A zero From or To is the
+// bucket of the first or last timed transaction.
+type TimeSeriesQuery struct {
+	Metric   string
+	Interval time.Duration
+	From     time.Time
+	To       time.Time
+}
+
+// TimeSeries is the activity of an address in buckets of Interval, the empty buckets included so that it can be
+// charted as is
+type TimeSeries struct {
+	Address  string             `json:"address"`
+	Metric   string             `json:"metric"`
+	Interval string             `json:"interval"`
+	Buckets  []TimeSeriesBucket `json:"buckets"`
+	// Untimed counts the transactions left out because they were stored before the block times were recorded
+	Untimed int `json:"untimed,omitempty"`
+}
+
+// TimeSeriesBucket is the activity of an address between Start and Start plus the interval
+type TimeSeriesBucket struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+	// Volume is the native value sent and received in wei, in the NumberEncoding of the response, only set for
+	// MetricVolume; the self transfers are counted but move no value
+	Volume string `json:"volume,omitempty"`
+}
+
+// ComputeTimeSeries buckets the transactions, as stored with hex values, of address by block time
+func ComputeTimeSeries(transactions []Transaction, address string, query TimeSeriesQuery, encoding string) (TimeSeries, error) {
+	if query.Metric != MetricTransactionCount && query.Metric != MetricVolume {
+		return TimeSeries{}, fmt.Errorf("%w: the metric must be %s or %s", ErrInvalidTimeSeries, MetricTransactionCount, MetricVolume)
+	}
+	if query.Interval < MinTimeSeriesInterval {
+		return TimeSeries{}, fmt.Errorf("%w: the interval must be at least %s", ErrInvalidTimeSeries, MinTimeSeriesInterval)
+	}
+	series := TimeSeries{Address: strings.ToLower(address), Metric: query.Metric, Interval: query.Interval.String(), Buckets: []TimeSeriesBucket{}}
+
+	from, to := query.From.Truncate(query.Interval), query.To.Truncate(query.Interval)
+	var timed []Transaction
+	for _, tx := range transactions {
+		if tx.BlockTime == nil {
+			series.Untimed++
+			continue
+		}
+		timed = append(timed, tx)
+		bucket := tx.BlockTime.Truncate(query.Interval)
+		if query.From.IsZero() && (from.IsZero() || bucket.Before(from)) {
+			from = bucket
+		}
+		if query.To.IsZero() && bucket.After(to) {
+			to = bucket
+		}
+	}
+	if from.IsZero() || to.IsZero() {
+		return series, nil
+	}
+	if to.Before(from) {
+		return TimeSeries{}, fmt.Errorf("%w: the range ends before it starts", ErrInvalidTimeSeries)
+	}
+	if buckets := to.Sub(from)/query.Interval + 1; buckets > MaxTimeSeriesBuckets {
+		return TimeSeries{}, fmt.Errorf("%w: the range has %d buckets, more than %d", ErrInvalidTimeSeries, buckets, MaxTimeSeriesBuckets)
+	}
+
+	volumes := make([]*big.Int, 0, to.Sub(from)/query.Interval+1)
+	for start := from; !start.After(to); start = start.Add(query.Interval) {
+		series.Buckets = append(series.Buckets, TimeSeriesBucket{Start: start.UTC()})
+		volumes = append(volumes, new(big.Int))
+	}
+	for _, tx := range timed {
+		bucket := tx.BlockTime.Truncate(query.Interval)
+		if bucket.Before(from) || bucket.After(to) {
+			continue
+		}
+		i := int(bucket.Sub(from) / query.Interval)
+		series.Buckets[i].Count++
+		if TransactionDirection(tx, address) == DirectionSelf {
+			continue
+		}
+		if value, ok := parseQuantity(tx.Value); ok {
+			volumes[i].Add(volumes[i], value)
+		}
+	}
+	if query.Metric == MetricVolume {
+		for i := range series.Buckets {
+			series.Buckets[i].Volume = encodeQuantity(volumes[i], encoding)
+		}
+	}
+	return series, nil
+}
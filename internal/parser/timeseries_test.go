@@ -0,0 +1,60 @@
+package parser_test
+
+import (
+	"errors"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestComputeTimeSeries(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	at := func(offset time.Duration) *time.Time {
+		blockTime := start.Add(offset)
+		return &blockTime
+	}
+	transactions := []parser.Transaction{
+		{Hash: "0x1", From: "0xabc", To: "0xdef", Value: "0x64", BlockTime: at(5 * time.Minute)},
+		{Hash: "0x2", From: "0xdef", To: "0xabc", Value: "0x1f4", BlockTime: at(50 * time.Minute)},
+		{Hash: "0x3", From: "0xabc", To: "0xabc", Value: "0x3e8", BlockTime: at(3*time.Hour + time.Minute)},
+		{Hash: "0x4", From: "0xabc", To: "0x123", Value: "0x258"},
+	}
+
+	series, err := parser.ComputeTimeSeries(transactions, "0xABC", parser.TimeSeriesQuery{Metric: parser.MetricVolume, Interval: time.Hour}, parser.NumberEncodingDecimal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if series.Address != "0xabc" || series.Interval != "1h0m0s" || series.Untimed != 1 || len(series.Buckets) != 4 {
+		t.Fatalf("Expected 4 hourly buckets and 1 untimed transaction, got %+v", series)
+	}
+	expected := []parser.TimeSeriesBucket{
+		{Start: start, Count: 2, Volume: "600"},
+		{Start: start.Add(time.Hour), Volume: "0"},
+		{Start: start.Add(2 * time.Hour), Volume: "0"},
+		{Start: start.Add(3 * time.Hour), Count: 1, Volume: "0"},
+	}
+	for i, bucket := range series.Buckets {
+		if !bucket.Start.Equal(expected[i].Start) || bucket.Count != expected[i].Count || bucket.Volume != expected[i].Volume {
+			t.Errorf("Expected bucket %d to be %+v, got %+v", i, expected[i], bucket)
+		}
+	}
+
+	// The range can start before the first transaction and cut the last ones
+	series, _ = parser.ComputeTimeSeries(transactions, "0xabc", parser.TimeSeriesQuery{
+		Metric: parser.MetricTransactionCount, Interval: 30 * time.Minute, From: start.Add(-time.Hour), To: start.Add(45 * time.Minute),
+	}, parser.NumberEncodingDecimal)
+	if len(series.Buckets) != 4 || series.Buckets[2].Count != 1 || series.Buckets[3].Count != 1 || series.Buckets[0].Volume != "" {
+		t.Errorf("Expected 4 half-hour buckets, got %+v", series.Buckets)
+	}
+
+	for _, query := range []parser.TimeSeriesQuery{
+		{Metric: "gas", Interval: time.Hour},
+		{Metric: parser.MetricTransactionCount, Interval: time.Second},
+		{Metric: parser.MetricTransactionCount, Interval: time.Minute, From: start, To: start.Add(30 * 24 * time.Hour)},
+		{Metric: parser.MetricTransactionCount, Interval: time.Hour, From: start, To: start.Add(-time.Hour)},
+	} {
+		if _, err := parser.ComputeTimeSeries(transactions, "0xabc", query, parser.NumberEncodingDecimal); !errors.Is(err, parser.ErrInvalidTimeSeries) {
+			t.Errorf("Expected ErrInvalidTimeSeries for %+v, got %v", query, err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StageTokens is the name of the pipeline stage annotating the token transfers with their token metadata
+const StageTokens = "tokens"
+
+// tokenRetryPeriod is the time after which the metadata of a contract that couldn't be resolved is tried again
+const tokenRetryPeriod = 10 * time.Minute
+
+// ErrUnknownToken is returned when a contract answers none of the ERC-20 metadata methods
+var ErrUnknownToken = errors.New("unknown token")
+
+// TokenMetadata is the metadata of an ERC-20 token contract, read with eth_call. The fields of the methods
+// the contract doesn't implement are empty, e.g. the decimals of an ERC-721 collection.
+type TokenMetadata struct {
+	Address  string `json:"address"`
+	Name     string `json:"name,omitempty"`
+	Symbol   string `json:"symbol,omitempty"`
+	Decimals *int   `json:"decimals,omitempty"`
+}
+
+// tokenCacheEntry is a resolved token metadata, or the error of its resolution
+type tokenCacheEntry struct {
+	metadata   TokenMetadata
+	err        error
+	resolvedAt time.Time
+}
+
+// TokenMetadata returns the metadata of a token contract, resolved on the first request and then cached
+func (p *EthParser) TokenMetadata(address string) (TokenMetadata, error) {
+	address = strings.ToLower(address)
+	p.mu.Lock()
+	entry, ok := p.tokens[address]
+	p.mu.Unlock()
+	if ok && (entry.err == nil || p.clock.Now().Sub(entry.resolvedAt) < tokenRetryPeriod) {
+		return entry.metadata, entry.err
+	}
+
+	metadata, err := p.resolveToken(address)
+	p.mu.Lock()
+	p.tokens[address] = tokenCacheEntry{metadata: metadata, err: err, resolvedAt: p.clock.Now()}
+	p.mu.Unlock()
+	return metadata, err
+}
+
+// resolveToken reads the name, symbol and decimals of a token contract
+func (p *EthParser) resolveToken(address string) (TokenMetadata, error) {
+	metadata := TokenMetadata{Address: address}
+	var errs []string
+	name, err := p.callTokenString(address, "name")
+	if err != nil {
+		errs = append(errs, "name: "+err.Error())
+	}
+	metadata.Name = name
+	symbol, err := p.callTokenString(address, "symbol")
+	if err != nil {
+		errs = append(errs, "symbol: "+err.Error())
+	}
+	metadata.Symbol = symbol
+
+	if decimals, err := p.callToken(address, "decimals"); err != nil {
+		errs = append(errs, "decimals: "+err.Error())
+	} else if n, err := strconv.Atoi(decimals[0].(string)); err == nil {
+		metadata.Decimals = &n
+	}
+
+	if len(errs) == 3 {
+		return TokenMetadata{}, fmt.Errorf("%w %s: %s", ErrUnknownToken, address, strings.Join(errs, ", "))
+	}
+	return metadata, nil
+}
+
+// callToken calls a method without arguments of the bundled ERC-20 ABI on a token contract
+func (p *EthParser) callToken(address string, name string) ([]interface{}, error) {
+	method, _ := bundledERC20ABI.Method(name, 0)
+	raw, err := EthCall(p.client, address, method.Selector(), "latest")
+	if err != nil {
+		return nil, err
+	}
+	return method.DecodeOutputs(raw)
+}
+
+// callTokenString calls a string method of a token contract. Some early tokens, such as MKR, return a
+// bytes32 instead, which is decoded as a zero-padded string.
+func (p *EthParser) callTokenString(address string, name string) (string, error) {
+	method, _ := bundledERC20ABI.Method(name, 0)
+	raw, err := EthCall(p.client, address, method.Selector(), "latest")
+	if err != nil {
+		return "", err
+	}
+	values, err := method.DecodeOutputs(raw)
+	if err == nil {
+		return values[0].(string), nil
+	}
+	if data, decodeErr := hex.DecodeString(trimHexPrefix(raw)); decodeErr == nil && len(data) == 32 {
+		return string(bytes.TrimRight(data, "\x00")), nil
+	}
+	return "", err
+}
+
+// tokensStage annotates the matched token transfers, direct calls of transfer or transferFrom on the token
+// contract, with the metadata of the token
+func (p *EthParser) tokensStage(ctx context.Context, block *BlockContext) error {
+	if !p.resolveTokens {
+		return nil
+	}
+	for _, transactions := range block.Matches {
+		for i := range transactions {
+			tx := &transactions[i]
+			if tx.Category != CategoryTokenTransfer || !tokenTransferSelectors[inputSelector(tx.Input)] {
+				continue
+			}
+			if metadata, err := p.TokenMetadata(tx.To); err == nil {
+				tx.Token = &metadata
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// metadataClient answers the ERC-20 metadata calls of the token contracts it knows
+type metadataClient struct {
+	*MockClient
+	results map[string]string // contract + selector -> result
+	calls   int
+}
+
+func (c *metadataClient) SendRequest(req parser.JSONRPCRequest) (parser.JSONRPCResponse, error) {
+	if req.Method != "eth_call" {
+		return c.MockClient.SendRequest(req)
+	}
+	c.calls++
+	call := req.Params[0].(map[string]string)
+	if result, ok := c.results[call["to"]+call["data"]]; ok {
+		return parser.JSONRPCResponse{Result: result}, nil
+	}
+	return parser.JSONRPCResponse{}, fmt.Errorf("execution reverted")
+}
+
+// abiString returns the ABI encoding of a short string
+func abiString(s string) string {
+	return fmt.Sprintf("0x%064x%064x", 32, len(s)) + paddedHex(s)
+}
+
+// paddedHex returns the hex of a short string right-padded with zeros to a word
+func paddedHex(s string) string {
+	return fmt.Sprintf("%x", s) + strings.Repeat("0", 64-2*len(s))
+}
+
+func TestEthParserTokenMetadata(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	transfer := "0xa9059cbb" + strings.Repeat("0", 64) + strings.Repeat("0", 63) + "1"
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{
+		{Hash: "0xa1", From: "0x1", To: "0xtoken", Value: "0x0", Input: transfer},
+		{Hash: "0xa2", From: "0x1", To: "0x2", Value: "0x10", Input: "0x"},
+	}})
+	mockBlockchain.AddBlock(2, parser.Block{Number: "0x2", Transactions: []parser.Transaction{
+		{Hash: "0xa3", From: "0x1", To: "0xtoken", Value: "0x0", Input: transfer},
+	}})
+	client := &metadataClient{MockClient: NewMockClient(mockBlockchain), results: map[string]string{
+		"0xtoken0x06fdde03": abiString("USD Coin"),
+		"0xtoken0x95d89b41": abiString("USDC"),
+		"0xtoken0x313ce567": fmt.Sprintf("0x%064x", 6),
+	}}
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, client, func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())), parser.WithClassifier(parser.NewHeuristicClassifier(nil)),
+		parser.WithTokenMetadata())
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	transactions := ethParser.GetTransactions("0x1")
+	if len(transactions) != 3 {
+		t.Fatalf("Expected 3 transactions, got %+v", transactions)
+	}
+	for _, tx := range transactions {
+		if tx.Hash == "0xa2" {
+			if tx.Token != nil {
+				t.Errorf("Expected no token on an ETH transfer, got %+v", tx.Token)
+			}
+			continue
+		}
+		if tx.Token == nil || tx.Token.Symbol != "USDC" || tx.Token.Name != "USD Coin" || tx.Token.Decimals == nil || *tx.Token.Decimals != 6 {
+			t.Errorf("Expected the USDC metadata on %s, got %+v", tx.Hash, tx.Token)
+		}
+	}
+	// The metadata is resolved once per token
+	if client.calls != 3 {
+		t.Errorf("Expected 3 metadata calls, got %d", client.calls)
+	}
+}
+
+func TestTokenMetadataResolution(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// An early token returning a bytes32 symbol and no name
+	client := &metadataClient{MockClient: NewMockClient(NewMockBlockchain()), results: map[string]string{
+		"0xmkr0x95d89b41": "0x" + paddedHex("MKR"),
+		"0xmkr0x313ce567": fmt.Sprintf("0x%064x", 18),
+	}}
+	clock := parser.NewManualClock(time.Now())
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, client, func(string, []parser.Transaction) {}, parser.WithClock(clock))
+	defer ethParser.WaitForShutdown()
+
+	metadata, err := ethParser.TokenMetadata("0xMKR")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if metadata.Symbol != "MKR" || metadata.Name != "" || *metadata.Decimals != 18 {
+		t.Errorf("Expected the MKR metadata, got %+v", metadata)
+	}
+
+	// Unknown contracts are cached too, and tried again after a while
+	if _, err := ethParser.TokenMetadata("0xeoa"); !errors.Is(err, parser.ErrUnknownToken) {
+		t.Fatalf("Expected ErrUnknownToken, got %v", err)
+	}
+	calls := client.calls
+	ethParser.TokenMetadata("0xeoa")
+	if client.calls != calls {
+		t.Errorf("Expected the failed resolution to be cached")
+	}
+	clock.Advance(11 * time.Minute)
+	ethParser.TokenMetadata("0xeoa")
+	if client.calls != calls+3 {
+		t.Errorf("Expected the resolution to be tried again, got %d calls", client.calls-calls)
+	}
+}
@@ -0,0 +1,56 @@
+package parser
+
+import "strings"
+
+// Handling modes of the self-transfers and of the zero-value transactions, see WithSelfTransfers and
+// WithZeroValueTransactions
+const (
+	// TransactionsDeliver stores and notifies the transactions as the others
+	TransactionsDeliver = "deliver"
+	// TransactionsSuppress stores the transactions without notifying them
+	TransactionsSuppress = "suppress"
+	// TransactionsSkip neither stores nor notifies the transactions
+	TransactionsSkip = "skip"
+)
+
+// isSelfTransfer reports whether a transaction is sent by an address to itself
+func isSelfTransfer(tx Transaction) bool {
+	return tx.From != "" && strings.EqualFold(tx.From, tx.To)
+}
+
+// isZeroValue reports whether a transaction transfers no ether, e.g. most of the contract calls
+func isZeroValue(tx Transaction) bool {
+	return strings.TrimLeft(strings.TrimPrefix(strings.ToLower(tx.Value), "0x"), "0") == ""
+}
+
+// transactionHandling returns the handling mode of a transaction, the most restrictive one of its kinds
+func (p *EthParser) transactionHandling(tx Transaction) string {
+	var modes []string
+	if isSelfTransfer(tx) {
+		modes = append(modes, p.selfTransfers)
+	}
+	if isZeroValue(tx) {
+		modes = append(modes, p.zeroValue)
+	}
+	handling := TransactionsDeliver
+	for _, mode := range modes {
+		switch mode {
+		case TransactionsSkip:
+			return TransactionsSkip
+		case TransactionsSuppress:
+			handling = TransactionsSuppress
+		}
+	}
+	return handling
+}
+
+// notifiedTransactions returns the stored transactions to notify, without the suppressed ones
+func (p *EthParser) notifiedTransactions(transactions []Transaction) []Transaction {
+	var notified []Transaction
+	for _, tx := range transactions {
+		if p.transactionHandling(tx) != TransactionsSuppress {
+			notified = append(notified, tx)
+		}
+	}
+	return notified
+}
@@ -0,0 +1,85 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+// transfersBlockchain has a self-transfer of 0x1, a zero-value transaction and a regular one in block 1
+func transfersBlockchain() *MockBlockchain {
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{
+		Number: "0x1",
+		Transactions: []parser.Transaction{
+			{Hash: "0xself", From: "0x1", To: "0x1", Value: "0x10"},
+			{Hash: "0xzero", From: "0x1", To: "0x2", Value: "0x0"},
+			{Hash: "0xpay", From: "0x2", To: "0x1", Value: "0x20"},
+		},
+	})
+	return mockBlockchain
+}
+
+// runTransfers processes block 1 for 0x1 and returns the stored and the notified hashes
+func runTransfers(t *testing.T, opts ...parser.Option) (stored []string, notified []string) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	notify := func(address string, transactions []parser.Transaction) {
+		for _, tx := range transactions {
+			notified = append(notified, tx.Hash)
+		}
+	}
+	opts = append(opts, parser.WithClock(parser.NewManualClock(time.Now())))
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(transfersBlockchain()), notify, opts...)
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	for _, tx := range ethParser.GetTransactions("0x1") {
+		stored = append(stored, tx.Hash)
+	}
+	return stored, notified
+}
+
+func TestSelfTransferMatchedOnce(t *testing.T) {
+	stored, notified := runTransfers(t)
+	if len(stored) != 3 || len(notified) != 3 {
+		t.Fatalf("Expected the 3 transactions once, got stored %v and notified %v", stored, notified)
+	}
+}
+
+func TestSelfTransfersAndZeroValueModes(t *testing.T) {
+	stored, notified := runTransfers(t, parser.WithSelfTransfers(parser.TransactionsSkip),
+		parser.WithZeroValueTransactions(parser.TransactionsSuppress))
+	if len(stored) != 2 || stored[0] != "0xzero" || stored[1] != "0xpay" {
+		t.Errorf("Expected the self-transfer to be skipped, got %v", stored)
+	}
+	if len(notified) != 1 || notified[0] != "0xpay" {
+		t.Errorf("Expected the zero-value transaction to be suppressed, got %v", notified)
+	}
+}
+
+func TestSelfTransferMostRestrictiveMode(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{
+		Number:       "0x1",
+		Transactions: []parser.Transaction{{Hash: "0xself", From: "0x1", To: "0x1", Value: "0x0"}},
+	})
+	notified := 0
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain),
+		func(string, []parser.Transaction) { notified++ }, parser.WithClock(parser.NewManualClock(time.Now())),
+		parser.WithSelfTransfers(parser.TransactionsSuppress), parser.WithZeroValueTransactions(parser.TransactionsSkip))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	if stored := ethParser.GetTransactions("0x1"); len(stored) != 0 || notified != 0 {
+		t.Errorf("Expected the zero-value self-transfer to be skipped, got %v and %d notifications", stored, notified)
+	}
+}
@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// etherDecimals is the number of decimals of the native unit of the Ethereum networks
+const etherDecimals = 18
+
+// Units selects the computed value fields of the transactions in the API responses, see ParseUnits
+type Units struct {
+	// Wei sets valueWei, the value in wei as a decimal string
+	Wei bool
+	// Ether sets valueEth, the value in the native unit as a decimal string
+	Ether bool
+	// Token sets valueFormatted, the amount of a token transfer in the units of the token, when its decimals
+	// are known
+	Token bool
+}
+
+// ParseUnits parses a comma separated list of the units of the computed value fields: wei, eth and token, or
+// all of them. An empty list selects none.
+func ParseUnits(list string) (Units, error) {
+	var units Units
+	for _, unit := range strings.Split(list, ",") {
+		switch strings.TrimSpace(unit) {
+		case "":
+		case "wei":
+			units.Wei = true
+		case "eth":
+			units.Ether = true
+		case "token":
+			units.Token = true
+		case "all":
+			units = Units{Wei: true, Ether: true, Token: true}
+		default:
+			return Units{}, fmt.Errorf("unknown unit %q, expected wei, eth, token or all", unit)
+		}
+	}
+	return units, nil
+}
+
+// WithUnits returns the transaction with the value fields of the units, computed with integers from its hex
+// or decimal value so that no amount goes through float64. A value that isn't a number leaves the fields
+// unset.
+func (tx Transaction) WithUnits(units Units) Transaction {
+	if value, ok := parseQuantity(tx.Value); ok {
+		if units.Wei {
+			tx.ValueWei = value.String()
+		}
+		if units.Ether {
+			tx.ValueEth = FormatUnits(value, etherDecimals)
+		}
+	}
+	if units.Token && tx.Token != nil && tx.Token.Decimals != nil {
+		if amount, ok := tokenTransferAmount(tx.Input); ok {
+			tx.ValueFormatted = FormatUnits(amount, *tx.Token.Decimals)
+		}
+	}
+	return tx
+}
+
+// TransactionsWithUnits returns a copy of the transactions with the value fields of the units
+func TransactionsWithUnits(transactions []Transaction, units Units) []Transaction {
+	if transactions == nil || units == (Units{}) {
+		return transactions
+	}
+	computed := make([]Transaction, len(transactions))
+	for i, tx := range transactions {
+		computed[i] = tx.WithUnits(units)
+	}
+	return computed
+}
+
+// FormatUnits formats an amount of the smallest unit with decimals, without trailing zeros: 1500000 with 6
+// decimals is "1.5"
+func FormatUnits(amount *big.Int, decimals int) string {
+	if decimals <= 0 {
+		return amount.String()
+	}
+	digits := new(big.Int).Abs(amount).String()
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	integer, fraction := digits[:len(digits)-decimals], strings.TrimRight(digits[len(digits)-decimals:], "0")
+	sign := ""
+	if amount.Sign() < 0 {
+		sign = "-"
+	}
+	if fraction == "" {
+		return sign + integer
+	}
+	return sign + integer + "." + fraction
+}
+
+// tokenTransferAmount returns the amount of a transfer or transferFrom call, its last argument
+func tokenTransferAmount(input string) (*big.Int, bool) {
+	selector := inputSelector(input)
+	if !tokenTransferSelectors[selector] {
+		return nil, false
+	}
+	arguments := 2
+	if selector == "0x23b872dd" {
+		arguments = 3
+	}
+	data := input[len(selector):]
+	if len(data) < arguments*64 {
+		return nil, false
+	}
+	return new(big.Int).SetString(data[(arguments-1)*64:arguments*64], 16)
+}
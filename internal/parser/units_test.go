@@ -0,0 +1,53 @@
+package parser_test
+
+import (
+	"eth-parser/internal/parser"
+	"math/big"
+	"testing"
+)
+
+func TestFormatUnits(t *testing.T) {
+	for _, test := range []struct {
+		amount   string
+		decimals int
+		expected string
+	}{
+		{"0", 18, "0"},
+		{"1500000000000000000", 18, "1.5"},
+		{"1", 18, "0.000000000000000001"},
+		{"115792089237316195423570985008687907853269984665640564039457584007913129639935", 18, "115792089237316195423570985008687907853269984665640564039457.584007913129639935"},
+		{"2500000", 6, "2.5"},
+		{"-25", 1, "-2.5"},
+		{"42", 0, "42"},
+	} {
+		amount, _ := new(big.Int).SetString(test.amount, 10)
+		if formatted := parser.FormatUnits(amount, test.decimals); formatted != test.expected {
+			t.Errorf("FormatUnits(%s, %d): expected %s, got %s", test.amount, test.decimals, test.expected, formatted)
+		}
+	}
+}
+
+func TestTransactionWithUnits(t *testing.T) {
+	units, err := parser.ParseUnits("wei, eth,token")
+	if err != nil || units != (parser.Units{Wei: true, Ether: true, Token: true}) {
+		t.Fatalf("Unexpected units %+v: %v", units, err)
+	}
+	if _, err := parser.ParseUnits("gwei"); err == nil {
+		t.Error("Expected an unknown unit to be rejected")
+	}
+
+	decimals := 6
+	// transfer(0x...02, 2500000)
+	tx := parser.Transaction{
+		Value: "0x14d1120d7b160000",
+		Input: "0xa9059cbb" + "0000000000000000000000000000000000000000000000000000000000000002" +
+			"00000000000000000000000000000000000000000000000000000000002625a0",
+		Token: &parser.TokenMetadata{Symbol: "USDC", Decimals: &decimals},
+	}.WithUnits(units)
+	if tx.ValueWei != "1500000000000000000" || tx.ValueEth != "1.5" || tx.ValueFormatted != "2.5" {
+		t.Errorf("Unexpected computed values %+v", tx)
+	}
+	if tx := (parser.Transaction{Value: "0x1"}).WithUnits(parser.Units{}); tx.ValueWei != "" || tx.ValueEth != "" {
+		t.Errorf("Expected no computed value without units, got %+v", tx)
+	}
+}
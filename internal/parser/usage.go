@@ -0,0 +1,363 @@
+package parser
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EventUsageProjectionExceeded is sent once a month when the projected usage of the node provider exceeds the
+// quota of the plan
+const EventUsageProjectionExceeded = "usage_projection_exceeded"
+
+// usageDayLayout formats the days of the usage counters, in UTC like the billing periods of the providers
+const usageDayLayout = "2006-01-02"
+
+// usageMinElapsed bounds the time the usage is extrapolated from, so that the first requests of a month don't
+// project an absurd rate
+const usageMinElapsed = time.Hour
+
+// usageAlertMinElapsed is the counted time of the month before which the projection isn't alerted on, the one
+// of a few hours, e.g. of a catch-up after a downtime, being too noisy
+const usageAlertMinElapsed = 24 * time.Hour
+
+// usageRetentionDays is the number of days of counters kept in memory
+const usageRetentionDays = 62
+
+// UsagePlan is the plan of the node provider the usage is projected against, see WithUsagePlan. The providers
+// bill in units weighing the methods, e.g. the compute units of Alchemy or the credits of Infura, a request by
+// default.
+type UsagePlan struct {
+	// MonthlyQuota is the number of units included per calendar month, zero for no quota
+	MonthlyQuota int64 `json:"monthlyQuota,omitempty"`
+	// MonthlyPrice is the fixed price of the plan, in USD
+	MonthlyPrice float64 `json:"monthlyPrice,omitempty"`
+	// PricePerMillion is the price of a million units beyond the quota, of all of them without quota
+	PricePerMillion float64 `json:"pricePerMillion,omitempty"`
+	// MethodUnits are the units of a request per method, DefaultUnits for the other methods (1 when zero)
+	MethodUnits  map[string]int64 `json:"methodUnits,omitempty"`
+	DefaultUnits int64            `json:"defaultUnits,omitempty"`
+}
+
+// units returns the units of calls requests of a method
+func (plan UsagePlan) units(method string, calls int64) int64 {
+	if units, ok := plan.MethodUnits[method]; ok {
+		return units * calls
+	}
+	if plan.DefaultUnits > 0 {
+		return plan.DefaultUnits * calls
+	}
+	return calls
+}
+
+// priced reports whether the plan has a price
+func (plan UsagePlan) priced() bool {
+	return plan.MonthlyPrice > 0 || plan.PricePerMillion > 0
+}
+
+// cost returns the price of the units of a month
+func (plan UsagePlan) cost(units int64) float64 {
+	billed := units
+	if plan.MonthlyQuota > 0 {
+		billed = max(units-plan.MonthlyQuota, 0)
+	}
+	return plan.MonthlyPrice + float64(billed)*plan.PricePerMillion/1e6
+}
+
+// UsageCount is the number of requests of a method sent to the node on a day
+type UsageCount struct {
+	// Day is formatted as 2006-01-02, in UTC
+	Day    string `json:"day"`
+	Method string `json:"method"`
+	Calls  int64  `json:"calls"`
+}
+
+// UsageStore persists the counters of the node requests, so that the usage of a month survives the restarts,
+// see WithUsageStore
+type UsageStore interface {
+	// AddUsage adds the calls of a count to the counter of its day and method
+	AddUsage(count UsageCount) error
+	// Usage returns the counters of the days from since, inclusive
+	Usage(since string) ([]UsageCount, error)
+}
+
+// MethodUsage is the usage of a method in a month
+type MethodUsage struct {
+	Method         string `json:"method"`
+	Calls          int64  `json:"calls"`
+	Units          int64  `json:"units"`
+	ProjectedUnits int64  `json:"projectedUnits"`
+}
+
+// DailyUsage is the usage of a day
+type DailyUsage struct {
+	Day   string `json:"day"`
+	Calls int64  `json:"calls"`
+	Units int64  `json:"units"`
+}
+
+// UsageReport is the usage of the node provider in the current month and its projection to the end of the month
+// at the rate since Since
+type UsageReport struct {
+	// Month is formatted as 2006-01, in UTC
+	Month string `json:"month"`
+	// Since is the start of the month, or the start of the counting when it's later
+	Since          time.Time `json:"since"`
+	Calls          int64     `json:"calls"`
+	Units          int64     `json:"units"`
+	ProjectedUnits int64     `json:"projectedUnits"`
+	// Plan is the plan of WithUsagePlan, the units are then the requests without it
+	Plan *UsagePlan `json:"plan,omitempty"`
+	// Cost and ProjectedCost are the price of the units of the month, in USD, with a priced plan
+	Cost          *float64 `json:"cost,omitempty"`
+	ProjectedCost *float64 `json:"projectedCost,omitempty"`
+	// QuotaUsed is the fraction of the quota used by the projected units, ExceedsPlan is set above 1
+	QuotaUsed   float64 `json:"quotaUsed,omitempty"`
+	ExceedsPlan bool    `json:"exceedsPlan"`
+	// Methods are ordered by units, the most expensive first, and Days chronologically
+	Methods []MethodUsage `json:"methods"`
+	Days    []DailyUsage  `json:"days"`
+}
+
+// usageKey is the counter of a method on a day
+type usageKey struct {
+	day    string
+	method string
+}
+
+// usageClient is the JsonRpcClient decorator counting the node requests of the parser per method and day. A
+// request is counted before it's sent: the providers bill the requests that fail or time out too, and a crash
+// doesn't lose it. The counts not saved yet in the UsageStore are saved at the end of every fetch cycle.
+type usageClient struct {
+	client   JsonRpcClient
+	clock    Clock
+	mu       sync.Mutex
+	started  time.Time
+	counts   map[usageKey]int64
+	pending  map[usageKey]int64 // counted since the last save
+	restored bool
+}
+
+// SendRequest counts the request, then sends it to the wrapped client
+func (c *usageClient) SendRequest(req JSONRPCRequest) (JSONRPCResponse, error) {
+	key := usageKey{day: c.clock.Now().UTC().Format(usageDayLayout), method: req.Method}
+	c.mu.Lock()
+	c.counts[key]++
+	c.pending[key]++
+	c.mu.Unlock()
+	return c.client.SendRequest(req)
+}
+
+// CancelRequests aborts the in-flight requests of the wrapped client, see RequestCanceler
+func (c *usageClient) CancelRequests() {
+	if canceler, ok := c.client.(RequestCanceler); ok {
+		canceler.CancelRequests()
+	}
+}
+
+// restoreUsage adds the counters of the current month saved in the UsageStore to the ones in memory, once
+func (p *EthParser) restoreUsage() {
+	if p.usageStore == nil || p.usage.restored {
+		return
+	}
+	now := p.clock.Now().UTC()
+	counts, err := p.usageStore.Usage(time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).Format(usageDayLayout))
+	if err != nil {
+		log.Printf("Error restoring the usage of the node: %v\n", err)
+		return
+	}
+	p.usage.mu.Lock()
+	for _, count := range counts {
+		p.usage.counts[usageKey{day: count.Day, method: count.Method}] += count.Calls
+	}
+	p.usage.restored = true
+	p.usage.mu.Unlock()
+}
+
+// saveUsage saves the pending counters in the UsageStore, the ones that couldn't be saved are tried again at
+// the next cycle, and drops the counters past usageRetentionDays
+func (p *EthParser) saveUsage() {
+	oldest := p.clock.Now().UTC().AddDate(0, 0, -usageRetentionDays).Format(usageDayLayout)
+	p.usage.mu.Lock()
+	pending := p.usage.pending
+	p.usage.pending = make(map[usageKey]int64)
+	for key := range p.usage.counts {
+		if key.day < oldest {
+			delete(p.usage.counts, key)
+		}
+	}
+	p.usage.mu.Unlock()
+	if p.usageStore == nil {
+		return
+	}
+
+	var failed error
+	for key, calls := range pending {
+		if failed == nil {
+			if failed = p.usageStore.AddUsage(UsageCount{Day: key.day, Method: key.method, Calls: calls}); failed == nil {
+				continue
+			}
+		}
+		p.usage.mu.Lock()
+		p.usage.pending[key] += calls
+		p.usage.mu.Unlock()
+	}
+	if failed != nil {
+		log.Printf("Error saving the usage of the node: %v\n", failed)
+	}
+}
+
+// UsageReport returns the usage of the node provider in the current month, in the units of the plan of
+// WithUsagePlan, and projects it to the end of the month at the rate since the start of the month or of the
+// counting, whichever is later
+func (p *EthParser) UsageReport() UsageReport {
+	now := p.clock.Now().UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	monthEnd := monthStart.AddDate(0, 1, 0)
+	first := monthStart.Format(usageDayLayout)
+
+	var plan UsagePlan
+	if p.usagePlan != nil {
+		plan = *p.usagePlan
+	}
+	report := UsageReport{Month: now.Format("2006-01"), Plan: p.usagePlan, Methods: []MethodUsage{}, Days: []DailyUsage{}}
+	methods := make(map[string]*MethodUsage)
+	days := make(map[string]*DailyUsage)
+	since := p.usage.started.UTC()
+	p.usage.mu.Lock()
+	for key, calls := range p.usage.counts {
+		if key.day < first {
+			continue
+		}
+		if day, _ := time.Parse(usageDayLayout, key.day); day.Before(since) {
+			since = day
+		}
+		units := plan.units(key.method, calls)
+		if methods[key.method] == nil {
+			methods[key.method] = &MethodUsage{Method: key.method}
+		}
+		methods[key.method].Calls += calls
+		methods[key.method].Units += units
+		if days[key.day] == nil {
+			days[key.day] = &DailyUsage{Day: key.day}
+		}
+		days[key.day].Calls += calls
+		days[key.day].Units += units
+		report.Calls += calls
+		report.Units += units
+	}
+	p.usage.mu.Unlock()
+	if since.Before(monthStart) {
+		since = monthStart
+	}
+	report.Since = since
+
+	// The units so far plus the same rate for the rest of the month
+	factor := 1 + float64(monthEnd.Sub(now))/float64(max(now.Sub(since), usageMinElapsed))
+	report.ProjectedUnits = int64(float64(report.Units) * factor)
+	for _, usage := range methods {
+		usage.ProjectedUnits = int64(float64(usage.Units) * factor)
+		report.Methods = append(report.Methods, *usage)
+	}
+	sort.Slice(report.Methods, func(i, j int) bool {
+		if report.Methods[i].Units != report.Methods[j].Units {
+			return report.Methods[i].Units > report.Methods[j].Units
+		}
+		return report.Methods[i].Method < report.Methods[j].Method
+	})
+	for _, usage := range days {
+		report.Days = append(report.Days, *usage)
+	}
+	sort.Slice(report.Days, func(i, j int) bool { return report.Days[i].Day < report.Days[j].Day })
+
+	if plan.priced() {
+		cost, projected := plan.cost(report.Units), plan.cost(report.ProjectedUnits)
+		report.Cost, report.ProjectedCost = &cost, &projected
+	}
+	if plan.MonthlyQuota > 0 {
+		report.QuotaUsed = float64(report.ProjectedUnits) / float64(plan.MonthlyQuota)
+		report.ExceedsPlan = report.ProjectedUnits > plan.MonthlyQuota
+	}
+	return report
+}
+
+// checkUsagePlan sends an EventUsageProjectionExceeded event the first time in a month that the projected usage
+// exceeds the quota of the plan, at the end of every fetch cycle
+func (p *EthParser) checkUsagePlan() {
+	if p.usagePlan == nil || p.usagePlan.MonthlyQuota <= 0 {
+		return
+	}
+	report := p.UsageReport()
+	if !report.ExceedsPlan || p.usageAlerted == report.Month || p.clock.Now().Sub(report.Since) < usageAlertMinElapsed {
+		return
+	}
+	p.usageAlerted = report.Month
+	log.Printf("Projected node usage of %s exceeds the plan: %d units for a quota of %d\n",
+		report.Month, report.ProjectedUnits, p.usagePlan.MonthlyQuota)
+	data := map[string]string{
+		"month":          report.Month,
+		"units":          strconv.FormatInt(report.Units, 10),
+		"projectedUnits": strconv.FormatInt(report.ProjectedUnits, 10),
+		"quota":          strconv.FormatInt(p.usagePlan.MonthlyQuota, 10),
+	}
+	if report.ProjectedCost != nil {
+		data["projectedCost"] = strconv.FormatFloat(*report.ProjectedCost, 'f', 2, 64)
+	}
+	p.emitEvent(Event{Type: EventUsageProjectionExceeded, Data: data})
+}
+
+// AddUsage adds the calls of a count to its counter in memory
+func (s *MemoryStorage) AddUsage(count UsageCount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.usage[usageKey{day: count.Day, method: count.Method}] += count.Calls
+	return nil
+}
+
+// Usage returns the counters in memory of the days from since, by day and method
+func (s *MemoryStorage) Usage(since string) ([]UsageCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	counts := []UsageCount{}
+	for key, calls := range s.usage {
+		if key.day >= since {
+			counts = append(counts, UsageCount{Day: key.day, Method: key.method, Calls: calls})
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Day != counts[j].Day {
+			return counts[i].Day < counts[j].Day
+		}
+		return counts[i].Method < counts[j].Method
+	})
+	return counts, nil
+}
+
+// AddUsage adds the calls of a count to its counter
+func (s *SQLStorage) AddUsage(count UsageCount) error {
+	_, err := s.db.Exec(`INSERT INTO rpc_usage (day, method, calls) VALUES ($1, $2, $3)
+		ON CONFLICT (day, method) DO UPDATE SET calls = rpc_usage.calls + excluded.calls`,
+		count.Day, count.Method, count.Calls)
+	return err
+}
+
+// Usage returns the counters of the days from since, by day and method
+func (s *SQLStorage) Usage(since string) ([]UsageCount, error) {
+	rows, err := s.db.Query(`SELECT day, method, calls FROM rpc_usage WHERE day >= $1 ORDER BY day, method`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []UsageCount{}
+	for rows.Next() {
+		var count UsageCount
+		if err := rows.Scan(&count.Day, &count.Method, &count.Calls); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
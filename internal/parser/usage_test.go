@@ -0,0 +1,75 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUsageReport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Ten days into October, with the usage of the day before saved by a previous run
+	clock := parser.NewManualClock(time.Date(2026, 10, 11, 0, 0, 0, 0, time.UTC))
+	store := parser.NewMemoryStorage()
+	store.AddUsage(parser.UsageCount{Day: "2026-09-30", Method: "eth_blockNumber", Calls: 5000})
+	store.AddUsage(parser.UsageCount{Day: "2026-10-10", Method: "eth_blockNumber", Calls: 100})
+	var mu sync.Mutex
+	var events []parser.Event
+	plan := parser.UsagePlan{MonthlyQuota: 1000, MonthlyPrice: 49, PricePerMillion: 1e6, MethodUnits: map[string]int64{"eth_blockNumber": 10}}
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(NewMockBlockchain()), func(string, []parser.Transaction) {},
+		parser.WithClock(clock), parser.WithUsagePlan(plan), parser.WithUsageStore(store),
+		parser.WithEventNotification(func(event parser.Event) {
+			mu.Lock()
+			events = append(events, event)
+			mu.Unlock()
+		}))
+	defer ethParser.WaitForShutdown()
+
+	clock.Advance(24 * time.Hour)
+	ethParser.ProcessNextCycle()
+	report := ethParser.UsageReport()
+	if report.Month != "2026-10" || !report.Since.Equal(time.Date(2026, 10, 10, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Expected the usage of October since the saved day, got %+v", report)
+	}
+	if len(report.Days) < 2 || report.Days[0].Day != "2026-10-10" || report.Days[0].Calls < 100 {
+		t.Fatalf("Expected the saved day first without the one of September, got %+v", report.Days)
+	}
+	if len(report.Methods) == 0 || report.Methods[0].Method != "eth_blockNumber" || report.Methods[0].Units != 10*report.Methods[0].Calls {
+		t.Fatalf("Expected eth_blockNumber weighed with its units first, got %+v", report.Methods)
+	}
+
+	// Two days counted out of 22 days: the rest of the month is projected at the same rate
+	if want := int64(float64(report.Units) * 11); report.ProjectedUnits != want {
+		t.Errorf("Expected %d projected units, got %d", want, report.ProjectedUnits)
+	}
+	if !report.ExceedsPlan || report.ProjectedCost == nil || *report.ProjectedCost != 49+float64(report.ProjectedUnits-1000) {
+		t.Errorf("Expected the projection to exceed the plan and pay the overage, got %+v", report)
+	}
+
+	// The counts are saved at the end of the cycle
+	saved, _ := store.Usage("2026-10-12")
+	if len(saved) == 0 {
+		t.Error("Expected the counts of the day to be saved")
+	}
+
+	// The alert is sent once a month
+	ethParser.ProcessNextCycle()
+	mu.Lock()
+	defer mu.Unlock()
+	alerts := 0
+	for _, event := range events {
+		if event.Type == parser.EventUsageProjectionExceeded {
+			alerts++
+			if event.Data["month"] != "2026-10" || event.Data["quota"] != "1000" {
+				t.Errorf("Unexpected alert %+v", event)
+			}
+		}
+	}
+	if alerts != 1 {
+		t.Errorf("Expected one alert, got %d", alerts)
+	}
+}
@@ -0,0 +1,85 @@
+package parser
+
+import "context"
+
+// TransactionsSince returns the transactions of the address in the blocks processed after cursor, and
+// the next cursor: the last processed block. A negative cursor starts from the last processed block.
+func (p *EthParser) TransactionsSince(address string, cursor int) ([]Transaction, int) {
+	p.mu.Lock()
+	processed := p.lastProcessedBlock
+	p.mu.Unlock()
+	if cursor < 0 || cursor > processed {
+		cursor = processed
+	}
+
+	var transactions []Transaction
+	for _, tx := range p.GetTransactions(address) {
+		if tx.BlockNumberDecimal > cursor && tx.BlockNumberDecimal <= processed {
+			transactions = append(transactions, tx)
+		}
+	}
+	return transactions, processed
+}
+
+// TransactionChanges returns the transactions of the address discovered after the processing of sinceBlock,
+// including those of older blocks found since by a rescan, and the cursor of the next call: the last processed
+// block. The transactions stored without DiscoveredBlock count as discovered with their block. A rescanned
+// transaction is returned again until the next block is processed, the clients dedupe them by hash.
+func (p *EthParser) TransactionChanges(address string, sinceBlock int) ([]Transaction, int) {
+	p.mu.Lock()
+	processed := p.lastProcessedBlock
+	p.mu.Unlock()
+
+	var transactions []Transaction
+	for _, tx := range p.GetTransactions(address) {
+		if discoveredBlock(tx) > sinceBlock {
+			transactions = append(transactions, tx)
+		}
+	}
+	return transactions, processed
+}
+
+// discoveredBlock returns the block whose processing stored the transaction
+func discoveredBlock(tx Transaction) int {
+	if tx.DiscoveredBlock > 0 {
+		return tx.DiscoveredBlock
+	}
+	return tx.BlockNumberDecimal
+}
+
+// discoveryBlock returns the DiscoveredBlock of the transactions of a block stored now: the block, or the next
+// one to process when it was processed before
+func (p *EthParser) discoveryBlock(number int) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return max(number, p.lastProcessedBlock+1)
+}
+
+// WaitForTransactions blocks until the address has transactions after cursor or ctx is done, see
+// TransactionsSince. On ctx done it returns the transactions found so far, usually none, and ctx.Err().
+func (p *EthParser) WaitForTransactions(ctx context.Context, address string, cursor int) ([]Transaction, int, error) {
+	for {
+		// Take the channel before reading, so a cycle completing in between wakes us up
+		p.mu.Lock()
+		processed := p.processed
+		p.mu.Unlock()
+
+		transactions, next := p.TransactionsSince(address, cursor)
+		if len(transactions) > 0 {
+			return transactions, next, nil
+		}
+		cursor = next
+
+		select {
+		case <-processed:
+		case <-ctx.Done():
+			return nil, cursor, ctx.Err()
+		}
+	}
+}
+
+// notifyProcessed wakes up the WaitForTransactions calls, it's called with mu held
+func (p *EthParser) notifyProcessed() {
+	close(p.processed)
+	p.processed = make(chan struct{})
+}
@@ -0,0 +1,95 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestEthParserWaitForTransactions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{
+		Number:       "0x1",
+		Transactions: []parser.Transaction{{Hash: "0xa1", From: "0x1", To: "0x9", Value: "100"}},
+	})
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockBlockchain),
+		func(string, []parser.Transaction) {}, parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	transactions, cursor := ethParser.TransactionsSince("0x1", 0)
+	if len(transactions) != 1 || transactions[0].Hash != "0xa1" || cursor != 1 {
+		t.Fatalf("Expected 0xa1 and cursor 1, got %v and %d", transactions, cursor)
+	}
+
+	// Nothing new after the cursor: the wait times out
+	timeoutCtx, cancelTimeout := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancelTimeout()
+	if transactions, next, err := ethParser.WaitForTransactions(timeoutCtx, "0x1", cursor); !errors.Is(err, context.DeadlineExceeded) || len(transactions) != 0 || next != cursor {
+		t.Fatalf("Expected a timeout at cursor %d, got %v, %d, %v", cursor, transactions, next, err)
+	}
+
+	// A waiter is woken up by the cycle processing the new block
+	type result struct {
+		transactions []parser.Transaction
+		cursor       int
+	}
+	results := make(chan result)
+	go func() {
+		transactions, next, _ := ethParser.WaitForTransactions(ctx, "0x1", cursor)
+		results <- result{transactions, next}
+	}()
+	mockBlockchain.AddBlock(2, parser.Block{
+		Number:       "0x2",
+		Transactions: []parser.Transaction{{Hash: "0xa2", From: "0x9", To: "0x1", Value: "200"}},
+	})
+	ethParser.ProcessNextCycle()
+
+	select {
+	case r := <-results:
+		if len(r.transactions) != 1 || r.transactions[0].Hash != "0xa2" || r.cursor != 2 {
+			t.Fatalf("Expected 0xa2 and cursor 2, got %v and %d", r.transactions, r.cursor)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The waiter was not woken up by the new transaction")
+	}
+}
+
+func TestEthParserTransactionChanges(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{{Hash: "0xa1", From: "0x1", To: "0x9"}}})
+	mockBlockchain.AddBlock(2, parser.Block{Number: "0x2", Transactions: []parser.Transaction{{Hash: "0xa2", From: "0x9", To: "0x1"}}})
+	ethParser := parser.NewEthParser(ctx, parser.NewMemoryStorage(), 1, NewMockClient(mockBlockchain),
+		func(string, []parser.Transaction) {}, parser.WithClock(parser.NewManualClock(time.Now())))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+	ethParser.ProcessNextCycle()
+
+	if transactions, cursor := ethParser.TransactionChanges("0x1", 0); len(transactions) != 2 || cursor != 2 {
+		t.Fatalf("Expected the whole history and cursor 2, got %+v and %d", transactions, cursor)
+	}
+	if transactions, _ := ethParser.TransactionChanges("0x1", 1); len(transactions) != 1 || transactions[0].Hash != "0xa2" {
+		t.Fatalf("Expected the transaction of block 2, got %+v", transactions)
+	}
+
+	// A transaction of block 1 found by a rescan is a change after the cursor, the others keep their discovery
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{
+		{Hash: "0xa1", From: "0x1", To: "0x9"}, {Hash: "0xmissed", From: "0x9", To: "0x1"},
+	}})
+	if _, err := ethParser.Rescan(parser.RescanRequest{FromBlock: 1, ToBlock: 2}); err != nil {
+		t.Fatal(err)
+	}
+	transactions, cursor := ethParser.TransactionChanges("0x1", 2)
+	if len(transactions) != 1 || transactions[0].Hash != "0xmissed" || transactions[0].DiscoveredBlock != 3 || cursor != 2 {
+		t.Fatalf("Expected the rescanned transaction, got %+v and %d", transactions, cursor)
+	}
+}
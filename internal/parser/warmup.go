@@ -0,0 +1,160 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Warmer is implemented by the notifiers holding a connection, e.g. to an SMTP server or a message broker,
+// warmed up on Start with WithWarmUp so that the first notification after a restart doesn't go over a cold
+// connection
+type Warmer interface {
+	// WarmUp establishes the connection and checks that it's healthy
+	WarmUp(ctx context.Context) error
+}
+
+const (
+	// warmUpAttemptTimeout bounds an attempt of a warm up
+	warmUpAttemptTimeout = 10 * time.Second
+	// warmUpRetryDelay is the delay between the attempts of a failing warm up
+	warmUpRetryDelay = 5 * time.Second
+)
+
+// WarmUpStatus reports the warm up of a notifier
+type WarmUpStatus struct {
+	Name      string `json:"name"`
+	Ready     bool   `json:"ready"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"lastError,omitempty"`
+	// GaveUp is set when the notifier wasn't warm within the timeout of WithWarmUp, the notifications are then
+	// delivered anyway
+	GaveUp bool `json:"gaveUp,omitempty"`
+}
+
+// notifierWarmUp is the state of a warm up registered with WithWarmUp
+type notifierWarmUp struct {
+	WarmUpStatus
+	warmer      Warmer
+	timeout     time.Duration
+	startedAt   time.Time
+	nextAttempt time.Time
+}
+
+// WarmUps returns the warm ups of the notifiers, in registration order
+func (p *EthParser) WarmUps() []WarmUpStatus {
+	p.warmUpMu.Lock()
+	defer p.warmUpMu.Unlock()
+	statuses := make([]WarmUpStatus, len(p.warmUps))
+	for i, warmUp := range p.warmUps {
+		statuses[i] = warmUp.WarmUpStatus
+	}
+	return statuses
+}
+
+// WarmedUp reports whether all the notifiers are warm, or were given up. The outbox isn't delivered until
+// then, so that the notifications wait in it instead of being lost to a cold connection.
+func (p *EthParser) WarmedUp() bool {
+	p.warmUpMu.Lock()
+	defer p.warmUpMu.Unlock()
+	for _, warmUp := range p.warmUps {
+		if !warmUp.Ready && !warmUp.GaveUp {
+			return false
+		}
+	}
+	return true
+}
+
+// PendingWarmUps lists the notifiers not warm yet with their last error, empty when the parser is warmed up
+func (p *EthParser) PendingWarmUps() string {
+	var pending []string
+	for _, status := range p.WarmUps() {
+		if !status.Ready && !status.GaveUp {
+			pending = append(pending, fmt.Sprintf("%s (%d attempts: %s)", status.Name, status.Attempts, status.LastError))
+		}
+	}
+	if len(pending) == 0 {
+		return ""
+	}
+	return "warming up " + strings.Join(pending, ", ")
+}
+
+// startWarmUps starts the warm ups over on Start, a restarted parser warms up its notifiers again
+func (p *EthParser) startWarmUps() {
+	now := p.clock.Now()
+	p.warmUpMu.Lock()
+	for _, warmUp := range p.warmUps {
+		warmUp.WarmUpStatus = WarmUpStatus{Name: warmUp.Name}
+		warmUp.startedAt, warmUp.nextAttempt = now, now
+	}
+	p.warmUpMu.Unlock()
+	p.warmUp()
+}
+
+// warmUp attempts the due warm ups and reports whether all the notifiers are warmed up
+func (p *EthParser) warmUp() bool {
+	now := p.clock.Now()
+	p.warmUpMu.Lock()
+	var due []*notifierWarmUp
+	for _, warmUp := range p.warmUps {
+		if !warmUp.Ready && !warmUp.GaveUp && !now.Before(warmUp.nextAttempt) {
+			due = append(due, warmUp)
+		}
+	}
+	p.warmUpMu.Unlock()
+
+	for _, warmUp := range due {
+		ctx, cancel := context.WithTimeout(p.ctx, warmUpAttemptTimeout)
+		err := warmUp.warmer.WarmUp(ctx)
+		cancel()
+
+		p.warmUpMu.Lock()
+		warmUp.Attempts++
+		switch {
+		case err == nil:
+			warmUp.Ready, warmUp.LastError = true, ""
+			log.Printf("Notifier %s warmed up\n", warmUp.Name)
+		case warmUp.timeout > 0 && p.clock.Now().Sub(warmUp.startedAt) >= warmUp.timeout:
+			warmUp.GaveUp, warmUp.LastError = true, err.Error()
+			log.Printf("Giving up warming up notifier %s after %d attempts: %v\n", warmUp.Name, warmUp.Attempts, err)
+		default:
+			warmUp.LastError = err.Error()
+			warmUp.nextAttempt = p.clock.Now().Add(warmUpRetryDelay)
+			log.Printf("Error warming up notifier %s: %v\n", warmUp.Name, err)
+		}
+		p.warmUpMu.Unlock()
+	}
+	return p.WarmedUp()
+}
+
+// WarmUp connects to the SMTP server and checks that it answers, see Warmer. The emails are sent over a
+// connection of their own, the warm up resolves the server and fails the readiness while it's unreachable.
+func (n *EmailNotifier) WarmUp(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.config.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	host, _, err := net.SplitHostPort(n.config.Addr)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if err := client.Noop(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
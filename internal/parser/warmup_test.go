@@ -0,0 +1,106 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+// flakyWarmer fails its first warm ups
+type flakyWarmer struct {
+	failures int
+	attempts int
+}
+
+func (w *flakyWarmer) WarmUp(ctx context.Context) error {
+	w.attempts++
+	if w.attempts <= w.failures {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestEthParserWarmUp(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	blockchain := mockChain(2)
+	blockchain.AddBlock(1, parser.Block{Number: "0x1", Transactions: []parser.Transaction{{Hash: "0xa1", From: "0x1", To: "0x2"}}})
+	var notified []string
+	clock := parser.NewManualClock(time.Now())
+	warmer := &flakyWarmer{failures: 2}
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(blockchain), func(address string, transactions []parser.Transaction) {
+		notified = append(notified, transactions[0].Hash)
+	}, parser.WithClock(clock), parser.WithWarmUp("broker", warmer, time.Minute))
+	defer ethParser.WaitForShutdown()
+	ethParser.Subscribe("0x1")
+
+	// The notification waits in the outbox while the notifier is cold
+	ethParser.ProcessNextCycle()
+	if len(notified) != 0 || ethParser.WarmedUp() || ethParser.PendingWarmUps() == "" {
+		t.Fatalf("Expected the notifier to be warming up, got %v %+v", notified, ethParser.WarmUps())
+	}
+
+	// The retry isn't due yet
+	ethParser.ProcessNextCycle()
+	if warmer.attempts != 1 {
+		t.Fatalf("Expected a single attempt, got %d", warmer.attempts)
+	}
+
+	for i := 0; i < 2; i++ {
+		clock.Advance(5 * time.Second)
+		ethParser.ProcessNextCycle()
+	}
+	if !ethParser.WarmedUp() || len(notified) != 1 || notified[0] != "0xa1" {
+		t.Fatalf("Expected the held notification after the warm up, got %v %+v", notified, ethParser.WarmUps())
+	}
+	if status := ethParser.WarmUps()[0]; !status.Ready || status.Attempts != 3 || status.LastError != "" {
+		t.Errorf("Unexpected warm up status %+v", status)
+	}
+}
+
+func TestEthParserWarmUpTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := parser.NewManualClock(time.Now())
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockChain(1)), func(string, []parser.Transaction) {},
+		parser.WithClock(clock), parser.WithWarmUp("broker", &flakyWarmer{failures: 100}, 10*time.Second))
+	defer ethParser.WaitForShutdown()
+
+	clock.Advance(10 * time.Second)
+	ethParser.ProcessNextCycle()
+	if status := ethParser.WarmUps()[0]; !status.GaveUp || !ethParser.WarmedUp() {
+		t.Errorf("Expected the warm up to be given up, got %+v", status)
+	}
+}
+
+func TestEthParserRestoresSubscriptions(t *testing.T) {
+	storage := parser.NewMemoryStorage()
+	newParser := func() *parser.EthParser {
+		return parser.New(storage, 1, NewMockClient(mockChain(1)), func(string, []parser.Transaction) {},
+			parser.WithClock(parser.NewManualClock(time.Now())), parser.WithSubscriptionStore(storage))
+	}
+
+	first := newParser()
+	first.SubscribeWith(parser.Subscription{Address: "0x1", Priority: parser.PriorityHigh})
+	first.Subscribe("0x2")
+	first.Subscribe("0x3")
+	first.Unsubscribe("0x3")
+	first.UpdateSubscription(parser.Subscription{Address: "0x2", Priority: parser.PriorityLow})
+	// The subscriptions with a callback are saved too, the callback is registered again by the embedder
+	first.SubscribeWithCallback("0x4", func(string, []parser.Transaction) {})
+
+	restarted := newParser()
+	if err := restarted.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer restarted.Stop(context.Background())
+	subscriptions := restarted.Subscriptions()
+	if len(subscriptions) != 3 || subscriptions[0].Priority != parser.PriorityHigh || subscriptions[1].Priority != parser.PriorityLow ||
+		subscriptions[2].Address != "0x4" {
+		t.Errorf("Expected the saved subscriptions, got %+v", subscriptions)
+	}
+}
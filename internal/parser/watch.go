@@ -0,0 +1,271 @@
+package parser
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Statuses of a WatchedTransaction
+const (
+	// WatchStatusUnknown is the status of a transaction the node doesn't know yet, e.g. still propagating
+	WatchStatusUnknown = "unknown"
+	// WatchStatusPending is the status of a transaction in the mempool of the node
+	WatchStatusPending = "pending"
+	// WatchStatusMined is the status of a mined transaction below its confirmation target
+	WatchStatusMined = "mined"
+	// WatchStatusConfirmed is the final status of a transaction mined for its confirmation target
+	WatchStatusConfirmed = "confirmed"
+	// WatchStatusDropped is the final status of a transaction the node didn't know for DropAfter
+	WatchStatusDropped = "dropped"
+)
+
+// EventTransactionStatus is sent when the status of a watched transaction changes, or when it's mined again in
+// another block after a reorganization
+const EventTransactionStatus = "transaction_status"
+
+const (
+	// defaultWatchConfirmations is the confirmation target of the watches without one
+	defaultWatchConfirmations = 12
+	// defaultWatchDropAfter is the time a watched transaction may stay unknown to the node before it's dropped
+	defaultWatchDropAfter = 30 * time.Minute
+	// maxActiveWatches bounds the watches polled every cycle per watcher, each hash costs a node request or two
+	maxActiveWatches = 1000
+	// watchRetention is how long the final watches stay readable
+	watchRetention = 24 * time.Hour
+)
+
+// ErrTooManyWatches is returned by WatchTransaction when maxActiveWatches transactions of the watcher are
+// already polled
+var ErrTooManyWatches = errors.New("too many watched transactions")
+
+// WatchRequest requests the tracking of a transaction broadcast by another service, see WatchTransaction
+type WatchRequest struct {
+	Hash string `json:"hash"`
+	// Confirmations is the number of blocks, its own included, after which the transaction is confirmed,
+	// 12 by default
+	Confirmations int `json:"confirmations,omitempty"`
+	// DropAfter is how long the transaction may stay unknown to the node before it's dropped, a Go duration
+	// such as "30m", the default
+	DropAfter string `json:"dropAfter,omitempty"`
+}
+
+// WatchedTransaction is the status of a watched transaction
+type WatchedTransaction struct {
+	Hash   string `json:"hash"`
+	Status string `json:"status"`
+	// From is the sender, once the node knows the transaction
+	From          string              `json:"from,omitempty"`
+	BlockNumber   int                 `json:"blockNumber,omitempty"`
+	BlockHash     string              `json:"blockHash,omitempty"`
+	Confirmations int                 `json:"confirmations"`
+	Target        int                 `json:"target"`
+	Receipt       *TransactionReceipt `json:"receipt,omitempty"`
+	WatchedAt     time.Time           `json:"watchedAt"`
+	UpdatedAt     time.Time           `json:"updatedAt"`
+
+	dropAfter time.Duration
+	lastSeen  time.Time // last time the node knew the transaction, or the watch time
+	namespace string    // tenant ID of the watcher, empty for the shared parser
+}
+
+// watchKey identifies a watch: the hash in the namespace of its watcher, the tenant ID or empty for the
+// shared parser, so that a tenant neither reads the watches of the others nor uses up their quota
+type watchKey struct {
+	namespace string
+	hash      string
+}
+
+// final reports whether the watch is no longer polled
+func (w *WatchedTransaction) final() bool {
+	return w.Status == WatchStatusConfirmed || w.Status == WatchStatusDropped
+}
+
+// watchReceipt is a receipt with the block of the transaction
+type watchReceipt struct {
+	TransactionReceipt
+	From        string `json:"from"`
+	BlockNumber string `json:"blockNumber"`
+	BlockHash   string `json:"blockHash"`
+}
+
+// WatchTransaction starts polling a transaction by hash until it's mined for its confirmation target or
+// dropped, sending an EventTransactionStatus event on each status change. Watching a hash again returns the
+// current watch.
+func (p *EthParser) WatchTransaction(request WatchRequest) (WatchedTransaction, error) {
+	return p.watchTransaction("", request)
+}
+
+// watchTransaction starts polling a transaction for the watcher of a namespace, the hashes watched in
+// several namespaces are polled once
+func (p *EthParser) watchTransaction(namespace string, request WatchRequest) (WatchedTransaction, error) {
+	target := request.Confirmations
+	if target <= 0 {
+		target = defaultWatchConfirmations
+	}
+	dropAfter := defaultWatchDropAfter
+	if request.DropAfter != "" {
+		var err error
+		if dropAfter, err = time.ParseDuration(request.DropAfter); err != nil || dropAfter <= 0 {
+			return WatchedTransaction{}, errors.New("invalid dropAfter duration")
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := watchKey{namespace, request.Hash}
+	if watch, exists := p.watches[key]; exists {
+		return *watch, nil
+	}
+	active := 0
+	for key, watch := range p.watches {
+		if key.namespace == namespace && !watch.final() {
+			active++
+		}
+	}
+	if active >= maxActiveWatches {
+		return WatchedTransaction{}, ErrTooManyWatches
+	}
+	now := p.clock.Now()
+	watch := &WatchedTransaction{
+		Hash:      request.Hash,
+		Status:    WatchStatusUnknown,
+		Target:    target,
+		WatchedAt: now,
+		UpdatedAt: now,
+		dropAfter: dropAfter,
+		lastSeen:  now,
+		namespace: namespace,
+	}
+	p.watches[key] = watch
+	return *watch, nil
+}
+
+// WatchedTransaction returns the watch of a hash, found is false when it isn't watched or was forgotten
+// watchRetention after its final status
+func (p *EthParser) WatchedTransaction(hash string) (WatchedTransaction, bool) {
+	return p.watchedTransaction("", hash)
+}
+
+// watchedTransaction returns the watch of a hash by the watcher of a namespace
+func (p *EthParser) watchedTransaction(namespace string, hash string) (WatchedTransaction, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	watch, found := p.watches[watchKey{namespace, hash}]
+	if !found {
+		return WatchedTransaction{}, false
+	}
+	return *watch, true
+}
+
+// pollWatchedTransactions updates the watched transactions from the node, once per cycle
+func (p *EthParser) pollWatchedTransactions() {
+	p.mu.Lock()
+	now := p.clock.Now()
+	head := p.currentBlock
+	polled := make(map[string]bool)
+	var hashes []string
+	for key, watch := range p.watches {
+		if !watch.final() {
+			if !polled[key.hash] {
+				polled[key.hash] = true
+				hashes = append(hashes, key.hash)
+			}
+		} else if now.Sub(watch.UpdatedAt) > watchRetention {
+			delete(p.watches, key)
+		}
+	}
+	p.mu.Unlock()
+	sort.Strings(hashes)
+
+	var events []Event
+	for _, hash := range hashes {
+		var receipt watchReceipt
+		mined, err := p.callResult("eth_getTransactionReceipt", hash, &receipt)
+		if err != nil {
+			log.Printf("Error polling the receipt of watched transaction %s: %v\n", hash, err)
+			continue
+		}
+		var tx Transaction
+		known := mined
+		if !mined {
+			if known, err = p.callResult("eth_getTransactionByHash", hash, &tx); err != nil {
+				log.Printf("Error polling watched transaction %s: %v\n", hash, err)
+				continue
+			}
+		}
+		block := 0
+		if mined {
+			if block, err = convertHexNumberToDecimal(receipt.BlockNumber); err != nil {
+				log.Printf("Invalid block number of the receipt of watched transaction %s: %v\n", hash, err)
+				continue
+			}
+		}
+
+		// Every namespace watching the hash gets the update, each against its own target and drop time
+		p.mu.Lock()
+		for key, watch := range p.watches {
+			if key.hash != hash || watch.final() {
+				continue
+			}
+			previousStatus, previousBlock := watch.Status, watch.BlockHash
+			switch {
+			case mined:
+				watch.BlockNumber, watch.BlockHash, watch.lastSeen = block, receipt.BlockHash, now
+				watch.Receipt = &receipt.TransactionReceipt
+				if watch.From == "" {
+					watch.From = receipt.From
+				}
+				watch.Confirmations = max(0, head-block+1)
+				watch.Status = WatchStatusMined
+				if watch.Confirmations >= watch.Target {
+					watch.Status = WatchStatusConfirmed
+				}
+			case known:
+				// Pending, or back in the mempool after the reorganization of its block
+				watch.BlockNumber, watch.BlockHash, watch.Receipt, watch.Confirmations = 0, "", nil, 0
+				watch.Status, watch.lastSeen = WatchStatusPending, now
+				if watch.From == "" {
+					watch.From = tx.From
+				}
+			default:
+				watch.BlockNumber, watch.BlockHash, watch.Receipt, watch.Confirmations = 0, "", nil, 0
+				watch.Status = WatchStatusUnknown
+				if now.Sub(watch.lastSeen) >= watch.dropAfter {
+					watch.Status = WatchStatusDropped
+				}
+			}
+			if watch.Status != previousStatus || (previousBlock != "" && watch.BlockHash != "" && watch.BlockHash != previousBlock) {
+				watch.UpdatedAt = now
+				events = append(events, watchEvent(*watch))
+			}
+		}
+		p.mu.Unlock()
+	}
+	for _, event := range events {
+		p.emitEvent(event)
+	}
+}
+
+// watchEvent returns the EventTransactionStatus event of a watch
+func watchEvent(watch WatchedTransaction) Event {
+	data := map[string]string{
+		"hash":          watch.Hash,
+		"status":        watch.Status,
+		"confirmations": strconv.Itoa(watch.Confirmations),
+		"target":        strconv.Itoa(watch.Target),
+	}
+	if watch.BlockHash != "" {
+		data["blockNumber"] = strconv.Itoa(watch.BlockNumber)
+		data["blockHash"] = watch.BlockHash
+	}
+	if watch.Receipt != nil {
+		data["receiptStatus"] = watch.Receipt.Status
+	}
+	if watch.namespace != "" {
+		data["tenant"] = watch.namespace
+	}
+	return Event{Type: EventTransactionStatus, Address: watch.From, Data: data}
+}
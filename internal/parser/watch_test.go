@@ -0,0 +1,67 @@
+package parser_test
+
+import (
+	"context"
+	"eth-parser/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestEthParserWatchTransactionConfirmations(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	blockchain := mockChain(2)
+	var events []parser.Event
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(blockchain), func(string, []parser.Transaction) {},
+		parser.WithClock(parser.NewManualClock(time.Now())),
+		parser.WithEventNotification(func(event parser.Event) { events = append(events, event) }))
+	defer ethParser.WaitForShutdown()
+
+	watch, err := ethParser.WatchTransaction(parser.WatchRequest{Hash: "0xabc", Confirmations: 2})
+	if err != nil || watch.Status != parser.WatchStatusUnknown || watch.Target != 2 {
+		t.Fatalf("Unexpected watch %+v: %v", watch, err)
+	}
+	ethParser.ProcessNextCycle()
+	if len(events) != 0 {
+		t.Fatalf("Expected no event while the transaction is unknown, got %+v", events)
+	}
+
+	blockchain.AddBlock(3, parser.Block{Number: "0x3", Transactions: []parser.Transaction{{Hash: "0xabc", From: "0x1"}}})
+	ethParser.ProcessNextCycle()
+	blockchain.AddBlock(4, parser.Block{Number: "0x4"})
+	ethParser.ProcessNextCycle()
+	ethParser.ProcessNextCycle()
+
+	if len(events) != 2 || events[0].Data["status"] != parser.WatchStatusMined || events[0].Data["confirmations"] != "1" ||
+		events[1].Data["status"] != parser.WatchStatusConfirmed || events[1].Address != "0x1" {
+		t.Fatalf("Expected the mined then confirmed events, got %+v", events)
+	}
+	if watch, _ := ethParser.WatchedTransaction("0xabc"); watch.Status != parser.WatchStatusConfirmed || watch.BlockNumber != 3 ||
+		watch.Receipt == nil || watch.Receipt.Status != "0x1" {
+		t.Errorf("Unexpected confirmed watch %+v", watch)
+	}
+}
+
+func TestEthParserWatchTransactionDropped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := parser.NewManualClock(time.Now())
+	var events []parser.Event
+	ethParser := parser.NewEthParser(ctx, NewMockStorage(), 1, NewMockClient(mockChain(1)), func(string, []parser.Transaction) {},
+		parser.WithClock(clock), parser.WithEventNotification(func(event parser.Event) { events = append(events, event) }))
+	defer ethParser.WaitForShutdown()
+
+	if _, err := ethParser.WatchTransaction(parser.WatchRequest{Hash: "0xabc", DropAfter: "10m"}); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(10 * time.Minute)
+	ethParser.ProcessNextCycle()
+	if len(events) != 1 || events[0].Data["status"] != parser.WatchStatusDropped {
+		t.Fatalf("Expected the dropped event, got %+v", events)
+	}
+	if _, err := ethParser.WatchTransaction(parser.WatchRequest{Hash: "0xdef", DropAfter: "soon"}); err == nil {
+		t.Error("Expected an invalid dropAfter to be rejected")
+	}
+}
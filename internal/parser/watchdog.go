@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EventLoopStuck is sent when the watchdog restarts a background loop that didn't progress within its deadline
+const EventLoopStuck = "loop_stuck"
+
+// Names of the background loops supervised by the watchdog
+const (
+	LoopHead  = "head"
+	LoopFetch = "fetch"
+)
+
+// RequestCanceler is implemented by the JsonRpcClients whose in-flight requests can be interrupted, so that
+// a loop blocked on a request without timeout can return when the watchdog restarts it
+type RequestCanceler interface {
+	CancelRequests()
+}
+
+// LoopStats reports the supervision of a background loop
+type LoopStats struct {
+	Name     string `json:"name"`
+	Restarts int    `json:"restarts"`
+	// BusySince is the start of the running iteration, zero while the loop is waiting for work
+	BusySince time.Time `json:"busySince,omitempty"`
+}
+
+// loopHeartbeat is the progress of one run of a loop, a restarted loop gets a new one
+type loopHeartbeat struct {
+	mu        sync.Mutex
+	busySince time.Time
+}
+
+// busy marks the start of an iteration
+func (h *loopHeartbeat) busy(now time.Time) {
+	h.mu.Lock()
+	h.busySince = now
+	h.mu.Unlock()
+}
+
+// idle marks the loop as waiting for work
+func (h *loopHeartbeat) idle() {
+	h.mu.Lock()
+	h.busySince = time.Time{}
+	h.mu.Unlock()
+}
+
+// since returns the start of the running iteration, zero when idle
+func (h *loopHeartbeat) since() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.busySince
+}
+
+// loopBody runs a background loop until ctx is done, reporting its iterations to the heartbeat
+type loopBody func(ctx context.Context, heartbeat *loopHeartbeat)
+
+// supervisedLoop is a background loop and its current run
+type supervisedLoop struct {
+	// newBody creates the tickers of the loop and returns its body, so that a restart gets fresh tickers
+	newBody   func() loopBody
+	heartbeat *loopHeartbeat
+	cancel    context.CancelFunc
+	restarts  int
+}
+
+// startLoop runs a loop under ctx, replacing its previous run if any
+func (p *EthParser) startLoop(ctx context.Context, name string, newBody func() loopBody) {
+	body := newBody()
+	loopCtx, cancel := context.WithCancel(ctx)
+	heartbeat := &loopHeartbeat{}
+
+	p.loopsMu.Lock()
+	loop, ok := p.loops[name]
+	if !ok {
+		loop = &supervisedLoop{newBody: newBody}
+		p.loops[name] = loop
+	}
+	loop.heartbeat = heartbeat
+	loop.cancel = cancel
+	p.loopsMu.Unlock()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		body(loopCtx, heartbeat)
+	}()
+}
+
+// runWatchdog restarts the loops busy for longer than the watchdog deadline, until ctx is done
+func (p *EthParser) runWatchdog(ctx context.Context, ticker Ticker) {
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			p.checkLoops(ctx)
+		case <-ctx.Done():
+			log.Println("Stopping runWatchdog")
+			return
+		}
+	}
+}
+
+// checkLoops restarts the stuck loops
+func (p *EthParser) checkLoops(ctx context.Context) {
+	now := p.clock.Now()
+	stuck := make(map[string]time.Duration)
+	p.loopsMu.Lock()
+	for name, loop := range p.loops {
+		if since := loop.heartbeat.since(); !since.IsZero() && now.Sub(since) >= p.watchdogDeadline {
+			stuck[name] = now.Sub(since)
+		}
+	}
+	p.loopsMu.Unlock()
+
+	for name, busy := range stuck {
+		if ctx.Err() != nil {
+			return
+		}
+		p.restartLoop(ctx, name, busy)
+	}
+}
+
+// restartLoop cancels the run of a stuck loop, interrupts the in-flight node requests and starts the loop
+// again. The stuck run exits as soon as its blocking call returns.
+func (p *EthParser) restartLoop(ctx context.Context, name string, busy time.Duration) {
+	log.Printf("Background loop %s made no progress for %s, restarting it\n", name, busy)
+	p.loopsMu.Lock()
+	loop := p.loops[name]
+	loop.cancel()
+	loop.restarts++
+	restarts := loop.restarts
+	p.loopsMu.Unlock()
+
+	for _, client := range []JsonRpcClient{p.client, p.fallbackClient} {
+		if canceler, ok := client.(RequestCanceler); ok {
+			canceler.CancelRequests()
+		}
+	}
+	p.startLoop(ctx, name, loop.newBody)
+	p.emitEvent(Event{Type: EventLoopStuck, Data: map[string]string{
+		"loop":     name,
+		"stuckFor": busy.String(),
+		"restarts": strconv.Itoa(restarts),
+	}})
+}
+
+// WatchdogStats returns the supervision of the background loops ordered by name, empty before Start
+func (p *EthParser) WatchdogStats() []LoopStats {
+	p.loopsMu.Lock()
+	defer p.loopsMu.Unlock()
+	stats := make([]LoopStats, 0, len(p.loops))
+	for name, loop := range p.loops {
+		stats = append(stats, LoopStats{Name: name, Restarts: loop.restarts, BusySince: loop.heartbeat.since()})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
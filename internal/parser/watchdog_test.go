@@ -0,0 +1,83 @@
+package parser_test
+
+import (
+	"context"
+	"errors"
+	"eth-parser/internal/parser"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hangingClient blocks the first block request until its requests are canceled
+type hangingClient struct {
+	*MockClient
+	entered  chan struct{}
+	canceled chan struct{}
+	once     sync.Once
+	hung     bool
+	mu       sync.Mutex
+}
+
+func (c *hangingClient) SendRequest(req parser.JSONRPCRequest) (parser.JSONRPCResponse, error) {
+	c.mu.Lock()
+	hang := req.Method == "eth_getBlockByNumber" && !c.hung
+	c.hung = c.hung || hang
+	c.mu.Unlock()
+	if hang {
+		close(c.entered)
+		<-c.canceled
+		return parser.JSONRPCResponse{}, errors.New("request canceled")
+	}
+	return c.MockClient.SendRequest(req)
+}
+
+func (c *hangingClient) CancelRequests() {
+	c.once.Do(func() { close(c.canceled) })
+}
+
+func TestEthParserWatchdog(t *testing.T) {
+	mockBlockchain := NewMockBlockchain()
+	mockBlockchain.AddBlock(1, parser.Block{Number: "0x1"})
+	client := &hangingClient{MockClient: NewMockClient(mockBlockchain), entered: make(chan struct{}), canceled: make(chan struct{})}
+	clock := parser.NewManualClock(time.Now())
+	events := make(chan parser.Event, 10)
+	ethParser := parser.New(NewMockStorage(), 1, client, func(string, []parser.Transaction) {},
+		parser.WithClock(clock), parser.WithWatchdog(time.Minute),
+		parser.WithEventNotification(func(event parser.Event) { events <- event }))
+	if err := ethParser.Start(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The first fetch cycle hangs on the block request
+	clock.Advance(time.Second)
+	<-client.entered
+	clock.Advance(30 * time.Second)
+	clock.Advance(30 * time.Second)
+	clock.Advance(30 * time.Second)
+
+	select {
+	case event := <-events:
+		if event.Type != parser.EventLoopStuck || event.Data["loop"] != parser.LoopFetch {
+			t.Fatalf("Expected a stuck fetch loop event, got %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the watchdog to restart the stuck loop")
+	}
+	select {
+	case <-client.canceled:
+	default:
+		t.Error("Expected the in-flight requests to be canceled")
+	}
+	stats := ethParser.WatchdogStats()
+	if len(stats) != 2 || stats[0].Name != parser.LoopFetch || stats[0].Restarts != 1 || stats[1].Restarts != 0 {
+		t.Errorf("Expected one restart of the fetch loop, got %+v", stats)
+	}
+
+	// The stuck run returned, so the parser stops
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := ethParser.Stop(ctx); err != nil {
+		t.Errorf("Expected the restarted parser to stop, got %v", err)
+	}
+}
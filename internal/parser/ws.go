@@ -0,0 +1,332 @@
+package parser
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// websocketGUID is the key suffix defined by RFC 6455 to compute Sec-WebSocket-Accept
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	// DefaultWSMaxMessageSize caps the size of the messages read from a WebSocket node, see WithMaxMessageSize
+	DefaultWSMaxMessageSize = 16 << 20
+	// wsCloseMessageTooBig is the close status of RFC 6455 for a message too big to process
+	wsCloseMessageTooBig = 1009
+)
+
+// ErrWSMessageTooBig is returned when a WebSocket frame or message exceeds the maximum message size
+var ErrWSMessageTooBig = errors.New("websocket message too big")
+
+// WSHeadSubscriber implements HeadSubscriber with eth_subscribe("newHeads") over a WebSocket endpoint
+type WSHeadSubscriber struct {
+	url            string
+	dialer         *egressDialer
+	maxMessageSize int64
+}
+
+// NewWSHeadSubscriber creates a HeadSubscriber for the given ws:// or wss:// node URL
+func NewWSHeadSubscriber(url string) *WSHeadSubscriber {
+	return &WSHeadSubscriber{url: url, dialer: &egressDialer{dialer: &net.Dialer{}}, maxMessageSize: DefaultWSMaxMessageSize}
+}
+
+// NewWSHeadSubscriberWithEgress creates a HeadSubscriber for the given node URL connecting through the egress
+func NewWSHeadSubscriberWithEgress(url string, egress EgressConfig) (*WSHeadSubscriber, error) {
+	dialer, err := newEgressDialer(egress)
+	if err != nil {
+		return nil, err
+	}
+	return &WSHeadSubscriber{url: url, dialer: dialer, maxMessageSize: DefaultWSMaxMessageSize}, nil
+}
+
+// WithMaxMessageSize caps the size of the frames and messages read from the node, DefaultWSMaxMessageSize by
+// default. The connection is closed with status 1009 when a message exceeds it.
+func (s *WSHeadSubscriber) WithMaxMessageSize(size int64) *WSHeadSubscriber {
+	s.maxMessageSize = size
+	return s
+}
+
+// SubscribeNewHeads opens a WebSocket connection and streams the number of every new head.
+// The channel is closed when ctx is canceled or the connection fails.
+func (s *WSHeadSubscriber) SubscribeNewHeads(ctx context.Context) (<-chan int, error) {
+	conn, err := dialWebSocket(ctx, s.dialer, s.url)
+	if err != nil {
+		return nil, err
+	}
+	conn.maxMessageSize = s.maxMessageSize
+
+	req, err := json.Marshal(JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "eth_subscribe",
+		Params:  []interface{}{"newHeads"},
+		ID:      1,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.WriteText(req); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	heads := make(chan int)
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	go func() {
+		defer close(heads)
+		defer conn.Close()
+		for {
+			msg, err := conn.ReadMessage()
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Println("Error reading new heads:", err)
+				}
+				return
+			}
+
+			var notification struct {
+				Method string `json:"method"`
+				Params struct {
+					Result struct {
+						Number string `json:"number"`
+					} `json:"result"`
+				} `json:"params"`
+				Error interface{} `json:"error"`
+			}
+			if err := json.Unmarshal(msg, &notification); err != nil {
+				log.Println("Error decoding new head:", err)
+				continue
+			}
+			if notification.Error != nil {
+				log.Printf("eth_subscribe error: %v\n", notification.Error)
+				return
+			}
+			if notification.Method != "eth_subscription" {
+				continue
+			}
+
+			number, err := convertHexNumberToDecimal(notification.Params.Result.Number)
+			if err != nil {
+				continue
+			}
+			select {
+			case heads <- number:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return heads, nil
+}
+
+// wsConn is a minimal RFC 6455 client connection supporting text messages
+type wsConn struct {
+	conn           net.Conn
+	br             *bufio.Reader
+	maxMessageSize int64 // zero for no limit
+}
+
+// dialWebSocket opens a client WebSocket connection to a ws:// or wss:// URL
+func dialWebSocket(ctx context.Context, dialer *egressDialer, rawURL string) (*wsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		switch u.Scheme {
+		case "ws":
+			host = net.JoinHostPort(u.Hostname(), "80")
+		case "wss":
+			host = net.JoinHostPort(u.Hostname(), "443")
+		}
+	}
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return nil, fmt.Errorf("unsupported websocket scheme %q", u.Scheme)
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "wss" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL:    u,
+		Host:   u.Host,
+		Header: http.Header{
+			"Upgrade":               {"websocket"},
+			"Connection":            {"Upgrade"},
+			"Sec-Websocket-Key":     {key},
+			"Sec-Websocket-Version": {"13"},
+		},
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("websocket handshake failed: %s", resp.Status)
+	}
+
+	accept := sha1.Sum([]byte(key + websocketGUID))
+	if resp.Header.Get("Sec-Websocket-Accept") != base64.StdEncoding.EncodeToString(accept[:]) ||
+		!strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") {
+		conn.Close()
+		return nil, errors.New("websocket handshake failed: invalid accept header")
+	}
+
+	return &wsConn{conn: conn, br: br}, nil
+}
+
+// WriteText sends a masked text frame
+func (c *wsConn) WriteText(payload []byte) error {
+	return c.writeFrame(0x1, payload)
+}
+
+// ReadMessage returns the next text or binary message, answering pings along the way. A frame or message
+// larger than the maximum size isn't read: the connection is closed with status 1009 and ErrWSMessageTooBig
+// is returned.
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	var message []byte
+	for {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(c.br, header); err != nil {
+			return nil, err
+		}
+		fin := header[0]&0x80 != 0
+		opcode := header[0] & 0x0f
+		masked := header[1]&0x80 != 0
+		length := uint64(header[1] & 0x7f)
+
+		switch length {
+		case 126:
+			ext := make([]byte, 2)
+			if _, err := io.ReadFull(c.br, ext); err != nil {
+				return nil, err
+			}
+			length = uint64(binary.BigEndian.Uint16(ext))
+		case 127:
+			ext := make([]byte, 8)
+			if _, err := io.ReadFull(c.br, ext); err != nil {
+				return nil, err
+			}
+			length = binary.BigEndian.Uint64(ext)
+		}
+
+		if c.maxMessageSize > 0 && length > uint64(c.maxMessageSize)-uint64(len(message)) {
+			c.writeFrame(0x8, binary.BigEndian.AppendUint16(nil, wsCloseMessageTooBig))
+			c.Close()
+			return nil, fmt.Errorf("%w: more than %d bytes", ErrWSMessageTooBig, c.maxMessageSize)
+		}
+
+		var mask []byte
+		if masked {
+			mask = make([]byte, 4)
+			if _, err := io.ReadFull(c.br, mask); err != nil {
+				return nil, err
+			}
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(c.br, payload); err != nil {
+			return nil, err
+		}
+		for i := range mask {
+			for j := i; j < len(payload); j += 4 {
+				payload[j] ^= mask[i]
+			}
+		}
+
+		switch opcode {
+		case 0x8: // close
+			return nil, io.EOF
+		case 0x9: // ping
+			if err := c.writeFrame(0xA, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case 0xA: // pong
+			continue
+		}
+
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// Close closes the underlying connection
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
+
+// writeFrame writes a single masked frame, as required for clients
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length < 126:
+		frame = append(frame, 0x80|byte(length))
+	case length <= 0xffff:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+
+	mask := make([]byte, 4)
+	if _, err := rand.Read(mask); err != nil {
+		return err
+	}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	_, err := c.conn.Write(frame)
+	return err
+}
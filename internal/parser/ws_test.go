@@ -0,0 +1,97 @@
+package parser_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"eth-parser/internal/parser"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWSHeadSubscriberMessageTooBig(t *testing.T) {
+	closeStatus := make(chan uint16, 1)
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		accept := sha1.Sum([]byte(r.Header.Get("Sec-Websocket-Key") + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(accept[:]) + "\r\n\r\n")
+		// A frame announcing 1TB, which must not be allocated
+		rw.Write(binary.BigEndian.AppendUint64([]byte{0x81, 127}, 1<<40))
+		rw.Flush()
+
+		for {
+			opcode, payload, err := readClientFrame(rw.Reader)
+			if err != nil {
+				return
+			}
+			if opcode == 0x8 && len(payload) >= 2 {
+				closeStatus <- binary.BigEndian.Uint16(payload)
+				return
+			}
+		}
+	}))
+	defer node.Close()
+
+	subscriber := parser.NewWSHeadSubscriber("ws" + strings.TrimPrefix(node.URL, "http")).WithMaxMessageSize(1024)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	heads, err := subscriber.SubscribeNewHeads(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	select {
+	case status := <-closeStatus:
+		if status != 1009 {
+			t.Errorf("Expected the close status 1009, got %d", status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the connection to be closed")
+	}
+	if _, open := <-heads; open {
+		t.Error("Expected the heads to end with the connection")
+	}
+}
+
+// readClientFrame reads a masked frame of the client
+func readClientFrame(r *bufio.Reader) (byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(r, ext); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(r, ext); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext)
+	}
+	frame := make([]byte, 4+length)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return 0, nil, err
+	}
+	mask, payload := frame[:4], frame[4:]
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return header[0] & 0x0f, payload, nil
+}